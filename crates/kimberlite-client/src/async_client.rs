@@ -354,7 +354,7 @@ impl AsyncClient {
     /// `(rows_affected, log_offset)`.
     pub async fn execute(&self, sql: &str, params: &[QueryParam]) -> ClientResult<(u64, u64)> {
         let response = self.query(sql, params).await?;
-        crate::client::extract_execute_result_for_async(&response).ok_or_else(|| {
+        crate::client::extract_execute_result_for_async(sql, &response).ok_or_else(|| {
             ClientError::server(
                 ErrorCode::InternalError,
                 format!(
@@ -688,6 +688,11 @@ impl Client {
     /// Returns `(rows_affected, log_offset)`. For DDL statements the
     /// rows-affected count is typically 0.
     ///
+    /// A statement with a `RETURNING` clause answers with the returned
+    /// rows, which do not carry the log offset: `execute` counts them as
+    /// the rows affected and reports offset 0. Run such a statement with
+    /// [`Client::query`] to read the rows.
+    ///
     /// # Errors
     ///
     /// Returns [`ClientError::Server`] if the server rejects the statement
@@ -712,7 +717,7 @@ impl Client {
     )]
     pub fn execute(&mut self, sql: &str, params: &[QueryParam]) -> ClientResult<(u64, u64)> {
         let response = self.query(sql, params)?;
-        extract_execute_result(&response).ok_or_else(|| {
+        extract_execute_result(sql, &response).ok_or_else(|| {
             ClientError::server(
                 ErrorCode::InternalError,
                 format!(
@@ -863,6 +868,13 @@ impl Client {
         }
     }
 
+    /// Returns `push` to the front of the push buffer, so that the next
+    /// [`Client::next_push`] yields it again. Callers that consume part
+    /// of a push, such as the first event of a batch, return the rest.
+    pub fn unread_push(&mut self, push: Push) {
+        self.push_buffer.push_front(push);
+    }
+
     /// Reads the next server-pushed frame. Blocks until a push arrives,
     /// EOF, or the read timeout expires.
     ///
@@ -1821,8 +1833,11 @@ impl std::fmt::Debug for Client {
 /// AUDIT-2026-04 S2.1: re-exported under `pub(crate)` so the async
 /// client can share the exact same DML response shape parsing
 /// without duplicating the contract with the server.
-pub(crate) fn extract_execute_result_for_async(response: &QueryResponse) -> Option<(u64, u64)> {
-    extract_execute_result(response)
+pub(crate) fn extract_execute_result_for_async(
+    sql: &str,
+    response: &QueryResponse,
+) -> Option<(u64, u64)> {
+    extract_execute_result(sql, response)
 }
 
 // ============================================================================
@@ -1939,9 +1954,14 @@ fn format_role_list(roles: &[&str]) -> ClientResult<String> {
 
 /// Extracts `(rows_affected, log_offset)` from a server response to a DML
 /// statement. The server returns these as two BigInt columns named
-/// `rows_affected` and `log_offset` — see `kimberlite-server` handler.
-fn extract_execute_result(response: &QueryResponse) -> Option<(u64, u64)> {
+/// `rows_affected` and `log_offset` — see `kimberlite-server` handler —
+/// except for a statement with a `RETURNING` clause, which it answers
+/// with the returned rows, one per affected row and without the offset.
+fn extract_execute_result(sql: &str, response: &QueryResponse) -> Option<(u64, u64)> {
     use kimberlite_wire::QueryValue;
+    if has_returning_clause(sql) {
+        return Some((response.rows.len() as u64, 0));
+    }
     if response.columns.len() != 2 || response.rows.len() != 1 {
         return None;
     }
@@ -1960,6 +1980,33 @@ fn extract_execute_result(response: &QueryResponse) -> Option<(u64, u64)> {
     }
 }
 
+/// Reports whether `sql` has a `RETURNING` clause: the keyword, in any
+/// case, as a whole word outside string literals and quoted identifiers.
+fn has_returning_clause(sql: &str) -> bool {
+    let mut word = String::new();
+    let mut quote = None;
+    for c in sql.chars().chain(std::iter::once(' ')) {
+        if let Some(q) = quote {
+            if c == q {
+                quote = None;
+            }
+            continue;
+        }
+        if c.is_alphanumeric() || c == '_' {
+            word.push(c);
+            continue;
+        }
+        if word.eq_ignore_ascii_case("RETURNING") {
+            return true;
+        }
+        word.clear();
+        if c == '\'' || c == '"' {
+            quote = Some(c);
+        }
+    }
+    false
+}
+
 #[cfg(test)]
 mod client_tests {
     use super::*;
@@ -1971,7 +2018,10 @@ mod client_tests {
             columns: vec!["rows_affected".to_string(), "log_offset".to_string()],
             rows: vec![vec![QueryValue::BigInt(3), QueryValue::BigInt(1024)]],
         };
-        assert_eq!(extract_execute_result(&response), Some((3, 1024)));
+        assert_eq!(
+            extract_execute_result("INSERT INTO t VALUES (1)", &response),
+            Some((3, 1024))
+        );
     }
 
     #[test]
@@ -1983,7 +2033,10 @@ mod client_tests {
                 QueryValue::Text("alice".into()),
             ]],
         };
-        assert_eq!(extract_execute_result(&response), None);
+        assert_eq!(
+            extract_execute_result("SELECT id, name FROM users", &response),
+            None
+        );
     }
 
     #[test]
@@ -1992,7 +2045,33 @@ mod client_tests {
             columns: vec!["rows_affected".to_string(), "log_offset".to_string()],
             rows: vec![],
         };
-        assert_eq!(extract_execute_result(&response), None);
+        assert_eq!(
+            extract_execute_result("INSERT INTO t VALUES (1)", &response),
+            None
+        );
+    }
+
+    #[test]
+    fn extract_execute_result_counts_returned_rows() {
+        let response = QueryResponse {
+            columns: vec!["id".to_string(), "name".to_string()],
+            rows: vec![
+                vec![QueryValue::BigInt(1), QueryValue::Text("alice".into())],
+                vec![QueryValue::BigInt(2), QueryValue::Text("bob".into())],
+            ],
+        };
+        let sql = "DELETE FROM users WHERE active = false returning id, name";
+        assert_eq!(extract_execute_result(sql, &response), Some((2, 0)));
+    }
+
+    #[test]
+    fn has_returning_clause_ignores_literals_and_identifiers() {
+        assert!(has_returning_clause("UPDATE t SET a = 1 RETURNING *"));
+        assert!(!has_returning_clause(
+            "INSERT INTO t (note) VALUES ('RETURNING')"
+        ));
+        assert!(!has_returning_clause("SELECT \"returning\" FROM t"));
+        assert!(!has_returning_clause("SELECT returning_id FROM t"));
     }
 
     // AUDIT-2026-04 S2.2 — reconnect primitives.
@@ -2112,6 +2191,50 @@ mod client_tests {
         assert!(!poisoned, "fresh clients must start unpoisoned");
     }
 
+    #[test]
+    fn unread_push_is_returned_before_buffered_pushes() {
+        use kimberlite_wire::PushPayload;
+        use std::net::TcpListener;
+        let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+        let addr = listener.local_addr().unwrap();
+        let acceptor = std::thread::spawn(move || listener.accept().map(|(s, _)| s));
+        let stream = match TcpStream::connect(addr) {
+            Ok(s) => s,
+            Err(_) => return, // platform flake — informational test
+        };
+        let _peer = acceptor.join();
+
+        let batch = |start: u64, events: Vec<Vec<u8>>| {
+            Push::new(PushPayload::SubscriptionEvents {
+                subscription_id: 7,
+                start_offset: Offset::new(start),
+                events,
+                credits_remaining: 0,
+            })
+        };
+        let mut client = Client {
+            stream,
+            tenant_id: TenantId::new(1),
+            next_request_id: 1,
+            last_request_id: None,
+            read_buf: BytesMut::new(),
+            config: ClientConfig::default(),
+            push_buffer: VecDeque::from([batch(5, vec![b"later".to_vec()])]),
+            peer_addr: Some(addr),
+            reconnect_count: 0,
+            poisoned: false,
+        };
+        client.unread_push(batch(3, vec![b"d".to_vec(), b"e".to_vec()]));
+
+        let starts: Vec<u64> = (0..2)
+            .map(|_| match client.next_push().unwrap().unwrap().payload {
+                PushPayload::SubscriptionEvents { start_offset, .. } => u64::from(start_offset),
+                other => panic!("unexpected push {other:?}"),
+            })
+            .collect();
+        assert_eq!(starts, vec![3, 5]);
+    }
+
     #[test]
     fn reconnect_without_peer_addr_returns_not_connected() {
         // Construct a Client via Default-ish path that doesn't set
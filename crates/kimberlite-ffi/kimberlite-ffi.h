@@ -85,6 +85,10 @@ typedef enum kmb_KmbError {
      Duplicate primary key on INSERT — unique constraint violation
      */
     KMB_KMB_ERROR_KMB_ERR_UNIQUE_CONSTRAINT_VIOLATION = 16,
+    /*
+     Append's expected offset is not the stream's current offset
+     */
+    KMB_KMB_ERROR_KMB_ERR_OFFSET_MISMATCH = 17,
 } kmb_KmbError;
 
 /*
@@ -194,6 +198,11 @@ typedef enum kmb_KmbQueryParamType {
      Timestamp (nanoseconds since epoch)
      */
     KMB_KMB_QUERY_PARAM_TYPE_KMB_PARAM_TIMESTAMP = 4,
+    /*
+     Binary data: `bigint_val` bytes at `text_val`, which need not be
+     NULL-terminated and may contain NUL bytes
+     */
+    KMB_KMB_QUERY_PARAM_TYPE_KMB_PARAM_BYTES = 5,
 } kmb_KmbQueryParamType;
 
 /*
@@ -367,11 +376,13 @@ typedef struct kmb_KmbQueryParam {
      */
     enum kmb_KmbQueryParamType param_type;
     /*
-     BigInt value (used when param_type == KmbParamBigInt)
+     BigInt value (used when param_type == KmbParamBigInt), or the
+     length of a KmbParamBytes value
      */
     int64_t bigint_val;
     /*
-     Text value (NULL-terminated, used when param_type == KmbParamText)
+     Text value (NULL-terminated, used when param_type == KmbParamText),
+     or the data of a KmbParamBytes value
      */
     const char *text_val;
     /*
@@ -1176,6 +1187,29 @@ enum kmb_KmbError kmb_client_read_events(struct kmb_KmbClient *client,
  */
 void kmb_read_result_free(struct kmb_KmbReadResult *result);
 
+/*
+ Get a stream's current length, the offset its next event will have.
+
+ Appends must name this offset as their expected offset, so clients
+ that append without their own expectation read it first.
+
+ # Arguments
+ - `client`: Client handle
+ - `stream_id`: Stream ID
+ - `length_out`: Output parameter for the stream's length
+
+ # Returns
+ - `KMB_OK` on success
+ - Error code on failure
+
+ # Safety
+ - `client` must be valid
+ - `length_out` must be valid pointer
+ */
+enum kmb_KmbError kmb_client_stream_length(struct kmb_KmbClient *client,
+                                           uint64_t stream_id,
+                                           uint64_t *length_out);
+
 /*
  Execute a SQL query against current state.
 
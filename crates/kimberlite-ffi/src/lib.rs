@@ -103,6 +103,8 @@ pub enum KmbError {
     KmbErrUnknown = 15,
     /// Duplicate primary key on INSERT — unique constraint violation
     KmbErrUniqueConstraintViolation = 16,
+    /// Append's expected offset is not the stream's current offset
+    KmbErrOffsetMismatch = 17,
 }
 
 /// Internal wrapper for the Rust client.
@@ -623,6 +625,11 @@ pub unsafe extern "C" fn kmb_subscription_unsubscribe(
 /// Block until the next event for `subscription_id` arrives (or the
 /// subscription closes).
 ///
+/// Events of a pushed batch are returned one per call, in stream order.
+/// Returns `KMB_ERR_TIMEOUT` if nothing arrives within the connection's
+/// read timeout; the subscription stays open and the call can be
+/// repeated.
+///
 /// The returned `KmbSubscriptionEvent` owns heap-allocated data — free it
 /// via `kmb_subscription_event_free`.
 ///
@@ -649,9 +656,21 @@ pub unsafe extern "C" fn kmb_subscription_next(
                         subscription_id: sub,
                         start_offset,
                         mut events,
-                        credits_remaining: _,
+                        credits_remaining,
                     } if sub == subscription_id => {
-                        if let Some(first) = events.drain(..1).next() {
+                        if !events.is_empty() {
+                            let first = events.remove(0);
+                            if !events.is_empty() {
+                                // Keep the rest of the batch for the next call.
+                                wrapper.client.unread_push(kimberlite_wire::Push::new(
+                                    kimberlite_wire::PushPayload::SubscriptionEvents {
+                                        subscription_id: sub,
+                                        start_offset: Offset::new(u64::from(start_offset) + 1),
+                                        events,
+                                        credits_remaining,
+                                    },
+                                ));
+                            }
                             let mut boxed = first.into_boxed_slice();
                             let ptr = boxed.as_mut_ptr();
                             let len = boxed.len();
@@ -687,6 +706,15 @@ pub unsafe extern "C" fn kmb_subscription_next(
                     // Socket EOF / timeout.
                     return KmbError::KmbErrConnectionFailed;
                 }
+                Err(ClientError::Connection(e))
+                    if matches!(
+                        e.kind(),
+                        std::io::ErrorKind::WouldBlock | std::io::ErrorKind::TimedOut
+                    ) =>
+                {
+                    // The read timed out between frames; nothing was lost.
+                    return KmbError::KmbErrTimeout;
+                }
                 Err(e) => return map_error(e),
             }
         }
@@ -2380,6 +2408,9 @@ pub enum KmbQueryParamType {
     KmbParamBoolean = 3,
     /// Timestamp (nanoseconds since epoch)
     KmbParamTimestamp = 4,
+    /// Binary data: `bigint_val` bytes at `text_val`, which need not be
+    /// NULL-terminated and may contain NUL bytes
+    KmbParamBytes = 5,
 }
 
 /// Query parameter value (input to query).
@@ -2387,9 +2418,11 @@ pub enum KmbQueryParamType {
 pub struct KmbQueryParam {
     /// Parameter type
     pub param_type: KmbQueryParamType,
-    /// BigInt value (used when param_type == KmbParamBigInt)
+    /// BigInt value (used when param_type == KmbParamBigInt), or the
+    /// length of a KmbParamBytes value
     pub bigint_val: i64,
-    /// Text value (NULL-terminated, used when param_type == KmbParamText)
+    /// Text value (NULL-terminated, used when param_type == KmbParamText),
+    /// or the data of a KmbParamBytes value
     pub text_val: *const c_char,
     /// Boolean value (used when param_type == KmbParamBoolean)
     pub bool_val: c_int,
@@ -2461,6 +2494,7 @@ fn map_error(err: ClientError) -> KmbError {
                 ErrorCode::QueryParseError => KmbError::KmbErrQuerySyntax,
                 ErrorCode::QueryExecutionError => KmbError::KmbErrQueryExecution,
                 ErrorCode::UniqueConstraintViolation => KmbError::KmbErrUniqueConstraintViolation,
+                ErrorCode::OffsetMismatch => KmbError::KmbErrOffsetMismatch,
                 _ => KmbError::KmbErrInternal,
             }
         }
@@ -2529,6 +2563,19 @@ unsafe fn convert_query_param(param: &KmbQueryParam) -> Result<QueryParam, KmbEr
             }
             KmbQueryParamType::KmbParamBoolean => Ok(QueryParam::Boolean(param.bool_val != 0)),
             KmbQueryParamType::KmbParamTimestamp => Ok(QueryParam::Timestamp(param.timestamp_val)),
+            KmbQueryParamType::KmbParamBytes => {
+                let Ok(len) = usize::try_from(param.bigint_val) else {
+                    return Err(KmbError::KmbErrInternal);
+                };
+                if len == 0 {
+                    return Ok(QueryParam::Bytes(Vec::new()));
+                }
+                if param.text_val.is_null() {
+                    return Err(KmbError::KmbErrNullPointer);
+                }
+                let data = std::slice::from_raw_parts(param.text_val.cast::<u8>(), len);
+                Ok(QueryParam::Bytes(data.to_vec()))
+            }
         }
     }
 }
@@ -3136,6 +3183,46 @@ pub unsafe extern "C" fn kmb_read_result_free(result: *mut KmbReadResult) {
     }
 }
 
+/// Get a stream's current length, the offset its next event will have.
+///
+/// Appends must name this offset as their expected offset, so clients
+/// that append without their own expectation read it first.
+///
+/// # Arguments
+/// - `client`: Client handle
+/// - `stream_id`: Stream ID
+/// - `length_out`: Output parameter for the stream's length
+///
+/// # Returns
+/// - `KMB_OK` on success
+/// - Error code on failure
+///
+/// # Safety
+/// - `client` must be valid
+/// - `length_out` must be valid pointer
+#[unsafe(no_mangle)]
+pub unsafe extern "C" fn kmb_client_stream_length(
+    client: *mut KmbClient,
+    stream_id: u64,
+    length_out: *mut u64,
+) -> KmbError {
+    unsafe {
+        if client.is_null() || length_out.is_null() {
+            return KmbError::KmbErrNullPointer;
+        }
+
+        let wrapper = &mut *(client as *mut ClientWrapper);
+
+        match wrapper.client.stream_length(StreamId::from(stream_id)) {
+            Ok(length) => {
+                *length_out = length;
+                KmbError::KmbOk
+            }
+            Err(e) => map_error(e),
+        }
+    }
+}
+
 /// Execute a SQL query against current state.
 ///
 /// # Arguments
@@ -3373,6 +3460,7 @@ pub unsafe extern "C" fn kmb_error_message(error: KmbError) -> *const c_char {
         KmbError::KmbErrClusterUnavailable => "No cluster replicas available\0",
         KmbError::KmbErrUnknown => "Unknown error\0",
         KmbError::KmbErrUniqueConstraintViolation => "Duplicate primary key\0",
+        KmbError::KmbErrOffsetMismatch => "Stream offset does not match expected offset\0",
     };
 
     msg.as_ptr() as *const c_char
@@ -3584,6 +3672,45 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_convert_query_param_bytes() {
+        unsafe {
+            let data = [0u8, 0xff, 0, b'k'];
+            let param = KmbQueryParam {
+                param_type: KmbQueryParamType::KmbParamBytes,
+                bigint_val: data.len() as i64,
+                text_val: data.as_ptr().cast(),
+                bool_val: 0,
+                timestamp_val: 0,
+            };
+            let result = convert_query_param(&param).unwrap();
+            if let QueryParam::Bytes(b) = result {
+                assert_eq!(b, data);
+            } else {
+                panic!("expected bytes param");
+            }
+
+            let empty = KmbQueryParam {
+                param_type: KmbQueryParamType::KmbParamBytes,
+                bigint_val: 0,
+                text_val: std::ptr::null(),
+                bool_val: 0,
+                timestamp_val: 0,
+            };
+            let result = convert_query_param(&empty).unwrap();
+            assert!(matches!(result, QueryParam::Bytes(b) if b.is_empty()));
+
+            let dangling = KmbQueryParam {
+                bigint_val: 4,
+                ..empty
+            };
+            assert_eq!(
+                convert_query_param(&dangling).unwrap_err(),
+                KmbError::KmbErrNullPointer
+            );
+        }
+    }
+
     #[test]
     fn test_convert_query_value_null() {
         unsafe {
@@ -3721,6 +3848,27 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_stream_length_null_client() {
+        unsafe {
+            let mut length: u64 = 7;
+            let result = kmb_client_stream_length(std::ptr::null_mut(), 1, &mut length);
+            assert_eq!(result, KmbError::KmbErrNullPointer);
+            assert_eq!(length, 7, "length_out must be left untouched on error");
+        }
+    }
+
+    #[test]
+    fn test_stream_length_null_length_out() {
+        unsafe {
+            // The pointer checks run before the client is dereferenced,
+            // so a dangling handle is never touched.
+            let client = std::ptr::NonNull::<KmbClient>::dangling().as_ptr();
+            let result = kmb_client_stream_length(client, 1, std::ptr::null_mut());
+            assert_eq!(result, KmbError::KmbErrNullPointer);
+        }
+    }
+
     #[test]
     fn test_read_result_free_null() {
         unsafe {
@@ -4220,6 +4368,23 @@ mod tests {
             }
         }
 
+        /// Property: Any byte string, NUL bytes included, is passed as-is
+        #[test]
+        fn prop_query_param_bytes(value in prop::collection::vec(any::<u8>(), 0..256)) {
+            unsafe {
+                let param = KmbQueryParam {
+                    param_type: KmbQueryParamType::KmbParamBytes,
+                    bigint_val: value.len() as i64,
+                    text_val: value.as_ptr().cast(),
+                    bool_val: 0,
+                    timestamp_val: 0,
+                };
+
+                let result = convert_query_param(&param).unwrap();
+                prop_assert!(matches!(result, QueryParam::Bytes(b) if b == value));
+            }
+        }
+
         /// Property: Query value BigInt preserves value
         #[test]
         fn prop_query_value_bigint(value in any::<i64>()) {
@@ -1200,7 +1200,7 @@ fn resolve_predicate(predicate: &Predicate, params: &[Value]) -> Result<Resolved
         }),
         Predicate::JsonContains { column, value } => Ok(ResolvedPredicate {
             column: column.clone(),
-            op: ResolvedOp::JsonContains(resolve_value(value, params)?),
+            op: ResolvedOp::JsonContains(json_operand(resolve_value(value, params)?)?),
         }),
         // Subquery predicates are pre-executed and substituted in the
         // top-level query() entry point before reaching the planner.
@@ -1262,6 +1262,21 @@ fn resolve_value(val: &PredicateValue, params: &[Value]) -> Result<Value> {
     }
 }
 
+/// Coerces the right-hand side of `@>` to JSON. The wire protocol has no
+/// JSON parameter type, so remote callers bind documents as TEXT, and a
+/// quoted literal parses as TEXT too.
+fn json_operand(val: Value) -> Result<Value> {
+    match val {
+        Value::Text(s) => serde_json::from_str(&s).map(Value::Json).map_err(|e| {
+            QueryError::TypeMismatch {
+                expected: "JSON document".to_string(),
+                actual: format!("TEXT that is not JSON ({e})"),
+            }
+        }),
+        other => Ok(other),
+    }
+}
+
 /// Sample the system clock for a fresh statement-stable timestamp in
 /// Unix nanoseconds. Lives at the planner boundary on purpose — the
 /// evaluator stays pure (PRESSURECRAFT §1 FCIS), so the clock read is
@@ -480,6 +480,24 @@ fn test_json_contains_operator() {
         .expect("JSON @> filter should succeed");
     assert_eq!(result.rows.len(), 1);
     assert_eq!(result.rows[0][0], Value::BigInt(1));
+
+    // Remote callers bind the document as TEXT; it is parsed as JSON.
+    let result = engine
+        .query(
+            &mut store,
+            "SELECT id FROM json_c WHERE data @> $1",
+            &[Value::Text(r#"{"owner": "bob"}"#.to_string())],
+        )
+        .expect("JSON @> filter with a TEXT document should succeed");
+    assert_eq!(result.rows.len(), 1);
+    assert_eq!(result.rows[0][0], Value::BigInt(2));
+
+    let err = engine.query(
+        &mut store,
+        "SELECT id FROM json_c WHERE data @> $1",
+        &[Value::Text("not json".to_string())],
+    );
+    assert!(err.is_err(), "a TEXT operand that is not JSON is rejected");
 }
 
 // JSON tests - Note: JSON cannot be used in WHERE or ORDER BY
@@ -1,5 +1,6 @@
 //! Request handler that routes requests to Kimberlite.
 
+use bytes::Bytes;
 use kimberlite::{Kimberlite, Offset};
 use kimberlite_query::Value;
 use kimberlite_rbac::enforcement::PolicyEnforcer;
@@ -144,6 +145,7 @@ impl RequestHandler {
                         "query".to_string(),
                         "append".to_string(),
                         "subscribe".to_string(),
+                        "query_param.bytes".to_string(),
                     ],
                 }),
                 new_identity,
@@ -279,13 +281,20 @@ impl RequestHandler {
                         )));
                     }
                     let exec_result = tenant.execute(&req.sql, &params)?;
-                    ResponsePayload::Query(QueryResponse {
-                        columns: vec!["rows_affected".to_string(), "log_offset".to_string()],
-                        rows: vec![vec![
-                            QueryValue::BigInt(exec_result.rows_affected() as i64),
-                            QueryValue::BigInt(exec_result.log_offset().as_u64() as i64),
-                        ]],
-                    })
+                    if let Some(returned) = exec_result.returned() {
+                        // DML with RETURNING answers like a SELECT, with one
+                        // row per affected row, filtered by the same policy.
+                        let base_response = convert_query_result(returned)?;
+                        ResponsePayload::Query(filter_query_response(base_response, &enforcer))
+                    } else {
+                        ResponsePayload::Query(QueryResponse {
+                            columns: vec!["rows_affected".to_string(), "log_offset".to_string()],
+                            rows: vec![vec![
+                                QueryValue::BigInt(exec_result.rows_affected() as i64),
+                                QueryValue::BigInt(exec_result.log_offset().as_u64() as i64),
+                            ]],
+                        })
+                    }
                 }
             }
 
@@ -453,6 +462,7 @@ fn convert_params(params: &[QueryParam]) -> Vec<Value> {
                 let nanos = if *v < 0 { 0 } else { *v as u64 };
                 Value::Timestamp(Timestamp::from_nanos(nanos))
             }
+            QueryParam::Bytes(v) => Value::Bytes(Bytes::copy_from_slice(v)),
         })
         .collect()
 }
@@ -714,4 +724,11 @@ mod tests {
             );
         }
     }
+
+    #[test]
+    fn convert_params_binds_bytes_as_is() {
+        let raw = vec![0u8, 0xff, b'\n', 0x80];
+        let values = convert_params(&[QueryParam::Bytes(raw.clone())]);
+        assert_eq!(values, vec![Value::Bytes(Bytes::from(raw))]);
+    }
 }
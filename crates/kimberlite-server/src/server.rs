@@ -1011,6 +1011,7 @@ impl Server {
             "admin.v1".to_string(),
             "schema.v1".to_string(),
             "server_info.v1".to_string(),
+            "query_param.bytes".to_string(),
         ];
         Response::new(
             request.id,
@@ -608,6 +608,52 @@ mod end_to_end {
             assert_eq!(rows.len(), 1, "single row must read back cleanly");
         });
     }
+
+    /// DML with a `RETURNING` clause answers with the returned rows, not
+    /// the `rows_affected`/`log_offset` pair. `query` must see the rows
+    /// and `execute` must still succeed, counting them as rows affected.
+    #[test]
+    fn test_e2e_dml_returning() {
+        use kimberlite_client::QueryParam;
+
+        run_e2e_test(|port| {
+            let mut client = Client::connect(
+                format!("127.0.0.1:{port}"),
+                TenantId::new(4_300_000),
+                ClientConfig::default(),
+            )
+            .expect("client should connect");
+
+            client
+                .execute("CREATE TABLE beds (id BIGINT PRIMARY KEY, ward TEXT)", &[])
+                .expect("CREATE should succeed");
+
+            let returned = client
+                .query(
+                    "INSERT INTO beds (id, ward) VALUES ($1, $2) RETURNING id, ward",
+                    &[QueryParam::BigInt(1), QueryParam::Text("east".to_string())],
+                )
+                .expect("INSERT ... RETURNING through query() should succeed");
+            assert_eq!(returned.columns, vec!["id", "ward"]);
+            assert_eq!(returned.rows.len(), 1);
+
+            let (rows, _offset) = client
+                .execute(
+                    "INSERT INTO beds (id, ward) VALUES ($1, $2) RETURNING id",
+                    &[QueryParam::BigInt(2), QueryParam::Text("east".to_string())],
+                )
+                .expect("INSERT ... RETURNING through execute() should succeed");
+            assert_eq!(rows, 1);
+
+            let (rows, _offset) = client
+                .execute(
+                    "DELETE FROM beds WHERE ward = $1 RETURNING id",
+                    &[QueryParam::Text("east".to_string())],
+                )
+                .expect("DELETE ... RETURNING through execute() should succeed");
+            assert_eq!(rows, 2);
+        });
+    }
 }
 
 /// Integration test: in a 3-node VSR cluster, a write submitted via the
@@ -41,7 +41,13 @@ pub const MAGIC: u32 = 0x5644_4220;
 ///   bytes, so a v4 payload cannot decode against the v5 schema. v0.6.1
 ///   clients are rejected at the frame validator with
 ///   `UnsupportedVersion(4)` — clean failure, no garbage decode. See
-///   `crates/kimberlite-wire/src/tests.rs::v5_compat`.
+///   `crates/kimberlite-wire/src/tests.rs::v5_compat`. `QueryParam::Bytes`
+///   was later appended as the last variant without a bump: every
+///   payload that predates it decodes unchanged, and a server that
+///   predates it fails to decode a request binding one. Servers that
+///   decode it advertise the `query_param.bytes` capability in their
+///   handshake and `ServerInfo` responses; clients must check for it
+///   before binding one.
 pub const PROTOCOL_VERSION: u16 = 5;
 
 /// Frame header size in bytes (magic + version + length + checksum).
@@ -284,6 +284,8 @@ pub enum QueryParam {
     Boolean(bool),
     /// Timestamp (nanoseconds since epoch).
     Timestamp(i64),
+    /// Binary data, bound to BYTES columns as-is.
+    Bytes(Vec<u8>),
 }
 
 /// Read events request.
@@ -68,6 +68,7 @@ enum FuzzableQueryParam {
     Text(String),
     Boolean(bool),
     Timestamp(i64),
+    Bytes(Vec<u8>),
 }
 
 impl From<FuzzableQueryParam> for kmb_wire::QueryParam {
@@ -78,6 +79,7 @@ impl From<FuzzableQueryParam> for kmb_wire::QueryParam {
             FuzzableQueryParam::Text(s) => Self::Text(s),
             FuzzableQueryParam::Boolean(b) => Self::Boolean(b),
             FuzzableQueryParam::Timestamp(t) => Self::Timestamp(t),
+            FuzzableQueryParam::Bytes(b) => Self::Bytes(b),
         }
     }
 }
@@ -0,0 +1,182 @@
+// Package accessreview produces who-accessed-what reports for periodic
+// access reviews, such as the quarterly reviews of HIPAA §164.308.
+//
+// A Recorder is a client interceptor that records every query,
+// statement, append, read and subscription with the actor and reason of its
+// kimberlite.AuditContext and the data class of the stream touched:
+//
+//	store := accessreview.NewMemoryStore()
+//	rec := accessreview.NewRecorder(store)
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(1),
+//	    kimberlite.WithInterceptors(rec.Interceptor))
+//	rec.ResolveClasses(client.StreamClass)
+//	...
+//	report, err := accessreview.Generate(ctx, store, quarterStart, quarterEnd)
+//	err = report.WriteCSV(w)
+//
+// The server's compliance audit log records consent, erasure and
+// access-control decisions but not the streams and data classes read,
+// so reports are built from accesses recorded by the clients. SQL text
+// is not recorded, since its literals may themselves be protected data.
+package accessreview
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Unclassified labels accesses whose data class is unknown, such as
+// queries.
+const Unclassified = "unclassified"
+
+// Access is one recorded client operation.
+type Access struct {
+	Time    time.Time
+	Actor   string
+	Reason  string
+	Purpose kimberlite.Purpose
+	// Op is the operation name, e.g. kimberlite.OpReadEvents.
+	Op       string
+	StreamID kimberlite.StreamID
+	// Class is the data class of the stream, or Unclassified.
+	Class string
+	// Denied reports an operation refused for lack of permission.
+	Denied bool
+}
+
+// Store keeps recorded accesses.
+type Store interface {
+	Record(ctx context.Context, a Access) error
+	// Accesses returns the accesses in [from, to), in time order.
+	Accesses(ctx context.Context, from, to time.Time) ([]Access, error)
+}
+
+// MemoryStore is an in-memory Store, for tests and short-lived tools.
+type MemoryStore struct {
+	mu       sync.Mutex
+	accesses []Access
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore { return &MemoryStore{} }
+
+// Record implements Store.
+func (m *MemoryStore) Record(_ context.Context, a Access) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accesses = append(m.accesses, a)
+	return nil
+}
+
+// Accesses implements Store.
+func (m *MemoryStore) Accesses(_ context.Context, from, to time.Time) ([]Access, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Access
+	for _, a := range m.accesses {
+		if !a.Time.Before(from) && a.Time.Before(to) {
+			out = append(out, a)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// Recorder records client operations in a Store.
+type Recorder struct {
+	store   Store
+	now     func() time.Time
+	onError func(error)
+
+	mu      sync.RWMutex
+	classes func(kimberlite.StreamID) (kimberlite.DataClass, bool)
+}
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// OnRecordError sets the function called when an access cannot be
+// recorded. Operations are never failed for it.
+func OnRecordError(fn func(error)) RecorderOption {
+	return func(r *Recorder) { r.onError = fn }
+}
+
+// NewRecorder returns a Recorder writing to store.
+func NewRecorder(store Store, opts ...RecorderOption) *Recorder {
+	r := &Recorder{store: store, now: time.Now}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// ResolveClasses sets the function giving the data class of streams,
+// typically the client's StreamClass method.
+func (r *Recorder) ResolveClasses(fn func(kimberlite.StreamID) (kimberlite.DataClass, bool)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.classes = fn
+}
+
+// Interceptor is a kimberlite.Interceptor recording each operation.
+func (r *Recorder) Interceptor(ctx context.Context, op *kimberlite.Operation, req any, next kimberlite.Invoker) error {
+	err := next(ctx, op, req)
+	if !recorded(op.Name) {
+		return err
+	}
+	audit, _ := kimberlite.AuditFromContext(ctx)
+	a := Access{
+		Time:     r.now().UTC(),
+		Actor:    audit.Actor,
+		Reason:   audit.Reason,
+		Purpose:  audit.Purpose,
+		Op:       op.Name,
+		StreamID: op.StreamID,
+		Class:    r.classOf(op),
+		Denied:   errors.Is(err, kimberlite.ErrPermissionDenied),
+	}
+	if rerr := r.store.Record(ctx, a); rerr != nil && r.onError != nil {
+		r.onError(rerr)
+	}
+	return err
+}
+
+// recorded reports whether operations named name access data. Stream
+// creation, catalogue lookups and administrative calls do not.
+func recorded(name string) bool {
+	switch name {
+	case kimberlite.OpQuery, kimberlite.OpExec, kimberlite.OpAppend,
+		kimberlite.OpReadEvents, kimberlite.OpSubscribe:
+		return true
+	}
+	return false
+}
+
+func (r *Recorder) classOf(op *kimberlite.Operation) string {
+	if !touchesStream(op.Name) {
+		return Unclassified
+	}
+	r.mu.RLock()
+	classes := r.classes
+	r.mu.RUnlock()
+	if classes == nil {
+		return Unclassified
+	}
+	if class, ok := classes(op.StreamID); ok {
+		return class.String()
+	}
+	return Unclassified
+}
+
+// touchesStream reports whether operations named name read or write the
+// events of a stream.
+func touchesStream(name string) bool {
+	return name == kimberlite.OpAppend || name == kimberlite.OpReadEvents || name == kimberlite.OpSubscribe
+}
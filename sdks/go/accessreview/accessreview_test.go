@@ -0,0 +1,76 @@
+package accessreview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestReport(t *testing.T) {
+	store := NewMemoryStore()
+	rec := NewRecorder(store)
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	rec.now = func() time.Time { now = now.Add(time.Minute); return now }
+	rec.ResolveClasses(func(id kimberlite.StreamID) (kimberlite.DataClass, bool) {
+		return kimberlite.DataClassRestricted, id == 7
+	})
+
+	ok := func(context.Context, *kimberlite.Operation, any) error { return nil }
+	denied := func(context.Context, *kimberlite.Operation, any) error { return kimberlite.ErrPermissionDenied }
+	ctx := context.Background()
+	nurse := kimberlite.WithAudit(ctx, kimberlite.AuditContext{Actor: "nurse", Reason: "triage"})
+	clerk := kimberlite.WithAudit(ctx, kimberlite.AuditContext{Actor: "clerk", Reason: "billing"})
+
+	calls := []struct {
+		ctx  context.Context
+		op   *kimberlite.Operation
+		next kimberlite.Invoker
+	}{
+		{nurse, &kimberlite.Operation{Name: kimberlite.OpReadEvents, StreamID: 7}, ok},
+		{nurse, &kimberlite.Operation{Name: kimberlite.OpReadEvents, StreamID: 7}, ok},
+		{clerk, &kimberlite.Operation{Name: kimberlite.OpReadEvents, StreamID: 7}, denied},
+		{clerk, &kimberlite.Operation{Name: kimberlite.OpQuery, Statement: "SELECT 1"}, ok},
+		{clerk, &kimberlite.Operation{Name: kimberlite.OpCreateStream}, ok},
+	}
+	for _, c := range calls {
+		if err := rec.Interceptor(c.ctx, c.op, nil, c.next); err != nil && !errors.Is(err, kimberlite.ErrPermissionDenied) {
+			t.Fatalf("interceptor error: %v", err)
+		}
+	}
+
+	report, err := Generate(ctx, store, now.Add(-time.Hour), now.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Users) != 2 || report.Users[0].Actor != "clerk" || report.Users[1].Actor != "nurse" {
+		t.Fatalf("users = %+v", report.Users)
+	}
+	n := report.Users[1].Classes[0]
+	if n.Class != "restricted" || n.Accesses != 2 || n.Denied != 0 || len(n.Streams) != 1 || n.Reasons[0] != "triage" || !n.Last.After(n.First) {
+		t.Fatalf("nurse = %+v", n)
+	}
+	if c := report.Users[0].Classes; len(c) != 2 || c[0].Denied != 1 || c[1].Class != Unclassified {
+		t.Fatalf("clerk = %+v", c)
+	}
+	if len(report.Classes) != 2 || report.Classes[0].Accesses != 3 {
+		t.Fatalf("classes = %+v", report.Classes)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || lines[0] != "actor,data_class,accesses,denied,streams,reasons,first,last" || !strings.HasPrefix(lines[3], "nurse,restricted,2,0,7,triage,") {
+		t.Fatalf("csv:\n%s", buf.String())
+	}
+
+	if empty, _ := Generate(ctx, store, now.Add(time.Hour), now.Add(2*time.Hour)); len(empty.Users) != 0 {
+		t.Fatal("window not applied")
+	}
+}
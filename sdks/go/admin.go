@@ -0,0 +1,181 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AdminClient provisions tenants through the server's administration
+// API. It authenticates with its own credential, which must carry the
+// Admin role, and offers no data operations, so that a control plane
+// holding it cannot read or write tenant data by mistake, and services
+// holding tenant credentials cannot provision:
+//
+//	admin, err := kimberlite.ConnectAdmin(addr, kimberlite.WithTenant(controlTenant),
+//	    kimberlite.WithTokenProvider(adminTokens))
+//	...
+//	tenant, created, err := admin.CreateTenant(ctx, kimberlite.TenantID(customer.ID), customer.Slug)
+//
+// The server registers tenants by ID and name only, in memory, so names
+// do not survive its restart. It has no tenant suspension, and applies
+// the same data classes, regions and limits to every tenant, so an
+// AdminClient cannot configure them per tenant; tenants' own clients
+// enforce residency and data classes with WithRegion and
+// WithDataClassEnforcement.
+type AdminClient struct {
+	c *Client
+}
+
+// ConnectAdmin connects an AdminClient, taking the options of Connect.
+// WithTenant names the tenant the admin credential belongs to, not the
+// tenants it provisions, and the credential is required: either
+// WithToken or WithTokenProvider.
+func ConnectAdmin(addr string, opts ...Option) (*AdminClient, error) {
+	var probe Client
+	for _, opt := range opts {
+		opt(&probe)
+	}
+	if probe.token == "" && probe.tokens == nil && probe.backend == nil {
+		return nil, fmt.Errorf("kimberlite: admin client without a token")
+	}
+	c, err := Connect(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminClient{c: c}, nil
+}
+
+// Close releases the admin client's connection.
+func (a *AdminClient) Close() error {
+	return a.c.Close()
+}
+
+// TenantInfo describes a registered tenant.
+type TenantInfo struct {
+	ID TenantID
+	// Name is empty for tenants registered without one, such as those
+	// the server registered on first use.
+	Name       string
+	TableCount int
+	CreatedAt  time.Time
+}
+
+type tenantInfoJSON struct {
+	TenantID       uint64  `json:"tenant_id"`
+	Name           *string `json:"name"`
+	TableCount     int     `json:"table_count"`
+	CreatedAtNanos uint64  `json:"created_at_nanos"`
+}
+
+func (j tenantInfoJSON) info() *TenantInfo {
+	info := &TenantInfo{ID: TenantID(j.TenantID), TableCount: j.TableCount}
+	if j.Name != nil {
+		info.Name = *j.Name
+	}
+	if j.CreatedAtNanos != 0 {
+		info.CreatedAt = time.Unix(0, int64(j.CreatedAtNanos)).UTC()
+	}
+	return info
+}
+
+// CreateTenant registers the tenant id with name, which may be empty.
+// Registering a tenant again is not an error: created reports whether
+// this call registered it, and renaming a named tenant fails.
+func (a *AdminClient) CreateTenant(ctx context.Context, id TenantID, name string) (info *TenantInfo, created bool, err error) {
+	if id == 0 {
+		return nil, false, ErrTenantRequired
+	}
+	raw, err := a.write(ctx, OpCreateTenant, func() ([]byte, error) { return ffiTenantCreate(a.c.kmbHandle, uint64(id), name) })
+	if err != nil {
+		return nil, false, err
+	}
+	var resp struct {
+		Tenant  tenantInfoJSON `json:"tenant"`
+		Created bool           `json:"created"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, false, fmt.Errorf("kimberlite: decode tenant: %w", err)
+	}
+	return resp.Tenant.info(), resp.Created, nil
+}
+
+// Tenant returns the tenant id, or ErrTenantNotFound.
+func (a *AdminClient) Tenant(ctx context.Context, id TenantID) (*TenantInfo, error) {
+	raw, err := a.call(ctx, OpTenant, func() ([]byte, error) { return ffiTenantGet(a.c.kmbHandle, uint64(id)) })
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Tenant tenantInfoJSON `json:"tenant"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode tenant: %w", err)
+	}
+	return resp.Tenant.info(), nil
+}
+
+// Tenants returns the registered tenants in order of ID.
+func (a *AdminClient) Tenants(ctx context.Context) ([]*TenantInfo, error) {
+	raw, err := a.call(ctx, OpTenants, func() ([]byte, error) { return ffiTenantList(a.c.kmbHandle) })
+	if err != nil {
+		return nil, err
+	}
+	return decodeTenants(raw)
+}
+
+func decodeTenants(raw []byte) ([]*TenantInfo, error) {
+	var resp struct {
+		Tenants []tenantInfoJSON `json:"tenants"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode tenants: %w", err)
+	}
+	out := make([]*TenantInfo, len(resp.Tenants))
+	for i, j := range resp.Tenants {
+		out[i] = j.info()
+	}
+	return out, nil
+}
+
+// DeleteTenant drops the tables of the tenant id and deletes its
+// registration, returning how many tables it dropped. It fails with
+// ErrTenantNotFound if the tenant was not registered, after dropping
+// any tables it had. The tenant's event streams stay in the log, which
+// is append-only.
+func (a *AdminClient) DeleteTenant(ctx context.Context, id TenantID) (tablesDropped int, err error) {
+	raw, err := a.write(ctx, OpDeleteTenant, func() ([]byte, error) { return ffiTenantDelete(a.c.kmbHandle, uint64(id)) })
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		Deleted       bool `json:"deleted"`
+		TablesDropped int  `json:"tables_dropped"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return 0, fmt.Errorf("kimberlite: decode tenant deletion: %w", err)
+	}
+	if !resp.Deleted {
+		return resp.TablesDropped, fmt.Errorf("%w: %d", ErrTenantNotFound, id)
+	}
+	return resp.TablesDropped, nil
+}
+
+// write is call for a call that changes the tenant registry.
+func (a *AdminClient) write(ctx context.Context, op string, fn func() ([]byte, error)) ([]byte, error) {
+	if err := a.c.checkWritable("tenant change"); err != nil {
+		return nil, err
+	}
+	return a.call(ctx, op, fn)
+}
+
+// call runs fn as the operation op of the admin client's connection.
+func (a *AdminClient) call(ctx context.Context, op string, fn func() ([]byte, error)) ([]byte, error) {
+	var raw []byte
+	err := a.c.call(ctx, &Operation{Name: op}, func(context.Context) (err error) {
+		raw, err = fn()
+		return err
+	})
+	return raw, err
+}
@@ -0,0 +1,56 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeTenants(t *testing.T) {
+	tenants, err := decodeTenants([]byte(`{"tenants":[{"tenant_id":1,"name":"acme","table_count":3,"created_at_nanos":1700000000000000000},{"tenant_id":2,"name":null,"table_count":0,"created_at_nanos":null}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 2 {
+		t.Fatalf("tenants %+v", tenants)
+	}
+	if acme := tenants[0]; acme.ID != 1 || acme.Name != "acme" || acme.TableCount != 3 || !acme.CreatedAt.Equal(time.Unix(0, 1700000000000000000)) {
+		t.Fatalf("acme %+v", acme)
+	}
+	if unnamed := tenants[1]; unnamed.ID != 2 || unnamed.Name != "" || !unnamed.CreatedAt.IsZero() {
+		t.Fatalf("unnamed %+v", unnamed)
+	}
+}
+
+func TestAdminClient(t *testing.T) {
+	ctx := context.Background()
+	if _, err := ConnectAdmin("test", WithTenant(1)); err == nil {
+		t.Fatal("ConnectAdmin without a token succeeded")
+	}
+
+	admin, err := ConnectAdmin("test", WithTenant(1), WithBackend(&recordingBackend{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	if _, _, err := admin.CreateTenant(ctx, 0, "acme"); !errors.Is(err, ErrTenantRequired) {
+		t.Fatalf("CreateTenant(0) = %v", err)
+	}
+	// A Backend serves no administration API.
+	if _, _, err := admin.CreateTenant(ctx, 7, "acme"); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("CreateTenant = %v", err)
+	}
+
+	readOnly, err := ConnectAdmin("test", WithTenant(1), WithBackend(&recordingBackend{}), WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readOnly.Close()
+	if _, err := readOnly.DeleteTenant(ctx, 7); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("read-only DeleteTenant = %v", err)
+	}
+	if _, err := readOnly.Tenants(ctx); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("read-only Tenants = %v", err)
+	}
+}
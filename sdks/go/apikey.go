@@ -0,0 +1,63 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// APIKeyInfo describes an API key without its secret.
+type APIKeyInfo struct {
+	ID      string
+	Subject string
+	Tenant  TenantID
+	Roles   []string
+	// ExpiresAt is zero for keys that do not expire.
+	ExpiresAt time.Time
+}
+
+type apiKeyInfoJSON struct {
+	KeyID          string   `json:"key_id"`
+	Subject        string   `json:"subject"`
+	TenantID       uint64   `json:"tenant_id"`
+	Roles          []string `json:"roles"`
+	ExpiresAtNanos *uint64  `json:"expires_at_nanos"`
+}
+
+func (j apiKeyInfoJSON) info() APIKeyInfo {
+	info := APIKeyInfo{ID: j.KeyID, Subject: j.Subject, Tenant: TenantID(j.TenantID), Roles: j.Roles}
+	if j.ExpiresAtNanos != nil && *j.ExpiresAtNanos != 0 {
+		info.ExpiresAt = time.Unix(0, int64(*j.ExpiresAtNanos)).UTC()
+	}
+	return info
+}
+
+// RotateAPIKey atomically replaces the API key oldKey with a new key of
+// the same subject, tenant and roles, and revokes oldKey. The new
+// secret is returned only once; store it before discarding oldKey.
+func (c *Client) RotateAPIKey(ctx context.Context, oldKey string) (newKey string, info APIKeyInfo, err error) {
+	if err := c.checkWritable("API key rotation"); err != nil {
+		return "", APIKeyInfo{}, err
+	}
+	var raw []byte
+	err = c.call(ctx, &Operation{Name: OpRotateAPIKey}, func(context.Context) (err error) {
+		raw, err = ffiAPIKeyRotate(c.kmbHandle, oldKey)
+		return err
+	})
+	if err != nil {
+		return "", APIKeyInfo{}, err
+	}
+	return decodeAPIKeyRotation(raw)
+}
+
+func decodeAPIKeyRotation(raw []byte) (string, APIKeyInfo, error) {
+	var resp struct {
+		NewKey string         `json:"new_key"`
+		Info   apiKeyInfoJSON `json:"info"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return "", APIKeyInfo{}, fmt.Errorf("kimberlite: decode API key rotation: %w", err)
+	}
+	return resp.NewKey, resp.Info.info(), nil
+}
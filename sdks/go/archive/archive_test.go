@@ -0,0 +1,244 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// memSource stamps events with the time they are read, as the client
+// does: the server does not report when events were appended.
+type memSource map[kimberlite.StreamID][][]byte
+
+func (m memSource) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error) {
+	var (
+		out  []kimberlite.Event
+		size uint64
+	)
+	for i := int(from); i < len(m[id]); i++ {
+		if len(out) > 0 && size+uint64(len(m[id][i])) > maxBytes {
+			break
+		}
+		size += uint64(len(m[id][i]))
+		out = append(out, kimberlite.Event{StreamID: id, Offset: kimberlite.Offset(i), Data: m[id][i], Timestamp: time.Now()})
+	}
+	return out, nil
+}
+
+func (m memSource) StreamLength(_ context.Context, id kimberlite.StreamID) (kimberlite.Offset, error) {
+	return kimberlite.Offset(len(m[id])), nil
+}
+
+// growingSource appends an event to each stream before reading it, as
+// a writer racing a backup would.
+type growingSource struct{ memSource }
+
+func (g growingSource) ReadEventsContext(ctx context.Context, id kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error) {
+	g.memSource[id] = append(g.memSource[id], []byte("late"))
+	return g.memSource.ReadEventsContext(ctx, id, from, maxBytes)
+}
+
+func TestSegmentRoundTrip(t *testing.T) {
+	events := []kimberlite.Event{
+		{StreamID: 3, Offset: 5, Data: []byte("a"), Timestamp: time.Unix(1, 0)},
+		{StreamID: 3, Offset: 6, Data: []byte("bc"), Timestamp: time.Unix(2, 0)},
+	}
+	var prev [HashSize]byte
+	prev[0] = 1
+	data, chain, err := EncodeSegment(3, events, prev, time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg, err := DecodeSegment(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seg.ChainHash != chain || seg.FirstOffset != 5 || seg.LastOffset != 6 || len(seg.Events) != 2 ||
+		string(seg.Events[1].Data) != "bc" || !seg.Events[1].Timestamp.IsZero() {
+		t.Fatalf("segment = %+v", seg)
+	}
+
+	// The time events were read is not part of the segment.
+	reread := []kimberlite.Event{events[0], events[1]}
+	reread[0].Timestamp, reread[1].Timestamp = time.Now(), time.Now()
+	if again, _, err := EncodeSegment(3, reread, prev, time.Unix(10, 0)); err != nil || !bytes.Equal(again, data) {
+		t.Fatalf("re-read events encode differently: %v", err)
+	}
+
+	for i := range data {
+		bad := append([]byte(nil), data...)
+		bad[i] ^= 0xff
+		if _, err := DecodeSegment(bad); !errors.Is(err, ErrCorruptSegment) {
+			t.Fatalf("flipping byte %d: err = %v", i, err)
+		}
+	}
+	if _, _, err := EncodeSegment(3, []kimberlite.Event{events[0], {StreamID: 3, Offset: 9}}, prev, time.Now()); err == nil {
+		t.Fatal("non-contiguous offsets accepted")
+	}
+}
+
+func TestExporter(t *testing.T) {
+	ctx := context.Background()
+	src := memSource{7: {[]byte("e0"), []byte("e1"), []byte("e2"), []byte("e3"), []byte("e4")}}
+	dir := t.TempDir()
+	store := NewDirStore(dir)
+	opts := []ExporterOption{WithPrefix("t1/"), WithSegmentBytes(4)}
+
+	e := NewExporter(src, store, []kimberlite.StreamID{7}, opts...)
+	if n, err := e.Poll(ctx); err != nil || n != 2 {
+		t.Fatalf("Poll = %d, %v; want 2 full segments", n, err)
+	}
+	if n, err := e.Flush(ctx); err != nil || n != 1 {
+		t.Fatalf("Flush = %d, %v; want the partial tail", n, err)
+	}
+
+	// A new exporter continues the chain from the store.
+	src[7] = append(src[7], []byte("e5"), []byte("e6"))
+	e = NewExporter(src, store, []kimberlite.StreamID{7}, opts...)
+	if n, err := e.Poll(ctx); err != nil || n != 1 {
+		t.Fatalf("Poll after restart = %d, %v", n, err)
+	}
+
+	var restored []string
+	sum, err := ReadStream(ctx, store, "t1/", 7, func(s *Segment) error {
+		for _, ev := range s.Events {
+			restored = append(restored, string(ev.Data))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Segments != 4 || sum.Events != 7 || sum.LastOffset != 6 || len(restored) != 7 || restored[6] != "e6" {
+		t.Fatalf("summary = %+v, restored = %v", sum, restored)
+	}
+
+	// Removing a segment breaks verification.
+	keys, _ := store.List(ctx, "t1/")
+	if err := os.Remove(filepath.Join(dir, filepath.FromSlash(keys[1]))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyStream(ctx, store, "t1/", 7); !errors.Is(err, ErrCorruptSegment) {
+		t.Fatalf("VerifyStream with missing segment: err = %v", err)
+	}
+}
+
+func TestExporterMaxAge(t *testing.T) {
+	ctx := context.Background()
+	src := memSource{1: {[]byte("x")}}
+	now := time.Unix(1000, 0)
+	e := NewExporter(src, NewDirStore(t.TempDir()), []kimberlite.StreamID{1}, WithMaxSegmentAge(time.Minute))
+	e.now = func() time.Time { return now }
+	if n, _ := e.Poll(ctx); n != 0 {
+		t.Fatalf("young segment written")
+	}
+	now = now.Add(time.Minute)
+	if n, err := e.Poll(ctx); err != nil || n != 1 {
+		t.Fatalf("Poll after max age = %d, %v", n, err)
+	}
+}
+
+// failingSource fails reads of stream 1.
+type failingSource struct{ memSource }
+
+func (f failingSource) ReadEventsContext(ctx context.Context, id kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error) {
+	if id == 1 {
+		return nil, kimberlite.ErrConnectionFailed
+	}
+	return f.memSource.ReadEventsContext(ctx, id, from, maxBytes)
+}
+
+func waitBackup(t *testing.T, store ObjectStore, id string) *Backup {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		b, err := BackupStatus(context.Background(), store, "t1/", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.State != BackupRunning {
+			return b
+		}
+	}
+	t.Fatalf("backup %s still running", id)
+	return nil
+}
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	src := memSource{
+		1: {[]byte("a0"), []byte("a1"), []byte("a2"), []byte("a3")},
+		2: {[]byte("b0")},
+		3: nil,
+	}
+	dir := t.TempDir()
+	store := NewDirStore(dir)
+
+	// The backup captures the streams as long as they were when it
+	// started, leaving out the events appended while it runs.
+	grow := growingSource{memSource{1: src[1][:4:4], 2: src[2][:1:1]}}
+	b, err := StartBackup(ctx, grow, store, []kimberlite.StreamID{1, 2, 3}, WithBackupPrefix("t1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.State != BackupRunning || len(b.Streams) != 3 {
+		t.Fatalf("started backup %+v", b)
+	}
+	done := waitBackup(t, store, b.ID)
+	if done.State != BackupComplete || done.FinishedAt.IsZero() {
+		t.Fatalf("backup %+v", done)
+	}
+	if s := done.Streams[0]; !s.Done || s.Length != 4 || s.Events != 4 || s.LastOffset != 3 || s.Bytes != 8 {
+		t.Fatalf("stream 1 %+v", s)
+	}
+	if s := done.Streams[2]; !s.Done || s.Events != 0 || s.Segments != 0 {
+		t.Fatalf("empty stream %+v", s)
+	}
+	if _, err := VerifyBackup(ctx, store, "t1/", b.ID); err != nil {
+		t.Fatalf("VerifyBackup = %v", err)
+	}
+	var restored []string
+	ReadStream(ctx, store, BackupPrefix("t1/", b.ID), 1, func(s *Segment) error {
+		for _, ev := range s.Events {
+			restored = append(restored, string(ev.Data))
+		}
+		return nil
+	})
+	if len(restored) != 4 || restored[3] != "a3" {
+		t.Fatalf("restored %v", restored)
+	}
+
+	// A backup whose stream cannot be read fails.
+	failed, err := StartBackup(ctx, failingSource{src}, store, []kimberlite.StreamID{2, 1}, WithBackupPrefix("t1/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := waitBackup(t, store, failed.ID); f.State != BackupFailed || f.Error == "" || !f.Streams[0].Done || f.Streams[1].Done {
+		t.Fatalf("failed backup %+v", f)
+	}
+	if _, err := VerifyBackup(ctx, store, "t1/", failed.ID); err == nil {
+		t.Fatal("failed backup verified")
+	}
+
+	list, err := ListBackups(ctx, store, "t1/")
+	if err != nil || len(list) != 2 || list[0].ID != b.ID || list[1].ID != failed.ID {
+		t.Fatalf("ListBackups = %v, %v", list, err)
+	}
+	if _, err := BackupStatus(ctx, store, "t1/", "missing"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("BackupStatus of a missing backup = %v", err)
+	}
+
+	// Removing a segment of a complete backup fails verification.
+	keys, _ := store.List(ctx, BackupPrefix("t1/", b.ID)+"stream-1/")
+	if err := os.Remove(filepath.Join(dir, filepath.FromSlash(keys[0]))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyBackup(ctx, store, "t1/", b.ID); !errors.Is(err, ErrCorruptSegment) {
+		t.Fatalf("VerifyBackup with missing segment = %v", err)
+	}
+}
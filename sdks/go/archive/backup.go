@@ -0,0 +1,291 @@
+package archive
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// BackupState is the state of a backup.
+type BackupState string
+
+// Backup states.
+const (
+	BackupRunning  BackupState = "running"
+	BackupComplete BackupState = "complete"
+	BackupFailed   BackupState = "failed"
+)
+
+// manifestName is the object, under a backup's prefix, recording it.
+const manifestName = "backup.json"
+
+// Backup records a backup started by StartBackup. It is stored as JSON
+// beside the backup's segments and rewritten as the backup progresses.
+type Backup struct {
+	ID        string      `json:"id"`
+	State     BackupState `json:"state"`
+	StartedAt time.Time   `json:"started_at"`
+	// UpdatedAt is when the record was last written. A running backup
+	// whose UpdatedAt stops advancing was abandoned by its process.
+	UpdatedAt time.Time `json:"updated_at"`
+	// FinishedAt is when the backup completed or failed.
+	FinishedAt time.Time      `json:"finished_at"`
+	Streams    []BackupStream `json:"streams"`
+	// Error is why a failed backup failed.
+	Error string `json:"error,omitempty"`
+}
+
+// BackupStream records the backup of one stream.
+type BackupStream struct {
+	Stream kimberlite.StreamID `json:"stream"`
+	// Length is the stream's length when the backup started: the
+	// backup holds its events at offsets below Length.
+	Length kimberlite.Offset `json:"length"`
+	// Done reports whether the stream has been backed up.
+	Done     bool   `json:"done"`
+	Segments int    `json:"segments"`
+	Events   int    `json:"events"`
+	Bytes    uint64 `json:"bytes"`
+	// LastOffset is the offset of the last event backed up, if Events is
+	// not zero.
+	LastOffset kimberlite.Offset `json:"last_offset"`
+	// ChainHash is the hex chain hash of the last segment, checked by
+	// VerifyBackup.
+	ChainHash string `json:"chain_hash"`
+}
+
+// BackupSource is the source of a backup's events. *kimberlite.Client
+// implements it.
+type BackupSource interface {
+	connect.EventSource
+	StreamLength(ctx context.Context, streamID kimberlite.StreamID) (kimberlite.Offset, error)
+}
+
+// BackupOption configures StartBackup.
+type BackupOption func(*backupConfig)
+
+type backupConfig struct {
+	prefix       string
+	segmentBytes uint64
+	now          func() time.Time
+}
+
+// WithBackupPrefix sets the key prefix backups are stored under, the
+// prefix to pass ListBackups, BackupStatus and VerifyBackup.
+func WithBackupPrefix(prefix string) BackupOption {
+	return func(c *backupConfig) { c.prefix = prefix }
+}
+
+// BackupPrefix returns the key prefix the segments of backup id are
+// stored under, for ReadStream to restore a stream from it.
+func BackupPrefix(prefix, id string) string {
+	return prefix + "backups/" + id + "/"
+}
+
+// StartBackup starts backing up streams from source to store and
+// returns the backup's record once it is stored. The backup runs in the
+// background until it completes, or fails, including when ctx is done;
+// poll BackupStatus to follow it, and VerifyBackup to check a complete
+// backup against its segments.
+//
+// Each backup is a full copy, in segments like an Exporter's, of the
+// streams as long as they were when StartBackup was called: it reads
+// each stream's length before it returns and backs up the events below
+// it, so events appended while the backup runs are left out. The
+// lengths are read one stream after another, so streams written to
+// meanwhile are captured at slightly different moments. The server has
+// no online backup of its own; back up its data directory, tables
+// included, with "kimberlite backup create" on the host.
+func StartBackup(ctx context.Context, source BackupSource, store ObjectStore, streams []kimberlite.StreamID, opts ...BackupOption) (*Backup, error) {
+	cfg := backupConfig{segmentBytes: 64 << 20, now: time.Now}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	started := cfg.now().UTC()
+	b := &Backup{
+		ID:        started.Format("20060102T150405Z") + "-" + kimberlite.NewEventID()[:8],
+		State:     BackupRunning,
+		StartedAt: started,
+		UpdatedAt: started,
+	}
+	for _, id := range streams {
+		length, err := source.StreamLength(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: back up stream %d: %w", id, err)
+		}
+		b.Streams = append(b.Streams, BackupStream{Stream: id, Length: length})
+	}
+	if err := putBackup(ctx, store, cfg.prefix, b); err != nil {
+		return nil, err
+	}
+	out := *b
+	out.Streams = slices.Clone(b.Streams)
+	go runBackup(ctx, source, store, &cfg, b)
+	return &out, nil
+}
+
+func runBackup(ctx context.Context, source BackupSource, store ObjectStore, cfg *backupConfig, b *Backup) {
+	err := func() error {
+		for i := range b.Streams {
+			if err := backupStream(ctx, source, store, cfg, b, &b.Streams[i]); err != nil {
+				return err
+			}
+			b.UpdatedAt = cfg.now().UTC()
+			if err := putBackup(ctx, store, cfg.prefix, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	b.State = BackupComplete
+	if err != nil {
+		b.State, b.Error = BackupFailed, err.Error()
+	}
+	b.FinishedAt = cfg.now().UTC()
+	b.UpdatedAt = b.FinishedAt
+	// Record the outcome even if ctx is done.
+	final, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	_ = putBackup(final, store, cfg.prefix, b)
+}
+
+// backupStream writes the events of s.Stream below s.Length as
+// segments under the backup's prefix.
+func backupStream(ctx context.Context, source BackupSource, store ObjectStore, cfg *backupConfig, b *Backup, s *BackupStream) error {
+	var (
+		chain   [HashSize]byte
+		next    kimberlite.Offset
+		pending []kimberlite.Event
+		size    uint64
+	)
+	write := func() error {
+		data, sum, err := EncodeSegment(s.Stream, pending, chain, cfg.now())
+		if err != nil {
+			return err
+		}
+		first, last := pending[0].Offset, pending[len(pending)-1].Offset
+		if err := store.Put(ctx, SegmentKey(BackupPrefix(cfg.prefix, b.ID), s.Stream, first, last), data); err != nil {
+			return fmt.Errorf("kimberlite: back up stream %d offsets %d-%d: %w", s.Stream, first, last, err)
+		}
+		chain = sum
+		s.Segments++
+		s.Events += len(pending)
+		s.Bytes += size
+		s.LastOffset = last
+		pending, size = nil, 0
+		return nil
+	}
+	for next < s.Length {
+		events, err := source.ReadEventsContext(ctx, s.Stream, next, 1<<20)
+		if err != nil {
+			return fmt.Errorf("kimberlite: back up stream %d: %w", s.Stream, err)
+		}
+		if len(events) == 0 {
+			return fmt.Errorf("kimberlite: back up stream %d: no events at offset %d of %d", s.Stream, next, s.Length)
+		}
+		for _, ev := range events {
+			if ev.Offset >= s.Length {
+				break
+			}
+			pending = append(pending, ev)
+			size += uint64(len(ev.Data))
+			next = ev.Offset + 1
+			if size >= cfg.segmentBytes {
+				if err := write(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if len(pending) > 0 {
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	s.Done, s.ChainHash = true, hex.EncodeToString(chain[:])
+	return nil
+}
+
+func putBackup(ctx context.Context, store ObjectStore, prefix string, b *Backup) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if err := store.Put(ctx, BackupPrefix(prefix, b.ID)+manifestName, data); err != nil {
+		return fmt.Errorf("kimberlite: record backup %s: %w", b.ID, err)
+	}
+	return nil
+}
+
+// BackupStatus returns the record of backup id under prefix, or an
+// error wrapping ErrObjectNotFound if there is none.
+func BackupStatus(ctx context.Context, store ObjectStore, prefix, id string) (*Backup, error) {
+	key := BackupPrefix(prefix, id) + manifestName
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var b Backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode %s: %w", key, err)
+	}
+	return &b, nil
+}
+
+// ListBackups returns the records of the backups under prefix, oldest
+// first.
+func ListBackups(ctx context.Context, store ObjectStore, prefix string) ([]*Backup, error) {
+	keys, err := store.List(ctx, prefix+"backups/")
+	if err != nil {
+		return nil, err
+	}
+	var out []*Backup
+	for _, key := range keys {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix+"backups/"), "/"+manifestName)
+		if !ok || strings.Contains(id, "/") {
+			continue
+		}
+		b, err := BackupStatus(ctx, store, prefix, id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	slices.SortStableFunc(out, func(a, b *Backup) int {
+		return cmp.Compare(a.StartedAt.UnixNano(), b.StartedAt.UnixNano())
+	})
+	return out, nil
+}
+
+// VerifyBackup checks that backup id under prefix is complete and that
+// the segments of each of its streams verify, as VerifyStream verifies
+// them, hold every event below the stream's recorded length, and match
+// its record. It returns the backup's record.
+func VerifyBackup(ctx context.Context, store ObjectStore, prefix, id string) (*Backup, error) {
+	b, err := BackupStatus(ctx, store, prefix, id)
+	if err != nil {
+		return nil, err
+	}
+	if b.State != BackupComplete {
+		return b, fmt.Errorf("kimberlite: backup %s is %s", id, b.State)
+	}
+	for _, s := range b.Streams {
+		sum, err := VerifyStream(ctx, store, BackupPrefix(prefix, id), s.Stream)
+		if err != nil {
+			return b, err
+		}
+		if sum.Segments != s.Segments || sum.Events != s.Events || kimberlite.Offset(s.Events) != s.Length ||
+			hex.EncodeToString(sum.ChainHash[:]) != s.ChainHash {
+			return b, fmt.Errorf("%w: backup %s stream %d does not match its record", ErrCorruptSegment, id, s.Stream)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,274 @@
+package archive
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// Exporter tails streams and writes their events to an ObjectStore as
+// hash-chained segments. Its position in each stream is recovered from
+// the last segment in the store, so an exporter needs no state of its
+// own and a restarted one continues the chain. It is not safe for
+// concurrent use, and each stream should have a single exporter.
+type Exporter struct {
+	source       connect.EventSource
+	store        ObjectStore
+	streams      []kimberlite.StreamID
+	prefix       string
+	segmentBytes uint64
+	maxAge       time.Duration
+	interval     time.Duration
+	now          func() time.Time
+
+	state map[kimberlite.StreamID]*streamState
+}
+
+type streamState struct {
+	next         kimberlite.Offset
+	chain        [HashSize]byte
+	pending      []kimberlite.Event
+	pendingBytes uint64
+	pendingSince time.Time
+}
+
+// ExporterOption configures an Exporter.
+type ExporterOption func(*Exporter)
+
+// WithPrefix sets the key prefix segments are stored under, e.g.
+// "kimberlite/tenant-1/".
+func WithPrefix(prefix string) ExporterOption {
+	return func(e *Exporter) { e.prefix = prefix }
+}
+
+// WithSegmentBytes sets the payload size at which a segment is closed.
+// The default is 64 MiB.
+func WithSegmentBytes(n uint64) ExporterOption {
+	return func(e *Exporter) { e.segmentBytes = n }
+}
+
+// WithMaxSegmentAge closes a smaller segment once its first event has
+// been pending for d, bounding how far the archive lags a quiet
+// stream. The default is one hour.
+func WithMaxSegmentAge(d time.Duration) ExporterOption {
+	return func(e *Exporter) { e.maxAge = d }
+}
+
+// WithExportInterval sets how long Run waits between polls. The
+// default is ten seconds.
+func WithExportInterval(d time.Duration) ExporterOption {
+	return func(e *Exporter) { e.interval = d }
+}
+
+// NewExporter returns an Exporter archiving streams from source to store.
+func NewExporter(source connect.EventSource, store ObjectStore, streams []kimberlite.StreamID, opts ...ExporterOption) *Exporter {
+	e := &Exporter{
+		source:       source,
+		store:        store,
+		streams:      append([]kimberlite.StreamID(nil), streams...),
+		segmentBytes: 64 << 20,
+		maxAge:       time.Hour,
+		interval:     10 * time.Second,
+		now:          time.Now,
+		state:        make(map[kimberlite.StreamID]*streamState),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Run exports until ctx is done or an error occurs. Events still
+// pending when it returns are exported by the next Run; call Flush to
+// write them first.
+func (e *Exporter) Run(ctx context.Context) error {
+	for {
+		if _, err := e.Poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+// Poll reads every stream to its end and writes the segments that are
+// closed, by size or age. It returns the number of segments written.
+func (e *Exporter) Poll(ctx context.Context) (int, error) {
+	return e.export(ctx, false)
+}
+
+// Flush is like Poll but also writes each stream's pending events as a
+// final, smaller segment.
+func (e *Exporter) Flush(ctx context.Context) (int, error) {
+	return e.export(ctx, true)
+}
+
+func (e *Exporter) export(ctx context.Context, flush bool) (int, error) {
+	var written int
+	for _, id := range e.streams {
+		n, err := e.exportStream(ctx, id, flush)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (e *Exporter) exportStream(ctx context.Context, id kimberlite.StreamID, flush bool) (int, error) {
+	st, err := e.stream(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	var written int
+	for {
+		from := st.next + kimberlite.Offset(len(st.pending))
+		events, err := e.source.ReadEventsContext(ctx, id, from, 1<<20)
+		if err != nil {
+			return written, err
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, ev := range events {
+			if len(st.pending) == 0 {
+				st.pendingSince = e.now()
+			}
+			st.pending = append(st.pending, ev)
+			st.pendingBytes += uint64(len(ev.Data))
+			if st.pendingBytes >= e.segmentBytes {
+				if err := e.writeSegment(ctx, id, st); err != nil {
+					return written, err
+				}
+				written++
+			}
+		}
+	}
+	if len(st.pending) > 0 && (flush || e.now().Sub(st.pendingSince) >= e.maxAge) {
+		if err := e.writeSegment(ctx, id, st); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+func (e *Exporter) writeSegment(ctx context.Context, id kimberlite.StreamID, st *streamState) error {
+	data, chain, err := EncodeSegment(id, st.pending, st.chain, e.now())
+	if err != nil {
+		return err
+	}
+	last := st.pending[len(st.pending)-1].Offset
+	if err := e.store.Put(ctx, SegmentKey(e.prefix, id, st.next, last), data); err != nil {
+		return fmt.Errorf("kimberlite: archive stream %d offsets %d-%d: %w", id, st.next, last, err)
+	}
+	st.next, st.chain = last+1, chain
+	st.pending, st.pendingBytes = nil, 0
+	return nil
+}
+
+// stream returns the export state of id, recovering it from the last
+// archived segment on first use.
+func (e *Exporter) stream(ctx context.Context, id kimberlite.StreamID) (*streamState, error) {
+	if st, ok := e.state[id]; ok {
+		return st, nil
+	}
+	st := &streamState{}
+	keys, err := e.store.List(ctx, e.prefix+streamPrefix(id))
+	if err != nil {
+		return nil, err
+	}
+	keys = segmentKeys(keys)
+	if len(keys) > 0 {
+		data, err := e.store.Get(ctx, keys[len(keys)-1])
+		if err != nil {
+			return nil, err
+		}
+		seg, err := DecodeSegment(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keys[len(keys)-1], err)
+		}
+		st.next, st.chain = seg.LastOffset+1, seg.ChainHash
+	}
+	e.state[id] = st
+	return st, nil
+}
+
+func segmentKeys(keys []string) []string {
+	out := keys[:0:0]
+	for _, k := range keys {
+		if strings.HasSuffix(k, Extension) {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// StreamSummary describes an archived stream verified by VerifyStream.
+type StreamSummary struct {
+	Segments   int
+	Events     int
+	LastOffset kimberlite.Offset
+	ChainHash  [HashSize]byte
+}
+
+// ReadStream calls fn with each archived segment of stream, in order,
+// after verifying it and its link to the segment before: the first
+// segment must start at offset 0 from the zero hash, and each later one
+// must continue the previous segment's offsets and chain hash. Use it
+// to restore a stream, or to feed an archive to another system.
+func ReadStream(ctx context.Context, store ObjectStore, prefix string, stream kimberlite.StreamID, fn func(*Segment) error) (StreamSummary, error) {
+	var sum StreamSummary
+	keys, err := store.List(ctx, prefix+streamPrefix(stream))
+	if err != nil {
+		return sum, err
+	}
+	for _, key := range segmentKeys(keys) {
+		data, err := store.Get(ctx, key)
+		if err != nil {
+			return sum, err
+		}
+		seg, err := DecodeSegment(data)
+		if err != nil {
+			return sum, fmt.Errorf("%s: %w", key, err)
+		}
+		var first kimberlite.Offset
+		if sum.Segments > 0 {
+			first = sum.LastOffset + 1
+		}
+		switch {
+		case seg.Stream != stream:
+			return sum, fmt.Errorf("%w: %s: belongs to stream %d", ErrCorruptSegment, key, seg.Stream)
+		case key != SegmentKey(prefix, stream, seg.FirstOffset, seg.LastOffset):
+			return sum, fmt.Errorf("%w: %s: key does not match header", ErrCorruptSegment, key)
+		case seg.FirstOffset != first:
+			return sum, fmt.Errorf("%w: %s: expected first offset %d, got %d", ErrCorruptSegment, key, first, seg.FirstOffset)
+		case seg.PrevHash != hex.EncodeToString(sum.ChainHash[:]):
+			return sum, fmt.Errorf("%w: %s: broken hash chain", ErrCorruptSegment, key)
+		}
+		if fn != nil {
+			if err := fn(seg); err != nil {
+				return sum, err
+			}
+		}
+		sum.Segments++
+		sum.Events += seg.Count
+		sum.LastOffset = seg.LastOffset
+		sum.ChainHash = seg.ChainHash
+	}
+	return sum, nil
+}
+
+// VerifyStream verifies every archived segment of stream and the chain
+// linking them.
+func VerifyStream(ctx context.Context, store ObjectStore, prefix string, stream kimberlite.StreamID) (StreamSummary, error) {
+	return ReadStream(ctx, store, prefix, stream, nil)
+}
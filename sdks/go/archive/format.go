@@ -0,0 +1,239 @@
+// Package archive exports Kimberlite streams to object storage for
+// long-term retention and disaster recovery independent of the
+// server's own backups.
+//
+// An Exporter tails streams and writes their events in segments: each
+// segment is one object holding a contiguous, closed range of one
+// stream's offsets. Segments are hash-chained, each starting from the
+// final hash of the one before it, so a missing, reordered or altered
+// segment is detected by VerifyStream.
+//
+// StartBackup writes the same segments as a one-off copy of streams as
+// long as they were when it started, recorded beside them for
+// ListBackups, BackupStatus and VerifyBackup, for jobs that take and
+// check backups on a schedule.
+//
+// # Segment format
+//
+// All integers are big-endian.
+//
+//	magic     "KMBA" 0x02
+//	header    uvarint length, then that many bytes of JSON:
+//	          {"stream": N, "first_offset": A, "last_offset": B,
+//	           "count": C, "prev_hash": "<hex>", "created_at": "<RFC 3339>"}
+//	records   C times:
+//	          offset uint64 | length uint32 | data
+//	footer    "KMBE" | chain hash [32] | file hash [32]
+//
+// Offsets are strictly increasing and contiguous across the segments of
+// a stream. The chain hash is computed over the records:
+//
+//	h0 = prev_hash (32 zero bytes for a stream's first segment)
+//	hi = SHA-256(hi-1 || offset || length || data)
+//
+// and stored as the final hi. The file hash is the SHA-256 of every
+// byte before it, footer magic and chain hash included.
+//
+// Records carry no event time: the Timestamp of events read from the
+// server is when they were read, not appended, and would differ between
+// two exports of the same events. created_at is when the segment was
+// written. Version 1 segments, which recorded the read time in each
+// record, are rejected as corrupt.
+//
+// Segments are stored under
+//
+//	<prefix>stream-<stream id>/<first offset>-<last offset>.kmba
+//
+// with offsets zero-padded to 20 digits so keys sort in log order.
+package archive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// HashSize is the size of chain and file hashes.
+const HashSize = sha256.Size
+
+// Extension is the object key suffix of segments.
+const Extension = ".kmba"
+
+var (
+	segmentMagic = []byte{'K', 'M', 'B', 'A', 0x02}
+	footerMagic  = []byte{'K', 'M', 'B', 'E'}
+)
+
+// ErrCorruptSegment is returned for segments that are malformed or fail
+// their integrity checks.
+var ErrCorruptSegment = errors.New("kimberlite: corrupt archive segment")
+
+// Header describes a segment.
+type Header struct {
+	Stream      kimberlite.StreamID `json:"stream"`
+	FirstOffset kimberlite.Offset   `json:"first_offset"`
+	LastOffset  kimberlite.Offset   `json:"last_offset"`
+	Count       int                 `json:"count"`
+	PrevHash    string              `json:"prev_hash"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// Segment is a decoded, verified segment.
+type Segment struct {
+	Header
+	// Events are the segment's events, with a zero Timestamp.
+	Events []kimberlite.Event
+	// ChainHash is the chain hash after the segment's last record; the
+	// next segment's PrevHash.
+	ChainHash [HashSize]byte
+}
+
+// SegmentKey returns the object key of the segment of stream covering
+// first through last.
+func SegmentKey(prefix string, stream kimberlite.StreamID, first, last kimberlite.Offset) string {
+	return fmt.Sprintf("%s%s%020d-%020d%s", prefix, streamPrefix(stream), first, last, Extension)
+}
+
+func streamPrefix(stream kimberlite.StreamID) string {
+	return "stream-" + strconv.FormatUint(uint64(stream), 10) + "/"
+}
+
+// EncodeSegment encodes events, which must be non-empty, contiguous and
+// all from stream, as a segment chained from prev. It returns the
+// encoded segment and its chain hash.
+func EncodeSegment(stream kimberlite.StreamID, events []kimberlite.Event, prev [HashSize]byte, createdAt time.Time) ([]byte, [HashSize]byte, error) {
+	if len(events) == 0 {
+		return nil, prev, errors.New("kimberlite: empty archive segment")
+	}
+	for i, ev := range events {
+		if ev.StreamID != stream {
+			return nil, prev, fmt.Errorf("kimberlite: archive segment for stream %d contains event of stream %d", stream, ev.StreamID)
+		}
+		if i > 0 && ev.Offset != events[i-1].Offset+1 {
+			return nil, prev, fmt.Errorf("kimberlite: archive segment offsets not contiguous at %d", ev.Offset)
+		}
+	}
+	hdr, err := json.Marshal(Header{
+		Stream:      stream,
+		FirstOffset: events[0].Offset,
+		LastOffset:  events[len(events)-1].Offset,
+		Count:       len(events),
+		PrevHash:    hex.EncodeToString(prev[:]),
+		CreatedAt:   createdAt.UTC(),
+	})
+	if err != nil {
+		return nil, prev, err
+	}
+
+	var b bytes.Buffer
+	b.Write(segmentMagic)
+	b.Write(binary.AppendUvarint(nil, uint64(len(hdr))))
+	b.Write(hdr)
+	chain := prev
+	for _, ev := range events {
+		rec := appendRecordPrefix(nil, ev)
+		b.Write(rec)
+		b.Write(ev.Data)
+		chain = chainHash(chain, rec, ev.Data)
+	}
+	b.Write(footerMagic)
+	b.Write(chain[:])
+	sum := sha256.Sum256(b.Bytes())
+	b.Write(sum[:])
+	return b.Bytes(), chain, nil
+}
+
+// DecodeSegment decodes data and verifies its file hash, record chain
+// and offsets. It does not check the segment's place in the stream;
+// see VerifyStream.
+func DecodeSegment(data []byte) (*Segment, error) {
+	corrupt := func(what string) (*Segment, error) {
+		return nil, fmt.Errorf("%w: %s", ErrCorruptSegment, what)
+	}
+	if len(data) < len(segmentMagic)+len(footerMagic)+2*HashSize || !bytes.HasPrefix(data, segmentMagic) {
+		return corrupt("bad magic")
+	}
+	body := data[:len(data)-HashSize]
+	if sum := sha256.Sum256(body); !bytes.Equal(sum[:], data[len(body):]) {
+		return corrupt("file hash mismatch")
+	}
+	footer := body[len(body)-len(footerMagic)-HashSize:]
+	if !bytes.HasPrefix(footer, footerMagic) {
+		return corrupt("bad footer")
+	}
+	rest := body[len(segmentMagic) : len(body)-len(footer)]
+
+	n, k := binary.Uvarint(rest)
+	if k <= 0 || n > uint64(len(rest)-k) {
+		return corrupt("bad header length")
+	}
+	var seg Segment
+	if err := json.Unmarshal(rest[k:k+int(n)], &seg.Header); err != nil {
+		return corrupt("bad header: " + err.Error())
+	}
+	rest = rest[k+int(n):]
+	prev, err := hex.DecodeString(seg.PrevHash)
+	if err != nil || len(prev) != HashSize {
+		return corrupt("bad prev_hash")
+	}
+	copy(seg.ChainHash[:], prev)
+
+	for len(rest) > 0 {
+		if len(rest) < recordPrefixSize {
+			return corrupt("truncated record")
+		}
+		size := int(binary.BigEndian.Uint32(rest[8:12]))
+		if size > len(rest)-recordPrefixSize {
+			return corrupt("truncated record")
+		}
+		ev := kimberlite.Event{
+			StreamID: seg.Stream,
+			Offset:   kimberlite.Offset(binary.BigEndian.Uint64(rest[0:8])),
+			Data:     append([]byte(nil), rest[recordPrefixSize:recordPrefixSize+size]...),
+		}
+		seg.ChainHash = chainHash(seg.ChainHash, rest[:recordPrefixSize], ev.Data)
+		seg.Events = append(seg.Events, ev)
+		rest = rest[recordPrefixSize+size:]
+	}
+
+	if !bytes.Equal(seg.ChainHash[:], footer[len(footerMagic):]) {
+		return corrupt("chain hash mismatch")
+	}
+	if len(seg.Events) != seg.Count || seg.Count == 0 {
+		return corrupt("record count mismatch")
+	}
+	for i, ev := range seg.Events {
+		if ev.Offset != seg.FirstOffset+kimberlite.Offset(i) {
+			return corrupt("offsets not contiguous")
+		}
+	}
+	if seg.Events[len(seg.Events)-1].Offset != seg.LastOffset {
+		return corrupt("last offset mismatch")
+	}
+	return &seg, nil
+}
+
+const recordPrefixSize = 8 + 4
+
+func appendRecordPrefix(b []byte, ev kimberlite.Event) []byte {
+	b = binary.BigEndian.AppendUint64(b, uint64(ev.Offset))
+	return binary.BigEndian.AppendUint32(b, uint32(len(ev.Data)))
+}
+
+func chainHash(prev [HashSize]byte, prefix, data []byte) [HashSize]byte {
+	h := sha256.New()
+	h.Write(prev[:])
+	h.Write(prefix)
+	h.Write(data)
+	var out [HashSize]byte
+	h.Sum(out[:0])
+	return out
+}
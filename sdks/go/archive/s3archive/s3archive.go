@@ -0,0 +1,97 @@
+// Package s3archive stores archive segments in Amazon S3 or any
+// S3-compatible object store:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	store := s3archive.New(s3.NewFromConfig(cfg), "kimberlite-archive")
+//	exporter := archive.NewExporter(client, store, streams, archive.WithPrefix("tenant-1/"))
+//
+// Google Cloud Storage is supported through its XML API: create the
+// client with BaseEndpoint "https://storage.googleapis.com" and HMAC
+// credentials for a service account.
+//
+// The archive package writes to any ObjectStore and needs nothing
+// beyond the client; this module is the one that brings in the S3
+// client, for programs that archive to S3 or GCS.
+package s3archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/kimberlitedb/kimberlite-go/archive"
+)
+
+// API is the subset of *s3.Client used by Store.
+type API interface {
+	PutObject(ctx context.Context, in *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	s3.ListObjectsV2APIClient
+}
+
+// Store implements archive.ObjectStore on an S3 bucket.
+type Store struct {
+	api    API
+	bucket string
+}
+
+var _ archive.ObjectStore = (*Store)(nil)
+
+// New returns a Store writing to bucket.
+func New(api API, bucket string) *Store {
+	return &Store{api: api, bucket: bucket}
+}
+
+// Put implements archive.ObjectStore.
+func (s *Store) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.api.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String("application/octet-stream"),
+	})
+	return err
+}
+
+// Get implements archive.ObjectStore.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.api.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, fmt.Errorf("%w: %s", archive.ErrObjectNotFound, key)
+		}
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+// List implements archive.ObjectStore. S3 lists keys in lexical order.
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	p := s3.NewListObjectsV2Paginator(s.api, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,80 @@
+package s3archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/archive"
+)
+
+// fakeS3 is an in-memory bucket returning one key per list page.
+type fakeS3 map[string][]byte
+
+func (f fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	f[aws.ToString(in.Key)] = data
+	return &s3.PutObjectOutput{}, err
+}
+
+func (f fakeS3) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := f[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f fakeS3) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	var keys []string
+	for k := range f {
+		if strings.HasPrefix(k, aws.ToString(in.Prefix)) && k > aws.ToString(in.StartAfter) && k > aws.ToString(in.ContinuationToken) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := &s3.ListObjectsV2Output{}
+	if len(keys) > 0 {
+		out.Contents = []types.Object{{Key: aws.String(keys[0])}}
+		if len(keys) > 1 {
+			out.IsTruncated = aws.Bool(true)
+			out.NextContinuationToken = aws.String(keys[0])
+		}
+	}
+	return out, nil
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := New(fakeS3{}, "bucket")
+	var prev [archive.HashSize]byte
+	for first := kimberlite.Offset(0); first < 6; first += 2 {
+		events := []kimberlite.Event{{StreamID: 1, Offset: first}, {StreamID: 1, Offset: first + 1}}
+		data, chain, err := archive.EncodeSegment(1, events, prev, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Put(ctx, archive.SegmentKey("p/", 1, first, first+1), data); err != nil {
+			t.Fatal(err)
+		}
+		prev = chain
+	}
+
+	sum, err := archive.VerifyStream(ctx, store, "p/", 1)
+	if err != nil || sum.Segments != 3 || sum.LastOffset != 5 {
+		t.Fatalf("VerifyStream = %+v, %v", sum, err)
+	}
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, archive.ErrObjectNotFound) {
+		t.Fatalf("Get missing: err = %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrObjectNotFound is returned by ObjectStore.Get for missing keys.
+var ErrObjectNotFound = errors.New("kimberlite: archive object not found")
+
+// ObjectStore is the object storage segments are written to. Keys use
+// "/" as separator. The s3archive module implements it for Amazon S3
+// and S3-compatible stores, including Google Cloud Storage through its
+// XML API.
+type ObjectStore interface {
+	// Put stores data under key, replacing any existing object.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the object stored under key, or an error wrapping
+	// ErrObjectNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns the keys starting with prefix, in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// DirStore is an ObjectStore keeping objects as files under a
+// directory, for tests and mounted volumes.
+type DirStore struct {
+	root string
+}
+
+var _ ObjectStore = (*DirStore)(nil)
+
+// NewDirStore returns a store rooted at dir.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{root: dir}
+}
+
+func (d *DirStore) path(key string) (string, error) {
+	clean := filepath.FromSlash(key)
+	if !filepath.IsLocal(clean) {
+		return "", fmt.Errorf("kimberlite: invalid archive key %q", key)
+	}
+	return filepath.Join(d.root, clean), nil
+}
+
+// Put implements ObjectStore. Objects are written to a temporary file
+// and renamed into place, so readers never see partial segments.
+func (d *DirStore) Put(_ context.Context, key string, data []byte) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// Get implements ObjectStore.
+func (d *DirStore) Get(_ context.Context, key string) ([]byte, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return data, err
+}
+
+// List implements ObjectStore.
+func (d *DirStore) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(d.root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == d.root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
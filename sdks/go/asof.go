@@ -0,0 +1,74 @@
+package kimberlite
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PointInTime is a point in the database's history that a query
+// reads the state of. The log is append-only, so every earlier state
+// of a table remains queryable.
+type PointInTime struct {
+	offset Offset
+	time   time.Time
+	isTime bool
+}
+
+// AsOf returns the point in time t: the state as of the last commit at
+// or before t.
+//
+// The server resolves times to log positions with an index of commit
+// times it keeps in memory and rebuilds from new commits after a
+// restart, so times before its last restart may not resolve. Use
+// AsOfOffset with a recorded ExecResult.LogOffset where that matters.
+func AsOf(t time.Time) PointInTime {
+	return PointInTime{time: t, isTime: true}
+}
+
+// AsOfOffset returns the point in time after the commit at log
+// position o, such as the LogOffset of an ExecResult.
+func AsOfOffset(o Offset) PointInTime {
+	return PointInTime{offset: o}
+}
+
+func (p PointInTime) String() string {
+	if p.isTime {
+		return p.time.UTC().Format(time.RFC3339Nano)
+	}
+	return "offset " + strconv.FormatUint(uint64(p.offset), 10)
+}
+
+// clause returns the SQL the server reads p from, the SQL:2011
+// "AS OF '<timestamp>'" or Kimberlite's "AT OFFSET <n>".
+func (p PointInTime) clause() string {
+	if p.isTime {
+		return "AS OF '" + p.time.UTC().Format(time.RFC3339Nano) + "'"
+	}
+	return "AT OFFSET " + strconv.FormatUint(uint64(p.offset), 10)
+}
+
+// QueryAsOf executes a SELECT against the state of the database at p.
+// Equivalent to QueryAsOfContext(context.Background(), p, sql, args...).
+//
+//	jan3 := time.Date(2026, 1, 3, 23, 59, 59, 0, time.UTC)
+//	r, err := client.QueryAsOf(kimberlite.AsOf(jan3),
+//	    "SELECT * FROM patients WHERE id = $1", kimberlite.NewInt(42))
+func (c *Client) QueryAsOf(p PointInTime, sql string, args ...Value) (*QueryResult, error) {
+	return c.QueryAsOfContext(context.Background(), p, sql, args...)
+}
+
+// QueryAsOfContext is the context-aware variant of QueryAsOf. sql must
+// not carry a time-travel clause of its own.
+func (c *Client) QueryAsOfContext(ctx context.Context, p PointInTime, sql string, args ...Value) (*QueryResult, error) {
+	return c.QueryContext(ctx, withTimeTravel(sql, p), args...)
+}
+
+// withTimeTravel appends p's clause to sql, after any trailing
+// semicolon is dropped. The clause goes on a line of its own so that a
+// trailing line comment does not swallow it.
+func withTimeTravel(sql string, p PointInTime) string {
+	sql = strings.TrimRight(strings.TrimRight(sql, " \t\r\n"), ";")
+	return sql + "\n " + p.clause()
+}
@@ -0,0 +1,32 @@
+package kimberlite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryAsOf(t *testing.T) {
+	b := &recordingBackend{}
+	c, err := Connect("test", WithTenant(1), WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	jan3 := time.Date(2026, 1, 3, 18, 30, 0, 500, time.FixedZone("EST", -5*3600))
+	if _, err := c.QueryAsOf(AsOf(jan3), "SELECT * FROM patients WHERE id = $1;\n", NewInt(42)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.QueryAsOf(AsOfOffset(17), "SELECT * FROM patients -- current chart"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"SELECT * FROM patients WHERE id = $1\n AS OF '2026-01-03T23:30:00.0000005Z'",
+		"SELECT * FROM patients -- current chart\n AT OFFSET 17",
+	}
+	for i, sql := range want {
+		if b.sql[i] != sql {
+			t.Errorf("sent %q, want %q", b.sql[i], sql)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"unsafe"
+)
+
+// Supply-chain controls may require a client to refuse servers other
+// than approved builds. WithAttestation checks the server on Connect,
+// and on every reconnect, against pinned expectations, and fails with
+// ErrAttestationFailed on any mismatch:
+//
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(1),
+//	    kimberlite.WithAttestation(kimberlite.AttestationPolicy{
+//	        BuildVersions: []string{"0.6.0"},
+//	        BuildHashes:   []string{approvedSHA256},
+//	        SigningKeys:   []ed25519.PublicKey{releaseKey},
+//	        Source:        fetchFromReleaseLog,
+//	    }))
+//
+// The server reports its build version and protocol version, which
+// are checked directly. It does not sign statements about its binary,
+// so build hashes and signing keys are checked against an Attestation
+// from the policy's Source, such as a release transparency log, a
+// sidecar measuring the server, or a confidential-computing quote
+// verifier. The attestation must name the version the server reports
+// and, if SigningKeys are pinned, be signed by one of them; without
+// them, the Source itself is trusted. A policy pinning build hashes
+// or signing keys without a Source fails every connection.
+//
+// The check guards against connecting to the wrong build by mistake,
+// such as an unapproved version left running after a rollback. It
+// gives no protection against a malicious server: the version is
+// whatever the server claims, and nothing binds the attestation to the
+// connection, so a server reporting an approved version passes with
+// that version's attestation whatever it runs. Binding requires the
+// server to sign a statement over a client nonce, which it does not
+// do; a Source that measures the server itself, such as a quote
+// verifier for the host, is the only part of the check that does not
+// take the server's word.
+
+// ErrAttestationFailed is returned when the server does not match the
+// client's AttestationPolicy.
+var ErrAttestationFailed = errors.New("kimberlite: server attestation failed")
+
+// AttestationError reports a server not matching the attestation
+// policy.
+type AttestationError struct {
+	// Field is the mismatching property, e.g. "build hash".
+	Field string
+	// Got is the server's value, or empty if it was not attested.
+	Got string
+}
+
+func (e *AttestationError) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("kimberlite: server attestation failed: %s not attested", e.Field)
+	}
+	return fmt.Sprintf("kimberlite: server attestation failed: %s %s not allowed", e.Field, e.Got)
+}
+
+// Unwrap makes errors.Is(err, ErrAttestationFailed) hold.
+func (e *AttestationError) Unwrap() error { return ErrAttestationFailed }
+
+// ServerInfo describes a server.
+type ServerInfo struct {
+	BuildVersion    string
+	ProtocolVersion int
+	Capabilities    []string
+	Uptime          time.Duration
+	// ClusterMode is ClusterModeStandalone or ClusterModeClustered.
+	ClusterMode string
+	TenantCount int
+}
+
+// ServerInfo returns the description of the server the client is
+// connected to.
+func (c *Client) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	var raw []byte
+	err := c.call(ctx, &Operation{Name: OpServerInfo}, func(context.Context) (err error) {
+		raw, err = ffiServerInfo(c.kmbHandle)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeServerInfo(raw)
+}
+
+func decodeServerInfo(raw []byte) (*ServerInfo, error) {
+	var j struct {
+		BuildVersion    string   `json:"build_version"`
+		ProtocolVersion int      `json:"protocol_version"`
+		Capabilities    []string `json:"capabilities"`
+		UptimeSecs      uint64   `json:"uptime_secs"`
+		ClusterMode     string   `json:"cluster_mode"`
+		TenantCount     int      `json:"tenant_count"`
+	}
+	if err := json.Unmarshal(raw, &j); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode server info: %w", err)
+	}
+	return &ServerInfo{
+		BuildVersion:    j.BuildVersion,
+		ProtocolVersion: j.ProtocolVersion,
+		Capabilities:    j.Capabilities,
+		Uptime:          time.Duration(j.UptimeSecs) * time.Second,
+		ClusterMode:     j.ClusterMode,
+		TenantCount:     j.TenantCount,
+	}, nil
+}
+
+// Attestation is a statement that a server build has the given hash,
+// signed with a release key.
+type Attestation struct {
+	BuildVersion string
+	// BuildHash is the hex SHA-256 of the server binary or image.
+	BuildHash  string
+	SigningKey ed25519.PublicKey
+	Signature  []byte
+}
+
+var attestationContext = []byte("kimberlite server attestation v1\x00")
+
+func (a *Attestation) signedBytes() []byte {
+	b := append([]byte(nil), attestationContext...)
+	b = append(b, a.BuildVersion...)
+	b = append(b, 0)
+	return append(b, a.BuildHash...)
+}
+
+// SignAttestation returns the attestation of a build signed with key,
+// for release pipelines publishing attestations.
+func SignAttestation(buildVersion, buildHash string, key ed25519.PrivateKey) *Attestation {
+	a := &Attestation{BuildVersion: buildVersion, BuildHash: buildHash, SigningKey: key.Public().(ed25519.PublicKey)}
+	a.Signature = ed25519.Sign(key, a.signedBytes())
+	return a
+}
+
+// Verify checks the attestation's signature against its SigningKey.
+func (a *Attestation) Verify() error {
+	if len(a.SigningKey) != ed25519.PublicKeySize || !ed25519.Verify(a.SigningKey, a.signedBytes(), a.Signature) {
+		return &AttestationError{Field: "signature"}
+	}
+	return nil
+}
+
+// An AttestationSource returns the attestation of the server described
+// by info.
+type AttestationSource func(ctx context.Context, info *ServerInfo) (*Attestation, error)
+
+// AttestationPolicy holds the expectations a server must meet. Empty
+// fields are not checked. The server's build version is taken as
+// reported, so the policy rejects misconfigured servers, not ones
+// lying about their build.
+type AttestationPolicy struct {
+	// BuildVersions are the allowed server versions.
+	BuildVersions []string
+	// MinProtocolVersion is the lowest allowed wire protocol version.
+	MinProtocolVersion int
+	// BuildHashes are the allowed hex SHA-256 build hashes.
+	BuildHashes []string
+	// SigningKeys are the keys trusted to sign attestations.
+	SigningKeys []ed25519.PublicKey
+	// Source obtains attestations, for checking BuildHashes and
+	// SigningKeys.
+	Source AttestationSource
+}
+
+// WithAttestation makes the client check the server against p before
+// using a connection. The check relies on what the server reports; see
+// AttestationPolicy.
+func WithAttestation(p AttestationPolicy) Option {
+	return func(c *Client) { c.attestation = &p }
+}
+
+// attest checks the server of the connection handle against the
+// client's policy, if it has one.
+func (c *Client) attest(handle unsafe.Pointer) error {
+	if c.attestation == nil {
+		return nil
+	}
+	raw, err := ffiServerInfo(handle)
+	if err != nil {
+		return fmt.Errorf("%w: server info: %v", ErrAttestationFailed, err)
+	}
+	info, err := decodeServerInfo(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAttestationFailed, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.attestation.check(ctx, info)
+}
+
+func (p *AttestationPolicy) check(ctx context.Context, info *ServerInfo) error {
+	if len(p.BuildVersions) > 0 && !slices.Contains(p.BuildVersions, info.BuildVersion) {
+		return &AttestationError{Field: "build version", Got: info.BuildVersion}
+	}
+	if info.ProtocolVersion < p.MinProtocolVersion {
+		return &AttestationError{Field: "protocol version", Got: fmt.Sprint(info.ProtocolVersion)}
+	}
+	if len(p.BuildHashes) == 0 && len(p.SigningKeys) == 0 {
+		return nil
+	}
+	if p.Source == nil {
+		return &AttestationError{Field: "build hash"}
+	}
+	a, err := p.Source(ctx, info)
+	if err != nil {
+		return fmt.Errorf("%w: attestation source: %v", ErrAttestationFailed, err)
+	}
+	if a == nil {
+		return &AttestationError{Field: "build hash"}
+	}
+	if err := a.Verify(); err != nil {
+		return err
+	}
+	if a.BuildVersion != info.BuildVersion {
+		return &AttestationError{Field: "attested build version", Got: a.BuildVersion}
+	}
+	if len(p.SigningKeys) > 0 && !slices.ContainsFunc(p.SigningKeys, func(k ed25519.PublicKey) bool { return bytes.Equal(k, a.SigningKey) }) {
+		return &AttestationError{Field: "signing key", Got: hex.EncodeToString(a.SigningKey)}
+	}
+	if len(p.BuildHashes) > 0 && !slices.Contains(p.BuildHashes, a.BuildHash) {
+		return &AttestationError{Field: "build hash", Got: a.BuildHash}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package kimberlite
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeServerInfo(t *testing.T) {
+	info, err := decodeServerInfo([]byte(`{"build_version":"0.6.0","protocol_version":3,"capabilities":["subscribe.v2"],"uptime_secs":90,"cluster_mode":"Standalone","tenant_count":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BuildVersion != "0.6.0" || info.ProtocolVersion != 3 || info.Uptime != 90*time.Second || info.ClusterMode != "Standalone" || info.TenantCount != 2 || len(info.Capabilities) != 1 {
+		t.Fatalf("info %+v", info)
+	}
+}
+
+func TestAttestationPolicy(t *testing.T) {
+	ctx := context.Background()
+	release, releaseKey, _ := ed25519.GenerateKey(nil)
+	_, rogueKey, _ := ed25519.GenerateKey(nil)
+	info := &ServerInfo{BuildVersion: "0.6.0", ProtocolVersion: 3}
+	from := func(a *Attestation) AttestationSource {
+		return func(context.Context, *ServerInfo) (*Attestation, error) { return a, nil }
+	}
+	pinned := AttestationPolicy{
+		BuildVersions: []string{"0.6.0"},
+		BuildHashes:   []string{"abc123"},
+		SigningKeys:   []ed25519.PublicKey{release},
+	}
+	withSource := func(p AttestationPolicy, s AttestationSource) *AttestationPolicy {
+		p.Source = s
+		return &p
+	}
+
+	for name, tc := range map[string]struct {
+		policy *AttestationPolicy
+		field  string
+	}{
+		"versions only":    {&AttestationPolicy{BuildVersions: []string{"0.6.0"}, MinProtocolVersion: 3}, ""},
+		"attested":         {withSource(pinned, from(SignAttestation("0.6.0", "abc123", releaseKey))), ""},
+		"wrong version":    {&AttestationPolicy{BuildVersions: []string{"0.5.0"}}, "build version"},
+		"old protocol":     {&AttestationPolicy{MinProtocolVersion: 4}, "protocol version"},
+		"no source":        {&pinned, "build hash"},
+		"wrong hash":       {withSource(pinned, from(SignAttestation("0.6.0", "def456", releaseKey))), "build hash"},
+		"untrusted key":    {withSource(pinned, from(SignAttestation("0.6.0", "abc123", rogueKey))), "signing key"},
+		"other build":      {withSource(pinned, from(SignAttestation("0.5.9", "abc123", releaseKey))), "attested build version"},
+		"forged signature": {withSource(pinned, from(&Attestation{BuildVersion: "0.6.0", BuildHash: "abc123", SigningKey: release, Signature: make([]byte, 64)})), "signature"},
+	} {
+		err := tc.policy.check(ctx, info)
+		var ae *AttestationError
+		switch {
+		case tc.field == "" && err != nil:
+			t.Errorf("%s: %v", name, err)
+		case tc.field != "" && (!errors.As(err, &ae) || ae.Field != tc.field || !errors.Is(err, ErrAttestationFailed)):
+			t.Errorf("%s: expected %s mismatch, got %v", name, tc.field, err)
+		}
+	}
+
+	failing := withSource(pinned, func(context.Context, *ServerInfo) (*Attestation, error) { return nil, errors.New("log unavailable") })
+	if err := failing.check(ctx, info); !errors.Is(err, ErrAttestationFailed) {
+		t.Fatalf("source failure: %v", err)
+	}
+}
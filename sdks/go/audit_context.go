@@ -21,27 +21,7 @@ package kimberlite
 //	    ...
 //	}
 
-/*
-#include <stdint.h>
-#include <stdlib.h>
-
-// Thread-local audit hooks exposed by libkimberlite_ffi.
-// See crates/kimberlite-ffi/src/lib.rs.
-extern int kmb_audit_set(
-    const char* actor,
-    const char* reason,
-    const char* correlation_id,
-    const char* idempotency_key
-);
-extern int kmb_audit_clear(void);
-*/
-import "C"
-
-import (
-	"context"
-	"sync"
-	"unsafe"
-)
+import "context"
 
 // AuditContext holds caller attribution for a single logical operation.
 // Both Actor and Reason are mandatory in regulated-industry apps
@@ -60,6 +40,9 @@ type AuditContext struct {
 	// IdempotencyKey lets servers deduplicate retries sharing the
 	// same key. Optional.
 	IdempotencyKey string
+	// Purpose is the purpose of use, sent to the server in brackets
+	// before Reason. Optional; see WithPurpose.
+	Purpose Purpose
 }
 
 type auditKey struct{}
@@ -80,56 +63,3 @@ func AuditFromContext(ctx context.Context) (AuditContext, bool) {
 	v, ok := ctx.Value(auditKey{}).(AuditContext)
 	return v, ok
 }
-
-// ffiAuditMu serialises access to the process-wide FFI audit
-// thread-local. Go routines may be migrated between OS threads; we
-// take the mutex, set, call, and clear atomically per SDK method so
-// attribution never leaks across calls.
-var ffiAuditMu sync.Mutex
-
-// withFFIAudit installs ctx on the FFI thread-local for the duration
-// of fn. No-op if ctx is the zero value / missing.
-//
-// Internal helper — every exported client method wraps its CGo call
-// in this so callers don't need to thread anything manually.
-func withFFIAudit(ctx context.Context, fn func() error) error {
-	audit, ok := AuditFromContext(ctx)
-	if !ok {
-		return fn()
-	}
-
-	ffiAuditMu.Lock()
-	defer ffiAuditMu.Unlock()
-
-	cActor := cStringOrNil(audit.Actor)
-	cReason := cStringOrNil(audit.Reason)
-	cCorr := cStringOrNil(audit.CorrelationID)
-	cIdem := cStringOrNil(audit.IdempotencyKey)
-	defer func() {
-		if cActor != nil {
-			C.free(unsafe.Pointer(cActor))
-		}
-		if cReason != nil {
-			C.free(unsafe.Pointer(cReason))
-		}
-		if cCorr != nil {
-			C.free(unsafe.Pointer(cCorr))
-		}
-		if cIdem != nil {
-			C.free(unsafe.Pointer(cIdem))
-		}
-	}()
-
-	C.kmb_audit_set(cActor, cReason, cCorr, cIdem)
-	defer C.kmb_audit_clear()
-	return fn()
-}
-
-// cStringOrNil returns a freshly-allocated C string, or nil for empty.
-// Caller must free the returned pointer.
-func cStringOrNil(s string) *C.char {
-	if s == "" {
-		return nil
-	}
-	return C.CString(s)
-}
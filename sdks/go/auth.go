@@ -0,0 +1,171 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// A TokenProvider returns a current authentication token, typically a
+// short-lived JWT from an identity provider. Providers caching tokens
+// must not return the token RejectedToken reports.
+type TokenProvider func(ctx context.Context) (string, error)
+
+type rejectedTokenKey struct{}
+
+// RejectedToken returns the token the server rejected, when the client
+// calls its TokenProvider after an authentication failure.
+func RejectedToken(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(rejectedTokenKey{}).(string)
+	return token, ok
+}
+
+// tokenRefreshMargin is how long before a JWT's expiry the client
+// replaces it.
+const tokenRefreshMargin = time.Minute
+
+// WithTokenProvider authenticates with tokens from p instead of a
+// static WithToken. The client fetches a token on Connect and
+// reconnects with a new one shortly before the current one expires,
+// going by the exp claim of JWTs, and whenever the server answers an
+// operation with an authentication failure, which it then retries
+// once. Operations wait while the client reconnects.
+func WithTokenProvider(p TokenProvider) Option {
+	return func(c *Client) { c.tokens = &tokenState{provider: p} }
+}
+
+// tokenState tracks the token of a client with a TokenProvider. Its
+// fields other than provider are guarded by Client.mu.
+type tokenState struct {
+	provider TokenProvider
+	// dial opens a connection authenticated with token; nil means
+	// ffiConnect.
+	dial func(token string) (unsafe.Pointer, error)
+	// expires is the expiry of the current token, or zero if unknown.
+	expires time.Time
+	// gen counts the tokens connected with, so that concurrent
+	// operations failing on one token replace it only once.
+	gen uint64
+}
+
+// authenticated calls fn, which takes c.mu itself, refreshing the token
+// first if it is about to expire, and again followed by one retry of fn
+// if fn fails with ErrAuthFailed. A request refused for authentication
+// was not executed, so the retry cannot apply it twice. On an expired
+// break-glass session it fails without refreshing, which would step up
+// again.
+func authenticated[T any](ctx context.Context, c *Client, fn func() (T, error)) (T, error) {
+	if c.breakGlass != nil && c.breakGlass.expired() {
+		var zero T
+		return zero, ErrBreakGlassExpired
+	}
+	if c.tokens == nil {
+		return fn()
+	}
+	c.mu.RLock()
+	gen, token, due := c.tokens.gen, c.token, c.tokenDue()
+	c.mu.RUnlock()
+	if due {
+		if err := c.refreshToken(ctx, gen); err != nil {
+			var zero T
+			return zero, err
+		}
+		c.mu.RLock()
+		gen, token = c.tokens.gen, c.token
+		c.mu.RUnlock()
+	}
+	v, err := fn()
+	if !errors.Is(err, ErrAuthFailed) {
+		return v, err
+	}
+	if rerr := c.refreshToken(context.WithValue(ctx, rejectedTokenKey{}, token), gen); rerr != nil {
+		return v, errors.Join(err, rerr)
+	}
+	return fn()
+}
+
+// tokenDue reports whether the current token expires within the
+// refresh margin. c.mu must be held.
+func (c *Client) tokenDue() bool {
+	exp := c.tokens.expires
+	return !exp.IsZero() && !time.Now().Add(tokenRefreshMargin).Before(exp)
+}
+
+// refreshToken reconnects with a new token, unless the token of
+// generation gen has been replaced already.
+func (c *Client) refreshToken(ctx context.Context, gen uint64) error {
+	token, err := c.tokens.provider(ctx)
+	if err != nil {
+		return fmt.Errorf("kimberlite: refresh token: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrNotConnected
+	}
+	if c.tokens.gen != gen {
+		return nil
+	}
+	dial := c.tokens.dial
+	if dial == nil {
+		dial = c.dialFFI
+	}
+	handle, err := dial(token)
+	if err != nil {
+		c.logConnection(ConnEventReconnect, err)
+		return fmt.Errorf("%w: %s", ErrConnectionFailed, err)
+	}
+	if err := c.attest(handle); err != nil {
+		c.logConnection(ConnEventReconnect, err)
+		ffiDisconnect(handle)
+		return err
+	}
+	if c.kmbHandle != nil {
+		ffiDisconnect(c.kmbHandle)
+	}
+	c.kmbHandle = handle
+	c.caps.reset()
+	c.setToken(token)
+	c.connectionEvent(ConnEventReconnect)
+	return nil
+}
+
+// setToken records token as the current one. c.mu must be held for
+// writing.
+func (c *Client) setToken(token string) {
+	c.token = token
+	if c.tokens != nil {
+		c.tokens.expires = tokenExpiry(token)
+		c.tokens.gen++
+	}
+}
+
+// tokenExpiry returns the exp claim of a JWT, without verifying it, or
+// zero for other tokens.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil || exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(exp), 0)
+}
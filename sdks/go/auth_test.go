@@ -0,0 +1,105 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func testJWT(exp time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"svc","exp":%d}`, exp.Unix())))
+	return "eyJhbGciOiJub25lIn0." + claims + ".sig"
+}
+
+func TestTokenExpiry(t *testing.T) {
+	exp := time.Unix(1900000000, 0)
+	if got := tokenExpiry(testJWT(exp)); !got.Equal(exp) {
+		t.Fatalf("tokenExpiry = %v, want %v", got, exp)
+	}
+	for _, token := range []string{"kmb_live_abc", "a.b.c", "a." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"x"}`)) + ".c"} {
+		if got := tokenExpiry(token); !got.IsZero() {
+			t.Errorf("tokenExpiry(%q) = %v, want zero", token, got)
+		}
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	ctx := context.Background()
+	var issued, dials int
+	next := func() string { return testJWT(time.Now().Add(time.Hour)) }
+	c := &Client{tokens: &tokenState{
+		provider: func(context.Context) (string, error) {
+			issued++
+			return next(), nil
+		},
+		dial: func(token string) (unsafe.Pointer, error) {
+			dials++
+			return nil, nil
+		},
+	}}
+	c.setToken(testJWT(time.Now().Add(30 * time.Second)))
+
+	// A token about to expire is replaced before the call.
+	calls := 0
+	ok := func() (int, error) { calls++; return calls, nil }
+	if _, err := authenticated(ctx, c, ok); err != nil || issued != 1 || dials != 1 || calls != 1 {
+		t.Fatalf("proactive refresh: %d issued, %d dials, %d calls, %v", issued, dials, calls, err)
+	}
+	if c.tokenDue() {
+		t.Fatal("fresh token due")
+	}
+
+	// An authentication failure refreshes and retries once.
+	calls = 0
+	failOnce := func() (int, error) {
+		if calls++; calls == 1 {
+			return 0, fmt.Errorf("%w: expired", ErrAuthFailed)
+		}
+		return calls, nil
+	}
+	if n, err := authenticated(ctx, c, failOnce); err != nil || n != 2 || issued != 2 || dials != 2 {
+		t.Fatalf("retry: %d, %v; %d issued, %d dials", n, err, issued, dials)
+	}
+
+	// A token replaced meanwhile by another call is not replaced again.
+	gen := c.tokens.gen
+	c.tokens.gen++
+	if err := c.refreshToken(ctx, gen); err != nil || dials != 2 {
+		t.Fatalf("stale refresh: %d dials, %v", dials, err)
+	}
+
+	fail := errors.New("idp down")
+	c.tokens.provider = func(context.Context) (string, error) { return "", fail }
+	always := func() (int, error) { return 0, ErrAuthFailed }
+	if _, err := authenticated(ctx, c, always); !errors.Is(err, ErrAuthFailed) || !errors.Is(err, fail) {
+		t.Fatalf("failed refresh: %v", err)
+	}
+}
+
+func TestRejectedToken(t *testing.T) {
+	var rejected []string
+	c := &Client{tokens: &tokenState{
+		provider: func(ctx context.Context) (string, error) {
+			if token, ok := RejectedToken(ctx); ok {
+				rejected = append(rejected, token)
+			}
+			return "t2", nil
+		},
+		dial: func(string) (unsafe.Pointer, error) { return nil, nil },
+	}}
+	c.setToken("t1")
+	calls := 0
+	authenticated(context.Background(), c, func() (int, error) {
+		if calls++; calls == 1 {
+			return 0, ErrAuthFailed
+		}
+		return 0, nil
+	})
+	if len(rejected) != 1 || rejected[0] != "t1" || c.token != "t2" {
+		t.Fatalf("rejected %v, token %q", rejected, c.token)
+	}
+}
@@ -0,0 +1,104 @@
+// Package awsiam authenticates Kimberlite clients with AWS IAM
+// identities, the way RDS IAM authentication does, so EC2, ECS, EKS and
+// Lambda workloads connect with their role instead of a long-lived
+// secret:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	auth := awsiam.New(cfg.Credentials, cfg.Region, "kimberlite.prod.example.com")
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(1),
+//	    kimberlite.WithTokenProvider(auth.Token))
+//
+// A token is an STS GetCallerIdentity request presigned with SigV4,
+// base64url-encoded after the prefix "kimberlite-aws-v1.". It carries
+// no secret: the server verifies it by sending the request to STS,
+// which answers with the caller's ARN, and maps the ARN to a role. The
+// signed x-kimberlite-server-id header binds the token to one server,
+// so it cannot be replayed against another service trusting STS. The
+// server must have AWS IAM authentication enabled.
+//
+// Tokens are signed locally, without a network call, and are valid
+// for 15 minutes, long enough for a connection handshake; each call
+// to Token signs a new one.
+//
+// Signing uses the SigV4 signer of aws-sdk-go-v2, which is why awsiam
+// has a go.mod of its own: programs authenticating with passwords or
+// mTLS never download the AWS SDK.
+package awsiam
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// TokenPrefix starts every token.
+const TokenPrefix = "kimberlite-aws-v1."
+
+// ServerIDHeader is the signed header naming the server a token is for.
+const ServerIDHeader = "x-kimberlite-server-id"
+
+// emptyPayloadHash is the SHA-256 of the empty body of a GET request.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Authenticator signs IAM authentication tokens.
+type Authenticator struct {
+	creds    aws.CredentialsProvider
+	region   string
+	serverID string
+	endpoint string
+	signer   *v4.Signer
+	now      func() time.Time
+}
+
+var _ kimberlite.TokenProvider = (*Authenticator)(nil).Token
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithSTSEndpoint overrides the STS endpoint signed for, e.g. a FIPS or
+// VPC endpoint. The default is the regional endpoint.
+func WithSTSEndpoint(url string) Option {
+	return func(a *Authenticator) { a.endpoint = url }
+}
+
+// New returns an Authenticator signing with creds for STS in region.
+// serverID names the server, as configured on it.
+func New(creds aws.CredentialsProvider, region, serverID string, opts ...Option) *Authenticator {
+	a := &Authenticator{
+		creds:    creds,
+		region:   region,
+		serverID: serverID,
+		endpoint: "https://sts." + region + ".amazonaws.com/",
+		signer:   v4.NewSigner(),
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Token returns a new token. It is a kimberlite.TokenProvider.
+func (a *Authenticator) Token(ctx context.Context) (string, error) {
+	creds, err := a.creds.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("kimberlite: aws credentials: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.endpoint+"?Action=GetCallerIdentity&Version=2011-06-15&X-Amz-Expires=900", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(ServerIDHeader, a.serverID)
+	signed, _, err := a.signer.PresignHTTP(ctx, creds, req, emptyPayloadHash, "sts", a.region, a.now().UTC())
+	if err != nil {
+		return "", fmt.Errorf("kimberlite: sign aws token: %w", err)
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(signed)), nil
+}
@@ -0,0 +1,49 @@
+package awsiam
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestToken(t *testing.T) {
+	creds := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"}, nil
+	})
+	a := New(creds, "eu-west-1", "kmb-prod")
+	a.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	token, err := a.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(token, TokenPrefix))
+	if !strings.HasPrefix(token, TokenPrefix) || err != nil {
+		t.Fatalf("token %q: %v", token, err)
+	}
+	u, err := url.Parse(string(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Host != "sts.eu-west-1.amazonaws.com" || q.Get("Action") != "GetCallerIdentity" || q.Get("X-Amz-Expires") != "900" {
+		t.Fatalf("request %s", u)
+	}
+	if q.Get("X-Amz-Credential") != "AKIDEXAMPLE/20260102/eu-west-1/sts/aws4_request" || q.Get("X-Amz-Security-Token") != "session" || q.Get("X-Amz-Signature") == "" {
+		t.Fatalf("signature params %v", q)
+	}
+	if !strings.Contains(q.Get("X-Amz-SignedHeaders"), ServerIDHeader) {
+		t.Fatalf("server id not signed: %q", q.Get("X-Amz-SignedHeaders"))
+	}
+
+	staging := New(creds, "eu-west-1", "kmb-staging")
+	staging.now = a.now
+	if other, _ := staging.Token(context.Background()); other == token {
+		t.Fatal("token not bound to the server")
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/kimberlitedb/kimberlite-go/awsiam
+
+go 1.21
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.30.5
+	github.com/kimberlitedb/kimberlite-go v0.5.0
+)
+
+require github.com/aws/smithy-go v1.20.4 // indirect
+
+replace github.com/kimberlitedb/kimberlite-go => ../
@@ -0,0 +1,72 @@
+package kimberlite
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/kimberlitedb/kimberlite-go/internal/embedded"
+)
+
+// Backend serves a client's requests in place of a Kimberlite server,
+// so tests run without one; the kimberlitetest package implements it
+// in memory. It receives the requests the client would send, after the
+// client's own checks, encryption and interceptors, and must be safe
+// for concurrent use.
+type Backend interface {
+	Query(sql string, args []Value) (*QueryResult, error)
+	Execute(sql string, args []Value) (*ExecResult, error)
+	CreateStream(name string, class DataClass, region Region) (*StreamInfo, error)
+	// Append appends events if the stream's next offset is expected,
+	// and returns the offset of the first. Plain appends expect the
+	// stream's length.
+	Append(streamID StreamID, expected Offset, events [][]byte) (Offset, error)
+	// StreamLength returns the stream's length, the offset of its next
+	// event.
+	StreamLength(streamID StreamID) (Offset, error)
+	ReadEvents(streamID StreamID, from Offset, maxBytes uint64) ([]Event, error)
+}
+
+// WithBackend makes Connect serve the client's requests with b rather
+// than connect to the address, which is ignored. Operations of the
+// server's administration API, such as masking policies, DescribeTable
+// and RotateAPIKey, fail with ErrNotConnected; Erase does too unless b
+// implements ErasureLog.
+func WithBackend(b Backend) Option {
+	return func(c *Client) {
+		c.backend = b
+	}
+}
+
+// embeddedBackend returns the Backend of the kimberlitetest server at
+// addr for tenant, or an error if none is registered there, as when the
+// kimberlitetest package is not linked.
+func embeddedBackend(addr string, tenant TenantID) (Backend, error) {
+	b, _ := embedded.Lookup(addr, uint64(tenant)).(Backend)
+	if b == nil {
+		return nil, fmt.Errorf("%w: no kimberlitetest server at %s", ErrConnectionFailed, addr)
+	}
+	return b, nil
+}
+
+// NewQueryResult returns the result of a query returning rows of
+// values for columns, for Backends.
+func NewQueryResult(columns []string, rows []Row) *QueryResult {
+	r := &QueryResult{Columns: columns, Rows: make([]map[string]Value, len(rows)), values: rows}
+	for i, row := range rows {
+		m := make(map[string]Value, len(columns))
+		for j, name := range columns {
+			m[name] = row.Value(j)
+		}
+		r.Rows[i] = m
+	}
+	return r
+}
+
+// dialFFI opens a connection authenticated with token, unless the
+// client has a backend and so no connection.
+func (c *Client) dialFFI(token string) (unsafe.Pointer, error) {
+	if c.backend != nil {
+		return nil, nil
+	}
+	return ffiConnect(c.addr, uint64(c.tenant), token)
+}
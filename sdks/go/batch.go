@@ -0,0 +1,195 @@
+package kimberlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// InsertBatcher accumulates rows for a table and inserts them with
+// multi-row INSERT statements, the values bound as parameters, each
+// time a batch fills and on Flush. An InsertBatcher is not safe for
+// concurrent use.
+//
+//	b, err := client.NewInsertBatcher("admissions", []string{"id", "ward", "at"})
+//	for _, a := range admissions {
+//	    if err := b.Add(ctx, a.ID, a.Ward, a.At); err != nil {
+//	        var rowErr *kimberlite.RowError
+//	        if !errors.As(err, &rowErr) {
+//	            return err
+//	        }
+//	        log.Printf("skipping admission %s: %v", a.ID, rowErr.Err)
+//	    }
+//	}
+//	err = b.Flush(ctx)
+//
+// Rows are numbered from 1 in the order they are added. A row with the
+// wrong number of values, or a value ValueOf cannot convert, is
+// rejected by Add with a *RowError and never sent. A batch the server
+// rejects fails with a *BatchError naming its rows; the server stops at
+// the first row it cannot insert, keeping those before it, and does not
+// say which row that was. A batch size of one row attributes every
+// failure to its row, at the cost of a round trip per row.
+type InsertBatcher struct {
+	c        *Client
+	table    string
+	columns  []string
+	maxRows  int
+	maxBytes int64
+	upsert   []string
+	clause   string
+
+	pending     []Value
+	first, last int64 // numbers of the first and last pending rows
+	size        int64
+	added       int64
+	inserted    int64
+}
+
+// BatchOption configures an InsertBatcher.
+type BatchOption func(*InsertBatcher)
+
+// WithBatchRows sets the most rows an InsertBatcher sends in one
+// statement. The default is 1000.
+func WithBatchRows(n int) BatchOption {
+	return func(b *InsertBatcher) {
+		if n > 0 {
+			b.maxRows = n
+		}
+	}
+}
+
+// WithBatchBytes sets the size of values, counted as QueryStats.Bytes
+// counts them, at which an InsertBatcher sends a batch before it has
+// its full number of rows. The default is 4 MiB; statements must stay
+// below the protocol's 16 MiB frame.
+func WithBatchBytes(n int64) BatchOption {
+	return func(b *InsertBatcher) {
+		if n > 0 {
+			b.maxBytes = n
+		}
+	}
+}
+
+// WithUpsert makes an InsertBatcher upsert its rows as Upsert does,
+// on conflictKeys.
+func WithUpsert(conflictKeys ...string) BatchOption {
+	return func(b *InsertBatcher) {
+		b.upsert = conflictKeys
+	}
+}
+
+// RowError is returned by InsertBatcher.Add for a row it rejects.
+type RowError struct {
+	// Row is the number of the row.
+	Row int64
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("kimberlite: insert row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// BatchError is returned when the server rejects a batch of rows.
+// Rows before the one the server could not insert were inserted.
+type BatchError struct {
+	// FirstRow and LastRow are the numbers of the batch's first and
+	// last rows. Rows Add rejected between them are not in the batch.
+	FirstRow, LastRow int64
+	Err               error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("kimberlite: insert rows %d-%d: %v", e.FirstRow, e.LastRow, e.Err)
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// NewInsertBatcher returns an InsertBatcher inserting into columns of
+// table, which must be plain identifiers.
+func (c *Client) NewInsertBatcher(table string, columns []string, opts ...BatchOption) (*InsertBatcher, error) {
+	b := &InsertBatcher{
+		c:        c,
+		table:    table,
+		columns:  append([]string(nil), columns...),
+		maxRows:  1000,
+		maxBytes: 4 << 20,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	var err error
+	if b.upsert != nil {
+		b.clause, err = onConflict(table, b.upsert, columns)
+	} else {
+		err = checkIdentifiers(table, columns)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Add queues a row, one value per column, converted with ValueOf, and
+// sends the pending rows if they fill a batch. It returns a *RowError
+// if it rejects the row, and a *BatchError if sending failed.
+func (b *InsertBatcher) Add(ctx context.Context, values ...any) error {
+	row := make([]Value, len(values))
+	for i, x := range values {
+		v, err := ValueOf(x)
+		if err != nil {
+			b.added++
+			return &RowError{Row: b.added, Err: fmt.Errorf("column %s: %w", b.columns[min(i, len(b.columns)-1)], err)}
+		}
+		row[i] = v
+	}
+	return b.add(ctx, row)
+}
+
+func (b *InsertBatcher) add(ctx context.Context, row []Value) error {
+	b.added++
+	if len(row) != len(b.columns) {
+		return &RowError{Row: b.added, Err: fmt.Errorf("%d values for %d columns", len(row), len(b.columns))}
+	}
+	if len(b.pending) == 0 {
+		b.first = b.added
+	}
+	b.last = b.added
+	b.pending = append(b.pending, row...)
+	for _, v := range row {
+		b.size += valueSize(v)
+	}
+	if b.Pending() >= b.maxRows || b.size >= b.maxBytes {
+		return b.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush sends the pending rows. The rows of a batch that fails are
+// dropped, not retried: some of them may have been inserted.
+func (b *InsertBatcher) Flush(ctx context.Context) error {
+	rows := b.Pending()
+	if rows == 0 {
+		return nil
+	}
+	args := b.pending
+	b.pending, b.size = nil, 0
+	r, err := b.c.ExecContext(ctx, insertSQL(b.table, b.columns, rows)+b.clause, args...)
+	if err != nil {
+		return &BatchError{FirstRow: b.first, LastRow: b.last, Err: err}
+	}
+	b.inserted += r.RowsAffected
+	return nil
+}
+
+// Pending returns the number of rows added and not yet sent.
+func (b *InsertBatcher) Pending() int {
+	return len(b.pending) / len(b.columns)
+}
+
+// Inserted returns the number of rows inserted, or with WithUpsert
+// inserted or updated, by batches that succeeded.
+func (b *InsertBatcher) Inserted() int64 {
+	return b.inserted
+}
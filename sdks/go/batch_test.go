@@ -0,0 +1,56 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestInsertBatcher(t *testing.T) {
+	eb := &execBackend{failAt: 2}
+	c, err := Connect("test", WithTenant(1), WithBackend(eb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	b, err := c.NewInsertBatcher("admissions", []string{"id", "ward"}, WithBatchRows(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rowErr *RowError
+	if err := b.Add(ctx, 1, "icu"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(ctx, 2, struct{}{}); !errors.As(err, &rowErr) || rowErr.Row != 2 {
+		t.Fatalf("unconvertible row: %v", err)
+	}
+	if err := b.Add(ctx, 3); !errors.As(err, &rowErr) || rowErr.Row != 3 {
+		t.Fatalf("short row: %v", err)
+	}
+	if err := b.Add(ctx, 4, "er"); err != nil || b.Pending() != 0 {
+		t.Fatalf("full batch not sent: %v", err)
+	}
+	if len(eb.args) != 1 || eb.args[0][2].AsInt() != 4 {
+		t.Fatalf("sent %v", eb.args)
+	}
+
+	// The second statement fails.
+	if err := b.Add(ctx, 5, "icu"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(ctx, 6); err == nil {
+		t.Fatal("short row accepted")
+	}
+	var batchErr *BatchError
+	if err := b.Add(ctx, 7, "er"); !errors.As(err, &batchErr) || batchErr.FirstRow != 5 || batchErr.LastRow != 7 || !errors.Is(err, ErrQueryFailed) {
+		t.Fatalf("failed batch: %v", err)
+	}
+	if b.Pending() != 0 || b.Inserted() != 2 {
+		t.Fatalf("pending %d, inserted %d", b.Pending(), b.Inserted())
+	}
+	if err := b.Flush(ctx); err != nil || len(eb.sql) != 2 {
+		t.Fatalf("empty flush: %v", err)
+	}
+}
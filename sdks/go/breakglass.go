@@ -0,0 +1,229 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Break-glass access lets a clinician or operator read data beyond
+// their normal privileges in an emergency. BreakGlass opens a session
+// on a separate connection authenticated with a fresh, elevated token
+// from the client's TokenProvider, which sees StepUpRequested and must
+// re-authenticate the user or service with elevated scope:
+//
+//	s, err := client.BreakGlass(ctx, "patient unresponsive in ER bay 4")
+//	if err != nil {
+//	    return err
+//	}
+//	defer s.Close()
+//	rows, err := s.QueryContext(ctx, "SELECT * FROM allergies WHERE patient_id = $1", id)
+//
+// Every operation of the session carries the justification in its
+// audit reason, ahead of any caller reason, and PurposeEmergency unless
+// the caller states another purpose, so the server's audit trail
+// records it for each access; the session's interceptors see that
+// audit context. Opening and closing a session is logged at Warn level.
+// Sessions expire after DefaultBreakGlassDuration, or the duration
+// given with BreakGlassDuration; later operations fail with
+// ErrBreakGlassExpired, and subscriptions opened during the session end
+// with it. The client's own connection keeps its normal privileges
+// throughout.
+
+// DefaultBreakGlassDuration is how long a break-glass session lasts
+// unless changed with BreakGlassDuration.
+const DefaultBreakGlassDuration = 15 * time.Minute
+
+var (
+	// ErrJustificationRequired is returned by BreakGlass for an empty
+	// justification.
+	ErrJustificationRequired = errors.New("kimberlite: break-glass justification required")
+	// ErrStepUpFailed is returned by BreakGlass when no elevated token
+	// can be obtained.
+	ErrStepUpFailed = errors.New("kimberlite: step-up authentication failed")
+	// ErrBreakGlassExpired is returned for operations of an expired
+	// break-glass session.
+	ErrBreakGlassExpired = errors.New("kimberlite: break-glass session expired")
+)
+
+type stepUpKey struct{}
+
+// StepUpRequested returns the justification of a break-glass session
+// when the client calls its TokenProvider for one. The provider must
+// then return a new, elevated token rather than a cached one.
+func StepUpRequested(ctx context.Context) (string, bool) {
+	justification, ok := ctx.Value(stepUpKey{}).(string)
+	return justification, ok
+}
+
+// BreakGlassOption configures a break-glass session.
+type BreakGlassOption func(*BreakGlassSession)
+
+// BreakGlassDuration sets how long a break-glass session lasts.
+func BreakGlassDuration(d time.Duration) BreakGlassOption {
+	return func(s *BreakGlassSession) { s.Expires = s.Started.Add(d) }
+}
+
+// BreakGlassSession is a client with temporarily elevated access. Its
+// operations are those of Client; Close ends the session.
+type BreakGlassSession struct {
+	*Client
+	Justification string
+	Started       time.Time
+	Expires       time.Time
+}
+
+// BreakGlass opens a break-glass session justified by justification.
+// It requires a client with a TokenProvider, and fails with
+// ErrStepUpFailed if the provider returns an error or the current
+// token.
+func (c *Client) BreakGlass(ctx context.Context, justification string, opts ...BreakGlassOption) (*BreakGlassSession, error) {
+	if justification == "" {
+		return nil, ErrJustificationRequired
+	}
+	if c.tokens == nil {
+		return nil, fmt.Errorf("%w: client has no TokenProvider", ErrStepUpFailed)
+	}
+	now := time.Now()
+	s := &BreakGlassSession{Justification: justification, Started: now, Expires: now.Add(DefaultBreakGlassDuration)}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	c.mu.RLock()
+	closed, current := c.closed, c.token
+	elevated := c.elevated(s)
+	c.mu.RUnlock()
+	if closed {
+		return nil, ErrNotConnected
+	}
+	token, err := elevated.tokens.provider(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrStepUpFailed, err)
+	}
+	if token == current {
+		return nil, fmt.Errorf("%w: token provider returned the current token", ErrStepUpFailed)
+	}
+	elevated.setToken(token)
+	dial := elevated.tokens.dial
+	if dial == nil {
+		dial = elevated.dialFFI
+	}
+	if elevated.kmbHandle, err = dial(token); err != nil {
+		c.logConnection(ConnEventConnect, err)
+		return nil, fmt.Errorf("%w: %s", ErrConnectionFailed, err)
+	}
+	if err := elevated.attest(elevated.kmbHandle); err != nil {
+		c.logConnection(ConnEventConnect, err)
+		elevated.disconnect()
+		return nil, err
+	}
+	s.Client = elevated
+	s.log("kimberlite: break-glass session opened")
+	return s, nil
+}
+
+// Close ends the session and closes its connection.
+func (s *BreakGlassSession) Close() error {
+	s.log("kimberlite: break-glass session closed")
+	return s.Client.Close()
+}
+
+func (s *BreakGlassSession) log(msg string) {
+	if s.logger == nil {
+		return
+	}
+	s.logger.LogAttrs(context.Background(), slog.LevelWarn, msg,
+		slog.String("addr", s.addr),
+		slog.Uint64("tenant", uint64(s.tenant)),
+		slog.String("justification", s.Justification),
+		slog.Time("expires", s.Expires))
+}
+
+// elevated returns an unconnected copy of c for session s, whose
+// tokens are requested with StepUpRequested and whose operations are
+// attributed to s. c.mu must be held.
+//
+// The copy has no read cache, so elevated reads cannot be served to the
+// normal connection later, and does not require a purpose: the
+// session's guard adds one, after the check of WithPurposeRequired.
+func (c *Client) elevated(s *BreakGlassSession) *Client {
+	e := &Client{
+		addr:          c.addr,
+		tenant:        c.tenant,
+		timeout:       c.timeout,
+		ffiAvail:      c.ffiAvail,
+		codecs:        c.codecs,
+		tracer:        c.tracer,
+		metrics:       c.metrics,
+		tsLoc:         c.tsLoc,
+		logger:        c.logger,
+		slowThreshold: c.slowThreshold,
+		logUnredacted: c.logUnredacted,
+		onSlowQuery:   c.onSlowQuery,
+		healthURL:     c.healthURL,
+		receipts:      c.receipts,
+		encryption:    c.encryption,
+		pii:           c.pii,
+		holds:         c.holds,
+		attestation:   c.attestation,
+		backend:       c.backend,
+
+		enforceClasses:  c.enforceClasses,
+		secureTransport: c.secureTransport,
+		region:          c.region,
+		readOnly:        c.readOnly,
+	}
+	provider := c.tokens.provider
+	e.tokens = &tokenState{
+		provider: func(ctx context.Context) (string, error) {
+			return provider(context.WithValue(ctx, stepUpKey{}, s.Justification))
+		},
+		dial: c.tokens.dial,
+	}
+	e.interceptors = c.interceptors
+	e.breakGlass = s
+	c.streams.mu.RLock()
+	for id, class := range c.streams.classes {
+		e.streams.set(id, class)
+	}
+	c.streams.mu.RUnlock()
+	c.regions.mu.RLock()
+	for id, region := range c.regions.regions {
+		e.regions.set(id, region)
+	}
+	c.regions.mu.RUnlock()
+	c.names.mu.RLock()
+	for id, name := range c.names.names {
+		e.names.set(id, name)
+	}
+	c.names.mu.RUnlock()
+	return e
+}
+
+// guard fails operations after the session expired and returns the
+// context of the others, attributing them to the session.
+func (s *BreakGlassSession) guard(ctx context.Context) (context.Context, error) {
+	if s.expired() {
+		return ctx, ErrBreakGlassExpired
+	}
+	audit, _ := AuditFromContext(ctx)
+	audit.Reason = breakGlassReason(s.Justification, audit.Reason)
+	if audit.Purpose == "" {
+		audit.Purpose = PurposeEmergency
+	}
+	return WithAudit(ctx, audit), nil
+}
+
+func (s *BreakGlassSession) expired() bool {
+	return !time.Now().Before(s.Expires)
+}
+
+func breakGlassReason(justification, reason string) string {
+	if reason == "" {
+		return "break-glass: " + justification
+	}
+	return "break-glass: " + justification + "; " + reason
+}
@@ -0,0 +1,119 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestBreakGlass(t *testing.T) {
+	ctx := context.Background()
+	var stepUp string
+	c := &Client{tenant: 1, tokens: &tokenState{
+		provider: func(ctx context.Context) (string, error) {
+			if j, ok := StepUpRequested(ctx); ok {
+				stepUp = j
+				return "elevated", nil
+			}
+			return "normal", nil
+		},
+		dial: func(string) (unsafe.Pointer, error) { return nil, nil },
+	}}
+	c.setToken("normal")
+	c.SetStreamClass(1, DataClassRestricted)
+
+	if _, err := c.BreakGlass(ctx, ""); !errors.Is(err, ErrJustificationRequired) {
+		t.Fatalf("expected ErrJustificationRequired, got %v", err)
+	}
+	if _, err := (&Client{}).BreakGlass(ctx, "code blue"); !errors.Is(err, ErrStepUpFailed) {
+		t.Fatalf("expected ErrStepUpFailed without provider, got %v", err)
+	}
+
+	s, err := c.BreakGlass(ctx, "code blue", BreakGlassDuration(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stepUp != "code blue" || s.token != "elevated" || c.token != "normal" {
+		t.Fatalf("step-up %q, session token %q, client token %q", stepUp, s.token, c.token)
+	}
+	if got := s.Expires.Sub(s.Started); got != time.Minute {
+		t.Fatalf("duration %v", got)
+	}
+	if class, _ := s.streams.get(1); class != DataClassRestricted {
+		t.Fatalf("class %v", class)
+	}
+
+	var audit AuditContext
+	record := func(ctx context.Context, op *Operation, req any, next Invoker) error {
+		audit, _ = AuditFromContext(ctx)
+		return nil
+	}
+	s.interceptors = []Interceptor{record}
+	callCtx := WithAudit(ctx, AuditContext{Actor: "dr-who", Reason: "allergies"})
+	if err := s.instrument(callCtx, &Operation{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if audit.Actor != "dr-who" || audit.Reason != "break-glass: code blue; allergies" || audit.Purpose != PurposeEmergency {
+		t.Fatalf("audit %+v", audit)
+	}
+	if err := s.instrument(WithPurpose(ctx, PurposeTreatment), &Operation{}, nil, nil); err != nil || audit.Purpose != PurposeTreatment || audit.Reason != "break-glass: code blue" {
+		t.Fatalf("audit %+v, %v", audit, err)
+	}
+
+	s.Expires = time.Now()
+	if err := s.instrument(ctx, &Operation{}, nil, nil); !errors.Is(err, ErrBreakGlassExpired) {
+		t.Fatalf("expected ErrBreakGlassExpired, got %v", err)
+	}
+
+	// A provider ignoring the step-up request elevates nothing.
+	c.tokens.provider = func(context.Context) (string, error) { return "normal", nil }
+	if _, err := c.BreakGlass(ctx, "code blue"); !errors.Is(err, ErrStepUpFailed) {
+		t.Fatalf("expected ErrStepUpFailed, got %v", err)
+	}
+}
+
+func TestBreakGlassExpiredSession(t *testing.T) {
+	ctx := context.Background()
+	subjects := NewMemorySubjectKeys()
+	if _, err := subjects.SubjectKey(ctx, "p-1", true); err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{
+		tenant:     1,
+		encryption: NewEncryption(nil, WithSubjectKeys(subjects)),
+		holds:      NewMemoryLegalHolds(),
+		tokens: &tokenState{
+			provider: func(ctx context.Context) (string, error) {
+				if _, ok := StepUpRequested(ctx); ok {
+					return "elevated", nil
+				}
+				return "normal", nil
+			},
+			dial: func(string) (unsafe.Pointer, error) { return nil, nil },
+		},
+	}
+	c.setToken("normal")
+	s, err := c.BreakGlass(ctx, "code blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Expires = time.Now()
+
+	if _, err := s.Subscribe(ctx, 1, 0); !errors.Is(err, ErrBreakGlassExpired) {
+		t.Fatalf("Subscribe: expected ErrBreakGlassExpired, got %v", err)
+	}
+	if err := s.Erase(ctx, "p-1"); !errors.Is(err, ErrBreakGlassExpired) {
+		t.Fatalf("Erase: expected ErrBreakGlassExpired, got %v", err)
+	}
+	if _, err := subjects.SubjectKey(ctx, "p-1", false); err != nil {
+		t.Fatalf("Erase destroyed the key of an expired session: %v", err)
+	}
+	if _, err := s.ListTables(ctx); !errors.Is(err, ErrBreakGlassExpired) {
+		t.Fatalf("ListTables: expected ErrBreakGlassExpired, got %v", err)
+	}
+	if err := s.PlaceLegalHold(ctx, SubjectHold("p-1"), "case-1"); !errors.Is(err, ErrBreakGlassExpired) {
+		t.Fatalf("PlaceLegalHold: expected ErrBreakGlassExpired, got %v", err)
+	}
+}
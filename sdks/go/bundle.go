@@ -0,0 +1,351 @@
+package kimberlite
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A chain-of-custody bundle is a self-contained export of a range of a
+// stream's events for litigation and e-discovery: a zip archive of the
+// events, their inclusion proofs, the signed tree head they prove
+// against, and a signed manifest of the other files' hashes, saying
+// who exported them, when and why:
+//
+//	ctx = kimberlite.WithAudit(ctx, kimberlite.AuditContext{Actor: "legal@example.com", Reason: "case 24-cv-1138"})
+//	manifest, err := client.ExportBundle(ctx, f, stream, custodianKey, kimberlite.WithCustodian("records-office"))
+//
+// VerifyBundle checks a bundle offline with the custodian's public key
+// alone, so a recipient such as opposing counsel needs neither the
+// database nor the exporting client: every event must be in the tree
+// of the signed head, and no file may be altered, added or removed.
+
+// Bundle file names.
+const (
+	BundleManifestFile  = "manifest.json"
+	BundleSignatureFile = "manifest.sig"
+	BundleEventsFile    = "events.ndjson"
+	BundleProofsFile    = "proofs.ndjson"
+	BundleTreeHeadFile  = "tree_head.json"
+)
+
+// bundleVersion is the version of the bundle format.
+const bundleVersion = 1
+
+// maxBundleFile limits the size of a file read from a bundle.
+const maxBundleFile = 1 << 30
+
+// ErrInvalidBundle is returned when a bundle fails verification.
+var ErrInvalidBundle = errors.New("kimberlite: invalid bundle")
+
+// BundleManifest describes a bundle. The events are the stream's events
+// at offsets From to To, To excluded, proven against the tree of its
+// first TreeSize events.
+type BundleManifest struct {
+	Version   int       `json:"version"`
+	TenantID  TenantID  `json:"tenant_id"`
+	StreamID  StreamID  `json:"stream_id"`
+	From      Offset    `json:"from"`
+	To        Offset    `json:"to"`
+	TreeSize  uint64    `json:"tree_size"`
+	CreatedAt time.Time `json:"created_at"`
+	Custodian string    `json:"custodian,omitempty"`
+	// Actor and Reason are those of the exporting context's
+	// AuditContext.
+	Actor  string `json:"actor,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// Files are the SHA-256 hashes of the bundle's other files.
+	Files map[string]Digest `json:"files"`
+}
+
+// Bundle is the content of a verified bundle.
+type Bundle struct {
+	Manifest BundleManifest
+	TreeHead SignedTreeHead
+	// Events are the bundled events, with a zero Timestamp.
+	Events []Event
+}
+
+// BundleOption configures ExportBundle.
+type BundleOption func(*bundleConfig)
+
+type bundleConfig struct {
+	from, to  Offset
+	custodian string
+}
+
+// WithBundleRange exports the events at offsets from to to, to
+// excluded. The default is all events.
+func WithBundleRange(from, to Offset) BundleOption {
+	return func(c *bundleConfig) { c.from, c.to = from, to }
+}
+
+// WithCustodian names the custodian signing the bundle in its
+// manifest.
+func WithCustodian(name string) BundleOption {
+	return func(c *bundleConfig) { c.custodian = name }
+}
+
+// bundleEvent is an exported event. It has no time: the Timestamp of
+// events read from the server is when they were read, not appended.
+// Bundles written before holding one as "timestamp" still verify, the
+// field being ignored.
+type bundleEvent struct {
+	Offset Offset `json:"offset"`
+	Data   []byte `json:"data"`
+}
+
+type bundleProof struct {
+	Offset Offset   `json:"offset"`
+	Path   []Digest `json:"path"`
+}
+
+type bundleTreeHead struct {
+	StreamID  StreamID  `json:"stream_id"`
+	TreeSize  uint64    `json:"tree_size"`
+	RootHash  Digest    `json:"root_hash"`
+	Timestamp time.Time `json:"timestamp"`
+	Signature []byte    `json:"signature"`
+}
+
+// ExportBundle writes a chain-of-custody bundle of stream to w, signing
+// its tree head and manifest with key, and returns its manifest. The
+// tree head covers all events of the stream at the time of the export.
+// Events are exported as stored, read with ReadStoredEvents, so the
+// head matches a witness's and encrypted payloads stay encrypted.
+func (c *Client) ExportBundle(ctx context.Context, w io.Writer, streamID StreamID, key ed25519.PrivateKey, opts ...BundleOption) (*BundleManifest, error) {
+	return exportBundle(ctx, storedEvents{c}, c.tenant, w, streamID, key, opts...)
+}
+
+func exportBundle(ctx context.Context, r eventReader, tenant TenantID, w io.Writer, streamID StreamID, key ed25519.PrivateKey, opts ...BundleOption) (*BundleManifest, error) {
+	var cfg bundleConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.to != 0 && cfg.to < cfg.from {
+		return nil, fmt.Errorf("kimberlite: invalid bundle range %d to %d", cfg.from, cfg.to)
+	}
+
+	var (
+		leaves   [][TreeHashSize]byte
+		selected []Event
+	)
+	for {
+		events, err := r.ReadEventsContext(ctx, streamID, Offset(len(leaves)), 1<<20)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, ev := range events {
+			if ev.Offset != Offset(len(leaves)) {
+				return nil, fmt.Errorf("kimberlite: stream %d: expected offset %d, read %d", streamID, len(leaves), ev.Offset)
+			}
+			leaves = append(leaves, leafHash(ev.Offset, ev.Data))
+			if ev.Offset >= cfg.from && (cfg.to == 0 || ev.Offset < cfg.to) {
+				selected = append(selected, ev)
+			}
+		}
+	}
+	size := uint64(len(leaves))
+	if cfg.to == 0 {
+		cfg.to = Offset(size)
+	}
+	if uint64(cfg.to) > size {
+		return nil, fmt.Errorf("kimberlite: bundle range %d to %d exceeds stream %d's %d events", cfg.from, cfg.to, streamID, size)
+	}
+
+	now := time.Now().UTC()
+	sth := SignTreeHead(TreeHead{StreamID: streamID, TreeSize: size, RootHash: treeRoot(leaves), Timestamp: now}, key)
+	var events, proofs, head bytes.Buffer
+	eventsEnc, proofsEnc := json.NewEncoder(&events), json.NewEncoder(&proofs)
+	for _, ev := range selected {
+		if err := eventsEnc.Encode(bundleEvent{Offset: ev.Offset, Data: ev.Data}); err != nil {
+			return nil, err
+		}
+		p := bundleProof{Offset: ev.Offset, Path: []Digest{}}
+		for _, h := range auditPath(uint64(ev.Offset), leaves) {
+			p.Path = append(p.Path, h)
+		}
+		if err := proofsEnc.Encode(p); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.NewEncoder(&head).Encode(bundleTreeHead{
+		StreamID:  sth.StreamID,
+		TreeSize:  sth.TreeSize,
+		RootHash:  sth.RootHash,
+		Timestamp: sth.Timestamp,
+		Signature: sth.Signature,
+	}); err != nil {
+		return nil, err
+	}
+
+	audit, _ := AuditFromContext(ctx)
+	m := &BundleManifest{
+		Version:   bundleVersion,
+		TenantID:  tenant,
+		StreamID:  streamID,
+		From:      cfg.from,
+		To:        cfg.to,
+		TreeSize:  size,
+		CreatedAt: now,
+		Custodian: cfg.custodian,
+		Actor:     audit.Actor,
+		Reason:    audit.Reason,
+		Files: map[string]Digest{
+			BundleEventsFile:   sha256.Sum256(events.Bytes()),
+			BundleProofsFile:   sha256.Sum256(proofs.Bytes()),
+			BundleTreeHeadFile: sha256.Sum256(head.Bytes()),
+		},
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{BundleManifestFile, manifest},
+		{BundleSignatureFile, ed25519.Sign(key, manifest)},
+		{BundleTreeHeadFile, head.Bytes()},
+		{BundleEventsFile, events.Bytes()},
+		{BundleProofsFile, proofs.Bytes()},
+	} {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VerifyBundle verifies the bundle in r, of size bytes, against the
+// custodian's key and returns its content. Verification failures wrap
+// ErrInvalidBundle.
+func VerifyBundle(r io.ReaderAt, size int64, key ed25519.PublicKey) (*Bundle, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	files := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		if _, dup := files[f.Name]; dup {
+			return nil, fmt.Errorf("%w: duplicate file %s", ErrInvalidBundle, f.Name)
+		}
+		data, err := readBundleFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, f.Name, err)
+		}
+		files[f.Name] = data
+	}
+
+	manifest, sig := files[BundleManifestFile], files[BundleSignatureFile]
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, manifest, sig) {
+		return nil, fmt.Errorf("%w: bad manifest signature", ErrInvalidBundle)
+	}
+	b := &Bundle{}
+	if err := json.Unmarshal(manifest, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: manifest: %v", ErrInvalidBundle, err)
+	}
+	m := &b.Manifest
+	if m.Version != bundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, m.Version)
+	}
+	for name, data := range files {
+		if name == BundleManifestFile || name == BundleSignatureFile {
+			continue
+		}
+		if want, ok := m.Files[name]; !ok || sha256.Sum256(data) != want {
+			return nil, fmt.Errorf("%w: %s does not match the manifest", ErrInvalidBundle, name)
+		}
+	}
+	for _, name := range []string{BundleTreeHeadFile, BundleEventsFile, BundleProofsFile} {
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidBundle, name)
+		}
+	}
+
+	var head bundleTreeHead
+	if err := json.Unmarshal(files[BundleTreeHeadFile], &head); err != nil {
+		return nil, fmt.Errorf("%w: tree head: %v", ErrInvalidBundle, err)
+	}
+	b.TreeHead = SignedTreeHead{
+		TreeHead:  TreeHead{StreamID: head.StreamID, TreeSize: head.TreeSize, RootHash: head.RootHash, Timestamp: head.Timestamp},
+		Signature: head.Signature,
+	}
+	if err := b.TreeHead.Verify(key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if head.StreamID != m.StreamID || head.TreeSize != m.TreeSize || m.From > m.To || uint64(m.To) > m.TreeSize {
+		return nil, fmt.Errorf("%w: tree head and manifest disagree", ErrInvalidBundle)
+	}
+
+	events := bufio.NewScanner(bytes.NewReader(files[BundleEventsFile]))
+	events.Buffer(nil, maxBundleFile)
+	proofs := bufio.NewScanner(bytes.NewReader(files[BundleProofsFile]))
+	proofs.Buffer(nil, maxBundleFile)
+	for offset := m.From; offset < m.To; offset++ {
+		if !events.Scan() || !proofs.Scan() {
+			return nil, fmt.Errorf("%w: event or proof at offset %d missing", ErrInvalidBundle, offset)
+		}
+		var ev bundleEvent
+		var p bundleProof
+		if err := json.Unmarshal(events.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("%w: event at offset %d: %v", ErrInvalidBundle, offset, err)
+		}
+		if err := json.Unmarshal(proofs.Bytes(), &p); err != nil {
+			return nil, fmt.Errorf("%w: proof at offset %d: %v", ErrInvalidBundle, offset, err)
+		}
+		if ev.Offset != offset || p.Offset != offset {
+			return nil, fmt.Errorf("%w: expected offset %d, found event %d and proof %d", ErrInvalidBundle, offset, ev.Offset, p.Offset)
+		}
+		path := make([][TreeHashSize]byte, len(p.Path))
+		for i, h := range p.Path {
+			path[i] = h
+		}
+		root, ok := rootFromPath(uint64(offset), m.TreeSize, leafHash(offset, ev.Data), path)
+		if !ok || root != head.RootHash {
+			return nil, fmt.Errorf("%w: event at offset %d is not in the signed tree", ErrInvalidBundle, offset)
+		}
+		b.Events = append(b.Events, Event{StreamID: m.StreamID, Offset: offset, Data: ev.Data})
+	}
+	if events.Scan() || proofs.Scan() {
+		return nil, fmt.Errorf("%w: events beyond offset %d", ErrInvalidBundle, m.To)
+	}
+	if err := errors.Join(events.Err(), proofs.Err()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	return b, nil
+}
+
+func readBundleFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxBundleFile+1))
+	if err == nil && len(data) > maxBundleFile {
+		err = errors.New("file too large")
+	}
+	return data, err
+}
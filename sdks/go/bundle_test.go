@@ -0,0 +1,117 @@
+package kimberlite
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBundle(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := fakeLog{}
+	for i := 0; i < 13; i++ {
+		log[1] = append(log[1], Event{StreamID: 1, Offset: Offset(i), Data: []byte(fmt.Sprintf("event-%d", i)), Timestamp: time.Unix(int64(i), 0)})
+	}
+	ctx := WithAudit(context.Background(), AuditContext{Actor: "legal", Reason: "case 42"})
+	var buf bytes.Buffer
+	m, err := exportBundle(ctx, log, 7, &buf, 1, priv, WithBundleRange(3, 8), WithCustodian("records"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TreeSize != 13 || m.From != 3 || m.To != 8 || m.Actor != "legal" || m.Reason != "case 42" || m.TenantID != 7 {
+		t.Fatalf("manifest %+v", m)
+	}
+
+	b, err := VerifyBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Events) != 5 || string(b.Events[0].Data) != "event-3" || !b.Events[4].Timestamp.IsZero() || b.Manifest.Custodian != "records" {
+		t.Fatalf("bundle %+v", b)
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, err := VerifyBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()), other); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("wrong key: expected ErrInvalidBundle, got %v", err)
+	}
+
+	// Altering, adding or removing files breaks the bundle.
+	for name, edit := range map[string]func(map[string][]byte){
+		"altered event": func(f map[string][]byte) {
+			f[BundleEventsFile] = bytes.Replace(f[BundleEventsFile], []byte("ZXZlbnQtNQ=="), []byte("Zm9yZ2VkLTU="), 1)
+		},
+		"extra file":    func(f map[string][]byte) { f["notes.txt"] = []byte("x") },
+		"missing proof": func(f map[string][]byte) { delete(f, BundleProofsFile) },
+	} {
+		files := unzipBundle(t, buf.Bytes())
+		edit(files)
+		data := zipBundle(t, files)
+		if _, err := VerifyBundle(bytes.NewReader(data), int64(len(data)), pub); !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("%s: expected ErrInvalidBundle, got %v", name, err)
+		}
+	}
+
+	// A forged event in a re-signed manifest is not in the signed tree.
+	files := unzipBundle(t, buf.Bytes())
+	files[BundleEventsFile] = bytes.Replace(files[BundleEventsFile], []byte("ZXZlbnQtNQ=="), []byte("Zm9yZ2VkLTU="), 1)
+	var forged BundleManifest
+	if err := json.Unmarshal(files[BundleManifestFile], &forged); err != nil {
+		t.Fatal(err)
+	}
+	forged.Files[BundleEventsFile] = sha256.Sum256(files[BundleEventsFile])
+	files[BundleManifestFile], _ = json.Marshal(forged)
+	files[BundleSignatureFile] = ed25519.Sign(priv, files[BundleManifestFile])
+	data := zipBundle(t, files)
+	if _, err := VerifyBundle(bytes.NewReader(data), int64(len(data)), pub); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("forged event: expected ErrInvalidBundle, got %v", err)
+	}
+
+	if _, err := exportBundle(ctx, log, 7, io.Discard, 1, priv, WithBundleRange(9, 14)); err == nil {
+		t.Fatal("range beyond stream exported")
+	}
+}
+
+func unzipBundle(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		b, err := readBundleFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = b
+	}
+	return files
+}
+
+func zipBundle(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
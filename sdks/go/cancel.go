@@ -0,0 +1,41 @@
+package kimberlite
+
+import "context"
+
+// abandonable runs fn, but returns ctx's error as soon as ctx is done
+// even if fn is still running. The protocol has no message cancelling
+// a statement, so an abandoned fn runs to completion and its result is
+// discarded; only the caller is released.
+//
+// fn holds a read lock of c.mu of its own, so the connection it uses
+// is not closed or replaced under it: Close and token refreshes wait
+// for abandoned statements. When a writer is already waiting for the
+// lock, fn runs in the caller and is not abandoned.
+func abandonable[T any](ctx context.Context, c *Client, fn func() (T, error)) (T, error) {
+	if ctx.Done() == nil {
+		return fn()
+	}
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if !c.mu.TryRLock() {
+		return fn()
+	}
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer c.mu.RUnlock()
+		v, err := fn()
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,89 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingBackend answers queries once release is closed.
+type blockingBackend struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) Query(string, []Value) (*QueryResult, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return NewQueryResult([]string{"n"}, []Row{{NewInt(1)}}), nil
+}
+
+func (b *blockingBackend) Execute(string, []Value) (*ExecResult, error) { return &ExecResult{}, nil }
+func (b *blockingBackend) CreateStream(string, DataClass, Region) (*StreamInfo, error) {
+	return &StreamInfo{}, nil
+}
+func (b *blockingBackend) Append(StreamID, Offset, [][]byte) (Offset, error) { return 0, nil }
+func (b *blockingBackend) StreamLength(StreamID) (Offset, error)             { return 0, nil }
+func (b *blockingBackend) ReadEvents(StreamID, Offset, uint64) ([]Event, error) {
+	return nil, nil
+}
+
+func TestQueryCancellation(t *testing.T) {
+	b := &blockingBackend{started: make(chan struct{}, 1), release: make(chan struct{})}
+	c, err := Connect("test", WithTenant(1), WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.QueryContext(ctx, "SELECT n FROM slow")
+		errc <- err
+	}()
+	<-b.started
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("cancelled query: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled query did not return")
+	}
+
+	// Close waits for the abandoned query.
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("closed while the abandoned query ran")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(b.release)
+	<-closed
+
+	if _, err := c.QueryContext(ctx, "SELECT n FROM slow"); !errors.Is(err, ErrNotConnected) && !errors.Is(err, context.Canceled) {
+		t.Fatalf("query after close: %v", err)
+	}
+}
+
+func TestQueryCompletesBeforeCancellation(t *testing.T) {
+	b := &blockingBackend{started: make(chan struct{}, 1), release: make(chan struct{})}
+	close(b.release)
+	c, err := Connect("test", WithTenant(1), WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r, err := c.QueryContext(ctx, "SELECT n FROM fast")
+	if err != nil || len(r.Rows) != 1 {
+		t.Fatalf("query: %+v, %v", r, err)
+	}
+}
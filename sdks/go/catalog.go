@@ -0,0 +1,108 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// TableInfo summarises a table in the tenant's catalogue.
+type TableInfo struct {
+	Name        string `json:"name"`
+	ColumnCount int    `json:"column_count"`
+}
+
+// TableDescription is a table's declared schema.
+type TableDescription struct {
+	Name string
+	// Columns are in declaration order.
+	Columns []ColumnType
+}
+
+// PrimaryKey returns the names of the primary key columns, in
+// declaration order.
+func (d *TableDescription) PrimaryKey() []string {
+	var key []string
+	for _, col := range d.Columns {
+		if col.PrimaryKey {
+			key = append(key, col.Name)
+		}
+	}
+	return key
+}
+
+// IndexInfo describes an index on a table.
+type IndexInfo struct {
+	Name string `json:"name"`
+	// Columns are the indexed columns, in key order.
+	Columns []string `json:"columns"`
+}
+
+// ListTables returns the tables in the tenant's catalogue.
+//
+// The catalogue is read through the admin API rather than
+// information_schema, which the server does not provide.
+func (c *Client) ListTables(ctx context.Context) ([]TableInfo, error) {
+	raw, err := c.catalogCall(ctx, &Operation{Name: OpListTables}, func() ([]byte, error) {
+		return ffiListTables(c.kmbHandle)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeTables(raw)
+}
+
+// DescribeTable returns the declared schema of table.
+func (c *Client) DescribeTable(ctx context.Context, table string) (*TableDescription, error) {
+	raw, err := c.catalogCall(ctx, &Operation{Name: OpDescribeTable, Table: table}, func() ([]byte, error) {
+		return ffiDescribeTable(c.kmbHandle, table)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeTableDescription(raw)
+}
+
+// ListIndexes returns the secondary indexes on table. The primary key
+// is not listed; see TableDescription.PrimaryKey.
+//
+// The server does not yet report index metadata: for a table that
+// exists the list is empty.
+func (c *Client) ListIndexes(ctx context.Context, table string) ([]IndexInfo, error) {
+	raw, err := c.catalogCall(ctx, &Operation{Name: OpListIndexes, Table: table}, func() ([]byte, error) {
+		return ffiListIndexes(c.kmbHandle, table)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeIndexes(raw)
+}
+
+func (c *Client) catalogCall(ctx context.Context, op *Operation, fn func() ([]byte, error)) ([]byte, error) {
+	var raw []byte
+	err := c.call(ctx, op, func(context.Context) (err error) {
+		raw, err = fn()
+		return err
+	})
+	return raw, err
+}
+
+func decodeTables(raw []byte) ([]TableInfo, error) {
+	var resp struct {
+		Tables []TableInfo `json:"tables"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode table list: %w", err)
+	}
+	return resp.Tables, nil
+}
+
+func decodeIndexes(raw []byte) ([]IndexInfo, error) {
+	var resp struct {
+		Indexes []IndexInfo `json:"indexes"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode index list: %w", err)
+	}
+	return resp.Indexes, nil
+}
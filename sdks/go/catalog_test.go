@@ -0,0 +1,54 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDecodeCatalog(t *testing.T) {
+	tables, err := decodeTables([]byte(`{"tables":[{"name":"patients","column_count":4},{"name":"wards","column_count":2}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0] != (TableInfo{Name: "patients", ColumnCount: 4}) {
+		t.Fatalf("tables = %+v", tables)
+	}
+
+	desc, err := decodeTableDescription([]byte(`{"table_name":"beds","columns":[
+		{"name":"ward","data_type":"TEXT","nullable":false,"primary_key":true},
+		{"name":"bed","data_type":"BIGINT","nullable":false,"primary_key":true},
+		{"name":"patient_id","data_type":"BIGINT","nullable":true,"primary_key":false}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Name != "beds" || len(desc.Columns) != 3 || !desc.Columns[2].Nullable {
+		t.Fatalf("description = %+v", desc)
+	}
+	if key := desc.PrimaryKey(); !slices.Equal(key, []string{"ward", "bed"}) {
+		t.Fatalf("primary key = %v", key)
+	}
+
+	indexes, err := decodeIndexes([]byte(`{"indexes":[{"name":"beds_patient","columns":["patient_id"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 1 || indexes[0].Name != "beds_patient" || !slices.Equal(indexes[0].Columns, []string{"patient_id"}) {
+		t.Fatalf("indexes = %+v", indexes)
+	}
+
+	if _, err := decodeTables([]byte(`{`)); err == nil {
+		t.Fatal("expected an error for malformed JSON")
+	}
+}
+
+func TestCatalogClosedClient(t *testing.T) {
+	c := &Client{closed: true}
+	if _, err := c.ListTables(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("ListTables err = %v", err)
+	}
+	if _, err := c.ListIndexes(context.Background(), "beds"); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("ListIndexes err = %v", err)
+	}
+}
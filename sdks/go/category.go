@@ -0,0 +1,129 @@
+package kimberlite
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Events are often read across streams rather than one stream at a
+// time: all events of every patient-<id> stream, or every Admission
+// wherever it was appended. The server has no such system streams, nor
+// filtered reads, so the client reads them itself: CategoryChanges and
+// EventTypeChanges return change consumers over the streams whose
+// names the client knows, including streams named after the consumer
+// was created:
+//
+//	patients, err := client.CategoryChanges("patient")      // $ce-patient
+//	admissions, err := client.EventTypeChanges("Admission") // $et-Admission
+//	rec, err := patients.Next(ctx)
+//
+// Stream names are recorded for streams created through the client;
+// SetStreamName records those of other streams. SystemStream accepts
+// the "$ce-" and "$et-" names of other event stores.
+
+// ErrUnknownSystemStream is returned by SystemStream for names other
+// than "$ce-<category>" and "$et-<event type>".
+var ErrUnknownSystemStream = errors.New("kimberlite: unknown system stream")
+
+// CategorySeparator separates a stream's category from its ID in
+// stream names, as in "patient-42".
+const CategorySeparator = "-"
+
+// SetStreamName records the name of a stream created outside the
+// client, for category reads. Streams created through the client are
+// recorded automatically.
+func (c *Client) SetStreamName(id StreamID, name string) {
+	c.names.set(id, name)
+}
+
+// StreamName returns the name of stream id, if the client knows it.
+func (c *Client) StreamName(id StreamID) (string, bool) {
+	return c.names.get(id)
+}
+
+// CategoryStreams returns the known streams of category, those named
+// category followed by CategorySeparator, in ID order.
+func (c *Client) CategoryStreams(category string) []StreamID {
+	prefix := category + CategorySeparator
+	return c.names.matching(func(name string) bool { return strings.HasPrefix(name, prefix) })
+}
+
+// CategoryChanges returns a consumer of the changes to the streams of
+// category.
+func (c *Client) CategoryChanges(category string, opts ...ChangeOption) (*ChangeConsumer, error) {
+	return c.discoveringChanges(func() []StreamID { return c.CategoryStreams(category) }, opts)
+}
+
+// EventTypeChanges returns a consumer of the events of eventType in
+// every stream the client knows, as with KnownStreamChanges.
+func (c *Client) EventTypeChanges(eventType string, opts ...ChangeOption) (*ChangeConsumer, error) {
+	opts = append(append([]ChangeOption(nil), opts...), WithEventTypes(eventType))
+	return c.discoveringChanges(c.knownStreams, opts)
+}
+
+// SystemStream returns the consumer of a "$ce-<category>" or
+// "$et-<event type>" system stream.
+func (c *Client) SystemStream(name string, opts ...ChangeOption) (*ChangeConsumer, error) {
+	switch {
+	case strings.HasPrefix(name, "$ce-") && len(name) > len("$ce-"):
+		return c.CategoryChanges(strings.TrimPrefix(name, "$ce-"), opts...)
+	case strings.HasPrefix(name, "$et-") && len(name) > len("$et-"):
+		return c.EventTypeChanges(strings.TrimPrefix(name, "$et-"), opts...)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownSystemStream, name)
+}
+
+func (c *Client) discoveringChanges(discover func() []StreamID, opts []ChangeOption) (*ChangeConsumer, error) {
+	f, err := newChangeConsumer(c, c.codecRegistry(), discover(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	f.discover = discover
+	return f, nil
+}
+
+// WithEventTypes makes a consumer return only events of the given
+// types. The other events are passed over, and the cursor moves past
+// them.
+func WithEventTypes(types ...string) ChangeOption {
+	return func(f *ChangeConsumer) { f.types = append(f.types, types...) }
+}
+
+// streamNames records the names of streams.
+type streamNames struct {
+	mu    sync.RWMutex
+	names map[StreamID]string
+}
+
+func (s *streamNames) set(id StreamID, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.names == nil {
+		s.names = make(map[StreamID]string)
+	}
+	s.names[id] = name
+}
+
+func (s *streamNames) get(id StreamID) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	name, ok := s.names[id]
+	return name, ok
+}
+
+// matching returns the streams whose names match, in ID order.
+func (s *streamNames) matching(match func(name string) bool) []StreamID {
+	s.mu.RLock()
+	var ids []StreamID
+	for id, name := range s.names {
+		if match(name) {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.RUnlock()
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,59 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCategoryStreams(t *testing.T) {
+	c := &Client{}
+	c.SetStreamName(3, "patient-42")
+	c.SetStreamName(1, "patient-7")
+	c.SetStreamName(2, "patients")
+	c.SetStreamName(4, "ward-1")
+	if got := c.CategoryStreams("patient"); !slices.Equal(got, []StreamID{1, 3}) {
+		t.Fatalf("patient streams: %v", got)
+	}
+	if name, ok := c.StreamName(4); !ok || name != "ward-1" {
+		t.Fatalf("stream 4: %q, %v", name, ok)
+	}
+	for _, name := range []string{"$ce-", "$all", "patient"} {
+		if _, err := c.SystemStream(name); !errors.Is(err, ErrUnknownSystemStream) {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestEventTypeFilterAndDiscovery(t *testing.T) {
+	ctx := context.Background()
+	log := fakeLog{}
+	log.append(t, 1, Envelope{Type: "Admitted"})
+	log.append(t, 1, Envelope{Type: "Discharged"})
+	log.append(t, 2, Envelope{Type: "Admitted"})
+
+	streams := []StreamID{1}
+	f, err := newChangeConsumer(log, NewCodecRegistry(), streams, WithEventTypes("Admitted"), WithChangePollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.discover = func() []StreamID { return streams }
+
+	rec, err := f.Next(ctx)
+	if err != nil || rec.StreamID != 1 || rec.Offset != 0 || rec.Position != 1 {
+		t.Fatalf("first: stream %d offset %d position %d, %v", rec.StreamID, rec.Offset, rec.Position, err)
+	}
+
+	// A stream named after the consumer was created is read from its
+	// start; the Discharged event is passed over.
+	streams = append(streams, 2)
+	rec, err = f.Next(ctx)
+	if err != nil || rec.StreamID != 2 || rec.Offset != 0 || rec.Position != 2 {
+		t.Fatalf("second: stream %d offset %d position %d, %v", rec.StreamID, rec.Offset, rec.Position, err)
+	}
+	if f.delivered[1] != 2 {
+		t.Fatalf("stream 1 delivered up to %d, want 2", f.delivered[1])
+	}
+}
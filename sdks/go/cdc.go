@@ -0,0 +1,316 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ChangeRecord is one change delivered by a ChangeConsumer.
+type ChangeRecord struct {
+	// Position is the record's sequence number in the consumer's
+	// output. It is strictly increasing and gap-free across resumes
+	// from the consumer's cursors, but it is assigned by the client:
+	// the server exposes no tenant-wide log position.
+	Position uint64
+	StreamID StreamID
+	Offset   Offset
+	Event    Event
+	// Envelope is the decoded event; raw events carry only Data.
+	Envelope Envelope
+	Schema   ChangeSchema
+	// Cursor resumes the consumer immediately after this record.
+	Cursor Cursor
+}
+
+// ChangeSchema describes a change's payload.
+type ChangeSchema struct {
+	// EventType and ContentType come from the envelope.
+	EventType   string
+	ContentType string
+	// ProtoMessage is the message name of protobuf payloads.
+	ProtoMessage string
+	// JSONSchema is the schema registered for EventType with
+	// CodecRegistry.RegisterSchema, if any.
+	JSONSchema json.RawMessage
+}
+
+// Cursor is an opaque, resumable position of a ChangeConsumer. It is
+// safe to persist and to pass between processes.
+type Cursor string
+
+// ErrInvalidCursor is returned for cursors the SDK did not produce.
+var ErrInvalidCursor = errors.New("kimberlite: invalid change cursor")
+
+// eventReader is the part of Client used by stream consumers.
+type eventReader interface {
+	ReadEventsContext(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64) ([]Event, error)
+}
+
+type cursorState struct {
+	Version  int               `json:"v"`
+	Position uint64            `json:"p"`
+	Streams  map[string]uint64 `json:"s"`
+}
+
+// ChangeConsumer yields the events of a set of streams as ordered
+// change records, in the manner of a change-data-capture connector.
+// Records of one stream are delivered in offset order; streams are
+// interleaved batch by batch. A ChangeConsumer is not safe for
+// concurrent use.
+type ChangeConsumer struct {
+	source   eventReader
+	registry *CodecRegistry
+	streams  []StreamID
+	cursor   Cursor
+	maxBytes uint64
+	interval time.Duration
+
+	delivered map[StreamID]Offset
+	fetched   map[StreamID]Offset
+	position  uint64
+	buf       []Event
+
+	// discover, if set, returns the streams to consume, checked for new
+	// ones before each read.
+	discover func() []StreamID
+	known    map[StreamID]bool
+	// types, if set, are the event types returned.
+	types []string
+	// retry makes Next retry reads that failed transiently.
+	retry bool
+}
+
+// ChangeOption configures a ChangeConsumer.
+type ChangeOption func(*ChangeConsumer)
+
+// WithCursor resumes from a cursor returned by an earlier consumer.
+// Streams recorded in the cursor are consumed even if not passed to
+// Changes; other streams start at offset 0.
+func WithCursor(c Cursor) ChangeOption {
+	return func(f *ChangeConsumer) { f.cursor = c }
+}
+
+// WithChangePollInterval sets how long Next waits before polling again
+// when every stream is caught up. The default is one second.
+func WithChangePollInterval(d time.Duration) ChangeOption {
+	return func(f *ChangeConsumer) { f.interval = d }
+}
+
+// WithChangeBatchBytes sets the maxBytes of each read. The default is
+// 1 MiB.
+func WithChangeBatchBytes(n uint64) ChangeOption {
+	return func(f *ChangeConsumer) { f.maxBytes = n }
+}
+
+// WithChangeRetry makes Next retry reads failing with ErrConnectionFailed
+// or ErrTimeout, as they do while a cluster fails over, at the poll
+// interval until they succeed or ctx is done, rather than return the
+// error.
+func WithChangeRetry() ChangeOption {
+	return func(f *ChangeConsumer) { f.retry = true }
+}
+
+// Changes returns a consumer of the changes to streams:
+//
+//	changes, err := client.Changes([]kimberlite.StreamID{admissions},
+//	    kimberlite.WithCursor(saved))
+//	for {
+//	    rec, err := changes.Next(ctx)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    publish(rec)
+//	    saved = rec.Cursor
+//	}
+func (c *Client) Changes(streams []StreamID, opts ...ChangeOption) (*ChangeConsumer, error) {
+	return newChangeConsumer(c, c.codecRegistry(), streams, opts...)
+}
+
+// KnownStreamChanges returns a consumer of the changes to every stream
+// the client knows, for search indexers and cache invalidation. Its
+// cursors record every stream consumed, so a consumer resumed from a
+// cursor after a restart continues all of them, and hold stream
+// offsets, which every replica agrees on, so they stay valid across
+// failovers, whose read errors the consumer retries as with
+// WithChangeRetry.
+//
+// The server cannot list streams, so this is not every stream of the
+// tenant: only those the client knows, created through it, resumed from
+// the cursor, or recorded with SetStreamName, SetStreamClass or
+// SetStreamRegion, including streams recorded after the consumer was
+// created. A stream another client created is missed until it is
+// recorded; consumers that must see every stream pass them to Changes.
+func (c *Client) KnownStreamChanges(opts ...ChangeOption) (*ChangeConsumer, error) {
+	opts = append([]ChangeOption{WithChangeRetry()}, opts...)
+	return c.discoveringChanges(c.knownStreams, opts)
+}
+
+// knownStreams returns the streams the client knows of, in ID order.
+func (c *Client) knownStreams() []StreamID {
+	ids := c.names.matching(func(string) bool { return true })
+	c.streams.mu.RLock()
+	for id := range c.streams.classes {
+		ids = append(ids, id)
+	}
+	c.streams.mu.RUnlock()
+	c.regions.mu.RLock()
+	for id := range c.regions.regions {
+		ids = append(ids, id)
+	}
+	c.regions.mu.RUnlock()
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+func newChangeConsumer(source eventReader, registry *CodecRegistry, streams []StreamID, opts ...ChangeOption) (*ChangeConsumer, error) {
+	f := &ChangeConsumer{
+		source:    source,
+		registry:  registry,
+		maxBytes:  1 << 20,
+		interval:  time.Second,
+		delivered: make(map[StreamID]Offset),
+		fetched:   make(map[StreamID]Offset),
+		known:     make(map[StreamID]bool),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	for _, id := range streams {
+		f.add(id)
+	}
+	if f.cursor != "" {
+		st, err := decodeCursor(f.cursor)
+		if err != nil {
+			return nil, err
+		}
+		f.position = st.Position
+		for _, k := range sortedKeys(st.Streams) {
+			n, err := strconv.ParseUint(k, 10, 64)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			id := StreamID(n)
+			f.add(id)
+			f.delivered[id] = Offset(st.Streams[k])
+			f.fetched[id] = Offset(st.Streams[k])
+		}
+	}
+	return f, nil
+}
+
+// Next returns the next change, waiting for one if every stream is
+// caught up, until ctx is done. An event that is not a valid envelope
+// is returned with an error wrapping ErrInvalidEnvelope and its raw
+// data in Envelope.Data; it is consumed all the same, so calling Next
+// again continues with the following change.
+func (f *ChangeConsumer) Next(ctx context.Context) (ChangeRecord, error) {
+	var (
+		ev     Event
+		env    Envelope
+		envErr error
+	)
+	for {
+		for len(f.buf) == 0 {
+			if err := f.fill(ctx); err != nil && !(f.retry && transientReadError(err)) {
+				return ChangeRecord{}, err
+			}
+			if len(f.buf) > 0 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ChangeRecord{}, ctx.Err()
+			case <-time.After(f.interval):
+			}
+		}
+		ev = f.buf[0]
+		f.buf = f.buf[1:]
+		f.delivered[ev.StreamID] = ev.Offset + 1
+		env, envErr = UnmarshalEnvelope(ev.Data)
+		if envErr != nil || len(f.types) == 0 || slices.Contains(f.types, env.Type) {
+			break
+		}
+	}
+	f.position++
+
+	rec := ChangeRecord{
+		Position: f.position,
+		StreamID: ev.StreamID,
+		Offset:   ev.Offset,
+		Event:    ev,
+		Cursor:   f.Cursor(),
+	}
+	if envErr != nil {
+		rec.Envelope = Envelope{Data: ev.Data}
+		return rec, fmt.Errorf("kimberlite: stream %d offset %d: %w", ev.StreamID, ev.Offset, envErr)
+	}
+	rec.Envelope = env
+	rec.Schema = ChangeSchema{
+		EventType:    env.Type,
+		ContentType:  env.Metadata[metaContentType],
+		ProtoMessage: env.Metadata[metaProtoMessage],
+	}
+	if env.Type != "" {
+		if s := f.registry.schemaFor(env.Type); s != nil {
+			rec.Schema.JSONSchema = s.Document()
+		}
+	}
+	return rec, nil
+}
+
+// Cursor returns the position after the last record returned by Next.
+func (f *ChangeConsumer) Cursor() Cursor {
+	st := cursorState{Version: 1, Position: f.position, Streams: make(map[string]uint64, len(f.streams))}
+	for _, id := range f.streams {
+		st.Streams[strconv.FormatUint(uint64(id), 10)] = uint64(f.delivered[id])
+	}
+	b, _ := json.Marshal(st)
+	return Cursor(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// add makes the consumer consume stream id, from offset 0 unless
+// resumed from a cursor.
+func (f *ChangeConsumer) add(id StreamID) {
+	if !f.known[id] {
+		f.known[id] = true
+		f.streams = append(f.streams, id)
+	}
+}
+
+func (f *ChangeConsumer) fill(ctx context.Context) error {
+	if f.discover != nil {
+		for _, id := range f.discover() {
+			f.add(id)
+		}
+	}
+	for _, id := range f.streams {
+		events, err := f.source.ReadEventsContext(ctx, id, f.fetched[id], f.maxBytes)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			f.buf = append(f.buf, events...)
+			f.fetched[id] = events[len(events)-1].Offset + 1
+		}
+	}
+	return nil
+}
+
+func transientReadError(err error) bool {
+	return errors.Is(err, ErrConnectionFailed) || errors.Is(err, ErrTimeout)
+}
+
+func decodeCursor(c Cursor) (cursorState, error) {
+	var st cursorState
+	b, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil || json.Unmarshal(b, &st) != nil || st.Version != 1 {
+		return st, ErrInvalidCursor
+	}
+	return st, nil
+}
@@ -0,0 +1,144 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+type fakeLog map[StreamID][]Event
+
+func (l fakeLog) ReadEventsContext(_ context.Context, id StreamID, from Offset, _ uint64) ([]Event, error) {
+	events := l[id]
+	if int(from) >= len(events) {
+		return nil, nil
+	}
+	return events[from:], nil
+}
+
+func (l fakeLog) append(t *testing.T, id StreamID, env Envelope) {
+	t.Helper()
+	data, err := env.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l[id] = append(l[id], Event{StreamID: id, Offset: Offset(len(l[id])), Data: data})
+}
+
+func TestChangeConsumer(t *testing.T) {
+	ctx := context.Background()
+	reg := NewCodecRegistry()
+	reg.RegisterSchema("Admitted", MustCompileSchema([]byte(admittedSchema)))
+
+	log := fakeLog{}
+	log.append(t, 1, Envelope{Type: "Admitted", Data: []byte(`{"patient":"p-1","bed":1}`), Metadata: map[string]string{metaContentType: "application/json"}})
+	log.append(t, 2, Envelope{Type: "Discharged"})
+	log.append(t, 1, Envelope{Type: "Admitted"})
+
+	f, err := newChangeConsumer(log, reg, []StreamID{1, 2}, WithChangePollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		stream StreamID
+		offset Offset
+	}{{1, 0}, {1, 1}, {2, 0}}
+	var cursor Cursor
+	for i, w := range want {
+		rec, err := f.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Position != uint64(i+1) || rec.StreamID != w.stream || rec.Offset != w.offset {
+			t.Fatalf("record %d: got position %d stream %d offset %d", i, rec.Position, rec.StreamID, rec.Offset)
+		}
+		if i == 0 {
+			if rec.Schema.EventType != "Admitted" || rec.Schema.ContentType != "application/json" || len(rec.Schema.JSONSchema) == 0 {
+				t.Fatalf("schema: %+v", rec.Schema)
+			}
+			cursor = rec.Cursor
+		}
+	}
+	if f.Cursor() == cursor {
+		t.Fatal("cursor did not advance")
+	}
+
+	// Resume after the first record, from the cursor alone.
+	r, err := newChangeConsumer(log, reg, nil, WithCursor(cursor), WithChangePollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Position != 2 || rec.StreamID != 1 || rec.Offset != 1 {
+		t.Fatalf("resumed at position %d stream %d offset %d", rec.Position, rec.StreamID, rec.Offset)
+	}
+	if rec, err = r.Next(ctx); err != nil || rec.StreamID != 2 {
+		t.Fatalf("got stream %d, %v", rec.StreamID, err)
+	}
+
+	// Caught up: Next waits for new changes until ctx is done.
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.Next(tctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+	log[2] = append(log[2], Event{StreamID: 2, Offset: 1, Data: []byte{0x00, 'K', 'E', 1, 0xff}})
+	rec, err = r.Next(ctx)
+	if !errors.Is(err, ErrInvalidEnvelope) || rec.Offset != 1 || rec.Position != 4 {
+		t.Fatalf("corrupt envelope: position %d offset %d, %v", rec.Position, rec.Offset, err)
+	}
+
+	if _, err := newChangeConsumer(log, reg, nil, WithCursor("garbage")); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// flakyLog fails its first reads, as during a failover.
+type flakyLog struct {
+	fakeLog
+	failures int
+}
+
+func (l *flakyLog) ReadEventsContext(ctx context.Context, id StreamID, from Offset, maxBytes uint64) ([]Event, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, fmt.Errorf("%w: leader changed", ErrConnectionFailed)
+	}
+	return l.fakeLog.ReadEventsContext(ctx, id, from, maxBytes)
+}
+
+func TestKnownStreamChanges(t *testing.T) {
+	c := &Client{}
+	c.SetStreamName(3, "patient-1")
+	c.SetStreamClass(1, DataClassPublic)
+	c.SetStreamRegion(3, RegionUSEast1)
+	if got := c.knownStreams(); !slices.Equal(got, []StreamID{1, 3}) {
+		t.Fatalf("known streams: %v", got)
+	}
+
+	ctx := context.Background()
+	log := &flakyLog{fakeLog: fakeLog{}, failures: 2}
+	log.append(t, 1, Envelope{Type: "Admitted"})
+	f, err := newChangeConsumer(log, NewCodecRegistry(), []StreamID{1}, WithChangeRetry(), WithChangePollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := f.Next(ctx); err != nil || rec.Offset != 0 {
+		t.Fatalf("after failover: offset %d, %v", rec.Offset, err)
+	}
+
+	log.failures = 1
+	f, err = newChangeConsumer(log, NewCodecRegistry(), []StreamID{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Next(ctx); !errors.Is(err, ErrConnectionFailed) {
+		t.Fatalf("without retry: %v", err)
+	}
+}
@@ -2,10 +2,14 @@ package kimberlite
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 	"unsafe"
+
+	"github.com/kimberlitedb/kimberlite-go/internal/embedded"
 )
 
 // Client is the main entry point for interacting with a Kimberlite database.
@@ -18,6 +22,39 @@ type Client struct {
 	closed    bool
 	ffiAvail  bool
 	kmbHandle unsafe.Pointer // opaque handle returned by kmb_client_connect
+	readCache ReadCache
+	codecs    *CodecRegistry
+	tracer    Tracer
+	metrics   MetricsRecorder
+	tsLoc     *time.Location
+
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	logUnredacted bool
+	onSlowQuery   func(context.Context, SlowQuery)
+	healthURL     string
+
+	interceptors []Interceptor
+	receipts     ReceiptSigner
+	encryption   *Encryption
+	pii          *PIIGuard
+	holds        LegalHoldStore
+	tokens       *tokenState
+	attestation  *AttestationPolicy
+	breakGlass   *BreakGlassSession // session of an elevated copy
+	caps         serverCaps
+
+	streams         streamClasses
+	regions         streamRegions
+	names           streamNames
+	backend         Backend
+	statements      *statementCache
+	lowPriority     chan struct{}
+	region          Region
+	enforceClasses  bool
+	secureTransport bool
+	requirePurpose  bool
+	readOnly        bool
 }
 
 // Option configures a Client.
@@ -30,7 +67,8 @@ func WithTenant(id uint64) Option {
 	}
 }
 
-// WithToken sets the authentication token (JWT or API key).
+// WithToken sets the authentication token (JWT or API key). For tokens
+// that expire, use WithTokenProvider.
 func WithToken(token string) Option {
 	return func(c *Client) {
 		c.token = token
@@ -45,11 +83,16 @@ func WithTimeout(d time.Duration) Option {
 }
 
 // Connect creates a new client and establishes a connection to the server.
+// An address with the kimberlitetest:// scheme names a kimberlitetest.Server
+// in the same process, and fails to connect if there is none.
 func Connect(addr string, opts ...Option) (*Client, error) {
 	c := &Client{
 		addr:     addr,
 		timeout:  30 * time.Second,
 		ffiAvail: ffiAvailable(),
+		tsLoc:    time.UTC,
+
+		slowThreshold: DefaultSlowThreshold,
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -59,13 +102,36 @@ func Connect(addr string, opts ...Option) (*Client, error) {
 		return nil, ErrTenantRequired
 	}
 
-	if !c.ffiAvail {
+	if c.backend == nil && embedded.IsAddr(addr) {
+		b, err := embeddedBackend(addr, c.tenant)
+		if err != nil {
+			return nil, err
+		}
+		c.backend = b
+	}
+	if !c.ffiAvail && c.backend == nil {
 		return nil, ErrFFIUnavailable
 	}
 
+	if c.tokens != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		token, err := c.tokens.provider(ctx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%w: token provider: %s", ErrConnectionFailed, err)
+		}
+		c.setToken(token)
+	}
 	if err := c.connect(); err != nil {
+		c.logConnection(ConnEventConnect, err)
 		return nil, fmt.Errorf("%w: %s", ErrConnectionFailed, err)
 	}
+	if err := c.attest(c.kmbHandle); err != nil {
+		c.logConnection(ConnEventConnect, err)
+		c.disconnect()
+		return nil, err
+	}
+	c.connectionEvent(ConnEventConnect)
 
 	return c, nil
 }
@@ -79,20 +145,31 @@ func (c *Client) Close() error {
 		return nil
 	}
 	c.closed = true
+	c.connectionEvent(ConnEventDisconnect)
 	return c.disconnect()
 }
 
-// Query executes a SQL query and returns the results.
-// Equivalent to QueryContext(context.Background(), sql).
-func (c *Client) Query(sql string) (*QueryResult, error) {
-	return c.QueryContext(context.Background(), sql)
+// Query executes a SQL query and returns the results. Positional
+// parameters ($1, $2, ...) are bound from args in order.
+// Equivalent to QueryContext(context.Background(), sql, args...).
+func (c *Client) Query(sql string, args ...Value) (*QueryResult, error) {
+	return c.QueryContext(context.Background(), sql, args...)
 }
 
 // QueryContext executes a SQL query with caller attribution taken
 // from ctx (via WithAudit) if present. Attribution is threaded onto
 // the wire Request.audit so the server's compliance ledger records
 // the actor/reason.
-func (c *Client) QueryContext(ctx context.Context, sql string) (*QueryResult, error) {
+//
+// When ctx is cancelled or its deadline passes, QueryContext returns
+// ctx's error at once. The server has no way to cancel a statement, so
+// it still runs the query to completion, and the client discards the
+// result; Close waits for such abandoned queries.
+func (c *Client) QueryContext(ctx context.Context, sql string, args ...Value) (*QueryResult, error) {
+	return authenticated(ctx, c, func() (*QueryResult, error) { return c.queryContext(ctx, sql, args) })
+}
+
+func (c *Client) queryContext(ctx context.Context, sql string, args []Value) (*QueryResult, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -101,14 +178,93 @@ func (c *Client) QueryContext(ctx context.Context, sql string) (*QueryResult, er
 	}
 
 	var result *QueryResult
-	err := withFFIAudit(ctx, func() error {
-		r, err := c.execQuery(sql)
+	req := &StatementRequest{SQL: sql, Args: args}
+	op := &Operation{Name: OpQuery, Statement: sql}
+	err := c.instrument(ctx, op, req, func(ctx context.Context) error {
+		sql, args := req.SQL, req.Args
+		ctx, cancel := statementContext(ctx)
+		defer cancel()
+		start := time.Now()
+		run, done, err := admit(ctx, c, func() (r *QueryResult, err error) {
+			err = withFFIAudit(ctx, func() error {
+				r, err = c.execQuery(sql, args)
+				return err
+			})
+			return r, err
+		})
+		if err != nil {
+			return err
+		}
+		defer done()
+		r, err := abandonable(ctx, c, run)
+		stats := QueryStats{Elapsed: time.Since(start)}
+		if r != nil {
+			r.Stats = queryStats(r, stats.Elapsed)
+			op.Rows, op.BytesIn = r.Stats.Rows, r.Stats.Bytes
+			stats = r.Stats
+		}
+		c.reportSlow(ctx, OpQuery, sql, stats, err)
 		result = r
 		return err
 	})
 	return result, err
 }
 
+// Exec executes a write statement (INSERT, UPDATE, DELETE, DDL).
+// Equivalent to ExecContext(context.Background(), sql, args...).
+//
+// A statement with a RETURNING clause returns the rows it lists in
+// ExecResult.Returning, with no follow-up SELECT:
+//
+//	r, err := client.Exec("DELETE FROM beds WHERE ward = $1 RETURNING bed, patient_id",
+//	    kimberlite.NewText("closed-ward"))
+func (c *Client) Exec(sql string, args ...Value) (*ExecResult, error) {
+	return c.ExecContext(context.Background(), sql, args...)
+}
+
+// ExecContext is the context-aware variant of Exec. Unlike
+// QueryContext it waits for the statement once it is sent, whatever
+// ctx, since a write abandoned mid-flight may or may not be applied.
+func (c *Client) ExecContext(ctx context.Context, sql string, args ...Value) (*ExecResult, error) {
+	return authenticated(ctx, c, func() (*ExecResult, error) { return c.execContext(ctx, sql, args) })
+}
+
+func (c *Client) execContext(ctx context.Context, sql string, args []Value) (*ExecResult, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, ErrNotConnected
+	}
+
+	var result *ExecResult
+	req := &StatementRequest{SQL: sql, Args: args}
+	op := &Operation{Name: OpExec, Statement: sql}
+	err := c.instrument(ctx, op, req, func(ctx context.Context) error {
+		ctx, cancel := statementContext(ctx)
+		defer cancel()
+		start := time.Now()
+		run, done, err := admit(ctx, c, func() (*ExecResult, error) {
+			return nil, withFFIAudit(ctx, func() error {
+				r, err := c.execStatement(req.SQL, req.Args)
+				if r != nil {
+					op.Rows = r.RowsAffected
+				}
+				result = r
+				return err
+			})
+		})
+		if err != nil {
+			return err
+		}
+		defer done()
+		_, err = run()
+		c.reportSlow(ctx, OpExec, req.SQL, QueryStats{Elapsed: time.Since(start), Rows: op.Rows}, err)
+		return err
+	})
+	return result, err
+}
+
 // CreateStream creates a new event stream with the given name and data class.
 func (c *Client) CreateStream(name string, class DataClass) (*StreamInfo, error) {
 	return c.CreateStreamContext(context.Background(), name, class)
@@ -116,6 +272,10 @@ func (c *Client) CreateStream(name string, class DataClass) (*StreamInfo, error)
 
 // CreateStreamContext is the context-aware variant of CreateStream.
 func (c *Client) CreateStreamContext(ctx context.Context, name string, class DataClass) (*StreamInfo, error) {
+	return authenticated(ctx, c, func() (*StreamInfo, error) { return c.createStreamContext(ctx, name, class, RegionGlobal) })
+}
+
+func (c *Client) createStreamContext(ctx context.Context, name string, class DataClass, region Region) (*StreamInfo, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -124,35 +284,100 @@ func (c *Client) CreateStreamContext(ctx context.Context, name string, class Dat
 	}
 
 	var info *StreamInfo
-	err := withFFIAudit(ctx, func() error {
-		r, err := c.createStream(name, class)
-		info = r
-		return err
+	req := &CreateStreamRequest{Name: name, Class: class, Region: region}
+	op := &Operation{Name: OpCreateStream}
+	err := c.instrument(ctx, op, req, func(ctx context.Context) error {
+		return withFFIAudit(ctx, func() error {
+			r, err := c.createStream(req.Name, req.Class, req.Region)
+			if r != nil {
+				op.StreamID = r.ID
+				c.SetStreamClass(r.ID, req.Class)
+				c.SetStreamRegion(r.ID, req.Region)
+				c.SetStreamName(r.ID, r.Name)
+			}
+			info = r
+			return err
+		})
 	})
 	return info, err
 }
 
-// Append writes one or more events to a stream.
+// Append writes one or more events to a stream, wherever it ends. The
+// server checks the offset of every append, so the client reads the
+// stream's length first, and retries if another writer appended in
+// between; use AppendExpected to detect those writes instead.
 func (c *Client) Append(streamID StreamID, events ...[]byte) (Offset, error) {
 	return c.AppendContext(context.Background(), streamID, events...)
 }
 
 // AppendContext is the context-aware variant of Append.
 func (c *Client) AppendContext(ctx context.Context, streamID StreamID, events ...[]byte) (Offset, error) {
+	return authenticated(ctx, c, func() (Offset, error) { return c.appendContext(ctx, streamID, nil, events) })
+}
+
+// AppendExpected appends events like AppendContext if the stream's
+// next offset is expected, its number of events, and fails with
+// ErrOffsetMismatch otherwise. Writers that read a stream, decide and
+// append use it to detect concurrent appends.
+func (c *Client) AppendExpected(ctx context.Context, streamID StreamID, expected Offset, events ...[]byte) (Offset, error) {
+	return authenticated(ctx, c, func() (Offset, error) { return c.appendContext(ctx, streamID, &expected, events) })
+}
+
+// appendContext appends events expecting the stream to be at expected,
+// or, if it is nil, wherever it is.
+func (c *Client) appendContext(ctx context.Context, streamID StreamID, expected *Offset, events [][]byte) (Offset, error) {
+	first, _, err := c.appendSealed(ctx, streamID, expected, events)
+	return first, err
+}
+
+// appendSealed is appendContext, also returning the events as sent:
+// encrypted if the client encrypts them, and as interceptors left them.
+func (c *Client) appendSealed(ctx context.Context, streamID StreamID, expected *Offset, events [][]byte) (Offset, [][]byte, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	if c.closed {
-		return 0, ErrNotConnected
+		return 0, nil, ErrNotConnected
+	}
+	// Refuse before encryption can create subject keys.
+	if err := c.checkWritable("append"); err != nil {
+		return 0, nil, err
+	}
+	if err := c.checkDataClass(streamID, events); err != nil {
+		return 0, nil, err
+	}
+	if err := c.checkResidency(streamID); err != nil {
+		return 0, nil, err
+	}
+	if c.pii != nil {
+		flagged, err := c.pii.inspect(ctx, streamID, events)
+		c.logPII(ctx, flagged, err)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	if c.encryption != nil {
+		sealed, err := c.encryption.encryptEvents(ctx, c.tenant, streamID, events)
+		if err != nil {
+			return 0, nil, err
+		}
+		events = sealed
 	}
 
 	var offset Offset
-	err := withFFIAudit(ctx, func() error {
-		o, err := c.appendEvents(streamID, events)
-		offset = o
-		return err
+	req := &AppendRequest{StreamID: streamID, Events: events, AnyOffset: expected == nil}
+	if expected != nil {
+		req.ExpectedOffset = *expected
+	}
+	op := &Operation{Name: OpAppend, StreamID: streamID, Events: len(events), BytesOut: payloadSize(events)}
+	err := c.instrument(ctx, op, req, func(ctx context.Context) error {
+		return withFFIAudit(ctx, func() error {
+			o, err := c.appendEvents(req)
+			offset = o
+			return err
+		})
 	})
-	return offset, err
+	return offset, req.Events, err
 }
 
 // ReadEvents reads events from a stream starting at the given offset.
@@ -162,6 +387,35 @@ func (c *Client) ReadEvents(streamID StreamID, from Offset, maxBytes uint64) ([]
 
 // ReadEventsContext is the context-aware variant of ReadEvents.
 func (c *Client) ReadEventsContext(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64) ([]Event, error) {
+	events, err := c.readEventsContext(ctx, streamID, from, maxBytes, true)
+	if err == nil && c.encryption != nil {
+		return c.encryption.decryptEvents(ctx, c.tenant, events)
+	}
+	return events, err
+}
+
+// ReadStoredEvents reads events as the server stores them: encrypted
+// payloads are returned encrypted, and the read cache is bypassed.
+// Inclusion proofs and tree heads cover these bytes, so erasing a
+// subject does not change them, and InclusionProof.Verify takes events
+// read this way.
+func (c *Client) ReadStoredEvents(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64) ([]Event, error) {
+	return c.readEventsContext(ctx, streamID, from, maxBytes, false)
+}
+
+// readEventsContext reads events as stored, without decrypting them,
+// from the read cache if cached is set.
+func (c *Client) readEventsContext(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64, cached bool) ([]Event, error) {
+	if err := c.checkPurpose(ctx, streamID); err != nil {
+		return nil, err
+	}
+	if err := c.checkResidency(streamID); err != nil {
+		return nil, err
+	}
+	return authenticated(ctx, c, func() ([]Event, error) { return c.readStoredContext(ctx, streamID, from, maxBytes, cached) })
+}
+
+func (c *Client) readStoredContext(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64, cached bool) ([]Event, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -170,9 +424,33 @@ func (c *Client) ReadEventsContext(ctx context.Context, streamID StreamID, from
 	}
 
 	var events []Event
-	err := withFFIAudit(ctx, func() error {
-		e, err := c.readEvents(streamID, from, maxBytes)
-		events = e
+	req := &ReadEventsRequest{StreamID: streamID, From: from, MaxBytes: maxBytes}
+	op := &Operation{Name: OpReadEvents, StreamID: streamID}
+	err := c.instrument(ctx, op, req, func(ctx context.Context) error {
+		defer func() {
+			op.Events, op.BytesIn = len(events), int64(eventsSize(events))
+		}()
+
+		cache := c.readCache
+		if !cached {
+			cache = nil
+		}
+		key := ReadCacheKey{Server: c.addr, Tenant: c.tenant, Stream: req.StreamID, From: req.From, MaxBytes: req.MaxBytes}
+		if cache != nil {
+			if hit, ok := cache.Get(key); ok {
+				events, op.CacheHit = hit, true
+				return nil
+			}
+		}
+
+		err := withFFIAudit(ctx, func() error {
+			e, err := c.readEvents(req.StreamID, req.From, req.MaxBytes)
+			events = e
+			return err
+		})
+		if err == nil && cache != nil && rangeClosed(events, req.MaxBytes) {
+			cache.Put(key, events)
+		}
 		return err
 	})
 	return events, err
@@ -181,32 +459,160 @@ func (c *Client) ReadEventsContext(ctx context.Context, streamID StreamID, from
 // --- Internal FFI bridge (implemented in ffi.go) ---
 
 func (c *Client) connect() error {
-	handle, err := ffiConnect(c.addr, uint64(c.tenant), c.token)
+	handle, err := c.dialFFI(c.token)
 	if err != nil {
 		return err
 	}
 	c.kmbHandle = handle
+	c.caps.reset()
 	return nil
 }
 
 func (c *Client) disconnect() error {
 	err := ffiDisconnect(c.kmbHandle)
 	c.kmbHandle = nil
+	c.caps.reset()
 	return err
 }
 
-func (c *Client) execQuery(sql string) (*QueryResult, error) {
-	return ffiQuery(c.kmbHandle, sql)
+func (c *Client) execQuery(sql string, args []Value) (*QueryResult, error) {
+	if err := c.checkStatement(sql); err != nil {
+		return nil, err
+	}
+	sql, args, err := c.bindStatement(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.backend != nil {
+		return c.backend.Query(sql, args)
+	}
+	if err := c.checkBytesParams(args); err != nil {
+		return nil, err
+	}
+	return ffiQuery(c.kmbHandle, sql, args, c.tsLoc)
+}
+
+func (c *Client) execStatement(sql string, args []Value) (*ExecResult, error) {
+	if err := c.checkStatement(sql); err != nil {
+		return nil, err
+	}
+	if hasKeyword(sql, "RETURNING") {
+		return c.execReturning(sql, args)
+	}
+	sql, args, err := c.bindStatement(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.backend != nil {
+		return c.backend.Execute(sql, args)
+	}
+	if err := c.checkBytesParams(args); err != nil {
+		return nil, err
+	}
+	return ffiExecute(c.kmbHandle, sql, args)
+}
+
+// execReturning executes a statement with a RETURNING clause, which
+// the server answers like a query, with the returned rows.
+func (c *Client) execReturning(sql string, args []Value) (*ExecResult, error) {
+	r, err := c.execQuery(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	r.RowsAffected = int64(len(r.Rows))
+	return &ExecResult{RowsAffected: r.RowsAffected, Returning: r}, nil
+}
+
+func (c *Client) createStream(name string, class DataClass, region Region) (*StreamInfo, error) {
+	if err := c.checkWritable("stream creation"); err != nil {
+		return nil, err
+	}
+	var info *StreamInfo
+	var err error
+	if c.backend != nil {
+		info, err = c.backend.CreateStream(name, class, region)
+	} else {
+		info, err = ffiCreateStream(c.kmbHandle, name, class, region)
+	}
+	if info != nil {
+		info.CreatedAt = info.CreatedAt.In(c.tsLoc)
+	}
+	return info, err
+}
+
+// plainAppendAttempts bounds how often a plain append rereads the
+// stream's length after another writer appended first.
+const plainAppendAttempts = 8
+
+// appendEvents sends req. The server checks every append's expected
+// offset, so a plain append expects the stream's length, and is sent
+// again while concurrent appends move the stream past it.
+func (c *Client) appendEvents(req *AppendRequest) (Offset, error) {
+	if !req.AnyOffset {
+		return c.appendAt(req.StreamID, req.ExpectedOffset, req.Events)
+	}
+	var err error
+	for i := 0; i < plainAppendAttempts; i++ {
+		var next, first Offset
+		if next, err = c.streamLength(req.StreamID); err != nil {
+			return 0, err
+		}
+		first, err = c.appendAt(req.StreamID, next, req.Events)
+		if !errors.Is(err, ErrOffsetMismatch) {
+			return first, err
+		}
+	}
+	return 0, err
+}
+
+func (c *Client) appendAt(streamID StreamID, expected Offset, events [][]byte) (Offset, error) {
+	if c.backend != nil {
+		return c.backend.Append(streamID, expected, events)
+	}
+	return ffiAppend(c.kmbHandle, uint64(streamID), uint64(expected), events)
 }
 
-func (c *Client) createStream(name string, class DataClass) (*StreamInfo, error) {
-	return ffiCreateStream(c.kmbHandle, name, class)
+// StreamLength returns the length of stream streamID: the offset its
+// next event will be appended at, and the number of events before it.
+func (c *Client) StreamLength(ctx context.Context, streamID StreamID) (Offset, error) {
+	if err := c.checkPurpose(ctx, streamID); err != nil {
+		return 0, err
+	}
+	if err := c.checkResidency(streamID); err != nil {
+		return 0, err
+	}
+	return authenticated(ctx, c, func() (Offset, error) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		if c.closed {
+			return 0, ErrNotConnected
+		}
+		var length Offset
+		err := c.instrument(ctx, &Operation{Name: OpStreamLength, StreamID: streamID}, nil, func(context.Context) (err error) {
+			length, err = c.streamLength(streamID)
+			return err
+		})
+		return length, err
+	})
 }
 
-func (c *Client) appendEvents(streamID StreamID, events [][]byte) (Offset, error) {
-	return ffiAppend(c.kmbHandle, uint64(streamID), events)
+func (c *Client) streamLength(streamID StreamID) (Offset, error) {
+	if c.backend != nil {
+		return c.backend.StreamLength(streamID)
+	}
+	return ffiStreamLength(c.kmbHandle, uint64(streamID))
 }
 
 func (c *Client) readEvents(streamID StreamID, from Offset, maxBytes uint64) ([]Event, error) {
-	return ffiReadEvents(c.kmbHandle, uint64(streamID), uint64(from), maxBytes)
+	var events []Event
+	var err error
+	if c.backend != nil {
+		events, err = c.backend.ReadEvents(streamID, from, maxBytes)
+	} else {
+		events, err = ffiReadEvents(c.kmbHandle, uint64(streamID), uint64(from), maxBytes)
+	}
+	for i := range events {
+		events[i].Timestamp = events[i].Timestamp.In(c.tsLoc)
+	}
+	return events, err
 }
@@ -0,0 +1,388 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CloudEventsSpecVersion is the CloudEvents specification implemented.
+const CloudEventsSpecVersion = "1.0"
+
+// CloudEventsContentType is the media type of structured-mode events.
+const CloudEventsContentType = "application/cloudevents+json"
+
+// CloudEvent is a CloudEvents 1.0 event, for interoperating with
+// Knative, EventBridge and other CloudEvents consumers.
+type CloudEvent struct {
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	DataContentType string
+	DataSchema      string
+	Time            time.Time
+	// Extensions holds extension attributes. Names must be lowercase
+	// alphanumeric; values are strings, booleans or integers.
+	Extensions map[string]any
+	Data       []byte
+}
+
+// CloudEventSource returns the default source URI for events of a
+// stream, used when an event carries no source of its own.
+func CloudEventSource(tenant TenantID, stream StreamID) string {
+	return fmt.Sprintf("kimberlite://tenant/%d/stream/%d", tenant, stream)
+}
+
+// NewCloudEvent converts an Envelope into a CloudEvent. The envelope's
+// ID and Type become the event's id and type (type defaults to
+// "kimberlite.event"); correlation and causation IDs and metadata
+// become extension attributes.
+func NewCloudEvent(env Envelope, source string, at time.Time) CloudEvent {
+	ce := CloudEvent{
+		ID:     env.ID,
+		Source: source,
+		Type:   env.Type,
+		Time:   at,
+		Data:   env.Data,
+	}
+	if ce.ID == "" {
+		ce.ID = NewEventID()
+	}
+	if ce.Type == "" {
+		ce.Type = "kimberlite.event"
+	}
+	for k, v := range env.Metadata {
+		switch k {
+		case "content_type":
+			ce.DataContentType = v
+		case "subject":
+			ce.Subject = v
+		default:
+			ce.setExtension(k, v)
+		}
+	}
+	if env.CorrelationID != "" {
+		ce.setExtension("correlationid", env.CorrelationID)
+	}
+	if env.CausationID != "" {
+		ce.setExtension("causationid", env.CausationID)
+	}
+	return ce
+}
+
+func (ce *CloudEvent) setExtension(name string, v any) {
+	if !validExtensionName(name) {
+		return
+	}
+	if ce.Extensions == nil {
+		ce.Extensions = make(map[string]any)
+	}
+	ce.Extensions[name] = v
+}
+
+func validExtensionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z') && !isDigit(c) {
+			return false
+		}
+	}
+	switch name {
+	case "id", "source", "type", "subject", "time", "specversion",
+		"datacontenttype", "dataschema", "data", "data_base64":
+		return false
+	}
+	return true
+}
+
+// Validate checks the attributes the specification requires.
+func (ce CloudEvent) Validate() error {
+	switch {
+	case ce.ID == "":
+		return fmt.Errorf("kimberlite: cloudevent: id is required")
+	case ce.Source == "":
+		return fmt.Errorf("kimberlite: cloudevent: source is required")
+	case ce.Type == "":
+		return fmt.Errorf("kimberlite: cloudevent: type is required")
+	}
+	for name := range ce.Extensions {
+		if !validExtensionName(name) {
+			return fmt.Errorf("kimberlite: cloudevent: invalid extension attribute %q", name)
+		}
+	}
+	return nil
+}
+
+// isJSONContentType reports whether data of this media type is JSON
+// and can be embedded in a structured event as "data". An empty type
+// implies JSON per the CloudEvents JSON format.
+func isJSONContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json")
+}
+
+// MarshalStructured encodes the event in structured content mode
+// (application/cloudevents+json). JSON data is embedded as "data";
+// anything else is carried as "data_base64".
+func (ce CloudEvent) MarshalStructured() ([]byte, error) {
+	if err := ce.Validate(); err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, 8+len(ce.Extensions))
+	for k, v := range ce.Extensions {
+		m[k] = v
+	}
+	m["specversion"] = CloudEventsSpecVersion
+	m["id"] = ce.ID
+	m["source"] = ce.Source
+	m["type"] = ce.Type
+	if ce.Subject != "" {
+		m["subject"] = ce.Subject
+	}
+	if ce.DataContentType != "" {
+		m["datacontenttype"] = ce.DataContentType
+	}
+	if ce.DataSchema != "" {
+		m["dataschema"] = ce.DataSchema
+	}
+	if !ce.Time.IsZero() {
+		m["time"] = ce.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if ce.Data != nil {
+		if isJSONContentType(ce.DataContentType) && json.Valid(ce.Data) {
+			m["data"] = json.RawMessage(ce.Data)
+		} else {
+			m["data_base64"] = base64.StdEncoding.EncodeToString(ce.Data)
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalStructuredCloudEvent decodes a structured-mode event.
+func UnmarshalStructuredCloudEvent(b []byte) (CloudEvent, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return CloudEvent{}, fmt.Errorf("kimberlite: cloudevent: %w", err)
+	}
+	str := func(k string) (string, error) {
+		raw, ok := m[k]
+		if !ok {
+			return "", nil
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", fmt.Errorf("kimberlite: cloudevent: attribute %q: %w", k, err)
+		}
+		return s, nil
+	}
+
+	var ce CloudEvent
+	spec, err := str("specversion")
+	if err != nil {
+		return CloudEvent{}, err
+	}
+	if spec != CloudEventsSpecVersion {
+		return CloudEvent{}, fmt.Errorf("kimberlite: cloudevent: unsupported specversion %q", spec)
+	}
+	for _, f := range []struct {
+		key string
+		dst *string
+	}{
+		{"id", &ce.ID}, {"source", &ce.Source}, {"type", &ce.Type}, {"subject", &ce.Subject},
+		{"datacontenttype", &ce.DataContentType}, {"dataschema", &ce.DataSchema},
+	} {
+		if *f.dst, err = str(f.key); err != nil {
+			return CloudEvent{}, err
+		}
+	}
+	if ts, err := str("time"); err != nil {
+		return CloudEvent{}, err
+	} else if ts != "" {
+		if ce.Time, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return CloudEvent{}, fmt.Errorf("kimberlite: cloudevent: time: %w", err)
+		}
+	}
+	if raw, ok := m["data"]; ok {
+		ce.Data = []byte(raw)
+	} else if enc, err := str("data_base64"); err != nil {
+		return CloudEvent{}, err
+	} else if enc != "" {
+		if ce.Data, err = base64.StdEncoding.DecodeString(enc); err != nil {
+			return CloudEvent{}, fmt.Errorf("kimberlite: cloudevent: data_base64: %w", err)
+		}
+	}
+
+	for k, raw := range m {
+		if !validExtensionName(k) || k == "specversion" {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return CloudEvent{}, fmt.Errorf("kimberlite: cloudevent: attribute %q: %w", k, err)
+		}
+		if f, ok := v.(float64); ok && f == float64(int64(f)) {
+			v = int64(f)
+		}
+		ce.setExtension(k, v)
+	}
+	return ce, ce.Validate()
+}
+
+// BinaryHeaders returns the event's attributes as binary content mode
+// headers (ce-id, ce-source, ...) plus Content-Type. The event data is
+// sent unmodified as the message body.
+func (ce CloudEvent) BinaryHeaders() (map[string]string, error) {
+	if err := ce.Validate(); err != nil {
+		return nil, err
+	}
+	h := map[string]string{
+		"ce-specversion": CloudEventsSpecVersion,
+		"ce-id":          ce.ID,
+		"ce-source":      ce.Source,
+		"ce-type":        ce.Type,
+	}
+	if ce.Subject != "" {
+		h["ce-subject"] = ce.Subject
+	}
+	if ce.DataSchema != "" {
+		h["ce-dataschema"] = ce.DataSchema
+	}
+	if !ce.Time.IsZero() {
+		h["ce-time"] = ce.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if ce.DataContentType != "" {
+		h["content-type"] = ce.DataContentType
+	}
+	for k, v := range ce.Extensions {
+		h["ce-"+k] = fmt.Sprint(v)
+	}
+	return h, nil
+}
+
+// CloudEventFromBinary decodes a binary content mode event from its
+// headers (matched case-insensitively) and body. Extension values are
+// returned as strings, since headers carry no type information.
+func CloudEventFromBinary(headers map[string]string, body []byte) (CloudEvent, error) {
+	ce := CloudEvent{Data: body}
+	spec := ""
+	for k, v := range headers {
+		k = strings.ToLower(k)
+		if k == "content-type" {
+			ce.DataContentType = v
+			continue
+		}
+		name, ok := strings.CutPrefix(k, "ce-")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "specversion":
+			spec = v
+		case "id":
+			ce.ID = v
+		case "source":
+			ce.Source = v
+		case "type":
+			ce.Type = v
+		case "subject":
+			ce.Subject = v
+		case "dataschema":
+			ce.DataSchema = v
+		case "time":
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return CloudEvent{}, fmt.Errorf("kimberlite: cloudevent: time: %w", err)
+			}
+			ce.Time = t
+		default:
+			ce.setExtension(name, v)
+		}
+	}
+	if spec != CloudEventsSpecVersion {
+		return CloudEvent{}, fmt.Errorf("kimberlite: cloudevent: unsupported specversion %q", spec)
+	}
+	return ce, ce.Validate()
+}
+
+// AppendCloudEvents appends events to a stream in structured content
+// mode, so the log itself holds standard CloudEvents documents. Events
+// without an ID or source get a generated ID and the stream's default
+// source.
+func (c *Client) AppendCloudEvents(ctx context.Context, streamID StreamID, events ...CloudEvent) (Offset, error) {
+	payloads := make([][]byte, len(events))
+	for i, ce := range events {
+		if ce.ID == "" {
+			ce.ID = NewEventID()
+		}
+		if ce.Source == "" {
+			ce.Source = CloudEventSource(c.tenant, streamID)
+		}
+		if ce.Time.IsZero() {
+			ce.Time = time.Now()
+		}
+		b, err := ce.MarshalStructured()
+		if err != nil {
+			return 0, err
+		}
+		payloads[i] = b
+	}
+	return c.AppendContext(ctx, streamID, payloads...)
+}
+
+// ReadCloudEvents reads events from a stream as CloudEvents.
+// Structured-mode documents are decoded as written; envelopes and raw
+// payloads are converted, with source, type and id populated from the
+// stream and envelope (raw events get the id "<stream>-<offset>"). The
+// converted events have no time, since the log does not record when
+// events were appended.
+func (c *Client) ReadCloudEvents(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64) ([]CloudEvent, error) {
+	events, err := c.ReadEventsContext(ctx, streamID, from, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]CloudEvent, len(events))
+	for i, e := range events {
+		ce, err := c.toCloudEvent(e)
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: event at offset %d: %w", e.Offset, err)
+		}
+		out[i] = ce
+	}
+	return out, nil
+}
+
+func (c *Client) toCloudEvent(e Event) (CloudEvent, error) {
+	if !IsEnvelope(e.Data) && looksLikeStructuredCloudEvent(e.Data) {
+		return UnmarshalStructuredCloudEvent(e.Data)
+	}
+	env, err := e.Envelope()
+	if err != nil {
+		return CloudEvent{}, err
+	}
+	if env.ID == "" {
+		env.ID = strconv.FormatUint(uint64(e.StreamID), 10) + "-" + strconv.FormatUint(uint64(e.Offset), 10)
+	}
+	return NewCloudEvent(env, CloudEventSource(c.tenant, e.StreamID), time.Time{}), nil
+}
+
+func looksLikeStructuredCloudEvent(b []byte) bool {
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	return json.Unmarshal(b, &probe) == nil && probe.SpecVersion != ""
+}
@@ -0,0 +1,76 @@
+package kimberlite
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCloudEventStructuredRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	in := NewCloudEvent(Envelope{
+		ID:            "evt-1",
+		Type:          "PatientAdmitted",
+		CorrelationID: "wf-1",
+		Metadata:      map[string]string{"ward": "icu", "Bad-Key": "x"},
+		Data:          []byte(`{"bed":4}`),
+	}, CloudEventSource(7, 42), at)
+	if in.Source != "kimberlite://tenant/7/stream/42" || in.Extensions["correlationid"] != "wf-1" {
+		t.Fatalf("unexpected attributes: %+v", in)
+	}
+	if _, ok := in.Extensions["Bad-Key"]; ok {
+		t.Fatal("invalid extension names should be dropped")
+	}
+
+	b, err := in.MarshalStructured()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"data":{"bed":4}`)) {
+		t.Fatalf("JSON data should be embedded, got %s", b)
+	}
+	out, err := UnmarshalStructuredCloudEvent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != "evt-1" || out.Type != "PatientAdmitted" || !out.Time.Equal(at) ||
+		out.Extensions["ward"] != "icu" || string(out.Data) != `{"bed":4}` {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+
+	bin := CloudEvent{ID: "e", Source: "s", Type: "t", DataContentType: "application/octet-stream", Data: []byte{0xff, 0x00}}
+	b, err = bin.MarshalStructured()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err = UnmarshalStructuredCloudEvent(b); err != nil || !bytes.Equal(out.Data, bin.Data) {
+		t.Fatalf("data_base64 round trip = %v, %v", out.Data, err)
+	}
+}
+
+func TestCloudEventBinaryMode(t *testing.T) {
+	in := CloudEvent{
+		ID: "e", Source: "s", Type: "t", DataContentType: "text/plain",
+		Extensions: map[string]any{"tenant": int64(3)}, Data: []byte("hi"),
+	}
+	h, err := in.BinaryHeaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h["ce-id"] != "e" || h["content-type"] != "text/plain" || h["ce-tenant"] != "3" {
+		t.Fatalf("unexpected headers: %v", h)
+	}
+	h["CE-Type"] = h["ce-type"] // header names are case-insensitive
+	delete(h, "ce-type")
+	out, err := CloudEventFromBinary(h, []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Type != "t" || out.Extensions["tenant"] != "3" || string(out.Data) != "hi" {
+		t.Fatalf("binary round trip mismatch: %+v", out)
+	}
+
+	if _, err := CloudEventFromBinary(map[string]string{"ce-id": "e"}, nil); err == nil {
+		t.Fatal("missing specversion should fail")
+	}
+}
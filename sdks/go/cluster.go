@@ -0,0 +1,77 @@
+package kimberlite
+
+import (
+	"context"
+	"time"
+)
+
+// Cluster modes of ServerInfo and ClusterInfo.
+const (
+	ClusterModeStandalone = "Standalone"
+	ClusterModeClustered  = "Clustered"
+)
+
+// NodeRole is the replication role of a node.
+type NodeRole string
+
+// Node roles. RoleUnknown is the role of nodes whose server does not
+// report it.
+const (
+	RoleUnknown  NodeRole = ""
+	RoleLeader   NodeRole = "leader"
+	RoleFollower NodeRole = "follower"
+)
+
+// NodeInfo describes a node of a deployment.
+type NodeInfo struct {
+	// Address is the address the client connected to the node with.
+	Address         string
+	Role            NodeRole
+	BuildVersion    string
+	ProtocolVersion int
+	Uptime          time.Duration
+	// ReplicationLag is how many commits the node is behind the leader.
+	// LagKnown reports whether the server reported it; it is zero for a
+	// leader.
+	ReplicationLag uint64
+	LagKnown       bool
+}
+
+// ClusterInfo describes the deployment a client is connected to.
+type ClusterInfo struct {
+	// Mode is ClusterModeStandalone or ClusterModeClustered.
+	Mode string
+	// Nodes are the nodes the server reported, the node the client is
+	// connected to first.
+	Nodes []NodeInfo
+}
+
+// ClusterInfo returns the topology and status of the deployment the
+// client is connected to, for runbooks and dashboards.
+//
+// The server reports only the node the client is connected to, its
+// build and uptime: a standalone server is its own leader, with no
+// lag, but the nodes of a cluster are listed with RoleUnknown and
+// LagKnown false, and their peers not at all. Until the server reports
+// replication status, check other nodes with a client connected to
+// each.
+func (c *Client) ClusterInfo(ctx context.Context) (*ClusterInfo, error) {
+	info, err := c.ServerInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return clusterInfo(c.addr, info), nil
+}
+
+func clusterInfo(addr string, info *ServerInfo) *ClusterInfo {
+	node := NodeInfo{
+		Address:         addr,
+		BuildVersion:    info.BuildVersion,
+		ProtocolVersion: info.ProtocolVersion,
+		Uptime:          info.Uptime,
+	}
+	if info.ClusterMode == ClusterModeStandalone {
+		node.Role, node.LagKnown = RoleLeader, true
+	}
+	return &ClusterInfo{Mode: info.ClusterMode, Nodes: []NodeInfo{node}}
+}
@@ -0,0 +1,32 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClusterInfo(t *testing.T) {
+	standalone := clusterInfo("db:5432", &ServerInfo{BuildVersion: "0.6.0", ProtocolVersion: 3, Uptime: time.Minute, ClusterMode: ClusterModeStandalone})
+	if standalone.Mode != ClusterModeStandalone || len(standalone.Nodes) != 1 {
+		t.Fatalf("standalone %+v", standalone)
+	}
+	if n := standalone.Nodes[0]; n.Address != "db:5432" || n.Role != RoleLeader || !n.LagKnown || n.ReplicationLag != 0 || n.BuildVersion != "0.6.0" || n.Uptime != time.Minute {
+		t.Fatalf("standalone node %+v", n)
+	}
+
+	clustered := clusterInfo("db-1:5432", &ServerInfo{BuildVersion: "0.6.0", ClusterMode: ClusterModeClustered})
+	if n := clustered.Nodes[0]; clustered.Mode != ClusterModeClustered || n.Role != RoleUnknown || n.LagKnown {
+		t.Fatalf("clustered %+v", clustered)
+	}
+
+	client, err := Connect("test", WithTenant(1), WithBackend(&recordingBackend{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.ClusterInfo(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("ClusterInfo with a Backend = %v", err)
+	}
+}
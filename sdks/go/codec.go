@@ -0,0 +1,320 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// Codec serializes event payloads.
+type Codec interface {
+	// ContentType is the media type recorded with every event the
+	// codec encodes, e.g. "application/json".
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// metaContentType is the envelope metadata key holding the payload's
+// media type.
+const metaContentType = "content_type"
+
+// JSONCodec encodes payloads with encoding/json. It is the default.
+var JSONCodec Codec = jsonCodec{}
+
+// GobCodec encodes payloads with encoding/gob. Gob is Go-specific, so
+// prefer JSON or MsgpackCodec for streams read by other SDKs.
+var GobCodec Codec = gobCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                { return "application/json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) ContentType() string { return "application/x-gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ErrUnknownEventType is returned when a Go value is appended whose
+// type has not been registered.
+var ErrUnknownEventType = errors.New("kimberlite: unknown event type")
+
+// MetadataCodec is implemented by codecs that record per-value
+// metadata alongside the payload, such as a message type name.
+type MetadataCodec interface {
+	Codec
+	Metadata(v any) map[string]string
+}
+
+// ResolvingCodec is implemented by codecs that can determine the Go
+// type of a payload from its metadata, so events of types not
+// registered by event type name can still be decoded.
+type ResolvingCodec interface {
+	Codec
+	// New returns a pointer to a new value for the payload described by
+	// meta, or false if the type is unknown.
+	New(meta map[string]string) (any, bool)
+}
+
+// CodecRegistry maps event types to Go types and codecs. A registry is
+// safe for concurrent use.
+type CodecRegistry struct {
+	mu           sync.RWMutex
+	defaultCodec Codec
+	byName       map[string]registeredType
+	byGoType     map[reflect.Type]string
+	byMediaType  map[string]Codec
+	schemas      map[string]*Schema
+	upcasters    map[string]map[int]Upcaster
+}
+
+type registeredType struct {
+	goType reflect.Type
+	codec  Codec
+}
+
+// NewCodecRegistry returns an empty registry whose default codec is
+// JSONCodec.
+func NewCodecRegistry() *CodecRegistry {
+	r := &CodecRegistry{
+		defaultCodec: JSONCodec,
+		byName:       make(map[string]registeredType),
+		byGoType:     make(map[reflect.Type]string),
+		byMediaType:  make(map[string]Codec),
+	}
+	r.RegisterCodec(JSONCodec)
+	r.RegisterCodec(GobCodec)
+	r.RegisterCodec(MsgpackCodec)
+	return r
+}
+
+// DefaultCodecs is the registry used by clients not configured with
+// WithCodecs.
+var DefaultCodecs = NewCodecRegistry()
+
+// WithCodecs sets the registry used by AppendValues and ReadValues.
+func WithCodecs(r *CodecRegistry) Option {
+	return func(c *Client) {
+		c.codecs = r
+	}
+}
+
+// SetDefault sets the codec for event types registered without one.
+func (r *CodecRegistry) SetDefault(codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultCodec = codec
+	r.byMediaType[codec.ContentType()] = codec
+}
+
+// RegisterCodec makes codec available for decoding events carrying its
+// content type, without tying it to an event type.
+func (r *CodecRegistry) RegisterCodec(codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byMediaType[codec.ContentType()] = codec
+}
+
+// Register associates eventType with the Go type of prototype (a value
+// or pointer of that type) and the codec used to encode it. A nil
+// codec uses the registry default.
+func (r *CodecRegistry) Register(eventType string, prototype any, codec Codec) {
+	t := reflect.TypeOf(prototype)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if eventType == "" || t == nil {
+		panic("kimberlite: Register requires an event type and prototype")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byName[eventType] = registeredType{goType: t, codec: codec}
+	r.byGoType[t] = eventType
+	if codec != nil {
+		r.byMediaType[codec.ContentType()] = codec
+	}
+}
+
+// lookup returns the event type and codec registered for Go type t.
+func (r *CodecRegistry) lookup(t reflect.Type) (eventType string, codec Codec, ok bool) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	eventType, ok = r.byGoType[t]
+	if ok {
+		codec = r.byName[eventType].codec
+	}
+	if codec == nil {
+		codec = r.defaultCodec
+	}
+	return eventType, codec, ok
+}
+
+// encode marshals v, returning the event type name and content type
+// to record alongside it.
+func (r *CodecRegistry) encode(v any) (eventType string, codec Codec, data []byte, err error) {
+	eventType, codec, ok := r.lookup(reflect.TypeOf(v))
+	if !ok {
+		return "", nil, nil, fmt.Errorf("%w: %T", ErrUnknownEventType, v)
+	}
+	data, err = codec.Marshal(v)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("kimberlite: encode %s: %w", eventType, err)
+	}
+	return eventType, codec, data, nil
+}
+
+// newEnvelope wraps v's encoded payload, recording its event type,
+// content type and codec metadata. The JSON form of v is validated
+// first if eventType has a schema, whatever the codec.
+func (r *CodecRegistry) newEnvelope(eventType string, codec Codec, v any, data []byte) (Envelope, error) {
+	if r.schemaFor(eventType) != nil {
+		doc := data
+		if codec != JSONCodec {
+			var err error
+			if doc, err = json.Marshal(v); err != nil {
+				return Envelope{}, fmt.Errorf("kimberlite: encode %s: %w", eventType, err)
+			}
+		}
+		if err := r.validatePayload(eventType, doc); err != nil {
+			return Envelope{}, err
+		}
+	}
+	meta := map[string]string{metaContentType: codec.ContentType()}
+	if mc, ok := codec.(MetadataCodec); ok {
+		for k, val := range mc.Metadata(v) {
+			meta[k] = val
+		}
+	}
+	if version := r.SchemaVersion(eventType); version > 1 {
+		meta[metaSchemaVersion] = strconv.Itoa(version)
+	}
+	return Envelope{Type: eventType, Metadata: meta, Data: data}, nil
+}
+
+// codecFor returns the codec for payloads of content type ct, or
+// fallback if ct is empty.
+func (r *CodecRegistry) codecFor(ct string, fallback Codec) (Codec, error) {
+	if ct == "" {
+		return fallback, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c, ok := r.byMediaType[ct]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("kimberlite: no codec for content type %q", ct)
+}
+
+// decode unmarshals an envelope's payload, upcast to the current
+// version of its type, into a new value of its registered Go type (or
+// the type a ResolvingCodec names), returned as a pointer. Events of
+// unknown types decode to nil.
+func (r *CodecRegistry) decode(env Envelope) (any, error) {
+	env, err := r.Upcast(env)
+	if err != nil {
+		return nil, err
+	}
+	ct := env.Metadata[metaContentType]
+	r.mu.RLock()
+	reg, ok := r.byName[env.Type]
+	codec := reg.codec
+	switch {
+	case ct != "":
+		codec = r.byMediaType[ct]
+	case codec == nil:
+		codec = r.defaultCodec
+	}
+	r.mu.RUnlock()
+
+	var ptr any
+	if ok {
+		ptr = reflect.New(reg.goType).Interface()
+	} else if rc, isResolving := codec.(ResolvingCodec); isResolving {
+		ptr, _ = rc.New(env.Metadata)
+	}
+	if ptr == nil {
+		return nil, nil
+	}
+	if codec == nil {
+		return nil, fmt.Errorf("kimberlite: decode %s: no codec for content type %q", env.Type, ct)
+	}
+	if err := codec.Unmarshal(env.Data, ptr); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode %s: %w", env.Type, err)
+	}
+	return ptr, nil
+}
+
+func (c *Client) codecRegistry() *CodecRegistry {
+	if c.codecs != nil {
+		return c.codecs
+	}
+	return DefaultCodecs
+}
+
+// DecodedEvent is an event read by ReadValues.
+type DecodedEvent struct {
+	Event
+	Envelope Envelope
+	// Value is a pointer to the decoded payload, or nil if the event's
+	// type is not registered.
+	Value any
+}
+
+// AppendValues encodes Go values of registered event types and appends
+// them as enveloped events, recording each one's event type and
+// content type so ReadValues (and other SDKs) can decode them.
+func (c *Client) AppendValues(ctx context.Context, streamID StreamID, values ...any) (Offset, error) {
+	reg := c.codecRegistry()
+	envs := make([]Envelope, len(values))
+	for i, v := range values {
+		eventType, codec, data, err := reg.encode(v)
+		if err != nil {
+			return 0, err
+		}
+		if envs[i], err = reg.newEnvelope(eventType, codec, v, data); err != nil {
+			return 0, err
+		}
+	}
+	return c.appendEnvelopes(ctx, streamID, envs, false)
+}
+
+// ReadValues reads events like ReadEnvelopes and decodes the payload
+// of every event whose type is registered.
+func (c *Client) ReadValues(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64) ([]DecodedEvent, error) {
+	events, envs, err := c.ReadEnvelopes(ctx, streamID, from, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	reg := c.codecRegistry()
+	out := make([]DecodedEvent, len(events))
+	for i, e := range events {
+		v, err := reg.decode(envs[i])
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: event at offset %d: %w", e.Offset, err)
+		}
+		out[i] = DecodedEvent{Event: e, Envelope: envs[i], Value: v}
+	}
+	return out, nil
+}
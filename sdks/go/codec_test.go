@@ -0,0 +1,148 @@
+package kimberlite
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type testAdmitted struct {
+	Patient string   `json:"patient"`
+	Bed     int      `json:"bed"`
+	Score   float64  `json:"score"`
+	Tags    []string `json:"tags"`
+	Notes   *string  `json:"notes"`
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	in := testAdmitted{Patient: "p-1", Bed: -300, Score: 0.5, Tags: []string{"icu"}}
+	for _, codec := range []Codec{JSONCodec, GobCodec, MsgpackCodec} {
+		b, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatalf("%s: %v", codec.ContentType(), err)
+		}
+		var out testAdmitted
+		if err := codec.Unmarshal(b, &out); err != nil {
+			t.Fatalf("%s: %v", codec.ContentType(), err)
+		}
+		if out.Patient != in.Patient || out.Bed != in.Bed || out.Score != in.Score || len(out.Tags) != 1 {
+			t.Fatalf("%s round trip = %+v", codec.ContentType(), out)
+		}
+	}
+}
+
+func TestMsgpackWireFormat(t *testing.T) {
+	b, err := MsgpackCodec.Marshal(map[string]any{"b": 1, "a": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x82, 0xa1, 'a', 0xc3, 0xa1, 'b', 0x01}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("msgpack = % x, want % x", b, want)
+	}
+	var v any
+	if err := MsgpackCodec.Unmarshal(b[:4], &v); err == nil {
+		t.Fatal("truncated input should fail")
+	}
+}
+
+func TestCodecRegistryDecode(t *testing.T) {
+	reg := NewCodecRegistry()
+	reg.Register("PatientAdmitted", testAdmitted{}, MsgpackCodec)
+
+	eventType, codec, data, err := reg.encode(&testAdmitted{Patient: "p-2"})
+	if err != nil || eventType != "PatientAdmitted" || codec != MsgpackCodec {
+		t.Fatalf("encode = %q, %v, %v", eventType, codec, err)
+	}
+	v, err := reg.decode(Envelope{
+		Type:     eventType,
+		Metadata: map[string]string{metaContentType: codec.ContentType()},
+		Data:     data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := v.(*testAdmitted); !ok || got.Patient != "p-2" {
+		t.Fatalf("decode = %#v", v)
+	}
+
+	if v, err := reg.decode(Envelope{Type: "Unregistered"}); v != nil || err != nil {
+		t.Fatalf("unregistered type = %v, %v", v, err)
+	}
+	if _, _, _, err := reg.encode(struct{}{}); !errors.Is(err, ErrUnknownEventType) {
+		t.Fatalf("expected ErrUnknownEventType, got %v", err)
+	}
+}
+
+// fakeProto stands in for a protobuf runtime; any payload format works
+// for exercising name registration and resolution.
+type fakeProto struct{}
+
+func (fakeProto) Marshal(m any) ([]byte, error)   { return JSONCodec.Marshal(m) }
+func (fakeProto) Unmarshal(b []byte, m any) error { return JSONCodec.Unmarshal(b, m) }
+func (fakeProto) MessageName(any) string          { return "acme.clinical.v1.PatientAdmitted" }
+
+func TestProtoCodecResolvesByMessageName(t *testing.T) {
+	pc := NewProtoCodec(fakeProto{})
+	writer := NewCodecRegistry()
+	writer.RegisterProto(pc, &testAdmitted{})
+
+	eventType, codec, data, err := writer.encode(&testAdmitted{Patient: "p-3"})
+	if err != nil || eventType != "acme.clinical.v1.PatientAdmitted" {
+		t.Fatalf("encode = %q, %v", eventType, err)
+	}
+	meta := codec.(MetadataCodec).Metadata(&testAdmitted{})
+	if meta[metaProtoMessage] != eventType {
+		t.Fatalf("metadata = %v", meta)
+	}
+
+	// A reader that only knows the codec, not the event type, still
+	// resolves the message through its name.
+	reader := NewCodecRegistry()
+	reader.RegisterCodec(pc)
+	meta[metaContentType] = ProtoContentType
+	v, err := reader.decode(Envelope{Type: "written-by-another-sdk", Metadata: meta, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := v.(*testAdmitted); !ok || got.Patient != "p-3" {
+		t.Fatalf("decode = %#v", v)
+	}
+}
+
+type testEvent interface{ patientID() string }
+
+func (e testAdmitted) patientID() string { return e.Patient }
+
+func TestStreamDecode(t *testing.T) {
+	reg := NewCodecRegistry()
+	reg.Register("PatientAdmitted", testAdmitted{}, MsgpackCodec)
+	c := &Client{codecs: reg}
+
+	typed := NewStream[testAdmitted](c, 1)
+	if typed.eventType != "PatientAdmitted" || typed.codec != MsgpackCodec {
+		t.Fatalf("stream config = %q, %v", typed.eventType, typed.codec)
+	}
+	data, _ := MsgpackCodec.Marshal(testAdmitted{Patient: "p-4"})
+	env, err := reg.newEnvelope(typed.eventType, typed.codec, nil, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := typed.decode(env)
+	if err != nil || got.Patient != "p-4" {
+		t.Fatalf("typed decode = %+v, %v", got, err)
+	}
+
+	union := NewStream[testEvent](c, 1)
+	ev, err := union.decode(env)
+	if err != nil || ev.patientID() != "p-4" {
+		t.Fatalf("union decode = %v, %v", ev, err)
+	}
+	if _, err := union.decode(Envelope{Type: "Unknown"}); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got %v", err)
+	}
+
+	if s := NewStream[struct{ A int }](c, 1, WithEventType("Custom"), WithStreamCodec(GobCodec)); s.eventType != "Custom" || s.codec != GobCodec {
+		t.Fatalf("options not applied: %q, %v", s.eventType, s.codec)
+	}
+}
@@ -0,0 +1,157 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ColumnType describes a result column.
+//
+// The query wire format carries values but not declared column types,
+// so by default the metadata is inferred from the values returned.
+// Declared types, nullability and precision/scale are available once
+// a table's schema has been applied with ApplyColumnTypes, typically
+// from Client.TableColumnTypes.
+type ColumnType struct {
+	// Name is the column name.
+	Name string
+	// DatabaseTypeName is the SQL type without modifiers, e.g.
+	// "BIGINT", "TEXT" or "DECIMAL".
+	DatabaseTypeName string
+	// Declared is true when the metadata comes from the table schema
+	// rather than being inferred from values.
+	Declared bool
+	// Nullable reports whether the column admits NULL. Only meaningful
+	// when NullableKnown is true.
+	Nullable bool
+	// NullableKnown is true when nullability is known.
+	NullableKnown bool
+	// Precision and Scale are set for DECIMAL columns when
+	// HasPrecisionScale is true.
+	Precision, Scale  int64
+	HasPrecisionScale bool
+	// PrimaryKey is true if the column is part of the primary key.
+	PrimaryKey bool
+}
+
+// ColumnTypes returns metadata for each column, in column order.
+func (r *QueryResult) ColumnTypes() []ColumnType {
+	out := make([]ColumnType, len(r.Columns))
+	for i, name := range r.Columns {
+		if ct, ok := r.declaredType(name); ok {
+			out[i] = ct
+			continue
+		}
+		out[i] = r.inferColumnType(name)
+	}
+	return out
+}
+
+// ApplyColumnTypes attaches declared column metadata to the result.
+// Entries are matched to result columns by name (case-insensitively);
+// unmatched result columns keep their inferred metadata.
+func (r *QueryResult) ApplyColumnTypes(types []ColumnType) {
+	if r.declared == nil {
+		r.declared = make(map[string]ColumnType, len(types))
+	}
+	for _, ct := range types {
+		ct.Declared = true
+		r.declared[strings.ToLower(ct.Name)] = ct
+	}
+}
+
+func (r *QueryResult) declaredType(name string) (ColumnType, bool) {
+	ct, ok := r.declared[strings.ToLower(name)]
+	if ok {
+		ct.Name = name
+	}
+	return ct, ok
+}
+
+func (r *QueryResult) inferColumnType(name string) ColumnType {
+	ct := ColumnType{Name: name, DatabaseTypeName: "UNKNOWN"}
+	for _, row := range r.Rows {
+		v, ok := row[name]
+		if !ok || v.IsNull() {
+			continue
+		}
+		ct.DatabaseTypeName = sqlTypeName(v.Type)
+		break
+	}
+	return ct
+}
+
+// sqlTypeName maps a ValueType to the SQL type name that produces it.
+func sqlTypeName(t ValueType) string {
+	switch t {
+	case ValueTypeInteger:
+		return "BIGINT"
+	case ValueTypeFloat:
+		return "REAL"
+	case ValueTypeBytes:
+		return "BYTES"
+	default:
+		return strings.ToUpper(t.String())
+	}
+}
+
+// parseDeclaredType splits a declared type such as "DECIMAL(10,2)"
+// into its base name and precision/scale.
+func parseDeclaredType(decl string) ColumnType {
+	decl = strings.TrimSpace(decl)
+	open := strings.IndexByte(decl, '(')
+	if open < 0 || !strings.HasSuffix(decl, ")") {
+		return ColumnType{DatabaseTypeName: strings.ToUpper(decl)}
+	}
+	ct := ColumnType{DatabaseTypeName: strings.ToUpper(strings.TrimSpace(decl[:open]))}
+	args := strings.Split(decl[open+1:len(decl)-1], ",")
+	p, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+	if err != nil {
+		return ct
+	}
+	ct.Precision, ct.HasPrecisionScale = p, true
+	if len(args) > 1 {
+		ct.Scale, _ = strconv.ParseInt(strings.TrimSpace(args[1]), 10, 64)
+	}
+	return ct
+}
+
+// TableColumnTypes returns the declared column metadata for table, the
+// columns of DescribeTable.
+func (c *Client) TableColumnTypes(ctx context.Context, table string) ([]ColumnType, error) {
+	desc, err := c.DescribeTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	return desc.Columns, nil
+}
+
+type describeTableJSON struct {
+	TableName string `json:"table_name"`
+	Columns   []struct {
+		Name       string `json:"name"`
+		DataType   string `json:"data_type"`
+		Nullable   bool   `json:"nullable"`
+		PrimaryKey bool   `json:"primary_key"`
+	} `json:"columns"`
+}
+
+func decodeTableDescription(raw []byte) (*TableDescription, error) {
+	var resp describeTableJSON
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode table description: %w", err)
+	}
+	out := make([]ColumnType, len(resp.Columns))
+	for i, col := range resp.Columns {
+		ct := parseDeclaredType(col.DataType)
+		ct.Name = col.Name
+		ct.Declared = true
+		ct.Nullable, ct.NullableKnown = col.Nullable, true
+		ct.PrimaryKey = col.PrimaryKey
+		out[i] = ct
+	}
+	return &TableDescription{Name: resp.TableName, Columns: out}, nil
+}
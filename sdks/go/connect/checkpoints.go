@@ -0,0 +1,216 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/internal/sqlident"
+)
+
+// CheckpointStore persists consumer positions by name. A position is
+// the next offset to process.
+type CheckpointStore interface {
+	// Load returns the position saved under name; ok is false if none
+	// has been saved.
+	Load(ctx context.Context, name string) (pos kimberlite.Offset, ok bool, err error)
+	// Save records pos under name.
+	Save(ctx context.Context, name string, pos kimberlite.Offset) error
+}
+
+// MemoryCheckpoints is a CheckpointStore that keeps positions in memory,
+// for tests and consumers that always start from the beginning.
+type MemoryCheckpoints struct {
+	mu  sync.Mutex
+	pos map[string]kimberlite.Offset
+}
+
+// NewMemoryCheckpoints returns an empty store.
+func NewMemoryCheckpoints() *MemoryCheckpoints {
+	return &MemoryCheckpoints{pos: make(map[string]kimberlite.Offset)}
+}
+
+// Load implements CheckpointStore.
+func (m *MemoryCheckpoints) Load(_ context.Context, name string) (kimberlite.Offset, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pos, ok := m.pos[name]
+	return pos, ok, nil
+}
+
+// Save implements CheckpointStore.
+func (m *MemoryCheckpoints) Save(_ context.Context, name string, pos kimberlite.Offset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pos[name] = pos
+	return nil
+}
+
+// checkpointEventType is the envelope type of StreamCheckpoints records.
+const checkpointEventType = "kimberlite.connect.checkpoint"
+
+type checkpointRecord struct {
+	Name     string `json:"name"`
+	Position uint64 `json:"position"`
+}
+
+// StreamCheckpoints is a CheckpointStore backed by a Kimberlite stream,
+// so positions share the log's durability and audit trail. Each Save
+// appends a record; Load replays records appended since the last call.
+// It is safe for concurrent use by one process, and each stream should
+// be written by a single StreamCheckpoints.
+type StreamCheckpoints struct {
+	log    EventLog
+	stream kimberlite.StreamID
+
+	mu   sync.Mutex
+	pos  map[string]kimberlite.Offset
+	next kimberlite.Offset
+}
+
+// NewStreamCheckpoints returns a store recording positions in stream.
+func NewStreamCheckpoints(log EventLog, stream kimberlite.StreamID) *StreamCheckpoints {
+	return &StreamCheckpoints{log: log, stream: stream, pos: make(map[string]kimberlite.Offset)}
+}
+
+// Load implements CheckpointStore.
+func (s *StreamCheckpoints) Load(ctx context.Context, name string) (kimberlite.Offset, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.catchUp(ctx); err != nil {
+		return 0, false, err
+	}
+	pos, ok := s.pos[name]
+	return pos, ok, nil
+}
+
+// Save implements CheckpointStore.
+func (s *StreamCheckpoints) Save(ctx context.Context, name string, pos kimberlite.Offset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.catchUp(ctx); err != nil {
+		return err
+	}
+	body, err := json.Marshal(checkpointRecord{Name: name, Position: uint64(pos)})
+	if err != nil {
+		return err
+	}
+	data, err := kimberlite.Envelope{Type: checkpointEventType, Data: body}.Marshal()
+	if err != nil {
+		return err
+	}
+	off, err := s.log.AppendContext(ctx, s.stream, data)
+	if err != nil {
+		return err
+	}
+	s.pos[name] = pos
+	s.next = off + 1
+	return nil
+}
+
+func (s *StreamCheckpoints) catchUp(ctx context.Context) error {
+	for {
+		events, err := s.log.ReadEventsContext(ctx, s.stream, s.next, 1<<20)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		for _, ev := range events {
+			env, err := kimberlite.UnmarshalEnvelope(ev.Data)
+			if err != nil {
+				return fmt.Errorf("kimberlite: checkpoint stream %d offset %d: %w", s.stream, ev.Offset, err)
+			}
+			if env.Type != checkpointEventType {
+				continue
+			}
+			var rec checkpointRecord
+			if err := json.Unmarshal(env.Data, &rec); err != nil {
+				return fmt.Errorf("kimberlite: checkpoint stream %d offset %d: %w", s.stream, ev.Offset, err)
+			}
+			s.pos[rec.Name] = kimberlite.Offset(rec.Position)
+		}
+		s.next = events[len(events)-1].Offset + 1
+	}
+}
+
+// SQLDB runs SQL statements. *sql.DB, *sql.Conn and *sql.Tx implement
+// it; a SQLCheckpoints on a *sql.Tx saves positions in the same
+// transaction as the consumer's own writes.
+type SQLDB interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SQLCheckpoints is a CheckpointStore backed by a SQL table with a text
+// name primary key and a bigint position column, in the consumer's own
+// database or in Kimberlite through the sqldriver package. Each name
+// should be written by a single store.
+type SQLCheckpoints struct {
+	db          SQLDB
+	table       string
+	placeholder func(n int) string
+}
+
+// SQLCheckpointOption configures a SQLCheckpoints.
+type SQLCheckpointOption func(*SQLCheckpoints)
+
+// WithPlaceholder sets the parameter placeholder of the database, e.g.
+// "?" for MySQL. The default is PostgreSQL's $1, $2, ..., which
+// Kimberlite and SQLite accept too.
+func WithPlaceholder(f func(n int) string) SQLCheckpointOption {
+	return func(s *SQLCheckpoints) { s.placeholder = f }
+}
+
+// NewSQLCheckpoints returns a store recording positions in table,
+// optionally schema-qualified. It panics if table is not an unquoted
+// SQL identifier, since the name is written into every statement.
+func NewSQLCheckpoints(db SQLDB, table string, opts ...SQLCheckpointOption) *SQLCheckpoints {
+	if err := sqlident.Check("checkpoint table", table); err != nil {
+		panic("connect: " + err.Error())
+	}
+	s := &SQLCheckpoints{db: db, table: table, placeholder: func(n int) string { return "$" + strconv.Itoa(n) }}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CreateTable creates the store's table unless it exists.
+func (s *SQLCheckpoints) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+s.table+" (name TEXT NOT NULL PRIMARY KEY, position BIGINT NOT NULL)")
+	return err
+}
+
+// Load implements CheckpointStore.
+func (s *SQLCheckpoints) Load(ctx context.Context, name string) (kimberlite.Offset, bool, error) {
+	var pos int64
+	err := s.db.QueryRowContext(ctx, "SELECT position FROM "+s.table+" WHERE name = "+s.placeholder(1), name).Scan(&pos)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return kimberlite.Offset(pos), true, nil
+}
+
+// Save implements CheckpointStore. It updates the name's row, and
+// inserts it if there is none, rather than relying on an upsert syntax
+// not every database has.
+func (s *SQLCheckpoints) Save(ctx context.Context, name string, pos kimberlite.Offset) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE "+s.table+" SET position = "+s.placeholder(1)+" WHERE name = "+s.placeholder(2), int64(pos), name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "INSERT INTO "+s.table+" (name, position) VALUES ("+s.placeholder(1)+", "+s.placeholder(2)+")", name, int64(pos))
+	return err
+}
@@ -0,0 +1,70 @@
+package connect
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/internal/sqltest"
+)
+
+// checkpointTable answers the statements of SQLCheckpoints, holding
+// the position of each name.
+type checkpointTable map[string]int64
+
+func (tb checkpointTable) Exec(query string, args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS checkpoints "):
+		return driver.RowsAffected(0), nil
+	case query == "UPDATE checkpoints SET position = $1 WHERE name = $2":
+		name := args[1].(string)
+		if _, ok := tb[name]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+		tb[name] = args[0].(int64)
+		return driver.RowsAffected(1), nil
+	case query == "INSERT INTO checkpoints (name, position) VALUES ($1, $2)":
+		tb[args[0].(string)] = args[1].(int64)
+		return driver.RowsAffected(1), nil
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (tb checkpointTable) Query(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+	if query != "SELECT position FROM checkpoints WHERE name = $1" {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	pos, ok := tb[args[0].(string)]
+	if !ok {
+		return []string{"position"}, nil, nil
+	}
+	return []string{"position"}, [][]driver.Value{{pos}}, nil
+}
+
+func TestSQLCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	rows := checkpointTable{}
+	db := sqltest.Open(t, rows)
+
+	store := NewSQLCheckpoints(db, "checkpoints")
+	if err := store.CreateTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := store.Load(ctx, "sink/1"); ok || err != nil {
+		t.Fatalf("load of unsaved name: %v, %v", ok, err)
+	}
+	for _, pos := range []kimberlite.Offset{5, 9} {
+		if err := store.Save(ctx, "sink/1", pos); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pos, ok, err := store.Load(ctx, "sink/1"); pos != 9 || !ok || err != nil {
+		t.Fatalf("load = %d, %v, %v", pos, ok, err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("%d rows, expected 1", len(rows))
+	}
+}
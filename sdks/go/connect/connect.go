@@ -0,0 +1,106 @@
+// Package connect bridges Kimberlite streams and message brokers such
+// as Kafka.
+//
+// A Sink tails Kimberlite streams and publishes their events to a
+// Producer with at-least-once delivery: a stream's checkpoint advances
+// only after the producer has acknowledged every message read before
+// it, so a restarted sink resumes where it left off and may redeliver,
+// but never skip, events. Sinks tail an explicit list of streams: the
+// read protocol has no tenant-wide ($all) feed.
+//
+// The package has no broker dependency. Producers and Consumers adapt
+// a concrete client; the kafkago module provides them for
+// github.com/segmentio/kafka-go:
+//
+//	sink := connect.NewSink(client, kafkago.NewProducer(writer),
+//	    []kimberlite.StreamID{admissions, discharges},
+//	    connect.WithCheckpoints(store, "ehr-to-kafka"),
+//	)
+//	err := sink.Run(ctx)
+//
+// A Source does the reverse, appending consumed messages to Kimberlite
+// streams exactly once by checkpointing partition offsets, typically in
+// a stream of their own with StreamCheckpoints.
+//
+// Any consumer position, including those of Sinks and projection
+// Runners, is kept in a CheckpointStore: MemoryCheckpoints,
+// StreamCheckpoints, or SQLCheckpoints, a table in the consumer's own
+// database or in Kimberlite, so consumers resume after restarts.
+package connect
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// AnyPartition lets the producer choose a message's partition, usually
+// by hashing its key.
+const AnyPartition = -1
+
+// Header is a message header.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a record exchanged with a message broker.
+type Message struct {
+	Topic string
+	// Partition is the target partition, or AnyPartition.
+	Partition int
+	// Offset is the broker offset of a consumed message.
+	Offset  int64
+	Key     []byte
+	Value   []byte
+	Headers []Header
+	// Time is the message timestamp. Sinks leave it zero for the
+	// producer to set, since the log does not record when events were
+	// appended.
+	Time time.Time
+}
+
+// Header returns the value of the first header named key.
+func (m Message) Header(key string) ([]byte, bool) {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return h.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Producer publishes messages to a broker. Produce must return nil only
+// once the broker has acknowledged every message.
+type Producer interface {
+	Produce(ctx context.Context, msgs []Message) error
+}
+
+// EventAppender appends events to Kimberlite streams.
+// *kimberlite.Client implements it.
+type EventAppender interface {
+	AppendContext(ctx context.Context, streamID kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error)
+}
+
+// EventLog reads and appends events. *kimberlite.Client implements it.
+type EventLog interface {
+	EventSource
+	EventAppender
+}
+
+// EventSource reads events from Kimberlite streams. *kimberlite.Client
+// implements it.
+type EventSource interface {
+	ReadEventsContext(ctx context.Context, streamID kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
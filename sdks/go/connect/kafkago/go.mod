@@ -0,0 +1,15 @@
+module github.com/kimberlitedb/kimberlite-go/connect/kafkago
+
+go 1.21
+
+require (
+	github.com/kimberlitedb/kimberlite-go v0.5.0
+	github.com/segmentio/kafka-go v0.4.47
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+)
+
+replace github.com/kimberlitedb/kimberlite-go => ../../
@@ -0,0 +1,147 @@
+// Package kafkago adapts github.com/segmentio/kafka-go to the
+// connect package:
+//
+//	writer := &kafka.Writer{Addr: kafka.TCP("broker:9092"), RequiredAcks: kafka.RequireAll}
+//	sink := connect.NewSink(client, kafkago.NewProducer(writer), streams)
+//
+//	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, GroupID: "kimberlite", Topic: "admissions"})
+//	source := connect.NewSource(kafkago.NewConsumer(reader), client, connect.ToStream(admissions),
+//	    connect.NewStreamCheckpoints(client, checkpoints))
+//
+// connect speaks to brokers only through its Producer and Consumer
+// interfaces, so kafka-go is required by this module alone; adapters
+// for other Kafka clients can be written the same way.
+package kafkago
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// Producer implements connect.Producer with a *kafka.Writer.
+type Producer struct {
+	w *kafka.Writer
+}
+
+var _ connect.Producer = (*Producer)(nil)
+
+// NewProducer returns a Producer writing with w. For at-least-once
+// delivery w must be synchronous (Async false) and should set
+// RequiredAcks to kafka.RequireAll.
+//
+// kafka-go chooses partitions with w.Balancer and ignores the partition
+// set on a message, so NewProducer wraps w.Balancer (kafka.Hash if nil)
+// in one that honours connect.Message.Partition when it is set.
+func NewProducer(w *kafka.Writer) *Producer {
+	fallback := w.Balancer
+	if fallback == nil {
+		fallback = &kafka.Hash{}
+	}
+	w.Balancer = partitionBalancer{fallback: fallback}
+	return &Producer{w: w}
+}
+
+// Produce implements connect.Producer.
+func (p *Producer) Produce(ctx context.Context, msgs []connect.Message) error {
+	out := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = toKafka(m)
+		if p.w.Topic != "" {
+			// kafka-go rejects messages naming a topic when the
+			// writer has one.
+			out[i].Topic = ""
+		}
+	}
+	return p.w.WriteMessages(ctx, out...)
+}
+
+func toKafka(m connect.Message) kafka.Message {
+	km := kafka.Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Key:       m.Key,
+		Value:     m.Value,
+		Time:      m.Time,
+	}
+	if len(m.Headers) > 0 {
+		km.Headers = make([]kafka.Header, len(m.Headers))
+		for i, h := range m.Headers {
+			km.Headers[i] = kafka.Header{Key: h.Key, Value: h.Value}
+		}
+	}
+	return km
+}
+
+// partitionBalancer routes messages with an explicit partition there
+// and defers to fallback for the rest.
+type partitionBalancer struct {
+	fallback kafka.Balancer
+}
+
+func (b partitionBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	if msg.Partition != connect.AnyPartition {
+		for _, p := range partitions {
+			if p == msg.Partition {
+				return p
+			}
+		}
+	}
+	return b.fallback.Balance(msg, partitions...)
+}
+
+// Consumer implements connect.Consumer with a *kafka.Reader.
+type Consumer struct {
+	r *kafka.Reader
+}
+
+var _ connect.Consumer = (*Consumer)(nil)
+
+// NewConsumer returns a Consumer reading with r. Offsets are committed
+// to the consumer group once messages are appended; a reader without a
+// GroupID commits nothing and relies on the Source's checkpoints alone
+// to skip redelivered messages.
+func NewConsumer(r *kafka.Reader) *Consumer {
+	return &Consumer{r: r}
+}
+
+// Fetch implements connect.Consumer.
+func (c *Consumer) Fetch(ctx context.Context) (connect.Message, error) {
+	km, err := c.r.FetchMessage(ctx)
+	if err != nil {
+		return connect.Message{}, err
+	}
+	return fromKafka(km), nil
+}
+
+// Commit implements connect.Consumer.
+func (c *Consumer) Commit(ctx context.Context, msgs ...connect.Message) error {
+	if c.r.Config().GroupID == "" || len(msgs) == 0 {
+		return nil
+	}
+	out := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}
+	}
+	return c.r.CommitMessages(ctx, out...)
+}
+
+func fromKafka(km kafka.Message) connect.Message {
+	m := connect.Message{
+		Topic:     km.Topic,
+		Partition: km.Partition,
+		Offset:    km.Offset,
+		Key:       km.Key,
+		Value:     km.Value,
+		Time:      km.Time,
+	}
+	if len(km.Headers) > 0 {
+		m.Headers = make([]connect.Header, len(km.Headers))
+		for i, h := range km.Headers {
+			m.Headers[i] = connect.Header{Key: h.Key, Value: h.Value}
+		}
+	}
+	return m
+}
@@ -0,0 +1,50 @@
+package kafkago
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+func TestToKafka(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	km := toKafka(connect.Message{
+		Topic:     "t",
+		Partition: 2,
+		Key:       []byte("k"),
+		Value:     []byte("v"),
+		Headers:   []connect.Header{{Key: connect.HeaderStream, Value: []byte("7")}},
+		Time:      at,
+	})
+	if km.Topic != "t" || km.Partition != 2 || string(km.Key) != "k" || string(km.Value) != "v" || !km.Time.Equal(at) {
+		t.Fatalf("message = %+v", km)
+	}
+	if len(km.Headers) != 1 || km.Headers[0].Key != connect.HeaderStream || string(km.Headers[0].Value) != "7" {
+		t.Fatalf("headers = %+v", km.Headers)
+	}
+
+	km.Offset = 42
+	m := fromKafka(km)
+	if m.Topic != "t" || m.Partition != 2 || m.Offset != 42 || string(m.Key) != "k" || string(m.Value) != "v" {
+		t.Fatalf("fromKafka = %+v", m)
+	}
+	if v, ok := m.Header(connect.HeaderStream); !ok || string(v) != "7" {
+		t.Fatalf("fromKafka headers = %+v", m.Headers)
+	}
+}
+
+func TestPartitionBalancer(t *testing.T) {
+	w := &kafka.Writer{}
+	NewProducer(w)
+	b := w.Balancer
+	if got := b.Balance(kafka.Message{Partition: 2, Key: []byte("k")}, 0, 1, 2); got != 2 {
+		t.Fatalf("explicit partition = %d, want 2", got)
+	}
+	hashed := (&kafka.Hash{}).Balance(kafka.Message{Key: []byte("k")}, 0, 1, 2)
+	if got := b.Balance(kafka.Message{Partition: connect.AnyPartition, Key: []byte("k")}, 0, 1, 2); got != hashed {
+		t.Fatalf("AnyPartition = %d, want hashed %d", got, hashed)
+	}
+}
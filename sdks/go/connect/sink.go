@@ -0,0 +1,227 @@
+package connect
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Headers set on every message a Sink publishes. Envelope metadata is
+// copied as further headers under its own keys, so trace context
+// (traceparent) and content_type pass through unchanged.
+const (
+	HeaderStream        = "kimberlite-stream"
+	HeaderOffset        = "kimberlite-offset"
+	HeaderEventID       = "kimberlite-id"
+	HeaderEventType     = "kimberlite-type"
+	HeaderCorrelationID = "kimberlite-correlation-id"
+	HeaderCausationID   = "kimberlite-causation-id"
+)
+
+// TopicFunc maps an event to the topic it is published to.
+type TopicFunc func(ev kimberlite.Event, env kimberlite.Envelope) string
+
+// KeyFunc maps an event to its message key.
+type KeyFunc func(ev kimberlite.Event, env kimberlite.Envelope) []byte
+
+// PartitionFunc maps an event to a partition, or AnyPartition.
+type PartitionFunc func(ev kimberlite.Event, env kimberlite.Envelope) int
+
+// StaticTopic publishes every event to topic.
+func StaticTopic(topic string) TopicFunc {
+	return func(kimberlite.Event, kimberlite.Envelope) string { return topic }
+}
+
+// Sink tails Kimberlite streams and publishes their events to a
+// Producer. It is not safe for concurrent use; run one Sink per
+// checkpoint name.
+type Sink struct {
+	source    EventSource
+	producer  Producer
+	streams   []kimberlite.StreamID
+	store     CheckpointStore
+	name      string
+	topic     TopicFunc
+	key       KeyFunc
+	partition PartitionFunc
+	maxBytes  uint64
+	interval  time.Duration
+
+	pos map[kimberlite.StreamID]kimberlite.Offset
+}
+
+// SinkOption configures a Sink.
+type SinkOption func(*Sink)
+
+// WithCheckpoints stores the sink's per-stream positions in store under
+// name, suffixed with "/<stream id>". Without it, positions are kept in
+// memory and a new Sink starts from the beginning of each stream.
+func WithCheckpoints(store CheckpointStore, name string) SinkOption {
+	return func(s *Sink) {
+		s.store, s.name = store, name
+	}
+}
+
+// WithTopic sets the topic mapping. The default publishes stream N to
+// "kimberlite.stream.N".
+func WithTopic(f TopicFunc) SinkOption {
+	return func(s *Sink) { s.topic = f }
+}
+
+// WithKey sets the key mapping. The default keys messages by stream ID,
+// so each stream's events stay ordered within one partition.
+func WithKey(f KeyFunc) SinkOption {
+	return func(s *Sink) { s.key = f }
+}
+
+// WithPartition sets the partition mapping. By default the producer
+// chooses (AnyPartition).
+func WithPartition(f PartitionFunc) SinkOption {
+	return func(s *Sink) { s.partition = f }
+}
+
+// WithBatchBytes sets the maxBytes of each read, and so the size of
+// each produced batch. The default is 1 MiB.
+func WithBatchBytes(n uint64) SinkOption {
+	return func(s *Sink) { s.maxBytes = n }
+}
+
+// WithPollInterval sets how long Run waits after catching up before
+// reading again. The default is one second.
+func WithPollInterval(d time.Duration) SinkOption {
+	return func(s *Sink) { s.interval = d }
+}
+
+// NewSink returns a Sink publishing the events of streams to producer.
+func NewSink(source EventSource, producer Producer, streams []kimberlite.StreamID, opts ...SinkOption) *Sink {
+	s := &Sink{
+		source:   source,
+		producer: producer,
+		streams:  append([]kimberlite.StreamID(nil), streams...),
+		name:     "sink",
+		topic: func(ev kimberlite.Event, _ kimberlite.Envelope) string {
+			return "kimberlite.stream." + strconv.FormatUint(uint64(ev.StreamID), 10)
+		},
+		key: func(ev kimberlite.Event, _ kimberlite.Envelope) []byte {
+			return strconv.AppendUint(nil, uint64(ev.StreamID), 10)
+		},
+		partition: func(kimberlite.Event, kimberlite.Envelope) int { return AnyPartition },
+		maxBytes:  1 << 20,
+		interval:  time.Second,
+		pos:       make(map[kimberlite.StreamID]kimberlite.Offset),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.store == nil {
+		s.store = NewMemoryCheckpoints()
+	}
+	return s
+}
+
+// Run publishes events until ctx is done or an error occurs, polling
+// each stream for new events once it has caught up. After an error the
+// sink can be run again; it resumes from its last checkpoint.
+func (s *Sink) Run(ctx context.Context) error {
+	for {
+		if _, err := s.Poll(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Poll publishes every event not yet published, advancing each stream's
+// checkpoint after each acknowledged batch, and returns the number of
+// messages produced.
+func (s *Sink) Poll(ctx context.Context) (int, error) {
+	var total int
+	for _, id := range s.streams {
+		n, err := s.drain(ctx, id)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (s *Sink) checkpointName(id kimberlite.StreamID) string {
+	return s.name + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (s *Sink) drain(ctx context.Context, id kimberlite.StreamID) (int, error) {
+	from, ok := s.pos[id]
+	if !ok {
+		var err error
+		if from, _, err = s.store.Load(ctx, s.checkpointName(id)); err != nil {
+			return 0, fmt.Errorf("kimberlite: load checkpoint for stream %d: %w", id, err)
+		}
+		s.pos[id] = from
+	}
+
+	var total int
+	for {
+		events, err := s.source.ReadEventsContext(ctx, id, from, s.maxBytes)
+		if err != nil {
+			return total, err
+		}
+		if len(events) == 0 {
+			return total, nil
+		}
+		msgs := make([]Message, len(events))
+		for i, ev := range events {
+			if msgs[i], err = s.message(ev); err != nil {
+				return total, err
+			}
+		}
+		if err := s.producer.Produce(ctx, msgs); err != nil {
+			return total, fmt.Errorf("kimberlite: produce stream %d from offset %d: %w", id, from, err)
+		}
+		total += len(msgs)
+
+		from = events[len(events)-1].Offset + 1
+		if err := s.store.Save(ctx, s.checkpointName(id), from); err != nil {
+			return total, fmt.Errorf("kimberlite: save checkpoint for stream %d: %w", id, err)
+		}
+		s.pos[id] = from
+	}
+}
+
+func (s *Sink) message(ev kimberlite.Event) (Message, error) {
+	env, err := kimberlite.UnmarshalEnvelope(ev.Data)
+	if err != nil {
+		return Message{}, fmt.Errorf("kimberlite: stream %d offset %d: %w", ev.StreamID, ev.Offset, err)
+	}
+	headers := []Header{
+		{Key: HeaderStream, Value: strconv.AppendUint(nil, uint64(ev.StreamID), 10)},
+		{Key: HeaderOffset, Value: strconv.AppendUint(nil, uint64(ev.Offset), 10)},
+	}
+	for _, h := range []struct{ key, value string }{
+		{HeaderEventID, env.ID},
+		{HeaderEventType, env.Type},
+		{HeaderCorrelationID, env.CorrelationID},
+		{HeaderCausationID, env.CausationID},
+	} {
+		if h.value != "" {
+			headers = append(headers, Header{Key: h.key, Value: []byte(h.value)})
+		}
+	}
+	for _, k := range sortedKeys(env.Metadata) {
+		headers = append(headers, Header{Key: k, Value: []byte(env.Metadata[k])})
+	}
+	return Message{
+		Topic:     s.topic(ev, env),
+		Partition: s.partition(ev, env),
+		Key:       s.key(ev, env),
+		Value:     env.Data,
+		Headers:   headers,
+	}, nil
+}
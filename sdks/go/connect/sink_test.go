@@ -0,0 +1,125 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// memLog is an in-memory EventSource and appender.
+type memLog struct {
+	streams map[kimberlite.StreamID][][]byte
+}
+
+func newMemLog() *memLog {
+	return &memLog{streams: make(map[kimberlite.StreamID][][]byte)}
+}
+
+func (l *memLog) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error) {
+	var (
+		out  []kimberlite.Event
+		size uint64
+	)
+	data := l.streams[id]
+	for i := int(from); i < len(data); i++ {
+		if len(out) > 0 && size+uint64(len(data[i])) > maxBytes {
+			break
+		}
+		size += uint64(len(data[i]))
+		out = append(out, kimberlite.Event{StreamID: id, Offset: kimberlite.Offset(i), Data: data[i]})
+	}
+	return out, nil
+}
+
+func (l *memLog) AppendContext(_ context.Context, id kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error) {
+	first := kimberlite.Offset(len(l.streams[id]))
+	l.streams[id] = append(l.streams[id], events...)
+	return first, nil
+}
+
+type recordingProducer struct {
+	msgs []Message
+	fail int // fail the next n calls
+}
+
+var errBroker = errors.New("broker unavailable")
+
+func (p *recordingProducer) Produce(_ context.Context, msgs []Message) error {
+	if p.fail > 0 {
+		p.fail--
+		return errBroker
+	}
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func TestSinkAtLeastOnce(t *testing.T) {
+	ctx := context.Background()
+	log := newMemLog()
+	env, err := kimberlite.Envelope{ID: "e1", Type: "Admitted", Metadata: map[string]string{"traceparent": "tp"}, Data: []byte(`{"id":1}`)}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.AppendContext(ctx, 7, env, []byte("raw-1"), []byte("raw-2"))
+
+	store := NewMemoryCheckpoints()
+	p := &recordingProducer{fail: 1}
+	sink := NewSink(log, p, []kimberlite.StreamID{7}, WithCheckpoints(store, "test"), WithBatchBytes(8))
+
+	if _, err := sink.Poll(ctx); !errors.Is(err, errBroker) {
+		t.Fatalf("Poll err = %v, want broker error", err)
+	}
+	if pos, ok, _ := store.Load(ctx, "test/7"); ok {
+		t.Fatalf("checkpoint advanced to %d despite failed produce", pos)
+	}
+
+	// A fresh sink resumes from the store.
+	sink = NewSink(log, p, []kimberlite.StreamID{7}, WithCheckpoints(store, "test"), WithBatchBytes(8))
+	n, err := sink.Poll(ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("Poll = %d, %v", n, err)
+	}
+	if pos, _, _ := store.Load(ctx, "test/7"); pos != 3 {
+		t.Fatalf("checkpoint = %d, want 3", pos)
+	}
+
+	m := p.msgs[0]
+	if m.Topic != "kimberlite.stream.7" || string(m.Key) != "7" || m.Partition != AnyPartition || string(m.Value) != `{"id":1}` {
+		t.Fatalf("message = %+v", m)
+	}
+	for k, want := range map[string]string{HeaderEventID: "e1", HeaderEventType: "Admitted", HeaderOffset: "0", "traceparent": "tp"} {
+		if v, _ := m.Header(k); string(v) != want {
+			t.Errorf("header %s = %q, want %q", k, v, want)
+		}
+	}
+
+	log.AppendContext(ctx, 7, []byte("raw-3"))
+	if n, err := sink.Poll(ctx); err != nil || n != 1 || string(p.msgs[3].Value) != "raw-3" {
+		t.Fatalf("Poll after append = %d, %v", n, err)
+	}
+}
+
+func TestSinkTransform(t *testing.T) {
+	ctx := context.Background()
+	log := newMemLog()
+	log.AppendContext(ctx, 7, []byte("a"), []byte("b"))
+
+	upper := func(ev kimberlite.Event) (kimberlite.Event, error) {
+		if string(ev.Data) == "b" {
+			return kimberlite.Event{}, errBroker
+		}
+		ev.Data = []byte(strings.ToUpper(string(ev.Data)))
+		return ev, nil
+	}
+	p := &recordingProducer{}
+	sink := NewSink(Transform(log, upper), p, []kimberlite.StreamID{7}, WithBatchBytes(1))
+	if _, err := sink.Poll(ctx); !errors.Is(err, errBroker) {
+		t.Fatalf("Poll err = %v, want transform error", err)
+	}
+	if len(p.msgs) != 1 || string(p.msgs[0].Value) != "A" {
+		t.Fatalf("messages = %+v", p.msgs)
+	}
+}
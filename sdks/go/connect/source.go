@@ -0,0 +1,275 @@
+package connect
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Metadata keys set on every envelope a Source appends.
+const (
+	MetaKafkaSource    = "kafka.source"
+	MetaKafkaTopic     = "kafka.topic"
+	MetaKafkaPartition = "kafka.partition"
+	MetaKafkaOffset    = "kafka.offset"
+	// MetaKafkaKey holds a UTF-8 message key; other keys are stored
+	// base64-encoded under MetaKafkaKeyBase64.
+	MetaKafkaKey       = "kafka.key"
+	MetaKafkaKeyBase64 = "kafka.key_base64"
+)
+
+// Consumer fetches messages from a broker.
+type Consumer interface {
+	// Fetch blocks until the next message is available.
+	Fetch(ctx context.Context) (Message, error)
+	// Commit acknowledges msgs to the broker. A Source calls it after
+	// the messages are durably appended.
+	Commit(ctx context.Context, msgs ...Message) error
+}
+
+// StreamFunc routes a consumed message to the stream it is appended to.
+// It must be deterministic: a redelivered message has to route to the
+// stream it was first appended to for deduplication to see it.
+type StreamFunc func(m Message) (kimberlite.StreamID, error)
+
+// ToStream routes every message to id.
+func ToStream(id kimberlite.StreamID) StreamFunc {
+	return func(Message) (kimberlite.StreamID, error) { return id, nil }
+}
+
+// Source consumes broker messages and appends them to Kimberlite
+// streams exactly once.
+//
+// Each topic partition's next offset is checkpointed in a
+// CheckpointStore, typically a StreamCheckpoints, after the message is
+// appended; messages below the checkpoint are skipped. A crash between
+// the append and the checkpoint would otherwise duplicate the message
+// on redelivery, so before first appending to a stream the Source
+// scans the events appended to it since its last checkpointed append,
+// and advances partition checkpoints past the messages it finds there.
+//
+// Messages become envelopes whose payload is the message value, with
+// the source name, topic, partition, offset and key recorded in
+// metadata. Headers are copied into metadata when their values are
+// valid UTF-8; the kimberlite-type, kimberlite-correlation-id and
+// kimberlite-causation-id headers written by Sink set the envelope's
+// fields instead, so events round-trip.
+//
+// A Source is not safe for concurrent use.
+type Source struct {
+	consumer Consumer
+	log      EventLog
+	route    StreamFunc
+	store    CheckpointStore
+	name     string
+	maxBytes uint64
+
+	pos     map[string]kimberlite.Offset
+	scanned map[kimberlite.StreamID]bool
+}
+
+// SourceOption configures a Source.
+type SourceOption func(*Source)
+
+// WithSourceName sets the name identifying the source in checkpoints
+// and envelope metadata. Sources sharing a checkpoint store need
+// distinct names. The default is "source".
+func WithSourceName(name string) SourceOption {
+	return func(s *Source) { s.name = name }
+}
+
+// NewSource returns a Source appending messages from consumer to the
+// streams chosen by route, checkpointing in store.
+func NewSource(consumer Consumer, log EventLog, route StreamFunc, store CheckpointStore, opts ...SourceOption) *Source {
+	s := &Source{
+		consumer: consumer,
+		log:      log,
+		route:    route,
+		store:    store,
+		name:     "source",
+		maxBytes: 1 << 20,
+		pos:      make(map[string]kimberlite.Offset),
+		scanned:  make(map[kimberlite.StreamID]bool),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run consumes messages until ctx is done or an error occurs. After an
+// error the source can be run again without duplicating messages.
+func (s *Source) Run(ctx context.Context) error {
+	for {
+		m, err := s.consumer.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.Handle(ctx, m); err != nil {
+			return err
+		}
+	}
+}
+
+// Handle appends m unless it was already appended, then commits it to
+// the consumer.
+func (s *Source) Handle(ctx context.Context, m Message) error {
+	if m.Offset < 0 {
+		return fmt.Errorf("kimberlite: message %s/%d has no offset", m.Topic, m.Partition)
+	}
+	id, err := s.route(m)
+	if err != nil {
+		return fmt.Errorf("kimberlite: route %s/%d@%d: %w", m.Topic, m.Partition, m.Offset, err)
+	}
+	if err := s.recover(ctx, id); err != nil {
+		return err
+	}
+
+	name := s.partitionName(m.Topic, m.Partition)
+	next, err := s.position(ctx, name)
+	if err != nil {
+		return err
+	}
+	if kimberlite.Offset(m.Offset) >= next {
+		data, err := s.envelope(m).Marshal()
+		if err != nil {
+			return err
+		}
+		off, err := s.log.AppendContext(ctx, id, data)
+		if err != nil {
+			return fmt.Errorf("kimberlite: append %s/%d@%d to stream %d: %w", m.Topic, m.Partition, m.Offset, id, err)
+		}
+		if err := s.save(ctx, name, kimberlite.Offset(m.Offset+1)); err != nil {
+			return err
+		}
+		if err := s.save(ctx, s.streamName(id), off+1); err != nil {
+			return err
+		}
+	}
+	return s.consumer.Commit(ctx, m)
+}
+
+func (s *Source) partitionName(topic string, partition int) string {
+	return s.name + "/" + topic + "/" + strconv.Itoa(partition)
+}
+
+func (s *Source) streamName(id kimberlite.StreamID) string {
+	return s.name + "/stream/" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (s *Source) position(ctx context.Context, name string) (kimberlite.Offset, error) {
+	if pos, ok := s.pos[name]; ok {
+		return pos, nil
+	}
+	pos, _, err := s.store.Load(ctx, name)
+	if err != nil {
+		return 0, fmt.Errorf("kimberlite: load checkpoint %s: %w", name, err)
+	}
+	s.pos[name] = pos
+	return pos, nil
+}
+
+func (s *Source) save(ctx context.Context, name string, pos kimberlite.Offset) error {
+	if err := s.store.Save(ctx, name, pos); err != nil {
+		return fmt.Errorf("kimberlite: save checkpoint %s: %w", name, err)
+	}
+	s.pos[name] = pos
+	return nil
+}
+
+// recover advances partition checkpoints past messages appended to
+// stream id after its last checkpointed append.
+func (s *Source) recover(ctx context.Context, id kimberlite.StreamID) error {
+	if s.scanned[id] {
+		return nil
+	}
+	from, err := s.position(ctx, s.streamName(id))
+	if err != nil {
+		return err
+	}
+	found := make(map[string]kimberlite.Offset)
+	for {
+		events, err := s.log.ReadEventsContext(ctx, id, from, s.maxBytes)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, ev := range events {
+			env, err := kimberlite.UnmarshalEnvelope(ev.Data)
+			if err != nil || env.Metadata[MetaKafkaSource] != s.name {
+				continue
+			}
+			partition, err1 := strconv.Atoi(env.Metadata[MetaKafkaPartition])
+			offset, err2 := strconv.ParseUint(env.Metadata[MetaKafkaOffset], 10, 64)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			name := s.partitionName(env.Metadata[MetaKafkaTopic], partition)
+			if next := kimberlite.Offset(offset + 1); next > found[name] {
+				found[name] = next
+			}
+		}
+		from = events[len(events)-1].Offset + 1
+	}
+	for _, name := range sortedKeys(found) {
+		cur, err := s.position(ctx, name)
+		if err != nil {
+			return err
+		}
+		if found[name] > cur {
+			if err := s.save(ctx, name, found[name]); err != nil {
+				return err
+			}
+		}
+	}
+	if cur, _ := s.position(ctx, s.streamName(id)); from > cur {
+		if err := s.save(ctx, s.streamName(id), from); err != nil {
+			return err
+		}
+	}
+	s.scanned[id] = true
+	return nil
+}
+
+func (s *Source) envelope(m Message) kimberlite.Envelope {
+	env := kimberlite.Envelope{
+		ID: "kafka:" + m.Topic + "/" + strconv.Itoa(m.Partition) + "/" + strconv.FormatInt(m.Offset, 10),
+		Metadata: map[string]string{
+			MetaKafkaSource:    s.name,
+			MetaKafkaTopic:     m.Topic,
+			MetaKafkaPartition: strconv.Itoa(m.Partition),
+			MetaKafkaOffset:    strconv.FormatInt(m.Offset, 10),
+		},
+		Data: m.Value,
+	}
+	if len(m.Key) > 0 {
+		if utf8.Valid(m.Key) {
+			env.Metadata[MetaKafkaKey] = string(m.Key)
+		} else {
+			env.Metadata[MetaKafkaKeyBase64] = base64.StdEncoding.EncodeToString(m.Key)
+		}
+	}
+	for _, h := range m.Headers {
+		if !utf8.Valid(h.Value) {
+			continue
+		}
+		switch v := string(h.Value); h.Key {
+		case HeaderEventType:
+			env.Type = v
+		case HeaderCorrelationID:
+			env.CorrelationID = v
+		case HeaderCausationID:
+			env.CausationID = v
+		case MetaKafkaSource, MetaKafkaTopic, MetaKafkaPartition, MetaKafkaOffset, MetaKafkaKey, MetaKafkaKeyBase64:
+		default:
+			env.Metadata[h.Key] = v
+		}
+	}
+	return env
+}
@@ -0,0 +1,99 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+type sliceConsumer struct {
+	msgs      []Message
+	committed []int64
+}
+
+func (c *sliceConsumer) Fetch(ctx context.Context) (Message, error) {
+	if len(c.msgs) == 0 {
+		return Message{}, context.Canceled
+	}
+	m := c.msgs[0]
+	c.msgs = c.msgs[1:]
+	return m, nil
+}
+
+func (c *sliceConsumer) Commit(_ context.Context, msgs ...Message) error {
+	for _, m := range msgs {
+		c.committed = append(c.committed, m.Offset)
+	}
+	return nil
+}
+
+// crashingStore fails the first Save after failAfter successful ones.
+type crashingStore struct {
+	CheckpointStore
+	failAfter int
+}
+
+var errCrash = errors.New("crash")
+
+func (s *crashingStore) Save(ctx context.Context, name string, pos kimberlite.Offset) error {
+	if s.failAfter == 0 {
+		s.failAfter = -1
+		return errCrash
+	}
+	s.failAfter--
+	return s.CheckpointStore.Save(ctx, name, pos)
+}
+
+func TestSourceExactlyOnce(t *testing.T) {
+	ctx := context.Background()
+	const (
+		target      kimberlite.StreamID = 1
+		checkpoints kimberlite.StreamID = 2
+	)
+	log := newMemLog()
+	msgs := []Message{
+		{Topic: "admissions", Partition: 0, Offset: 10, Key: []byte("p1"), Value: []byte(`{"n":1}`),
+			Headers: []Header{{Key: HeaderEventType, Value: []byte("Admitted")}, {Key: "traceparent", Value: []byte("tp")}}},
+		{Topic: "admissions", Partition: 0, Offset: 11, Value: []byte(`{"n":2}`)},
+	}
+
+	// The first message is appended, then the process "crashes" before
+	// its partition checkpoint is saved.
+	store := &crashingStore{CheckpointStore: NewStreamCheckpoints(log, checkpoints), failAfter: 0}
+	src := NewSource(&sliceConsumer{msgs: msgs[:1]}, log, ToStream(target), store, WithSourceName("kafka"))
+	if err := src.Run(ctx); !errors.Is(err, errCrash) {
+		t.Fatalf("Run err = %v, want crash", err)
+	}
+	if n := len(log.streams[target]); n != 1 {
+		t.Fatalf("%d events appended, want 1", n)
+	}
+
+	// After restart both messages are redelivered; only the second is new.
+	consumer := &sliceConsumer{msgs: msgs}
+	src = NewSource(consumer, log, ToStream(target), NewStreamCheckpoints(log, checkpoints), WithSourceName("kafka"))
+	if err := src.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	if n := len(log.streams[target]); n != 2 {
+		t.Fatalf("%d events appended, want 2", n)
+	}
+	if len(consumer.committed) != 2 {
+		t.Fatalf("committed %v", consumer.committed)
+	}
+
+	env, err := kimberlite.UnmarshalEnvelope(log.streams[target][0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.ID != "kafka:admissions/0/10" || env.Type != "Admitted" || string(env.Data) != `{"n":1}` ||
+		env.Metadata[MetaKafkaKey] != "p1" || env.Metadata["traceparent"] != "tp" {
+		t.Fatalf("envelope = %+v", env)
+	}
+
+	pos, ok, err := NewStreamCheckpoints(log, checkpoints).Load(ctx, "kafka/admissions/0")
+	if err != nil || !ok || pos != 12 {
+		t.Fatalf("checkpoint = %d, %v, %v", pos, ok, err)
+	}
+}
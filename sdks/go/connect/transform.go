@@ -0,0 +1,37 @@
+package connect
+
+import (
+	"context"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// TransformFunc rewrites an event read from a stream, such as
+// kimberlite.Deidentifier.DeidentifyEvent.
+type TransformFunc func(ev kimberlite.Event) (kimberlite.Event, error)
+
+type transformSource struct {
+	source EventSource
+	fn     TransformFunc
+}
+
+// Transform returns an EventSource reading from source and passing
+// every event through fn, so sinks and exporters built on it only ever
+// see transformed events. An error from fn fails the read.
+func Transform(source EventSource, fn TransformFunc) EventSource {
+	return transformSource{source: source, fn: fn}
+}
+
+func (t transformSource) ReadEventsContext(ctx context.Context, streamID kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error) {
+	events, err := t.source.ReadEventsContext(ctx, streamID, from, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]kimberlite.Event, len(events))
+	for i, ev := range events {
+		if out[i], err = t.fn(ev); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
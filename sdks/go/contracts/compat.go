@@ -0,0 +1,375 @@
+package contracts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// ErrBreakingChange is returned, wrapped in a *BreakingChangeError, when
+// contracts are not compatible with their baseline.
+var ErrBreakingChange = errors.New("kimberlite: breaking contract change")
+
+// Change is a contract change that breaks consumers.
+type Change struct {
+	EventType string
+	// Path is a JSON Pointer into the baseline schema ("" for the root).
+	Path   string
+	Reason string
+}
+
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s %s: %s", c.EventType, path, c.Reason)
+}
+
+// BreakingChangeError lists the breaking changes CompatibleWith found.
+type BreakingChangeError struct {
+	Changes []Change
+}
+
+func (e *BreakingChangeError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "kimberlite: %d breaking contract changes:", len(e.Changes))
+	for _, c := range e.Changes {
+		b.WriteString("\n\t")
+		b.WriteString(c.String())
+	}
+	return b.String()
+}
+
+// Unwrap makes errors.Is(err, ErrBreakingChange) hold.
+func (e *BreakingChangeError) Unwrap() error { return ErrBreakingChange }
+
+// CompatibleWith checks that every JSON Schema contract of baseline is
+// kept by c, with a schema accepting no payload the baseline rejects.
+// It returns a *BreakingChangeError listing the changes that break
+// consumers, among them contracts c dropped. Types new in c are
+// compatible.
+func (c *Contracts) CompatibleWith(baseline *Contracts) error {
+	baseline.mu.RLock()
+	defer baseline.mu.RUnlock()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	types := make([]string, 0, len(baseline.schemas))
+	for t := range baseline.schemas {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	var changes []Change
+	for _, t := range types {
+		s, ok := c.schemas[t]
+		if !ok {
+			changes = append(changes, Change{EventType: t, Reason: "contract removed"})
+			continue
+		}
+		changes = append(changes, Compare(t, baseline.schemas[t], s)...)
+	}
+	if len(changes) > 0 {
+		return &BreakingChangeError{Changes: changes}
+	}
+	return nil
+}
+
+// Compare returns the changes from baseline to schema that let schema
+// accept payloads baseline rejects, attributed to eventType.
+func Compare(eventType string, baseline, schema *kimberlite.Schema) []Change {
+	k := &comparison{eventType: eventType, seen: make(map[string]bool)}
+	if err := decodeSchema(baseline.Document(), &k.oldRoot); err != nil {
+		return []Change{{EventType: eventType, Reason: "baseline schema: " + err.Error()}}
+	}
+	if err := decodeSchema(schema.Document(), &k.newRoot); err != nil {
+		return []Change{{EventType: eventType, Reason: "schema: " + err.Error()}}
+	}
+	k.compare(k.oldRoot, k.newRoot, "")
+	return k.changes
+}
+
+func decodeSchema(doc []byte, v *any) error {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+type comparison struct {
+	eventType        string
+	oldRoot, newRoot any
+	changes          []Change
+	seen             map[string]bool // pairs of $refs compared, for recursive schemas
+}
+
+func (k *comparison) breaks(path, format string, args ...any) {
+	k.changes = append(k.changes, Change{EventType: k.eventType, Path: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+// compare checks that cur accepts nothing old rejects.
+func (k *comparison) compare(old, cur any, path string) {
+	old, oldRef := k.resolve(k.oldRoot, old)
+	cur, newRef := k.resolve(k.newRoot, cur)
+	if oldRef != "" && newRef != "" {
+		pair := oldRef + "\x00" + newRef
+		if k.seen[pair] {
+			return
+		}
+		k.seen[pair] = true
+	}
+	o, n := schemaMap(old), schemaMap(cur)
+	if n == nil { // false accepts nothing
+		return
+	}
+	if o == nil {
+		k.breaks(path, "accepts values the baseline rejects")
+		return
+	}
+
+	k.compareTypes(o, n, path)
+	if ov, ok := o["const"]; ok {
+		if nv, ok := n["const"]; !ok || !jsonEqual(ov, nv) {
+			k.breaks(path, "const changed")
+		}
+	}
+	if ov, ok := o["enum"].([]any); ok {
+		allowed := arrayOf(n["enum"])
+		if nv, ok := n["const"]; ok {
+			allowed = []any{nv}
+		}
+		if allowed == nil {
+			k.breaks(path, "enum removed")
+		}
+		for _, v := range allowed {
+			if !containsJSON(ov, v) {
+				k.breaks(path, "enum value %s added", mustJSON(v))
+			}
+		}
+	}
+	for _, kw := range []string{"minimum", "exclusiveMinimum", "minLength", "minItems", "minProperties"} {
+		k.compareBound(o, n, kw, path, func(ov, nv float64) bool { return nv >= ov })
+	}
+	for _, kw := range []string{"maximum", "exclusiveMaximum", "maxLength", "maxItems", "maxProperties"} {
+		k.compareBound(o, n, kw, path, func(ov, nv float64) bool { return nv <= ov })
+	}
+	k.compareBound(o, n, "multipleOf", path, func(ov, nv float64) bool {
+		q := nv / ov
+		return math.Abs(q-math.Round(q)) < 1e-9
+	})
+	for _, kw := range []string{"pattern", "format"} {
+		if ov, ok := o[kw]; ok && !jsonEqual(ov, n[kw]) {
+			k.breaks(path, "%s changed", kw)
+		}
+	}
+	if o["uniqueItems"] == true && n["uniqueItems"] != true {
+		k.breaks(path, "uniqueItems removed")
+	}
+	for _, name := range stringsOf(o["required"]) {
+		if !contains(stringsOf(n["required"]), name) {
+			k.breaks(path, "property %q no longer required", name)
+		}
+	}
+
+	oldProps, _ := o["properties"].(map[string]any)
+	newProps, _ := n["properties"].(map[string]any)
+	for _, name := range sortedKeys(oldProps) {
+		np, ok := newProps[name]
+		if !ok {
+			np = additional(n)
+		}
+		k.compare(oldProps[name], np, path+"/properties/"+pointerEscape(name))
+	}
+	for _, name := range sortedKeys(newProps) {
+		if _, ok := oldProps[name]; !ok {
+			k.compare(additional(o), newProps[name], path+"/additionalProperties")
+		}
+	}
+	if _, ok := o["additionalProperties"]; ok {
+		k.compare(additional(o), additional(n), path+"/additionalProperties")
+	}
+	if oi, ok := o["items"]; ok {
+		ni, ok := n["items"]
+		if !ok {
+			ni = true
+		}
+		k.compare(oi, ni, path+"/items")
+	}
+	for _, kw := range []string{"allOf", "anyOf", "oneOf", "not"} {
+		if ov, ok := o[kw]; ok && !jsonEqual(ov, n[kw]) {
+			k.breaks(path, "%s changed", kw)
+		}
+	}
+}
+
+func (k *comparison) compareTypes(o, n map[string]any, path string) {
+	oldTypes := stringsOf(o["type"])
+	if oldTypes == nil {
+		return
+	}
+	newTypes := stringsOf(n["type"])
+	if newTypes == nil {
+		if _, ok := n["const"]; !ok && n["enum"] == nil {
+			k.breaks(path, "type constraint removed")
+		}
+		return
+	}
+	for _, t := range newTypes {
+		if !contains(oldTypes, t) && !(t == "integer" && contains(oldTypes, "number")) {
+			k.breaks(path, "type %s added", t)
+		}
+	}
+}
+
+// compareBound checks a numeric keyword of old is kept in new, with a
+// value for which ok holds.
+func (k *comparison) compareBound(o, n map[string]any, kw, path string, ok func(ov, nv float64) bool) {
+	ov, has := number(o[kw])
+	if !has {
+		return
+	}
+	nv, has := number(n[kw])
+	switch {
+	case !has:
+		k.breaks(path, "%s removed", kw)
+	case !ok(ov, nv):
+		k.breaks(path, "%s relaxed from %v to %v", kw, ov, nv)
+	}
+}
+
+// resolve follows a local $ref of node within root, returning the
+// referenced schema and the reference.
+func (k *comparison) resolve(root, node any) (any, string) {
+	var ref string
+	for i := 0; i < 32; i++ {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return node, ref
+		}
+		r, ok := m["$ref"].(string)
+		if !ok {
+			return node, ref
+		}
+		ref = r
+		node = lookup(root, r)
+	}
+	return false, ref
+}
+
+// lookup returns the schema a local JSON Pointer reference names, or
+// false, which no baseline keeps compatible with, if there is none.
+func lookup(root any, ref string) any {
+	if !strings.HasPrefix(ref, "#") {
+		return false
+	}
+	node := root
+	for _, tok := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		m, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		if node, ok = m[tok]; !ok {
+			return false
+		}
+	}
+	return node
+}
+
+// schemaMap returns a schema as a map of keywords; the boolean schema
+// true is an empty map and false is nil.
+func schemaMap(s any) map[string]any {
+	switch s := s.(type) {
+	case map[string]any:
+		return s
+	case bool:
+		if s {
+			return map[string]any{}
+		}
+	}
+	return nil
+}
+
+func additional(m map[string]any) any {
+	if a, ok := m["additionalProperties"]; ok {
+		return a
+	}
+	return true
+}
+
+func number(v any) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func stringsOf(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func arrayOf(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsJSON(list []any, v any) bool {
+	for _, x := range list {
+		if jsonEqual(x, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonEqual(a, b any) bool {
+	return mustJSON(a) == mustJSON(b)
+}
+
+// mustJSON encodes a decoded JSON value canonically: encoding/json
+// sorts object keys.
+func mustJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func pointerEscape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
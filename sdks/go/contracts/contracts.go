@@ -0,0 +1,227 @@
+// Package contracts tests events against the schemas their consumers
+// rely on, so a producer change that would break them fails the build
+// rather than reaching the immutable log.
+//
+// A Contracts holds a schema per event type: a JSON Schema, or a
+// protobuf message type for payloads encoded with ProtoCodec. Tests
+// check the events code under test produced:
+//
+//	c := contracts.New()
+//	c.Register("PatientAdmitted", kimberlite.MustCompileSchema(admittedSchema))
+//	// ... run the code under test against a kimberlitetest client ...
+//	c.Check(t, store.Events(admissions)...)
+//
+// JSON Schemas are also checked against a baseline committed with the
+// consumers, typically with LoadDir and WriteDir on a testdata
+// directory. CompatibleWith reports each change that lets producers
+// write payloads the baseline rejects:
+//
+//	baseline, err := contracts.LoadDir("testdata/contracts")
+//	if err := c.CompatibleWith(baseline); err != nil {
+//	    t.Fatal(err) // or, after agreeing the change with consumers, c.WriteDir("testdata/contracts")
+//	}
+//
+// The comparison is conservative: a schema is compatible when every
+// assertion of the baseline is kept or tightened, and changes to
+// allOf, anyOf, oneOf and not it cannot prove safe are reported.
+// Protobuf contracts are only validated; compatibility of message
+// definitions is left to tools working on .proto files, such as buf.
+package contracts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// ErrNoContract is returned by Validate for events of a type without a
+// contract, including events without an envelope or type.
+var ErrNoContract = errors.New("kimberlite: event type has no contract")
+
+// schemaSuffix ends the file names of LoadDir and WriteDir.
+const schemaSuffix = ".schema.json"
+
+// protoMessageKey is the envelope metadata key in which ProtoCodec
+// records the message name.
+const protoMessageKey = "proto_message"
+
+// Contracts maps event types to the schemas of their payloads. It is
+// safe for concurrent use.
+type Contracts struct {
+	mu      sync.RWMutex
+	schemas map[string]*kimberlite.Schema
+	protos  map[string]protoContract
+}
+
+type protoContract struct {
+	rt   kimberlite.ProtoRuntime
+	typ  reflect.Type
+	name string
+}
+
+// New returns an empty set of contracts.
+func New() *Contracts {
+	return &Contracts{schemas: make(map[string]*kimberlite.Schema), protos: make(map[string]protoContract)}
+}
+
+// Register requires JSON payloads of eventType to conform to schema,
+// replacing any contract the type had.
+func (c *Contracts) Register(eventType string, schema *kimberlite.Schema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.protos, eventType)
+	c.schemas[eventType] = schema
+}
+
+// RegisterProto requires payloads of eventType to decode with rt as
+// messages of msg's type, a pointer to a generated message, and to be
+// recorded under its name, replacing any contract the type had.
+func (c *Contracts) RegisterProto(eventType string, rt kimberlite.ProtoRuntime, msg any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.schemas, eventType)
+	c.protos[eventType] = protoContract{rt: rt, typ: reflect.TypeOf(msg).Elem(), name: rt.MessageName(msg)}
+}
+
+// Types returns the event types with a contract, sorted.
+func (c *Contracts) Types() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	types := make([]string, 0, len(c.schemas)+len(c.protos))
+	for t := range c.schemas {
+		types = append(types, t)
+	}
+	for t := range c.protos {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Apply registers the JSON Schemas with reg, so the client validates
+// appends against them as well.
+func (c *Contracts) Apply(reg *kimberlite.CodecRegistry) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for t, s := range c.schemas {
+		reg.RegisterSchema(t, s)
+	}
+}
+
+// Validate checks an envelope against its type's contract. JSON Schema
+// violations are reported as a *kimberlite.SchemaError.
+func (c *Contracts) Validate(env kimberlite.Envelope) error {
+	c.mu.RLock()
+	schema, p := c.schemas[env.Type], c.protos[env.Type]
+	c.mu.RUnlock()
+	switch {
+	case schema != nil:
+		if err := schema.Validate(env.Data); err != nil {
+			var se *kimberlite.SchemaError
+			if errors.As(err, &se) {
+				se.EventType = env.Type
+			}
+			return err
+		}
+		return nil
+	case p.rt != nil:
+		if name, ok := env.Metadata[protoMessageKey]; ok && name != p.name {
+			return fmt.Errorf("kimberlite: event %q is a %s message, not %s", env.Type, name, p.name)
+		}
+		if err := p.rt.Unmarshal(env.Data, reflect.New(p.typ).Interface()); err != nil {
+			return fmt.Errorf("kimberlite: event %q is not a %s message: %w", env.Type, p.name, err)
+		}
+		return nil
+	case env.Type == "":
+		return fmt.Errorf("%w: event has no type", ErrNoContract)
+	}
+	return fmt.Errorf("%w: %q", ErrNoContract, env.Type)
+}
+
+// ValidateEvents decodes each event's envelope and validates it, and
+// returns the errors of all events that fail.
+func (c *Contracts) ValidateEvents(events ...kimberlite.Event) error {
+	var errs []error
+	for _, ev := range events {
+		env, err := ev.Envelope()
+		if err == nil {
+			err = c.Validate(env)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stream %d offset %d: %w", ev.StreamID, ev.Offset, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check reports each of events that fails validation as an error of t.
+func (c *Contracts) Check(t testing.TB, events ...kimberlite.Event) {
+	t.Helper()
+	for _, ev := range events {
+		if err := c.ValidateEvents(ev); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// LoadDir reads contracts from the JSON Schema files in dir, named
+// after their event type with the suffix ".schema.json".
+func LoadDir(dir string) (*Contracts, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+schemaSuffix))
+	if err != nil {
+		return nil, err
+	}
+	c := New()
+	for _, path := range paths {
+		doc, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := kimberlite.CompileSchema(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		c.Register(strings.TrimSuffix(filepath.Base(path), schemaSuffix), schema)
+	}
+	return c, nil
+}
+
+// WriteDir writes the JSON Schema contracts to dir in the layout
+// LoadDir reads, creating dir if needed, and removes the files of
+// types without one.
+func (c *Contracts) WriteDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	old, err := filepath.Glob(filepath.Join(dir, "*"+schemaSuffix))
+	if err != nil {
+		return err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, path := range old {
+		if _, ok := c.schemas[strings.TrimSuffix(filepath.Base(path), schemaSuffix)]; !ok {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	for t, s := range c.schemas {
+		doc := append([]byte(nil), s.Document()...)
+		if len(doc) == 0 || doc[len(doc)-1] != '\n' {
+			doc = append(doc, '\n')
+		}
+		if err := os.WriteFile(filepath.Join(dir, t+schemaSuffix), doc, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+const admittedV1 = `{
+	"type": "object",
+	"required": ["mrn", "ward"],
+	"properties": {
+		"mrn": {"type": "string", "pattern": "^[A-Z][0-9]+$"},
+		"ward": {"type": "integer", "minimum": 1, "maximum": 40},
+		"status": {"enum": ["admitted", "transferred"]},
+		"notes": {"type": "array", "items": {"$ref": "#/$defs/note"}}
+	},
+	"additionalProperties": false,
+	"$defs": {"note": {"type": "string", "maxLength": 200}}
+}`
+
+func event(t *testing.T, typ, payload string) kimberlite.Event {
+	t.Helper()
+	data, err := kimberlite.Envelope{ID: kimberlite.NewEventID(), Type: typ, Data: []byte(payload)}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kimberlite.Event{StreamID: 1, Data: data}
+}
+
+func TestValidate(t *testing.T) {
+	c := New()
+	c.Register("PatientAdmitted", kimberlite.MustCompileSchema([]byte(admittedV1)))
+
+	if err := c.ValidateEvents(event(t, "PatientAdmitted", `{"mrn":"A12","ward":4,"notes":["ok"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	err := c.ValidateEvents(event(t, "PatientAdmitted", `{"mrn":"A12","ward":99}`))
+	var se *kimberlite.SchemaError
+	if !errors.As(err, &se) || se.EventType != "PatientAdmitted" || se.Path != "/ward" {
+		t.Fatalf("violation: %v", err)
+	}
+	if err := c.ValidateEvents(event(t, "PatientDischarged", `{}`)); !errors.Is(err, ErrNoContract) {
+		t.Fatalf("unknown type: %v", err)
+	}
+	if err := c.ValidateEvents(kimberlite.Event{Data: []byte("raw")}); !errors.Is(err, ErrNoContract) {
+		t.Fatalf("raw event: %v", err)
+	}
+}
+
+type fakeMessage struct{ Name string }
+
+type fakeProto struct{}
+
+func (fakeProto) Marshal(m any) ([]byte, error) { return json.Marshal(m) }
+func (fakeProto) Unmarshal(b []byte, m any) error {
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.DisallowUnknownFields()
+	return dec.Decode(m)
+}
+func (fakeProto) MessageName(any) string { return "test.FakeMessage" }
+
+func TestValidateProto(t *testing.T) {
+	c := New()
+	c.RegisterProto("Named", fakeProto{}, &fakeMessage{})
+	if err := c.Validate(kimberlite.Envelope{Type: "Named", Data: []byte(`{"Name":"a"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(kimberlite.Envelope{Type: "Named", Data: []byte(`{"Other":1}`)}); err == nil {
+		t.Fatal("undecodable message accepted")
+	}
+	env := kimberlite.Envelope{Type: "Named", Data: []byte(`{}`), Metadata: map[string]string{"proto_message": "test.Other"}}
+	if err := c.Validate(env); err == nil {
+		t.Fatal("message of another type accepted")
+	}
+}
+
+func TestCompatibleWith(t *testing.T) {
+	baseline := New()
+	baseline.Register("PatientAdmitted", kimberlite.MustCompileSchema([]byte(admittedV1)))
+	baseline.Register("PatientDischarged", kimberlite.MustCompileSchema([]byte(`{"type":"object"}`)))
+
+	compatible := New()
+	for typ, doc := range map[string]string{
+		// Narrower bounds and new required properties and types.
+		"PatientAdmitted":   strings.Replace(admittedV1, `"maximum": 40`, `"maximum": 30`, 1),
+		"PatientDischarged": `{"type":"object","required":["at"]}`,
+		"PatientNew":        `{"type":"object"}`,
+	} {
+		compatible.Register(typ, kimberlite.MustCompileSchema([]byte(doc)))
+	}
+	if err := compatible.CompatibleWith(baseline); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := strings.NewReplacer(
+		`"required": ["mrn", "ward"]`, `"required": ["mrn"]`,
+		`"maximum": 40`, `"maximum": 50`,
+		`"enum": ["admitted", "transferred"]`, `"enum": ["admitted", "transferred", "left"]`,
+		`"maxLength": 200`, `"maxLength": 500`,
+		`"additionalProperties": false`, `"additionalProperties": {"type": "string"}`,
+	).Replace(admittedV1)
+	c := New()
+	c.Register("PatientAdmitted", kimberlite.MustCompileSchema([]byte(broken)))
+	err := c.CompatibleWith(baseline)
+	var bce *BreakingChangeError
+	if !errors.As(err, &bce) || !errors.Is(err, ErrBreakingChange) {
+		t.Fatalf("breaking changes: %v", err)
+	}
+	var got []string
+	for _, ch := range bce.Changes {
+		got = append(got, ch.String())
+	}
+	want := []string{
+		`PatientAdmitted /: property "ward" no longer required`,
+		`PatientAdmitted /properties/notes/items: maxLength relaxed from 200 to 500`,
+		`PatientAdmitted /properties/status: enum value "left" added`,
+		`PatientAdmitted /properties/ward: maximum relaxed from 40 to 50`,
+		`PatientAdmitted /additionalProperties: accepts values the baseline rejects`,
+		`PatientDischarged /: contract removed`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	c := New()
+	c.Register("PatientAdmitted", kimberlite.MustCompileSchema([]byte(admittedV1)))
+	if err := os.WriteFile(filepath.Join(dir, "Stale"+schemaSuffix), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types := loaded.Types(); len(types) != 1 || types[0] != "PatientAdmitted" {
+		t.Fatalf("loaded %v", types)
+	}
+	if err := loaded.CompatibleWith(c); err != nil {
+		t.Fatal(err)
+	}
+}
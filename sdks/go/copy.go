@@ -0,0 +1,121 @@
+package kimberlite
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CopyFromSource supplies the rows CopyFrom loads, in the manner of
+// database/sql's Rows: Next advances to each row and Values returns it.
+type CopyFromSource interface {
+	// Next advances to the next row, reporting false when there are no
+	// more or an error occurred.
+	Next() bool
+	// Values returns the current row, one value per column.
+	Values() ([]Value, error)
+	// Err returns the error, if any, that stopped Next.
+	Err() error
+}
+
+// CopyFromRows returns a CopyFromSource reading rows.
+func CopyFromRows(rows [][]Value) CopyFromSource {
+	return &sliceSource{rows: rows, i: -1}
+}
+
+type sliceSource struct {
+	rows [][]Value
+	i    int
+}
+
+func (s *sliceSource) Next() bool {
+	s.i++
+	return s.i < len(s.rows)
+}
+
+func (s *sliceSource) Values() ([]Value, error) { return s.rows[s.i], nil }
+func (s *sliceSource) Err() error               { return nil }
+
+// CopyFrom loads the rows of src into the columns of table, and returns
+// the number of rows inserted.
+//
+// The protocol has no bulk-load message, so rows are sent through an
+// InsertBatcher, configured by opts, as multi-row INSERT statements
+// with the values bound as parameters; that is far faster than one
+// INSERT a row, since each statement is a single round trip and a
+// single commit. Batches are committed as they are sent: if CopyFrom
+// fails, the rows of earlier batches, which the count covers, remain
+// inserted, as may those of the failing batch up to the row that
+// failed.
+//
+// table and columns must be plain identifiers.
+func (c *Client) CopyFrom(ctx context.Context, table string, columns []string, src CopyFromSource, opts ...BatchOption) (int64, error) {
+	b, err := c.NewInsertBatcher(table, columns, opts...)
+	if err != nil {
+		return 0, err
+	}
+	for src.Next() {
+		vals, err := src.Values()
+		if err != nil {
+			return b.Inserted(), &RowError{Row: b.added + 1, Err: err}
+		}
+		if err := b.add(ctx, vals); err != nil {
+			return b.Inserted(), err
+		}
+	}
+	if err := src.Err(); err != nil {
+		return b.Inserted(), fmt.Errorf("kimberlite: copy: %w", err)
+	}
+	err = b.Flush(ctx)
+	return b.Inserted(), err
+}
+
+// insertSQL returns a multi-row INSERT of rows rows into columns of
+// table, with the values as positional parameters in row order.
+func insertSQL(table string, columns []string, rows int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES ")
+	n := 0
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for i := range columns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// checkIdentifiers checks that table and columns are plain identifiers,
+// safe to write into SQL as they are.
+func checkIdentifiers(table string, columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("kimberlite: no columns for %s", table)
+	}
+	for _, name := range append([]string{table}, columns...) {
+		if !isIdentifier(name) {
+			return fmt.Errorf("kimberlite: %q is not a plain identifier", name)
+		}
+	}
+	return nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
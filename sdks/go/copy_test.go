@@ -0,0 +1,64 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// execBackend acknowledges writes, recording their statements, and
+// fails the call numbered failAt.
+type execBackend struct {
+	blockingBackend
+	sql    []string
+	args   [][]Value
+	failAt int
+}
+
+func (b *execBackend) Execute(sql string, args []Value) (*ExecResult, error) {
+	b.sql = append(b.sql, sql)
+	b.args = append(b.args, args)
+	if len(b.sql) == b.failAt {
+		return nil, ErrQueryFailed
+	}
+	return &ExecResult{RowsAffected: int64(strings.Count(sql, "(")) - 1}, nil
+}
+
+func TestCopyFrom(t *testing.T) {
+	b := &execBackend{}
+	c, err := Connect("test", WithTenant(1), WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	rows := make([][]Value, 2500)
+	for i := range rows {
+		rows[i] = []Value{NewInt(int64(i)), NewText("patient")}
+	}
+	n, err := c.CopyFrom(context.Background(), "patients", []string{"id", "name"}, CopyFromRows(rows))
+	if err != nil || n != 2500 {
+		t.Fatalf("copied %d: %v", n, err)
+	}
+	if len(b.sql) != 3 || len(b.args[2]) != 1000 || b.args[2][999].AsText() != "patient" || b.args[2][998].AsInt() != 2499 {
+		t.Fatalf("sent %d statements", len(b.sql))
+	}
+	if !strings.HasPrefix(b.sql[0], "INSERT INTO patients (id, name) VALUES ($1, $2), ($3, $4)") {
+		t.Fatalf("sql %.60q", b.sql[0])
+	}
+
+	b.sql, b.failAt = nil, 2
+	n, err = c.CopyFrom(context.Background(), "patients", []string{"id", "name"}, CopyFromRows(rows))
+	if n != 1000 || !errors.Is(err, ErrQueryFailed) || !strings.Contains(err.Error(), "rows 1001-2000") {
+		t.Fatalf("copied %d: %v", n, err)
+	}
+
+	if _, err := c.CopyFrom(context.Background(), "patients; DROP TABLE x", []string{"id"}, CopyFromRows(nil)); err == nil {
+		t.Fatal("table name not checked")
+	}
+	short := [][]Value{{NewInt(1)}}
+	if _, err := c.CopyFrom(context.Background(), "patients", []string{"id", "name"}, CopyFromRows(short)); err == nil {
+		t.Fatal("short row accepted")
+	}
+}
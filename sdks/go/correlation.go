@@ -0,0 +1,74 @@
+package kimberlite
+
+import "context"
+
+// Correlation and causation IDs flow through context.Context the same
+// way AuditContext does. A consumer handling an event derives its
+// context from the event, and every envelope appended under that
+// context is linked back to it:
+//
+//	for i, env := range envs {
+//	    ctx := kimberlite.ContextFromEnvelope(ctx, env)
+//	    _, err := client.AppendEnvelopes(ctx, billing, kimberlite.Envelope{
+//	        Type: "InvoiceRaised",
+//	        Data: invoice,
+//	    }) // CorrelationID = env's workflow, CausationID = env.ID
+//	}
+
+type correlationKey struct{}
+
+type correlationIDs struct {
+	correlation string
+	causation   string
+}
+
+// WithCorrelationID returns a derived context whose appended envelopes
+// carry id as their CorrelationID.
+func WithCorrelationID(parent context.Context, id string) context.Context {
+	ids := correlationFrom(parent)
+	ids.correlation = id
+	return context.WithValue(parent, correlationKey{}, ids)
+}
+
+// WithCausationID returns a derived context whose appended envelopes
+// carry id as their CausationID.
+func WithCausationID(parent context.Context, id string) context.Context {
+	ids := correlationFrom(parent)
+	ids.causation = id
+	return context.WithValue(parent, correlationKey{}, ids)
+}
+
+// ContextFromEnvelope returns a context for handling env: events
+// appended under it continue env's workflow (its CorrelationID, or its
+// ID if it started the workflow) and name env as their cause.
+func ContextFromEnvelope(parent context.Context, env Envelope) context.Context {
+	correlation := env.CorrelationID
+	if correlation == "" {
+		correlation = env.ID
+	}
+	return context.WithValue(parent, correlationKey{}, correlationIDs{
+		correlation: correlation,
+		causation:   env.ID,
+	})
+}
+
+// CorrelationFromContext returns the correlation and causation IDs
+// carried by ctx. When no correlation ID has been set explicitly, the
+// CorrelationID of the request's AuditContext is used.
+func CorrelationFromContext(ctx context.Context) (correlationID, causationID string) {
+	ids := correlationFrom(ctx)
+	if ids.correlation == "" {
+		if audit, ok := AuditFromContext(ctx); ok {
+			ids.correlation = audit.CorrelationID
+		}
+	}
+	return ids.correlation, ids.causation
+}
+
+func correlationFrom(ctx context.Context) correlationIDs {
+	if ctx == nil {
+		return correlationIDs{}
+	}
+	ids, _ := ctx.Value(correlationKey{}).(correlationIDs)
+	return ids
+}
@@ -0,0 +1,186 @@
+package kimberlite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Data-class enforcement stops a misconfigured service from writing
+// protected data in the clear. With WithDataClassEnforcement, appends
+// to DataClassRestricted streams fail with a *DataClassError unless the
+// client encrypts the stream's events (see WithEncryption) and its
+// connection is secured:
+//
+//	client, err := kimberlite.Connect(addr,
+//	    kimberlite.WithEncryption(enc),
+//	    kimberlite.WithSecureTransport(),
+//	    kimberlite.WithDataClassEnforcement())
+//
+// The client connects over plain TCP, so transport security is provided
+// outside it, by a TLS sidecar, tunnel or service mesh, and declared
+// with WithSecureTransport. Appends to streams whose class the client
+// does not know fail with ErrUnknownStreamClass.
+
+// An enveloped event may declare a data class stricter than its
+// stream's with Envelope.SetDataClass, for streams mixing sensitivity
+// levels. Encryption, the PII guard and data-class enforcement treat
+// such an event as the stricter class, so a Restricted event in an
+// Internal stream is encrypted with the Restricted key and only readers
+// holding that key see it in clear text. A declared class weaker than
+// the stream's has no effect.
+
+const metaDataClass = "data_class"
+
+// ParseDataClass parses the String form of a data class.
+func ParseDataClass(s string) (DataClass, error) {
+	for _, class := range []DataClass{DataClassPublic, DataClassInternal, DataClassConfidential, DataClassRestricted} {
+		if strings.EqualFold(s, class.String()) {
+			return class, nil
+		}
+	}
+	return 0, fmt.Errorf("kimberlite: unknown data class %q", s)
+}
+
+// SetDataClass declares the envelope's data class.
+func (e *Envelope) SetDataClass(class DataClass) {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[metaDataClass] = class.String()
+}
+
+// DataClass returns the envelope's declared data class, if any. An
+// unparseable declaration is reported as DataClassRestricted.
+func (e Envelope) DataClass() (DataClass, bool) {
+	s, ok := e.Metadata[metaDataClass]
+	if !ok {
+		return 0, false
+	}
+	class, err := ParseDataClass(s)
+	if err != nil {
+		return DataClassRestricted, true
+	}
+	return class, true
+}
+
+// eventClass returns the data class of event data appended to a stream
+// of class, which is known if known is set. The event's class is known
+// if the stream's is, or if it declares the strictest class.
+func eventClass(data []byte, class DataClass, known bool) (DataClass, bool) {
+	if !IsEnvelope(data) {
+		return class, known
+	}
+	env, err := UnmarshalEnvelope(data)
+	if err != nil {
+		return class, known
+	}
+	declared, ok := env.DataClass()
+	switch {
+	case !ok:
+		return class, known
+	case known:
+		return max(class, declared), true
+	default:
+		return declared, declared == DataClassRestricted
+	}
+}
+
+// ErrDataClassPolicy is returned (wrapped in a *DataClassError) for
+// appends that violate data-class enforcement.
+var ErrDataClassPolicy = errors.New("kimberlite: data class policy violation")
+
+// DataClassError describes an append refused by data-class enforcement.
+type DataClassError struct {
+	StreamID StreamID
+	Class    DataClass
+	// Missing names the unmet requirement: "encryption" or "secure
+	// transport".
+	Missing string
+}
+
+func (e *DataClassError) Error() string {
+	return fmt.Sprintf("kimberlite: %s stream %d requires %s", e.Class, e.StreamID, e.Missing)
+}
+
+// Unwrap makes errors.Is(err, ErrDataClassPolicy) hold.
+func (e *DataClassError) Unwrap() error { return ErrDataClassPolicy }
+
+// WithDataClassEnforcement makes the client refuse appends to
+// Restricted streams without encryption and secure transport.
+func WithDataClassEnforcement() Option {
+	return func(c *Client) { c.enforceClasses = true }
+}
+
+// WithSecureTransport declares that the connection to the server is
+// protected by TLS terminated outside the client.
+func WithSecureTransport() Option {
+	return func(c *Client) { c.secureTransport = true }
+}
+
+// SetStreamClass records the data class of a stream created outside
+// the client, for its encryption, PII guard and data-class enforcement.
+// Streams created through the client are recorded automatically.
+func (c *Client) SetStreamClass(id StreamID, class DataClass) {
+	c.streams.set(id, class)
+	if c.encryption != nil {
+		c.encryption.SetStreamClass(id, class)
+	}
+	if c.pii != nil {
+		c.pii.SetStreamClass(id, class)
+	}
+}
+
+// StreamClass returns the data class of stream id, if the client knows
+// it.
+func (c *Client) StreamClass(id StreamID) (DataClass, bool) {
+	return c.streams.get(id)
+}
+
+// checkDataClass enforces the data-class policy for an append of
+// events to stream.
+func (c *Client) checkDataClass(stream StreamID, events [][]byte) error {
+	if !c.enforceClasses {
+		return nil
+	}
+	streamClass, streamKnown := c.streams.get(stream)
+	for _, data := range events {
+		class, known := eventClass(data, streamClass, streamKnown)
+		if !known {
+			return fmt.Errorf("%w: stream %d", ErrUnknownStreamClass, stream)
+		}
+		if class != DataClassRestricted {
+			continue
+		}
+		if c.encryption == nil || !c.encryption.classes[class] {
+			return &DataClassError{StreamID: stream, Class: class, Missing: "encryption"}
+		}
+		if !c.secureTransport {
+			return &DataClassError{StreamID: stream, Class: class, Missing: "secure transport"}
+		}
+	}
+	return nil
+}
+
+// streamClasses records the data classes of streams.
+type streamClasses struct {
+	mu      sync.RWMutex
+	classes map[StreamID]DataClass
+}
+
+func (s *streamClasses) set(id StreamID, class DataClass) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.classes == nil {
+		s.classes = make(map[StreamID]DataClass)
+	}
+	s.classes[id] = class
+}
+
+func (s *streamClasses) get(id StreamID) (DataClass, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	class, ok := s.classes[id]
+	return class, ok
+}
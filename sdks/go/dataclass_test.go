@@ -0,0 +1,100 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDataClassEnforcement(t *testing.T) {
+	plain := &Client{enforceClasses: true}
+	plain.SetStreamClass(1, DataClassRestricted)
+	plain.SetStreamClass(2, DataClassInternal)
+	var dce *DataClassError
+	if err := plain.checkDataClass(1, [][]byte{[]byte("x")}); !errors.As(err, &dce) || dce.Missing != "encryption" || !errors.Is(err, ErrDataClassPolicy) {
+		t.Fatalf("expected missing encryption, got %v", err)
+	}
+	if err := plain.checkDataClass(2, [][]byte{[]byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.checkDataClass(3, [][]byte{[]byte("x")}); !errors.Is(err, ErrUnknownStreamClass) {
+		t.Fatalf("expected ErrUnknownStreamClass, got %v", err)
+	}
+
+	enc := NewEncryption(NewKeyring())
+	encrypted := &Client{enforceClasses: true, encryption: enc}
+	encrypted.SetStreamClass(1, DataClassRestricted)
+	if _, known := enc.streams.get(1); !known {
+		t.Fatal("stream class not passed to encryption")
+	}
+	if err := encrypted.checkDataClass(1, [][]byte{[]byte("x")}); !errors.As(err, &dce) || dce.Missing != "secure transport" {
+		t.Fatalf("expected missing secure transport, got %v", err)
+	}
+	encrypted.secureTransport = true
+	if err := encrypted.checkDataClass(1, [][]byte{[]byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+
+	publicOnly := &Client{enforceClasses: true, secureTransport: true, encryption: NewEncryption(nil, EncryptClasses(DataClassConfidential))}
+	publicOnly.SetStreamClass(1, DataClassRestricted)
+	if err := publicOnly.checkDataClass(1, [][]byte{[]byte("x")}); !errors.As(err, &dce) || dce.Missing != "encryption" {
+		t.Fatalf("expected missing encryption, got %v", err)
+	}
+	if err := (&Client{}).checkDataClass(1, [][]byte{[]byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventDataClass(t *testing.T) {
+	ctx := context.Background()
+	restricted := Envelope{ID: "e1", Data: []byte("ssn 123-45-6789")}
+	restricted.SetDataClass(DataClassRestricted)
+	weaker := Envelope{ID: "e2", Data: []byte("ssn 123-45-6789")}
+	weaker.SetDataClass(DataClassPublic)
+	r, _ := restricted.Marshal()
+	w, _ := weaker.Marshal()
+
+	if class, ok := restricted.DataClass(); !ok || class != DataClassRestricted {
+		t.Fatalf("declared class = %v, %v", class, ok)
+	}
+	if _, err := ParseDataClass("secret"); err == nil {
+		t.Fatal("expected error for unknown class")
+	}
+	if class, known := eventClass(w, DataClassConfidential, true); !known || class != DataClassConfidential {
+		t.Fatalf("weaker declaration = %v", class)
+	}
+	if class, known := eventClass(r, 0, false); !known || class != DataClassRestricted {
+		t.Fatal("Restricted declaration on unknown stream")
+	}
+
+	keys := NewKeyring()
+	if err := keys.Add(DataClassRestricted, "phi-1", bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	enc := NewEncryption(keys)
+	enc.SetStreamClass(1, DataClassInternal)
+	sealed, err := enc.encryptEvents(ctx, 1, 1, [][]byte{r, []byte("plain")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed[0]) || IsEncrypted(sealed[1]) {
+		t.Fatal("per-event class not honored by encryption")
+	}
+
+	guard := NewPIIGuard(nil, BlockPII())
+	guard.SetStreamClass(1, DataClassInternal)
+	if _, err := guard.inspect(ctx, 1, [][]byte{r}); err != nil {
+		t.Fatalf("Restricted event scanned: %v", err)
+	}
+	if _, err := guard.inspect(ctx, 1, [][]byte{w}); !errors.Is(err, ErrPIIDetected) {
+		t.Fatalf("expected ErrPIIDetected, got %v", err)
+	}
+
+	c := &Client{enforceClasses: true}
+	c.SetStreamClass(1, DataClassInternal)
+	var dce *DataClassError
+	if err := c.checkDataClass(1, [][]byte{w, r}); !errors.As(err, &dce) || dce.Class != DataClassRestricted {
+		t.Fatalf("expected DataClassError, got %v", err)
+	}
+}
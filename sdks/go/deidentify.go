@@ -0,0 +1,266 @@
+package kimberlite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// De-identification replaces the direct identifiers of events with
+// tokens before they leave for analytics copies: data warehouses,
+// Kafka topics, archives. Tokens are keyed hashes, so they are
+// deterministic, and joins on a tokenized column still work, but they
+// cannot be reversed, even by the key holder, and cannot be guessed
+// without the key, even for small value spaces such as SSNs:
+//
+//	tok, err := kimberlite.NewTokenizer(key)
+//	d := kimberlite.NewDeidentifier(tok,
+//	    kimberlite.DeidentifyField("PatientAdmitted", "patient.name", kimberlite.TokenizeField),
+//	    kimberlite.DeidentifyField("PatientAdmitted", "patient.ssn", kimberlite.TokenizeFieldFormat),
+//	    kimberlite.DeidentifyField("", "notes", kimberlite.DropField),
+//	    kimberlite.DeidentifyPII(nil))
+//	sink := connect.NewSink(connect.Transform(client, d.DeidentifyEvent), producer, streams)
+//
+// Keep the tokenization key out of the analytics environment; anyone
+// holding it can test guesses against tokens.
+
+// Tokenizer derives deterministic tokens from values with HMAC-SHA256.
+type Tokenizer struct {
+	key []byte
+}
+
+// NewTokenizer returns a Tokenizer keyed by key, which must be at least
+// 32 bytes of secret random data.
+func NewTokenizer(key []byte) (*Tokenizer, error) {
+	if len(key) < 32 {
+		return nil, errors.New("kimberlite: tokenization key must be at least 32 bytes")
+	}
+	return &Tokenizer{key: append([]byte(nil), key...)}, nil
+}
+
+// Token returns the token of value: "tok_" and 32 hex digits.
+func (t *Tokenizer) Token(value string) string {
+	sum := t.mac("token", value, 0)
+	return "tok_" + hex.EncodeToString(sum[:16])
+}
+
+// FormatToken returns a token of value with its format: every digit is
+// replaced by a digit, every ASCII letter by a letter of the same case,
+// and every other character is kept, so "457-55-5462" becomes another
+// string of the same shape that passes format validation downstream.
+func (t *Tokenizer) FormatToken(value string) string {
+	var b strings.Builder
+	b.Grow(len(value))
+	var block [sha256.Size]byte
+	var counter uint64
+	used := len(block)
+	next := func(n byte) byte {
+		if used == len(block) {
+			block = t.mac("format", value, counter)
+			counter++
+			used = 0
+		}
+		used++
+		return block[used-1] % n
+	}
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteByte('0' + next(10))
+		case r >= 'a' && r <= 'z':
+			b.WriteByte('a' + next(26))
+		case r >= 'A' && r <= 'Z':
+			b.WriteByte('A' + next(26))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (t *Tokenizer) mac(domain, value string, counter uint64) [sha256.Size]byte {
+	m := hmac.New(sha256.New, t.key)
+	m.Write([]byte(domain))
+	m.Write([]byte{0})
+	m.Write(binary.BigEndian.AppendUint64(nil, counter))
+	m.Write([]byte(value))
+	var sum [sha256.Size]byte
+	m.Sum(sum[:0])
+	return sum
+}
+
+// DeidentifyAction is what a Deidentifier does to a field.
+type DeidentifyAction int
+
+const (
+	// DropField removes the field.
+	DropField DeidentifyAction = iota
+	// TokenizeField replaces the field with its Token.
+	TokenizeField
+	// TokenizeFieldFormat replaces the field with its FormatToken.
+	TokenizeFieldFormat
+)
+
+type fieldRule struct {
+	path   string
+	action DeidentifyAction
+}
+
+// Deidentifier de-identifies events for export.
+type Deidentifier struct {
+	tok      *Tokenizer
+	fields   map[string][]fieldRule
+	metadata []string
+	detector Detector
+}
+
+// DeidentifyOption configures a Deidentifier.
+type DeidentifyOption func(*Deidentifier)
+
+// DeidentifyField applies action to a field of the JSON payloads of
+// eventType, or of every event if eventType is empty. Fields name
+// object members with dots for nested ones, as in EncryptFields.
+// Values other than strings are tokenized in their JSON form, and
+// tokens are always strings.
+func DeidentifyField(eventType, path string, action DeidentifyAction) DeidentifyOption {
+	return func(d *Deidentifier) {
+		d.fields[eventType] = append(d.fields[eventType], fieldRule{path: path, action: action})
+	}
+}
+
+// DeidentifyMetadata tokenizes the envelope metadata entries named by
+// keys. The subject_id entry is always tokenized.
+func DeidentifyMetadata(keys ...string) DeidentifyOption {
+	return func(d *Deidentifier) { d.metadata = append(d.metadata, keys...) }
+}
+
+// DeidentifyPII replaces the identifiers found by detector anywhere in
+// payloads, after the field rules, with their FormatToken. A nil
+// detector means a RegexDetector.
+func DeidentifyPII(detector Detector) DeidentifyOption {
+	return func(d *Deidentifier) {
+		if detector == nil {
+			detector = RegexDetector{}
+		}
+		d.detector = detector
+	}
+}
+
+// NewDeidentifier returns a Deidentifier tokenizing with tok.
+func NewDeidentifier(tok *Tokenizer, opts ...DeidentifyOption) *Deidentifier {
+	d := &Deidentifier{tok: tok, fields: make(map[string][]fieldRule), metadata: []string{metaSubject}}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// DeidentifyEvent returns ev with its identifiers replaced. Field
+// rules apply to JSON object payloads, of envelopes or of bare events;
+// other payloads are only scanned for PII. Encrypted payloads and
+// fields are passed through sealed.
+func (d *Deidentifier) DeidentifyEvent(ev Event) (Event, error) {
+	if !IsEnvelope(ev.Data) {
+		data, err := d.payload(d.fields[""], ev.Data, true)
+		if err != nil {
+			return Event{}, fmt.Errorf("kimberlite: de-identify stream %d offset %d: %w", ev.StreamID, ev.Offset, err)
+		}
+		ev.Data = data
+		return ev, nil
+	}
+	env, err := UnmarshalEnvelope(ev.Data)
+	if err != nil {
+		return Event{}, fmt.Errorf("kimberlite: de-identify stream %d offset %d: %w", ev.StreamID, ev.Offset, err)
+	}
+	rules := append(append([]fieldRule(nil), d.fields[""]...), d.fields[env.Type]...)
+	if env.Data, err = d.payload(rules, env.Data, isJSONContentType(env.Metadata[metaContentType])); err != nil {
+		return Event{}, fmt.Errorf("kimberlite: de-identify stream %d offset %d: %w", ev.StreamID, ev.Offset, err)
+	}
+	if len(env.Metadata) > 0 {
+		meta := make(map[string]string, len(env.Metadata))
+		for k, v := range env.Metadata {
+			meta[k] = v
+		}
+		for _, k := range d.metadata {
+			if v, ok := meta[k]; ok {
+				meta[k] = d.tok.Token(v)
+			}
+		}
+		env.Metadata = meta
+	}
+	if ev.Data, err = env.Marshal(); err != nil {
+		return Event{}, err
+	}
+	return ev, nil
+}
+
+func (d *Deidentifier) payload(rules []fieldRule, data []byte, maybeJSON bool) ([]byte, error) {
+	if IsEncrypted(data) {
+		return data, nil
+	}
+	if len(rules) > 0 && maybeJSON {
+		if doc, err := decodeJSONObject(data); err == nil {
+			for _, rule := range rules {
+				if err := d.apply(doc, rule); err != nil {
+					return nil, err
+				}
+			}
+			if data, err = json.Marshal(doc); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if d.detector == nil {
+		return data, nil
+	}
+	findings := d.detector.Detect(data)
+	if len(findings) == 0 {
+		return data, nil
+	}
+	out := append([]byte(nil), data...)
+	for _, f := range findings {
+		if f.Start < 0 || f.End > len(out) || f.Start >= f.End || !utf8.Valid(out[f.Start:f.End]) {
+			continue
+		}
+		copy(out[f.Start:f.End], d.tok.FormatToken(string(out[f.Start:f.End])))
+	}
+	return out, nil
+}
+
+func (d *Deidentifier) apply(doc map[string]any, rule fieldRule) error {
+	parent, name := doc, rule.path
+	if i := strings.LastIndexByte(rule.path, '.'); i >= 0 {
+		parent, name = lookupObject(doc, strings.Split(rule.path[:i], ".")), rule.path[i+1:]
+	}
+	v, ok := parent[name]
+	if !ok {
+		return nil
+	}
+	if rule.action == DropField {
+		delete(parent, name)
+		return nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		s = string(b)
+	}
+	if strings.HasPrefix(s, encryptedFieldPrefix) {
+		return nil
+	}
+	if rule.action == TokenizeFieldFormat {
+		parent[name] = d.tok.FormatToken(s)
+	} else {
+		parent[name] = d.tok.Token(s)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package kimberlite
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTokenizer(t *testing.T) {
+	if _, err := NewTokenizer(make([]byte, 16)); err == nil {
+		t.Fatal("expected error for short key")
+	}
+	tok, err := NewTokenizer(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := NewTokenizer(bytes.Repeat([]byte{2}, 32))
+
+	a := tok.Token("alice@example.com")
+	if a != tok.Token("alice@example.com") || a == tok.Token("bob@example.com") || a == other.Token("alice@example.com") {
+		t.Fatal("tokens not deterministic per key and value")
+	}
+	if !regexp.MustCompile(`^tok_[0-9a-f]{32}$`).MatchString(a) {
+		t.Fatalf("token %q", a)
+	}
+
+	ssn := tok.FormatToken("457-55-5462")
+	if ssn == "457-55-5462" || !regexp.MustCompile(`^\d{3}-\d{2}-\d{4}$`).MatchString(ssn) || ssn != tok.FormatToken("457-55-5462") {
+		t.Fatalf("format token %q", ssn)
+	}
+	if got := tok.FormatToken("Ab é-9"); !regexp.MustCompile(`^[A-Z][a-z] é-\d$`).MatchString(got) {
+		t.Fatalf("format token %q", got)
+	}
+}
+
+func TestDeidentifyEvent(t *testing.T) {
+	tok, _ := NewTokenizer(bytes.Repeat([]byte{1}, 32))
+	d := NewDeidentifier(tok,
+		DeidentifyField("Admitted", "patient.name", TokenizeField),
+		DeidentifyField("Admitted", "patient.mrn", TokenizeFieldFormat),
+		DeidentifyField("", "notes", DropField),
+		DeidentifyMetadata("email"),
+		DeidentifyPII(nil))
+
+	env := Envelope{
+		ID:       "e1",
+		Type:     "Admitted",
+		Metadata: map[string]string{metaSubject: "p-1", "email": "a@b.c", "traceparent": "tp"},
+		Data:     []byte(`{"patient":{"name":"Alice","mrn":12345},"notes":"x","bed":3,"memo":"SSN 457-55-5462"}`),
+	}
+	data, _ := env.Marshal()
+	out, err := d.DeidentifyEvent(Event{StreamID: 1, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := out.Envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Metadata[metaSubject] != tok.Token("p-1") || got.Metadata["email"] != tok.Token("a@b.c") || got.Metadata["traceparent"] != "tp" {
+		t.Fatalf("metadata %v", got.Metadata)
+	}
+	if env.Metadata[metaSubject] != "p-1" {
+		t.Fatal("input envelope modified")
+	}
+	doc, err := decodeJSONObject(got.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patient := doc["patient"].(map[string]any)
+	if patient["name"] != tok.Token("Alice") || patient["mrn"] != tok.FormatToken("12345") {
+		t.Fatalf("patient %v", patient)
+	}
+	if _, ok := doc["notes"]; ok {
+		t.Fatal("dropped field kept")
+	}
+	if memo := doc["memo"].(string); strings.Contains(memo, "457-55-5462") || !strings.HasPrefix(memo, "SSN ") {
+		t.Fatalf("memo %q", memo)
+	}
+
+	// Other types get only the rules for every type; bare events too.
+	out, err = d.DeidentifyEvent(Event{Data: []byte(`{"patient":{"name":"Alice"},"notes":"x"}`)})
+	if err != nil || string(out.Data) != `{"patient":{"name":"Alice"}}` {
+		t.Fatalf("bare event %s, %v", out.Data, err)
+	}
+	if out, _ := d.DeidentifyEvent(Event{Data: []byte("call 457-55-5462")}); bytes.Contains(out.Data, []byte("457-55-5462")) {
+		t.Fatalf("PII kept in %q", out.Data)
+	}
+}
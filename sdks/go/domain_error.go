@@ -98,6 +98,8 @@ func MapKimberliteError(err error) *DomainError {
 		return &DomainError{Kind: DomainKindNotFound, Message: err.Error()}
 	case errors.Is(err, ErrPermissionDenied):
 		return &DomainError{Kind: DomainKindForbidden, Message: err.Error()}
+	case errors.Is(err, ErrOffsetMismatch):
+		return &DomainError{Kind: DomainKindConcurrentModification, Message: err.Error()}
 	case errors.Is(err, ErrTimeout):
 		return &DomainError{Kind: DomainKindTimeout, Message: err.Error()}
 	case errors.Is(err, ErrNotConnected), errors.Is(err, ErrConnectionFailed):
@@ -0,0 +1,491 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Client-side encryption seals event payloads with AES-256-GCM before
+// they leave the process, so the server and the wire only ever see
+// ciphertext. A whole payload is sealed as
+//
+//	0x00 'K' 'C' version | key ID length uint16 | key ID | nonce [12] | ciphertext
+//
+// and a sealed JSON field holds the string "kmbenc:" followed by the
+// unpadded base64 of the same frame over the field's JSON value. The
+// additional data binds each frame to its tenant, stream and field, so
+// ciphertext can't be replayed elsewhere. Envelope headers (ID, type,
+// correlation and metadata) stay in plaintext and must not carry
+// protected data.
+
+const encryptionVersion = 1
+
+var encryptedMagic = []byte{0x00, 'K', 'C', encryptionVersion}
+
+const encryptedFieldPrefix = "kmbenc:"
+
+var (
+	// ErrKeyUnavailable is returned by a KeyProvider without the key
+	// asked for.
+	ErrKeyUnavailable = errors.New("kimberlite: encryption key unavailable")
+	// ErrUnknownStreamClass is returned when appending to a stream whose
+	// data class the Encryption, or the client enforcing data classes,
+	// has not been told.
+	ErrUnknownStreamClass = errors.New("kimberlite: data class of stream unknown")
+	// ErrDecrypt is returned for ciphertext that fails authentication.
+	ErrDecrypt = errors.New("kimberlite: decryption failed")
+)
+
+// KeyProvider supplies 32-byte AES-256 keys, e.g. from a KMS.
+type KeyProvider interface {
+	// EncryptionKey returns the current key for data of class.
+	EncryptionKey(ctx context.Context, class DataClass) (keyID string, key []byte, err error)
+	// DecryptionKey returns the key with the given ID, or an error
+	// wrapping ErrKeyUnavailable if the caller may not have it.
+	DecryptionKey(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// Keyring is an in-memory KeyProvider.
+type Keyring struct {
+	mu      sync.RWMutex
+	current map[DataClass]string
+	keys    map[string][]byte
+}
+
+// NewKeyring returns an empty Keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{current: make(map[DataClass]string), keys: make(map[string][]byte)}
+}
+
+// Add adds a key and makes it the current key of class. Keys added
+// earlier stay available for decryption.
+func (k *Keyring) Add(class DataClass, keyID string, key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("kimberlite: encryption key %q must be 32 bytes, got %d", keyID, len(key))
+	}
+	if err := checkKeyID(keyID); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.current[class] = keyID
+	k.keys[keyID] = append([]byte(nil), key...)
+	return nil
+}
+
+// EncryptionKey implements KeyProvider.
+func (k *Keyring) EncryptionKey(_ context.Context, class DataClass) (string, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	id, ok := k.current[class]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: no key for %s data", ErrKeyUnavailable, class)
+	}
+	return id, k.keys[id], nil
+}
+
+// DecryptionKey implements KeyProvider.
+func (k *Keyring) DecryptionKey(_ context.Context, keyID string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrKeyUnavailable, keyID)
+	}
+	return key, nil
+}
+
+// Encryption encrypts the events a client appends to streams of the
+// configured data classes and decrypts them on read. Install it with
+// WithEncryption.
+type Encryption struct {
+	keys     KeyProvider
+	subjects SubjectKeyStore
+	classes  map[DataClass]bool
+	fields   map[string][]string
+	streams  streamClasses
+}
+
+// EncryptionOption configures an Encryption.
+type EncryptionOption func(*Encryption)
+
+// EncryptClasses sets the data classes whose streams are encrypted. The
+// default is DataClassConfidential and DataClassRestricted.
+func EncryptClasses(classes ...DataClass) EncryptionOption {
+	return func(e *Encryption) {
+		e.classes = make(map[DataClass]bool, len(classes))
+		for _, class := range classes {
+			e.classes[class] = true
+		}
+	}
+}
+
+// EncryptFields encrypts only the given fields of JSON payloads of
+// eventType, leaving the rest readable; payloads of other types are
+// encrypted whole. Fields name object members, with dots for nested
+// ones, e.g. "ssn" or "patient.dob". Absent fields are skipped.
+func EncryptFields(eventType string, fields ...string) EncryptionOption {
+	return func(e *Encryption) {
+		e.fields[eventType] = append(e.fields[eventType], fields...)
+	}
+}
+
+// NewEncryption returns an Encryption using keys, which may be nil if
+// only subject keys are used.
+func NewEncryption(keys KeyProvider, opts ...EncryptionOption) *Encryption {
+	e := &Encryption{
+		keys:    keys,
+		classes: map[DataClass]bool{DataClassConfidential: true, DataClassRestricted: true},
+		fields:  make(map[string][]string),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// SetStreamClass records the data class of a stream. Streams created
+// through the client are recorded automatically; others must be set
+// before appending, or the append fails with ErrUnknownStreamClass
+// rather than risk sending protected data in plaintext.
+func (e *Encryption) SetStreamClass(id StreamID, class DataClass) {
+	e.streams.set(id, class)
+}
+
+// WithEncryption makes the client encrypt appended events and decrypt
+// read ones with e. Events whose key the provider withholds are
+// returned still encrypted; see IsEncrypted.
+func WithEncryption(e *Encryption) Option {
+	return func(c *Client) { c.encryption = e }
+}
+
+// IsEncrypted reports whether data is, or is an envelope whose payload
+// is, encrypted as a whole.
+func IsEncrypted(data []byte) bool {
+	if IsEnvelope(data) {
+		env, err := UnmarshalEnvelope(data)
+		if err != nil {
+			return false
+		}
+		data = env.Data
+	}
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+func (e *Encryption) encryptEvents(ctx context.Context, tenant TenantID, stream StreamID, events [][]byte) ([][]byte, error) {
+	streamClass, streamKnown := e.streams.get(stream)
+	type sealerKey struct {
+		class   DataClass
+		subject string
+	}
+	sealers := make(map[sealerKey]sealer)
+	out := make([][]byte, len(events))
+	for i, data := range events {
+		subject := ""
+		if e.subjects != nil {
+			subject = subjectOf(ctx, data)
+		}
+		class, known := eventClass(data, streamClass, streamKnown)
+		if subject == "" {
+			if !known {
+				return nil, fmt.Errorf("%w: stream %d", ErrUnknownStreamClass, stream)
+			}
+			if !e.classes[class] {
+				out[i] = data
+				continue
+			}
+		} else {
+			class = 0
+		}
+		sk := sealerKey{class: class, subject: subject}
+		s, ok := sealers[sk]
+		if !ok {
+			var err error
+			if s, err = e.sealer(ctx, class, subject); err != nil {
+				return nil, err
+			}
+			sealers[sk] = s
+		}
+		var err error
+		if out[i], err = e.encryptEvent(s.aead, s.keyID, tenant, stream, data); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+type sealer struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// sealer returns the key for subject's events, or for the class if
+// subject is empty.
+func (e *Encryption) sealer(ctx context.Context, class DataClass, subject string) (sealer, error) {
+	var keyID string
+	var key []byte
+	var err error
+	switch {
+	case subject != "":
+		keyID = subjectKeyPrefix + subject
+		key, err = e.subjects.SubjectKey(ctx, subject, true)
+	case e.keys == nil:
+		err = fmt.Errorf("%w: no key for %s data", ErrKeyUnavailable, class)
+	default:
+		keyID, key, err = e.keys.EncryptionKey(ctx, class)
+	}
+	if err != nil {
+		return sealer{}, err
+	}
+	if err := checkKeyID(keyID); err != nil {
+		return sealer{}, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return sealer{}, err
+	}
+	return sealer{keyID: keyID, aead: aead}, nil
+}
+
+func (e *Encryption) decryptionKey(ctx context.Context, keyID string) ([]byte, error) {
+	if subject, ok := strings.CutPrefix(keyID, subjectKeyPrefix); ok && e.subjects != nil {
+		return e.subjects.SubjectKey(ctx, subject, false)
+	}
+	if e.keys == nil {
+		return nil, fmt.Errorf("%w: %q", ErrKeyUnavailable, keyID)
+	}
+	return e.keys.DecryptionKey(ctx, keyID)
+}
+
+func (e *Encryption) encryptEvent(aead cipher.AEAD, keyID string, tenant TenantID, stream StreamID, data []byte) ([]byte, error) {
+	if !IsEnvelope(data) {
+		return seal(aead, keyID, tenant, stream, "", data)
+	}
+	env, err := UnmarshalEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	if fields, ok := e.fields[env.Type]; ok && isJSONContentType(env.Metadata[metaContentType]) {
+		env.Data, err = sealFields(aead, keyID, tenant, stream, env.Data, fields)
+	} else {
+		env.Data, err = seal(aead, keyID, tenant, stream, "", env.Data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return env.Marshal()
+}
+
+func sealFields(aead cipher.AEAD, keyID string, tenant TenantID, stream StreamID, payload []byte, fields []string) ([]byte, error) {
+	doc, err := decodeJSONObject(payload)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: encrypt fields: %w", err)
+	}
+	for _, path := range fields {
+		parent, name := doc, path
+		if i := strings.LastIndexByte(path, '.'); i >= 0 {
+			parent, name = lookupObject(doc, strings.Split(path[:i], ".")), path[i+1:]
+		}
+		v, ok := parent[name]
+		if !ok {
+			continue
+		}
+		plain, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		frame, err := seal(aead, keyID, tenant, stream, path, plain)
+		if err != nil {
+			return nil, err
+		}
+		parent[name] = encryptedFieldPrefix + base64.RawStdEncoding.EncodeToString(frame)
+	}
+	return json.Marshal(doc)
+}
+
+func lookupObject(doc map[string]any, names []string) map[string]any {
+	for _, name := range names {
+		next, ok := doc[name].(map[string]any)
+		if !ok {
+			return nil
+		}
+		doc = next
+	}
+	return doc
+}
+
+func (e *Encryption) decryptEvents(ctx context.Context, tenant TenantID, events []Event) ([]Event, error) {
+	out := make([]Event, len(events))
+	for i, ev := range events {
+		data, err := e.decryptEvent(ctx, tenant, ev.StreamID, ev.Data)
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: stream %d offset %d: %w", ev.StreamID, ev.Offset, err)
+		}
+		ev.Data = data
+		out[i] = ev
+	}
+	return out, nil
+}
+
+func (e *Encryption) decryptEvent(ctx context.Context, tenant TenantID, stream StreamID, data []byte) ([]byte, error) {
+	if !IsEnvelope(data) {
+		return e.open(ctx, tenant, stream, "", data)
+	}
+	env, err := UnmarshalEnvelope(data)
+	if err != nil {
+		return data, nil
+	}
+	var plain []byte
+	switch {
+	case bytes.HasPrefix(env.Data, encryptedMagic):
+		plain, err = e.open(ctx, tenant, stream, "", env.Data)
+	case bytes.Contains(env.Data, []byte(encryptedFieldPrefix)):
+		plain, err = e.openFields(ctx, tenant, stream, env.Data)
+	default:
+		return data, nil
+	}
+	if err != nil || bytes.Equal(plain, env.Data) {
+		return data, err
+	}
+	env.Data = plain
+	return env.Marshal()
+}
+
+// open decrypts a whole-payload frame, returning data unchanged if it
+// is not one or its key is withheld.
+func (e *Encryption) open(ctx context.Context, tenant TenantID, stream StreamID, path string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return data, nil
+	}
+	rest := data[len(encryptedMagic):]
+	if len(rest) < 2 || len(rest) < 2+int(binary.BigEndian.Uint16(rest)) {
+		return nil, ErrDecrypt
+	}
+	keyID := string(rest[2 : 2+int(binary.BigEndian.Uint16(rest))])
+	key, err := e.decryptionKey(ctx, keyID)
+	if errors.Is(err, ErrKeyUnavailable) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[2+len(keyID):]
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], additionalData(keyID, tenant, stream, path))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+func (e *Encryption) openFields(ctx context.Context, tenant TenantID, stream StreamID, payload []byte) ([]byte, error) {
+	doc, err := decodeJSONObject(payload)
+	if err != nil {
+		return payload, nil
+	}
+	changed := false
+	var walk func(obj map[string]any, prefix string) error
+	walk = func(obj map[string]any, prefix string) error {
+		for name, v := range obj {
+			switch v := v.(type) {
+			case map[string]any:
+				if err := walk(v, prefix+name+"."); err != nil {
+					return err
+				}
+			case string:
+				if !strings.HasPrefix(v, encryptedFieldPrefix) {
+					continue
+				}
+				frame, err := base64.RawStdEncoding.DecodeString(v[len(encryptedFieldPrefix):])
+				if err != nil {
+					return ErrDecrypt
+				}
+				plain, err := e.open(ctx, tenant, stream, prefix+name, frame)
+				if err != nil {
+					return err
+				}
+				if bytes.Equal(plain, frame) {
+					continue
+				}
+				dec := json.NewDecoder(bytes.NewReader(plain))
+				dec.UseNumber()
+				var decoded any
+				if err := dec.Decode(&decoded); err != nil {
+					return ErrDecrypt
+				}
+				obj[name], changed = decoded, true
+			}
+		}
+		return nil
+	}
+	if err := walk(doc, ""); err != nil || !changed {
+		return payload, err
+	}
+	return json.Marshal(doc)
+}
+
+func seal(aead cipher.AEAD, keyID string, tenant TenantID, stream StreamID, path string, plain []byte) ([]byte, error) {
+	out := make([]byte, 0, len(encryptedMagic)+2+len(keyID)+aead.NonceSize()+len(plain)+aead.Overhead())
+	out = append(out, encryptedMagic...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(keyID)))
+	out = append(out, keyID...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("kimberlite: encrypt: %w", err)
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, additionalData(keyID, tenant, stream, path)), nil
+}
+
+func additionalData(keyID string, tenant TenantID, stream StreamID, path string) []byte {
+	b := append([]byte(nil), encryptedMagic...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(keyID)))
+	b = append(b, keyID...)
+	b = binary.BigEndian.AppendUint64(b, uint64(tenant))
+	b = binary.BigEndian.AppendUint64(b, uint64(stream))
+	return append(b, path...)
+}
+
+func checkKeyID(keyID string) error {
+	if keyID == "" || len(keyID) > 0xffff {
+		return fmt.Errorf("kimberlite: invalid encryption key ID %q", keyID)
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func decodeJSONObject(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("payload is not a JSON object")
+	}
+	return doc, nil
+}
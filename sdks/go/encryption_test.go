@@ -0,0 +1,161 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryption(t *testing.T) {
+	ctx := context.Background()
+	keys := NewKeyring()
+	if err := keys.Add(DataClassRestricted, "phi-1", bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Add(DataClassRestricted, "short", []byte("short")); err == nil {
+		t.Fatal("expected error for short key")
+	}
+	enc := NewEncryption(keys, EncryptFields("Admitted", "ssn", "patient.dob"))
+	enc.SetStreamClass(1, DataClassRestricted)
+	enc.SetStreamClass(2, DataClassPublic)
+
+	admitted, _ := Envelope{
+		ID:       "e1",
+		Type:     "Admitted",
+		Metadata: map[string]string{metaContentType: "application/json"},
+		Data:     []byte(`{"bed":4,"ssn":"123-45-6789","patient":{"dob":"1970-01-01","mrn":12345678901234567890}}`),
+	}.Marshal()
+	note, _ := Envelope{ID: "e2", Type: "Note", Data: []byte("secret note")}.Marshal()
+	raw := []byte("raw secret")
+
+	sealed, err := enc.encryptEvents(ctx, 7, 1, [][]byte{admitted, note, raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range sealed {
+		for _, secret := range []string{"123-45-6789", "1970-01-01", "secret"} {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Fatalf("event %d leaks %q: %q", i, secret, data)
+			}
+		}
+	}
+	env, _ := UnmarshalEnvelope(sealed[0])
+	if env.Type != "Admitted" || !bytes.Contains(env.Data, []byte(`"bed":4`)) || !strings.Contains(string(env.Data), encryptedFieldPrefix) {
+		t.Fatalf("field encryption: %s", env.Data)
+	}
+	if IsEncrypted(sealed[0]) || !IsEncrypted(sealed[1]) || !IsEncrypted(sealed[2]) {
+		t.Fatal("IsEncrypted")
+	}
+
+	events := make([]Event, len(sealed))
+	for i, data := range sealed {
+		events[i] = Event{StreamID: 1, Offset: Offset(i), Data: data}
+	}
+	opened, err := enc.decryptEvents(ctx, 7, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, _ = UnmarshalEnvelope(opened[0].Data)
+	if !bytes.Contains(env.Data, []byte(`"ssn":"123-45-6789"`)) || !bytes.Contains(env.Data, []byte(`"mrn":12345678901234567890`)) {
+		t.Fatalf("decrypted fields: %s", env.Data)
+	}
+	if env, _ = UnmarshalEnvelope(opened[1].Data); string(env.Data) != "secret note" || env.ID != "e2" {
+		t.Fatalf("decrypted envelope: %+v", env)
+	}
+	if !bytes.Equal(opened[2].Data, raw) {
+		t.Fatalf("decrypted raw: %q", opened[2].Data)
+	}
+
+	// Readers without the key see ciphertext; moved ciphertext fails.
+	other := NewEncryption(NewKeyring())
+	if got, err := other.decryptEvents(ctx, 7, events); err != nil || !bytes.Equal(got[2].Data, sealed[2]) {
+		t.Fatalf("unauthorized read: %v", err)
+	}
+	moved := []Event{{StreamID: 3, Data: sealed[2]}}
+	if _, err := enc.decryptEvents(ctx, 7, moved); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected ErrDecrypt, got %v", err)
+	}
+
+	// Rotated keys still decrypt older events.
+	if err := keys.Add(DataClassRestricted, "phi-2", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := enc.decryptEvents(ctx, 7, events[2:]); err != nil || !bytes.Equal(got[0].Data, raw) {
+		t.Fatalf("after rotation: %v", err)
+	}
+
+	if got, err := enc.encryptEvents(ctx, 7, 2, [][]byte{raw}); err != nil || !bytes.Equal(got[0], raw) {
+		t.Fatalf("public stream: %q, %v", got, err)
+	}
+	if _, err := enc.encryptEvents(ctx, 7, 9, [][]byte{raw}); !errors.Is(err, ErrUnknownStreamClass) {
+		t.Fatalf("expected ErrUnknownStreamClass, got %v", err)
+	}
+	enc.SetStreamClass(4, DataClassConfidential)
+	if _, err := enc.encryptEvents(ctx, 7, 4, [][]byte{raw}); !errors.Is(err, ErrKeyUnavailable) {
+		t.Fatalf("expected ErrKeyUnavailable, got %v", err)
+	}
+}
+
+type countingWrapper struct{ wraps, unwraps int }
+
+func (w *countingWrapper) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	w.wraps++
+	return append([]byte("w"), key...), nil
+}
+
+func (w *countingWrapper) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	w.unwraps++
+	return wrapped[1:], nil
+}
+
+func TestEnvelopeKeys(t *testing.T) {
+	ctx := context.Background()
+	w := &countingWrapper{}
+	keys, err := NewEnvelopeKeys(WithMasterKey("m", w, DataClassRestricted), WithKeyCacheTTL(time.Minute), WithKeyCacheSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	keys.now = func() time.Time { return now }
+
+	id1, key1, err := keys.EncryptionKey(ctx, DataClassRestricted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id2, _, _ := keys.EncryptionKey(ctx, DataClassRestricted); id2 != id1 || w.wraps != 1 {
+		t.Fatalf("data key not reused: %d wraps", w.wraps)
+	}
+	now = now.Add(2 * time.Minute)
+	id3, _, _ := keys.EncryptionKey(ctx, DataClassRestricted)
+	if id3 == id1 || w.wraps != 2 {
+		t.Fatalf("data key not rotated: %d wraps", w.wraps)
+	}
+	// The cache holds one key, so the first needs unwrapping again.
+	if got, err := keys.DecryptionKey(ctx, id1); err != nil || !bytes.Equal(got, key1) || w.unwraps != 1 {
+		t.Fatalf("decrypt rotated key: %d unwraps, %v", w.unwraps, err)
+	}
+
+	if _, err := keys.DecryptionKey(ctx, "other:AAAA"); !errors.Is(err, ErrKeyUnavailable) {
+		t.Fatalf("expected ErrKeyUnavailable, got %v", err)
+	}
+	if _, _, err := keys.EncryptionKey(ctx, DataClassConfidential); !errors.Is(err, ErrKeyUnavailable) {
+		t.Fatalf("expected ErrKeyUnavailable, got %v", err)
+	}
+	if _, err := NewEnvelopeKeys(WithMasterKey("a:b", w)); err == nil {
+		t.Fatal("expected error for invalid master key name")
+	}
+
+	enc := NewEncryption(keys)
+	enc.SetStreamClass(1, DataClassRestricted)
+	sealed, err := enc.encryptEvents(ctx, 1, 1, [][]byte{[]byte("phi")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := enc.decryptEvents(ctx, 1, []Event{{StreamID: 1, Data: sealed[0]}})
+	if err != nil || string(opened[0].Data) != "phi" {
+		t.Fatalf("envelope round trip: %q, %v", opened[0].Data, err)
+	}
+}
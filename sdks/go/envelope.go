@@ -0,0 +1,173 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Envelope is the SDK's event envelope: a payload plus the metadata
+// needed to trace business workflows across services.
+//
+// The server stores event payloads as opaque bytes, so the envelope is
+// a client-side convention. Enveloped events are framed with a short
+// binary prefix that can't start valid JSON or UTF-8 text, which lets
+// readers tell them apart from raw events appended with Append:
+//
+//	0x00 'K' 'E' version | uvarint header length | JSON header | payload
+type Envelope struct {
+	// ID uniquely identifies the event. Generated on append if empty.
+	ID string `json:"id"`
+	// Type names the event, e.g. "PatientAdmitted". Optional.
+	Type string `json:"type,omitempty"`
+	// CorrelationID ties together every event of one business workflow.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// CausationID is the ID of the event (or command) that directly
+	// caused this one.
+	CausationID string `json:"causation_id,omitempty"`
+	// Metadata carries free-form string attributes.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Data is the event payload.
+	Data []byte `json:"-"`
+}
+
+const envelopeVersion = 1
+
+var envelopeMagic = []byte{0x00, 'K', 'E', envelopeVersion}
+
+// ErrInvalidEnvelope is returned when enveloped event data is corrupt.
+var ErrInvalidEnvelope = errors.New("kimberlite: invalid event envelope")
+
+// Marshal encodes the envelope for appending.
+func (e Envelope) Marshal() ([]byte, error) {
+	hdr, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: encode envelope: %w", err)
+	}
+	out := make([]byte, 0, len(envelopeMagic)+binary.MaxVarintLen64+len(hdr)+len(e.Data))
+	out = append(out, envelopeMagic...)
+	out = binary.AppendUvarint(out, uint64(len(hdr)))
+	out = append(out, hdr...)
+	out = append(out, e.Data...)
+	return out, nil
+}
+
+// IsEnvelope reports whether data was written as an Envelope.
+func IsEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, envelopeMagic)
+}
+
+// UnmarshalEnvelope decodes event data. Data that was not written as
+// an envelope is returned as an Envelope carrying only the payload, so
+// streams mixing raw and enveloped events read uniformly.
+func UnmarshalEnvelope(data []byte) (Envelope, error) {
+	if !IsEnvelope(data) {
+		return Envelope{Data: data}, nil
+	}
+	rest := data[len(envelopeMagic):]
+	n, k := binary.Uvarint(rest)
+	if k <= 0 || n > uint64(len(rest)-k) {
+		return Envelope{}, ErrInvalidEnvelope
+	}
+	rest = rest[k:]
+
+	var e Envelope
+	if err := json.Unmarshal(rest[:n], &e); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	e.Data = rest[n:]
+	return e, nil
+}
+
+// Envelope decodes the event's data as an Envelope.
+func (e Event) Envelope() (Envelope, error) {
+	return UnmarshalEnvelope(e.Data)
+}
+
+// NewEventID returns a random RFC 4122 version 4 UUID string.
+func NewEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("kimberlite: crypto/rand unavailable: " + err.Error())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// AppendEnvelopes appends enveloped events to a stream. Missing IDs are
+// generated, and missing correlation and causation IDs are filled from
+// ctx (see WithCorrelationID and ContextFromEnvelope), so events
+// written while handling another event are linked to it automatically.
+//
+// If the client's tracer is a TracePropagator, the current trace
+// context is added to each envelope's metadata.
+//
+// JSON payloads of event types with a registered schema (see
+// CodecRegistry.RegisterSchema) are validated first; if any event
+// fails, nothing is appended.
+func (c *Client) AppendEnvelopes(ctx context.Context, streamID StreamID, envs ...Envelope) (Offset, error) {
+	return c.appendEnvelopes(ctx, streamID, envs, true)
+}
+
+func (c *Client) appendEnvelopes(ctx context.Context, streamID StreamID, envs []Envelope, validate bool) (Offset, error) {
+	reg := c.codecRegistry()
+	correlation, causation := CorrelationFromContext(ctx)
+	events := make([][]byte, len(envs))
+	for i, env := range envs {
+		if validate && env.Type != "" && isJSONContentType(env.Metadata[metaContentType]) {
+			if err := reg.validatePayload(env.Type, env.Data); err != nil {
+				return 0, err
+			}
+		}
+		if env.ID == "" {
+			env.ID = NewEventID()
+		}
+		if env.CorrelationID == "" {
+			env.CorrelationID = correlation
+		}
+		if env.CausationID == "" {
+			env.CausationID = causation
+		}
+		env.Metadata = c.injectTrace(ctx, env.Metadata)
+		b, err := env.Marshal()
+		if err != nil {
+			return 0, err
+		}
+		events[i] = b
+	}
+	return c.AppendContext(ctx, streamID, events...)
+}
+
+// ReadEnvelopes reads events like ReadEventsContext and decodes each
+// one's envelope. The returned slices are index-aligned.
+func (c *Client) ReadEnvelopes(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64) ([]Event, []Envelope, error) {
+	events, err := c.ReadEventsContext(ctx, streamID, from, maxBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	envs := make([]Envelope, len(events))
+	for i, e := range events {
+		env, err := e.Envelope()
+		if err != nil {
+			return nil, nil, fmt.Errorf("kimberlite: event at offset %d: %w", e.Offset, err)
+		}
+		envs[i] = env
+	}
+	return events, envs, nil
+}
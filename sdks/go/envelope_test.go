@@ -0,0 +1,93 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	in := Envelope{
+		ID:            NewEventID(),
+		Type:          "PatientAdmitted",
+		CorrelationID: "wf-1",
+		CausationID:   "cmd-9",
+		Metadata:      map[string]string{"ward": "icu"},
+		Data:          []byte{0x00, 0x01, '{'},
+	}
+	b, err := in.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEnvelope(b) {
+		t.Fatal("marshalled envelope should be detected")
+	}
+	out, err := UnmarshalEnvelope(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != in.ID || out.Type != in.Type || out.CorrelationID != "wf-1" ||
+		out.CausationID != "cmd-9" || out.Metadata["ward"] != "icu" || !bytes.Equal(out.Data, in.Data) {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+
+	if _, err := UnmarshalEnvelope(b[:6]); err == nil {
+		t.Fatal("truncated envelope should fail")
+	}
+}
+
+func TestUnmarshalRawEvent(t *testing.T) {
+	env, err := Event{Data: []byte(`{"raw":true}`)}.Envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.ID != "" || string(env.Data) != `{"raw":true}` {
+		t.Fatalf("raw event should decode as payload-only envelope, got %+v", env)
+	}
+}
+
+func TestNewEventID(t *testing.T) {
+	id := NewEventID()
+	if len(id) != 36 || id[14] != '4' || id == NewEventID() {
+		t.Fatalf("unexpected event ID %q", id)
+	}
+}
+
+func TestCorrelationPropagation(t *testing.T) {
+	ctx := WithAudit(context.Background(), AuditContext{Actor: "u", Reason: "r", CorrelationID: "req-1"})
+	if corr, cause := CorrelationFromContext(ctx); corr != "req-1" || cause != "" {
+		t.Fatalf("audit fallback = %q, %q", corr, cause)
+	}
+
+	started := ContextFromEnvelope(ctx, Envelope{ID: "evt-1"})
+	if corr, cause := CorrelationFromContext(started); corr != "evt-1" || cause != "evt-1" {
+		t.Fatalf("workflow start = %q, %q", corr, cause)
+	}
+
+	next := ContextFromEnvelope(ctx, Envelope{ID: "evt-2", CorrelationID: "wf-7"})
+	if corr, cause := CorrelationFromContext(next); corr != "wf-7" || cause != "evt-2" {
+		t.Fatalf("continuation = %q, %q", corr, cause)
+	}
+}
+
+type metaPropagator struct{ noopTracer }
+
+func (metaPropagator) Inject(_ context.Context, md map[string]string) { md["traceparent"] = "00-x" }
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ *Operation) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+
+func TestInjectTrace(t *testing.T) {
+	orig := map[string]string{"ward": "icu"}
+	c := &Client{tracer: metaPropagator{}}
+	md := c.injectTrace(context.Background(), orig)
+	if md["traceparent"] != "00-x" || md["ward"] != "icu" || len(orig) != 1 {
+		t.Fatalf("injected = %v, original = %v", md, orig)
+	}
+	if md := (&Client{tracer: noopTracer{}}).injectTrace(context.Background(), orig); len(md) != 1 {
+		t.Fatalf("non-propagating tracer changed metadata: %v", md)
+	}
+}
@@ -0,0 +1,218 @@
+package kimberlite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Crypto-shredding erases a data subject from an append-only log
+// without rewriting it: each subject's events are encrypted with a key
+// of their own, and destroying the key leaves the events in place but
+// unreadable. Give the client's Encryption a SubjectKeyStore with
+// WithSubjectKeys, name the subject of the events being appended, and
+// call Erase to forget them:
+//
+//	ctx = kimberlite.WithSubject(ctx, "patient-4711")
+//	_, err = client.AppendValues(ctx, admissions, admitted)
+//	...
+//	err = client.Erase(ctx, "patient-4711")
+//
+// An envelope's "subject_id" metadata names its subject, overriding the
+// context. Subject IDs travel in plaintext in the key IDs of the
+// encrypted events, so they should be opaque identifiers, not personal
+// data themselves.
+
+const (
+	metaSubject      = "subject_id"
+	subjectKeyPrefix = "subject:"
+)
+
+// ErrSubjectErased is returned for the keys of erased subjects. It
+// wraps ErrKeyUnavailable, so reads return erased subjects' events
+// still encrypted.
+var ErrSubjectErased = fmt.Errorf("%w: subject erased", ErrKeyUnavailable)
+
+// SubjectKeyStore holds the per-subject keys of crypto-shredding. It
+// must be mutable storage outside the log, such as a KMS or a database
+// whose deletes are final, so destroyed keys are really gone.
+type SubjectKeyStore interface {
+	// SubjectKey returns subject's 32-byte key, creating it if create is
+	// set and the subject has none. It returns an error wrapping
+	// ErrSubjectErased for erased subjects, and one wrapping
+	// ErrKeyUnavailable for unknown subjects when create is unset.
+	SubjectKey(ctx context.Context, subject string, create bool) ([]byte, error)
+	// DestroySubjectKey irrevocably destroys subject's key. Later
+	// appends for the subject fail rather than create a new key.
+	DestroySubjectKey(ctx context.Context, subject string) error
+}
+
+// MemorySubjectKeys is an in-memory SubjectKeyStore, for tests.
+type MemorySubjectKeys struct {
+	mu     sync.Mutex
+	keys   map[string][]byte
+	erased map[string]bool
+}
+
+var _ SubjectKeyStore = (*MemorySubjectKeys)(nil)
+
+// NewMemorySubjectKeys returns an empty MemorySubjectKeys.
+func NewMemorySubjectKeys() *MemorySubjectKeys {
+	return &MemorySubjectKeys{keys: make(map[string][]byte), erased: make(map[string]bool)}
+}
+
+// SubjectKey implements SubjectKeyStore.
+func (m *MemorySubjectKeys) SubjectKey(_ context.Context, subject string, create bool) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.erased[subject] {
+		return nil, fmt.Errorf("%w: %q", ErrSubjectErased, subject)
+	}
+	if key, ok := m.keys[subject]; ok {
+		return key, nil
+	}
+	if !create {
+		return nil, fmt.Errorf("%w: no key for subject %q", ErrKeyUnavailable, subject)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("kimberlite: generate subject key: %w", err)
+	}
+	m.keys[subject] = key
+	return key, nil
+}
+
+// DestroySubjectKey implements SubjectKeyStore.
+func (m *MemorySubjectKeys) DestroySubjectKey(_ context.Context, subject string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if key, ok := m.keys[subject]; ok {
+		clear(key)
+		delete(m.keys, subject)
+	}
+	m.erased[subject] = true
+	return nil
+}
+
+// WithSubjectKeys encrypts the events of named subjects with their own
+// keys from store, whatever their stream's data class.
+func WithSubjectKeys(store SubjectKeyStore) EncryptionOption {
+	return func(e *Encryption) { e.subjects = store }
+}
+
+type subjectKey struct{}
+
+// WithSubject returns a derived context whose appended events are
+// encrypted with the key of subject.
+func WithSubject(parent context.Context, subject string) context.Context {
+	return context.WithValue(parent, subjectKey{}, subject)
+}
+
+// subjectOf returns the subject of event data: its envelope's, or
+// else ctx's.
+func subjectOf(ctx context.Context, data []byte) string {
+	if IsEnvelope(data) {
+		if env, err := UnmarshalEnvelope(data); err == nil && env.Metadata[metaSubject] != "" {
+			return env.Metadata[metaSubject]
+		}
+	}
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+// ErrNoSubjectKeys is returned by Erase on a client whose encryption
+// has no SubjectKeyStore.
+var ErrNoSubjectKeys = errors.New("kimberlite: no subject key store configured")
+
+// ErasureLog is the server's compliance log of erasure requests. Erase
+// records every erasure in it. Backends implementing it serve Erase;
+// with other Backends Erase fails with ErrNotConnected, like the rest
+// of the server's administration API.
+type ErasureLog interface {
+	// RequestErasure opens an erasure request for subject and returns
+	// its ID.
+	RequestErasure(subject string) (requestID string, err error)
+	// CompleteErasure completes the request requestID.
+	CompleteErasure(requestID string) error
+}
+
+// Erase destroys subject's key, making every event written for the
+// subject permanently unreadable while the log itself stays intact. It
+// fails with an error wrapping ErrLegalHold while the subject is under
+// legal hold, and with one wrapping ErrNoLegalHoldStore on a client
+// without a LegalHoldStore, which cannot tell.
+//
+// The erasure is recorded in the server's compliance log: Erase opens
+// an erasure request before destroying the key and completes it after.
+// If the request cannot be opened the key is kept; if it cannot be
+// completed the key is gone, and the error names the request left open.
+func (c *Client) Erase(ctx context.Context, subject string) error {
+	if err := c.checkWritable("subject erasure"); err != nil {
+		return err
+	}
+	if c.encryption == nil || c.encryption.subjects == nil {
+		return ErrNoSubjectKeys
+	}
+	if subject == "" {
+		return errors.New("kimberlite: empty subject")
+	}
+	if err := c.CheckDisposal(ctx, SubjectHold(subject)); err != nil {
+		return err
+	}
+	requestID, err := c.requestErasure(ctx, subject)
+	if err != nil {
+		return fmt.Errorf("kimberlite: erase subject %q: %w", subject, err)
+	}
+	if err := c.encryption.subjects.DestroySubjectKey(ctx, subject); err != nil {
+		return fmt.Errorf("kimberlite: erase subject %q: %w", subject, err)
+	}
+	if err := c.completeErasure(ctx, requestID); err != nil {
+		return fmt.Errorf("kimberlite: erase subject %q: key destroyed, but erasure request %s is not complete: %w", subject, requestID, err)
+	}
+	return nil
+}
+
+func (c *Client) requestErasure(ctx context.Context, subject string) (string, error) {
+	var requestID string
+	err := c.call(ctx, &Operation{Name: OpRequestErasure}, func(context.Context) error {
+		if c.backend != nil {
+			log, ok := c.backend.(ErasureLog)
+			if !ok {
+				return ErrNotConnected
+			}
+			id, err := log.RequestErasure(subject)
+			requestID = id
+			return err
+		}
+		raw, err := ffiErasureRequest(c.kmbHandle, subject)
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return fmt.Errorf("kimberlite: decode erasure request: %w", err)
+		}
+		requestID = resp.RequestID
+		return nil
+	})
+	return requestID, err
+}
+
+func (c *Client) completeErasure(ctx context.Context, requestID string) error {
+	return c.call(ctx, &Operation{Name: OpCompleteErasure}, func(context.Context) error {
+		if c.backend != nil {
+			log, ok := c.backend.(ErasureLog)
+			if !ok {
+				return ErrNotConnected
+			}
+			return log.CompleteErasure(requestID)
+		}
+		_, err := ffiErasureComplete(c.kmbHandle, requestID)
+		return err
+	})
+}
@@ -0,0 +1,92 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCryptoShredding(t *testing.T) {
+	ctx := context.Background()
+	subjects := NewMemorySubjectKeys()
+	enc := NewEncryption(nil, WithSubjectKeys(subjects))
+	erasures := &erasureBackend{}
+	c := &Client{encryption: enc, backend: erasures, holds: NoLegalHolds}
+
+	tagged, _ := Envelope{ID: "e2", Metadata: map[string]string{metaSubject: "p-2"}, Data: []byte("bob")}.Marshal()
+	// Subject streams need no registered class.
+	sealed, err := enc.encryptEvents(WithSubject(ctx, "p-1"), 1, 9, [][]byte{[]byte("alice"), tagged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed[0]) || !IsEncrypted(sealed[1]) {
+		t.Fatal("subject events not encrypted")
+	}
+	if _, err := enc.encryptEvents(ctx, 1, 9, [][]byte{[]byte("anon")}); !errors.Is(err, ErrUnknownStreamClass) {
+		t.Fatalf("expected ErrUnknownStreamClass, got %v", err)
+	}
+
+	events := []Event{{StreamID: 9, Offset: 0, Data: sealed[0]}, {StreamID: 9, Offset: 1, Data: sealed[1]}}
+	opened, err := enc.decryptEvents(ctx, 1, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, _ := UnmarshalEnvelope(opened[1].Data)
+	if string(opened[0].Data) != "alice" || string(env.Data) != "bob" {
+		t.Fatalf("decrypted: %q, %q", opened[0].Data, env.Data)
+	}
+
+	// Without an erasure log to record it in, the key is kept.
+	if err := (&Client{encryption: enc, backend: &blockingBackend{}, holds: NoLegalHolds}).Erase(ctx, "p-1"); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+	if opened, _ := enc.decryptEvents(ctx, 1, events); string(opened[0].Data) != "alice" {
+		t.Fatal("key destroyed without an erasure request")
+	}
+
+	if err := c.Erase(ctx, "p-1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(erasures.requested) != 1 || erasures.requested[0] != "p-1" || len(erasures.completed) != 1 || erasures.completed[0] != "erasure-1" {
+		t.Fatalf("erasure log: requested %v, completed %v", erasures.requested, erasures.completed)
+	}
+	opened, err = enc.decryptEvents(ctx, 1, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened[0].Data, sealed[0]) {
+		t.Fatal("erased subject's event decrypted")
+	}
+	if env, _ := UnmarshalEnvelope(opened[1].Data); string(env.Data) != "bob" {
+		t.Fatal("other subject affected by erasure")
+	}
+	if _, err := enc.encryptEvents(WithSubject(ctx, "p-1"), 1, 9, [][]byte{[]byte("again")}); !errors.Is(err, ErrSubjectErased) {
+		t.Fatalf("expected ErrSubjectErased, got %v", err)
+	}
+
+	if err := (&Client{}).Erase(ctx, "p-2"); !errors.Is(err, ErrNoSubjectKeys) {
+		t.Fatalf("expected ErrNoSubjectKeys, got %v", err)
+	}
+	// Without a legal hold store, holds cannot be checked.
+	if err := (&Client{encryption: enc, backend: erasures}).Erase(ctx, "p-2"); !errors.Is(err, ErrNoLegalHoldStore) {
+		t.Fatalf("expected ErrNoLegalHoldStore, got %v", err)
+	}
+}
+
+// erasureBackend is a Backend keeping only an erasure log.
+type erasureBackend struct {
+	Backend
+	requested, completed []string
+}
+
+func (b *erasureBackend) RequestErasure(subject string) (string, error) {
+	b.requested = append(b.requested, subject)
+	return fmt.Sprintf("erasure-%d", len(b.requested)), nil
+}
+
+func (b *erasureBackend) CompleteErasure(requestID string) error {
+	b.completed = append(b.completed, requestID)
+	return nil
+}
@@ -16,17 +16,33 @@ var (
 	// ErrStreamNotFound is returned when a stream does not exist.
 	ErrStreamNotFound = errors.New("kimberlite: stream not found")
 
+	// ErrTenantNotFound is returned when a tenant is not registered.
+	ErrTenantNotFound = errors.New("kimberlite: tenant not found")
+
 	// ErrTenantRequired is returned when a tenant ID is required but not provided.
 	ErrTenantRequired = errors.New("kimberlite: tenant ID required")
 
+	// ErrAuthFailed is returned when the server rejects the client's credentials.
+	ErrAuthFailed = errors.New("kimberlite: authentication failed")
+
 	// ErrPermissionDenied is returned when the operation is not authorized.
 	ErrPermissionDenied = errors.New("kimberlite: permission denied")
 
+	// ErrOffsetMismatch is returned by AppendExpected when the stream is
+	// not at the expected offset: another writer appended first.
+	ErrOffsetMismatch = errors.New("kimberlite: stream offset mismatch")
+
 	// ErrTimeout is returned when an operation exceeds its deadline.
 	ErrTimeout = errors.New("kimberlite: operation timed out")
 
 	// ErrFFIUnavailable is returned when the native FFI library is not loaded.
 	ErrFFIUnavailable = errors.New("kimberlite: FFI library not available (CGo required)")
+
+	// ErrNullValue is returned when a typed accessor is used on a NULL value.
+	ErrNullValue = errors.New("kimberlite: value is NULL")
+
+	// ErrTypeMismatch is returned when a value cannot be converted to the requested type.
+	ErrTypeMismatch = errors.New("kimberlite: type mismatch")
 )
 
 // KimberliteError wraps an error with additional context from the server.
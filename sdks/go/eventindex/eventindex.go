@@ -0,0 +1,172 @@
+// Package eventindex finds events by the values of their envelope
+// metadata, such as every event of an order across streams, without
+// scanning the streams.
+//
+// The server has no index of event metadata, so an Index keeps one in
+// an SQL table, in the application's database or in Kimberlite through
+// the sqldriver package. An Index is a projection: a projection Runner
+// feeds it the events of the streams to index, and FindEvents looks up
+// the index and reads the matching events:
+//
+//	ix := eventindex.New(db, "event_metadata", []string{"order_id"})
+//	err := ix.CreateTable(ctx)
+//	runner := projection.NewRunner(client, "event_metadata", ix, streams,
+//	    projection.WithCheckpoints(connect.NewSQLCheckpoints(db, "event_metadata_positions")))
+//	go runner.Run(ctx)
+//
+//	events, err := ix.FindEvents(ctx, client, "order_id", "o-17")
+//
+// The index is as current as the runner: events appended since its
+// last batch are not found yet.
+package eventindex
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+	"github.com/kimberlitedb/kimberlite-go/internal/sqlident"
+)
+
+// DB is the database holding an index. *sql.DB implements it.
+type DB interface {
+	connect.SQLDB
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Index indexes events by the values of metadata keys. Its table has
+// text meta_key and meta_value columns and bigint stream and
+// event_offset columns, one row per indexed key of each event.
+type Index struct {
+	db          DB
+	table       string
+	keys        []string
+	placeholder func(n int) string
+	readBytes   uint64
+}
+
+// Option configures an Index.
+type Option func(*Index)
+
+// WithPlaceholder sets how Index marks the parameters of its
+// statements, for databases such as MySQL that take "?" instead of the
+// default $1, $2, .... It does not reach the runner's checkpoints,
+// which take connect.WithPlaceholder.
+func WithPlaceholder(f func(n int) string) Option {
+	return func(ix *Index) { ix.placeholder = f }
+}
+
+// WithReadBytes sets the maxBytes of the read of each event found. It
+// must be at least the size of the largest event. The default is
+// 64 KiB.
+func WithReadBytes(n uint64) Option {
+	return func(ix *Index) { ix.readBytes = n }
+}
+
+// New returns the index in table of the metadata keys. Table names
+// cannot be bound as parameters, so New panics if table is anything
+// but an identifier, optionally qualified by a schema.
+func New(db DB, table string, keys []string, opts ...Option) *Index {
+	if err := sqlident.Check("index table", table); err != nil {
+		panic("eventindex: " + err.Error())
+	}
+	ix := &Index{
+		db:          db,
+		table:       table,
+		keys:        append([]string(nil), keys...),
+		placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		readBytes:   64 << 10,
+	}
+	for _, opt := range opts {
+		opt(ix)
+	}
+	return ix
+}
+
+// CreateTable creates the index table unless it exists.
+func (ix *Index) CreateTable(ctx context.Context) error {
+	_, err := ix.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+ix.table+
+		" (meta_key TEXT NOT NULL, meta_value TEXT NOT NULL, stream BIGINT NOT NULL, event_offset BIGINT NOT NULL,"+
+		" PRIMARY KEY (meta_key, meta_value, stream, event_offset))")
+	return err
+}
+
+// Handle indexes an event, replacing its rows if it was indexed before.
+// Events that are not envelopes, or have none of the keys, are not
+// indexed. It implements projection.Projection.
+func (ix *Index) Handle(ctx context.Context, ev kimberlite.Event) error {
+	if !kimberlite.IsEnvelope(ev.Data) {
+		return nil
+	}
+	env, err := ev.Envelope()
+	if err != nil {
+		return err
+	}
+	p := ix.placeholder
+	stream, offset := int64(ev.StreamID), int64(ev.Offset)
+	if _, err := ix.db.ExecContext(ctx, "DELETE FROM "+ix.table+" WHERE stream = "+p(1)+" AND event_offset = "+p(2), stream, offset); err != nil {
+		return err
+	}
+	for _, key := range ix.keys {
+		value, ok := env.Metadata[key]
+		if !ok {
+			continue
+		}
+		_, err := ix.db.ExecContext(ctx, "INSERT INTO "+ix.table+" (meta_key, meta_value, stream, event_offset) VALUES ("+p(1)+", "+p(2)+", "+p(3)+", "+p(4)+")",
+			key, value, stream, offset)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset empties the index, for projection.Runner.Reset and Rebuild.
+func (ix *Index) Reset(ctx context.Context) error {
+	_, err := ix.db.ExecContext(ctx, "DELETE FROM "+ix.table)
+	return err
+}
+
+// FindEvents returns the indexed events whose metadata key has value,
+// ordered by stream and offset, read from source.
+func (ix *Index) FindEvents(ctx context.Context, source connect.EventSource, key, value string) ([]kimberlite.Event, error) {
+	p := ix.placeholder
+	rows, err := ix.db.QueryContext(ctx, "SELECT stream, event_offset FROM "+ix.table+
+		" WHERE meta_key = "+p(1)+" AND meta_value = "+p(2)+" ORDER BY stream, event_offset", key, value)
+	if err != nil {
+		return nil, err
+	}
+	type ref struct {
+		stream kimberlite.StreamID
+		offset kimberlite.Offset
+	}
+	var refs []ref
+	for rows.Next() {
+		var stream, offset int64
+		if err := rows.Scan(&stream, &offset); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		refs = append(refs, ref{kimberlite.StreamID(stream), kimberlite.Offset(offset)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	events := make([]kimberlite.Event, 0, len(refs))
+	for _, r := range refs {
+		read, err := source.ReadEventsContext(ctx, r.stream, r.offset, ix.readBytes)
+		if err != nil {
+			return events, err
+		}
+		if len(read) == 0 || read[0].Offset != r.offset {
+			return events, fmt.Errorf("kimberlite: indexed event at stream %d offset %d not read", r.stream, r.offset)
+		}
+		events = append(events, read[0])
+	}
+	return events, nil
+}
@@ -0,0 +1,120 @@
+package eventindex
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/internal/sqltest"
+)
+
+// indexTable answers the statements of Index, holding its rows of
+// meta_key, meta_value, stream and event_offset.
+type indexTable struct{ rows [][]driver.Value }
+
+func (tb *indexTable) Exec(query string, args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO idx "):
+		tb.rows = append(tb.rows, args)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "DELETE FROM idx WHERE stream = $1 AND event_offset = $2"):
+		kept := tb.rows[:0]
+		for _, r := range tb.rows {
+			if r[2] != args[0] || r[3] != args[1] {
+				kept = append(kept, r)
+			}
+		}
+		tb.rows = kept
+		return driver.RowsAffected(1), nil
+	case query == "DELETE FROM idx":
+		tb.rows = nil
+		return driver.RowsAffected(1), nil
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (tb *indexTable) Query(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+	if !strings.HasPrefix(query, "SELECT stream, event_offset FROM idx WHERE meta_key = $1 AND meta_value = $2") {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	var rows [][]driver.Value
+	for _, r := range tb.rows {
+		if r[0] == args[0] && r[1] == args[1] {
+			rows = append(rows, []driver.Value{r[2], r[3]})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a[0] != b[0] {
+			return a[0].(int64) < b[0].(int64)
+		}
+		return a[1].(int64) < b[1].(int64)
+	})
+	return []string{"stream", "event_offset"}, rows, nil
+}
+
+type fakeLog map[kimberlite.StreamID][]kimberlite.Event
+
+func (l fakeLog) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, _ uint64) ([]kimberlite.Event, error) {
+	if int(from) >= len(l[id]) {
+		return nil, nil
+	}
+	return l[id][from:], nil
+}
+
+func (l fakeLog) append(t *testing.T, id kimberlite.StreamID, meta map[string]string) kimberlite.Event {
+	t.Helper()
+	data, err := kimberlite.Envelope{ID: kimberlite.NewEventID(), Metadata: meta}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := kimberlite.Event{StreamID: id, Offset: kimberlite.Offset(len(l[id])), Data: data}
+	l[id] = append(l[id], ev)
+	return ev
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	tb := &indexTable{}
+	db := sqltest.Open(t, tb)
+
+	log := fakeLog{}
+	ix := New(db, "idx", []string{"order_id", "ward"})
+	for _, ev := range []kimberlite.Event{
+		log.append(t, 2, map[string]string{"order_id": "o-1", "ward": "4"}),
+		log.append(t, 1, map[string]string{"order_id": "o-2"}),
+		log.append(t, 1, map[string]string{"order_id": "o-1"}),
+		log.append(t, 1, nil),
+		{StreamID: 3, Data: []byte("raw")},
+	} {
+		if err := ix.Handle(ctx, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Redelivery does not duplicate rows.
+	if err := ix.Handle(ctx, log[2][0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(tb.rows) != 4 {
+		t.Fatalf("%d rows", len(tb.rows))
+	}
+
+	events, err := ix.FindEvents(ctx, log, "order_id", "o-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].StreamID != 1 || events[0].Offset != 1 || events[1].StreamID != 2 {
+		t.Fatalf("found %+v", events)
+	}
+	if events, _ := ix.FindEvents(ctx, log, "order_id", "o-9"); len(events) != 0 {
+		t.Fatalf("found %d events of an unknown order", len(events))
+	}
+
+	if err := ix.Reset(ctx); err != nil || len(tb.rows) != 0 {
+		t.Fatalf("reset: %d rows, %v", len(tb.rows), err)
+	}
+}
@@ -0,0 +1,255 @@
+// Package eventsourcing loads and saves event-sourced aggregates kept
+// in Kimberlite streams, one stream per aggregate.
+//
+// A Repository replays a stream's envelopes into a new aggregate, lets
+// the caller decide and record new events, and appends them with the
+// stream's expected offset, so a concurrent writer's events are never
+// overwritten or interleaved. Update runs the whole load, decide, save
+// loop and retries it on conflicts:
+//
+//	repo := eventsourcing.NewRepository(client, func() *Patient { return &Patient{} })
+//	_, err := repo.Update(ctx, stream, func(p *eventsourcing.Root[*Patient]) error {
+//	    if p.Aggregate.Discharged {
+//	        return ErrAlreadyDischarged
+//	    }
+//	    return p.Record(kimberlite.Envelope{Type: "PatientDischarged", Data: body})
+//	})
+//
+// Long-lived aggregates are loaded faster with WithSnapshots, which
+// saves their state every few events and replays only the events after
+// the latest snapshot.
+package eventsourcing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Aggregate is an entity whose state is the fold of its events.
+type Aggregate interface {
+	// Apply folds one event into the state. It is called for the
+	// stream's events on load and for events recorded with
+	// Root.Record.
+	Apply(env kimberlite.Envelope) error
+}
+
+// EventStore reads and conditionally appends events.
+// *kimberlite.Client implements it.
+type EventStore interface {
+	ReadEventsContext(ctx context.Context, streamID kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error)
+	AppendExpected(ctx context.Context, streamID kimberlite.StreamID, expected kimberlite.Offset, events ...[]byte) (kimberlite.Offset, error)
+}
+
+// Root is an aggregate loaded from its stream, with the events recorded
+// since.
+type Root[T Aggregate] struct {
+	Aggregate T
+	Stream    kimberlite.StreamID
+	// Version is the number of the stream's events applied, the offset
+	// the next saved event will have.
+	Version kimberlite.Offset
+
+	pending []kimberlite.Envelope
+}
+
+// Record applies envs to the aggregate and queues them for Save. If
+// one fails to apply, it and the following ones are not queued.
+func (r *Root[T]) Record(envs ...kimberlite.Envelope) error {
+	for _, env := range envs {
+		if err := r.Aggregate.Apply(env); err != nil {
+			return err
+		}
+		r.pending = append(r.pending, env)
+	}
+	return nil
+}
+
+// Uncommitted returns the events recorded and not yet saved.
+func (r *Root[T]) Uncommitted() []kimberlite.Envelope {
+	return r.pending
+}
+
+// A RetryPolicy returns how long to wait before retrying after the
+// attempt-th failed attempt, counting from 1, or false to give up.
+type RetryPolicy func(attempt int, err error) (time.Duration, bool)
+
+// NoRetry gives up on the first conflict.
+func NoRetry(int, error) (time.Duration, bool) { return 0, false }
+
+// Backoff retries attempts-1 times, waiting base doubled after each
+// attempt, capped at limit, with up to 50% jitter so that competing
+// writers spread out.
+func Backoff(attempts int, base, limit time.Duration) RetryPolicy {
+	return func(attempt int, _ error) (time.Duration, bool) {
+		if attempt >= attempts {
+			return 0, false
+		}
+		d := limit
+		if shift := attempt - 1; shift < 32 && base<<shift < limit {
+			d = base << shift
+		}
+		return d/2 + time.Duration(rand.Int63n(int64(d/2)+1)), true
+	}
+}
+
+// DefaultRetry is the retry policy of repositories without WithRetry:
+// five attempts, from 10ms up to 1s apart.
+var DefaultRetry = Backoff(5, 10*time.Millisecond, time.Second)
+
+// Option configures a Repository.
+type Option func(*config)
+
+type config struct {
+	retry    RetryPolicy
+	maxBytes uint64
+
+	snapshots     SnapshotStore
+	snapshotEvery kimberlite.Offset
+}
+
+// WithRetry sets the policy of Update for conflicting saves.
+func WithRetry(p RetryPolicy) Option {
+	return func(c *config) { c.retry = p }
+}
+
+// WithReadBatchBytes sets the read size used to load streams. The
+// default is 1 MiB.
+func WithReadBatchBytes(n uint64) Option {
+	return func(c *config) { c.maxBytes = n }
+}
+
+// Repository loads and saves aggregates of type T. It is safe for
+// concurrent use.
+type Repository[T Aggregate] struct {
+	store EventStore
+	new   func() T
+	cfg   config
+}
+
+// NewRepository returns a repository of the aggregates in store,
+// created empty by newAggregate before events are applied.
+func NewRepository[T Aggregate](store EventStore, newAggregate func() T, opts ...Option) *Repository[T] {
+	r := &Repository[T]{store: store, new: newAggregate, cfg: config{retry: DefaultRetry, maxBytes: 1 << 20}}
+	for _, opt := range opts {
+		opt(&r.cfg)
+	}
+	return r
+}
+
+// Load replays stream into a new aggregate, starting from its latest
+// snapshot if the repository has WithSnapshots.
+func (r *Repository[T]) Load(ctx context.Context, stream kimberlite.StreamID) (*Root[T], error) {
+	root := &Root[T]{Aggregate: r.new(), Stream: stream}
+	if r.cfg.snapshots != nil {
+		if err := r.restore(ctx, root); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.replay(ctx, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// replay applies the stream's events from root.Version on.
+func (r *Repository[T]) replay(ctx context.Context, root *Root[T]) error {
+	for {
+		events, err := r.store.ReadEventsContext(ctx, root.Stream, root.Version, r.cfg.maxBytes)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		for _, ev := range events {
+			if ev.Offset != root.Version {
+				return fmt.Errorf("kimberlite: stream %d: expected offset %d, read %d", root.Stream, root.Version, ev.Offset)
+			}
+			env, err := ev.Envelope()
+			if err != nil {
+				return fmt.Errorf("kimberlite: stream %d offset %d: %w", root.Stream, ev.Offset, err)
+			}
+			if err := root.Aggregate.Apply(env); err != nil {
+				return fmt.Errorf("kimberlite: apply stream %d offset %d: %w", root.Stream, ev.Offset, err)
+			}
+			root.Version++
+		}
+	}
+}
+
+// Save appends root's uncommitted events if no other events were
+// appended to its stream since it was loaded, and fails with an error
+// wrapping kimberlite.ErrOffsetMismatch otherwise. Missing event IDs
+// are generated and missing correlation and causation IDs are taken
+// from ctx.
+func (r *Repository[T]) Save(ctx context.Context, root *Root[T]) error {
+	if len(root.pending) == 0 {
+		return nil
+	}
+	correlation, causation := kimberlite.CorrelationFromContext(ctx)
+	events := make([][]byte, len(root.pending))
+	for i, env := range root.pending {
+		if env.ID == "" {
+			env.ID = kimberlite.NewEventID()
+		}
+		if env.CorrelationID == "" {
+			env.CorrelationID = correlation
+		}
+		if env.CausationID == "" {
+			env.CausationID = causation
+		}
+		data, err := env.Marshal()
+		if err != nil {
+			return err
+		}
+		events[i] = data
+	}
+	if _, err := r.store.AppendExpected(ctx, root.Stream, root.Version, events...); err != nil {
+		return err
+	}
+	from := root.Version
+	root.Version += kimberlite.Offset(len(events))
+	root.pending = nil
+	r.snapshot(ctx, root, from)
+	return nil
+}
+
+// Update loads stream, calls decide to record events, and saves them.
+// When the save conflicts with another writer, it reloads the
+// aggregate and calls decide again, as the retry policy allows. decide
+// may be called several times and must not have other side effects;
+// an error from it is returned without saving.
+func (r *Repository[T]) Update(ctx context.Context, stream kimberlite.StreamID, decide func(*Root[T]) error) (*Root[T], error) {
+	for attempt := 1; ; attempt++ {
+		root, err := r.Load(ctx, stream)
+		if err != nil {
+			return nil, err
+		}
+		if err := decide(root); err != nil {
+			return nil, err
+		}
+		err = r.Save(ctx, root)
+		if !errors.Is(err, kimberlite.ErrOffsetMismatch) {
+			if err != nil {
+				return nil, err
+			}
+			return root, nil
+		}
+		wait, ok := r.cfg.retry(attempt, err)
+		if !ok {
+			return nil, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package eventsourcing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/kimberlitetest"
+)
+
+type memStore struct {
+	events map[kimberlite.StreamID][][]byte
+	// race appends an event of another writer before the next append.
+	race int
+}
+
+func (m *memStore) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, _ uint64) ([]kimberlite.Event, error) {
+	var out []kimberlite.Event
+	for i := int(from); i < len(m.events[id]); i++ {
+		out = append(out, kimberlite.Event{StreamID: id, Offset: kimberlite.Offset(i), Data: m.events[id][i]})
+	}
+	return out, nil
+}
+
+func (m *memStore) AppendExpected(_ context.Context, id kimberlite.StreamID, expected kimberlite.Offset, events ...[]byte) (kimberlite.Offset, error) {
+	if m.race > 0 {
+		m.race--
+		data, _ := kimberlite.Envelope{Type: "Added", Data: []byte("100")}.Marshal()
+		m.events[id] = append(m.events[id], data)
+	}
+	if int(expected) != len(m.events[id]) {
+		return 0, fmt.Errorf("%w: expected %d, stream at %d", kimberlite.ErrOffsetMismatch, expected, len(m.events[id]))
+	}
+	m.events[id] = append(m.events[id], events...)
+	return expected, nil
+}
+
+type counter struct{ total int }
+
+func (c *counter) Apply(env kimberlite.Envelope) error {
+	n, err := strconv.Atoi(string(env.Data))
+	if err != nil {
+		return err
+	}
+	c.total += n
+	return nil
+}
+
+func add(n int) kimberlite.Envelope {
+	return kimberlite.Envelope{Type: "Added", Data: []byte(strconv.Itoa(n))}
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{events: map[kimberlite.StreamID][][]byte{}}
+	repo := NewRepository(store, func() *counter { return &counter{} }, WithRetry(Backoff(3, time.Millisecond, time.Millisecond)))
+
+	root, err := repo.Load(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Record(add(2), add(3)); err != nil {
+		t.Fatal(err)
+	}
+	if root.Aggregate.total != 5 || len(root.Uncommitted()) != 2 {
+		t.Fatalf("total %d, %d uncommitted", root.Aggregate.total, len(root.Uncommitted()))
+	}
+	if err := repo.Save(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if root.Version != 2 || len(root.Uncommitted()) != 0 {
+		t.Fatalf("version %d, %d uncommitted", root.Version, len(root.Uncommitted()))
+	}
+
+	// A save of a stale root conflicts.
+	stale, _ := repo.Load(ctx, 1)
+	fresh, _ := repo.Load(ctx, 1)
+	fresh.Record(add(1))
+	if err := repo.Save(ctx, fresh); err != nil {
+		t.Fatal(err)
+	}
+	stale.Record(add(1))
+	if err := repo.Save(ctx, stale); !errors.Is(err, kimberlite.ErrOffsetMismatch) {
+		t.Fatalf("expected ErrOffsetMismatch, got %v", err)
+	}
+
+	// Update retries conflicts with a reloaded aggregate.
+	store.race = 2
+	calls := 0
+	root, err = repo.Update(ctx, 1, func(r *Root[*counter]) error {
+		calls++
+		return r.Record(add(r.Aggregate.total))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || root.Aggregate.total != 412 || root.Version != 6 {
+		t.Fatalf("%d calls, total %d, version %d", calls, root.Aggregate.total, root.Version)
+	}
+	reloaded, _ := repo.Load(ctx, 1)
+	if reloaded.Aggregate.total != 412 {
+		t.Fatalf("reloaded total %d", reloaded.Aggregate.total)
+	}
+
+	// It gives up when the policy does.
+	store.race = 3
+	if _, err := repo.Update(ctx, 1, func(r *Root[*counter]) error { return r.Record(add(1)) }); !errors.Is(err, kimberlite.ErrOffsetMismatch) {
+		t.Fatalf("expected ErrOffsetMismatch, got %v", err)
+	}
+
+	// Decision errors are returned without saving.
+	errRefused := errors.New("refused")
+	before := len(store.events[1])
+	if _, err := repo.Update(ctx, 1, func(*Root[*counter]) error { return errRefused }); !errors.Is(err, errRefused) || len(store.events[1]) != before {
+		t.Fatalf("decide error: %v, %d events", err, len(store.events[1]))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Backoff(4, 10*time.Millisecond, 25*time.Millisecond)
+	for attempt, max := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 25 * time.Millisecond} {
+		d, ok := p(attempt, nil)
+		if !ok || d < max/2 || d > max {
+			t.Errorf("attempt %d: %v %v", attempt, d, ok)
+		}
+	}
+	if _, ok := p(4, nil); ok {
+		t.Error("retried past the last attempt")
+	}
+}
+
+func TestRepositoryCreateConflict(t *testing.T) {
+	ctx := context.Background()
+	client := kimberlitetest.NewMockClient()
+	defer client.Close()
+	info, err := client.CreateStream("counter-1", kimberlite.DataClassPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepository(client, func() *counter { return &counter{} })
+
+	// Two writers creating the same aggregate both load version 0; only
+	// the first save wins.
+	first, _ := repo.Load(ctx, info.ID)
+	second, _ := repo.Load(ctx, info.ID)
+	first.Record(add(1))
+	second.Record(add(2))
+	if err := repo.Save(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(ctx, second); !errors.Is(err, kimberlite.ErrOffsetMismatch) {
+		t.Fatalf("second create: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package eventsourcing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Snapshot is the serialized state of an aggregate after the first
+// Version events of its stream.
+type Snapshot struct {
+	Stream  kimberlite.StreamID `json:"stream"`
+	Version kimberlite.Offset   `json:"version"`
+	Data    []byte              `json:"data"`
+}
+
+// SnapshotStore persists the latest snapshot of each aggregate.
+type SnapshotStore interface {
+	// Load returns the latest snapshot of the aggregate of stream; ok
+	// is false if none has been saved.
+	Load(ctx context.Context, stream kimberlite.StreamID) (snap Snapshot, ok bool, err error)
+	// Save records snap as the latest snapshot of its aggregate.
+	Save(ctx context.Context, snap Snapshot) error
+}
+
+// Snapshotter is implemented by aggregates with their own snapshot
+// encoding. Others are snapshotted with encoding/json.
+type Snapshotter interface {
+	MarshalSnapshot() ([]byte, error)
+	UnmarshalSnapshot(data []byte) error
+}
+
+// WithSnapshots makes the repository save an aggregate's snapshot to
+// store whenever a Save takes its version past a multiple of every,
+// and load aggregates from their latest snapshot and the events after
+// it. Snapshots are an optimization: a snapshot that fails to save is
+// skipped until the next multiple, and one that fails to restore, e.g.
+// after a change of the aggregate's fields, is ignored in favour of
+// replaying the whole stream.
+func WithSnapshots(store SnapshotStore, every int) Option {
+	return func(c *config) {
+		c.snapshots = store
+		c.snapshotEvery = kimberlite.Offset(max(every, 1))
+	}
+}
+
+// restore loads the latest snapshot of root's stream, if any, into
+// root.
+func (r *Repository[T]) restore(ctx context.Context, root *Root[T]) error {
+	snap, ok, err := r.cfg.snapshots.Load(ctx, root.Stream)
+	if err != nil || !ok {
+		return err
+	}
+	agg := r.new()
+	if s, ok := any(agg).(Snapshotter); ok {
+		err = s.UnmarshalSnapshot(snap.Data)
+	} else {
+		err = json.Unmarshal(snap.Data, agg)
+	}
+	if err != nil {
+		return nil
+	}
+	root.Aggregate, root.Version = agg, snap.Version
+	return nil
+}
+
+// snapshot saves a snapshot of root if Save took it from version from
+// past a multiple of the snapshot interval.
+func (r *Repository[T]) snapshot(ctx context.Context, root *Root[T], from kimberlite.Offset) {
+	if r.cfg.snapshots == nil || from/r.cfg.snapshotEvery == root.Version/r.cfg.snapshotEvery {
+		return
+	}
+	var data []byte
+	var err error
+	if s, ok := any(root.Aggregate).(Snapshotter); ok {
+		data, err = s.MarshalSnapshot()
+	} else {
+		data, err = json.Marshal(root.Aggregate)
+	}
+	if err != nil {
+		return
+	}
+	_ = r.cfg.snapshots.Save(ctx, Snapshot{Stream: root.Stream, Version: root.Version, Data: data})
+}
+
+// MemorySnapshots is a SnapshotStore that keeps snapshots in memory.
+type MemorySnapshots struct {
+	mu    sync.Mutex
+	snaps map[kimberlite.StreamID]Snapshot
+}
+
+// NewMemorySnapshots returns an empty store.
+func NewMemorySnapshots() *MemorySnapshots {
+	return &MemorySnapshots{snaps: make(map[kimberlite.StreamID]Snapshot)}
+}
+
+// Load implements SnapshotStore.
+func (m *MemorySnapshots) Load(_ context.Context, stream kimberlite.StreamID) (Snapshot, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snap, ok := m.snaps[stream]
+	return snap, ok, nil
+}
+
+// Save implements SnapshotStore.
+func (m *MemorySnapshots) Save(_ context.Context, snap Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cur, ok := m.snaps[snap.Stream]; !ok || snap.Version >= cur.Version {
+		m.snaps[snap.Stream] = snap
+	}
+	return nil
+}
+
+// snapshotEventType is the envelope type of StreamSnapshots records.
+const snapshotEventType = "kimberlite.eventsourcing.snapshot"
+
+// SnapshotLog reads and appends the events of a snapshot stream.
+// *kimberlite.Client implements it.
+type SnapshotLog interface {
+	ReadEventsContext(ctx context.Context, streamID kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error)
+	AppendContext(ctx context.Context, streamID kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error)
+}
+
+// StreamSnapshots is a SnapshotStore backed by a Kimberlite stream
+// shared by the snapshots of many aggregates. Each Save appends a
+// record; Load indexes the records appended since the last call and
+// reads the latest one of the aggregate. It is safe for concurrent
+// use.
+type StreamSnapshots struct {
+	log    SnapshotLog
+	stream kimberlite.StreamID
+
+	mu     sync.Mutex
+	latest map[kimberlite.StreamID]snapshotRef
+	next   kimberlite.Offset
+}
+
+// snapshotRef locates a snapshot record in the snapshot stream.
+type snapshotRef struct {
+	version kimberlite.Offset
+	offset  kimberlite.Offset
+	size    int
+}
+
+// NewStreamSnapshots returns a store recording snapshots in stream.
+func NewStreamSnapshots(log SnapshotLog, stream kimberlite.StreamID) *StreamSnapshots {
+	return &StreamSnapshots{log: log, stream: stream, latest: make(map[kimberlite.StreamID]snapshotRef)}
+}
+
+// Load implements SnapshotStore.
+func (s *StreamSnapshots) Load(ctx context.Context, stream kimberlite.StreamID) (Snapshot, bool, error) {
+	s.mu.Lock()
+	err := s.catchUp(ctx)
+	ref, ok := s.latest[stream]
+	s.mu.Unlock()
+	if err != nil || !ok {
+		return Snapshot{}, false, err
+	}
+	events, err := s.log.ReadEventsContext(ctx, s.stream, ref.offset, uint64(ref.size))
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	if len(events) == 0 || events[0].Offset != ref.offset {
+		return Snapshot{}, false, fmt.Errorf("kimberlite: snapshot stream %d offset %d not readable", s.stream, ref.offset)
+	}
+	snap, _, err := s.decode(events[0])
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	return snap, true, nil
+}
+
+// Save implements SnapshotStore.
+func (s *StreamSnapshots) Save(ctx context.Context, snap Snapshot) error {
+	body, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	data, err := kimberlite.Envelope{Type: snapshotEventType, Data: body}.Marshal()
+	if err != nil {
+		return err
+	}
+	off, err := s.log.AppendContext(ctx, s.stream, data)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index(snap, kimberlite.Event{Offset: off, Data: data})
+	return nil
+}
+
+func (s *StreamSnapshots) catchUp(ctx context.Context) error {
+	for {
+		events, err := s.log.ReadEventsContext(ctx, s.stream, s.next, 1<<20)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		for _, ev := range events {
+			snap, ok, err := s.decode(ev)
+			if err != nil {
+				return err
+			}
+			if ok {
+				s.index(snap, ev)
+			}
+		}
+		s.next = events[len(events)-1].Offset + 1
+	}
+}
+
+// index records ev, holding snap, as the latest snapshot of its
+// aggregate unless a later version is known. s.mu must be held.
+func (s *StreamSnapshots) index(snap Snapshot, ev kimberlite.Event) {
+	if cur, ok := s.latest[snap.Stream]; !ok || snap.Version >= cur.version {
+		s.latest[snap.Stream] = snapshotRef{version: snap.Version, offset: ev.Offset, size: len(ev.Data)}
+	}
+}
+
+// decode returns the snapshot of ev; ok is false for events other than
+// snapshot records.
+func (s *StreamSnapshots) decode(ev kimberlite.Event) (snap Snapshot, ok bool, err error) {
+	env, err := ev.Envelope()
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("kimberlite: snapshot stream %d offset %d: %w", s.stream, ev.Offset, err)
+	}
+	if env.Type != snapshotEventType {
+		return Snapshot{}, false, nil
+	}
+	if err := json.Unmarshal(env.Data, &snap); err != nil {
+		return Snapshot{}, false, fmt.Errorf("kimberlite: snapshot stream %d offset %d: %w", s.stream, ev.Offset, err)
+	}
+	return snap, true, nil
+}
@@ -0,0 +1,71 @@
+package eventsourcing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// snapLog is a SnapshotLog over a memStore.
+type snapLog struct{ *memStore }
+
+func (l snapLog) AppendContext(ctx context.Context, id kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error) {
+	return l.AppendExpected(ctx, id, kimberlite.Offset(len(l.events[id])), events...)
+}
+
+type exported struct{ Total int }
+
+func (e *exported) Apply(env kimberlite.Envelope) error {
+	var c counter
+	err := c.Apply(env)
+	e.Total += c.total
+	return err
+}
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	events := &memStore{events: map[kimberlite.StreamID][][]byte{}}
+	snapshots := NewStreamSnapshots(snapLog{events}, 99)
+	repo := NewRepository(events, func() *exported { return &exported{} }, WithSnapshots(snapshots, 3))
+
+	for i := 1; i <= 7; i++ {
+		if _, err := repo.Update(ctx, 1, func(r *Root[*exported]) error { return r.Record(add(i)) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(events.events[99]); n != 2 {
+		t.Fatalf("%d snapshots, expected 2", n)
+	}
+
+	// A fresh store indexes the snapshot stream and loads version 6.
+	fresh := NewStreamSnapshots(snapLog{events}, 99)
+	snap, ok, err := fresh.Load(ctx, 1)
+	if err != nil || !ok || snap.Version != 6 {
+		t.Fatalf("snapshot %+v %v %v", snap, ok, err)
+	}
+
+	// Load uses the snapshot: tampering with its events is not seen.
+	events.events[1][0], _ = kimberlite.Envelope{Type: "Added", Data: []byte("1000")}.Marshal()
+	root, err := NewRepository(events, func() *exported { return &exported{} }, WithSnapshots(fresh, 3)).Load(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Version != 7 || root.Aggregate.Total != 28 {
+		t.Fatalf("version %d, total %d", root.Version, root.Aggregate.Total)
+	}
+
+	// Without snapshots the whole stream is replayed.
+	root, _ = NewRepository(events, func() *exported { return &exported{} }).Load(ctx, 1)
+	if root.Aggregate.Total != 1027 {
+		t.Fatalf("replayed total %d", root.Aggregate.Total)
+	}
+
+	// Snapshots that cannot be restored are ignored.
+	mem := NewMemorySnapshots()
+	mem.Save(ctx, Snapshot{Stream: 1, Version: 6, Data: []byte("not json")})
+	root, _ = NewRepository(events, func() *exported { return &exported{} }, WithSnapshots(mem, 3)).Load(ctx, 1)
+	if root.Version != 7 || root.Aggregate.Total != 1027 {
+		t.Fatalf("version %d, total %d", root.Version, root.Aggregate.Total)
+	}
+}
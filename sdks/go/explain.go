@@ -0,0 +1,206 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Plan is a query plan, as EXPLAIN reports it.
+//
+// The server renders a plan as a tree of access-path and operator
+// nodes, each with a few salient attributes; it does not estimate row
+// counts or costs. ExplainAnalyze runs the query as well, and reports
+// the rows it returned and how long it took.
+type Plan struct {
+	Root *PlanNode
+	// Text is the plan as the server rendered it.
+	Text string
+
+	// Analyzed reports whether Rows and Elapsed were measured.
+	Analyzed bool
+	// Rows is the number of rows the query returned.
+	Rows int64
+	// Elapsed is how long the query took, measured by the client.
+	Elapsed time.Duration
+}
+
+// PlanNode is a node of a query plan, such as a TableScan, IndexScan,
+// PointLookup, Join or Aggregate.
+type PlanNode struct {
+	Kind string
+	// Table is the table a scan or lookup reads.
+	Table string
+	// Attrs holds the node's other attributes, such as "index",
+	// "filter" ("yes" or "no"), "limit" or "cols", as rendered. Flags
+	// without a value map to "".
+	Attrs    map[string]string
+	Children []*PlanNode
+}
+
+// String renders n and its descendants in the server's format, with
+// attributes in sorted order.
+func (n *PlanNode) String() string {
+	var b strings.Builder
+	n.write(&b, 0)
+	return b.String()
+}
+
+func (n *PlanNode) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString("-> ")
+	b.WriteString(n.Kind)
+	if n.Table != "" || len(n.Attrs) > 0 {
+		attrs := make([]string, 0, len(n.Attrs)+1)
+		if n.Table != "" {
+			attrs = append(attrs, n.Table)
+		}
+		for _, k := range sortedAttrKeys(n.Attrs) {
+			if v := n.Attrs[k]; v != "" {
+				attrs = append(attrs, k+"="+v)
+			} else {
+				attrs = append(attrs, k)
+			}
+		}
+		b.WriteString(" [" + strings.Join(attrs, ", ") + "]")
+	}
+	b.WriteString("\n")
+	for _, c := range n.Children {
+		c.write(b, depth+1)
+	}
+}
+
+// Walk calls fn for n and each of its descendants, depth first.
+func (n *PlanNode) Walk(fn func(*PlanNode)) {
+	fn(n)
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Explain returns the plan the server would run sql with, without
+// running it. Equivalent to ExplainContext(context.Background(), ...).
+func (c *Client) Explain(sql string, args ...Value) (*Plan, error) {
+	return c.ExplainContext(context.Background(), sql, args...)
+}
+
+// ExplainContext is the context-aware variant of Explain. The server
+// explains SELECT statements other than UNIONs.
+func (c *Client) ExplainContext(ctx context.Context, sql string, args ...Value) (*Plan, error) {
+	r, err := c.QueryContext(ctx, "EXPLAIN "+sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Rows) != 1 || len(r.Columns) != 1 {
+		return nil, fmt.Errorf("%w: EXPLAIN returned %d rows of %d columns", ErrQueryFailed, len(r.Rows), len(r.Columns))
+	}
+	text := r.Row(0).Value(0).AsText()
+	root, err := ParsePlan(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{Root: root, Text: text}, nil
+}
+
+// ExplainAnalyze returns the plan of sql like ExplainContext, then runs
+// the query and records the number of rows it returned and its elapsed
+// time in the plan. The rows themselves are discarded.
+func (c *Client) ExplainAnalyze(ctx context.Context, sql string, args ...Value) (*Plan, error) {
+	plan, err := c.ExplainContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	plan.Analyzed, plan.Rows, plan.Elapsed = true, r.Stats.Rows, r.Stats.Elapsed
+	return plan, nil
+}
+
+// ParsePlan parses the text of an EXPLAIN result: a node per line,
+// indented by two spaces per level, each "-> Kind [attr, key=value]".
+func ParsePlan(text string) (*PlanNode, error) {
+	var root *PlanNode
+	var stack []*PlanNode
+	for i, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		body := strings.TrimLeft(line, " ")
+		depth := (len(line) - len(body)) / 2
+		if !strings.HasPrefix(body, "-> ") || depth > len(stack) || (depth == 0) != (root == nil) {
+			return nil, fmt.Errorf("kimberlite: plan line %d: unexpected %q", i+1, line)
+		}
+		n := parsePlanNode(strings.TrimPrefix(body, "-> "))
+		if depth == 0 {
+			root = n
+		} else {
+			parent := stack[depth-1]
+			parent.Children = append(parent.Children, n)
+		}
+		stack = append(stack[:depth], n)
+	}
+	if root == nil {
+		return nil, errors.New("kimberlite: empty plan")
+	}
+	return root, nil
+}
+
+func parsePlanNode(s string) *PlanNode {
+	n := &PlanNode{Kind: s, Attrs: map[string]string{}}
+	open := strings.IndexByte(s, '[')
+	if open < 0 || !strings.HasSuffix(s, "]") {
+		return n
+	}
+	n.Kind = strings.TrimSpace(s[:open])
+	for i, attr := range splitPlanAttrs(s[open+1 : len(s)-1]) {
+		k, v, ok := strings.Cut(attr, "=")
+		switch {
+		case ok:
+			n.Attrs[k] = v
+		case i == 0 && isScan(n.Kind):
+			n.Table = attr
+		default:
+			n.Attrs[attr] = ""
+		}
+	}
+	return n
+}
+
+func isScan(kind string) bool {
+	return strings.HasSuffix(kind, "Scan") || kind == "PointLookup"
+}
+
+// splitPlanAttrs splits s at the commas outside brackets and
+// parentheses, which group lists such as "group=[a,b]".
+func splitPlanAttrs(s string) []string {
+	var out []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		out = append(out, rest)
+	}
+	return out
+}
+
+func sortedAttrKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package kimberlite
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// planBackend answers EXPLAIN with plan and other queries with rows
+// rows.
+type planBackend struct {
+	blockingBackend
+	plan    string
+	rows    int
+	queries []string
+}
+
+func (b *planBackend) Query(sql string, _ []Value) (*QueryResult, error) {
+	b.queries = append(b.queries, sql)
+	if strings.HasPrefix(sql, "EXPLAIN ") {
+		return NewQueryResult([]string{"plan"}, []Row{{NewText(b.plan)}}), nil
+	}
+	return NewQueryResult([]string{"n"}, make([]Row, b.rows)), nil
+}
+
+const joinPlan = `-> Aggregate [group=[dept_id,site], aggs=2, having=0]
+  -> Join [type=Inner, on=1, cols=5]
+    -> IndexScan [employees, index=by_dept, cols=3, filter=yes, limit=none]
+    -> PointLookup [departments, cols=2]
+`
+
+func TestExplain(t *testing.T) {
+	b := &planBackend{plan: joinPlan, rows: 3}
+	c, err := Connect("test", WithTenant(1), WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	plan, err := c.Explain("SELECT dept_id, COUNT(*) FROM employees JOIN departments ON id = dept_id GROUP BY dept_id, site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := plan.Root
+	if root.Kind != "Aggregate" || root.Attrs["group"] != "[dept_id,site]" || len(root.Children) != 1 {
+		t.Fatalf("root %+v", root)
+	}
+	join := root.Children[0]
+	if join.Kind != "Join" || join.Attrs["type"] != "Inner" || len(join.Children) != 2 {
+		t.Fatalf("join %+v", join)
+	}
+	scan, lookup := join.Children[0], join.Children[1]
+	if scan.Table != "employees" || scan.Attrs["index"] != "by_dept" || lookup.Kind != "PointLookup" || lookup.Table != "departments" {
+		t.Fatalf("leaves %+v %+v", scan, lookup)
+	}
+	var kinds []string
+	root.Walk(func(n *PlanNode) { kinds = append(kinds, n.Kind) })
+	if len(kinds) != 4 || kinds[3] != "PointLookup" {
+		t.Fatalf("walk %v", kinds)
+	}
+	if plan.Analyzed || plan.Text != joinPlan {
+		t.Fatalf("plan %+v", plan)
+	}
+	reparsed, err := ParsePlan(root.String())
+	if err != nil || reparsed.String() != root.String() {
+		t.Fatalf("round trip %q, %v", root.String(), err)
+	}
+
+	plan, err = c.ExplainAnalyze(context.Background(), "SELECT * FROM employees")
+	if err != nil || !plan.Analyzed || plan.Rows != 3 {
+		t.Fatalf("analyze %+v, %v", plan, err)
+	}
+	if len(b.queries) != 3 || b.queries[1] != "EXPLAIN SELECT * FROM employees" || b.queries[2] != "SELECT * FROM employees" {
+		t.Fatalf("queries %q", b.queries)
+	}
+
+	for _, bad := range []string{"", "TableScan [t]", "-> A\n    -> B"} {
+		if _, err := ParsePlan(bad); err == nil {
+			t.Errorf("ParsePlan(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package kimberlite
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportOption configures QueryResult.WriteCSV and WriteNDJSON.
+type ExportOption func(*exportConfig)
+
+type exportConfig struct {
+	null       string
+	timeLayout string
+	header     bool
+	comma      rune
+}
+
+// WithNullString sets how WriteCSV renders NULL. The default is the
+// empty string. NDJSON always uses null.
+func WithNullString(s string) ExportOption {
+	return func(c *exportConfig) { c.null = s }
+}
+
+// WithTimestampFormat sets the time.Format layout of timestamps. The
+// default is time.RFC3339Nano.
+func WithTimestampFormat(layout string) ExportOption {
+	return func(c *exportConfig) { c.timeLayout = layout }
+}
+
+// WithoutHeader omits the CSV header row of column names.
+func WithoutHeader() ExportOption {
+	return func(c *exportConfig) { c.header = false }
+}
+
+// WithDelimiter sets the CSV field delimiter. The default is ','.
+func WithDelimiter(r rune) ExportOption {
+	return func(c *exportConfig) { c.comma = r }
+}
+
+func newExportConfig(opts []ExportOption) exportConfig {
+	cfg := exportConfig{timeLayout: time.RFC3339Nano, header: true, comma: ','}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WriteCSV writes the result as RFC 4180 CSV, preceded by a header row
+// of column names unless WithoutHeader is given. Bytes are base64
+// encoded; JSON, arrays and records are written as JSON text.
+func (r *QueryResult) WriteCSV(w io.Writer, opts ...ExportOption) error {
+	cfg := newExportConfig(opts)
+	cw := csv.NewWriter(w)
+	cw.Comma = cfg.comma
+	if cfg.header {
+		if err := cw.Write(r.Columns); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(r.Columns))
+	for i := range r.Rows {
+		row := r.Row(i)
+		for j := range record {
+			s, err := cfg.text(row.Value(j))
+			if err != nil {
+				return err
+			}
+			record[j] = s
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteNDJSON writes the result as newline-delimited JSON, one object
+// per row with keys in column order. Bytes are base64 strings, JSON
+// values are embedded as-is, and arrays and records become JSON arrays
+// and objects.
+func (r *QueryResult) WriteNDJSON(w io.Writer, opts ...ExportOption) error {
+	cfg := newExportConfig(opts)
+	bw := bufio.NewWriter(w)
+	keys := make([][]byte, len(r.Columns))
+	for i, name := range r.Columns {
+		k, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		keys[i] = k
+	}
+	for i := range r.Rows {
+		row := r.Row(i)
+		bw.WriteByte('{')
+		for j, k := range keys {
+			if j > 0 {
+				bw.WriteByte(',')
+			}
+			bw.Write(k)
+			bw.WriteByte(':')
+			jv, err := cfg.jsonValue(row.Value(j))
+			if err != nil {
+				return err
+			}
+			v, err := json.Marshal(jv)
+			if err != nil {
+				return err
+			}
+			bw.Write(v)
+		}
+		bw.WriteString("}\n")
+	}
+	return bw.Flush()
+}
+
+func (c exportConfig) text(v Value) (string, error) {
+	switch v.Type {
+	case ValueTypeNull:
+		return c.null, nil
+	case ValueTypeInteger:
+		return strconv.FormatInt(v.AsInt(), 10), nil
+	case ValueTypeFloat:
+		return strconv.FormatFloat(v.AsFloat(), 'g', -1, 64), nil
+	case ValueTypeText:
+		return v.AsText(), nil
+	case ValueTypeBoolean:
+		return strconv.FormatBool(v.AsBool()), nil
+	case ValueTypeTimestamp:
+		return v.AsTimestamp().Format(c.timeLayout), nil
+	case ValueTypeInterval:
+		return v.AsInterval().String(), nil
+	case ValueTypeBytes:
+		b, err := v.DecodeBytes()
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	jv, err := c.jsonValue(v)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(jv)
+	return string(b), err
+}
+
+// jsonValue is jsonEncodable with timestamps formatted per c.
+func (c exportConfig) jsonValue(v Value) (any, error) {
+	switch v.Type {
+	case ValueTypeTimestamp:
+		return v.AsTimestamp().Format(c.timeLayout), nil
+	case ValueTypeBytes:
+		return v.DecodeBytes()
+	case ValueTypeArray:
+		elems := v.AsArray()
+		out := make([]any, len(elems))
+		for i, e := range elems {
+			x, err := c.jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = x
+		}
+		return out, nil
+	case ValueTypeRecord:
+		fields := v.AsRecord()
+		out := make(map[string]any, len(fields))
+		for k, f := range fields {
+			x, err := c.jsonValue(f)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = x
+		}
+		return out, nil
+	}
+	return v.jsonEncodable(), nil
+}
@@ -0,0 +1,58 @@
+package kimberlite
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func exportFixture(t *testing.T) *QueryResult {
+	t.Helper()
+	meta, err := NewJSON(map[string]any{"ward": "icu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	return &QueryResult{
+		Columns: []string{"id", "name", "admitted_at", "meta", "tags", "scan"},
+		Rows: []map[string]Value{
+			{"id": NewInt(1), "name": NewText(`O"Brien, Pat`), "admitted_at": NewTimestamp(at), "meta": meta,
+				"tags": NewArray(NewText("a"), NewText("b")), "scan": NewBytes([]byte{0xde, 0xad})},
+			{"id": NewInt(2), "name": NewNull(), "admitted_at": NewNull(), "meta": NewNull(), "tags": NewNull(), "scan": NewNull()},
+		},
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := exportFixture(t)
+	var b bytes.Buffer
+	if err := r.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,name,admitted_at,meta,tags,scan\n" +
+		`1,"O""Brien, Pat",2024-03-01T12:30:00Z,"{""ward"":""icu""}","[""a"",""b""]",3q0=` + "\n" +
+		"2,,,,,\n"
+	if b.String() != want {
+		t.Fatalf("WriteCSV =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := r.WriteCSV(&b, WithoutHeader(), WithNullString(`\N`), WithDelimiter('\t'), WithTimestampFormat(time.DateOnly)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "2\t\\N\t\\N\t\\N\t\\N\t\\N\n"; !bytes.HasSuffix(b.Bytes(), []byte(want)) || !bytes.Contains(b.Bytes(), []byte("\t2024-03-01\t")) {
+		t.Fatalf("WriteCSV with options =\n%s", got)
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := exportFixture(t).WriteNDJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":1,"name":"O\"Brien, Pat","admitted_at":"2024-03-01T12:30:00Z","meta":{"ward":"icu"},"tags":["a","b"],"scan":"3q0="}` + "\n" +
+		`{"id":2,"name":null,"admitted_at":null,"meta":null,"tags":null,"scan":null}` + "\n"
+	if b.String() != want {
+		t.Fatalf("WriteNDJSON =\n%s\nwant\n%s", b.String(), want)
+	}
+}
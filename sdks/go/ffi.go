@@ -1,3 +1,5 @@
+//go:build cgo
+
 package kimberlite
 
 // CGo bindings to libkimberlite_ffi (handle-based API).
@@ -31,6 +33,7 @@ typedef enum {
 	KMB_ERR_INTERNAL          = 13,
 	KMB_ERR_CLUSTER_UNAVAILABLE = 14,
 	KMB_ERR_UNKNOWN           = 15,
+	KMB_ERR_OFFSET_MISMATCH   = 17,
 } KmbError;
 
 // Opaque client handle.
@@ -53,6 +56,23 @@ typedef struct {
 	size_t    event_count;
 } KmbReadResult;
 
+// Query parameter types.
+#define KMB_PARAM_NULL      0
+#define KMB_PARAM_BIGINT    1
+#define KMB_PARAM_TEXT      2
+#define KMB_PARAM_BOOLEAN   3
+#define KMB_PARAM_TIMESTAMP 4
+#define KMB_PARAM_BYTES     5
+
+// A single bound query parameter.
+typedef struct {
+	int         param_type;
+	int64_t     bigint_val;
+	const char* text_val;
+	int         bool_val;
+	int64_t     timestamp_val;
+} KmbQueryParam;
+
 // Query value types.
 #define KMB_VALUE_NULL      0
 #define KMB_VALUE_BIGINT    1
@@ -78,16 +98,68 @@ typedef struct {
 	size_t          row_count;
 } KmbQueryResult;
 
+// Result of a DML statement.
+typedef struct {
+	uint64_t rows_affected;
+	uint64_t log_offset;
+} KmbExecuteResult;
+
+// JSON payload returned by admin/metadata calls. Owned by the library.
+typedef struct {
+	char* json;
+} KmbAdminJson;
+
+// Result of kmb_subscribe.
+typedef struct {
+	uint64_t subscription_id;
+	uint64_t start_offset;
+	uint32_t initial_credits;
+} KmbSubscribeResult;
+
+// An event, or the close, of a subscription. Owned by the library.
+typedef struct {
+	uint64_t offset;
+	uint8_t* data;
+	size_t   data_len;
+	int      closed;
+	int      close_reason;
+} KmbSubscriptionEvent;
+
 // FFI function declarations.
 extern KmbError    kmb_client_connect(const KmbClientConfig* config, KmbClient** client_out);
 extern void        kmb_client_disconnect(KmbClient* client);
 extern KmbError    kmb_client_create_stream(KmbClient* client, const char* name, int data_class, uint64_t* stream_id_out);
+extern KmbError    kmb_client_create_stream_with_placement(KmbClient* client, const char* name, int data_class, int placement, const char* custom_region, uint64_t* stream_id_out);
 extern KmbError    kmb_client_append(KmbClient* client, uint64_t stream_id, uint64_t expected_offset, const uint8_t** events, const size_t* event_lengths, size_t event_count, uint64_t* first_offset_out);
+extern KmbError    kmb_client_stream_length(KmbClient* client, uint64_t stream_id, uint64_t* length_out);
 extern KmbError    kmb_client_read_events(KmbClient* client, uint64_t stream_id, uint64_t from_offset, uint64_t max_bytes, KmbReadResult** result_out);
 extern void        kmb_read_result_free(KmbReadResult* result);
-extern KmbError    kmb_client_query(KmbClient* client, const char* sql, const void* params, size_t param_count, KmbQueryResult** result_out);
+extern KmbError    kmb_subscribe(KmbClient* client, uint64_t stream_id, uint64_t from_offset, uint32_t initial_credits, KmbSubscribeResult* result_out);
+extern KmbError    kmb_subscription_grant_credits(KmbClient* client, uint64_t subscription_id, uint32_t additional_credits, uint32_t* new_balance_out);
+extern KmbError    kmb_subscription_unsubscribe(KmbClient* client, uint64_t subscription_id);
+extern KmbError    kmb_subscription_next(KmbClient* client, uint64_t subscription_id, KmbSubscriptionEvent* event_out);
+extern void        kmb_subscription_event_free(KmbSubscriptionEvent* event);
+extern KmbError    kmb_client_query(KmbClient* client, const char* sql, const KmbQueryParam* params, size_t param_count, KmbQueryResult** result_out);
 extern void        kmb_query_result_free(KmbQueryResult* result);
+extern KmbError    kmb_client_execute(KmbClient* client, const char* sql, const KmbQueryParam* params, size_t param_count, KmbExecuteResult* result_out);
 extern const char* kmb_error_message(KmbError error);
+extern void        kmb_admin_json_free(KmbAdminJson* result);
+extern KmbError    kmb_admin_list_tables(KmbClient* client, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_describe_table(KmbClient* client, const char* table_name, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_list_indexes(KmbClient* client, const char* table_name, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_masking_policy_create(KmbClient* client, const char* name, const char* strategy_json, const char* roles_json);
+extern KmbError    kmb_admin_masking_policy_drop(KmbClient* client, const char* name);
+extern KmbError    kmb_admin_masking_policy_attach(KmbClient* client, const char* table_name, const char* column_name, const char* policy_name);
+extern KmbError    kmb_admin_masking_policy_detach(KmbClient* client, const char* table_name, const char* column_name);
+extern KmbError    kmb_admin_masking_policy_list(KmbClient* client, _Bool include_attachments, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_api_key_rotate(KmbClient* client, const char* old_key, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_server_info(KmbClient* client, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_tenant_create(KmbClient* client, uint64_t tenant_id, const char* name, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_tenant_get(KmbClient* client, uint64_t tenant_id, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_tenant_list(KmbClient* client, KmbAdminJson* result_out);
+extern KmbError    kmb_admin_tenant_delete(KmbClient* client, uint64_t tenant_id, KmbAdminJson* result_out);
+extern KmbError    kmb_compliance_erasure_request(KmbClient* client, const char* subject_id, KmbAdminJson* result_out);
+extern KmbError    kmb_compliance_erasure_complete(KmbClient* client, const char* request_id, KmbAdminJson* result_out);
 
 // kmb_connect_helper avoids the CGo pointer-in-pointer restriction by
 // building KmbClientConfig entirely on the C stack (all pointer fields
@@ -163,26 +235,122 @@ func ffiDisconnect(handle unsafe.Pointer) error {
 }
 
 // ffiQuery executes a SQL query and returns the results.
-func ffiQuery(handle unsafe.Pointer, sql string) (*QueryResult, error) {
+func ffiQuery(handle unsafe.Pointer, sql string, args []Value, loc *time.Location) (*QueryResult, error) {
 	if handle == nil {
 		return nil, ErrNotConnected
 	}
 
+	cParams, freeParams, err := ffiParams(args)
+	if err != nil {
+		return nil, err
+	}
+	defer freeParams()
+
 	cSQL := C.CString(sql)
 	defer C.free(unsafe.Pointer(cSQL))
 
 	var resultOut *C.KmbQueryResult
-	rc := C.kmb_client_query((*C.KmbClient)(handle), cSQL, nil, 0, &resultOut)
+	rc := C.kmb_client_query((*C.KmbClient)(handle), cSQL, cParams, C.size_t(len(args)), &resultOut)
 	if rc != C.KMB_OK {
 		return nil, mapFFIError(rc)
 	}
 	defer C.kmb_query_result_free(resultOut)
 
-	return convertQueryResult(resultOut), nil
+	return convertQueryResult(resultOut, loc), nil
 }
 
-// ffiCreateStream creates a new stream and returns its info.
-func ffiCreateStream(handle unsafe.Pointer, name string, class DataClass) (*StreamInfo, error) {
+// ffiExecute runs a DML statement and returns its acknowledgement.
+func ffiExecute(handle unsafe.Pointer, sql string, args []Value) (*ExecResult, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+
+	cParams, freeParams, err := ffiParams(args)
+	if err != nil {
+		return nil, err
+	}
+	defer freeParams()
+
+	cSQL := C.CString(sql)
+	defer C.free(unsafe.Pointer(cSQL))
+
+	var out C.KmbExecuteResult
+	rc := C.kmb_client_execute((*C.KmbClient)(handle), cSQL, cParams, C.size_t(len(args)), &out)
+	if rc != C.KMB_OK {
+		return nil, mapFFIError(rc)
+	}
+	return &ExecResult{
+		RowsAffected: int64(out.rows_affected),
+		LogOffset:    Offset(out.log_offset),
+	}, nil
+}
+
+// ffiParams marshals query arguments into a C-allocated KmbQueryParam
+// array. The returned func frees the array and any strings it owns.
+func ffiParams(args []Value) (*C.KmbQueryParam, func(), error) {
+	if len(args) == 0 {
+		return nil, func() {}, nil
+	}
+
+	n := len(args)
+	cParams := (*C.KmbQueryParam)(C.calloc(C.size_t(n), C.size_t(unsafe.Sizeof(C.KmbQueryParam{}))))
+	params := (*[1 << 20]C.KmbQueryParam)(unsafe.Pointer(cParams))[:n:n]
+	var owned []unsafe.Pointer
+	free := func() {
+		for _, p := range owned {
+			C.free(p)
+		}
+		C.free(unsafe.Pointer(cParams))
+	}
+
+	for i, v := range args {
+		p := &params[i]
+		switch v.Type {
+		case ValueTypeNull:
+			p.param_type = C.KMB_PARAM_NULL
+		case ValueTypeInteger:
+			p.param_type = C.KMB_PARAM_BIGINT
+			p.bigint_val = C.int64_t(v.AsInt())
+		case ValueTypeBoolean:
+			p.param_type = C.KMB_PARAM_BOOLEAN
+			if v.AsBool() {
+				p.bool_val = 1
+			}
+		case ValueTypeTimestamp:
+			p.param_type = C.KMB_PARAM_TIMESTAMP
+			p.timestamp_val = C.int64_t(v.AsTimestamp().UnixNano())
+		case ValueTypeBytes:
+			// The data travels as text_val, with its length in
+			// bigint_val; malloc(0) may return NULL, so allocate at
+			// least one byte.
+			n := v.bytesLen()
+			buf := C.malloc(C.size_t(n + 1))
+			owned = append(owned, buf)
+			if err := v.writeBytesTo(unsafe.Slice((*byte)(buf), n)); err != nil {
+				free()
+				return nil, nil, fmt.Errorf("kimberlite: parameter $%d: %w", i+1, err)
+			}
+			p.param_type = C.KMB_PARAM_BYTES
+			p.bigint_val = C.int64_t(n)
+			p.text_val = (*C.char)(buf)
+		default:
+			text, err := v.wireText()
+			if err != nil {
+				free()
+				return nil, nil, fmt.Errorf("kimberlite: parameter $%d: %w", i+1, err)
+			}
+			cs := C.CString(text)
+			owned = append(owned, unsafe.Pointer(cs))
+			p.param_type = C.KMB_PARAM_TEXT
+			p.text_val = cs
+		}
+	}
+	return cParams, free, nil
+}
+
+// ffiCreateStream creates a new stream, pinned to region unless it is
+// RegionGlobal, and returns its info.
+func ffiCreateStream(handle unsafe.Pointer, name string, class DataClass, region Region) (*StreamInfo, error) {
 	if handle == nil {
 		return nil, ErrNotConnected
 	}
@@ -191,7 +359,22 @@ func ffiCreateStream(handle unsafe.Pointer, name string, class DataClass) (*Stre
 	defer C.free(unsafe.Pointer(cName))
 
 	var streamIDOut C.uint64_t
-	rc := C.kmb_client_create_stream((*C.KmbClient)(handle), cName, C.int(class), &streamIDOut)
+	var rc C.KmbError
+	if region == RegionGlobal {
+		rc = C.kmb_client_create_stream((*C.KmbClient)(handle), cName, C.int(class), &streamIDOut)
+	} else {
+		placement, custom := 3, (*C.char)(nil)
+		switch region {
+		case RegionUSEast1:
+			placement = 1
+		case RegionAPSoutheast2:
+			placement = 2
+		default:
+			custom = C.CString(string(region))
+			defer C.free(unsafe.Pointer(custom))
+		}
+		rc = C.kmb_client_create_stream_with_placement((*C.KmbClient)(handle), cName, C.int(class), C.int(placement), custom, &streamIDOut)
+	}
 	if rc != C.KMB_OK {
 		return nil, mapFFIError(rc)
 	}
@@ -200,12 +383,13 @@ func ffiCreateStream(handle unsafe.Pointer, name string, class DataClass) (*Stre
 		ID:        StreamID(streamIDOut),
 		Name:      name,
 		DataClass: class,
+		Region:    region,
 		CreatedAt: time.Now(),
 	}, nil
 }
 
 // ffiAppend appends events to a stream.
-func ffiAppend(handle unsafe.Pointer, streamID uint64, events [][]byte) (Offset, error) {
+func ffiAppend(handle unsafe.Pointer, streamID, expectedOffset uint64, events [][]byte) (Offset, error) {
 	if handle == nil {
 		return 0, ErrNotConnected
 	}
@@ -238,7 +422,7 @@ func ffiAppend(handle unsafe.Pointer, streamID uint64, events [][]byte) (Offset,
 	rc := C.kmb_client_append(
 		(*C.KmbClient)(handle),
 		C.uint64_t(streamID),
-		0, // expected_offset: 0 = no optimistic concurrency check
+		C.uint64_t(expectedOffset),
 		cEventPtrs,
 		cEventLens,
 		C.size_t(n),
@@ -250,6 +434,78 @@ func ffiAppend(handle unsafe.Pointer, streamID uint64, events [][]byte) (Offset,
 	return Offset(firstOffsetOut), nil
 }
 
+// ffiStreamLength returns a stream's length, the offset of its next
+// event.
+func ffiStreamLength(handle unsafe.Pointer, streamID uint64) (Offset, error) {
+	if handle == nil {
+		return 0, ErrNotConnected
+	}
+	var lengthOut C.uint64_t
+	if rc := C.kmb_client_stream_length((*C.KmbClient)(handle), C.uint64_t(streamID), &lengthOut); rc != C.KMB_OK {
+		return 0, mapFFIError(rc)
+	}
+	return Offset(lengthOut), nil
+}
+
+// ffiSubscribe subscribes to the events of a stream from fromOffset,
+// with credits for that many events, and returns the subscription's ID
+// and the offset of its first event.
+func ffiSubscribe(handle unsafe.Pointer, streamID, fromOffset uint64, credits uint32) (uint64, Offset, error) {
+	if handle == nil {
+		return 0, 0, ErrNotConnected
+	}
+	var out C.KmbSubscribeResult
+	if rc := C.kmb_subscribe((*C.KmbClient)(handle), C.uint64_t(streamID), C.uint64_t(fromOffset), C.uint32_t(credits), &out); rc != C.KMB_OK {
+		return 0, 0, mapFFIError(rc)
+	}
+	return uint64(out.subscription_id), Offset(out.start_offset), nil
+}
+
+// ffiSubscriptionGrantCredits grants a subscription credits for more
+// events.
+func ffiSubscriptionGrantCredits(handle unsafe.Pointer, subscriptionID uint64, credits uint32) error {
+	if handle == nil {
+		return ErrNotConnected
+	}
+	if rc := C.kmb_subscription_grant_credits((*C.KmbClient)(handle), C.uint64_t(subscriptionID), C.uint32_t(credits), nil); rc != C.KMB_OK {
+		return mapFFIError(rc)
+	}
+	return nil
+}
+
+// ffiSubscriptionUnsubscribe cancels a subscription.
+func ffiSubscriptionUnsubscribe(handle unsafe.Pointer, subscriptionID uint64) error {
+	if handle == nil {
+		return ErrNotConnected
+	}
+	if rc := C.kmb_subscription_unsubscribe((*C.KmbClient)(handle), C.uint64_t(subscriptionID)); rc != C.KMB_OK {
+		return mapFFIError(rc)
+	}
+	return nil
+}
+
+// ffiSubscriptionNext waits for the next event of a subscription, up to
+// the connection's read timeout, after which it returns an error
+// wrapping ErrTimeout. closed reports that the server closed the
+// subscription, for the reason given.
+func ffiSubscriptionNext(handle unsafe.Pointer, subscriptionID uint64) (offset Offset, data []byte, closed bool, reason int, err error) {
+	if handle == nil {
+		return 0, nil, false, 0, ErrNotConnected
+	}
+	var ev C.KmbSubscriptionEvent
+	if rc := C.kmb_subscription_next((*C.KmbClient)(handle), C.uint64_t(subscriptionID), &ev); rc != C.KMB_OK {
+		return 0, nil, false, 0, mapFFIError(rc)
+	}
+	defer C.kmb_subscription_event_free(&ev)
+	if ev.closed != 0 {
+		return 0, nil, true, int(ev.close_reason), nil
+	}
+	if ev.data_len > 0 && ev.data != nil {
+		data = C.GoBytes(unsafe.Pointer(ev.data), C.int(ev.data_len))
+	}
+	return Offset(ev.offset), data, false, 0, nil
+}
+
 // ffiReadEvents reads events from a stream starting at fromOffset.
 func ffiReadEvents(handle unsafe.Pointer, streamID, fromOffset, maxBytes uint64) ([]Event, error) {
 	if handle == nil {
@@ -294,6 +550,233 @@ func ffiReadEvents(handle unsafe.Pointer, streamID, fromOffset, maxBytes uint64)
 	return out, nil
 }
 
+// ffiListTables returns the JSON table listing for the tenant.
+func ffiListTables(handle unsafe.Pointer) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_list_tables((*C.KmbClient)(handle), out)
+	})
+}
+
+// ffiDescribeTable returns the JSON column listing for table.
+func ffiDescribeTable(handle unsafe.Pointer, table string) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_describe_table((*C.KmbClient)(handle), cTable, out)
+	})
+}
+
+// ffiListIndexes returns the JSON index listing for table.
+func ffiListIndexes(handle unsafe.Pointer, table string) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_list_indexes((*C.KmbClient)(handle), cTable, out)
+	})
+}
+
+// ffiMaskingPolicyCreate creates the masking policy name from its
+// strategy and exempt roles, both JSON-encoded.
+func ffiMaskingPolicyCreate(handle unsafe.Pointer, name string, strategy, roles []byte) error {
+	if handle == nil {
+		return ErrNotConnected
+	}
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	cStrategy := C.CString(string(strategy))
+	defer C.free(unsafe.Pointer(cStrategy))
+	cRoles := C.CString(string(roles))
+	defer C.free(unsafe.Pointer(cRoles))
+
+	if rc := C.kmb_admin_masking_policy_create((*C.KmbClient)(handle), cName, cStrategy, cRoles); rc != C.KMB_OK {
+		return mapFFIError(rc)
+	}
+	return nil
+}
+
+// ffiMaskingPolicyDrop drops the masking policy name.
+func ffiMaskingPolicyDrop(handle unsafe.Pointer, name string) error {
+	if handle == nil {
+		return ErrNotConnected
+	}
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	if rc := C.kmb_admin_masking_policy_drop((*C.KmbClient)(handle), cName); rc != C.KMB_OK {
+		return mapFFIError(rc)
+	}
+	return nil
+}
+
+// ffiMaskingPolicyAttach attaches the masking policy to table.column.
+func ffiMaskingPolicyAttach(handle unsafe.Pointer, table, column, policy string) error {
+	if handle == nil {
+		return ErrNotConnected
+	}
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+	cColumn := C.CString(column)
+	defer C.free(unsafe.Pointer(cColumn))
+	cPolicy := C.CString(policy)
+	defer C.free(unsafe.Pointer(cPolicy))
+
+	if rc := C.kmb_admin_masking_policy_attach((*C.KmbClient)(handle), cTable, cColumn, cPolicy); rc != C.KMB_OK {
+		return mapFFIError(rc)
+	}
+	return nil
+}
+
+// ffiMaskingPolicyDetach detaches the masking policy from table.column.
+func ffiMaskingPolicyDetach(handle unsafe.Pointer, table, column string) error {
+	if handle == nil {
+		return ErrNotConnected
+	}
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+	cColumn := C.CString(column)
+	defer C.free(unsafe.Pointer(cColumn))
+
+	if rc := C.kmb_admin_masking_policy_detach((*C.KmbClient)(handle), cTable, cColumn); rc != C.KMB_OK {
+		return mapFFIError(rc)
+	}
+	return nil
+}
+
+// ffiMaskingPolicyList returns the JSON masking policy catalogue.
+func ffiMaskingPolicyList(handle unsafe.Pointer, includeAttachments bool) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_masking_policy_list((*C.KmbClient)(handle), C._Bool(includeAttachments), out)
+	})
+}
+
+// ffiAPIKeyRotate rotates oldKey, returning the JSON new key and info.
+func ffiAPIKeyRotate(handle unsafe.Pointer, oldKey string) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	cKey := C.CString(oldKey)
+	defer C.free(unsafe.Pointer(cKey))
+
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_api_key_rotate((*C.KmbClient)(handle), cKey, out)
+	})
+}
+
+// ffiServerInfo returns the JSON server info.
+func ffiServerInfo(handle unsafe.Pointer) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_server_info((*C.KmbClient)(handle), out)
+	})
+}
+
+// ffiTenantCreate registers the tenant id, named name unless it is
+// empty, returning the JSON tenant and whether it was created.
+func ffiTenantCreate(handle unsafe.Pointer, id uint64, name string) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	var cName *C.char
+	if name != "" {
+		cName = C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+	}
+
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_tenant_create((*C.KmbClient)(handle), C.uint64_t(id), cName, out)
+	})
+}
+
+// ffiTenantGet returns the JSON summary of the tenant id.
+func ffiTenantGet(handle unsafe.Pointer, id uint64) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_tenant_get((*C.KmbClient)(handle), C.uint64_t(id), out)
+	})
+}
+
+// ffiTenantList returns the JSON listing of registered tenants.
+func ffiTenantList(handle unsafe.Pointer) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_tenant_list((*C.KmbClient)(handle), out)
+	})
+}
+
+// ffiTenantDelete deletes the tenant id, returning the JSON outcome.
+func ffiTenantDelete(handle unsafe.Pointer, id uint64) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_admin_tenant_delete((*C.KmbClient)(handle), C.uint64_t(id), out)
+	})
+}
+
+// ffiErasureRequest opens an erasure request for subject in the
+// server's compliance log and returns its JSON record.
+func ffiErasureRequest(handle unsafe.Pointer, subject string) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	cSubject := C.CString(subject)
+	defer C.free(unsafe.Pointer(cSubject))
+
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_compliance_erasure_request((*C.KmbClient)(handle), cSubject, out)
+	})
+}
+
+// ffiErasureComplete completes the erasure request requestID and
+// returns the JSON audit record of the erasure.
+func ffiErasureComplete(handle unsafe.Pointer, requestID string) ([]byte, error) {
+	if handle == nil {
+		return nil, ErrNotConnected
+	}
+	cID := C.CString(requestID)
+	defer C.free(unsafe.Pointer(cID))
+
+	return ffiAdminJSON(func(out *C.KmbAdminJson) C.KmbError {
+		return C.kmb_compliance_erasure_complete((*C.KmbClient)(handle), cID, out)
+	})
+}
+
+// ffiAdminJSON runs an admin call that returns a KmbAdminJson and
+// copies the payload into Go memory.
+func ffiAdminJSON(call func(out *C.KmbAdminJson) C.KmbError) ([]byte, error) {
+	var out C.KmbAdminJson
+	rc := call(&out)
+	if rc != C.KMB_OK {
+		return nil, mapFFIError(rc)
+	}
+	defer C.kmb_admin_json_free(&out)
+
+	if out.json == nil {
+		return nil, nil
+	}
+	return []byte(C.GoString(out.json)), nil
+}
+
 // mapFFIError converts a KmbError code to a Go error.
 func mapFFIError(rc C.KmbError) error {
 	msg := C.GoString(C.kmb_error_message(rc))
@@ -302,10 +785,16 @@ func mapFFIError(rc C.KmbError) error {
 		return fmt.Errorf("%w: %s", ErrConnectionFailed, msg)
 	case C.KMB_ERR_STREAM_NOT_FOUND:
 		return fmt.Errorf("%w: %s", ErrStreamNotFound, msg)
+	case C.KMB_ERR_TENANT_NOT_FOUND:
+		return fmt.Errorf("%w: %s", ErrTenantNotFound, msg)
+	case C.KMB_ERR_AUTH_FAILED:
+		return fmt.Errorf("%w: %s", ErrAuthFailed, msg)
 	case C.KMB_ERR_PERMISSION_DENIED:
 		return fmt.Errorf("%w: %s", ErrPermissionDenied, msg)
 	case C.KMB_ERR_TIMEOUT:
 		return fmt.Errorf("%w: %s", ErrTimeout, msg)
+	case C.KMB_ERR_OFFSET_MISMATCH:
+		return fmt.Errorf("%w: %s", ErrOffsetMismatch, msg)
 	case C.KMB_ERR_QUERY_SYNTAX, C.KMB_ERR_QUERY_EXECUTION:
 		return fmt.Errorf("%w: %s", ErrQueryFailed, msg)
 	default:
@@ -317,7 +806,8 @@ func mapFFIError(rc C.KmbError) error {
 }
 
 // convertQueryResult converts a C KmbQueryResult pointer to a Go QueryResult.
-func convertQueryResult(r *C.KmbQueryResult) *QueryResult {
+// Timestamps are presented in loc.
+func convertQueryResult(r *C.KmbQueryResult, loc *time.Location) *QueryResult {
 	colCount := int(r.column_count)
 	rowCount := int(r.row_count)
 
@@ -330,31 +820,36 @@ func convertQueryResult(r *C.KmbQueryResult) *QueryResult {
 	}
 
 	rows := make([]map[string]Value, rowCount)
+	values := make([]Row, rowCount)
 	if rowCount > 0 && r.rows != nil {
 		rowPtrs := (*[1 << 20]*C.KmbQueryValue)(unsafe.Pointer(r.rows))[:rowCount:rowCount]
 		rowLens := (*[1 << 20]C.size_t)(unsafe.Pointer(r.row_lengths))[:rowCount:rowCount]
 		for i, rowPtr := range rowPtrs {
 			rowLen := int(rowLens[i])
 			row := make(map[string]Value, rowLen)
+			ordered := make(Row, colCount)
 			if rowPtr != nil && rowLen > 0 {
 				vals := (*[1 << 20]C.KmbQueryValue)(unsafe.Pointer(rowPtr))[:rowLen:rowLen]
 				for j, v := range vals {
 					colName := ""
+					val := convertQueryValue(v, loc)
 					if j < colCount {
 						colName = columns[j]
+						ordered[j] = val
 					}
-					row[colName] = convertQueryValue(v)
+					row[colName] = val
 				}
 			}
 			rows[i] = row
+			values[i] = ordered
 		}
 	}
 
-	return &QueryResult{Columns: columns, Rows: rows}
+	return &QueryResult{Columns: columns, Rows: rows, values: values}
 }
 
 // convertQueryValue converts a C KmbQueryValue to a Go Value.
-func convertQueryValue(v C.KmbQueryValue) Value {
+func convertQueryValue(v C.KmbQueryValue, loc *time.Location) Value {
 	switch int(v.value_type) {
 	case C.KMB_VALUE_BIGINT:
 		return NewInt(int64(v.bigint_val))
@@ -366,7 +861,7 @@ func convertQueryValue(v C.KmbQueryValue) Value {
 	case C.KMB_VALUE_BOOLEAN:
 		return NewBool(v.bool_val != 0)
 	case C.KMB_VALUE_TIMESTAMP:
-		return NewTimestamp(time.Unix(0, int64(v.timestamp_val)))
+		return NewTimestamp(time.Unix(0, int64(v.timestamp_val)).In(loc))
 	default:
 		return NewNull()
 	}
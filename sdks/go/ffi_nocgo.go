@@ -0,0 +1,135 @@
+//go:build !cgo
+
+package kimberlite
+
+// Without CGo the native library is not linked: Connect fails with
+// ErrFFIUnavailable unless the client has a Backend, such as the
+// in-memory one of the kimberlitetest package.
+
+import (
+	"context"
+	"time"
+	"unsafe"
+)
+
+func ffiAvailable() bool {
+	return false
+}
+
+func ffiConnect(string, uint64, string) (unsafe.Pointer, error) {
+	return nil, ErrFFIUnavailable
+}
+
+func ffiDisconnect(unsafe.Pointer) error {
+	return nil
+}
+
+func ffiQuery(unsafe.Pointer, string, []Value, *time.Location) (*QueryResult, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiExecute(unsafe.Pointer, string, []Value) (*ExecResult, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiCreateStream(unsafe.Pointer, string, DataClass, Region) (*StreamInfo, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiAppend(unsafe.Pointer, uint64, uint64, [][]byte) (Offset, error) {
+	return 0, ErrNotConnected
+}
+
+func ffiStreamLength(unsafe.Pointer, uint64) (Offset, error) {
+	return 0, ErrNotConnected
+}
+
+func ffiReadEvents(unsafe.Pointer, uint64, uint64, uint64) ([]Event, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiSubscribe(unsafe.Pointer, uint64, uint64, uint32) (uint64, Offset, error) {
+	return 0, 0, ErrNotConnected
+}
+
+func ffiSubscriptionGrantCredits(unsafe.Pointer, uint64, uint32) error {
+	return ErrNotConnected
+}
+
+func ffiSubscriptionUnsubscribe(unsafe.Pointer, uint64) error {
+	return ErrNotConnected
+}
+
+func ffiSubscriptionNext(unsafe.Pointer, uint64) (Offset, []byte, bool, int, error) {
+	return 0, nil, false, 0, ErrNotConnected
+}
+
+func ffiListTables(unsafe.Pointer) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiDescribeTable(unsafe.Pointer, string) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiListIndexes(unsafe.Pointer, string) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiMaskingPolicyCreate(unsafe.Pointer, string, []byte, []byte) error {
+	return ErrNotConnected
+}
+
+func ffiMaskingPolicyDrop(unsafe.Pointer, string) error {
+	return ErrNotConnected
+}
+
+func ffiMaskingPolicyAttach(unsafe.Pointer, string, string, string) error {
+	return ErrNotConnected
+}
+
+func ffiMaskingPolicyDetach(unsafe.Pointer, string, string) error {
+	return ErrNotConnected
+}
+
+func ffiMaskingPolicyList(unsafe.Pointer, bool) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiAPIKeyRotate(unsafe.Pointer, string) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiServerInfo(unsafe.Pointer) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiTenantCreate(unsafe.Pointer, uint64, string) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiTenantGet(unsafe.Pointer, uint64) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiTenantList(unsafe.Pointer) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiTenantDelete(unsafe.Pointer, uint64) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiErasureRequest(unsafe.Pointer, string) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+func ffiErasureComplete(unsafe.Pointer, string) ([]byte, error) {
+	return nil, ErrNotConnected
+}
+
+// withFFIAudit runs fn: there is no native library to attribute the
+// call to ctx's AuditContext.
+func withFFIAudit(_ context.Context, fn func() error) error {
+	return fn()
+}
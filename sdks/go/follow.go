@@ -0,0 +1,63 @@
+package kimberlite
+
+import (
+	"context"
+	"time"
+)
+
+// Bounds of the interval at which FollowEvents polls a stream that has
+// no new events. The interval starts short, for streams written to
+// often, and doubles up to the limit while the stream stays quiet.
+const (
+	followPollMin = 10 * time.Millisecond
+	followPollMax = 500 * time.Millisecond
+)
+
+// FollowEvents reads events from a stream like ReadEventsContext, but
+// when there are none at from waits up to wait for some to be appended,
+// like tail -f. It returns as soon as a read returns events, and
+// returns no events and no error once wait has passed, so callers can
+// loop on it without polling themselves:
+//
+//	for {
+//	    events, err := client.FollowEvents(ctx, stream, from, 1<<20, 30*time.Second)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    for _, ev := range events {
+//	        handle(ev)
+//	        from = ev.Offset + 1
+//	    }
+//	}
+//
+// FollowEvents polls the server with reads, at an interval growing from
+// 10ms to 500ms while the stream is quiet, on the client's connection.
+// Subscribe receives events as the server pushes them instead, on a
+// connection of its own, and suits consumers that wait on a stream for
+// long. FollowEvents returns ctx's error if ctx is done first.
+func (c *Client) FollowEvents(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64, wait time.Duration) ([]Event, error) {
+	return follow(ctx, c, streamID, from, maxBytes, wait)
+}
+
+func follow(ctx context.Context, source eventReader, streamID StreamID, from Offset, maxBytes uint64, wait time.Duration) ([]Event, error) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	interval := followPollMin
+	for {
+		events, err := source.ReadEventsContext(ctx, streamID, from, maxBytes)
+		if err != nil || len(events) > 0 {
+			return events, err
+		}
+		poll := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			poll.Stop()
+			return nil, ctx.Err()
+		case <-deadline.C:
+			poll.Stop()
+			return nil, nil
+		case <-poll.C:
+		}
+		interval = min(2*interval, followPollMax)
+	}
+}
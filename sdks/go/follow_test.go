@@ -0,0 +1,52 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncLog is a fakeLog safe for appends during reads.
+type syncLog struct {
+	mu  sync.Mutex
+	log fakeLog
+}
+
+func (l *syncLog) ReadEventsContext(ctx context.Context, id StreamID, from Offset, maxBytes uint64) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.log.ReadEventsContext(ctx, id, from, maxBytes)
+}
+
+func TestFollow(t *testing.T) {
+	ctx := context.Background()
+	l := &syncLog{log: fakeLog{}}
+
+	start := time.Now()
+	events, err := follow(ctx, l, 1, 0, 1<<20, 30*time.Millisecond)
+	if err != nil || len(events) != 0 {
+		t.Fatalf("quiet stream: %d events, %v", len(events), err)
+	}
+	if time.Since(start) < 30*time.Millisecond {
+		t.Fatal("returned before the wait passed")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.mu.Lock()
+		l.log.append(t, 1, Envelope{Type: "Admitted"})
+		l.mu.Unlock()
+	}()
+	events, err = follow(ctx, l, 1, 0, 1<<20, 5*time.Second)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("appended event: %d events, %v", len(events), err)
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := follow(cctx, l, 1, 1, 1<<20, 5*time.Second); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("cancelled: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Roles and grants control which tables a connection's role may read.
+// CreateRole and GrantTable issue the SQL statements the server
+// understands, so infrastructure-as-code tooling can manage them
+// without building SQL text:
+//
+//	err := client.CreateRole(ctx, "billing_clerk")
+//	err = client.GrantTable(ctx, "billing_clerk", "patients", "id", "name", "insurer")
+//
+// The server keeps SQL roles and grants in memory and has no statement
+// listing them or granting access to streams; stream access follows
+// the roles of the connection's API key. GrantStream and ListGrants
+// therefore fail with an error wrapping errors.ErrUnsupported until the
+// server exposes them, and tooling must keep the desired grants in its
+// own state.
+
+// Grant is a privilege held by a role.
+type Grant struct {
+	Role string
+	// Table is the granted table, or empty for a stream grant.
+	Table string
+	// Columns are the readable columns of Table; nil means all.
+	Columns []string
+	// Stream is the granted stream of a stream grant.
+	Stream StreamID
+}
+
+// CreateRole creates the SQL role name. It fails if the role exists.
+func (c *Client) CreateRole(ctx context.Context, name string) error {
+	if err := checkIdent("role", name); err != nil {
+		return err
+	}
+	_, err := c.ExecContext(ctx, "CREATE ROLE "+name)
+	return err
+}
+
+// GrantTable grants role SELECT on table, restricted to columns if any
+// are given.
+func (c *Client) GrantTable(ctx context.Context, role, table string, columns ...string) error {
+	sql, err := grantSQL(Grant{Role: role, Table: table, Columns: columns})
+	if err != nil {
+		return err
+	}
+	_, err = c.ExecContext(ctx, sql)
+	return err
+}
+
+// GrantStream grants role read access to a stream. The server does not
+// support stream grants yet; it always fails with an error wrapping
+// errors.ErrUnsupported.
+func (c *Client) GrantStream(ctx context.Context, role string, stream StreamID) error {
+	if err := checkIdent("role", role); err != nil {
+		return err
+	}
+	return fmt.Errorf("kimberlite: grant stream %d to %s: %w", stream, role, errors.ErrUnsupported)
+}
+
+// ListGrants lists the grants of the tenant. The server does not
+// expose its grants yet; it always fails with an error wrapping
+// errors.ErrUnsupported.
+func (c *Client) ListGrants(ctx context.Context) ([]Grant, error) {
+	return nil, fmt.Errorf("kimberlite: list grants: %w", errors.ErrUnsupported)
+}
+
+func grantSQL(g Grant) (string, error) {
+	if err := checkIdent("role", g.Role); err != nil {
+		return "", err
+	}
+	if err := checkIdent("table", g.Table); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("GRANT SELECT")
+	if len(g.Columns) > 0 {
+		for _, col := range g.Columns {
+			if err := checkIdent("column", col); err != nil {
+				return "", err
+			}
+		}
+		b.WriteString(" (" + strings.Join(g.Columns, ", ") + ")")
+	}
+	b.WriteString(" ON " + g.Table + " TO " + g.Role)
+	return b.String(), nil
+}
+
+// checkIdent rejects names that are not plain SQL identifiers, so they
+// can be written into statements unquoted.
+func checkIdent(kind, name string) error {
+	ok := name != "" && isIdentStart(name[0])
+	for i := 1; ok && i < len(name); i++ {
+		ok = isIdentChar(name[i])
+	}
+	if !ok {
+		return fmt.Errorf("kimberlite: invalid %s name %q", kind, name)
+	}
+	return nil
+}
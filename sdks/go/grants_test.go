@@ -0,0 +1,44 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGrantSQL(t *testing.T) {
+	for _, tc := range []struct {
+		grant Grant
+		want  string
+	}{
+		{Grant{Role: "doctor", Table: "patients"}, "GRANT SELECT ON patients TO doctor"},
+		{Grant{Role: "billing_clerk", Table: "patients", Columns: []string{"id", "name"}}, "GRANT SELECT (id, name) ON patients TO billing_clerk"},
+	} {
+		if got, err := grantSQL(tc.grant); err != nil || got != tc.want {
+			t.Errorf("grantSQL(%+v) = %q, %v; want %q", tc.grant, got, err, tc.want)
+		}
+	}
+	for _, g := range []Grant{
+		{Role: "doctor; DROP TABLE x", Table: "patients"},
+		{Role: "doctor", Table: ""},
+		{Role: "doctor", Table: "patients", Columns: []string{"1id"}},
+	} {
+		if _, err := grantSQL(g); err == nil {
+			t.Errorf("grantSQL(%+v) accepted", g)
+		}
+	}
+}
+
+func TestUnsupportedGrants(t *testing.T) {
+	ctx := context.Background()
+	c := &Client{}
+	if err := c.GrantStream(ctx, "doctor", 3); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("GrantStream: %v", err)
+	}
+	if _, err := c.ListGrants(ctx); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("ListGrants: %v", err)
+	}
+	if err := c.CreateRole(ctx, "bad role"); err == nil || errors.Is(err, ErrNotConnected) {
+		t.Fatalf("CreateRole accepted an invalid name: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthStatus is the status of a client or one of its components, as
+// the server's /health and /ready endpoints report it.
+type HealthStatus string
+
+// Health statuses, from best to worst.
+const (
+	HealthOK        HealthStatus = "ok"
+	HealthDegraded  HealthStatus = "degraded"
+	HealthUnhealthy HealthStatus = "unhealthy"
+)
+
+// Healthy reports whether s is HealthOK or HealthDegraded: a degraded
+// client still serves requests.
+func (s HealthStatus) Healthy() bool {
+	return s == HealthOK || s == HealthDegraded
+}
+
+func (s HealthStatus) rank() int {
+	switch s {
+	case HealthOK:
+		return 0
+	case HealthDegraded:
+		return 1
+	}
+	return 2
+}
+
+// ComponentHealth is the outcome of one check of Health.
+type ComponentHealth struct {
+	Status HealthStatus `json:"status"`
+	// Message explains a status other than HealthOK.
+	Message string `json:"message,omitempty"`
+	// Duration is how long the check took.
+	Duration time.Duration `json:"-"`
+}
+
+// Health is the outcome of Client.Health.
+type Health struct {
+	// Status is the worst of the components' statuses.
+	Status HealthStatus `json:"status"`
+	// Components holds the checks by name: "server", the round trip of
+	// Ping, and, with WithHealthURL, the server's own readiness checks
+	// as "server.disk", "server.memory" and so on.
+	Components map[string]ComponentHealth `json:"checks"`
+	// BuildVersion and Uptime describe the server, if it answered.
+	BuildVersion string        `json:"version,omitempty"`
+	Uptime       time.Duration `json:"-"`
+}
+
+// WithHealthURL makes Health include the checks of the server's
+// readiness endpoint at url, the /ready path of its HTTP listener,
+// such as "http://db-1:9090/ready". The endpoint reports the server's
+// disk, memory and data directory, which the client protocol does not.
+func WithHealthURL(url string) Option {
+	return func(c *Client) { c.healthURL = url }
+}
+
+// Ping checks that the server answers the client, round-tripping a
+// request on its connection, authenticated with its token. It returns
+// ctx's error as soon as ctx is done, for use under probe timeouts. A
+// client with a Backend answers while it is open.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.ping(ctx)
+	return err
+}
+
+func (c *Client) ping(ctx context.Context) (*ServerInfo, error) {
+	raw, err := authenticated(ctx, c, func() (raw []byte, err error) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		if c.closed {
+			return nil, ErrNotConnected
+		}
+		err = c.instrument(ctx, &Operation{Name: OpPing}, nil, func(ctx context.Context) error {
+			if c.backend != nil {
+				return nil
+			}
+			raw, err = abandonable(ctx, c, func() (raw []byte, err error) {
+				err = withFFIAudit(ctx, func() error {
+					raw, err = ffiServerInfo(c.kmbHandle)
+					return err
+				})
+				return raw, err
+			})
+			return err
+		})
+		return raw, err
+	})
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return decodeServerInfo(raw)
+}
+
+// Health checks the client's connection to the server and, with
+// WithHealthURL, the server's readiness, for Kubernetes readiness
+// probes and load balancer health checks; HealthHandler serves it over
+// HTTP. Checks that fail make Health unhealthy rather than return an
+// error.
+func (c *Client) Health(ctx context.Context) *Health {
+	h := &Health{Components: make(map[string]ComponentHealth)}
+	start := time.Now()
+	info, err := c.ping(ctx)
+	server := ComponentHealth{Status: HealthOK, Duration: time.Since(start)}
+	if err != nil {
+		server.Status, server.Message = HealthUnhealthy, err.Error()
+	}
+	h.Components["server"] = server
+	if info != nil {
+		h.BuildVersion, h.Uptime = info.BuildVersion, info.Uptime
+	}
+	if c.healthURL != "" {
+		for name, check := range readiness(ctx, c.healthURL) {
+			h.Components["server."+name] = check
+		}
+	}
+	h.Status = HealthOK
+	for _, check := range h.Components {
+		if check.Status.rank() > h.Status.rank() {
+			h.Status = check.Status
+		}
+	}
+	return h
+}
+
+// readiness returns the checks of the readiness endpoint at url, or a
+// single "readiness" check of its status if it answered with none, or
+// failed if it did not answer.
+func readiness(ctx context.Context, url string) map[string]ComponentHealth {
+	failed := func(err error) map[string]ComponentHealth {
+		return map[string]ComponentHealth{"readiness": {Status: HealthUnhealthy, Message: err.Error()}}
+	}
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return failed(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return failed(err)
+	}
+	defer resp.Body.Close()
+	// A server that is not ready answers 503 with its checks.
+	var body struct {
+		Status HealthStatus `json:"status"`
+		Checks map[string]struct {
+			Status     HealthStatus `json:"status"`
+			Message    string       `json:"message"`
+			DurationMS int64        `json:"duration_ms"`
+		} `json:"checks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Status == "" {
+		return failed(fmt.Errorf("kimberlite: readiness endpoint answered %s", resp.Status))
+	}
+	if len(body.Checks) == 0 {
+		return map[string]ComponentHealth{"readiness": {Status: body.Status, Duration: time.Since(start)}}
+	}
+	checks := make(map[string]ComponentHealth, len(body.Checks))
+	for name, check := range body.Checks {
+		checks[name] = ComponentHealth{Status: check.Status, Message: check.Message, Duration: time.Duration(check.DurationMS) * time.Millisecond}
+	}
+	return checks
+}
+
+// HealthHandler returns a handler answering with the client's Health
+// as JSON, with status 200 while it is Healthy and 503 otherwise, for
+// the readiness probes of services using the client:
+//
+//	mux.Handle("/ready", client.HealthHandler())
+func (c *Client) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := c.Health(r.Context())
+		status := http.StatusOK
+		if !h.Status.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,68 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	client, err := Connect("test", WithTenant(1), WithBackend(&recordingBackend{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping = %v", err)
+	}
+	client.Close()
+	if err := client.Ping(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("Ping after Close = %v", err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	ctx := context.Background()
+	ready := `{"status":"degraded","checks":{"disk":{"status":"degraded","message":"disk 92% full","duration_ms":3},"memory":{"status":"ok"}},"version":"0.6.0"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ready))
+	}))
+	defer srv.Close()
+
+	client, err := Connect("test", WithTenant(1), WithBackend(&recordingBackend{}), WithHealthURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := client.Health(ctx)
+	if h.Status != HealthDegraded || h.Components["server"].Status != HealthOK || len(h.Components) != 3 {
+		t.Fatalf("health %+v", h)
+	}
+	if disk := h.Components["server.disk"]; disk.Message != "disk 92% full" || disk.Duration.Milliseconds() != 3 {
+		t.Fatalf("disk %+v", disk)
+	}
+
+	rec := httptest.NewRecorder()
+	client.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	var body map[string]any
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &body) != nil || body["status"] != "degraded" {
+		t.Fatalf("handler answered %d %s", rec.Code, rec.Body)
+	}
+
+	ready = `not json`
+	if h := client.Health(ctx); h.Status != HealthUnhealthy || h.Components["server.readiness"].Status != HealthUnhealthy {
+		t.Fatalf("health with a broken endpoint %+v", h)
+	}
+	ready = `{"status":"ok"}`
+	client.Close()
+	h = client.Health(ctx)
+	if h.Status != HealthUnhealthy || h.Components["server"].Message == "" || h.Components["server.readiness"].Status != HealthOK {
+		t.Fatalf("health after Close %+v", h)
+	}
+	rec = httptest.NewRecorder()
+	client.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("handler after Close answered %d", rec.Code)
+	}
+}
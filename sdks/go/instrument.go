@@ -0,0 +1,189 @@
+package kimberlite
+
+import (
+	"context"
+	"time"
+)
+
+// Operation names reported in Operation.Name.
+const (
+	OpQuery        = "Query"
+	OpExec         = "Exec"
+	OpCreateStream = "CreateStream"
+	OpAppend       = "Append"
+	OpReadEvents   = "ReadEvents"
+	OpStreamLength = "StreamLength"
+	OpSubscribe    = "Subscribe"
+
+	// Catalogue and masking policy operations.
+	OpListTables          = "ListTables"
+	OpDescribeTable       = "DescribeTable"
+	OpListIndexes         = "ListIndexes"
+	OpCreateMaskingPolicy = "CreateMaskingPolicy"
+	OpDropMaskingPolicy   = "DropMaskingPolicy"
+	OpAttachMaskingPolicy = "AttachMaskingPolicy"
+	OpDetachMaskingPolicy = "DetachMaskingPolicy"
+	OpMaskingPolicies     = "MaskingPolicies"
+
+	// Compliance operations. Erase opens and completes an erasure
+	// request around destroying the subject's key.
+	OpRequestErasure   = "RequestErasure"
+	OpCompleteErasure  = "CompleteErasure"
+	OpPlaceLegalHold   = "PlaceLegalHold"
+	OpReleaseLegalHold = "ReleaseLegalHold"
+	OpRotateAPIKey     = "RotateAPIKey"
+
+	// Server and tenant administration operations. Ping is the request
+	// of Ping and Health.
+	OpServerInfo   = "ServerInfo"
+	OpPing         = "Ping"
+	OpCreateTenant = "CreateTenant"
+	OpTenant       = "Tenant"
+	OpTenants      = "Tenants"
+	OpDeleteTenant = "DeleteTenant"
+)
+
+// Operation describes one client call to instrumentation. Request
+// fields are set before the call starts; result fields are filled in
+// by the time it completes.
+type Operation struct {
+	Name   string
+	Tenant TenantID
+	// Statement is the SQL text of Query and Exec calls.
+	Statement string
+	// StreamID is the stream of stream operations, such as Append and
+	// ReadEvents.
+	StreamID StreamID
+	// Table is the table of catalogue and masking policy operations
+	// naming one.
+	Table string
+
+	// Rows is the number of rows returned (Query) or affected (Exec).
+	Rows int64
+	// Events is the number of events appended or read.
+	Events int
+	// BytesOut and BytesIn count event payload bytes sent and received;
+	// for Query, BytesIn is the result's QueryStats.Bytes.
+	BytesOut, BytesIn int64
+	// CacheHit reports a ReadEvents call served from the read cache.
+	CacheHit bool
+}
+
+// Tracer observes client operations, typically by opening a span for
+// each one. The otelkimberlite package provides an OpenTelemetry
+// implementation.
+type Tracer interface {
+	// Start is called when op begins. The returned context is used for
+	// the call, and end is called with its outcome once op's result
+	// fields are filled in.
+	Start(ctx context.Context, op *Operation) (_ context.Context, end func(err error))
+}
+
+// TracePropagator is implemented by Tracers that propagate trace
+// context through events: AppendEnvelopes calls Inject to add the
+// current trace context to each envelope's metadata, so consumers can
+// continue the trace.
+type TracePropagator interface {
+	Inject(ctx context.Context, metadata map[string]string)
+}
+
+// WithTracer sets the tracer notified of every client operation.
+func WithTracer(t Tracer) Option {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+// instrument runs call as op behind the client's interceptors,
+// reporting each invocation to the client's tracer, metrics recorder
+// and logger. call reads its arguments from req, which interceptors
+// may modify. On a break-glass session it fails once the session
+// expired, and otherwise runs the interceptors with the session's
+// audit context.
+func (c *Client) instrument(ctx context.Context, op *Operation, req any, call func(ctx context.Context) error) error {
+	op.Tenant = c.tenant
+	if c.breakGlass != nil {
+		var err error
+		if ctx, err = c.breakGlass.guard(ctx); err != nil {
+			return err
+		}
+	}
+	return c.intercept(ctx, op, req, func(ctx context.Context, op *Operation, _ any) error {
+		return c.observe(ctx, op, call)
+	})
+}
+
+// call runs fn as op on the client's connection: authenticated, behind
+// the interceptors and observed like every operation, with the audit
+// context of the ctx it is given installed for the native library.
+func (c *Client) call(ctx context.Context, op *Operation, fn func(ctx context.Context) error) error {
+	_, err := authenticated(ctx, c, func() (struct{}, error) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		if c.closed {
+			return struct{}{}, ErrNotConnected
+		}
+		return struct{}{}, c.instrument(ctx, op, nil, func(ctx context.Context) error {
+			return withFFIAudit(ctx, func() error { return fn(ctx) })
+		})
+	})
+	return err
+}
+
+func (c *Client) observe(ctx context.Context, op *Operation, call func(ctx context.Context) error) error {
+	if c.tracer == nil && c.metrics == nil && c.logger == nil {
+		return call(ctx)
+	}
+	end := func(error) {}
+	if c.tracer != nil {
+		ctx, end = c.tracer.Start(ctx, op)
+	}
+	if c.metrics != nil {
+		c.metrics.OperationStarted(op)
+	}
+	start := time.Now()
+	err := call(ctx)
+	elapsed := time.Since(start)
+	if c.metrics != nil {
+		c.metrics.OperationDone(op, elapsed, err)
+	}
+	if c.logger != nil {
+		c.logOperation(ctx, op, elapsed, err)
+	}
+	end(err)
+	return err
+}
+
+func (c *Client) connectionEvent(event string) {
+	if c.metrics != nil {
+		c.metrics.ConnectionEvent(c.tenant, event)
+	}
+	c.logConnection(event, nil)
+}
+
+// injectTrace returns metadata with the trace context of ctx added, if
+// the client's tracer propagates it. metadata itself is not modified.
+func (c *Client) injectTrace(ctx context.Context, metadata map[string]string) map[string]string {
+	p, ok := c.tracer.(TracePropagator)
+	if !ok {
+		return metadata
+	}
+	out := make(map[string]string, len(metadata)+2)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	p.Inject(ctx, out)
+	if len(out) == 0 {
+		return metadata
+	}
+	return out
+}
+
+func payloadSize(events [][]byte) int64 {
+	var n int64
+	for _, e := range events {
+		n += int64(len(e))
+	}
+	return n
+}
@@ -0,0 +1,90 @@
+package kimberlite
+
+import "context"
+
+// Invoker runs a client operation, or the rest of the interceptor
+// chain in front of it.
+type Invoker func(ctx context.Context, op *Operation, req any) error
+
+// Interceptor wraps every client operation. It receives the operation,
+// its request, and next, which runs the remaining interceptors and then
+// the call itself:
+//
+//	retry := func(ctx context.Context, op *kimberlite.Operation, req any, next kimberlite.Invoker) error {
+//	    err := next(ctx, op, req)
+//	    for i := 0; i < 3 && errors.Is(err, kimberlite.ErrTimeout); i++ {
+//	        err = next(ctx, op, req)
+//	    }
+//	    return err
+//	}
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(1), kimberlite.WithInterceptors(retry))
+//
+// An interceptor may skip next and return its own error, call next
+// several times, or modify the request's fields before calling it. It
+// must pass op and req through unchanged; results are delivered to the
+// caller directly, with op's result fields reporting them.
+//
+// req is one of *StatementRequest (OpQuery, OpExec),
+// *CreateStreamRequest, *AppendRequest or *ReadEventsRequest. The other
+// operations, such as catalogue, masking policy, erasure and
+// administration calls, have no request to modify: req is nil, and op
+// names their stream or table.
+type Interceptor func(ctx context.Context, op *Operation, req any, next Invoker) error
+
+// StatementRequest is the request of Query and Exec operations.
+type StatementRequest struct {
+	SQL  string
+	Args []Value
+}
+
+// CreateStreamRequest is the request of CreateStream operations.
+type CreateStreamRequest struct {
+	Name   string
+	Class  DataClass
+	Region Region
+}
+
+// AppendRequest is the request of Append operations.
+type AppendRequest struct {
+	StreamID StreamID
+	Events   [][]byte
+	// ExpectedOffset is the offset AppendExpected expects the stream
+	// to be at. AnyOffset is set instead for plain appends, which the
+	// client sends expecting the stream's length, read first, and
+	// sends again if another writer appended in between.
+	ExpectedOffset Offset
+	AnyOffset      bool
+}
+
+// ReadEventsRequest is the request of ReadEvents operations.
+type ReadEventsRequest struct {
+	StreamID StreamID
+	From     Offset
+	MaxBytes uint64
+}
+
+// WithInterceptors adds interceptors to the client. The first one
+// added is outermost. Tracing, metrics and logging run inside the
+// chain, so each call to next is observed as one operation.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *Client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// intercept runs terminal behind the client's interceptors.
+func (c *Client) intercept(ctx context.Context, op *Operation, req any, terminal Invoker) error {
+	if len(c.interceptors) == 0 {
+		return terminal(ctx, op, req)
+	}
+	return c.interceptors[0](ctx, op, req, c.chain(1, terminal))
+}
+
+func (c *Client) chain(i int, terminal Invoker) Invoker {
+	if i == len(c.interceptors) {
+		return terminal
+	}
+	return func(ctx context.Context, op *Operation, req any) error {
+		return c.interceptors[i](ctx, op, req, c.chain(i+1, terminal))
+	}
+}
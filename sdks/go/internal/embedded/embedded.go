@@ -0,0 +1,55 @@
+// Package embedded registers the in-process servers of the kimberlitetest
+// package, so that kimberlite.Connect reaches them by address. Only
+// addresses with the reserved kimberlitetest:// scheme are looked up,
+// and only packages of this module can register one, so a client of a
+// real server cannot be rerouted to a fake.
+package embedded
+
+import (
+	"strings"
+	"sync"
+)
+
+// Scheme prefixes the addresses of embedded servers.
+const Scheme = "kimberlitetest://"
+
+var servers struct {
+	sync.Mutex
+	open map[string]func(tenant uint64) any
+}
+
+// IsAddr reports whether addr names an embedded server.
+func IsAddr(addr string) bool {
+	return strings.HasPrefix(addr, Scheme)
+}
+
+// Register serves the clients of addr, which must have the Scheme
+// prefix, with the backend open returns for their tenant. The returned
+// function removes the registration.
+func Register(addr string, open func(tenant uint64) any) (unregister func()) {
+	if !IsAddr(addr) {
+		panic("embedded: address " + addr + " lacks the " + Scheme + " scheme")
+	}
+	servers.Lock()
+	defer servers.Unlock()
+	if servers.open == nil {
+		servers.open = make(map[string]func(uint64) any)
+	}
+	servers.open[addr] = open
+	return func() {
+		servers.Lock()
+		defer servers.Unlock()
+		delete(servers.open, addr)
+	}
+}
+
+// Lookup returns the backend registered at addr for tenant, or nil.
+func Lookup(addr string, tenant uint64) any {
+	servers.Lock()
+	open := servers.open[addr]
+	servers.Unlock()
+	if open == nil {
+		return nil
+	}
+	return open(tenant)
+}
@@ -0,0 +1,30 @@
+// Package sqlident checks the table names that packages keeping state
+// in SQL tables write into their statements. The names cannot be bound
+// as parameters, so they are checked instead of quoted: quoting rules
+// differ between databases, and a checked name needs none.
+package sqlident
+
+import "fmt"
+
+// Check returns an error naming kind unless name is an identifier of
+// ASCII letters, digits and underscores not starting with a digit, or
+// several joined by dots, as in a schema-qualified table name.
+func Check(kind, name string) error {
+	start := true
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z'):
+			start = false
+		case c >= '0' && c <= '9' && !start:
+		case c == '.' && !start:
+			start = true
+		default:
+			return fmt.Errorf("invalid %s name %q", kind, name)
+		}
+	}
+	if start {
+		return fmt.Errorf("invalid %s name %q", kind, name)
+	}
+	return nil
+}
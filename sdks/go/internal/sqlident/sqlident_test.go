@@ -0,0 +1,16 @@
+package sqlident
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	for _, name := range []string{"outbox", "_t1", "app.checkpoints", "Schema_Migrations"} {
+		if err := Check("table", name); err != nil {
+			t.Errorf("Check(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "1t", "a.", ".a", "a..b", "a b", "t; DROP TABLE x", `"t"`, "tä"} {
+		if err := Check("table", name); err == nil {
+			t.Errorf("Check(%q) accepted", name)
+		}
+	}
+}
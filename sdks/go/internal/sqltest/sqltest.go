@@ -0,0 +1,93 @@
+// Package sqltest provides an in-memory database/sql database for the
+// tests of packages that keep state in SQL tables, such as checkpoint
+// stores, outboxes and indexes. A Handler stands in for the tables,
+// answering the statements the package under test runs.
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Handler runs statements on in-memory tables. Exec and Query are
+// called one at a time, with the statement's text and arguments, and
+// return an error for statements they do not understand.
+type Handler interface {
+	Exec(query string, args []driver.Value) (driver.Result, error)
+	Query(query string, args []driver.Value) (columns []string, rows [][]driver.Value, err error)
+}
+
+// Open returns a database whose statements h runs, closed when t ends.
+// A statement takes as many arguments as it has $ placeholders.
+// Transactions are accepted but isolate nothing: their statements take
+// effect as they run, and rolling back undoes none of them.
+func Open(t testing.TB, h Handler) *sql.DB {
+	db := sql.OpenDB(&connector{h: h})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type connector struct {
+	mu sync.Mutex
+	h  Handler
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) { return conn{c}, nil }
+func (c *connector) Driver() driver.Driver                        { return c }
+func (c *connector) Open(string) (driver.Conn, error)             { return conn{c}, nil }
+
+type conn struct{ c *connector }
+
+func (c conn) Prepare(query string) (driver.Stmt, error) { return stmt{c.c, query}, nil }
+func (c conn) Close() error                              { return nil }
+func (c conn) Begin() (driver.Tx, error)                 { return tx{}, nil }
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type stmt struct {
+	c     *connector
+	query string
+}
+
+func (s stmt) Close() error  { return nil }
+func (s stmt) NumInput() int { return strings.Count(s.query, "$") }
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	return s.c.h.Exec(s.query, args)
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	columns, values, err := s.c.h.Query(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: columns, rows: values}, nil
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
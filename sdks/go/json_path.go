@@ -0,0 +1,170 @@
+package kimberlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a path to a value inside a JSON document, written as in
+// SQL/JSON: "$" for the document, ".key" or ["key"] for a member of an
+// object and [n] for an element of an array, e.g. "$.patient.id" or
+// "$.allergies[0]". The leading "$" may be left out.
+type JSONPath struct {
+	steps []JSONPathStep
+}
+
+// JSONPathStep is one step of a JSONPath: a member of an object by Key,
+// or an element of an array by Index when IsIndex is set.
+type JSONPathStep struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// ParseJSONPath parses a path such as "$.patient.id".
+func ParseJSONPath(path string) (JSONPath, error) {
+	var p JSONPath
+	s := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	for s != "" {
+		switch s[0] {
+		case '.':
+			j := 1
+			for j < len(s) && s[j] != '.' && s[j] != '[' {
+				j++
+			}
+			if j == 1 {
+				return JSONPath{}, fmt.Errorf("kimberlite: json path %q: empty key", path)
+			}
+			p.steps = append(p.steps, JSONPathStep{Key: s[1:j]})
+			s = s[j:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return JSONPath{}, fmt.Errorf("kimberlite: json path %q: unclosed [", path)
+			}
+			inner := s[1:end]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				p.steps = append(p.steps, JSONPathStep{Key: inner[1 : len(inner)-1]})
+			} else if n, err := strconv.Atoi(inner); err == nil && n >= 0 {
+				p.steps = append(p.steps, JSONPathStep{Index: n, IsIndex: true})
+			} else {
+				return JSONPath{}, fmt.Errorf("kimberlite: json path %q: invalid step [%s]", path, inner)
+			}
+			s = s[end+1:]
+		default:
+			if len(p.steps) > 0 || strings.HasPrefix(strings.TrimSpace(path), "$") {
+				return JSONPath{}, fmt.Errorf("kimberlite: json path %q: unexpected %q", path, s[0])
+			}
+			s = "." + s
+		}
+	}
+	return p, nil
+}
+
+// Steps returns the steps of p, from the document down.
+func (p JSONPath) Steps() []JSONPathStep {
+	return p.steps
+}
+
+func (p JSONPath) String() string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, st := range p.steps {
+		switch {
+		case st.IsIndex:
+			b.WriteString("[" + strconv.Itoa(st.Index) + "]")
+		case isIdentifier(st.Key):
+			b.WriteString("." + st.Key)
+		default:
+			b.WriteString("[" + strconv.Quote(st.Key) + "]")
+		}
+	}
+	return b.String()
+}
+
+// Path returns the value at path in the JSON document v, such as a
+// JSON column or an event payload read as one:
+//
+//	id, err := row["payload"].Path("$.patient.id")
+//
+// Strings, numbers and booleans become text, integer or float, and
+// boolean values; objects and arrays become JSON values. A path that
+// is not in the document gives NULL, as JSON null does.
+func (v Value) Path(path string) (Value, error) {
+	raw, err := v.rawPath(path)
+	if err != nil || raw == nil {
+		return NewNull(), err
+	}
+	var x any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&x); err != nil {
+		return Value{}, fmt.Errorf("kimberlite: decode json value: %w", err)
+	}
+	switch t := x.(type) {
+	case nil:
+		return NewNull(), nil
+	case string:
+		return NewText(t), nil
+	case bool:
+		return NewBool(t), nil
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return NewInt(n), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return Value{}, fmt.Errorf("kimberlite: decode json number %s: %w", t, err)
+		}
+		return NewFloat(f), nil
+	}
+	return NewRawJSON(raw), nil
+}
+
+// DecodePath decodes the value at path in the JSON document v into
+// target using encoding/json, leaving target untouched if the path is
+// not in the document.
+func (v Value) DecodePath(path string, target any) error {
+	raw, err := v.rawPath(path)
+	if err != nil || raw == nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Errorf("kimberlite: decode json value at %s: %w", path, err)
+	}
+	return nil
+}
+
+// rawPath returns the encoded value at path in v, or nil if there is
+// none.
+func (v Value) rawPath(path string) (json.RawMessage, error) {
+	p, err := ParseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := v.rawJSON()
+	if !ok {
+		return nil, fmt.Errorf("kimberlite: cannot decode %s value as json", v.Type)
+	}
+	for _, st := range p.steps {
+		if st.IsIndex {
+			var elems []json.RawMessage
+			if json.Unmarshal(raw, &elems) != nil || st.Index >= len(elems) {
+				return nil, nil
+			}
+			raw = elems[st.Index]
+			continue
+		}
+		var members map[string]json.RawMessage
+		if json.Unmarshal(raw, &members) != nil {
+			return nil, nil
+		}
+		if raw, ok = members[st.Key]; !ok {
+			return nil, nil
+		}
+	}
+	return raw, nil
+}
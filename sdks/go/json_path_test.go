@@ -0,0 +1,69 @@
+package kimberlite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"$":                     "$",
+		"$.patient.id":          "$.patient.id",
+		"patient.id":            "$.patient.id",
+		`$.allergies[0]`:        "$.allergies[0]",
+		`$["first name"].given`: `$["first name"].given`,
+		`$['ward']`:             "$.ward",
+	} {
+		p, err := ParseJSONPath(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if p.String() != want {
+			t.Errorf("%s: String() = %q, want %q", path, p.String(), want)
+		}
+	}
+	for _, path := range []string{"$..id", "$.a[", "$.a[-1]", "$.a[x]", "$a"} {
+		if p, err := ParseJSONPath(path); err == nil {
+			t.Errorf("%s: parsed %v", path, p.Steps())
+		}
+	}
+	p, _ := ParseJSONPath("$.allergies[2]")
+	want := []JSONPathStep{{Key: "allergies"}, {Index: 2, IsIndex: true}}
+	if !reflect.DeepEqual(p.Steps(), want) {
+		t.Fatalf("steps = %v", p.Steps())
+	}
+}
+
+func TestValuePath(t *testing.T) {
+	doc := NewRawJSON([]byte(`{"patient": {"id": 7, "weight": 71.5, "name": "Ana", "admitted": true, "allergies": ["penicillin", "latex"], "ward": null}}`))
+	for path, want := range map[string]Value{
+		"$.patient.id":           NewInt(7),
+		"$.patient.weight":       NewFloat(71.5),
+		"$.patient.name":         NewText("Ana"),
+		"$.patient.admitted":     NewBool(true),
+		"$.patient.allergies[1]": NewText("latex"),
+		"$.patient.ward":         NewNull(),
+		"$.patient.missing":      NewNull(),
+		"$.patient.allergies[5]": NewNull(),
+		"$.patient.name.first":   NewNull(),
+	} {
+		got, err := doc.Path(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", path, got, want)
+		}
+	}
+	allergies, err := doc.Path("$.patient.allergies")
+	if err != nil || allergies.Type != ValueTypeJSON || string(allergies.AsRawJSON()) != `["penicillin", "latex"]` {
+		t.Fatalf("allergies = %v, %v", allergies, err)
+	}
+
+	// JSON columns arrive from the server as text.
+	var names []string
+	if err := NewText(`{"allergies": ["penicillin"]}`).DecodePath("$.allergies", &names); err != nil || !reflect.DeepEqual(names, []string{"penicillin"}) {
+		t.Fatalf("names = %v, %v", names, err)
+	}
+	if _, err := NewInt(1).Path("$.id"); err == nil {
+		t.Fatal("Path of an integer succeeded")
+	}
+}
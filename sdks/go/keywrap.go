@@ -0,0 +1,203 @@
+package kimberlite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyWrapper encrypts data keys under a master key that never leaves a
+// KMS. The kms package and its subpackages wrap AWS KMS, Google Cloud
+// KMS, Azure Key Vault and HashiCorp Vault.
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// EnvelopeKeys is a KeyProvider doing envelope encryption: events are
+// encrypted with random data keys, and each data key is wrapped by the
+// master key of its data class. The wrapped key travels in the key ID
+// of every frame it encrypts,
+//
+//	<master key name>:<unpadded base64url of the wrapped data key>
+//
+// so readers need only access to the master key. Data keys and
+// unwrapped keys are cached to keep KMS calls off the append path.
+type EnvelopeKeys struct {
+	ttl time.Duration
+	max int
+	now func() time.Time
+
+	mu        sync.Mutex
+	masters   map[string]KeyWrapper
+	classes   map[DataClass]string
+	current   map[DataClass]cachedKey
+	unwrapped map[string]cachedKey
+}
+
+type cachedKey struct {
+	id      string
+	key     []byte
+	expires time.Time
+}
+
+// EnvelopeOption configures EnvelopeKeys.
+type EnvelopeOption func(*EnvelopeKeys)
+
+// WithMasterKey wraps the data keys of class with w. name identifies
+// the master key in key IDs and must not contain ':'. Register the
+// same name for readers; a class may be omitted to only decrypt.
+func WithMasterKey(name string, w KeyWrapper, classes ...DataClass) EnvelopeOption {
+	return func(k *EnvelopeKeys) {
+		k.masters[name] = w
+		for _, class := range classes {
+			k.classes[class] = name
+		}
+	}
+}
+
+// WithKeyCacheTTL sets how long a data key encrypts new events before
+// it is replaced, and how long unwrapped keys stay cached. The default
+// is five minutes.
+func WithKeyCacheTTL(d time.Duration) EnvelopeOption {
+	return func(k *EnvelopeKeys) { k.ttl = d }
+}
+
+// WithKeyCacheSize bounds the number of cached unwrapped keys. The
+// default is 1000.
+func WithKeyCacheSize(n int) EnvelopeOption {
+	return func(k *EnvelopeKeys) { k.max = n }
+}
+
+// NewEnvelopeKeys returns an envelope-encryption KeyProvider.
+func NewEnvelopeKeys(opts ...EnvelopeOption) (*EnvelopeKeys, error) {
+	k := &EnvelopeKeys{
+		masters:   make(map[string]KeyWrapper),
+		classes:   make(map[DataClass]string),
+		ttl:       5 * time.Minute,
+		max:       1000,
+		now:       time.Now,
+		current:   make(map[DataClass]cachedKey),
+		unwrapped: make(map[string]cachedKey),
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	for name := range k.masters {
+		if name == "" || strings.Contains(name, ":") {
+			return nil, fmt.Errorf("kimberlite: invalid master key name %q", name)
+		}
+	}
+	return k, nil
+}
+
+// EncryptionKey implements KeyProvider, generating and wrapping a new
+// data key when the class has none or its key has expired.
+func (k *EnvelopeKeys) EncryptionKey(ctx context.Context, class DataClass) (string, []byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	name, ok := k.classes[class]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: no master key for %s data", ErrKeyUnavailable, class)
+	}
+	if c, ok := k.current[class]; ok && k.now().Before(c.expires) {
+		return c.id, c.key, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, fmt.Errorf("kimberlite: generate data key: %w", err)
+	}
+	wrapped, err := k.masters[name].WrapKey(ctx, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("kimberlite: wrap data key with %s: %w", name, err)
+	}
+	c := cachedKey{id: name + ":" + base64.RawURLEncoding.EncodeToString(wrapped), key: key, expires: k.now().Add(k.ttl)}
+	k.current[class] = c
+	k.cache(c)
+	return c.id, c.key, nil
+}
+
+// DecryptionKey implements KeyProvider.
+func (k *EnvelopeKeys) DecryptionKey(ctx context.Context, keyID string) ([]byte, error) {
+	name, enc, ok := strings.Cut(keyID, ":")
+	k.mu.Lock()
+	if c, ok := k.unwrapped[keyID]; ok && k.now().Before(c.expires) {
+		k.mu.Unlock()
+		return c.key, nil
+	}
+	w := k.masters[name]
+	k.mu.Unlock()
+
+	if !ok || w == nil {
+		return nil, fmt.Errorf("%w: unknown master key %q", ErrKeyUnavailable, name)
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: malformed wrapped key: %w", err)
+	}
+	key, err := w.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: unwrap data key with %s: %w", name, err)
+	}
+	k.mu.Lock()
+	k.cache(cachedKey{id: keyID, key: key, expires: k.now().Add(k.ttl)})
+	k.mu.Unlock()
+	return key, nil
+}
+
+// Rotate retires the data keys encrypting new events of classes, or of
+// every class if none are given, so the next appends wrap fresh ones.
+// Retired keys still decrypt the events they encrypted.
+func (k *EnvelopeKeys) Rotate(classes ...DataClass) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(classes) == 0 {
+		clear(k.current)
+		return
+	}
+	for _, class := range classes {
+		delete(k.current, class)
+	}
+}
+
+// RotateMasterKey makes w, named name, the master key of classes and
+// retires their data keys. Earlier master keys stay registered to
+// decrypt existing events until they are re-encrypted; see
+// Client.RotateAndReencrypt.
+func (k *EnvelopeKeys) RotateMasterKey(name string, w KeyWrapper, classes ...DataClass) error {
+	if name == "" || strings.Contains(name, ":") {
+		return fmt.Errorf("kimberlite: invalid master key name %q", name)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.masters[name] = w
+	for _, class := range classes {
+		k.classes[class] = name
+		delete(k.current, class)
+	}
+	return nil
+}
+
+// cache adds c to the unwrapped keys, evicting expired entries, and an
+// arbitrary one if the cache is still full. k.mu must be held.
+func (k *EnvelopeKeys) cache(c cachedKey) {
+	if len(k.unwrapped) >= k.max {
+		now := k.now()
+		for id, e := range k.unwrapped {
+			if !now.Before(e.expires) {
+				delete(k.unwrapped, id)
+			}
+		}
+		for id := range k.unwrapped {
+			if len(k.unwrapped) < k.max {
+				break
+			}
+			delete(k.unwrapped, id)
+		}
+	}
+	k.unwrapped[c.id] = c
+}
@@ -1,6 +1,14 @@
 package kimberlite
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -101,6 +109,51 @@ func TestValueTimestamp(t *testing.T) {
 	}
 }
 
+func TestValueJSON(t *testing.T) {
+	type chart struct {
+		Allergies []string `json:"allergies"`
+	}
+	v, err := NewJSON(chart{Allergies: []string{"penicillin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Type != ValueTypeJSON {
+		t.Fatalf("Type = %v, want json", v.Type)
+	}
+	var got chart
+	if err := v.AsJSON(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Allergies) != 1 || got.Allergies[0] != "penicillin" {
+		t.Fatalf("AsJSON() = %+v", got)
+	}
+
+	// JSON columns arrive as text over the wire.
+	var fromText chart
+	if err := NewText(`{"allergies":["latex"]}`).AsJSON(&fromText); err != nil {
+		t.Fatal(err)
+	}
+	if fromText.Allergies[0] != "latex" {
+		t.Fatalf("AsJSON() from text = %+v", fromText)
+	}
+
+	if err := NewInt(1).AsJSON(&got); err == nil {
+		t.Fatal("AsJSON on an integer should fail")
+	}
+}
+
+func TestFloatWireText(t *testing.T) {
+	for f, want := range map[float64]string{1.5: "1.5", -0.1: "-0.1", 1e21: "1e+21", 3: "3"} {
+		text, err := NewFloat(f).wireText()
+		if err != nil || text != want {
+			t.Fatalf("wire text of %v = %q, %v; want %q", f, text, err, want)
+		}
+		if back, _ := strconv.ParseFloat(text, 64); back != f {
+			t.Fatalf("wire text %q parses as %v", text, back)
+		}
+	}
+}
+
 func TestKimberliteError(t *testing.T) {
 	err := &KimberliteError{
 		Code:    "AUTH_FAILED",
@@ -154,3 +207,474 @@ func TestQueryResult(t *testing.T) {
 		t.Fatal("expected Alice in first row")
 	}
 }
+
+func TestValueArray(t *testing.T) {
+	v := NewInts([]int64{1, 2, 3})
+	if got := v.AsInts(); len(got) != 3 || got[2] != 3 {
+		t.Fatalf("AsInts() = %v", got)
+	}
+
+	// Array columns arrive from the server as JSON text.
+	fromWire := NewText(`["a","b"]`)
+	if got := fromWire.AsTexts(); len(got) != 2 || got[1] != "b" {
+		t.Fatalf("AsTexts() = %v", got)
+	}
+	if NewText("not an array").AsArray() != nil {
+		t.Fatal("AsArray on plain text should be nil")
+	}
+}
+
+func TestExpandArrayArgs(t *testing.T) {
+	sql, args := expandArrayArgs(
+		"SELECT * FROM patients WHERE ward = $1 AND id = ANY($2) AND note <> '= ANY($2)'",
+		[]Value{NewText("icu"), NewInts([]int64{10, 20, 30})},
+	)
+	want := "SELECT * FROM patients WHERE ward = $1 AND id IN ($2, $3, $4) AND note <> '= ANY($2)'"
+	if sql != want {
+		t.Fatalf("sql = %q, want %q", sql, want)
+	}
+	if len(args) != 4 || args[1].AsInt() != 10 || args[3].AsInt() != 30 {
+		t.Fatalf("unexpected args: %+v", args)
+	}
+
+	sql, args = expandArrayArgs("SELECT 1 WHERE id = any ( $1 )", []Value{NewInts(nil)})
+	if sql != "SELECT 1 WHERE id IN ($1)" || !args[0].IsNull() {
+		t.Fatalf("empty array: sql = %q, args = %+v", sql, args)
+	}
+}
+
+func TestIntervalParseAndFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Interval
+	}{
+		{"P1Y2M3DT4H5M6.5S", Interval{Months: 14, Days: 3, Time: 4*time.Hour + 5*time.Minute + 6500*time.Millisecond}},
+		{"1 year 2 mons 3 days 04:05:06.5", Interval{Months: 14, Days: 3, Time: 4*time.Hour + 5*time.Minute + 6500*time.Millisecond}},
+		{"-P1D", Interval{Days: -1}},
+		{"2 days ago", Interval{Days: -2}},
+		{"90m", Interval{Time: 90 * time.Minute}},
+		{"PT0S", Interval{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseInterval(tt.in)
+		if err != nil {
+			t.Fatalf("ParseInterval(%q): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseInterval(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		back, err := ParseInterval(got.String())
+		if err != nil || back != got {
+			t.Errorf("round trip of %q via %q = %+v, %v", tt.in, got.String(), back, err)
+		}
+	}
+
+	if _, err := ParseInterval("3 fortnights"); err == nil {
+		t.Fatal("unknown unit should fail")
+	}
+}
+
+func TestValueInterval(t *testing.T) {
+	v := NewText("1 mon 1 day")
+	if got := v.AsDuration(); got != 31*24*time.Hour {
+		t.Fatalf("AsDuration() = %v", got)
+	}
+	start := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	if got := v.AsInterval().AddTo(start); !got.Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("AddTo() = %v", got)
+	}
+	if NewDuration(time.Hour).AsInterval().Time != time.Hour {
+		t.Fatal("NewDuration should round-trip")
+	}
+}
+
+func TestValueOKAccessors(t *testing.T) {
+	if n, ok := NewInt(0).IntOK(); !ok || n != 0 {
+		t.Fatal("IntOK on integer 0 should report ok")
+	}
+	if _, ok := NewNull().IntOK(); ok {
+		t.Fatal("IntOK on NULL should not report ok")
+	}
+	if _, ok := NewText("0").IntOK(); ok {
+		t.Fatal("IntOK on text should not report ok")
+	}
+	if _, ok := NewText("P1D").IntervalOK(); !ok {
+		t.Fatal("IntervalOK should parse interval text")
+	}
+}
+
+func TestGet(t *testing.T) {
+	s, err := Get[string](NewText("Alice"))
+	if err != nil || s != "Alice" {
+		t.Fatalf("Get[string] = %q, %v", s, err)
+	}
+	if _, err := Get[int64](NewNull()); !errors.Is(err, ErrNullValue) {
+		t.Fatalf("Get on NULL: got %v, want ErrNullValue", err)
+	}
+	if _, err := Get[int64](NewText("1")); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("Get mismatch: got %v, want ErrTypeMismatch", err)
+	}
+	ids, err := Get[[]int64](NewText("[4,5]"))
+	if err != nil || len(ids) != 2 || ids[1] != 5 {
+		t.Fatalf("Get[[]int64] = %v, %v", ids, err)
+	}
+	if _, err := Get[[]int64](NewText(`[4,"5"]`)); !errors.Is(err, ErrTypeMismatch) || !strings.Contains(err.Error(), "element 1") {
+		t.Fatalf("Get[[]int64] with a text element: got %v, want ErrTypeMismatch naming element 1", err)
+	}
+	if _, err := Get[[]string](NewText(`["a",null]`)); !errors.Is(err, ErrTypeMismatch) || !strings.Contains(err.Error(), "element 1") {
+		t.Fatalf("Get[[]string] with a NULL element: got %v, want ErrTypeMismatch naming element 1", err)
+	}
+	if v, err := Get[Value](NewNull()); err != nil || !v.IsNull() {
+		t.Fatalf("Get[Value] on NULL = %+v, %v", v, err)
+	}
+}
+
+func TestValueDriverRoundTrip(t *testing.T) {
+	now := time.Now()
+	for _, in := range []Value{NewNull(), NewInt(7), NewFloat(1.5), NewText("x"), NewBool(true), NewBytes([]byte{1}), NewTimestamp(now)} {
+		dv, err := in.Value()
+		if err != nil {
+			t.Fatalf("Value() for %s: %v", in.Type, err)
+		}
+		var out Value
+		if err := out.Scan(dv); err != nil {
+			t.Fatalf("Scan(%v): %v", dv, err)
+		}
+		if out.Type != in.Type {
+			t.Fatalf("round trip changed type %s -> %s", in.Type, out.Type)
+		}
+	}
+
+	dv, err := NewInts([]int64{1, 2}).Value()
+	if err != nil || dv != "[1,2]" {
+		t.Fatalf("array Value() = %v, %v", dv, err)
+	}
+}
+
+func TestValueOf(t *testing.T) {
+	var nilPtr *int
+	n := 3
+	tests := []struct {
+		in   any
+		want ValueType
+	}{
+		{nil, ValueTypeNull},
+		{nilPtr, ValueTypeNull},
+		{&n, ValueTypeInteger},
+		{uint64(9), ValueTypeInteger},
+		{"s", ValueTypeText},
+		{time.Second, ValueTypeInterval},
+		{[]string{"a"}, ValueTypeArray},
+	}
+	for _, tt := range tests {
+		v, err := ValueOf(tt.in)
+		if err != nil {
+			t.Fatalf("ValueOf(%#v): %v", tt.in, err)
+		}
+		if v.Type != tt.want {
+			t.Errorf("ValueOf(%#v).Type = %s, want %s", tt.in, v.Type, tt.want)
+		}
+	}
+	if _, err := ValueOf(struct{}{}); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("ValueOf(struct{}{}) err = %v", err)
+	}
+}
+
+func TestColumnTypes(t *testing.T) {
+	result := QueryResult{
+		Columns: []string{"id", "balance", "note"},
+		Rows: []map[string]Value{
+			{"id": NewInt(1), "balance": NewText("10.50"), "note": NewNull()},
+		},
+	}
+
+	inferred := result.ColumnTypes()
+	if inferred[0].DatabaseTypeName != "BIGINT" || inferred[0].Declared {
+		t.Fatalf("inferred id = %+v", inferred[0])
+	}
+	if inferred[2].DatabaseTypeName != "UNKNOWN" {
+		t.Fatalf("all-NULL column should be UNKNOWN, got %+v", inferred[2])
+	}
+
+	desc, err := decodeTableDescription([]byte(`{"table_name":"accounts","columns":[
+		{"name":"id","data_type":"BIGINT","nullable":false,"primary_key":true},
+		{"name":"BALANCE","data_type":"DECIMAL(10,2)","nullable":true,"primary_key":false}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.ApplyColumnTypes(desc.Columns)
+
+	declared := result.ColumnTypes()
+	if !declared[0].PrimaryKey || declared[0].Nullable || !declared[0].NullableKnown {
+		t.Fatalf("declared id = %+v", declared[0])
+	}
+	b := declared[1]
+	if b.Name != "balance" || b.DatabaseTypeName != "DECIMAL" || b.Precision != 10 || b.Scale != 2 || !b.HasPrecisionScale {
+		t.Fatalf("declared balance = %+v", b)
+	}
+	if declared[2].Declared {
+		t.Fatal("note has no declared metadata")
+	}
+}
+
+func TestRowIndexAccess(t *testing.T) {
+	result := QueryResult{
+		Columns: []string{"id", "name"},
+		Rows: []map[string]Value{
+			{"id": NewInt(1), "name": NewText("Alice")},
+		},
+	}
+	row := result.Row(0)
+	name := result.ColumnIndex("name")
+	if row.Int(0) != 1 || row.Text(name) != "Alice" {
+		t.Fatalf("unexpected row: %+v", row)
+	}
+	if !row.IsNull(5) || row.Text(-1) != "" {
+		t.Fatal("out-of-range access should yield NULL/zero")
+	}
+	if result.Row(1) != nil || result.ColumnIndex("missing") != -1 {
+		t.Fatal("missing row/column should be nil/-1")
+	}
+}
+
+func TestWallTimestamp(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	appt := time.Date(2026, 3, 1, 9, 0, 0, 0, berlin)
+
+	v := NewWallTimestamp(appt)
+	if got := v.AsTimestamp(); got.Hour() != 9 || got.Location() != time.UTC {
+		t.Fatalf("wall timestamp stored as %v", got)
+	}
+	if got := v.AsWallTime(berlin); !got.Equal(appt) {
+		t.Fatalf("AsWallTime() = %v, want %v", got, appt)
+	}
+
+	instant := NewTimestamp(appt)
+	if got := instant.AsTimestampIn(time.UTC); got.Hour() != 8 || !got.Equal(appt) {
+		t.Fatalf("AsTimestampIn(UTC) = %v", got)
+	}
+}
+
+func TestBlobBinding(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x00, 0xFF, 0x10}, 1000)
+
+	inline := NewBytes(payload)
+	streamed := NewBlob(bytes.NewReader(payload), int64(len(payload)))
+	if inline.bytesLen() != len(payload) || streamed.bytesLen() != len(payload) {
+		t.Fatalf("bytesLen() = %d, %d, want %d", inline.bytesLen(), streamed.bytesLen(), len(payload))
+	}
+	a := make([]byte, inline.bytesLen())
+	b := make([]byte, streamed.bytesLen())
+	if err := inline.writeBytesTo(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := streamed.writeBytesTo(b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, payload) || !bytes.Equal(b, payload) {
+		t.Fatal("bound bytes differ from the payload")
+	}
+
+	// The server returns BYTES columns as base64 text.
+	got, err := NewText(base64.StdEncoding.EncodeToString(payload)).DecodeBytes()
+	if err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("DecodeBytes() round trip failed: %v", err)
+	}
+
+	short := NewBlob(bytes.NewReader(payload[:10]), 20)
+	if err := short.writeBytesTo(make([]byte, short.bytesLen())); err == nil {
+		t.Fatal("short blob reader should fail to bind")
+	}
+
+	// Servers must advertise that they accept bytes parameters.
+	c := &Client{}
+	c.caps.known, c.caps.caps = true, []string{"query", "append"}
+	if err := c.checkBytesParams([]Value{NewInt(1), inline}); !errors.Is(err, ErrBytesParamsUnsupported) {
+		t.Fatalf("bytes parameter on an older server: %v", err)
+	}
+	if err := c.checkBytesParams([]Value{NewInt(1)}); err != nil {
+		t.Fatalf("no bytes parameter: %v", err)
+	}
+	c.caps.caps = append(c.caps.caps, capabilityBytesParams)
+	if err := c.checkBytesParams([]Value{inline}); err != nil {
+		t.Fatalf("bytes parameter on a newer server: %v", err)
+	}
+	c.caps.reset()
+	if err := c.checkBytesParams([]Value{inline}); errors.Is(err, ErrBytesParamsUnsupported) || err == nil {
+		t.Fatalf("capabilities not asked for again after reconnecting: %v", err)
+	}
+}
+
+func TestRecordValues(t *testing.T) {
+	v := NewText(`{"id":7,"address":{"city":"Oslo"},"tags":[{"k":"a"}]}`)
+	rec, ok := v.RecordOK()
+	if !ok || rec["id"].AsInt() != 7 {
+		t.Fatalf("json record = %v, %v", rec, ok)
+	}
+	if city := v.Field("address.city").AsText(); city != "Oslo" {
+		t.Fatalf("nested field = %q", city)
+	}
+	if tag := rec["tags"].AsArray()[0]; tag.Type != ValueTypeRecord || tag.Field("k").AsText() != "a" {
+		t.Fatalf("record in array = %+v", tag)
+	}
+
+	row := NewText(`(1,"a ""b"",c",)`).AsRecord()
+	if row["f1"].AsText() != "1" || row["f2"].AsText() != `a "b",c` || !row["f3"].IsNull() {
+		t.Fatalf("row literal = %v", row)
+	}
+	if NewText("plain").AsRecord() != nil {
+		t.Fatal("plain text should not be a record")
+	}
+
+	got, err := Get[map[string]Value](NewRecord(map[string]Value{"n": NewInt(1)}))
+	if err != nil || got["n"].AsInt() != 1 {
+		t.Fatalf("Get record = %v, %v", got, err)
+	}
+	text, err := NewRecord(map[string]Value{"n": NewInt(1)}).wireText()
+	if err != nil || text != `{"n":1}` {
+		t.Fatalf("record wire text = %q, %v", text, err)
+	}
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	m := NewPrometheusMetrics(0.01, 0.1)
+	op := &Operation{Name: OpAppend, BytesOut: 42}
+	m.OperationStarted(op)
+	m.OperationDone(op, 5*time.Millisecond, &KimberliteError{Code: "16", Message: "dup"})
+	m.ConnectionEvent(1, ConnEventConnect)
+
+	var b bytes.Buffer
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`kimberlite_client_operation_duration_seconds_bucket{operation="Append",le="0.01"} 1`,
+		`kimberlite_client_operation_duration_seconds_bucket{operation="Append",le="+Inf"} 1`,
+		`kimberlite_client_errors_total{operation="Append",code="16"} 1`,
+		`kimberlite_client_sent_bytes_total{operation="Append"} 42`,
+		`kimberlite_client_operations_in_flight 0`,
+		`kimberlite_client_connection_events_total{event="connect"} 1`,
+	} {
+		if !bytes.Contains(b.Bytes(), []byte(want)) {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+	if got := ErrorCode(fmt.Errorf("wrapped: %w", ErrTimeout)); got != "timeout" {
+		t.Fatalf("ErrorCode = %q", got)
+	}
+}
+
+func TestRedactSQL(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM patients WHERE name = 'O''Brien' AND age > 42":  "SELECT * FROM patients WHERE name = ? AND age > ?",
+		`SELECT "col1" FROM t2 WHERE id = $1 AND x < 1.5e-3 -- 'note'`: `SELECT "col1" FROM t2 WHERE id = $1 AND x < ? -- 'note'`,
+		"INSERT INTO t VALUES (.5, -7) /* 'c' */":                      "INSERT INTO t VALUES (?, -?) /* 'c' */",
+	}
+	for in, want := range cases {
+		if got := redactSQL(in); got != want {
+			t.Errorf("redactSQL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestHasKeyword(t *testing.T) {
+	cases := map[string]bool{
+		"INSERT INTO t (id) VALUES (1) RETURNING id":                true,
+		"delete from t where id = $1 returning *":                   true,
+		"INSERT INTO t (note) VALUES ('RETURNING id')":              false,
+		`UPDATE t SET "returning" = 1`:                              false,
+		"UPDATE t SET returning_count = 1 -- RETURNING\n":           false,
+		"INSERT INTO t VALUES (1) /* RETURNING */ RETURNING id, at": true,
+	}
+	for sql, want := range cases {
+		if got := hasKeyword(sql, "RETURNING"); got != want {
+			t.Errorf("hasKeyword(%q) = %v, want %v", sql, got, want)
+		}
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var b bytes.Buffer
+	c := &Client{addr: "localhost:5432", tenant: 7, token: "secret-token", slowThreshold: 10 * time.Millisecond}
+	WithLogger(slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug})))(c)
+
+	err := c.instrument(context.Background(), &Operation{Name: OpQuery, Statement: "SELECT * FROM t WHERE ssn = '123-45-6789'"}, nil,
+		func(context.Context) error {
+			time.Sleep(15 * time.Millisecond)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.logger.Info("client", "client", c)
+
+	out := b.String()
+	for _, want := range []string{"slow operation", "op=Query", "tenant=7", "ssn = ?", "client.addr=localhost:5432"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	for _, leak := range []string{"123-45-6789", "secret-token"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("log leaked %q:\n%s", leak, out)
+		}
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	var order []string
+	tag := func(name string) Interceptor {
+		return func(ctx context.Context, op *Operation, req any, next Invoker) error {
+			order = append(order, name)
+			return next(ctx, op, req)
+		}
+	}
+	rewrite := func(ctx context.Context, op *Operation, req any, next Invoker) error {
+		if r, ok := req.(*StatementRequest); ok {
+			r.SQL += " LIMIT 10"
+		}
+		return next(ctx, op, req)
+	}
+	retry := func(ctx context.Context, op *Operation, req any, next Invoker) error {
+		err := next(ctx, op, req)
+		for i := 0; i < 2 && errors.Is(err, ErrTimeout); i++ {
+			err = next(ctx, op, req)
+		}
+		return err
+	}
+	c := &Client{tenant: 1}
+	WithInterceptors(tag("outer"), rewrite)(c)
+	WithInterceptors(retry, tag("inner"))(c)
+
+	req := &StatementRequest{SQL: "SELECT * FROM t"}
+	var calls int
+	var sql string
+	err := c.instrument(context.Background(), &Operation{Name: OpQuery}, req, func(context.Context) error {
+		calls++
+		sql = req.SQL
+		if calls < 3 {
+			return ErrTimeout
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || sql != "SELECT * FROM t LIMIT 10" {
+		t.Fatalf("calls = %d, sql = %q", calls, sql)
+	}
+	if got := strings.Join(order, ","); got != "outer,inner,inner,inner" {
+		t.Fatalf("order = %s", got)
+	}
+
+	denied := errors.New("denied")
+	c = &Client{}
+	WithInterceptors(func(context.Context, *Operation, any, Invoker) error { return denied })(c)
+	err = c.instrument(context.Background(), &Operation{Name: OpAppend}, &AppendRequest{}, func(context.Context) error {
+		t.Fatal("call ran despite short-circuit")
+		return nil
+	})
+	if err != denied {
+		t.Fatalf("err = %v", err)
+	}
+}
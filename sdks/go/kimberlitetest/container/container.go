@@ -0,0 +1,287 @@
+// Package container runs a Kimberlite server in a Docker container for
+// integration tests, in the manner of Testcontainers.
+//
+// New starts a container, waits until the server answers, provisions a
+// tenant and a token for it, and returns a client connected to it; the
+// container is removed when the test ends:
+//
+//	func TestAdmissions(t *testing.T) {
+//	    client := container.New(t)
+//	    info, err := client.CreateStream("admissions", kimberlite.DataClassRestricted)
+//	    // ...
+//	}
+//
+// Run starts a container outside a test, for a TestMain sharing one
+// server between tests:
+//
+//	c, err := container.Run(ctx, container.WithTenant(7))
+//	defer c.Terminate(context.Background())
+//	client, err := c.Client()
+//
+// The package drives the docker command, which must be on the PATH and
+// honours DOCKER_HOST and the other variables of the Docker CLI; New
+// skips the test when it is not installed. The client links the native
+// library, so tests using it need CGo.
+//
+// The stock image starts the server without authentication, so the
+// token is accepted without being checked. For an image configured to
+// verify JWTs, WithJWTSecret sets the secret the token is signed with.
+package container
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// DefaultImage is the image containers run unless WithImage sets
+// another.
+const DefaultImage = "ghcr.io/kimberlitedb/kimberlite:latest"
+
+// port is the port the server listens on inside the container.
+const port = "5432"
+
+// ErrDockerUnavailable is returned by Run when the docker command is not
+// on the PATH.
+var ErrDockerUnavailable = errors.New("kimberlite: docker command not found")
+
+// Option configures Run and New.
+type Option func(*config)
+
+type config struct {
+	image   string
+	tenant  uint64
+	secret  string
+	env     []string
+	timeout time.Duration
+}
+
+// WithImage runs image rather than DefaultImage.
+func WithImage(image string) Option {
+	return func(c *config) {
+		c.image = image
+	}
+}
+
+// WithTenant provisions tenant id rather than tenant 1.
+func WithTenant(id uint64) Option {
+	return func(c *config) {
+		c.tenant = id
+	}
+}
+
+// WithJWTSecret signs the token with secret, for an image whose server
+// verifies JWTs with it. The default is "kimberlitetest".
+func WithJWTSecret(secret string) Option {
+	return func(c *config) {
+		c.secret = secret
+	}
+}
+
+// WithEnv sets the environment variable key to value in the container.
+func WithEnv(key, value string) Option {
+	return func(c *config) {
+		c.env = append(c.env, key+"="+value)
+	}
+}
+
+// WithStartupTimeout bounds how long Run waits for the server to answer,
+// by default one minute. Pulling the image counts towards it.
+func WithStartupTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// Container is a running Kimberlite server.
+type Container struct {
+	// ID is the Docker container ID.
+	ID string
+	// Addr is the host:port the server is published on.
+	Addr string
+	// Tenant is the provisioned tenant.
+	Tenant uint64
+	// Token is a token for Tenant with the Admin role.
+	Token string
+}
+
+// New starts a container with Run, removes it when t ends, and returns a
+// client connected to its tenant, closed when t ends. It skips t if the
+// docker command is not installed, and fails t if the container does not
+// start.
+func New(t testing.TB, opts ...Option) *kimberlite.Client {
+	t.Helper()
+	c, err := Run(context.Background(), opts...)
+	if errors.Is(err, ErrDockerUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := c.Terminate(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	client, err := c.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// Run starts a container and returns it once the server answers and its
+// tenant is provisioned. If the server does not start, the container is
+// removed and the error includes the end of its log.
+func Run(ctx context.Context, opts ...Option) (*Container, error) {
+	cfg := config{image: DefaultImage, tenant: 1, secret: "kimberlitetest", timeout: time.Minute}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, ErrDockerUnavailable
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	// The image's default command binds the loopback interface of the
+	// container, which a published port does not reach.
+	args := []string{"run", "--detach", "--publish", "127.0.0.1::" + port}
+	for _, kv := range cfg.env {
+		args = append(args, "--env", kv)
+	}
+	args = append(args, cfg.image, "start", "--address", "0.0.0.0:"+port, "/data")
+	id, err := docker(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: start container of %s: %w", cfg.image, err)
+	}
+	c := &Container{ID: id, Tenant: cfg.tenant}
+	if err := c.start(ctx, cfg); err != nil {
+		logs, _ := docker(context.Background(), "logs", "--tail", "20", id)
+		c.Terminate(context.Background())
+		if logs != "" {
+			return nil, fmt.Errorf("kimberlite: container %.12s: %w\n%s", id, err, logs)
+		}
+		return nil, fmt.Errorf("kimberlite: container %.12s: %w", id, err)
+	}
+	return c, nil
+}
+
+func (c *Container) start(ctx context.Context, cfg config) error {
+	out, err := docker(ctx, "port", c.ID, port+"/tcp")
+	if err != nil {
+		return fmt.Errorf("published port: %w", err)
+	}
+	if c.Addr, err = publishedAddr(out); err != nil {
+		return err
+	}
+	if err := c.waitReady(ctx); err != nil {
+		return err
+	}
+	// The server creates a tenant on its first connection.
+	_, err = docker(ctx, "exec", c.ID, "kimberlite", "tenant", "create",
+		"--id", fmt.Sprint(c.Tenant), "--name", fmt.Sprintf("kimberlitetest-%d", c.Tenant),
+		"--server", "127.0.0.1:"+port, "--force")
+	if err != nil {
+		return fmt.Errorf("provision tenant %d: %w", c.Tenant, err)
+	}
+	c.Token, err = token(cfg.secret, c.Tenant, time.Now())
+	return err
+}
+
+// waitReady polls the server with the image's health check until it
+// answers.
+func (c *Container) waitReady(ctx context.Context) error {
+	tick := time.NewTicker(250 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		_, err := docker(ctx, "exec", c.ID, "kimberlite", "info", "--server", "127.0.0.1:"+port, "--tenant", "0")
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("server not ready: %w (last check: %v)", ctx.Err(), err)
+		case <-tick.C:
+		}
+	}
+}
+
+// Client connects to the container's tenant with its token. Options in
+// opts apply after those and may override them.
+func (c *Container) Client(opts ...kimberlite.Option) (*kimberlite.Client, error) {
+	opts = append([]kimberlite.Option{kimberlite.WithTenant(c.Tenant), kimberlite.WithToken(c.Token)}, opts...)
+	return kimberlite.Connect(c.Addr, opts...)
+}
+
+// Terminate stops and removes the container and its data.
+func (c *Container) Terminate(ctx context.Context) error {
+	if _, err := docker(ctx, "rm", "--force", "--volumes", c.ID); err != nil {
+		return fmt.Errorf("kimberlite: remove container %.12s: %w", c.ID, err)
+	}
+	return nil
+}
+
+// docker runs the docker command with args and returns its trimmed
+// output, or an error with its standard error.
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("docker %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("docker %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// publishedAddr returns the address of the first binding that docker
+// port printed, one per line.
+func publishedAddr(out string) (string, error) {
+	line, _, _ := strings.Cut(out, "\n")
+	host, p, err := net.SplitHostPort(strings.TrimSpace(line))
+	if err != nil {
+		return "", fmt.Errorf("published port %q: %w", out, err)
+	}
+	if host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, p), nil
+}
+
+// token returns an HS256 JWT for tenant with the Admin role, valid for a
+// day from now, with the claims the server verifies.
+func token(secret string, tenant uint64, now time.Time) (string, error) {
+	claims, err := json.Marshal(map[string]any{
+		"sub":       "kimberlitetest",
+		"tenant_id": tenant,
+		"roles":     []string{"Admin"},
+		"iat":       now.Unix(),
+		"exp":       now.Add(24 * time.Hour).Unix(),
+		"iss":       "kimberlite",
+		"aud":       []string{"kimberlite"},
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
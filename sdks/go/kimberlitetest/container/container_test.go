@@ -0,0 +1,60 @@
+package container
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tok, err := token("s3cret", 7, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token %q has %d parts", tok, len(parts))
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)); parts[2] != want {
+		t.Fatalf("signature %s, want %s", parts[2], want)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims struct {
+		Tenant uint64   `json:"tenant_id"`
+		Roles  []string `json:"roles"`
+		Exp    int64    `json:"exp"`
+		Aud    []string `json:"aud"`
+	}
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Tenant != 7 || claims.Roles[0] != "Admin" || claims.Exp != now.Add(24*time.Hour).Unix() || claims.Aud[0] != "kimberlite" {
+		t.Fatalf("claims %+v", claims)
+	}
+}
+
+func TestPublishedAddr(t *testing.T) {
+	for out, want := range map[string]string{
+		"127.0.0.1:49153":               "127.0.0.1:49153",
+		"0.0.0.0:49153\n[::]:49153":     "127.0.0.1:49153",
+		"[::1]:50000":                   "[::1]:50000",
+		"  192.168.1.20:6000  \n[::]:1": "192.168.1.20:6000",
+	} {
+		if got, err := publishedAddr(out); err != nil || got != want {
+			t.Errorf("publishedAddr(%q) = %q, %v; want %q", out, got, err, want)
+		}
+	}
+	if _, err := publishedAddr(""); err == nil {
+		t.Error("no binding: no error")
+	}
+}
@@ -0,0 +1,173 @@
+package kimberlitetest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Fault is a failure injected into a client operation.
+type Fault struct {
+	// Delay holds the operation back before it runs or fails.
+	Delay time.Duration
+	// Err, if set, is returned from the operation.
+	Err error
+	// Applied runs the operation before returning Err, as when a
+	// connection drops after the server applied a request but before
+	// its response arrived.
+	Applied bool
+}
+
+// Latency delays an operation by d.
+func Latency(d time.Duration) Fault {
+	return Fault{Delay: d}
+}
+
+// Timeout fails an operation with ErrTimeout without running it.
+func Timeout() Fault {
+	return Fault{Err: fmt.Errorf("%w: injected", kimberlite.ErrTimeout)}
+}
+
+// Drop fails an operation with ErrConnectionFailed without running it,
+// as when the connection drops before the request is sent.
+func Drop() Fault {
+	return Fault{Err: fmt.Errorf("%w: injected", kimberlite.ErrConnectionFailed)}
+}
+
+// LostResponse runs an operation and then fails it with
+// ErrConnectionFailed, so an append is stored although the caller sees
+// an error: the case idempotency keys exist for.
+func LostResponse() Fault {
+	return Fault{Err: fmt.Errorf("%w: injected after the request was applied", kimberlite.ErrConnectionFailed), Applied: true}
+}
+
+// Conflict fails an operation with ErrOffsetMismatch without running it,
+// as when another writer appended to the stream first.
+func Conflict() Fault {
+	return Fault{Err: fmt.Errorf("%w: injected", kimberlite.ErrOffsetMismatch)}
+}
+
+// Injection records a fault injected into the call'th call of an
+// operation.
+type Injection struct {
+	Op    string
+	Call  int
+	Fault Fault
+}
+
+// Faults injects faults into client operations, on a schedule or at
+// random, to test retry and idempotency logic:
+//
+//	faults := kimberlitetest.NewFaults(1).
+//	    Schedule(kimberlite.OpAppend, kimberlitetest.LostResponse(), 1).
+//	    Randomly(kimberlite.OpReadEvents, 0.1, kimberlitetest.Timeout())
+//	client := kimberlitetest.NewMockClient(kimberlite.WithInterceptors(faults.Interceptor()))
+//
+// Random faults come from a generator seeded with the seed given to
+// NewFaults, so a test making the same calls in the same order sees the
+// same faults. Rules apply in the order added; a call gets the fault of
+// the first rule matching it. Faults is safe for concurrent use.
+type Faults struct {
+	mu       sync.Mutex
+	rng      *rand.Rand
+	rules    []faultRule
+	calls    map[string]int
+	injected []Injection
+}
+
+type faultRule struct {
+	op    string
+	fault Fault
+	calls map[int]bool
+	p     float64
+}
+
+// NewFaults returns a Faults injecting nothing until rules are added,
+// with random faults drawn from seed.
+func NewFaults(seed int64) *Faults {
+	return &Faults{rng: rand.New(rand.NewSource(seed)), calls: make(map[string]int)}
+}
+
+// Schedule injects f into the given calls of operation op, counted from
+// 1, such as kimberlite.OpAppend; an empty op counts calls of every
+// operation.
+func (fs *Faults) Schedule(op string, f Fault, calls ...int) *Faults {
+	set := make(map[int]bool, len(calls))
+	for _, n := range calls {
+		set[n] = true
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.rules = append(fs.rules, faultRule{op: op, fault: f, calls: set})
+	return fs
+}
+
+// Randomly injects f into each call of operation op with probability
+// p; an empty op matches every operation.
+func (fs *Faults) Randomly(op string, p float64, f Fault) *Faults {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.rules = append(fs.rules, faultRule{op: op, fault: f, p: p})
+	return fs
+}
+
+// Injected returns the faults injected so far, in order.
+func (fs *Faults) Injected() []Injection {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]Injection(nil), fs.injected...)
+}
+
+// Interceptor returns the interceptor injecting the faults. Add it last
+// to WithInterceptors, so retrying interceptors run in front of it and
+// see the faults.
+func (fs *Faults) Interceptor() kimberlite.Interceptor {
+	return func(ctx context.Context, op *kimberlite.Operation, req any, next kimberlite.Invoker) error {
+		f, ok := fs.next(op.Name)
+		if !ok {
+			return next(ctx, op, req)
+		}
+		if f.Delay > 0 {
+			t := time.NewTimer(f.Delay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+		if f.Err == nil {
+			return next(ctx, op, req)
+		}
+		if f.Applied {
+			if err := next(ctx, op, req); err != nil {
+				return err
+			}
+		}
+		return f.Err
+	}
+}
+
+// next counts a call of op and returns the fault to inject into it.
+func (fs *Faults) next(op string) (Fault, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.calls[op]++
+	fs.calls[""]++
+	for _, r := range fs.rules {
+		if r.op != "" && r.op != op {
+			continue
+		}
+		n := fs.calls[r.op]
+		if r.calls != nil && !r.calls[n] || r.calls == nil && fs.rng.Float64() >= r.p {
+			continue
+		}
+		fs.injected = append(fs.injected, Injection{Op: op, Call: fs.calls[op], Fault: r.fault})
+		return r.fault, true
+	}
+	return Fault{}, false
+}
@@ -0,0 +1,88 @@
+package kimberlitetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestFaultSchedule(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	faults := NewFaults(1).
+		Schedule(kimberlite.OpAppend, LostResponse(), 1).
+		Schedule(kimberlite.OpAppend, Conflict(), 2).
+		Schedule("", Drop(), 5)
+	client := store.Client(kimberlite.WithInterceptors(faults.Interceptor()))
+	defer client.Close()
+
+	info, err := client.CreateStream("s", kimberlite.DataClassPublic) // call 1
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AppendContext(ctx, info.ID, []byte("a")); !errors.Is(err, kimberlite.ErrConnectionFailed) {
+		t.Fatalf("lost response: %v", err)
+	}
+	if n := len(store.Events(info.ID)); n != 1 {
+		t.Fatalf("lost response stored %d events", n)
+	}
+	if _, err := client.AppendExpected(ctx, info.ID, 1, []byte("b")); !errors.Is(err, kimberlite.ErrOffsetMismatch) {
+		t.Fatalf("conflict: %v", err)
+	}
+	if _, err := client.AppendExpected(ctx, info.ID, 1, []byte("b")); err != nil { // call 4
+		t.Fatal(err)
+	}
+	if _, err := client.ReadEvents(info.ID, 0, 1<<10); !errors.Is(err, kimberlite.ErrConnectionFailed) {
+		t.Fatalf("drop: %v", err)
+	}
+	if n := len(store.Events(info.ID)); n != 2 {
+		t.Fatalf("%d events", n)
+	}
+
+	got := faults.Injected()
+	if len(got) != 3 || got[0].Op != kimberlite.OpAppend || got[1].Call != 2 || got[2].Op != kimberlite.OpReadEvents || got[2].Call != 1 {
+		t.Fatalf("injected %+v", got)
+	}
+}
+
+func TestFaultRandomDeterministic(t *testing.T) {
+	run := func() []int {
+		faults := NewFaults(42).Randomly(kimberlite.OpQuery, 0.3, Timeout())
+		client := NewMockClient(kimberlite.WithInterceptors(faults.Interceptor()))
+		defer client.Close()
+		if _, err := client.Exec("CREATE TABLE t (id BIGINT PRIMARY KEY)"); err != nil {
+			t.Fatal(err)
+		}
+		var failed []int
+		for i := 0; i < 50; i++ {
+			if _, err := client.Query("SELECT * FROM t"); errors.Is(err, kimberlite.ErrTimeout) {
+				failed = append(failed, i)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return failed
+	}
+	a, b := run(), run()
+	if len(a) == 0 || len(a) == 50 || !reflect.DeepEqual(a, b) {
+		t.Fatalf("failures %v and %v", a, b)
+	}
+}
+
+func TestFaultLatency(t *testing.T) {
+	faults := NewFaults(1).Schedule("", Latency(time.Hour), 2)
+	client := NewMockClient(kimberlite.WithInterceptors(faults.Interceptor()))
+	defer client.Close()
+	if _, err := client.CreateStream("s", kimberlite.DataClassPublic); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.ReadEventsContext(ctx, 1, 0, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("delayed read: %v", err)
+	}
+}
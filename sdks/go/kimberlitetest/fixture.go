@@ -0,0 +1,204 @@
+package kimberlitetest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// A fixture is a stream's events in a canonical text form, for golden
+// tests of projections against historical data: dump a stream once
+// with DumpStream, commit the file, and load it into a Store in tests:
+//
+//	f, err := os.Create("testdata/admissions.ndjson")
+//	err = kimberlitetest.DumpStream(ctx, f, client, info)
+//
+//	store := kimberlitetest.NewStore()
+//	info, err := store.LoadFile("testdata/admissions.ndjson")
+//	runner := projection.NewRunner(store.Client(), "census", census, []kimberlite.StreamID{info.ID})
+//
+// The file is newline-delimited JSON: a line describing the stream,
+// then a line per event with its offset and timestamp. Envelope fields
+// are written as JSON, as are payloads that are compact JSON; other
+// payloads are base64. Loading a fixture reproduces the events' bytes
+// exactly, and dumping the same events gives the same file.
+
+// Fixture is a stream and its events, as stored in a fixture file.
+type Fixture struct {
+	Stream kimberlite.StreamInfo
+	Events []kimberlite.Event
+}
+
+type fixtureStream struct {
+	Stream    string `json:"stream"`
+	DataClass string `json:"data_class"`
+	Region    string `json:"region,omitempty"`
+}
+
+type fixtureEvent struct {
+	Offset    kimberlite.Offset `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	// Envelope is the envelope header of enveloped events.
+	Envelope json.RawMessage `json:"envelope,omitempty"`
+	// JSON or Data is the payload.
+	JSON json.RawMessage `json:"json,omitempty"`
+	Data []byte          `json:"data,omitempty"`
+}
+
+// DumpStream reads every event of stream info.ID from source and
+// writes them to w as a fixture. Only info's ID, Name, DataClass and
+// Region are used.
+func DumpStream(ctx context.Context, w io.Writer, source connect.EventSource, info kimberlite.StreamInfo) error {
+	f := &Fixture{Stream: info}
+	for from := kimberlite.Offset(0); ; {
+		events, err := source.ReadEventsContext(ctx, info.ID, from, 1<<20)
+		if err != nil {
+			return fmt.Errorf("kimberlite: dump stream %d at offset %d: %w", info.ID, from, err)
+		}
+		if len(events) == 0 {
+			break
+		}
+		f.Events = append(f.Events, events...)
+		from = events[len(events)-1].Offset + 1
+	}
+	return f.Write(w)
+}
+
+// Write writes f to w in the fixture format.
+func (f *Fixture) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(fixtureStream{Stream: f.Stream.Name, DataClass: f.Stream.DataClass.String(), Region: string(f.Stream.Region)})
+	if err != nil {
+		return err
+	}
+	for _, ev := range f.Events {
+		if err := enc.Encode(fixtureEventOf(ev)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func fixtureEventOf(ev kimberlite.Event) fixtureEvent {
+	out := fixtureEvent{Offset: ev.Offset, Timestamp: ev.Timestamp.UTC()}
+	payload := ev.Data
+	if kimberlite.IsEnvelope(ev.Data) {
+		// Envelopes that do not encode back to the same bytes are kept
+		// whole, as a payload.
+		env, err := kimberlite.UnmarshalEnvelope(ev.Data)
+		if data, merr := env.Marshal(); err == nil && merr == nil && bytes.Equal(data, ev.Data) {
+			payload = env.Data
+			env.Data = nil
+			out.Envelope, _ = json.Marshal(env)
+		}
+	}
+	if compactJSON(payload) {
+		out.JSON = payload
+	} else if len(payload) > 0 {
+		out.Data = payload
+	}
+	return out
+}
+
+// ReadFixture reads a fixture written by Fixture.Write or DumpStream.
+// The stream's ID is left zero.
+func ReadFixture(r io.Reader) (*Fixture, error) {
+	dec := json.NewDecoder(r)
+	var hdr fixtureStream
+	if err := dec.Decode(&hdr); err != nil {
+		return nil, fmt.Errorf("kimberlite: fixture header: %w", err)
+	}
+	class, err := kimberlite.ParseDataClass(hdr.DataClass)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: fixture header: %w", err)
+	}
+	f := &Fixture{Stream: kimberlite.StreamInfo{Name: hdr.Stream, DataClass: class, Region: kimberlite.Region(hdr.Region)}}
+	for {
+		var fe fixtureEvent
+		if err := dec.Decode(&fe); errors.Is(err, io.EOF) {
+			return f, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("kimberlite: fixture event %d: %w", len(f.Events), err)
+		}
+		if fe.Offset != kimberlite.Offset(len(f.Events)) {
+			return nil, fmt.Errorf("kimberlite: fixture event %d has offset %d", len(f.Events), fe.Offset)
+		}
+		ev, err := fe.event()
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: fixture event %d: %w", fe.Offset, err)
+		}
+		f.Events = append(f.Events, ev)
+	}
+}
+
+func (fe fixtureEvent) event() (kimberlite.Event, error) {
+	payload := fe.Data
+	if fe.JSON != nil {
+		payload = fe.JSON
+	}
+	data := payload
+	if fe.Envelope != nil {
+		var env kimberlite.Envelope
+		if err := json.Unmarshal(fe.Envelope, &env); err != nil {
+			return kimberlite.Event{}, err
+		}
+		env.Data = payload
+		var err error
+		if data, err = env.Marshal(); err != nil {
+			return kimberlite.Event{}, err
+		}
+	}
+	return kimberlite.Event{Offset: fe.Offset, Data: append([]byte{}, data...), Timestamp: fe.Timestamp}, nil
+}
+
+// Load creates the stream of the fixture read from r and appends its
+// events with their recorded timestamps.
+func (s *Store) Load(r io.Reader) (kimberlite.StreamInfo, error) {
+	f, err := ReadFixture(r)
+	if err != nil {
+		return kimberlite.StreamInfo{}, err
+	}
+	info, err := s.CreateStream(f.Stream.Name, f.Stream.DataClass, f.Stream.Region)
+	if err != nil {
+		return kimberlite.StreamInfo{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.streams[info.ID-1]
+	for _, ev := range f.Events {
+		ev.StreamID = info.ID
+		st.events = append(st.events, ev)
+	}
+	return *info, nil
+}
+
+// LoadFile loads the fixture file at path, like Load.
+func (s *Store) LoadFile(path string) (kimberlite.StreamInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return kimberlite.StreamInfo{}, err
+	}
+	defer f.Close()
+	return s.Load(f)
+}
+
+// compactJSON reports whether data is compact JSON, which the fixture
+// encoder writes back byte for byte.
+func compactJSON(data []byte) bool {
+	if len(data) == 0 || !json.Valid(data) {
+		return false
+	}
+	var buf bytes.Buffer
+	return json.Compact(&buf, data) == nil && bytes.Equal(buf.Bytes(), data)
+}
@@ -0,0 +1,81 @@
+package kimberlitetest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestFixtureRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	store.now = func() time.Time { return time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600)) }
+	client := store.Client()
+	defer client.Close()
+
+	info, err := client.CreateStream("admissions", kimberlite.DataClassRestricted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admitted, err := kimberlite.Envelope{ID: "e-1", Type: "PatientAdmitted", Metadata: map[string]string{"ward": "4"}, Data: []byte(`{"mrn":"A<1>"}`)}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned, err := kimberlite.Envelope{ID: "e-2", Type: "ScanAttached", Data: []byte{0xff, 0x00}}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := [][]byte{[]byte(`{"raw":true}`), []byte("not json"), []byte(`{ "spaced": 1 }`), admitted, scanned, {}}
+	if _, err := client.AppendContext(ctx, info.ID, events...); err != nil {
+		t.Fatal(err)
+	}
+
+	var dump bytes.Buffer
+	if err := DumpStream(ctx, &dump, client, *info); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(dump.String(), "\n"), "\n")
+	if len(lines) != 7 || lines[0] != `{"stream":"admissions","data_class":"restricted"}` {
+		t.Fatalf("fixture:\n%s", dump.String())
+	}
+	if want := `{"offset":0,"timestamp":"2024-03-01T08:30:00Z","json":{"raw":true}}`; lines[1] != want {
+		t.Fatalf("raw JSON event %s, want %s", lines[1], want)
+	}
+	if !strings.Contains(lines[4], `"envelope":{"id":"e-1","type":"PatientAdmitted","metadata":{"ward":"4"}},"json":{"mrn":"A<1>"}`) {
+		t.Fatalf("enveloped event %s", lines[4])
+	}
+
+	loaded := NewStore()
+	got, err := loaded.Load(bytes.NewReader(dump.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "admissions" || got.DataClass != kimberlite.DataClassRestricted {
+		t.Fatalf("loaded stream %+v", got)
+	}
+	replayed := loaded.Events(got.ID)
+	if len(replayed) != len(events) {
+		t.Fatalf("%d events loaded", len(replayed))
+	}
+	for i, ev := range replayed {
+		if !bytes.Equal(ev.Data, events[i]) || ev.Offset != kimberlite.Offset(i) || !ev.Timestamp.Equal(store.now()) {
+			t.Fatalf("event %d: %+v", i, ev)
+		}
+	}
+
+	var again bytes.Buffer
+	if err := DumpStream(ctx, &again, loaded.Client(), got); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != dump.String() {
+		t.Fatalf("dump of loaded fixture differs:\n%s\n%s", dump.String(), again.String())
+	}
+
+	if _, err := ReadFixture(strings.NewReader(lines[0] + "\n" + lines[2] + "\n")); err == nil {
+		t.Fatal("fixture with a gap loaded")
+	}
+}
@@ -0,0 +1,219 @@
+// Package kimberlitetest provides an in-memory Kimberlite for unit
+// tests, which need neither a server nor the native library: built
+// with CGO_ENABLED=0, the client package links without it.
+//
+// NewMockClient returns a *kimberlite.Client whose requests a Store
+// serves, so code under test uses the real client API, its options
+// and helpers included:
+//
+//	client := kimberlitetest.NewMockClient()
+//	defer client.Close()
+//	info, err := client.CreateStream("admissions", kimberlite.DataClassRestricted)
+//	_, err = client.AppendExpected(ctx, info.ID, 0, event)
+//	_, err = client.Exec("CREATE TABLE patients (id BIGINT PRIMARY KEY, name TEXT)")
+//
+// A Store keeps streams with offsets and optimistic concurrency, and
+// tables queried with a subset of SQL: CREATE TABLE, DROP TABLE,
+// INSERT, UPDATE and DELETE with RETURNING, and single-table SELECT
+// with WHERE, ORDER BY, LIMIT and OFFSET, and COUNT(*). Conditions compare columns with
+// literals and $n or ? parameters, with =, <>, <, <=, >, >=, IS NULL,
+// IN, LIKE, ILIKE, JSON containment (@>), AND, OR, NOT and parentheses. Joins, aggregates other than
+// COUNT(*), and the server's administration API are not supported,
+// except for the erasure log that Erase records in.
+//
+// A Server embeds stores for any number of tenants at an address of its
+// own, for end-to-end tests of code that connects by address or DSN
+// rather than accepting a client.
+package kimberlitetest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// NewMockClient returns a client served by a new Store, for tenant 1
+// unless opts set another. It panics if the client cannot be created,
+// which only a failing token provider in opts causes.
+func NewMockClient(opts ...kimberlite.Option) *kimberlite.Client {
+	return NewStore().Client(opts...)
+}
+
+// Store is an in-memory Kimberlite tenant. It is safe for concurrent
+// use, and clients sharing a Store see each other's writes.
+type Store struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	streams  []*stream
+	names    map[string]kimberlite.StreamID
+	tables   map[string]*table
+	log      kimberlite.Offset
+	erasures []Erasure
+}
+
+// Erasure is a request in a Store's erasure log.
+type Erasure struct {
+	RequestID string
+	Subject   string
+	Complete  bool
+}
+
+type stream struct {
+	info   kimberlite.StreamInfo
+	events []kimberlite.Event
+}
+
+// NewStore returns an empty store.
+func NewStore() *Store {
+	return &Store{
+		now:    time.Now,
+		names:  make(map[string]kimberlite.StreamID),
+		tables: make(map[string]*table),
+	}
+}
+
+// Client returns a client served by s. See NewMockClient.
+func (s *Store) Client(opts ...kimberlite.Option) *kimberlite.Client {
+	opts = append([]kimberlite.Option{kimberlite.WithTenant(1)}, opts...)
+	opts = append(opts, kimberlite.WithBackend(s))
+	c, err := kimberlite.Connect("memory", opts...)
+	if err != nil {
+		panic("kimberlitetest: " + err.Error())
+	}
+	return c
+}
+
+// Events returns a copy of the events of stream id, for assertions.
+func (s *Store) Events(id kimberlite.StreamID) []kimberlite.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.stream(id)
+	if err != nil {
+		return nil
+	}
+	return copyEvents(st.events)
+}
+
+// CreateStream implements kimberlite.Backend.
+func (s *Store) CreateStream(name string, class kimberlite.DataClass, region kimberlite.Region) (*kimberlite.StreamInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.names[name]; ok {
+		return nil, &kimberlite.KimberliteError{Code: "StreamAlreadyExists", Message: fmt.Sprintf("stream %q already exists", name)}
+	}
+	id := kimberlite.StreamID(len(s.streams) + 1)
+	st := &stream{info: kimberlite.StreamInfo{ID: id, Name: name, DataClass: class, Region: region, CreatedAt: s.now()}}
+	s.streams = append(s.streams, st)
+	s.names[name] = id
+	info := st.info
+	return &info, nil
+}
+
+// Append implements kimberlite.Backend. Like the server, it refuses
+// appends that do not expect the stream's length.
+func (s *Store) Append(id kimberlite.StreamID, expected kimberlite.Offset, events [][]byte) (kimberlite.Offset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.stream(id)
+	if err != nil {
+		return 0, err
+	}
+	next := kimberlite.Offset(len(st.events))
+	if expected != next {
+		return 0, fmt.Errorf("%w: stream %d is at offset %d, not %d", kimberlite.ErrOffsetMismatch, id, next, expected)
+	}
+	now := s.now()
+	for i, data := range events {
+		st.events = append(st.events, kimberlite.Event{
+			Offset:    next + kimberlite.Offset(i),
+			StreamID:  id,
+			Data:      append([]byte(nil), data...),
+			Timestamp: now,
+		})
+	}
+	return next, nil
+}
+
+// StreamLength implements kimberlite.Backend.
+func (s *Store) StreamLength(id kimberlite.StreamID) (kimberlite.Offset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.stream(id)
+	if err != nil {
+		return 0, err
+	}
+	return kimberlite.Offset(len(st.events)), nil
+}
+
+// ReadEvents implements kimberlite.Backend. It returns the events from
+// offset from whose data fits in maxBytes, and at least one.
+func (s *Store) ReadEvents(id kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.stream(id)
+	if err != nil {
+		return nil, err
+	}
+	if int(from) >= len(st.events) {
+		return nil, nil
+	}
+	end, size := int(from), uint64(0)
+	for end < len(st.events) {
+		size += uint64(len(st.events[end].Data))
+		if size > maxBytes && end > int(from) {
+			break
+		}
+		end++
+	}
+	return copyEvents(st.events[from:end]), nil
+}
+
+// RequestErasure implements kimberlite.ErasureLog.
+func (s *Store) RequestErasure(subject string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := Erasure{RequestID: fmt.Sprintf("erasure-%d", len(s.erasures)+1), Subject: subject}
+	s.erasures = append(s.erasures, e)
+	return e.RequestID, nil
+}
+
+// CompleteErasure implements kimberlite.ErasureLog.
+func (s *Store) CompleteErasure(requestID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.erasures {
+		if s.erasures[i].RequestID == requestID {
+			if s.erasures[i].Complete {
+				return fmt.Errorf("kimberlitetest: erasure request %s already complete", requestID)
+			}
+			s.erasures[i].Complete = true
+			return nil
+		}
+	}
+	return fmt.Errorf("kimberlitetest: erasure request %s not found", requestID)
+}
+
+// Erasures returns the store's erasure log, for assertions.
+func (s *Store) Erasures() []Erasure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Erasure(nil), s.erasures...)
+}
+
+func (s *Store) stream(id kimberlite.StreamID) (*stream, error) {
+	if id == 0 || int(id) > len(s.streams) {
+		return nil, fmt.Errorf("%w: stream %d", kimberlite.ErrStreamNotFound, id)
+	}
+	return s.streams[id-1], nil
+}
+
+func copyEvents(events []kimberlite.Event) []kimberlite.Event {
+	out := make([]kimberlite.Event, len(events))
+	for i, ev := range events {
+		ev.Data = append([]byte(nil), ev.Data...)
+		out[i] = ev
+	}
+	return out
+}
@@ -0,0 +1,351 @@
+package kimberlitetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestStoreChecksExpectedOffset(t *testing.T) {
+	store := NewStore()
+	info, err := store.CreateStream("charts", kimberlite.DataClassPublic, kimberlite.RegionGlobal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off, err := store.Append(info.ID, 0, [][]byte{[]byte("a")}); err != nil || off != 0 {
+		t.Fatalf("first append: %d, %v", off, err)
+	}
+	if _, err := store.Append(info.ID, 0, [][]byte{[]byte("b")}); !errors.Is(err, kimberlite.ErrOffsetMismatch) {
+		t.Fatalf("append at offset 0 to a non-empty stream: %v", err)
+	}
+	if n, err := store.StreamLength(info.ID); err != nil || n != 1 {
+		t.Fatalf("StreamLength = %d, %v", n, err)
+	}
+
+	// Plain appends send the stream's length.
+	client, err := kimberlite.Connect("mock", kimberlite.WithTenant(1), kimberlite.WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if off, err := client.Append(info.ID, []byte("b")); err != nil || off != 1 {
+		t.Fatalf("plain append to a non-empty stream: %d, %v", off, err)
+	}
+}
+
+func TestMockStreams(t *testing.T) {
+	ctx := context.Background()
+	client := NewMockClient()
+	defer client.Close()
+
+	info, err := client.CreateStream("admissions", kimberlite.DataClassPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateStream("admissions", kimberlite.DataClassPublic); kimberlite.MapKimberliteError(err).Kind != kimberlite.DomainKindConflict {
+		t.Fatalf("duplicate stream: %v", err)
+	}
+	if off, err := client.AppendContext(ctx, info.ID, []byte("a"), []byte("bb")); err != nil || off != 0 {
+		t.Fatalf("append: %d, %v", off, err)
+	}
+	if _, err := client.AppendExpected(ctx, info.ID, 1, []byte("c")); !errors.Is(err, kimberlite.ErrOffsetMismatch) {
+		t.Fatalf("stale append: %v", err)
+	}
+	if off, err := client.AppendExpected(ctx, info.ID, 2, []byte("c")); err != nil || off != 2 {
+		t.Fatalf("expected append: %d, %v", off, err)
+	}
+	// Like the server, the mock checks expected offsets of 0 too, so a
+	// second writer creating the stream loses.
+	if _, err := client.AppendExpected(ctx, info.ID, 0, []byte("d")); !errors.Is(err, kimberlite.ErrOffsetMismatch) {
+		t.Fatalf("append expecting an empty stream: %v", err)
+	}
+	if n, err := client.StreamLength(ctx, info.ID); err != nil || n != 3 {
+		t.Fatalf("StreamLength = %d, %v", n, err)
+	}
+
+	events, err := client.ReadEventsContext(ctx, info.ID, 1, 2)
+	if err != nil || len(events) != 1 || string(events[0].Data) != "bb" || events[0].Offset != 1 {
+		t.Fatalf("read: %+v, %v", events, err)
+	}
+	if events, _ := client.ReadEvents(info.ID, 0, 1<<20); len(events) != 3 {
+		t.Fatalf("read all: %d events", len(events))
+	}
+	if _, err := client.ReadEvents(99, 0, 1); !errors.Is(err, kimberlite.ErrStreamNotFound) {
+		t.Fatalf("unknown stream: %v", err)
+	}
+}
+
+func TestMockSubscribe(t *testing.T) {
+	ctx := context.Background()
+	client := NewMockClient()
+	defer client.Close()
+	info, err := client.CreateStream("admissions", kimberlite.DataClassPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AppendContext(ctx, info.ID, []byte("a"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The mock cannot push, so subscriptions follow the stream.
+	sub, err := client.Subscribe(ctx, info.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		client.AppendContext(ctx, info.ID, []byte("c"))
+	}()
+	for _, want := range []string{"b", "c"} {
+		ev, err := sub.Next(ctx)
+		if err != nil || string(ev.Data) != want {
+			t.Fatalf("next: %q, %v; want %q", ev.Data, err, want)
+		}
+	}
+	if sub.Offset() != 3 {
+		t.Fatalf("offset %d", sub.Offset())
+	}
+	sub.Close()
+	if _, err := sub.Next(ctx); !errors.Is(err, kimberlite.ErrSubscriptionClosed) {
+		t.Fatalf("after Close: %v", err)
+	}
+}
+
+func TestInterceptorsSeeEveryOperation(t *testing.T) {
+	ctx := context.Background()
+	seen := make(map[string]bool)
+	record := func(ctx context.Context, op *kimberlite.Operation, req any, next kimberlite.Invoker) error {
+		seen[op.Name] = true
+		return next(ctx, op, req)
+	}
+	enc := kimberlite.NewEncryption(nil, kimberlite.WithSubjectKeys(kimberlite.NewMemorySubjectKeys()))
+	client := NewMockClient(kimberlite.WithInterceptors(record),
+		kimberlite.WithEncryption(enc), kimberlite.WithLegalHolds(kimberlite.NewMemoryLegalHolds()))
+	defer client.Close()
+	info, err := client.CreateStream("admissions", kimberlite.DataClassPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := client.Subscribe(ctx, info.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Close()
+	if _, err := client.StreamLength(ctx, info.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Erase(ctx, "patient-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.PlaceLegalHold(ctx, kimberlite.SubjectHold("patient-2"), "case-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	client.ListTables(ctx)
+	client.TableColumnTypes(ctx, "patients")
+	client.MaskingPolicies(ctx, false)
+
+	for _, op := range []string{
+		kimberlite.OpSubscribe, kimberlite.OpStreamLength, kimberlite.OpRequestErasure,
+		kimberlite.OpCompleteErasure, kimberlite.OpPlaceLegalHold, kimberlite.OpPing,
+		kimberlite.OpListTables, kimberlite.OpDescribeTable, kimberlite.OpMaskingPolicies,
+	} {
+		if !seen[op] {
+			t.Errorf("interceptor did not see %s", op)
+		}
+	}
+}
+
+func TestProofsCoverStoredEvents(t *testing.T) {
+	ctx := context.Background()
+	cache := kimberlite.NewMemoryReadCache(1 << 20)
+	enc := kimberlite.NewEncryption(nil, kimberlite.WithSubjectKeys(kimberlite.NewMemorySubjectKeys()))
+	store := NewStore()
+	client := store.Client(kimberlite.WithEncryption(enc), kimberlite.WithReadCache(cache), kimberlite.WithLegalHolds(kimberlite.NoLegalHolds))
+	defer client.Close()
+
+	info, err := client.CreateStream("charts", kimberlite.DataClassConfidential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AppendContext(kimberlite.WithSubject(ctx, "p-1"), info.ID, []byte("alice"), []byte("a1c")); err != nil {
+		t.Fatal(err)
+	}
+	trusted, err := client.TreeHead(info.ID)
+	if err != nil || trusted.TreeSize != 2 {
+		t.Fatalf("tree head: %+v, %v", trusted, err)
+	}
+
+	// Erasing the subject changes what ReadEvents returns, not the log.
+	if err := client.Erase(ctx, "p-1"); err != nil {
+		t.Fatal(err)
+	}
+	if log := store.Erasures(); len(log) != 1 || log[0].Subject != "p-1" || !log[0].Complete {
+		t.Fatalf("erasure log: %+v", log)
+	}
+	head, err := client.VerifyChain(info.ID, trusted)
+	if err != nil || head.RootHash != trusted.RootHash {
+		t.Fatalf("verify after erasure: %+v, %v", head, err)
+	}
+
+	// A forged cache entry is not what the witness checks.
+	forged := []kimberlite.Event{{StreamID: info.ID, Offset: 0, Data: []byte("forged")}}
+	cache.Put(kimberlite.ReadCacheKey{Server: "memory", Tenant: 1, Stream: info.ID, From: 0, MaxBytes: 1 << 20}, forged)
+	if _, err := client.VerifyChain(info.ID, trusted); err != nil {
+		t.Fatalf("verify with a forged cache entry: %v", err)
+	}
+
+	stored, err := client.ReadStoredEvents(ctx, info.ID, 0, 1<<20)
+	if err != nil || len(stored) != 2 || !kimberlite.IsEncrypted(stored[0].Data) {
+		t.Fatalf("stored events: %+v, %v", stored, err)
+	}
+
+	// Bundles export the same events, so their head is the witness's.
+	_, key, _ := ed25519.GenerateKey(nil)
+	var buf bytes.Buffer
+	if _, err := client.ExportBundle(ctx, &buf, info.ID, key); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := kimberlite.VerifyBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()), key.Public().(ed25519.PublicKey))
+	if err != nil || bundle.TreeHead.RootHash != trusted.RootHash || !bytes.Equal(bundle.Events[0].Data, stored[0].Data) {
+		t.Fatalf("bundle: %+v, %v", bundle, err)
+	}
+}
+
+func TestMockSQL(t *testing.T) {
+	ctx := context.Background()
+	client := NewMockClient()
+	defer client.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE patients (id BIGINT PRIMARY KEY, name TEXT NOT NULL, ward TEXT, age INT)",
+		"CREATE TABLE IF NOT EXISTS patients (id BIGINT)",
+		"INSERT INTO patients (id, name, ward, age) VALUES (1, 'Ada', '4', 36), (2, 'Grace', NULL, 45)",
+	} {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	res, err := client.ExecContext(ctx, "INSERT INTO patients VALUES ($1, $2, $3, $4)",
+		kimberlite.NewInt(3), kimberlite.NewText("O'Neil"), kimberlite.NewText("4"), kimberlite.NewInt(29))
+	if err != nil || res.RowsAffected != 1 {
+		t.Fatalf("insert: %+v, %v", res, err)
+	}
+	if _, err := client.Exec("INSERT INTO patients (id, name) VALUES (1, 'Dup')"); !errors.Is(err, kimberlite.ErrQueryFailed) {
+		t.Fatalf("duplicate key: %v", err)
+	}
+
+	q, err := client.QueryContext(ctx, "SELECT id, name FROM patients WHERE ward = $1 AND age > $2 ORDER BY age DESC", kimberlite.NewText("4"), kimberlite.NewInt(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Rows) != 2 || q.Rows[0]["name"].AsText() != "Ada" || q.Rows[1]["name"].AsText() != "O'Neil" {
+		t.Fatalf("rows %v", q.Rows)
+	}
+
+	if _, err := client.Exec("UPDATE patients SET ward = '7' WHERE ward IS NULL OR id IN (3)"); err != nil {
+		t.Fatal(err)
+	}
+	q, err = client.Query("SELECT COUNT(*) AS n FROM patients WHERE NOT (ward = '4')")
+	if err != nil || q.Rows[0]["n"].AsInt() != 2 {
+		t.Fatalf("count: %v, %v", q, err)
+	}
+	q, err = client.Query("SELECT * FROM patients ORDER BY id LIMIT 1 OFFSET 1")
+	if err != nil || len(q.Rows) != 1 || q.Rows[0]["id"].AsInt() != 2 {
+		t.Fatalf("limit: %v, %v", q, err)
+	}
+	q, err = client.Query("SELECT id FROM patients WHERE name ILIKE '%a%' AND name NOT LIKE 'G_ace' ORDER BY id")
+	if err != nil || len(q.Rows) != 1 || q.Rows[0]["id"].AsInt() != 1 {
+		t.Fatalf("like: %v, %v", q, err)
+	}
+	q, err = client.Query("SELECT id FROM patients WHERE name LIKE 'O''%'")
+	if err != nil || len(q.Rows) != 1 || q.Rows[0]["id"].AsInt() != 3 {
+		t.Fatalf("like quote: %v, %v", q, err)
+	}
+	if res, err := client.Exec("DELETE FROM patients WHERE age < 40"); err != nil || res.RowsAffected != 2 {
+		t.Fatalf("delete: %+v, %v", res, err)
+	}
+	if _, err := client.Query("SELECT * FROM missing"); kimberlite.MapKimberliteError(err).Kind != kimberlite.DomainKindNotFound {
+		t.Fatalf("missing table: %v", err)
+	}
+}
+
+func TestMockReturning(t *testing.T) {
+	ctx := context.Background()
+	client := NewMockClient()
+	defer client.Close()
+
+	if _, err := client.Exec("CREATE TABLE beds (id BIGINT PRIMARY KEY, ward TEXT, patient_id BIGINT)"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.ExecContext(ctx, "INSERT INTO beds (id, ward) VALUES (1, 'icu'), (2, 'icu'), (3, 'er') RETURNING id, ward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 3 || len(res.Returning.Rows) != 3 || res.Returning.Rows[2]["ward"].AsText() != "er" {
+		t.Fatalf("insert: %+v", res)
+	}
+	res, err = client.ExecContext(ctx, "UPDATE beds SET patient_id = $1 WHERE id = $2 RETURNING *", kimberlite.NewInt(42), kimberlite.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 1 || len(res.Returning.Columns) != 3 || res.Returning.Rows[0]["patient_id"].AsInt() != 42 {
+		t.Fatalf("update: %+v", res.Returning)
+	}
+	res, err = client.ExecContext(ctx, "DELETE FROM beds WHERE ward = 'icu' RETURNING patient_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 2 || !res.Returning.Rows[0]["patient_id"].IsNull() || res.Returning.Rows[1]["patient_id"].AsInt() != 42 {
+		t.Fatalf("delete: %+v", res.Returning)
+	}
+	if _, err := client.Exec("DELETE FROM beds RETURNING missing"); !errors.Is(err, kimberlite.ErrQueryFailed) {
+		t.Fatalf("unknown column: %v", err)
+	}
+	if q, err := client.Query("SELECT COUNT(*) AS n FROM beds"); err != nil || q.Rows[0]["n"].AsInt() != 1 {
+		t.Fatalf("count after failed delete: %v, %v", q, err)
+	}
+	if res, err := client.Exec("DELETE FROM beds"); err != nil || res.Returning != nil {
+		t.Fatalf("delete without RETURNING: %+v, %v", res, err)
+	}
+}
+
+func TestMockJSONContains(t *testing.T) {
+	client := NewMockClient()
+	defer client.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE charts (id BIGINT PRIMARY KEY, doc JSONB)",
+		`INSERT INTO charts (id, doc) VALUES (1, '{"owner": "alice", "tags": ["icu", "night"], "vitals": {"hr": 80}}'), (2, '{"owner": "bob", "tags": ["er"]}')`,
+	} {
+		if _, err := client.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	for needle, want := range map[string]int{
+		`{"owner": "bob"}`:                   1,
+		`{"tags": ["night"]}`:                1,
+		`{"vitals": {"hr": 80}, "tags": []}`: 1,
+		`{"tags": ["er", "icu"]}`:            0,
+		`{}`:                                 2,
+	} {
+		q, err := client.Query("SELECT id FROM charts WHERE doc @> $1", kimberlite.NewText(needle))
+		if err != nil || len(q.Rows) != want {
+			t.Errorf("%s: %v, %v", needle, q, err)
+		}
+	}
+	if _, err := client.Query("SELECT id FROM charts WHERE doc @> 'not json'"); !errors.Is(err, kimberlite.ErrQueryFailed) {
+		t.Fatalf("invalid document: %v", err)
+	}
+	if _, err := client.Exec("INSERT INTO charts (id, doc) VALUES (3, '{')"); !errors.Is(err, kimberlite.ErrQueryFailed) {
+		t.Fatalf("invalid json insert: %v", err)
+	}
+}
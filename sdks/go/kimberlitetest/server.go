@@ -0,0 +1,78 @@
+package kimberlitetest
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/internal/embedded"
+)
+
+var servers atomic.Uint64
+
+// Server is an embedded, in-process Kimberlite with a Store per tenant.
+// Clients reach it by its kimberlitetest:// address, with
+// kimberlite.Connect or sql.Open and the sqldriver package, so end-to-end tests of code that connects
+// from its own configuration run without a server or Docker:
+//
+//	srv := kimberlitetest.NewServer()
+//	defer srv.Close()
+//	cfg := app.Config{KimberliteAddr: srv.Addr(), Tenant: 1}
+//	db, err := sql.Open("kimberlite", srv.DSN(1))
+//
+// Tenants are created on their first connection, as on a server.
+type Server struct {
+	addr       string
+	unregister func()
+
+	mu      sync.Mutex
+	tenants map[uint64]*Store
+}
+
+// NewServer starts a server at an address of its own.
+func NewServer() *Server {
+	s := &Server{
+		addr:    fmt.Sprintf("%s%d", embedded.Scheme, servers.Add(1)),
+		tenants: make(map[uint64]*Store),
+	}
+	s.unregister = embedded.Register(s.addr, func(tenant uint64) any {
+		return s.Tenant(tenant)
+	})
+	return s
+}
+
+// Addr returns the server's address, for kimberlite.Connect.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// DSN returns a data source name for tenant, for sql.Open with the
+// sqldriver package.
+func (s *Server) DSN(tenant uint64) string {
+	return fmt.Sprintf("%s?tenant=%d", s.addr, tenant)
+}
+
+// Tenant returns the store of tenant id, creating it if needed.
+func (s *Server) Tenant(id uint64) *Store {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.tenants[id]
+	if !ok {
+		st = NewStore()
+		s.tenants[id] = st
+	}
+	return st
+}
+
+// Client returns a client of tenant. Like Store.Client, it panics if the
+// client cannot be created.
+func (s *Server) Client(tenant uint64, opts ...kimberlite.Option) *kimberlite.Client {
+	return s.Tenant(tenant).Client(append([]kimberlite.Option{kimberlite.WithTenant(tenant)}, opts...)...)
+}
+
+// Close stops the server accepting connections at its address. Clients
+// already connected keep working.
+func (s *Server) Close() {
+	s.unregister()
+}
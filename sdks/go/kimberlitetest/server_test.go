@@ -0,0 +1,58 @@
+package kimberlitetest
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	_ "github.com/kimberlitedb/kimberlite-go/sqldriver"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+
+	a, err := kimberlite.Connect(srv.Addr(), kimberlite.WithTenant(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if _, err := a.Exec("CREATE TABLE notes (id BIGINT PRIMARY KEY, body TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Exec("INSERT INTO notes VALUES (1, 'seen')"); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("kimberlite", srv.DSN(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var body string
+	if err := db.QueryRow("SELECT body FROM notes WHERE id = $1", 1).Scan(&body); err != nil || body != "seen" {
+		t.Fatalf("sql: %q, %v", body, err)
+	}
+
+	// Tenants do not share data.
+	b := srv.Client(2)
+	defer b.Close()
+	if _, err := b.Query("SELECT * FROM notes"); err == nil {
+		t.Fatal("tenant 2 reads tenant 1's table")
+	}
+
+	srv.Close()
+	if _, err := a.Query("SELECT * FROM notes"); err != nil {
+		t.Fatalf("connected client after close: %v", err)
+	}
+	if c, err := kimberlite.Connect(srv.Addr(), kimberlite.WithTenant(1)); !errors.Is(err, kimberlite.ErrConnectionFailed) {
+		if err == nil {
+			c.Close()
+		}
+		t.Fatalf("connect after close: %v", err)
+	}
+	if !strings.HasPrefix(srv.Addr(), "kimberlitetest://") {
+		t.Fatalf("address %q lacks the reserved scheme", srv.Addr())
+	}
+}
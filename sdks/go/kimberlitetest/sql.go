@@ -0,0 +1,1238 @@
+package kimberlitetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Query implements kimberlite.Backend. Statements other than SELECT
+// return the number of rows they changed, and the rows of a RETURNING
+// clause.
+func (s *Store) Query(sql string, args []kimberlite.Value) (*kimberlite.QueryResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.run(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	r := kimberlite.NewQueryResult(res.columns, res.rows)
+	r.RowsAffected = res.affected
+	return r, nil
+}
+
+// Execute implements kimberlite.Backend.
+func (s *Store) Execute(sql string, args []kimberlite.Value) (*kimberlite.ExecResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.run(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	if res.affected > 0 {
+		s.log++
+	}
+	return &kimberlite.ExecResult{RowsAffected: res.affected, LogOffset: s.log}, nil
+}
+
+type result struct {
+	columns  []string
+	rows     []kimberlite.Row
+	affected int64
+}
+
+type table struct {
+	name    string
+	columns []column
+	pk      []int
+	rows    [][]kimberlite.Value
+}
+
+type column struct {
+	name    string
+	typ     kimberlite.ValueType
+	notNull bool
+}
+
+func (t *table) column(name string) (int, error) {
+	for i, c := range t.columns {
+		if strings.EqualFold(c.name, name) {
+			return i, nil
+		}
+	}
+	return 0, queryError("table %s has no column %s", t.name, name)
+}
+
+func queryError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", kimberlite.ErrQueryFailed, fmt.Sprintf(format, args...))
+}
+
+// run parses and executes one statement. s.mu must be held.
+func (s *Store) run(sql string, args []kimberlite.Value) (result, error) {
+	toks, err := tokenize(sql)
+	if err != nil {
+		return result{}, err
+	}
+	p := &parser{toks: toks, args: args, store: s}
+	var res result
+	switch {
+	case p.accept("CREATE"):
+		res, err = p.create()
+	case p.accept("DROP"):
+		res, err = p.drop()
+	case p.accept("INSERT"):
+		res, err = p.insert()
+	case p.accept("SELECT"):
+		res, err = p.selectRows()
+	case p.accept("UPDATE"):
+		res, err = p.update()
+	case p.accept("DELETE"):
+		res, err = p.delete()
+	default:
+		return result{}, queryError("unsupported statement %q", sql)
+	}
+	if err != nil {
+		return result{}, err
+	}
+	p.accept(";")
+	if !p.at(tokEOF) {
+		return result{}, queryError("unexpected %q", p.peek().text)
+	}
+	return res, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokParam
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+}
+
+func tokenize(sql string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(sql); {
+		c := rune(sql[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(sql); j++ {
+				if sql[j] == '\'' {
+					if j+1 < len(sql) && sql[j+1] == '\'' {
+						b.WriteByte('\'')
+						j++
+						continue
+					}
+					break
+				}
+				b.WriteByte(sql[j])
+			}
+			if j >= len(sql) {
+				return nil, queryError("unterminated string")
+			}
+			toks = append(toks, token{tokString, b.String()})
+			i = j + 1
+		case c == '$' || c == '?':
+			j := i + 1
+			for c == '$' && j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			toks = append(toks, token{tokParam, sql[i:j]})
+			i = j
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9':
+			j := i + 1
+			for j < len(sql) && (sql[j] >= '0' && sql[j] <= '9' || sql[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, sql[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(c) || c == '"':
+			if c == '"' {
+				j := strings.IndexByte(sql[i+1:], '"')
+				if j < 0 {
+					return nil, queryError("unterminated identifier")
+				}
+				toks = append(toks, token{tokIdent, sql[i+1 : i+1+j]})
+				i += j + 2
+				continue
+			}
+			j := i + 1
+			for j < len(sql) && (sql[j] == '_' || sql[j] == '.' || unicode.IsLetter(rune(sql[j])) || unicode.IsDigit(rune(sql[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, sql[i:j]})
+			i = j
+		case i+1 < len(sql) && slices.Contains([]string{"<=", ">=", "<>", "!=", "@>"}, sql[i:i+2]):
+			toks = append(toks, token{tokPunct, sql[i : i+2]})
+			i += 2
+		case strings.ContainsRune("(),*=<>;", c):
+			toks = append(toks, token{tokPunct, string(c)})
+			i++
+		default:
+			return nil, queryError("unexpected character %q", c)
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+type parser struct {
+	toks  []token
+	pos   int
+	args  []kimberlite.Value
+	next  int // index of the next ? parameter
+	store *Store
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) at(kind tokKind) bool { return p.peek().kind == kind }
+
+// accept consumes the next token if it is the keyword or punctuation
+// word.
+func (p *parser) accept(word string) bool {
+	t := p.peek()
+	if (t.kind == tokIdent || t.kind == tokPunct) && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(words ...string) error {
+	for _, w := range words {
+		if !p.accept(w) {
+			return queryError("expected %s, found %q", w, p.peek().text)
+		}
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return "", queryError("expected a name, found %q", t.text)
+	}
+	p.pos++
+	return t.text, nil
+}
+
+func (p *parser) table() (*table, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := p.store.tables[strings.ToLower(name)]
+	if !ok {
+		return nil, &kimberlite.KimberliteError{Code: "TableNotFound", Message: fmt.Sprintf("table %s not found", name)}
+	}
+	return t, nil
+}
+
+// value parses a literal or parameter.
+func (p *parser) value() (kimberlite.Value, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokString:
+		p.pos++
+		return kimberlite.NewText(t.text), nil
+	case t.kind == tokNumber:
+		p.pos++
+		if strings.Contains(t.text, ".") {
+			f, err := strconv.ParseFloat(t.text, 64)
+			return kimberlite.NewFloat(f), err
+		}
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		return kimberlite.NewInt(n), err
+	case t.kind == tokParam:
+		p.pos++
+		i := p.next
+		if t.text != "?" {
+			n, _ := strconv.Atoi(t.text[1:])
+			i = n - 1
+		} else {
+			p.next++
+		}
+		if i < 0 || i >= len(p.args) {
+			return kimberlite.Value{}, queryError("no argument for parameter %s", t.text)
+		}
+		return p.args[i], nil
+	case p.accept("NULL"):
+		return kimberlite.NewNull(), nil
+	case p.accept("TRUE"):
+		return kimberlite.NewBool(true), nil
+	case p.accept("FALSE"):
+		return kimberlite.NewBool(false), nil
+	}
+	return kimberlite.Value{}, queryError("expected a value, found %q", t.text)
+}
+
+var columnTypes = map[string]kimberlite.ValueType{
+	"BIGINT": kimberlite.ValueTypeInteger, "INT": kimberlite.ValueTypeInteger, "INTEGER": kimberlite.ValueTypeInteger, "SMALLINT": kimberlite.ValueTypeInteger,
+	"TEXT": kimberlite.ValueTypeText, "VARCHAR": kimberlite.ValueTypeText,
+	"BOOLEAN": kimberlite.ValueTypeBoolean, "BOOL": kimberlite.ValueTypeBoolean,
+	"TIMESTAMP": kimberlite.ValueTypeTimestamp,
+	"REAL":      kimberlite.ValueTypeFloat, "FLOAT": kimberlite.ValueTypeFloat, "DOUBLE": kimberlite.ValueTypeFloat,
+	"BYTES": kimberlite.ValueTypeBytes, "BYTEA": kimberlite.ValueTypeBytes, "BLOB": kimberlite.ValueTypeBytes,
+	"JSON": kimberlite.ValueTypeJSON, "JSONB": kimberlite.ValueTypeJSON,
+}
+
+func (p *parser) create() (result, error) {
+	if err := p.expect("TABLE"); err != nil {
+		return result{}, err
+	}
+	ifNotExists := p.accept("IF")
+	if ifNotExists {
+		if err := p.expect("NOT", "EXISTS"); err != nil {
+			return result{}, err
+		}
+	}
+	name, err := p.ident()
+	if err != nil {
+		return result{}, err
+	}
+	t := &table{name: name}
+	if err := p.expect("("); err != nil {
+		return result{}, err
+	}
+	for {
+		if p.accept("PRIMARY") {
+			if err := p.expect("KEY", "("); err != nil {
+				return result{}, err
+			}
+			for {
+				col, err := p.ident()
+				if err != nil {
+					return result{}, err
+				}
+				i, err := t.column(col)
+				if err != nil {
+					return result{}, err
+				}
+				t.pk = append(t.pk, i)
+				if !p.accept(",") {
+					break
+				}
+			}
+			if err := p.expect(")"); err != nil {
+				return result{}, err
+			}
+		} else {
+			col, err := p.ident()
+			if err != nil {
+				return result{}, err
+			}
+			typ, err := p.ident()
+			if err != nil {
+				return result{}, err
+			}
+			vt, ok := columnTypes[strings.ToUpper(typ)]
+			if !ok {
+				return result{}, queryError("unsupported column type %s", typ)
+			}
+			if p.accept("(") { // VARCHAR(n)
+				if _, err := p.value(); err != nil {
+					return result{}, err
+				}
+				if err := p.expect(")"); err != nil {
+					return result{}, err
+				}
+			}
+			c := column{name: col, typ: vt}
+			for {
+				if p.accept("NOT") {
+					if err := p.expect("NULL"); err != nil {
+						return result{}, err
+					}
+					c.notNull = true
+				} else if p.accept("PRIMARY") {
+					if err := p.expect("KEY"); err != nil {
+						return result{}, err
+					}
+					c.notNull = true
+					t.pk = append(t.pk, len(t.columns))
+				} else if !p.accept("NULL") {
+					break
+				}
+			}
+			t.columns = append(t.columns, c)
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return result{}, err
+	}
+	key := strings.ToLower(name)
+	if _, ok := p.store.tables[key]; ok {
+		if ifNotExists {
+			return result{}, nil
+		}
+		return result{}, &kimberlite.KimberliteError{Code: "TableAlreadyExists", Message: fmt.Sprintf("table %s already exists", name)}
+	}
+	p.store.tables[key] = t
+	return result{}, nil
+}
+
+func (p *parser) drop() (result, error) {
+	if err := p.expect("TABLE"); err != nil {
+		return result{}, err
+	}
+	ifExists := p.accept("IF")
+	if ifExists {
+		if err := p.expect("EXISTS"); err != nil {
+			return result{}, err
+		}
+	}
+	name, err := p.ident()
+	if err != nil {
+		return result{}, err
+	}
+	key := strings.ToLower(name)
+	if _, ok := p.store.tables[key]; !ok && !ifExists {
+		return result{}, &kimberlite.KimberliteError{Code: "TableNotFound", Message: fmt.Sprintf("table %s not found", name)}
+	}
+	delete(p.store.tables, key)
+	return result{}, nil
+}
+
+func (p *parser) insert() (result, error) {
+	if err := p.expect("INTO"); err != nil {
+		return result{}, err
+	}
+	t, err := p.table()
+	if err != nil {
+		return result{}, err
+	}
+	cols := make([]int, len(t.columns))
+	for i := range cols {
+		cols[i] = i
+	}
+	if p.accept("(") {
+		cols = cols[:0]
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return result{}, err
+			}
+			i, err := t.column(name)
+			if err != nil {
+				return result{}, err
+			}
+			cols = append(cols, i)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return result{}, err
+		}
+	}
+	if err := p.expect("VALUES"); err != nil {
+		return result{}, err
+	}
+	var rows [][]kimberlite.Value
+	for {
+		if err := p.expect("("); err != nil {
+			return result{}, err
+		}
+		row := make([]kimberlite.Value, len(t.columns))
+		for i := range row {
+			row[i] = kimberlite.NewNull()
+		}
+		for i, col := range cols {
+			if i > 0 {
+				if err := p.expect(","); err != nil {
+					return result{}, err
+				}
+			}
+			v, err := p.value()
+			if err != nil {
+				return result{}, err
+			}
+			if row[col], err = t.coerce(col, v); err != nil {
+				return result{}, err
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return result{}, err
+		}
+		rows = append(rows, row)
+		if !p.accept(",") {
+			break
+		}
+	}
+	ret, err := p.returning(t)
+	if err != nil {
+		return result{}, err
+	}
+	for _, row := range rows {
+		if err := t.check(row, -1); err != nil {
+			return result{}, err
+		}
+		t.rows = append(t.rows, row)
+	}
+	return ret.result(rows), nil
+}
+
+// coerce converts v to the type of column col.
+func (t *table) coerce(col int, v kimberlite.Value) (kimberlite.Value, error) {
+	c := t.columns[col]
+	switch {
+	case v.IsNull() || v.Type == c.typ:
+		return v, nil
+	case c.typ == kimberlite.ValueTypeFloat && v.Type == kimberlite.ValueTypeInteger:
+		return kimberlite.NewFloat(float64(v.AsInt())), nil
+	case c.typ == kimberlite.ValueTypeTimestamp && v.Type == kimberlite.ValueTypeText:
+		ts, err := time.Parse(time.RFC3339Nano, v.AsText())
+		if err != nil {
+			return v, queryError("column %s: invalid timestamp %q", c.name, v.AsText())
+		}
+		return kimberlite.NewTimestamp(ts), nil
+	case c.typ == kimberlite.ValueTypeJSON && v.Type == kimberlite.ValueTypeText:
+		if !json.Valid([]byte(v.AsText())) {
+			return v, queryError("column %s: invalid json %q", c.name, v.AsText())
+		}
+		return kimberlite.NewRawJSON(json.RawMessage(v.AsText())), nil
+	}
+	return v, queryError("column %s of type %s cannot hold %s", c.name, c.typ, v.Type)
+}
+
+// check validates row, to be stored at index at or appended if at is
+// -1, against the table's constraints.
+func (t *table) check(row []kimberlite.Value, at int) error {
+	for i, c := range t.columns {
+		if c.notNull && row[i].IsNull() {
+			return queryError("column %s cannot be NULL", c.name)
+		}
+	}
+	if len(t.pk) == 0 {
+		return nil
+	}
+	for i, other := range t.rows {
+		if i == at {
+			continue
+		}
+		same := true
+		for _, k := range t.pk {
+			if c, ok := compare(row[k], other[k]); !ok || c != 0 {
+				same = false
+				break
+			}
+		}
+		if same {
+			return queryError("duplicate primary key in table %s", t.name)
+		}
+	}
+	return nil
+}
+
+func (p *parser) selectRows() (result, error) {
+	type item struct {
+		name  string
+		alias string
+	}
+	var items []item
+	star, count := false, false
+	switch {
+	case p.accept("*"):
+		star = true
+	case p.accept("COUNT"):
+		if err := p.expect("(", "*", ")"); err != nil {
+			return result{}, err
+		}
+		count = true
+		items = append(items, item{name: "count", alias: "count"})
+		if p.accept("AS") {
+			alias, err := p.ident()
+			if err != nil {
+				return result{}, err
+			}
+			items[0].alias = alias
+		}
+	default:
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return result{}, err
+			}
+			it := item{name: name, alias: name}
+			if p.accept("AS") {
+				if it.alias, err = p.ident(); err != nil {
+					return result{}, err
+				}
+			}
+			items = append(items, it)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err := p.expect("FROM"); err != nil {
+		return result{}, err
+	}
+	t, err := p.table()
+	if err != nil {
+		return result{}, err
+	}
+	rows, err := p.where(t)
+	if err != nil {
+		return result{}, err
+	}
+	matched := make([][]kimberlite.Value, len(rows))
+	for i, r := range rows {
+		matched[i] = t.rows[r]
+	}
+
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return result{}, err
+		}
+		type key struct {
+			col  int
+			desc bool
+		}
+		var keys []key
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return result{}, err
+			}
+			col, err := t.column(name)
+			if err != nil {
+				return result{}, err
+			}
+			k := key{col: col}
+			if p.accept("DESC") {
+				k.desc = true
+			} else {
+				p.accept("ASC")
+			}
+			keys = append(keys, k)
+			if !p.accept(",") {
+				break
+			}
+		}
+		slices.SortStableFunc(matched, func(a, b []kimberlite.Value) int {
+			for _, k := range keys {
+				c := order(a[k.col], b[k.col])
+				if k.desc {
+					c = -c
+				}
+				if c != 0 {
+					return c
+				}
+			}
+			return 0
+		})
+	}
+	if p.accept("LIMIT") {
+		n, err := p.count()
+		if err != nil {
+			return result{}, err
+		}
+		offset := 0
+		if p.accept("OFFSET") {
+			if offset, err = p.count(); err != nil {
+				return result{}, err
+			}
+		}
+		matched = matched[min(offset, len(matched)):]
+		matched = matched[:min(n, len(matched))]
+	}
+
+	if count {
+		return result{columns: []string{items[0].alias}, rows: []kimberlite.Row{{kimberlite.NewInt(int64(len(matched)))}}}, nil
+	}
+	var cols []int
+	var names []string
+	if star {
+		for i, c := range t.columns {
+			cols, names = append(cols, i), append(names, c.name)
+		}
+	} else {
+		for _, it := range items {
+			i, err := t.column(it.name)
+			if err != nil {
+				return result{}, err
+			}
+			cols, names = append(cols, i), append(names, it.alias)
+		}
+	}
+	res := result{columns: names, rows: make([]kimberlite.Row, len(matched))}
+	for i, row := range matched {
+		out := make(kimberlite.Row, len(cols))
+		for j, c := range cols {
+			out[j] = row[c]
+		}
+		res.rows[i] = out
+	}
+	return res, nil
+}
+
+func (p *parser) count() (int, error) {
+	v, err := p.value()
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.IntOK()
+	if !ok || n < 0 {
+		return 0, queryError("invalid row count")
+	}
+	return int(n), nil
+}
+
+func (p *parser) update() (result, error) {
+	t, err := p.table()
+	if err != nil {
+		return result{}, err
+	}
+	if err := p.expect("SET"); err != nil {
+		return result{}, err
+	}
+	type assignment struct {
+		col int
+		val kimberlite.Value
+	}
+	var set []assignment
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return result{}, err
+		}
+		col, err := t.column(name)
+		if err != nil {
+			return result{}, err
+		}
+		if err := p.expect("="); err != nil {
+			return result{}, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return result{}, err
+		}
+		if v, err = t.coerce(col, v); err != nil {
+			return result{}, err
+		}
+		set = append(set, assignment{col, v})
+		if !p.accept(",") {
+			break
+		}
+	}
+	rows, err := p.where(t)
+	if err != nil {
+		return result{}, err
+	}
+	ret, err := p.returning(t)
+	if err != nil {
+		return result{}, err
+	}
+	updated := make([][]kimberlite.Value, len(rows))
+	for i, r := range rows {
+		row := slices.Clone(t.rows[r])
+		for _, a := range set {
+			row[a.col] = a.val
+		}
+		if err := t.check(row, r); err != nil {
+			return result{}, err
+		}
+		updated[i] = row
+	}
+	for i, r := range rows {
+		t.rows[r] = updated[i]
+	}
+	return ret.result(updated), nil
+}
+
+func (p *parser) delete() (result, error) {
+	if err := p.expect("FROM"); err != nil {
+		return result{}, err
+	}
+	t, err := p.table()
+	if err != nil {
+		return result{}, err
+	}
+	rows, err := p.where(t)
+	if err != nil {
+		return result{}, err
+	}
+	ret, err := p.returning(t)
+	if err != nil {
+		return result{}, err
+	}
+	drop := make(map[int]bool, len(rows))
+	var dropped [][]kimberlite.Value
+	for _, r := range rows {
+		drop[r] = true
+		dropped = append(dropped, t.rows[r])
+	}
+	kept := make([][]kimberlite.Value, 0, len(t.rows)-len(rows))
+	for i, row := range t.rows {
+		if !drop[i] {
+			kept = append(kept, row)
+		}
+	}
+	t.rows = kept
+	return ret.result(dropped), nil
+}
+
+// returningClause is the columns of a RETURNING clause.
+type returningClause struct {
+	cols  []int
+	names []string
+}
+
+// returning parses an optional RETURNING clause listing columns of t.
+func (p *parser) returning(t *table) (returningClause, error) {
+	var ret returningClause
+	if !p.accept("RETURNING") {
+		return ret, nil
+	}
+	if p.accept("*") {
+		for i, c := range t.columns {
+			ret.cols, ret.names = append(ret.cols, i), append(ret.names, c.name)
+		}
+		return ret, nil
+	}
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return ret, err
+		}
+		i, err := t.column(name)
+		if err != nil {
+			return ret, err
+		}
+		ret.cols, ret.names = append(ret.cols, i), append(ret.names, name)
+		if !p.accept(",") {
+			return ret, nil
+		}
+	}
+}
+
+// result returns the result of a statement that changed rows, with
+// their returned columns.
+func (ret returningClause) result(rows [][]kimberlite.Value) result {
+	res := result{affected: int64(len(rows))}
+	if ret.names == nil {
+		return res
+	}
+	res.columns, res.rows = ret.names, make([]kimberlite.Row, len(rows))
+	for i, row := range rows {
+		out := make(kimberlite.Row, len(ret.cols))
+		for j, c := range ret.cols {
+			out[j] = row[c]
+		}
+		res.rows[i] = out
+	}
+	return res
+}
+
+// truth is a value of SQL's three-valued logic.
+type truth int
+
+const (
+	no truth = iota
+	yes
+	unknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return yes
+	}
+	return no
+}
+
+type condition func(row []kimberlite.Value) truth
+
+// where parses an optional WHERE clause and returns the indexes of the
+// rows of t matching it.
+func (p *parser) where(t *table) ([]int, error) {
+	match := func([]kimberlite.Value) truth { return yes }
+	if p.accept("WHERE") {
+		var err error
+		if match, err = p.or(t); err != nil {
+			return nil, err
+		}
+	}
+	var rows []int
+	for i, row := range t.rows {
+		if match(row) == yes {
+			rows = append(rows, i)
+		}
+	}
+	return rows, nil
+}
+
+func (p *parser) or(t *table) (condition, error) {
+	left, err := p.and(t)
+	for err == nil && p.accept("OR") {
+		var right condition
+		if right, err = p.and(t); err == nil {
+			l, r := left, right
+			left = func(row []kimberlite.Value) truth {
+				a, b := l(row), r(row)
+				switch {
+				case a == yes || b == yes:
+					return yes
+				case a == unknown || b == unknown:
+					return unknown
+				}
+				return no
+			}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) and(t *table) (condition, error) {
+	left, err := p.not(t)
+	for err == nil && p.accept("AND") {
+		var right condition
+		if right, err = p.not(t); err == nil {
+			l, r := left, right
+			left = func(row []kimberlite.Value) truth {
+				a, b := l(row), r(row)
+				switch {
+				case a == no || b == no:
+					return no
+				case a == unknown || b == unknown:
+					return unknown
+				}
+				return yes
+			}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) not(t *table) (condition, error) {
+	if p.accept("NOT") {
+		c, err := p.not(t)
+		if err != nil {
+			return nil, err
+		}
+		return func(row []kimberlite.Value) truth { return negate(c(row)) }, nil
+	}
+	if p.accept("(") {
+		c, err := p.or(t)
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+	return p.comparison(t)
+}
+
+func negate(v truth) truth {
+	switch v {
+	case yes:
+		return no
+	case no:
+		return yes
+	}
+	return unknown
+}
+
+// operand is a column of the row or a value.
+type operand struct {
+	col int
+	val kimberlite.Value
+}
+
+func (o operand) eval(row []kimberlite.Value) kimberlite.Value {
+	if o.col >= 0 {
+		return row[o.col]
+	}
+	return o.val
+}
+
+func (p *parser) operand(t *table) (operand, error) {
+	if tok := p.peek(); tok.kind == tokIdent && !slices.Contains([]string{"NULL", "TRUE", "FALSE"}, strings.ToUpper(tok.text)) {
+		p.pos++
+		col, err := t.column(tok.text)
+		return operand{col: col}, err
+	}
+	v, err := p.value()
+	return operand{col: -1, val: v}, err
+}
+
+func (p *parser) comparison(t *table) (condition, error) {
+	left, err := p.operand(t)
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("IS") {
+		want := !p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return func(row []kimberlite.Value) truth { return truthOf(left.eval(row).IsNull() == want) }, nil
+	}
+	negated := p.accept("NOT")
+	if p.at(tokIdent) && (strings.EqualFold(p.peek().text, "LIKE") || strings.EqualFold(p.peek().text, "ILIKE")) {
+		return p.like(left, negated)
+	}
+	if negated || p.accept("IN") {
+		if negated {
+			if err := p.expect("IN"); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var list []operand
+		for {
+			o, err := p.operand(t)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, o)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(row []kimberlite.Value) truth {
+			v, result := left.eval(row), no
+			for _, o := range list {
+				c, ok := compare(v, o.eval(row))
+				if !ok {
+					result = unknown
+				} else if c == 0 {
+					result = yes
+					break
+				}
+			}
+			if negated {
+				return negate(result)
+			}
+			return result
+		}, nil
+	}
+
+	if p.accept("@>") {
+		return p.contains(left)
+	}
+	op := p.peek().text
+	if p.peek().kind != tokPunct || !slices.Contains([]string{"=", "<>", "!=", "<", "<=", ">", ">="}, op) {
+		return nil, queryError("expected a comparison, found %q", op)
+	}
+	p.pos++
+	right, err := p.operand(t)
+	if err != nil {
+		return nil, err
+	}
+	return func(row []kimberlite.Value) truth {
+		c, ok := compare(left.eval(row), right.eval(row))
+		if !ok {
+			return unknown
+		}
+		switch op {
+		case "=":
+			return truthOf(c == 0)
+		case "<>", "!=":
+			return truthOf(c != 0)
+		case "<":
+			return truthOf(c < 0)
+		case "<=":
+			return truthOf(c <= 0)
+		case ">":
+			return truthOf(c > 0)
+		}
+		return truthOf(c >= 0)
+	}, nil
+}
+
+// like parses the rest of a LIKE or ILIKE condition on left. As on the
+// server, the pattern is a string literal, % and _ are its wildcards
+// and a backslash makes the one after it literal.
+func (p *parser) like(left operand, negated bool) (condition, error) {
+	fold := strings.EqualFold(p.peek().text, "ILIKE")
+	p.pos++
+	if !p.at(tokString) {
+		return nil, queryError("LIKE pattern must be a string literal")
+	}
+	pattern := p.peek().text
+	p.pos++
+	var b strings.Builder
+	if fold {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("(?s)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern) && (pattern[i+1] == '%' || pattern[i+1] == '_'):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '%':
+			b.WriteString(".*")
+		case c == '_':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteByte('$')
+	re := regexp.MustCompile(b.String())
+	return func(row []kimberlite.Value) truth {
+		v := left.eval(row)
+		if v.Type != kimberlite.ValueTypeText {
+			return unknown
+		}
+		return truthOf(re.MatchString(v.AsText()) != negated)
+	}, nil
+}
+
+// contains parses the rest of a JSON containment condition, left @>
+// document. As on the server, the document may be JSON or text holding
+// JSON.
+func (p *parser) contains(left operand) (condition, error) {
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	var needle any
+	if err := v.AsJSON(&needle); err != nil {
+		return nil, queryError("@> needs a JSON document: %v", err)
+	}
+	return func(row []kimberlite.Value) truth {
+		cell := left.eval(row)
+		if cell.Type != kimberlite.ValueTypeJSON {
+			return unknown
+		}
+		var doc any
+		if cell.AsJSON(&doc) != nil {
+			return unknown
+		}
+		return truthOf(jsonContains(doc, needle))
+	}, nil
+}
+
+// jsonContains reports whether doc contains needle: an object contains
+// an object whose members it contains in turn, an array contains an
+// array each of whose elements it contains, and other values contain
+// those equal to them.
+func jsonContains(doc, needle any) bool {
+	switch n := needle.(type) {
+	case map[string]any:
+		d, ok := doc.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, nv := range n {
+			dv, ok := d[k]
+			if !ok || !jsonContains(dv, nv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		d, ok := doc.([]any)
+		if !ok {
+			return false
+		}
+		for _, nv := range n {
+			if !slices.ContainsFunc(d, func(dv any) bool { return jsonContains(dv, nv) }) {
+				return false
+			}
+		}
+		return true
+	}
+	return doc == needle
+}
+
+// compare compares two values, and reports false if they are not
+// comparable: either is NULL or their types differ.
+func compare(a, b kimberlite.Value) (int, bool) {
+	if a.IsNull() || b.IsNull() {
+		return 0, false
+	}
+	if a.Type == kimberlite.ValueTypeFloat || b.Type == kimberlite.ValueTypeFloat {
+		x, ok1 := number(a)
+		y, ok2 := number(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return cmpOrdered(x, y), true
+	}
+	if a.Type != b.Type {
+		return 0, false
+	}
+	switch a.Type {
+	case kimberlite.ValueTypeInteger:
+		return cmpOrdered(a.AsInt(), b.AsInt()), true
+	case kimberlite.ValueTypeText:
+		return strings.Compare(a.AsText(), b.AsText()), true
+	case kimberlite.ValueTypeBoolean:
+		x, y := a.AsBool(), b.AsBool()
+		if x == y {
+			return 0, true
+		}
+		if !x {
+			return -1, true
+		}
+		return 1, true
+	case kimberlite.ValueTypeTimestamp:
+		return a.AsTimestamp().Compare(b.AsTimestamp()), true
+	case kimberlite.ValueTypeBytes:
+		return strings.Compare(string(a.AsBytes()), string(b.AsBytes())), true
+	}
+	return 0, false
+}
+
+func number(v kimberlite.Value) (float64, bool) {
+	switch v.Type {
+	case kimberlite.ValueTypeInteger:
+		return float64(v.AsInt()), true
+	case kimberlite.ValueTypeFloat:
+		return v.AsFloat(), true
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | float64](x, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// order compares values for ORDER BY, with NULLs first.
+func order(a, b kimberlite.Value) int {
+	switch {
+	case a.IsNull() && b.IsNull():
+		return 0
+	case a.IsNull():
+		return -1
+	case b.IsNull():
+		return 1
+	}
+	c, _ := compare(a, b)
+	return c
+}
@@ -0,0 +1,81 @@
+// Package awskms wraps data keys with AWS KMS, for
+// kimberlite.EnvelopeKeys:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	wrapper := awskms.New(kms.NewFromConfig(cfg), "alias/kimberlite-phi")
+//	keys, err := kimberlite.NewEnvelopeKeys(
+//	    kimberlite.WithMasterKey("aws-phi", wrapper, kimberlite.DataClassRestricted))
+//
+// Only this module imports the KMS client; EnvelopeKeys sees nothing
+// but the key wrapper, so programs whose master keys live in GCP,
+// Azure or Vault build without the AWS SDK.
+package awskms
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// API is the subset of *kms.Client used by Wrapper.
+type API interface {
+	Encrypt(ctx context.Context, in *kms.EncryptInput, opts ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, in *kms.DecryptInput, opts ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// Wrapper implements kimberlite.KeyWrapper with a KMS symmetric key.
+type Wrapper struct {
+	api     API
+	keyID   string
+	context map[string]string
+}
+
+var _ kimberlite.KeyWrapper = (*Wrapper)(nil)
+
+// Option configures a Wrapper.
+type Option func(*Wrapper)
+
+// WithEncryptionContext binds wrapped keys to context, which KMS
+// requires again to unwrap them and records in CloudTrail.
+func WithEncryptionContext(context map[string]string) Option {
+	return func(w *Wrapper) { w.context = context }
+}
+
+// New returns a Wrapper for keyID, a key ID, ARN or alias.
+func New(api API, keyID string, opts ...Option) *Wrapper {
+	w := &Wrapper{api: api, keyID: keyID}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// WrapKey implements kimberlite.KeyWrapper.
+func (w *Wrapper) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	out, err := w.api.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(w.keyID),
+		Plaintext:         dataKey,
+		EncryptionContext: w.context,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+// UnwrapKey implements kimberlite.KeyWrapper. The key is named so KMS
+// fails rather than decrypt a blob wrapped by a different key.
+func (w *Wrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := w.api.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(w.keyID),
+		CiphertextBlob:    wrapped,
+		EncryptionContext: w.context,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
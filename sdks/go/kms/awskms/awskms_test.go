@@ -0,0 +1,68 @@
+package awskms
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+type fakeKMS struct {
+	keyID string
+	calls int
+}
+
+func (f *fakeKMS) Encrypt(_ context.Context, in *kms.EncryptInput, _ ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	f.calls++
+	if aws.ToString(in.KeyId) != f.keyID || in.EncryptionContext["tenant"] != "1" {
+		return nil, errors.New("AccessDeniedException")
+	}
+	return &kms.EncryptOutput{CiphertextBlob: append([]byte("blob:"), in.Plaintext...)}, nil
+}
+
+func (f *fakeKMS) Decrypt(_ context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.calls++
+	if aws.ToString(in.KeyId) != f.keyID || in.EncryptionContext["tenant"] != "1" {
+		return nil, errors.New("AccessDeniedException")
+	}
+	return &kms.DecryptOutput{Plaintext: bytes.TrimPrefix(in.CiphertextBlob, []byte("blob:"))}, nil
+}
+
+func TestWrapper(t *testing.T) {
+	ctx := context.Background()
+	api := &fakeKMS{keyID: "alias/phi"}
+	w := New(api, "alias/phi", WithEncryptionContext(map[string]string{"tenant": "1"}))
+
+	keys, err := kimberlite.NewEnvelopeKeys(kimberlite.WithMasterKey("aws", w, kimberlite.DataClassRestricted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, key, err := keys.EncryptionKey(ctx, kimberlite.DataClassRestricted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := keys.EncryptionKey(ctx, kimberlite.DataClassRestricted); err != nil || api.calls != 1 {
+		t.Fatalf("data key not cached: %d calls, %v", api.calls, err)
+	}
+
+	reader, _ := kimberlite.NewEnvelopeKeys(kimberlite.WithMasterKey("aws", w))
+	for i := 0; i < 2; i++ {
+		got, err := reader.DecryptionKey(ctx, id)
+		if err != nil || !bytes.Equal(got, key) {
+			t.Fatalf("unwrap: %v", err)
+		}
+	}
+	if api.calls != 2 {
+		t.Fatalf("unwrapped key not cached: %d calls", api.calls)
+	}
+
+	denied, _ := kimberlite.NewEnvelopeKeys(kimberlite.WithMasterKey("aws", New(api, "alias/phi")))
+	if _, err := denied.DecryptionKey(ctx, id); err == nil {
+		t.Fatal("expected error without encryption context")
+	}
+}
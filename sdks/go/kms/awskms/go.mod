@@ -0,0 +1,17 @@
+module github.com/kimberlitedb/kimberlite-go/kms/awskms
+
+go 1.21
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.30.5
+	github.com/aws/aws-sdk-go-v2/service/kms v1.35.7
+	github.com/kimberlitedb/kimberlite-go v0.5.0
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.3.17 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.17 // indirect
+	github.com/aws/smithy-go v1.20.4 // indirect
+)
+
+replace github.com/kimberlitedb/kimberlite-go => ../../
@@ -0,0 +1,247 @@
+// Package kms wraps data keys with cloud key management services, for
+// kimberlite.EnvelopeKeys:
+//
+//	wrapper := kms.NewVault(http.DefaultClient, "https://vault:8200", token, "phi")
+//	keys, err := kimberlite.NewEnvelopeKeys(
+//	    kimberlite.WithMasterKey("vault-phi", wrapper, kimberlite.DataClassRestricted))
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(1),
+//	    kimberlite.WithEncryption(kimberlite.NewEncryption(keys)))
+//
+// Google Cloud KMS, Azure Key Vault and HashiCorp Vault are called over
+// their REST APIs with the standard library. Authenticate Google and
+// Azure requests through the *http.Client, e.g. one from
+// golang.org/x/oauth2/google or a transport adding an azidentity
+// bearer token. AWS KMS needs request signing and lives in the awskms
+// module.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Option configures a wrapper.
+type Option func(*options)
+
+type options struct {
+	endpoint  string
+	namespace string
+	mount     string
+	algorithm string
+}
+
+// WithEndpoint overrides the service's base URL, e.g. for a regional
+// or private endpoint.
+func WithEndpoint(url string) Option {
+	return func(o *options) { o.endpoint = strings.TrimSuffix(url, "/") }
+}
+
+// WithNamespace sets the Vault Enterprise namespace.
+func WithNamespace(ns string) Option {
+	return func(o *options) { o.namespace = ns }
+}
+
+// WithMount sets the path the Vault transit engine is mounted at. The
+// default is "transit".
+func WithMount(path string) Option {
+	return func(o *options) { o.mount = strings.Trim(path, "/") }
+}
+
+// WithAlgorithm sets the Azure Key Vault wrapping algorithm. The
+// default is "RSA-OAEP-256"; use "A256KW" for Managed HSM AES keys.
+func WithAlgorithm(alg string) Option {
+	return func(o *options) { o.algorithm = alg }
+}
+
+func newOptions(opts []Option, o options) options {
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// GCP wraps keys with a Google Cloud KMS symmetric key.
+type GCP struct {
+	client   *http.Client
+	endpoint string
+	key      string
+}
+
+var _ kimberlite.KeyWrapper = (*GCP)(nil)
+
+// NewGCP returns a wrapper for the key named
+// projects/P/locations/L/keyRings/R/cryptoKeys/K.
+func NewGCP(client *http.Client, keyName string, opts ...Option) *GCP {
+	o := newOptions(opts, options{endpoint: "https://cloudkms.googleapis.com"})
+	return &GCP{client: client, endpoint: o.endpoint, key: keyName}
+}
+
+// WrapKey implements kimberlite.KeyWrapper.
+func (g *GCP) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var out struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	err := call(ctx, g.client, g.endpoint+"/v1/"+g.key+":encrypt", nil, map[string]any{"plaintext": dataKey}, &out)
+	return out.Ciphertext, err
+}
+
+// UnwrapKey implements kimberlite.KeyWrapper.
+func (g *GCP) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	err := call(ctx, g.client, g.endpoint+"/v1/"+g.key+":decrypt", nil, map[string]any{"ciphertext": wrapped}, &out)
+	return out.Plaintext, err
+}
+
+// Azure wraps keys with an Azure Key Vault or Managed HSM key.
+type Azure struct {
+	client    *http.Client
+	keyURL    string
+	algorithm string
+}
+
+var _ kimberlite.KeyWrapper = (*Azure)(nil)
+
+const azureAPIVersion = "7.4"
+
+// NewAzure returns a wrapper for the key named keyName in the vault at
+// vaultURL, e.g. "https://my-vault.vault.azure.net". Keys are wrapped
+// with the key's current version and unwrapped with the version that
+// wrapped them.
+func NewAzure(client *http.Client, vaultURL, keyName string, opts ...Option) *Azure {
+	o := newOptions(opts, options{endpoint: vaultURL, algorithm: "RSA-OAEP-256"})
+	return &Azure{client: client, keyURL: strings.TrimSuffix(o.endpoint, "/") + "/keys/" + url.PathEscape(keyName), algorithm: o.algorithm}
+}
+
+type azureKeyOp struct {
+	Alg   string `json:"alg"`
+	Value string `json:"value"`
+}
+
+type azureKeyResult struct {
+	Kid   string `json:"kid"`
+	Value string `json:"value"`
+}
+
+// WrapKey implements kimberlite.KeyWrapper. The result is the key
+// version's length-prefixed name followed by the wrapped key.
+func (a *Azure) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var out azureKeyResult
+	in := azureKeyOp{Alg: a.algorithm, Value: base64.RawURLEncoding.EncodeToString(dataKey)}
+	if err := call(ctx, a.client, a.keyURL+"/wrapkey?api-version="+azureAPIVersion, nil, in, &out); err != nil {
+		return nil, err
+	}
+	version := out.Kid[strings.LastIndexByte(out.Kid, '/')+1:]
+	wrapped, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(out.Value, "="))
+	if err != nil || version == "" || len(version) > 255 {
+		return nil, fmt.Errorf("kimberlite: azure key vault: malformed wrapkey response")
+	}
+	return append(append([]byte{byte(len(version))}, version...), wrapped...), nil
+}
+
+// UnwrapKey implements kimberlite.KeyWrapper.
+func (a *Azure) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 1 || len(wrapped) < 1+int(wrapped[0]) {
+		return nil, errors.New("kimberlite: azure key vault: malformed wrapped key")
+	}
+	version, key := string(wrapped[1:1+int(wrapped[0])]), wrapped[1+int(wrapped[0]):]
+	var out azureKeyResult
+	in := azureKeyOp{Alg: a.algorithm, Value: base64.RawURLEncoding.EncodeToString(key)}
+	if err := call(ctx, a.client, a.keyURL+"/"+url.PathEscape(version)+"/unwrapkey?api-version="+azureAPIVersion, nil, in, &out); err != nil {
+		return nil, err
+	}
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(out.Value, "="))
+}
+
+// Vault wraps keys with a HashiCorp Vault transit key.
+type Vault struct {
+	client *http.Client
+	base   string
+	key    string
+	header http.Header
+}
+
+var _ kimberlite.KeyWrapper = (*Vault)(nil)
+
+// NewVault returns a wrapper for the transit key keyName of the Vault
+// server at addr, authenticating with token.
+func NewVault(client *http.Client, addr, token, keyName string, opts ...Option) *Vault {
+	o := newOptions(opts, options{endpoint: addr, mount: "transit"})
+	h := http.Header{"X-Vault-Token": {token}}
+	if o.namespace != "" {
+		h.Set("X-Vault-Namespace", o.namespace)
+	}
+	return &Vault{
+		client: client,
+		base:   strings.TrimSuffix(o.endpoint, "/") + "/v1/" + o.mount,
+		key:    url.PathEscape(keyName),
+		header: h,
+	}
+}
+
+// WrapKey implements kimberlite.KeyWrapper. The result is Vault's
+// "vault:v<N>:..." ciphertext.
+func (v *Vault) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var out struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	err := call(ctx, v.client, v.base+"/encrypt/"+v.key, v.header, map[string]any{"plaintext": dataKey}, &out)
+	return []byte(out.Data.Ciphertext), err
+}
+
+// UnwrapKey implements kimberlite.KeyWrapper.
+func (v *Vault) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Data struct {
+			Plaintext []byte `json:"plaintext"`
+		} `json:"data"`
+	}
+	err := call(ctx, v.client, v.base+"/decrypt/"+v.key, v.header, map[string]any{"ciphertext": string(wrapped)}, &out)
+	return out.Data.Plaintext, err
+}
+
+// call POSTs in as JSON to endpoint and decodes the JSON response into out.
+func call(ctx context.Context, client *http.Client, endpoint string, header http.Header, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return fmt.Errorf("kimberlite: kms: %s: %s: %s", req.URL.Redacted(), resp.Status, msg)
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,110 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// flip stands in for a KMS's encryption.
+func flip(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ 0x5a
+	}
+	return out
+}
+
+func fakeServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b64 := func(s string) []byte {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				t.Errorf("%s: %v", r.URL.Path, err)
+			}
+			return b
+		}
+		b64url := func(s string) []byte {
+			b, err := base64.RawURLEncoding.DecodeString(s)
+			if err != nil {
+				t.Errorf("%s: %v", r.URL.Path, err)
+			}
+			return b
+		}
+		var out any
+		switch p := r.URL.Path; {
+		case p == "/v1/projects/p/locations/l/keyRings/r/cryptoKeys/k:encrypt":
+			out = map[string]any{"ciphertext": flip(b64(in["plaintext"]))}
+		case p == "/v1/projects/p/locations/l/keyRings/r/cryptoKeys/k:decrypt":
+			out = map[string]any{"plaintext": flip(b64(in["ciphertext"]))}
+		case p == "/keys/phi/wrapkey" && in["alg"] == "RSA-OAEP-256":
+			out = map[string]string{"kid": "https://vault/keys/phi/v7", "value": base64.RawURLEncoding.EncodeToString(flip(b64url(in["value"])))}
+		case p == "/keys/phi/v7/unwrapkey":
+			out = map[string]string{"kid": "https://vault/keys/phi/v7", "value": base64.RawURLEncoding.EncodeToString(flip(b64url(in["value"])))}
+		case p == "/v1/transit/encrypt/phi" && r.Header.Get("X-Vault-Token") == "tok" && r.Header.Get("X-Vault-Namespace") == "ns":
+			out = map[string]any{"data": map[string]string{"ciphertext": "vault:v1:" + base64.StdEncoding.EncodeToString(flip(b64(in["plaintext"])))}}
+		case p == "/v1/transit/decrypt/phi" && strings.HasPrefix(in["ciphertext"], "vault:v1:"):
+			out = map[string]any{"data": map[string]any{"plaintext": flip(b64(strings.TrimPrefix(in["ciphertext"], "vault:v1:")))}}
+		default:
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func TestWrappers(t *testing.T) {
+	ctx := context.Background()
+	srv := fakeServer(t)
+	defer srv.Close()
+	c := srv.Client()
+
+	wrappers := map[string]kimberlite.KeyWrapper{
+		"gcp":   NewGCP(c, "projects/p/locations/l/keyRings/r/cryptoKeys/k", WithEndpoint(srv.URL)),
+		"azure": NewAzure(c, srv.URL, "phi"),
+		"vault": NewVault(c, srv.URL, "tok", "phi", WithNamespace("ns")),
+	}
+	dataKey := bytes.Repeat([]byte{7}, 32)
+	for name, w := range wrappers {
+		wrapped, err := w.WrapKey(ctx, dataKey)
+		if err != nil {
+			t.Fatalf("%s wrap: %v", name, err)
+		}
+		if bytes.Contains(wrapped, dataKey) {
+			t.Fatalf("%s: wrapped key contains the data key", name)
+		}
+		got, err := w.UnwrapKey(ctx, wrapped)
+		if err != nil || !bytes.Equal(got, dataKey) {
+			t.Fatalf("%s unwrap: %x, %v", name, got, err)
+		}
+
+		keys, err := kimberlite.NewEnvelopeKeys(kimberlite.WithMasterKey(name, w, kimberlite.DataClassRestricted))
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, key, err := keys.EncryptionKey(ctx, kimberlite.DataClassRestricted)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		reader, _ := kimberlite.NewEnvelopeKeys(kimberlite.WithMasterKey(name, w))
+		if got, err := reader.DecryptionKey(ctx, id); err != nil || !bytes.Equal(got, key) {
+			t.Fatalf("%s envelope: %v", name, err)
+		}
+	}
+
+	if _, err := NewVault(c, srv.URL, "wrong", "phi").WrapKey(ctx, dataKey); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected permission error, got %v", err)
+	}
+}
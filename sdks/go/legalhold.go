@@ -0,0 +1,233 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A legal hold preserves a stream or a data subject for litigation or
+// an investigation. While a hold exists the client refuses to erase the
+// subject with Erase, and CheckDisposal fails so retention jobs skip
+// the stream:
+//
+//	err := client.PlaceLegalHold(ctx, kimberlite.SubjectHold("patient-4711"), "CASE-2026-118")
+//	...
+//	err = client.Erase(ctx, "patient-4711") // errors.Is(err, kimberlite.ErrLegalHold)
+//
+// Holds are kept in a LegalHoldStore given to the client with
+// WithLegalHolds; every client that may dispose of data must share it.
+// The client enforces holds, not the server, which knows nothing of
+// them: a client without the store could erase held data. So Erase and
+// CheckDisposal fail closed, with ErrNoLegalHoldStore, on a client
+// without one; a deployment that does not use holds says so with
+// WithLegalHolds(NoLegalHolds).
+
+// ErrLegalHold is returned for operations that would destroy data
+// under legal hold.
+var ErrLegalHold = errors.New("kimberlite: under legal hold")
+
+// ErrHoldNotFound is returned when releasing a hold that does not exist.
+var ErrHoldNotFound = errors.New("kimberlite: legal hold not found")
+
+// ErrNoLegalHoldStore is returned by the legal hold methods of a client
+// without a LegalHoldStore.
+var ErrNoLegalHoldStore = errors.New("kimberlite: no legal hold store configured")
+
+// HoldTarget is what a legal hold preserves: a stream, or every event
+// of a data subject.
+type HoldTarget struct {
+	Stream  StreamID
+	Subject string
+}
+
+// StreamHold targets stream id.
+func StreamHold(id StreamID) HoldTarget { return HoldTarget{Stream: id} }
+
+// SubjectHold targets the data subject.
+func SubjectHold(subject string) HoldTarget { return HoldTarget{Subject: subject} }
+
+func (t HoldTarget) String() string {
+	if t.Subject != "" {
+		return fmt.Sprintf("subject %q", t.Subject)
+	}
+	return fmt.Sprintf("stream %d", t.Stream)
+}
+
+// LegalHold is a hold placed on a target for a case.
+type LegalHold struct {
+	Target HoldTarget
+	CaseID string
+	// PlacedBy is the actor of the AuditContext that placed the hold.
+	PlacedBy string
+	PlacedAt time.Time
+}
+
+// LegalHoldStore records legal holds. A target may be held by several
+// cases at once; it is released when the last is.
+type LegalHoldStore interface {
+	// PlaceHold records hold. Placing a hold that exists is a no-op.
+	PlaceHold(ctx context.Context, hold LegalHold) error
+	// ReleaseHold removes the hold of caseID on target, returning an
+	// error wrapping ErrHoldNotFound if there is none.
+	ReleaseHold(ctx context.Context, target HoldTarget, caseID string) error
+	// Holds returns the holds on target.
+	Holds(ctx context.Context, target HoldTarget) ([]LegalHold, error)
+}
+
+// NoLegalHolds is the LegalHoldStore of deployments that do not use
+// legal holds. It holds nothing, and refuses to place holds.
+var NoLegalHolds LegalHoldStore = noLegalHolds{}
+
+type noLegalHolds struct{}
+
+func (noLegalHolds) PlaceHold(context.Context, LegalHold) error {
+	return fmt.Errorf("%w: legal holds are not in use", ErrNoLegalHoldStore)
+}
+
+func (noLegalHolds) ReleaseHold(_ context.Context, target HoldTarget, caseID string) error {
+	return fmt.Errorf("%w: case %q on %s", ErrHoldNotFound, caseID, target)
+}
+
+func (noLegalHolds) Holds(context.Context, HoldTarget) ([]LegalHold, error) {
+	return nil, nil
+}
+
+// MemoryLegalHolds is an in-memory LegalHoldStore, for tests.
+type MemoryLegalHolds struct {
+	mu    sync.Mutex
+	holds map[HoldTarget][]LegalHold
+}
+
+var _ LegalHoldStore = (*MemoryLegalHolds)(nil)
+
+// NewMemoryLegalHolds returns an empty MemoryLegalHolds.
+func NewMemoryLegalHolds() *MemoryLegalHolds {
+	return &MemoryLegalHolds{holds: make(map[HoldTarget][]LegalHold)}
+}
+
+// PlaceHold implements LegalHoldStore.
+func (m *MemoryLegalHolds) PlaceHold(_ context.Context, hold LegalHold) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.holds[hold.Target] {
+		if h.CaseID == hold.CaseID {
+			return nil
+		}
+	}
+	m.holds[hold.Target] = append(m.holds[hold.Target], hold)
+	return nil
+}
+
+// ReleaseHold implements LegalHoldStore.
+func (m *MemoryLegalHolds) ReleaseHold(_ context.Context, target HoldTarget, caseID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	holds := m.holds[target]
+	for i, h := range holds {
+		if h.CaseID == caseID {
+			if holds = append(holds[:i], holds[i+1:]...); len(holds) == 0 {
+				delete(m.holds, target)
+			} else {
+				m.holds[target] = holds
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: case %q on %s", ErrHoldNotFound, caseID, target)
+}
+
+// Holds implements LegalHoldStore.
+func (m *MemoryLegalHolds) Holds(_ context.Context, target HoldTarget) ([]LegalHold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]LegalHold(nil), m.holds[target]...), nil
+}
+
+// WithLegalHolds makes the client honor and manage the legal holds in
+// store.
+func WithLegalHolds(store LegalHoldStore) Option {
+	return func(c *Client) { c.holds = store }
+}
+
+// PlaceLegalHold holds target for caseID.
+func (c *Client) PlaceLegalHold(ctx context.Context, target HoldTarget, caseID string) error {
+	if err := c.checkWritable("legal hold change"); err != nil {
+		return err
+	}
+	if c.holds == nil {
+		return ErrNoLegalHoldStore
+	}
+	if caseID == "" {
+		return errors.New("kimberlite: empty legal hold case ID")
+	}
+	err := c.call(ctx, &Operation{Name: OpPlaceLegalHold}, func(ctx context.Context) error {
+		audit, _ := AuditFromContext(ctx)
+		hold := LegalHold{Target: target, CaseID: caseID, PlacedBy: audit.Actor, PlacedAt: time.Now().UTC()}
+		return c.holds.PlaceHold(ctx, hold)
+	})
+	if err != nil {
+		return fmt.Errorf("kimberlite: place legal hold on %s: %w", target, err)
+	}
+	return nil
+}
+
+// ReleaseLegalHold releases the hold of caseID on target.
+func (c *Client) ReleaseLegalHold(ctx context.Context, target HoldTarget, caseID string) error {
+	if err := c.checkWritable("legal hold change"); err != nil {
+		return err
+	}
+	if c.holds == nil {
+		return ErrNoLegalHoldStore
+	}
+	err := c.call(ctx, &Operation{Name: OpReleaseLegalHold}, func(ctx context.Context) error {
+		return c.holds.ReleaseHold(ctx, target, caseID)
+	})
+	if err != nil {
+		return fmt.Errorf("kimberlite: release legal hold on %s: %w", target, err)
+	}
+	return nil
+}
+
+// LegalHolds returns the holds on target.
+func (c *Client) LegalHolds(ctx context.Context, target HoldTarget) ([]LegalHold, error) {
+	if c.holds == nil {
+		return nil, ErrNoLegalHoldStore
+	}
+	return c.holds.Holds(ctx, target)
+}
+
+// LoadLegalHolds sets info.LegalHolds to the holds on its stream.
+func (c *Client) LoadLegalHolds(ctx context.Context, info *StreamInfo) error {
+	holds, err := c.LegalHolds(ctx, StreamHold(info.ID))
+	if err != nil {
+		return err
+	}
+	info.LegalHolds = holds
+	return nil
+}
+
+// CheckDisposal returns an error wrapping ErrLegalHold if target is
+// under legal hold. Retention jobs call it before trimming a stream.
+// A client without a LegalHoldStore cannot tell, so it returns
+// ErrNoLegalHoldStore.
+func (c *Client) CheckDisposal(ctx context.Context, target HoldTarget) error {
+	if c.holds == nil {
+		return fmt.Errorf("%w: cannot check legal holds on %s", ErrNoLegalHoldStore, target)
+	}
+	holds, err := c.holds.Holds(ctx, target)
+	if err != nil {
+		return fmt.Errorf("kimberlite: check legal holds on %s: %w", target, err)
+	}
+	if len(holds) == 0 {
+		return nil
+	}
+	cases := make([]string, len(holds))
+	for i, h := range holds {
+		cases[i] = h.CaseID
+	}
+	return fmt.Errorf("%w: %s held by %s", ErrLegalHold, target, strings.Join(cases, ", "))
+}
@@ -0,0 +1,62 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLegalHold(t *testing.T) {
+	ctx := WithAudit(context.Background(), AuditContext{Actor: "counsel", Reason: "litigation"})
+	holds := NewMemoryLegalHolds()
+	c := &Client{holds: holds, encryption: NewEncryption(nil, WithSubjectKeys(NewMemorySubjectKeys())), backend: &erasureBackend{}}
+
+	for _, caseID := range []string{"CASE-1", "CASE-2", "CASE-1"} {
+		if err := c.PlaceLegalHold(ctx, SubjectHold("p-1"), caseID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.PlaceLegalHold(ctx, StreamHold(7), "CASE-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Erase(ctx, "p-1"); !errors.Is(err, ErrLegalHold) {
+		t.Fatalf("expected ErrLegalHold, got %v", err)
+	}
+	if err := c.Erase(ctx, "p-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	info := &StreamInfo{ID: 7}
+	if err := c.LoadLegalHolds(ctx, info); err != nil || !info.UnderLegalHold() || info.LegalHolds[0].PlacedBy != "counsel" {
+		t.Fatalf("stream holds: %+v, %v", info.LegalHolds, err)
+	}
+	if err := c.CheckDisposal(ctx, StreamHold(8)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, caseID := range []string{"CASE-1", "CASE-2"} {
+		if err := c.ReleaseLegalHold(ctx, SubjectHold("p-1"), caseID); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ReleaseLegalHold(ctx, SubjectHold("p-1"), caseID); !errors.Is(err, ErrHoldNotFound) {
+			t.Fatalf("expected ErrHoldNotFound, got %v", err)
+		}
+	}
+	if err := c.Erase(ctx, "p-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Client{}).PlaceLegalHold(ctx, StreamHold(7), "CASE-1"); !errors.Is(err, ErrNoLegalHoldStore) {
+		t.Fatalf("expected ErrNoLegalHoldStore, got %v", err)
+	}
+	if err := (&Client{}).CheckDisposal(ctx, StreamHold(7)); !errors.Is(err, ErrNoLegalHoldStore) {
+		t.Fatalf("disposal without a hold store: %v", err)
+	}
+
+	none := &Client{holds: NoLegalHolds}
+	if err := none.CheckDisposal(ctx, StreamHold(7)); err != nil {
+		t.Fatal(err)
+	}
+	if err := none.PlaceLegalHold(ctx, StreamHold(7), "CASE-1"); !errors.Is(err, ErrNoLegalHoldStore) {
+		t.Fatalf("expected ErrNoLegalHoldStore, got %v", err)
+	}
+}
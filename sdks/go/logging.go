@@ -0,0 +1,146 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// DefaultSlowThreshold is the duration above which operations are
+// logged as slow, unless changed with WithSlowThreshold.
+const DefaultSlowThreshold = time.Second
+
+// WithLogger sets the logger for client diagnostics:
+//
+//   - Debug: connect, disconnect, and every completed operation with
+//     its duration, stream, row and event counts.
+//   - Warn: failed connects, failed operations, operations slower
+//     than the slow threshold, and PII found by a PIIGuard.
+//
+// Logs are redacted by default: the auth token and event payloads are
+// never logged, and literals in SQL text are replaced with ?. Use
+// WithUnredactedLogs to log statements verbatim.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithSlowThreshold sets the duration above which an operation is
+// logged at Warn level and, for queries, reported to the OnSlowQuery
+// hook. Zero disables both.
+func WithSlowThreshold(d time.Duration) Option {
+	return func(c *Client) {
+		c.slowThreshold = d
+	}
+}
+
+// WithUnredactedLogs logs SQL statements verbatim, literals included.
+// Tokens and event payloads are still never logged.
+func WithUnredactedLogs() Option {
+	return func(c *Client) {
+		c.logUnredacted = true
+	}
+}
+
+// LogValue implements slog.LogValuer, so a Client passed to a logger
+// is rendered as its address and tenant, never its token.
+func (c *Client) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("addr", c.addr),
+		slog.Uint64("tenant", uint64(c.tenant)),
+	)
+}
+
+func (c *Client) logConnection(event string, err error) {
+	if c.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("event", event),
+		slog.String("addr", c.addr),
+		slog.Uint64("tenant", uint64(c.tenant)),
+	}
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()), slog.String("code", ErrorCode(err)))
+	}
+	c.logger.LogAttrs(context.Background(), level, "kimberlite: connection "+event, attrs...)
+}
+
+func (c *Client) logOperation(ctx context.Context, op *Operation, elapsed time.Duration, err error) {
+	slow := c.slowThreshold > 0 && elapsed >= c.slowThreshold
+	level := slog.LevelDebug
+	msg := "kimberlite: operation"
+	switch {
+	case err != nil:
+		level, msg = slog.LevelWarn, "kimberlite: operation failed"
+	case slow:
+		level, msg = slog.LevelWarn, "kimberlite: slow operation"
+	}
+	if !c.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("op", op.Name),
+		slog.Uint64("tenant", uint64(op.Tenant)),
+		slog.Duration("duration", elapsed),
+	}
+	if op.Statement != "" {
+		stmt := op.Statement
+		if !c.logUnredacted {
+			stmt = redactSQL(stmt)
+		}
+		attrs = append(attrs, slog.String("statement", stmt))
+	}
+	switch op.Name {
+	case OpQuery, OpExec:
+		attrs = append(attrs, slog.Int64("rows", op.Rows))
+	case OpCreateStream, OpAppend, OpReadEvents, OpSubscribe, OpStreamLength:
+		attrs = append(attrs, slog.Uint64("stream", uint64(op.StreamID)))
+	}
+	if op.Table != "" {
+		attrs = append(attrs, slog.String("table", op.Table))
+	}
+	if op.Events > 0 {
+		attrs = append(attrs, slog.Int("events", op.Events))
+	}
+	if op.BytesOut > 0 {
+		attrs = append(attrs, slog.Int64("bytes_out", op.BytesOut))
+	}
+	if op.BytesIn > 0 {
+		attrs = append(attrs, slog.Int64("bytes_in", op.BytesIn))
+	}
+	if op.CacheHit {
+		attrs = append(attrs, slog.Bool("cache_hit", true))
+	}
+	if slow {
+		attrs = append(attrs, slog.Duration("threshold", c.slowThreshold))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()), slog.String("code", ErrorCode(err)))
+	}
+	c.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (c *Client) logPII(ctx context.Context, flagged []*PIIError, err error) {
+	if c.logger == nil {
+		return
+	}
+	var blocked *PIIError
+	if errors.As(err, &blocked) {
+		flagged = append(flagged, blocked)
+	}
+	for _, perr := range flagged {
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "kimberlite: PII detected",
+			slog.Uint64("stream", uint64(perr.StreamID)),
+			slog.Int("event", perr.Event),
+			slog.String("kinds", strings.Join(perr.kinds(), ",")),
+			slog.Bool("blocked", perr == blocked),
+		)
+	}
+}
@@ -0,0 +1,176 @@
+package kimberlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Masking policies make the server mask sensitive columns on read. A
+// policy pairs a strategy with the roles exempt from it; attached to a
+// column, it rewrites the column's values in every query result for
+// callers whose role is not exempt. The decision is made server-side
+// from the role of the connection's credentials, so a client cannot
+// opt out, and the read is recorded in the audit trail with the
+// caller's AuditContext:
+//
+//	err := client.CreateMaskingPolicy(ctx, "ssn_mask",
+//	    kimberlite.MaskingStrategy{Kind: kimberlite.MaskRedactSSN}, "clinician", "admin")
+//	err = client.AttachMaskingPolicy(ctx, "patients", "ssn", "ssn_mask")
+//
+//	ctx = kimberlite.WithAudit(ctx, kimberlite.AuditContext{Actor: userID, Reason: "billing"})
+//	rows, err := client.QueryContext(ctx, "SELECT name, ssn FROM patients")
+//	// A billing role reads ssn as ***-**-6789.
+
+// MaskKind names a masking strategy.
+type MaskKind string
+
+// Masking strategies.
+const (
+	// MaskRedactSSN keeps the last four digits of an SSN (***-**-6789).
+	MaskRedactSSN MaskKind = "RedactSsn"
+	// MaskRedactPhone keeps the last four digits of a phone number.
+	MaskRedactPhone MaskKind = "RedactPhone"
+	// MaskRedactEmail keeps an email's first letter and domain.
+	MaskRedactEmail MaskKind = "RedactEmail"
+	// MaskRedactCreditCard keeps the last four digits of a card number.
+	MaskRedactCreditCard MaskKind = "RedactCreditCard"
+	// MaskRedactCustom replaces values with MaskingStrategy.Replacement.
+	MaskRedactCustom MaskKind = "RedactCustom"
+	// MaskHash replaces values with their SHA-256 hash.
+	MaskHash MaskKind = "Hash"
+	// MaskTokenize replaces values with stable tokens, so masked values
+	// still join and group.
+	MaskTokenize MaskKind = "Tokenize"
+	// MaskTruncate keeps the first MaskingStrategy.MaxChars characters.
+	MaskTruncate MaskKind = "Truncate"
+	// MaskNull replaces values with NULL.
+	MaskNull MaskKind = "Null"
+)
+
+// MaskingStrategy describes how a policy masks values.
+type MaskingStrategy struct {
+	Kind MaskKind `json:"kind"`
+	// Replacement is the literal replacement of MaskRedactCustom.
+	Replacement string `json:"replacement,omitempty"`
+	// MaxChars is the kept length of MaskTruncate.
+	MaxChars int `json:"max_chars,omitempty"`
+}
+
+func (s MaskingStrategy) validate() error {
+	switch s.Kind {
+	case MaskRedactSSN, MaskRedactPhone, MaskRedactEmail, MaskRedactCreditCard, MaskHash, MaskTokenize, MaskNull:
+	case MaskRedactCustom:
+		if s.Replacement == "" {
+			return fmt.Errorf("kimberlite: %s masking needs a replacement", s.Kind)
+		}
+	case MaskTruncate:
+		if s.MaxChars <= 0 {
+			return fmt.Errorf("kimberlite: %s masking needs a positive max chars", s.Kind)
+		}
+	default:
+		return fmt.Errorf("kimberlite: unknown masking strategy %q", s.Kind)
+	}
+	return nil
+}
+
+// MaskingPolicy is a policy in the tenant's masking catalogue.
+type MaskingPolicy struct {
+	Name     string          `json:"name"`
+	Strategy MaskingStrategy `json:"strategy"`
+	// ExemptRoles read the columns in clear text.
+	ExemptRoles []string `json:"exempt_roles"`
+	// DefaultMasked reports whether roles not named by the policy are
+	// masked.
+	DefaultMasked   bool `json:"default_masked"`
+	AttachmentCount int  `json:"attachment_count"`
+}
+
+// MaskingAttachment binds a policy to a column.
+type MaskingAttachment struct {
+	Table  string `json:"table_name"`
+	Column string `json:"column_name"`
+	Policy string `json:"policy_name"`
+}
+
+// MaskingCatalog lists a tenant's masking policies and, if requested,
+// their attachments.
+type MaskingCatalog struct {
+	Policies    []MaskingPolicy     `json:"policies"`
+	Attachments []MaskingAttachment `json:"attachments"`
+}
+
+// CreateMaskingPolicy creates the masking policy name. Callers with one
+// of exemptRoles read attached columns in clear text; everyone else
+// reads them masked by strategy.
+func (c *Client) CreateMaskingPolicy(ctx context.Context, name string, strategy MaskingStrategy, exemptRoles ...string) error {
+	if err := strategy.validate(); err != nil {
+		return err
+	}
+	if exemptRoles == nil {
+		exemptRoles = []string{}
+	}
+	rawStrategy, err := json.Marshal(strategy)
+	if err != nil {
+		return err
+	}
+	rawRoles, err := json.Marshal(exemptRoles)
+	if err != nil {
+		return err
+	}
+	return c.maskingWrite(ctx, &Operation{Name: OpCreateMaskingPolicy}, func() error {
+		return ffiMaskingPolicyCreate(c.kmbHandle, name, rawStrategy, rawRoles)
+	})
+}
+
+// DropMaskingPolicy drops the masking policy name.
+func (c *Client) DropMaskingPolicy(ctx context.Context, name string) error {
+	return c.maskingWrite(ctx, &Operation{Name: OpDropMaskingPolicy}, func() error {
+		return ffiMaskingPolicyDrop(c.kmbHandle, name)
+	})
+}
+
+// AttachMaskingPolicy masks table.column with the policy named policy.
+func (c *Client) AttachMaskingPolicy(ctx context.Context, table, column, policy string) error {
+	return c.maskingWrite(ctx, &Operation{Name: OpAttachMaskingPolicy, Table: table}, func() error {
+		return ffiMaskingPolicyAttach(c.kmbHandle, table, column, policy)
+	})
+}
+
+// DetachMaskingPolicy removes the masking policy from table.column, so
+// every caller reads it in clear text.
+func (c *Client) DetachMaskingPolicy(ctx context.Context, table, column string) error {
+	return c.maskingWrite(ctx, &Operation{Name: OpDetachMaskingPolicy, Table: table}, func() error {
+		return ffiMaskingPolicyDetach(c.kmbHandle, table, column)
+	})
+}
+
+// MaskingPolicies returns the tenant's masking catalogue, with the
+// column attachments if includeAttachments is set.
+func (c *Client) MaskingPolicies(ctx context.Context, includeAttachments bool) (*MaskingCatalog, error) {
+	var raw []byte
+	err := c.call(ctx, &Operation{Name: OpMaskingPolicies}, func(context.Context) (err error) {
+		raw, err = ffiMaskingPolicyList(c.kmbHandle, includeAttachments)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeMaskingCatalog(raw)
+}
+
+// maskingWrite runs fn, a call changing the masking catalogue, as op.
+func (c *Client) maskingWrite(ctx context.Context, op *Operation, fn func() error) error {
+	if err := c.checkWritable("masking policy change"); err != nil {
+		return err
+	}
+	return c.call(ctx, op, func(context.Context) error { return fn() })
+}
+
+func decodeMaskingCatalog(raw []byte) (*MaskingCatalog, error) {
+	var cat MaskingCatalog
+	if err := json.Unmarshal(raw, &cat); err != nil {
+		return nil, fmt.Errorf("kimberlite: decode masking policies: %w", err)
+	}
+	return &cat, nil
+}
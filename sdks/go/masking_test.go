@@ -0,0 +1,32 @@
+package kimberlite
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMaskingStrategy(t *testing.T) {
+	b, err := json.Marshal(MaskingStrategy{Kind: MaskTruncate, MaxChars: 4})
+	if err != nil || string(b) != `{"kind":"Truncate","max_chars":4}` {
+		t.Fatalf("marshal: %s, %v", b, err)
+	}
+	for _, s := range []MaskingStrategy{{Kind: MaskTruncate}, {Kind: MaskRedactCustom}, {Kind: "Shuffle"}} {
+		if s.validate() == nil {
+			t.Errorf("%+v: expected error", s)
+		}
+	}
+
+	cat, err := decodeMaskingCatalog([]byte(`{
+		"policies":[{"name":"ssn_mask","strategy":{"kind":"RedactSsn"},"exempt_roles":["clinician"],"default_masked":true,"attachment_count":1}],
+		"attachments":[{"table_name":"patients","column_name":"ssn","policy_name":"ssn_mask"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := cat.Policies[0]
+	if p.Strategy.Kind != MaskRedactSSN || p.ExemptRoles[0] != "clinician" || !p.DefaultMasked || p.AttachmentCount != 1 {
+		t.Fatalf("policy = %+v", p)
+	}
+	if a := cat.Attachments[0]; a.Table != "patients" || a.Column != "ssn" || a.Policy != "ssn_mask" {
+		t.Fatalf("attachment = %+v", a)
+	}
+}
@@ -0,0 +1,318 @@
+// Package metering records each tenant's usage of Kimberlite, for
+// billing.
+//
+// The server does not account usage by tenant, so clients do: a Meter
+// intercepts a client's operations, counts them by tenant in fixed time
+// windows, and flushes the counts to a usage stream, where the counts
+// of every process using the Meter's stream add up. Read sums them for
+// a period:
+//
+//	meter := metering.New(billing, usage)
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(tenant),
+//	    kimberlite.WithInterceptors(meter.Interceptor()))
+//	go meter.Run(ctx)
+//
+//	// in the billing job:
+//	windows, err := metering.Read(ctx, billing, usage, monthStart, monthEnd)
+//
+// where billing is a client of the tenant that keeps the usage stream,
+// such as the control plane's, and usage is the stream.
+//
+// Counts are of the operations clients complete: failed operations
+// and reads served from the read cache are not counted, nor are the
+// reads and appends of the usage stream by Flush and Read. The server
+// does not report storage either; BytesAppended, the event bytes
+// appended to the log, which is append-only, measures how storage
+// grows, not the size of tables.
+package metering
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// TypeUsage is the envelope type of the records of a usage stream.
+const TypeUsage = "kimberlite.usage"
+
+// Usage is a tenant's usage in the window from Start to End.
+type Usage struct {
+	Tenant kimberlite.TenantID `json:"tenant"`
+	Start  time.Time           `json:"start"`
+	End    time.Time           `json:"end"`
+
+	// EventsAppended and BytesAppended count the events appended and
+	// their payload bytes.
+	EventsAppended int64 `json:"events_appended"`
+	BytesAppended  int64 `json:"bytes_appended"`
+	// Queries counts Query calls and Statements Exec calls.
+	Queries    int64 `json:"queries"`
+	Statements int64 `json:"statements"`
+	// RowsRead counts the rows queries returned, and RowsWritten those
+	// statements affected.
+	RowsRead    int64 `json:"rows_read"`
+	RowsWritten int64 `json:"rows_written"`
+	// EventsRead counts the events read.
+	EventsRead int64 `json:"events_read"`
+	// EgressBytes is the size of query results, as QueryStats.Bytes
+	// measures it, and of the payloads of events read.
+	EgressBytes int64 `json:"egress_bytes"`
+}
+
+// Add adds the counts of v to u.
+func (u *Usage) Add(v Usage) {
+	u.EventsAppended += v.EventsAppended
+	u.BytesAppended += v.BytesAppended
+	u.Queries += v.Queries
+	u.Statements += v.Statements
+	u.RowsRead += v.RowsRead
+	u.RowsWritten += v.RowsWritten
+	u.EventsRead += v.EventsRead
+	u.EgressBytes += v.EgressBytes
+}
+
+// Meter counts the usage of the clients it intercepts. It is safe for
+// concurrent use, and one Meter may serve clients of many tenants.
+type Meter struct {
+	log      connect.EventAppender
+	stream   kimberlite.StreamID
+	window   time.Duration
+	interval time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	counts map[windowKey]*Usage
+
+	// flushMu serialises flushes. unsent holds the records of a failed
+	// flush, sent again, with the same idempotency key, before newer
+	// counts.
+	flushMu  sync.Mutex
+	unsent   [][]byte
+	unsentID string
+}
+
+// windowKey identifies a tenant's window by its start in Unix
+// nanoseconds.
+type windowKey struct {
+	tenant kimberlite.TenantID
+	start  int64
+}
+
+// Option configures a Meter.
+type Option func(*Meter)
+
+// WithWindow sets the length of the windows usage is counted in, the
+// finest granularity Read can report. The default is one hour.
+func WithWindow(d time.Duration) Option {
+	return func(m *Meter) { m.window = d }
+}
+
+// WithFlushInterval sets how often Run flushes counts to the usage
+// stream. The default is one minute.
+func WithFlushInterval(d time.Duration) Option {
+	return func(m *Meter) { m.interval = d }
+}
+
+// New returns a Meter flushing counts to stream, which should hold
+// nothing else, with log.
+func New(log connect.EventAppender, stream kimberlite.StreamID, opts ...Option) *Meter {
+	m := &Meter{
+		log:      log,
+		stream:   stream,
+		window:   time.Hour,
+		interval: time.Minute,
+		now:      time.Now,
+		counts:   make(map[windowKey]*Usage),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Interceptor returns the interceptor counting a client's operations.
+func (m *Meter) Interceptor() kimberlite.Interceptor {
+	return func(ctx context.Context, op *kimberlite.Operation, req any, next kimberlite.Invoker) error {
+		err := next(ctx, op, req)
+		if err == nil && ctx.Value(ownCall{}) == nil {
+			m.count(op)
+		}
+		return err
+	}
+}
+
+// ownCall marks the contexts of the package's own calls, which meters
+// do not count.
+type ownCall struct{}
+
+// count adds a completed operation to the counts of its window.
+func (m *Meter) count(op *kimberlite.Operation) {
+	var u Usage
+	switch op.Name {
+	case kimberlite.OpAppend:
+		u.EventsAppended, u.BytesAppended = int64(op.Events), op.BytesOut
+	case kimberlite.OpReadEvents:
+		if op.CacheHit {
+			return
+		}
+		u.EventsRead, u.EgressBytes = int64(op.Events), op.BytesIn
+	case kimberlite.OpQuery:
+		u.Queries, u.RowsRead, u.EgressBytes = 1, op.Rows, op.BytesIn
+	case kimberlite.OpExec:
+		u.Statements, u.RowsWritten = 1, op.Rows
+	default:
+		return
+	}
+	start := m.now().UTC().Truncate(m.window)
+	key := windowKey{tenant: op.Tenant, start: start.UnixNano()}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.counts[key]
+	if !ok {
+		w = &Usage{Tenant: op.Tenant, Start: start, End: start.Add(m.window)}
+		m.counts[key] = w
+	}
+	w.Add(u)
+}
+
+// Usage returns the counts not yet flushed, by tenant and window.
+func (m *Meter) Usage() []Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedUsage(m.counts)
+}
+
+// Flush appends the counts since the last flush to the usage stream,
+// one record per tenant and window, in one append. If the append
+// fails, Flush returns the error and the next flush sends the same
+// records again with the same idempotency key, so the server can drop
+// a duplicate of an append that succeeded unacknowledged.
+func (m *Meter) Flush(ctx context.Context) error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+	for {
+		if m.unsent == nil {
+			records, err := m.take()
+			if err != nil || records == nil {
+				return err
+			}
+			m.unsent, m.unsentID = records, kimberlite.NewEventID()
+		}
+		audit, _ := kimberlite.AuditFromContext(ctx)
+		audit.IdempotencyKey = m.unsentID
+		own := context.WithValue(kimberlite.WithAudit(ctx, audit), ownCall{}, true)
+		if _, err := m.log.AppendContext(own, m.stream, m.unsent...); err != nil {
+			return fmt.Errorf("kimberlite: flush usage: %w", err)
+		}
+		m.unsent = nil
+	}
+}
+
+// take removes the counts since the last flush and returns them as
+// records, or nil if there are none.
+func (m *Meter) take() ([][]byte, error) {
+	m.mu.Lock()
+	counts := m.counts
+	m.counts = make(map[windowKey]*Usage)
+	m.mu.Unlock()
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	records := make([][]byte, 0, len(counts))
+	for _, u := range sortedUsage(counts) {
+		data, err := json.Marshal(u)
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: encode usage: %w", err)
+		}
+		record, err := kimberlite.Envelope{Type: TypeUsage, Data: data}.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Run flushes the counts every flush interval until ctx is done, then
+// flushes once more, and returns ctx's error, or the error of the last
+// flush if it failed. Errors of earlier flushes are retried by the
+// next.
+func (m *Meter) Run(ctx context.Context) error {
+	tick := time.NewTicker(m.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.interval)
+			err := m.Flush(final)
+			cancel()
+			if err != nil {
+				return err
+			}
+			return ctx.Err()
+		case <-tick.C:
+			_ = m.Flush(ctx)
+		}
+	}
+}
+
+// Read returns the usage recorded in stream for the windows starting
+// from from until to, summed over the processes that recorded it, by
+// tenant and then window.
+func Read(ctx context.Context, source connect.EventSource, stream kimberlite.StreamID, from, to time.Time) ([]Usage, error) {
+	ctx = context.WithValue(ctx, ownCall{}, true)
+	sums := make(map[windowKey]*Usage)
+	var offset kimberlite.Offset
+	for {
+		events, err := source.ReadEventsContext(ctx, stream, offset, 1<<20)
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: read usage: %w", err)
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, ev := range events {
+			offset = ev.Offset + 1
+			env, err := ev.Envelope()
+			if err != nil || env.Type != TypeUsage {
+				continue
+			}
+			var u Usage
+			if err := json.Unmarshal(env.Data, &u); err != nil {
+				return nil, fmt.Errorf("kimberlite: decode usage at offset %d: %w", ev.Offset, err)
+			}
+			if u.Start.Before(from) || !u.Start.Before(to) {
+				continue
+			}
+			key := windowKey{tenant: u.Tenant, start: u.Start.UnixNano()}
+			if sum, ok := sums[key]; ok {
+				sum.Add(u)
+			} else {
+				sums[key] = &u
+			}
+		}
+	}
+	return sortedUsage(sums), nil
+}
+
+// sortedUsage returns the usage in counts by tenant and then window.
+func sortedUsage(counts map[windowKey]*Usage) []Usage {
+	out := make([]Usage, 0, len(counts))
+	for _, u := range counts {
+		out = append(out, *u)
+	}
+	slices.SortFunc(out, func(a, b Usage) int {
+		if c := cmp.Compare(a.Tenant, b.Tenant); c != 0 {
+			return c
+		}
+		return a.Start.Compare(b.Start)
+	})
+	return out
+}
@@ -0,0 +1,110 @@
+package metering
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/kimberlitetest"
+)
+
+// flakyLog fails the appends it is told to, recording the idempotency
+// key of each.
+type flakyLog struct {
+	*kimberlite.Client
+	fail int
+	keys []string
+}
+
+func (l *flakyLog) AppendContext(ctx context.Context, id kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error) {
+	audit, _ := kimberlite.AuditFromContext(ctx)
+	l.keys = append(l.keys, audit.IdempotencyKey)
+	if l.fail > 0 {
+		l.fail--
+		return 0, kimberlite.ErrConnectionFailed
+	}
+	return l.Client.AppendContext(ctx, id, events...)
+}
+
+func TestMeter(t *testing.T) {
+	ctx := context.Background()
+	billing := kimberlitetest.NewMockClient()
+	usage, err := billing.CreateStream("usage", kimberlite.DataClassPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := &flakyLog{Client: billing}
+	meter := New(log, usage.ID)
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	meter.now = func() time.Time { return now }
+
+	client := kimberlitetest.NewMockClient(kimberlite.WithTenant(7), kimberlite.WithInterceptors(meter.Interceptor()))
+	admissions, _ := client.CreateStream("admissions", kimberlite.DataClassPublic)
+	if _, err := client.AppendContext(ctx, admissions.ID, []byte("ab"), []byte("cde")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ReadEventsContext(ctx, admissions.ID, 0, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	client.Exec("CREATE TABLE patients (id BIGINT PRIMARY KEY, name TEXT)")
+	client.Exec("INSERT INTO patients (id, name) VALUES (1, 'Ada'), (2, 'Grace')")
+	if _, err := client.Query("SELECT name FROM patients"); err != nil {
+		t.Fatal(err)
+	}
+	client.Query("SELECT * FROM missing") // failed, not counted
+
+	got := meter.Usage()
+	if len(got) != 1 {
+		t.Fatalf("usage %+v", got)
+	}
+	hour := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	want := Usage{
+		Tenant: 7, Start: hour, End: hour.Add(time.Hour),
+		EventsAppended: 2, BytesAppended: 5, EventsRead: 2,
+		Queries: 1, Statements: 2, RowsRead: 2, RowsWritten: 2,
+		EgressBytes: 5 + int64(len("Ada")+len("Grace")),
+	}
+	if got[0] != want {
+		t.Fatalf("usage %+v\nwant  %+v", got[0], want)
+	}
+
+	// A failed flush is retried with the same key.
+	log.fail = 1
+	if err := meter.Flush(ctx); !errors.Is(err, kimberlite.ErrConnectionFailed) {
+		t.Fatalf("Flush = %v", err)
+	}
+	now = now.Add(time.Hour)
+	client.Query("SELECT name FROM patients")
+	if err := meter.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.keys) != 3 || log.keys[0] != log.keys[1] || log.keys[1] == log.keys[2] {
+		t.Fatalf("idempotency keys %q", log.keys)
+	}
+	if got := meter.Usage(); len(got) != 0 {
+		t.Fatalf("usage after flush %+v", got)
+	}
+
+	// Another process's counts for the same window add up.
+	other := New(billing, usage.ID)
+	other.now = meter.now
+	other.count(&kimberlite.Operation{Name: kimberlite.OpQuery, Tenant: 7, Rows: 1, BytesIn: 8})
+	if err := other.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	windows, err := Read(ctx, billing, usage.ID, hour, hour.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windows) != 2 || windows[0] != want {
+		t.Fatalf("windows %+v", windows)
+	}
+	if w := windows[1]; !w.Start.Equal(hour.Add(time.Hour)) || w.Queries != 2 || w.RowsRead != 3 || w.EgressBytes != 16 {
+		t.Fatalf("second window %+v", w)
+	}
+	if windows, _ := Read(ctx, billing, usage.ID, hour.Add(time.Hour), hour.Add(2*time.Hour)); len(windows) != 1 {
+		t.Fatalf("windows of the second hour %+v", windows)
+	}
+}
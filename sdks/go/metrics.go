@@ -0,0 +1,263 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connection lifecycle events reported to MetricsRecorder.ConnectionEvent.
+const (
+	ConnEventConnect    = "connect"
+	ConnEventDisconnect = "disconnect"
+	ConnEventReconnect  = "reconnect"
+)
+
+// MetricsRecorder receives client metrics. Implementations must be
+// safe for concurrent use; one recorder may serve many clients.
+// PrometheusMetrics is a built-in implementation, and otelkimberlite
+// provides one backed by an OpenTelemetry MeterProvider.
+type MetricsRecorder interface {
+	// OperationStarted is called when an operation begins.
+	OperationStarted(op *Operation)
+	// OperationDone is called when it completes, with op's result
+	// fields filled in.
+	OperationDone(op *Operation, elapsed time.Duration, err error)
+	// ConnectionEvent is called on connection lifecycle events.
+	ConnectionEvent(tenant TenantID, event string)
+}
+
+// WithMetrics sets the recorder for client metrics.
+func WithMetrics(m MetricsRecorder) Option {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// ErrorCode classifies err for metrics labels: the server error code
+// of a *KimberliteError, a short name for the SDK's sentinel errors,
+// "canceled"/"deadline_exceeded" for context errors, "" for nil and
+// "unknown" otherwise.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var ke *KimberliteError
+	if errors.As(err, &ke) && ke.Code != "" {
+		return ke.Code
+	}
+	for _, s := range []struct {
+		err  error
+		code string
+	}{
+		{ErrNotConnected, "not_connected"},
+		{ErrConnectionFailed, "connection_failed"},
+		{ErrTimeout, "timeout"},
+		{ErrOffsetMismatch, "offset_mismatch"},
+		{ErrAuthFailed, "auth_failed"},
+		{ErrPermissionDenied, "permission_denied"},
+		{ErrStreamNotFound, "stream_not_found"},
+		{ErrQueryFailed, "query_failed"},
+		{ErrSchemaViolation, "schema_violation"},
+		{ErrPIIDetected, "pii_detected"},
+		{ErrDataClassPolicy, "data_class_policy"},
+		{ErrPurposeRequired, "purpose_required"},
+		{ErrStepUpFailed, "step_up_failed"},
+		{ErrBreakGlassExpired, "break_glass_expired"},
+		{ErrResidencyViolation, "residency_violation"},
+		{ErrAttestationFailed, "attestation_failed"},
+		{context.Canceled, "canceled"},
+		{context.DeadlineExceeded, "deadline_exceeded"},
+	} {
+		if errors.Is(err, s.err) {
+			return s.code
+		}
+	}
+	return "unknown"
+}
+
+// PrometheusMetrics is a MetricsRecorder that serves its metrics in
+// the Prometheus text exposition format:
+//
+//	metrics := kimberlite.NewPrometheusMetrics()
+//	http.Handle("/metrics", metrics)
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(1), kimberlite.WithMetrics(metrics))
+//
+// It exports:
+//
+//	kimberlite_client_operation_duration_seconds   histogram {operation}
+//	kimberlite_client_errors_total                 counter   {operation, code}
+//	kimberlite_client_sent_bytes_total             counter   {operation}
+//	kimberlite_client_received_bytes_total         counter   {operation}
+//	kimberlite_client_operations_in_flight         gauge
+//	kimberlite_client_connection_events_total      counter   {event}
+//
+// The in-flight gauge measures client utilisation; for connection pool
+// figures of a sqldriver pool, export sql.DB.Stats alongside it.
+type PrometheusMetrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	durations map[string]*histogram
+	errors    map[[2]string]uint64
+	sent      map[string]uint64
+	received  map[string]uint64
+	inFlight  int64
+	conn      map[string]uint64
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	sum    float64
+	count  uint64
+}
+
+// DefaultLatencyBuckets are the histogram bounds, in seconds, used by
+// NewPrometheusMetrics.
+var DefaultLatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NewPrometheusMetrics returns an empty recorder. Latency buckets
+// default to DefaultLatencyBuckets.
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &PrometheusMetrics{
+		buckets:   b,
+		durations: make(map[string]*histogram),
+		errors:    make(map[[2]string]uint64),
+		sent:      make(map[string]uint64),
+		received:  make(map[string]uint64),
+		conn:      make(map[string]uint64),
+	}
+}
+
+// OperationStarted implements MetricsRecorder.
+func (m *PrometheusMetrics) OperationStarted(*Operation) {
+	m.mu.Lock()
+	m.inFlight++
+	m.mu.Unlock()
+}
+
+// OperationDone implements MetricsRecorder.
+func (m *PrometheusMetrics) OperationDone(op *Operation, elapsed time.Duration, err error) {
+	secs := elapsed.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	h, ok := m.durations[op.Name]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[op.Name] = h
+	}
+	for i, ub := range m.buckets {
+		if secs <= ub {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += secs
+	h.count++
+	if err != nil {
+		m.errors[[2]string{op.Name, ErrorCode(err)}]++
+	}
+	if op.BytesOut > 0 {
+		m.sent[op.Name] += uint64(op.BytesOut)
+	}
+	if op.BytesIn > 0 {
+		m.received[op.Name] += uint64(op.BytesIn)
+	}
+}
+
+// ConnectionEvent implements MetricsRecorder.
+func (m *PrometheusMetrics) ConnectionEvent(_ TenantID, event string) {
+	m.mu.Lock()
+	m.conn[event]++
+	m.mu.Unlock()
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	m.mu.Lock()
+
+	b.WriteString("# HELP kimberlite_client_operation_duration_seconds Client operation latency.\n")
+	b.WriteString("# TYPE kimberlite_client_operation_duration_seconds histogram\n")
+	for _, op := range sortedKeys(m.durations) {
+		h := m.durations[op]
+		var cum uint64
+		for i, ub := range m.buckets {
+			cum += h.counts[i]
+			fmt.Fprintf(&b, "kimberlite_client_operation_duration_seconds_bucket{operation=%s,le=\"%s\"} %d\n",
+				promLabel(op), strconv.FormatFloat(ub, 'g', -1, 64), cum)
+		}
+		fmt.Fprintf(&b, "kimberlite_client_operation_duration_seconds_bucket{operation=%s,le=\"+Inf\"} %d\n", promLabel(op), h.count)
+		fmt.Fprintf(&b, "kimberlite_client_operation_duration_seconds_sum{operation=%s} %g\n", promLabel(op), h.sum)
+		fmt.Fprintf(&b, "kimberlite_client_operation_duration_seconds_count{operation=%s} %d\n", promLabel(op), h.count)
+	}
+
+	b.WriteString("# HELP kimberlite_client_errors_total Failed client operations by error code.\n")
+	b.WriteString("# TYPE kimberlite_client_errors_total counter\n")
+	errKeys := make([][2]string, 0, len(m.errors))
+	for k := range m.errors {
+		errKeys = append(errKeys, k)
+	}
+	sort.Slice(errKeys, func(i, j int) bool {
+		if errKeys[i][0] != errKeys[j][0] {
+			return errKeys[i][0] < errKeys[j][0]
+		}
+		return errKeys[i][1] < errKeys[j][1]
+	})
+	for _, k := range errKeys {
+		fmt.Fprintf(&b, "kimberlite_client_errors_total{operation=%s,code=%s} %d\n", promLabel(k[0]), promLabel(k[1]), m.errors[k])
+	}
+
+	writeCounter(&b, "kimberlite_client_sent_bytes_total", "Event payload bytes sent.", "operation", m.sent)
+	writeCounter(&b, "kimberlite_client_received_bytes_total", "Event payload bytes received.", "operation", m.received)
+
+	b.WriteString("# HELP kimberlite_client_operations_in_flight Client operations in progress.\n")
+	b.WriteString("# TYPE kimberlite_client_operations_in_flight gauge\n")
+	fmt.Fprintf(&b, "kimberlite_client_operations_in_flight %d\n", m.inFlight)
+
+	writeCounter(&b, "kimberlite_client_connection_events_total", "Connection lifecycle events.", "event", m.conn)
+
+	m.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func writeCounter(b *strings.Builder, name, help, label string, values map[string]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, k := range sortedKeys(values) {
+		fmt.Fprintf(b, "%s{%s=%s} %d\n", name, label, promLabel(k), values[k])
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// promLabel quotes a label value as the exposition format requires.
+func promLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
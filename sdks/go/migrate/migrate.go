@@ -0,0 +1,450 @@
+// Package migrate applies versioned schema migrations to a Kimberlite
+// database.
+//
+// Migrations are SQL files named "<version>_<name>.up.sql", with an
+// optional "<version>_<name>.down.sql" reverting it, typically embedded
+// in the application:
+//
+//	//go:embed migrations/*.sql
+//	var files embed.FS
+//
+//	sub, _ := fs.Sub(files, "migrations")
+//	migrations, err := migrate.Load(sub)
+//	m := migrate.New(client, migrations, migrate.WithAuditStream(client, schemaChanges))
+//	applied, err := m.Up(ctx)
+//
+// The versions applied are tracked in a table, schema_migrations by
+// default, with the checksum of each migration's up script and when it
+// was applied. With WithAuditStream every migration applied or
+// reverted is also recorded as an event, so the history of the schema
+// is kept in the log alongside the data it governs.
+//
+// Kimberlite has no multi-statement transactions: the statements of a
+// migration are executed one at a time, and a migration that fails
+// part way leaves its earlier statements applied. The migration is
+// then recorded as dirty and Up and Down refuse to run until the
+// schema has been repaired by hand and the migration resolved with
+// Force. Writing statements that can be rerun, such as CREATE TABLE IF
+// NOT EXISTS, keeps repairs simple.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/internal/sqlident"
+)
+
+// ErrDirty is returned when a migration failed part way and has not
+// been resolved with Force.
+var ErrDirty = errors.New("kimberlite: migration dirty")
+
+// ErrNoDown is returned by Down for a migration without a down script.
+var ErrNoDown = errors.New("kimberlite: migration has no down script")
+
+// Types of the events WithAuditStream records.
+const (
+	TypeApplied  = "kimberlite.migration.applied"
+	TypeReverted = "kimberlite.migration.reverted"
+	TypeForced   = "kimberlite.migration.forced"
+)
+
+// DB runs the statements of migrations. *kimberlite.Client implements
+// it.
+type DB interface {
+	ExecContext(ctx context.Context, sql string, args ...kimberlite.Value) (*kimberlite.ExecResult, error)
+	QueryContext(ctx context.Context, sql string, args ...kimberlite.Value) (*kimberlite.QueryResult, error)
+}
+
+// Log records migration events. *kimberlite.Client implements it.
+type Log interface {
+	AppendEnvelopes(ctx context.Context, streamID kimberlite.StreamID, envs ...kimberlite.Envelope) (kimberlite.Offset, error)
+}
+
+// Migration is a versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	// Up applies the migration and Down, if not empty, reverts it.
+	// Each holds statements separated by semicolons.
+	Up, Down string
+}
+
+// Checksum returns the SHA-256 of the migration's up script, in hex.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+var fileName = regexp.MustCompile(`^([0-9]+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the root directory of fsys, ordered by
+// version. Other files are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: migrations: %w", err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: migration %s: %w", e.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: migration %s: %w", e.Name(), err)
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("kimberlite: migration %d is named both %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("kimberlite: migration %d_%s has no up script", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	slices.SortFunc(out, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return out, nil
+}
+
+// Migrator applies and reverts migrations.
+type Migrator struct {
+	db         DB
+	migrations []Migration
+	table      string
+	log        Log
+	stream     kimberlite.StreamID
+	now        func() time.Time
+}
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// WithTable sets the table migrations are tracked in, the default
+// being schema_migrations. New panics unless name is a plain or
+// schema-qualified identifier.
+func WithTable(name string) Option {
+	return func(m *Migrator) { m.table = name }
+}
+
+// WithAuditStream records every migration applied, reverted or forced
+// as an event of stream, of type TypeApplied, TypeReverted or
+// TypeForced with the migration's version, name and checksum as JSON.
+func WithAuditStream(log Log, stream kimberlite.StreamID) Option {
+	return func(m *Migrator) { m.log, m.stream = log, stream }
+}
+
+// New returns a Migrator applying migrations, ordered by version, to
+// db.
+func New(db DB, migrations []Migration, opts ...Option) *Migrator {
+	m := &Migrator{db: db, table: "schema_migrations", now: time.Now}
+	m.migrations = slices.Clone(migrations)
+	slices.SortFunc(m.migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	for _, opt := range opts {
+		opt(m)
+	}
+	if err := sqlident.Check("migrations table", m.table); err != nil {
+		panic("migrate: " + err.Error())
+	}
+	return m
+}
+
+// Status describes a migration and whether it is applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Dirty reports a migration that failed part way.
+	Dirty bool
+	// Modified reports an applied migration whose up script has
+	// changed since it was applied.
+	Modified bool
+	// Unknown reports a version recorded as applied that is not among
+	// the Migrator's migrations; only Version and Name are set.
+	Unknown bool
+}
+
+type record struct {
+	name      string
+	checksum  string
+	dirty     bool
+	appliedAt time.Time
+}
+
+// Status returns the status of every migration, and of versions
+// recorded as applied that the Migrator does not know, by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	records, err := m.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Status
+	for _, mig := range m.migrations {
+		s := Status{Migration: mig}
+		if r, ok := records[mig.Version]; ok {
+			s.Applied, s.AppliedAt, s.Dirty = !r.dirty, r.appliedAt, r.dirty
+			s.Modified = r.checksum != mig.Checksum()
+			delete(records, mig.Version)
+		}
+		out = append(out, s)
+	}
+	for v, r := range records {
+		out = append(out, Status{Migration: Migration{Version: v, Name: r.name}, Applied: !r.dirty, AppliedAt: r.appliedAt, Dirty: r.dirty, Unknown: true})
+	}
+	slices.SortFunc(out, func(a, b Status) int { return cmp.Compare(a.Version, b.Version) })
+	return out, nil
+}
+
+// Up applies the migrations not yet applied, in order, and returns
+// those it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	records, err := m.clean(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, mig := range m.migrations {
+		if _, ok := records[mig.Version]; ok {
+			continue
+		}
+		if err := m.apply(ctx, mig); err != nil {
+			return applied, err
+		}
+		applied = append(applied, mig)
+	}
+	return applied, nil
+}
+
+// Down reverts the most recently applied migration, by version, and
+// returns it. It returns false if no migration is applied.
+func (m *Migrator) Down(ctx context.Context) (Migration, bool, error) {
+	records, err := m.clean(ctx)
+	if err != nil {
+		return Migration{}, false, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := records[mig.Version]; !ok {
+			continue
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			return mig, false, fmt.Errorf("%w: %d_%s", ErrNoDown, mig.Version, mig.Name)
+		}
+		return mig, true, m.revert(ctx, mig)
+	}
+	return Migration{}, false, nil
+}
+
+// Force records the migration of version as applied and not dirty,
+// once the schema has been brought to that migration's state by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	i := slices.IndexFunc(m.migrations, func(mig Migration) bool { return mig.Version == version })
+	if i < 0 {
+		return fmt.Errorf("kimberlite: no migration %d", version)
+	}
+	mig := m.migrations[i]
+	records, err := m.records(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := records[version]; ok {
+		err = m.setDirty(ctx, mig, false)
+	} else if err = m.insert(ctx, mig); err == nil {
+		err = m.setDirty(ctx, mig, false)
+	}
+	if err != nil {
+		return err
+	}
+	return m.audit(ctx, TypeForced, mig)
+}
+
+// clean returns the applied versions, failing with ErrDirty if a
+// migration is dirty.
+func (m *Migrator) clean(ctx context.Context) (map[int64]record, error) {
+	records, err := m.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for v, r := range records {
+		if r.dirty {
+			return nil, fmt.Errorf("%w: %d_%s failed part way; repair the schema and call Force", ErrDirty, v, r.name)
+		}
+	}
+	return records, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	if err := m.insert(ctx, mig); err != nil {
+		return err
+	}
+	if err := m.exec(ctx, mig, mig.Up); err != nil {
+		return err
+	}
+	if err := m.setDirty(ctx, mig, false); err != nil {
+		return err
+	}
+	return m.audit(ctx, TypeApplied, mig)
+}
+
+func (m *Migrator) revert(ctx context.Context, mig Migration) error {
+	if err := m.setDirty(ctx, mig, true); err != nil {
+		return err
+	}
+	if err := m.exec(ctx, mig, mig.Down); err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, "DELETE FROM "+m.table+" WHERE version = $1", kimberlite.NewInt(mig.Version)); err != nil {
+		return fmt.Errorf("kimberlite: migration %d: %w", mig.Version, err)
+	}
+	return m.audit(ctx, TypeReverted, mig)
+}
+
+func (m *Migrator) exec(ctx context.Context, mig Migration, script string) error {
+	for i, stmt := range splitStatements(script) {
+		if _, err := m.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("kimberlite: migration %d_%s statement %d: %w", mig.Version, mig.Name, i+1, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+m.table+
+		" (version BIGINT NOT NULL PRIMARY KEY, name TEXT NOT NULL, checksum TEXT NOT NULL, dirty BOOLEAN NOT NULL, applied_at TIMESTAMP NOT NULL)")
+	if err != nil {
+		return fmt.Errorf("kimberlite: migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) records(ctx context.Context) (map[int64]record, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	r, err := m.db.QueryContext(ctx, "SELECT version, name, checksum, dirty, applied_at FROM "+m.table)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: migrations table: %w", err)
+	}
+	records := make(map[int64]record, len(r.Rows))
+	for i := range r.Rows {
+		row := r.Row(i)
+		records[row.Int(0)] = record{name: row.Text(1), checksum: row.Text(2), dirty: row.Bool(3), appliedAt: row.Timestamp(4)}
+	}
+	return records, nil
+}
+
+// insert records mig as being applied, and dirty until setDirty
+// records it done.
+func (m *Migrator) insert(ctx context.Context, mig Migration) error {
+	_, err := m.db.ExecContext(ctx, "INSERT INTO "+m.table+" (version, name, checksum, dirty, applied_at) VALUES ($1, $2, $3, $4, $5)",
+		kimberlite.NewInt(mig.Version), kimberlite.NewText(mig.Name), kimberlite.NewText(mig.Checksum()),
+		kimberlite.NewBool(true), kimberlite.NewTimestamp(m.now()))
+	if err != nil {
+		return fmt.Errorf("kimberlite: migration %d: %w", mig.Version, err)
+	}
+	return nil
+}
+
+func (m *Migrator) setDirty(ctx context.Context, mig Migration, dirty bool) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE "+m.table+" SET dirty = $1, checksum = $2, applied_at = $3 WHERE version = $4",
+		kimberlite.NewBool(dirty), kimberlite.NewText(mig.Checksum()), kimberlite.NewTimestamp(m.now()), kimberlite.NewInt(mig.Version))
+	if err != nil {
+		return fmt.Errorf("kimberlite: migration %d: %w", mig.Version, err)
+	}
+	return nil
+}
+
+func (m *Migrator) audit(ctx context.Context, typ string, mig Migration) error {
+	if m.log == nil {
+		return nil
+	}
+	data, err := json.Marshal(map[string]any{"version": mig.Version, "name": mig.Name, "checksum": mig.Checksum()})
+	if err != nil {
+		return err
+	}
+	if _, err := m.log.AppendEnvelopes(ctx, m.stream, kimberlite.Envelope{Type: typ, Data: data}); err != nil {
+		return fmt.Errorf("kimberlite: migration %d: audit: %w", mig.Version, err)
+	}
+	return nil
+}
+
+// splitStatements splits script at the semicolons outside string
+// literals and quoted identifiers, dropping comments and empty
+// statements.
+func splitStatements(script string) []string {
+	var (
+		out []string
+		b   strings.Builder
+	)
+	add := func() {
+		if stmt := strings.TrimSpace(b.String()); stmt != "" {
+			out = append(out, stmt)
+		}
+		b.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '\'' || c == '"':
+			j := i + 1
+			for ; j < len(script); j++ {
+				if script[j] == c {
+					if j+1 < len(script) && script[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			j = min(j, len(script)-1)
+			b.WriteString(script[i : j+1])
+			i = j
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			b.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(script)
+			}
+			b.WriteByte(' ')
+		case c == ';':
+			add()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	add()
+	return out
+}
@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/kimberlitetest"
+)
+
+var files = fstest.MapFS{
+	"0001_patients.up.sql":   {Data: []byte("CREATE TABLE patients (id BIGINT NOT NULL PRIMARY KEY, name TEXT);\n-- seed\nINSERT INTO patients (id, name) VALUES (1, 'a;b');\n")},
+	"0001_patients.down.sql": {Data: []byte("DROP TABLE patients")},
+	"0002_wards.up.sql":      {Data: []byte("CREATE TABLE wards (id BIGINT NOT NULL PRIMARY KEY)")},
+	"0002_wards.down.sql":    {Data: []byte("DROP TABLE wards")},
+	"README.md":              {Data: []byte("not a migration")},
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[0].Name != "patients" || migrations[1].Down != "DROP TABLE wards" {
+		t.Fatalf("loaded %+v", migrations)
+	}
+	if _, err := Load(fstest.MapFS{"0003_x.down.sql": {}}); err == nil {
+		t.Fatal("migration without up script loaded")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := splitStatements("CREATE TABLE a (x TEXT); /* ; */ INSERT INTO a VALUES ('it''s;');\n-- done;\n")
+	want := []string{"CREATE TABLE a (x TEXT)", "INSERT INTO a VALUES ('it''s;')"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("split %q", got)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	client := kimberlitetest.NewMockClient()
+	defer client.Close()
+	audit, err := client.CreateStream("schema_changes", kimberlite.DataClassPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, _ := Load(files)
+	m := New(client, migrations, WithAuditStream(client, audit.ID))
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("up: %v, %v", applied, err)
+	}
+	if r, err := client.Query("SELECT name FROM patients"); err != nil || r.Row(0).Text(0) != "a;b" {
+		t.Fatalf("seed: %v", err)
+	}
+	if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("second up: %v, %v", applied, err)
+	}
+
+	reverted, ok, err := m.Down(ctx)
+	if err != nil || !ok || reverted.Version != 2 {
+		t.Fatalf("down: %v, %v, %v", reverted, ok, err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil || len(status) != 2 || !status[0].Applied || status[0].Modified || status[1].Applied {
+		t.Fatalf("status: %+v, %v", status, err)
+	}
+
+	events, err := client.ReadEvents(audit.ID, 0, 1<<20)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("audit events: %d, %v", len(events), err)
+	}
+	if env, _ := events[2].Envelope(); env.Type != TypeReverted {
+		t.Fatalf("last audit event %q", env.Type)
+	}
+
+	// A failing migration is left dirty until forced.
+	broken := append(migrations, Migration{Version: 3, Name: "broken", Up: "CREATE TABLE beds (id BIGINT NOT NULL PRIMARY KEY); NOT SQL"})
+	m = New(client, broken)
+	if _, err := m.Up(ctx); err == nil {
+		t.Fatal("broken migration applied")
+	}
+	if _, err := m.Up(ctx); !errors.Is(err, ErrDirty) {
+		t.Fatalf("up while dirty: %v", err)
+	}
+	if err := m.Force(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = m.Status(ctx)
+	if !status[1].Applied || !status[2].Applied || status[2].Dirty {
+		t.Fatalf("status after force: %+v", status)
+	}
+}
@@ -0,0 +1,340 @@
+package kimberlite
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact monetary amount: an integer count of the
+// currency's minor units (cents for USD, yen for JPY, fils for KWD)
+// and an ISO 4217 currency code. Arithmetic never goes through
+// floating point, and amounts that cannot be represented exactly are
+// rejected rather than rounded.
+type Money struct {
+	// Amount is the value in minor units.
+	Amount int64
+	// Currency is the upper-case ISO 4217 alphabetic code.
+	Currency string
+}
+
+var (
+	// ErrCurrencyMismatch is returned when combining amounts in
+	// different currencies.
+	ErrCurrencyMismatch = errors.New("kimberlite: currency mismatch")
+
+	// ErrMoneyPrecision is returned when an amount has more decimal
+	// places than its currency's minor unit allows.
+	ErrMoneyPrecision = errors.New("kimberlite: amount not representable in minor units")
+
+	// ErrMoneyOverflow is returned when an amount exceeds int64 minor units.
+	ErrMoneyOverflow = errors.New("kimberlite: money overflow")
+)
+
+// currencyExponents lists ISO 4217 currencies whose minor unit is not
+// 1/100 of the major unit.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// CurrencyExponent returns the number of decimal places of the
+// currency's minor unit: 2 unless ISO 4217 specifies otherwise.
+func CurrencyExponent(currency string) int {
+	if e, ok := currencyExponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewMoney returns amount minor units of currency, which must be a
+// three-letter ISO 4217 code (case-insensitive).
+func NewMoney(amount int64, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	if !validCurrency(currency) {
+		return Money{}, fmt.Errorf("kimberlite: invalid currency code %q", currency)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// ParseMoney parses a decimal amount in major units, such as "12.34",
+// in the given currency.
+func ParseMoney(amount, currency string) (Money, error) {
+	m, err := NewMoney(0, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if m.Amount, err = parseMinorUnits(amount, CurrencyExponent(m.Currency)); err != nil {
+		return Money{}, err
+	}
+	return m, nil
+}
+
+// parseMinorUnits converts decimal text (optionally signed, optionally
+// in exponent form as floats are rendered) to minor units exactly.
+func parseMinorUnits(s string, exp int) (int64, error) {
+	s = strings.TrimSpace(s)
+	orig := s
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return 0, fmt.Errorf("kimberlite: invalid amount %q", orig)
+		}
+		exp += e
+		s = s[:i]
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+	if intPart == "" && frac == "" {
+		return 0, fmt.Errorf("kimberlite: invalid amount %q", orig)
+	}
+	digits := intPart + frac
+	exp -= len(frac)
+	for exp < 0 {
+		if !strings.HasSuffix(digits, "0") {
+			return 0, fmt.Errorf("%w: %q", ErrMoneyPrecision, orig)
+		}
+		digits = digits[:len(digits)-1]
+		exp++
+	}
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return 0, nil
+	}
+	if len(digits)+exp > 19 {
+		return 0, fmt.Errorf("%w: %q", ErrMoneyOverflow, orig)
+	}
+	digits += strings.Repeat("0", exp)
+	for i := 0; i < len(digits); i++ {
+		if !isDigit(digits[i]) {
+			return 0, fmt.Errorf("kimberlite: invalid amount %q", orig)
+		}
+	}
+	u, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || u > math.MaxInt64+1 || (!neg && u > math.MaxInt64) {
+		return 0, fmt.Errorf("%w: %q", ErrMoneyOverflow, orig)
+	}
+	if neg {
+		return int64(-u), nil
+	}
+	return int64(u), nil
+}
+
+// Decimal renders the amount in major units with exactly the
+// currency's number of decimal places, e.g. "-12.05".
+func (m Money) Decimal() string {
+	exp := CurrencyExponent(m.Currency)
+	u := uint64(m.Amount)
+	sign := ""
+	if m.Amount < 0 {
+		sign, u = "-", -u
+	}
+	s := strconv.FormatUint(u, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+// String renders the amount with its currency, e.g. "12.34 USD".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool { return m.Amount == 0 }
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return nil
+}
+
+// Add returns m + o.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (sum > m.Amount) != (o.Amount > 0) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Mul returns m multiplied by an integer quantity.
+func (m Money) Mul(n int64) (Money, error) {
+	hi, lo := bits.Mul64(absU64(m.Amount), absU64(n))
+	neg := (m.Amount < 0) != (n < 0)
+	if hi != 0 || lo > math.MaxInt64+1 || (!neg && lo > math.MaxInt64) {
+		return Money{}, ErrMoneyOverflow
+	}
+	amount := int64(lo)
+	if neg {
+		amount = int64(-lo)
+	}
+	return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+func absU64(n int64) uint64 {
+	if n < 0 {
+		return -uint64(n)
+	}
+	return uint64(n)
+}
+
+// Cmp compares m and o, returning -1, 0 or +1.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Allocate splits m in proportion to ratios without losing or creating
+// minor units: the remainder left by integer division is distributed
+// one unit at a time to the first shares. Splitting 10.00 three ways
+// yields 3.34, 3.33 and 3.33.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, fmt.Errorf("kimberlite: negative allocation ratio %d", r)
+		}
+		total += r
+		if total < 0 {
+			return nil, ErrMoneyOverflow
+		}
+	}
+	if total == 0 {
+		return nil, errors.New("kimberlite: allocation ratios sum to zero")
+	}
+	out := make([]Money, len(ratios))
+	remainder := m.Amount
+	for i, r := range ratios {
+		hi, lo := bits.Mul64(absU64(m.Amount), uint64(r))
+		q, _ := bits.Div64(hi, lo, uint64(total))
+		share := int64(q)
+		if m.Amount < 0 {
+			share = -share
+		}
+		out[i] = Money{Amount: share, Currency: m.Currency}
+		remainder -= share
+	}
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i++ {
+		if ratios[i%len(ratios)] == 0 {
+			continue
+		}
+		out[i%len(ratios)].Amount += step
+		remainder -= step
+	}
+	return out, nil
+}
+
+type moneyJSON struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes m as {"amount": <minor units>, "currency": "USD"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON decodes the form produced by MarshalJSON.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var j moneyJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	v, err := NewMoney(j.Amount, j.Currency)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value implements driver.Valuer, binding m as its decimal amount in
+// major units, ready for a DECIMAL column. The currency is not part of
+// the bound value; store it in its own column.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// AsMoney interprets the value as an amount in major units of
+// currency. DECIMAL and REAL columns arrive as decimal text and are
+// converted exactly; integers are whole major units. Fails with
+// ErrMoneyPrecision when the amount has more decimal places than the
+// currency allows, and ErrNullValue for NULL.
+func (v Value) AsMoney(currency string) (Money, error) {
+	m, err := NewMoney(0, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	exp := CurrencyExponent(m.Currency)
+	switch v.Type {
+	case ValueTypeNull:
+		return Money{}, ErrNullValue
+	case ValueTypeInteger:
+		m.Amount, err = parseMinorUnits(strconv.FormatInt(v.AsInt(), 10), exp)
+	case ValueTypeText:
+		m.Amount, err = parseMinorUnits(v.AsText(), exp)
+	case ValueTypeFloat:
+		// Shortest round-trip formatting recovers the literal the float
+		// was parsed from, so 0.1 converts to 10 cents, not 0.1000000000000000055.
+		m.Amount, err = parseMinorUnits(strconv.FormatFloat(v.AsFloat(), 'g', -1, 64), exp)
+	default:
+		return Money{}, fmt.Errorf("%w: cannot convert %s to money", ErrTypeMismatch, v.Type)
+	}
+	if err != nil {
+		return Money{}, err
+	}
+	return m, nil
+}
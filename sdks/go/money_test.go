@@ -0,0 +1,76 @@
+package kimberlite
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMoneyParseAndFormat(t *testing.T) {
+	cases := []struct {
+		in, currency string
+		minor        int64
+		out          string
+	}{
+		{"12.34", "usd", 1234, "12.34 USD"},
+		{"-0.05", "EUR", -5, "-0.05 EUR"},
+		{"1500", "JPY", 1500, "1500 JPY"},
+		{"1.5", "KWD", 1500, "1.500 KWD"},
+		{"1.2300", "USD", 123, "1.23 USD"},
+		{"1e2", "USD", 10000, "100.00 USD"},
+	}
+	for _, tc := range cases {
+		m, err := ParseMoney(tc.in, tc.currency)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.in, tc.currency, err)
+		}
+		if m.Amount != tc.minor || m.String() != tc.out {
+			t.Errorf("%s %s = %d (%s), want %d (%s)", tc.in, tc.currency, m.Amount, m, tc.minor, tc.out)
+		}
+	}
+	if _, err := ParseMoney("0.001", "USD"); !errors.Is(err, ErrMoneyPrecision) {
+		t.Fatalf("expected ErrMoneyPrecision, got %v", err)
+	}
+	if _, err := ParseMoney("1e30", "USD"); !errors.Is(err, ErrMoneyOverflow) {
+		t.Fatalf("expected ErrMoneyOverflow, got %v", err)
+	}
+	if _, err := NewMoney(1, "US"); err == nil {
+		t.Fatal("invalid currency should fail")
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a, _ := NewMoney(1000, "USD")
+	b, _ := NewMoney(1, "EUR")
+	if _, err := a.Add(b); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch, got %v", err)
+	}
+	if _, err := (Money{Amount: 1 << 62, Currency: "USD"}).Mul(4); !errors.Is(err, ErrMoneyOverflow) {
+		t.Fatalf("expected ErrMoneyOverflow, got %v", err)
+	}
+	shares, err := a.Allocate(1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shares[0].Amount != 334 || shares[1].Amount != 333 || shares[2].Amount != 333 {
+		t.Fatalf("allocation = %v", shares)
+	}
+	neg, _ := NewMoney(-5, "USD")
+	if shares, _ := neg.Allocate(1, 1); shares[0].Amount != -3 || shares[1].Amount != -2 {
+		t.Fatalf("negative allocation = %v", shares)
+	}
+}
+
+func TestValueAsMoney(t *testing.T) {
+	for _, v := range []Value{NewText("0.10"), NewFloat(0.1)} {
+		m, err := v.AsMoney("USD")
+		if err != nil || m.Amount != 10 {
+			t.Fatalf("%v as money = %v, %v", v, m, err)
+		}
+	}
+	if _, err := NewNull().AsMoney("USD"); !errors.Is(err, ErrNullValue) {
+		t.Fatalf("expected ErrNullValue, got %v", err)
+	}
+	if dv, _ := (Money{Amount: 1999, Currency: "USD"}).Value(); dv != "19.99" {
+		t.Fatalf("driver value = %v", dv)
+	}
+}
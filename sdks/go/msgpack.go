@@ -0,0 +1,316 @@
+package kimberlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MsgpackCodec encodes payloads as MessagePack.
+//
+// Values are mapped through their JSON representation, so struct tags,
+// json.Marshaler implementations and field names behave exactly as
+// with JSONCodec; the wire form is compact MessagePack that any
+// MessagePack library can read. Map keys are written in sorted order.
+//
+// Going through JSON limits the types written to those JSON has:
+//   - []byte is written as a str holding its base64 encoding, never as
+//     bin, as are other types marshalling to JSON strings, such as
+//     time.Time as RFC 3339 text.
+//   - Floats with no fractional part, such as 2.0, are written as
+//     integers, so other MessagePack readers see an int. This codec
+//     reads them back as float64.
+//   - Integers beyond the range of int64 and uint64 are written as
+//     float64.
+//   - Ext types, including the timestamp extension, are neither
+//     written nor read.
+//
+// bin values are read as []byte, which decodes into []byte fields.
+// Payloads shared with other MessagePack producers should keep to
+// types these rules round-trip.
+var MsgpackCodec Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return msgpackAppend(nil, tree)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	d := msgpackDecoder{buf: data}
+	tree, err := d.value()
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.buf) {
+		return errMsgpackTrailing
+	}
+	j, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, v)
+}
+
+var (
+	errMsgpackTruncated = errors.New("kimberlite: msgpack: truncated data")
+	errMsgpackTrailing  = errors.New("kimberlite: msgpack: trailing data")
+)
+
+func msgpackAppend(b []byte, v any) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if t {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(t), 10, 64); err == nil {
+			return msgpackAppendInt(b, i), nil
+		}
+		if u, err := strconv.ParseUint(string(t), 10, 64); err == nil {
+			b = append(b, 0xcf)
+			return binary.BigEndian.AppendUint64(b, u), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, 0xcb)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case string:
+		n := len(t)
+		switch {
+		case n < 32:
+			b = append(b, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			b = append(b, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+		}
+		return append(b, t...), nil
+	case []any:
+		b = msgpackAppendHeader(b, len(t), 0x90, 0xdc)
+		var err error
+		for _, e := range t {
+			if b, err = msgpackAppend(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = msgpackAppendHeader(b, len(t), 0x80, 0xde)
+		var err error
+		for _, k := range keys {
+			if b, err = msgpackAppend(b, k); err != nil {
+				return nil, err
+			}
+			if b, err = msgpackAppend(b, t[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("kimberlite: msgpack: unsupported value %T", v)
+}
+
+func msgpackAppendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 127, i >= -32 && i < 0:
+		return append(b, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+// msgpackAppendHeader writes an array or map header: the fix form for
+// fewer than 16 entries, else the 16- or 32-bit form (wide+1).
+func msgpackAppendHeader(b []byte, n int, fix, wide byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, wide), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, wide+1), uint32(n))
+}
+
+type msgpackDecoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *msgpackDecoder) take(n int) ([]byte, error) {
+	if n < 0 || len(d.buf)-d.pos < n {
+		return nil, errMsgpackTruncated
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *msgpackDecoder) value() (any, error) {
+	tb, err := d.take(1)
+	if err != nil {
+		return nil, err
+	}
+	c := tb[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return d.object(int(c & 0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.take(int(n))
+		return append([]byte(nil), b...), err
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (c - 0xcc))
+	case 0xd0:
+		u, err := d.uint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.uint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.uint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.uint(8)
+		return int64(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(int(n))
+	}
+	return nil, fmt.Errorf("kimberlite: msgpack: unsupported type byte 0x%02x", c)
+}
+
+func (d *msgpackDecoder) str(n int) (any, error) {
+	b, err := d.take(n)
+	return string(b), err
+}
+
+func (d *msgpackDecoder) array(n int) (any, error) {
+	if n > len(d.buf)-d.pos {
+		return nil, errMsgpackTruncated
+	}
+	out := make([]any, n)
+	for i := range out {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (d *msgpackDecoder) object(n int) (any, error) {
+	if n > len(d.buf)-d.pos {
+		return nil, errMsgpackTruncated
+	}
+	out := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		switch key := k.(type) {
+		case string:
+			out[key] = v
+		case int64:
+			out[strconv.FormatInt(key, 10)] = v
+		case uint64:
+			out[strconv.FormatUint(key, 10)] = v
+		default:
+			return nil, fmt.Errorf("kimberlite: msgpack: unsupported map key %T", k)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,238 @@
+package kimberlite
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NamedQuery executes sql with :name or @name placeholders bound from
+// arg.
+// Equivalent to NamedQueryContext(context.Background(), sql, arg).
+func (c *Client) NamedQuery(sql string, arg any) (*QueryResult, error) {
+	return c.NamedQueryContext(context.Background(), sql, arg)
+}
+
+// NamedQueryContext executes sql with :name or @name placeholders
+// bound from arg, which is a struct (or pointer to one) or a map with
+// string keys.
+//
+// Struct fields bind by their `db` tag, falling back to the lowercased
+// field name, the same mapping sqlx uses; `db:"-"` skips a field and
+// embedded structs are flattened:
+//
+//	type admission struct {
+//	    PatientID int64  `db:"patient_id"`
+//	    Ward      string `db:"ward"`
+//	}
+//	client.NamedQueryContext(ctx,
+//	    "SELECT * FROM admissions WHERE patient_id = :patient_id AND ward = :ward",
+//	    admission{PatientID: 42, Ward: "icu"})
+func (c *Client) NamedQueryContext(ctx context.Context, sql string, arg any) (*QueryResult, error) {
+	positional, args, err := BindNamed(sql, arg)
+	if err != nil {
+		return nil, err
+	}
+	return c.QueryContext(ctx, positional, args...)
+}
+
+// NamedExec executes a write statement with :name or @name
+// placeholders bound from arg. Equivalent to NamedExecContext(context.Background(), sql, arg).
+func (c *Client) NamedExec(sql string, arg any) (*ExecResult, error) {
+	return c.NamedExecContext(context.Background(), sql, arg)
+}
+
+// NamedExecContext is the write-statement counterpart of
+// NamedQueryContext and binds arg the same way.
+func (c *Client) NamedExecContext(ctx context.Context, sql string, arg any) (*ExecResult, error) {
+	positional, args, err := BindNamed(sql, arg)
+	if err != nil {
+		return nil, err
+	}
+	return c.ExecContext(ctx, positional, args...)
+}
+
+// BindNamed rewrites :name and @name placeholders in sql to positional
+// $N placeholders and returns the matching arguments taken from arg. A
+// name used several times binds a single parameter, whichever form
+// each use takes, so :id and @id are the same parameter.
+func BindNamed(sql string, arg any) (string, []Value, error) {
+	lookup, err := namedLookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		b     strings.Builder
+		args  []Value
+		index = make(map[string]int)
+		last  int
+	)
+	for _, p := range scanNamedPlaceholders(sql) {
+		n, seen := index[p.name]
+		if !seen {
+			x, ok := lookup(p.name)
+			if !ok {
+				return "", nil, fmt.Errorf("kimberlite: no value bound for %s", sql[p.start:p.end])
+			}
+			v, err := ValueOf(x)
+			if err != nil {
+				return "", nil, fmt.Errorf("kimberlite: parameter %s: %w", sql[p.start:p.end], err)
+			}
+			args = append(args, v)
+			n = len(args)
+			index[p.name] = n
+		}
+		b.WriteString(sql[last:p.start])
+		b.WriteString("$" + strconv.Itoa(n))
+		last = p.end
+	}
+	b.WriteString(sql[last:])
+	return b.String(), args, nil
+}
+
+// Rebind rewrites ? placeholders in sql to $1, $2, ... in order, for
+// code written against drivers that use question-mark bind variables.
+// Question marks inside literals, quoted identifiers and comments are
+// left alone, and sql that already uses $N placeholders is returned
+// unchanged.
+func Rebind(sql string) string {
+	if len(scanPlaceholders(sql)) > 0 {
+		return sql
+	}
+	var (
+		b    strings.Builder
+		n    int
+		last int
+	)
+	walkSQL(sql, func(i int) int {
+		if sql[i] == '?' {
+			n++
+			b.WriteString(sql[last:i])
+			b.WriteString("$" + strconv.Itoa(n))
+			last = i + 1
+		}
+		return i + 1
+	})
+	if n == 0 {
+		return sql
+	}
+	b.WriteString(sql[last:])
+	return b.String()
+}
+
+// namedLookup returns a function resolving parameter names against arg.
+func namedLookup(arg any) (func(name string) (any, bool), error) {
+	switch m := arg.(type) {
+	case map[string]any:
+		return func(name string) (any, bool) { x, ok := m[name]; return x, ok }, nil
+	case map[string]Value:
+		return func(name string) (any, bool) { x, ok := m[name]; return x, ok }, nil
+	}
+
+	rv := reflect.ValueOf(arg)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("kimberlite: cannot bind named parameters from nil %T", arg)
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("kimberlite: named parameter map must have string keys, got %T", arg)
+		}
+		return func(name string) (any, bool) {
+			x := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+			if !x.IsValid() {
+				return nil, false
+			}
+			return x.Interface(), true
+		}, nil
+	case reflect.Struct:
+		fields := structFields(rv.Type())
+		return func(name string) (any, bool) {
+			path, ok := fields[name]
+			if !ok {
+				return nil, false
+			}
+			f, ok := fieldByPath(rv, path)
+			if !ok {
+				return nil, true // nil embedded pointer: bind NULL
+			}
+			return f.Interface(), true
+		}, nil
+	}
+	return nil, fmt.Errorf("kimberlite: cannot bind named parameters from %T", arg)
+}
+
+var structFieldCache sync.Map // reflect.Type -> map[string][]int
+
+// structFields maps column names to field index paths for t.
+func structFields(t reflect.Type) map[string][]int {
+	if cached, ok := structFieldCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	collectStructFields(t, nil, fields)
+	structFieldCache.Store(t, fields)
+	return fields
+}
+
+// collectStructFields records the bindable fields of t in into. Fields
+// of the outer struct shadow fields promoted from embedded ones.
+func collectStructFields(t reflect.Type, prefix []int, into map[string][]int) {
+	type embedded struct {
+		t    reflect.Type
+		path []int
+	}
+	var deferred []embedded
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+		path := append(append([]int(nil), prefix...), i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && !hasTag && ft.Kind() == reflect.Struct {
+			deferred = append(deferred, embedded{t: ft, path: path})
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if _, dup := into[name]; !dup {
+			into[name] = path
+		}
+	}
+	for _, e := range deferred {
+		collectStructFields(e.t, e.path, into)
+	}
+}
+
+// fieldByPath walks an index path, reporting false if it crosses a nil
+// embedded pointer.
+func fieldByPath(v reflect.Value, path []int) (reflect.Value, bool) {
+	for i, idx := range path {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, true
+}
@@ -0,0 +1,82 @@
+package kimberlite
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBindNamedStruct(t *testing.T) {
+	type audit struct {
+		Actor string `db:"actor"`
+	}
+	type admission struct {
+		audit
+		PatientID int64  `db:"patient_id"`
+		Ward      string // binds as "ward"
+		Secret    string `db:"-"`
+	}
+
+	sql, args, err := BindNamed(
+		"INSERT INTO admissions VALUES (:patient_id, :ward, :actor) -- :secret\n"+
+			"RETURNING note::text, ':ward', :patient_id",
+		admission{audit: audit{Actor: "dr-house"}, PatientID: 42, Ward: "icu"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO admissions VALUES ($1, $2, $3) -- :secret\nRETURNING note::text, ':ward', $1"
+	if sql != want {
+		t.Fatalf("sql = %q\nwant  %q", sql, want)
+	}
+	if len(args) != 3 || args[0].AsInt() != 42 || args[1].AsText() != "icu" || args[2].AsText() != "dr-house" {
+		t.Fatalf("unexpected args: %+v", args)
+	}
+}
+
+func TestBindNamedMap(t *testing.T) {
+	sql, args, err := BindNamed("SELECT * FROM t WHERE a = :a AND b = :b", map[string]any{"a": 1, "b": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != "SELECT * FROM t WHERE a = $1 AND b = $2" || !args[1].IsNull() {
+		t.Fatalf("sql = %q, args = %+v", sql, args)
+	}
+
+	if _, _, err := BindNamed("SELECT :missing", map[string]any{}); err == nil {
+		t.Fatal("unbound name should fail")
+	}
+	if _, _, err := BindNamed("SELECT :a", 5); err == nil {
+		t.Fatal("non-struct, non-map arg should fail")
+	}
+}
+
+func TestBindNamedAt(t *testing.T) {
+	sql, args, err := BindNamed(
+		"SELECT * FROM t WHERE a = @a AND tags @> @tags AND b = :a AND c @@ 'x' AND note = '@a'",
+		map[string]any{"a": 1, "tags": "icu"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT * FROM t WHERE a = $1 AND tags @> $2 AND b = $1 AND c @@ 'x' AND note = '@a'"
+	if sql != want || len(args) != 2 {
+		t.Fatalf("sql = %q\nwant  %q, args = %+v", sql, want, args)
+	}
+	if _, _, err := BindNamed("SELECT @missing", map[string]any{}); err == nil || !strings.Contains(err.Error(), "@missing") {
+		t.Fatalf("unbound name: %v", err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM t WHERE a = ? AND b = ?":    "SELECT * FROM t WHERE a = $1 AND b = $2",
+		"SELECT '?' FROM t WHERE a = ? -- why?\n":  "SELECT '?' FROM t WHERE a = $1 -- why?\n",
+		"SELECT * FROM t WHERE a = $1 AND b = '?'": "SELECT * FROM t WHERE a = $1 AND b = '?'",
+		"SELECT 1": "SELECT 1",
+	}
+	for in, want := range cases {
+		if got := Rebind(in); got != want {
+			t.Errorf("Rebind(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package notify sends lightweight notifications, a channel name and a
+// payload, between the services of a tenant, in the manner of
+// PostgreSQL's LISTEN and NOTIFY, for cache invalidation and similar
+// coordination:
+//
+//	n := notify.New(client, notifications)
+//	sub, err := n.Subscribe(ctx, "patients")
+//	...
+//	defer sub.Close()
+//	for {
+//	    msg, err := sub.Next(ctx)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    cache.Invalidate(string(msg.Payload))
+//	}
+//
+//	// elsewhere, after changing patient 42:
+//	err = n.Notify(ctx, "patients", []byte("42"))
+//
+// The server has no notification channels, so notifications are events
+// of a stream shared by every channel, which should hold nothing else.
+// Subscriptions receive the stream's events as the server pushes them,
+// with Client.Subscribe, so each notification arrives as soon as it is
+// durable, in the order sent. Unlike PostgreSQL's, notifications are
+// durable: a subscriber that was away can resume with SubscribeFrom
+// where it left off.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// TypeNotification is the envelope type of notifications, MetaChannel
+// the metadata key of their channel and MetaSentAt that of the time
+// they were sent, in RFC 3339 format.
+const (
+	TypeNotification = "kimberlite.notify"
+	MetaChannel      = "notify.channel"
+	MetaSentAt       = "notify.sent_at"
+)
+
+// EventLog is the part of *kimberlite.Client a Notifier uses.
+type EventLog interface {
+	AppendContext(ctx context.Context, streamID kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error)
+	ReadEventsContext(ctx context.Context, streamID kimberlite.StreamID, from kimberlite.Offset, maxBytes uint64) ([]kimberlite.Event, error)
+	Subscribe(ctx context.Context, streamID kimberlite.StreamID, from kimberlite.Offset) (*kimberlite.StreamSubscription, error)
+}
+
+// Notifier sends and subscribes to the notifications of a stream. It
+// is safe for concurrent use.
+type Notifier struct {
+	log    EventLog
+	stream kimberlite.StreamID
+}
+
+// New returns a Notifier for the notifications of stream.
+func New(log EventLog, stream kimberlite.StreamID) *Notifier {
+	return &Notifier{log: log, stream: stream}
+}
+
+// Notification is a notification received by a Subscription.
+type Notification struct {
+	Channel string
+	Payload []byte
+	// Offset is the notification's position in the stream;
+	// SubscribeFrom(Offset+1) resumes after it.
+	Offset kimberlite.Offset
+	// SentAt is when the notifier sent the notification, by its clock,
+	// or zero if the notification does not record it.
+	SentAt time.Time
+}
+
+// Notify sends payload to the subscribers of channel. It returns once
+// the notification is durable; subscribers receive it after.
+func (n *Notifier) Notify(ctx context.Context, channel string, payload []byte) error {
+	if channel == "" {
+		return fmt.Errorf("kimberlite: notify without a channel")
+	}
+	data, err := kimberlite.Envelope{
+		Type:     TypeNotification,
+		Metadata: map[string]string{MetaChannel: channel, MetaSentAt: time.Now().UTC().Format(time.RFC3339Nano)},
+		Data:     payload,
+	}.Marshal()
+	if err != nil {
+		return err
+	}
+	if _, err := n.log.AppendContext(ctx, n.stream, data); err != nil {
+		return fmt.Errorf("kimberlite: notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Subscribe returns a subscription to the notifications sent to
+// channels after it subscribes, or to every channel if there are none.
+func (n *Notifier) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	end, err := n.end(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: subscribe to notifications: %w", err)
+	}
+	return n.SubscribeFrom(end, channels...), nil
+}
+
+// SubscribeFrom returns a subscription to the notifications sent to
+// channels, or to every channel if there are none, from offset on. A
+// subscriber resumes after downtime from the Offset of its last
+// subscription. The subscription opens its server subscription on the
+// first call of Next.
+func (n *Notifier) SubscribeFrom(offset kimberlite.Offset, channels ...string) *Subscription {
+	return &Subscription{n: n, channels: slices.Clone(channels), from: offset}
+}
+
+// probeBytes is the maxBytes of the reads that find the stream's end.
+const probeBytes = 1 << 10
+
+// end returns the offset after the last event of the stream, found by
+// reading at exponentially growing offsets and then bisecting, so that
+// subscribing does not read the stream's history.
+func (n *Notifier) end(ctx context.Context) (kimberlite.Offset, error) {
+	exists := func(o kimberlite.Offset) (bool, error) {
+		events, err := n.log.ReadEventsContext(ctx, n.stream, o, probeBytes)
+		return len(events) > 0, err
+	}
+	// Every offset below lo holds an event, and hi holds none.
+	lo, hi := kimberlite.Offset(0), kimberlite.Offset(0)
+	for {
+		ok, err := exists(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		lo, hi = hi+1, 2*hi+1
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ok, err := exists(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// Subscription receives the notifications of a set of channels. It is
+// not safe for concurrent use.
+type Subscription struct {
+	n        *Notifier
+	channels []string
+	from     kimberlite.Offset
+	events   *kimberlite.StreamSubscription
+	closed   bool
+}
+
+// Next returns the next notification, waiting for one to be sent if
+// there is none, until ctx is done.
+func (s *Subscription) Next(ctx context.Context) (Notification, error) {
+	if s.closed {
+		return Notification{}, kimberlite.ErrSubscriptionClosed
+	}
+	if s.events == nil {
+		events, err := s.n.log.Subscribe(ctx, s.n.stream, s.from)
+		if err != nil {
+			return Notification{}, fmt.Errorf("kimberlite: subscribe to notifications: %w", err)
+		}
+		s.events = events
+	}
+	for {
+		ev, err := s.events.Next(ctx)
+		if err != nil {
+			return Notification{}, err
+		}
+		s.from = ev.Offset + 1
+		env, err := kimberlite.UnmarshalEnvelope(ev.Data)
+		if err != nil || env.Type != TypeNotification {
+			continue
+		}
+		channel := env.Metadata[MetaChannel]
+		if len(s.channels) > 0 && !slices.Contains(s.channels, channel) {
+			continue
+		}
+		sentAt, _ := time.Parse(time.RFC3339Nano, env.Metadata[MetaSentAt])
+		return Notification{Channel: channel, Payload: env.Data, Offset: ev.Offset, SentAt: sentAt}, nil
+	}
+}
+
+// Offset returns the offset the subscription reads from next:
+// SubscribeFrom it returns a subscription that receives the
+// notifications this one has not returned yet.
+func (s *Subscription) Offset() kimberlite.Offset {
+	return s.from
+}
+
+// Close ends the subscription, releasing its server subscription.
+func (s *Subscription) Close() error {
+	s.closed = true
+	if s.events == nil {
+		return nil
+	}
+	return s.events.Close()
+}
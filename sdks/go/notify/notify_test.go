@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/kimberlitetest"
+)
+
+func TestNotify(t *testing.T) {
+	ctx := context.Background()
+	client := kimberlitetest.NewMockClient()
+	info, err := client.CreateStream("notifications", kimberlite.DataClassPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := New(client, info.ID)
+	start := time.Now()
+	// Sent before subscribing, so not received.
+	for i := 0; i < 5; i++ {
+		if err := n.Notify(ctx, "patients", []byte("old")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sub, err := n.Subscribe(ctx, "patients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Offset() != 5 {
+		t.Fatalf("subscribed at %d, want 5", sub.Offset())
+	}
+	all, _ := n.Subscribe(ctx)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		n.Notify(ctx, "wards", []byte("4"))
+		n.Notify(ctx, "patients", []byte("42"))
+	}()
+	got, err := sub.Next(ctx)
+	if err != nil || got.Channel != "patients" || string(got.Payload) != "42" || got.Offset != 6 {
+		t.Fatalf("Next = %+v, %v", got, err)
+	}
+	for _, want := range []string{"wards", "patients"} {
+		if got, err := all.Next(ctx); err != nil || got.Channel != want {
+			t.Fatalf("all.Next = %+v, %v; want %s", got, err, want)
+		}
+	}
+
+	resumed := n.SubscribeFrom(3, "patients")
+	for _, want := range []kimberlite.Offset{3, 4, 6} {
+		got, err := resumed.Next(ctx)
+		if err != nil || got.Offset != want {
+			t.Fatalf("resumed.Next = %+v, %v; want offset %d", got, err, want)
+		}
+		// The subscription reads them later; SentAt is when they were sent.
+		if got.SentAt.Before(start) || got.SentAt.After(time.Now()) {
+			t.Fatalf("SentAt %v, sent after %v", got.SentAt, start)
+		}
+	}
+
+	short, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	if _, err := sub.Next(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Next with nothing sent = %v", err)
+	}
+	if err := n.Notify(ctx, "", nil); err == nil {
+		t.Fatal("Notify without a channel succeeded")
+	}
+	sub.Close()
+	if _, err := sub.Next(ctx); !errors.Is(err, kimberlite.ErrSubscriptionClosed) {
+		t.Fatalf("Next after Close = %v", err)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	ctx := context.Background()
+	client := kimberlitetest.NewMockClient()
+	for _, size := range []int{0, 1, 2, 3, 7, 8, 9, 100} {
+		info, err := client.CreateStream(fmt.Sprint("s", size), kimberlite.DataClassPublic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < size; i++ {
+			client.AppendContext(ctx, info.ID, []byte("x"))
+		}
+		if end, err := New(client, info.ID).end(ctx); err != nil || end != kimberlite.Offset(size) {
+			t.Errorf("end of %d events = %d, %v", size, end, err)
+		}
+	}
+}
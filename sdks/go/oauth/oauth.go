@@ -0,0 +1,190 @@
+// Package oauth authenticates Kimberlite clients with OAuth 2.0 access
+// tokens obtained by the client-credentials grant (RFC 6749 §4.4), the
+// flow of service accounts and workloads without a user:
+//
+//	creds := oauth.NewClientCredentials(http.DefaultClient,
+//	    "https://idp.example.com/oauth2/token", clientID, clientSecret,
+//	    oauth.WithScopes("kimberlite"))
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(1),
+//	    kimberlite.WithTokenProvider(creds.Token))
+//
+// Tokens are cached until shortly before they expire, so clients and
+// pools sharing one ClientCredentials make one token request per
+// token lifetime. Discover finds the token endpoint of an OpenID
+// Connect issuer.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// refreshMargin is how long before its expiry a cached token is
+// replaced. It exceeds the margin of the kimberlite client, so a client
+// refreshing its token gets a new one.
+const refreshMargin = 2 * time.Minute
+
+// Option configures ClientCredentials.
+type Option func(*ClientCredentials)
+
+// WithScopes sets the scopes requested.
+func WithScopes(scopes ...string) Option {
+	return func(c *ClientCredentials) { c.scopes = scopes }
+}
+
+// WithAudience sets the audience parameter that some identity
+// providers, such as Auth0, require.
+func WithAudience(audience string) Option {
+	return WithParam("audience", audience)
+}
+
+// WithParam adds a parameter to token requests.
+func WithParam(key, value string) Option {
+	return func(c *ClientCredentials) { c.params.Add(key, value) }
+}
+
+// WithCredentialsInBody sends the client ID and secret as form
+// parameters rather than with HTTP basic authentication, for providers
+// not supporting the latter.
+func WithCredentialsInBody() Option {
+	return func(c *ClientCredentials) { c.inBody = true }
+}
+
+// ClientCredentials obtains and caches access tokens with the
+// client-credentials grant. It is safe for concurrent use.
+type ClientCredentials struct {
+	client   *http.Client
+	tokenURL string
+	id       string
+	secret   string
+	scopes   []string
+	params   url.Values
+	inBody   bool
+	now      func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+var _ kimberlite.TokenProvider = (*ClientCredentials)(nil).Token
+
+// NewClientCredentials returns a token source requesting tokens from
+// the token endpoint tokenURL as client id.
+func NewClientCredentials(client *http.Client, tokenURL, id, secret string, opts ...Option) *ClientCredentials {
+	c := &ClientCredentials{
+		client:   client,
+		tokenURL: tokenURL,
+		id:       id,
+		secret:   secret,
+		params:   url.Values{},
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token returns a cached access token, requesting a new one if it is
+// about to expire or was rejected (see kimberlite.RejectedToken). It is
+// a kimberlite.TokenProvider.
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	rejected, _ := kimberlite.RejectedToken(ctx)
+	return c.cachedToken(ctx, rejected)
+}
+
+func (c *ClientCredentials) cachedToken(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && c.token != rejected && (c.expires.IsZero() || c.now().Before(c.expires)) {
+		return c.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+	for k, vs := range c.params {
+		form[k] = vs
+	}
+	if c.inBody {
+		form.Set("client_id", c.id)
+		form.Set("client_secret", c.secret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !c.inBody {
+		req.SetBasicAuth(url.QueryEscape(c.id), url.QueryEscape(c.secret))
+	}
+
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	start := c.now()
+	if err := do(c.client, req, &out); err != nil {
+		return "", fmt.Errorf("kimberlite: oauth token request: %w", err)
+	}
+	if out.Error != "" || out.AccessToken == "" {
+		return "", fmt.Errorf("kimberlite: oauth token request: %s %s", out.Error, out.Description)
+	}
+	c.token, c.expires = out.AccessToken, time.Time{}
+	if out.ExpiresIn > 0 {
+		lifetime := time.Duration(out.ExpiresIn) * time.Second
+		c.expires = start.Add(lifetime - min(refreshMargin, lifetime/2))
+	}
+	return c.token, nil
+}
+
+// Discover returns the token endpoint of the OpenID Connect issuer at
+// issuerURL, from its discovery document.
+func Discover(ctx context.Context, client *http.Client, issuerURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuerURL, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return "", err
+	}
+	var doc struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	if err := do(client, req, &doc); err != nil {
+		return "", fmt.Errorf("kimberlite: oidc discovery: %w", err)
+	}
+	if doc.TokenEndpoint == "" {
+		return "", fmt.Errorf("kimberlite: oidc discovery: %s has no token endpoint", issuerURL)
+	}
+	return doc.TokenEndpoint, nil
+}
+
+// do sends req and decodes its JSON response into out. Error responses
+// of the token endpoint are JSON too, and decoded unless unreadable.
+func do(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil || (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnauthorized) {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
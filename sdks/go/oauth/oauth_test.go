@@ -0,0 +1,67 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestClientCredentials(t *testing.T) {
+	issued := 0
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/.well-known/openid-configuration" {
+			fmt.Fprintf(w, `{"issuer":%q,"token_endpoint":%q}`, srv.URL, srv.URL+"/token")
+			return
+		}
+		id, secret, _ := r.BasicAuth()
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" || id != "svc" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		if r.Form.Get("scope") != "kimberlite admin" || r.Form.Get("audience") != "kmb" {
+			t.Errorf("form %v", r.Form)
+		}
+		issued++
+		fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"Bearer","expires_in":3600}`, issued)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	tokenURL, err := Discover(ctx, srv.Client(), srv.URL+"/")
+	if err != nil || tokenURL != srv.URL+"/token" {
+		t.Fatalf("Discover = %q, %v", tokenURL, err)
+	}
+	creds := NewClientCredentials(srv.Client(), tokenURL, "svc", "s3cret", WithScopes("kimberlite", "admin"), WithAudience("kmb"))
+	now := time.Now()
+	creds.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if tok, err := creds.Token(ctx); err != nil || tok != "tok-1" {
+			t.Fatalf("Token = %q, %v", tok, err)
+		}
+	}
+	now = now.Add(59 * time.Minute)
+	if tok, _ := creds.Token(ctx); tok != "tok-2" {
+		t.Fatalf("token near expiry not replaced: %q", tok)
+	}
+	if tok, _ := kimberlite.TokenProvider(creds.Token)(ctx); tok != "tok-2" {
+		t.Fatalf("cached token %q", tok)
+	}
+	if tok, _ := creds.cachedToken(ctx, "tok-2"); tok != "tok-3" {
+		t.Fatalf("rejected token returned: %q", tok)
+	}
+
+	bad := NewClientCredentials(srv.Client(), tokenURL, "svc", "wrong")
+	if _, err := bad.Token(ctx); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("expected invalid_client, got %v", err)
+	}
+}
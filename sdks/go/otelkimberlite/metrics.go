@@ -0,0 +1,125 @@
+package otelkimberlite
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Metric attribute keys.
+const (
+	KeyErrorType = attribute.Key("error.type")
+	KeyEvent     = attribute.Key("kimberlite.connection.event")
+)
+
+// Meter implements kimberlite.MetricsRecorder on an OpenTelemetry
+// MeterProvider. It records:
+//
+//	kimberlite.client.operation.duration   histogram (s)  {db.operation.name, error.type}
+//	kimberlite.client.errors               counter        {db.operation.name, error.type}
+//	kimberlite.client.sent_bytes           counter (By)   {db.operation.name}
+//	kimberlite.client.received_bytes       counter (By)   {db.operation.name}
+//	kimberlite.client.operations.in_flight updown counter
+//	kimberlite.client.connection.events    counter        {kimberlite.connection.event}
+//
+// Every measurement also carries kimberlite.tenant_id.
+type Meter struct {
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+	sent     metric.Int64Counter
+	received metric.Int64Counter
+	inFlight metric.Int64UpDownCounter
+	conn     metric.Int64Counter
+}
+
+var _ kimberlite.MetricsRecorder = (*Meter)(nil)
+
+// MeterOption configures a Meter.
+type MeterOption func(*meterConfig)
+
+type meterConfig struct {
+	provider metric.MeterProvider
+}
+
+// WithMeterProvider sets the provider instruments are created from.
+// The global provider is used by default.
+func WithMeterProvider(mp metric.MeterProvider) MeterOption {
+	return func(c *meterConfig) { c.provider = mp }
+}
+
+// NewMeter returns a Meter for use with kimberlite.WithMetrics.
+func NewMeter(opts ...MeterOption) (*Meter, error) {
+	var cfg meterConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.provider == nil {
+		cfg.provider = otel.GetMeterProvider()
+	}
+	meter := cfg.provider.Meter(ScopeName, metric.WithInstrumentationVersion(kimberlite.Version))
+
+	var (
+		m   Meter
+		err error
+	)
+	if m.duration, err = meter.Float64Histogram("kimberlite.client.operation.duration",
+		metric.WithUnit("s"), metric.WithDescription("Client operation latency.")); err != nil {
+		return nil, err
+	}
+	if m.errors, err = meter.Int64Counter("kimberlite.client.errors",
+		metric.WithDescription("Failed client operations.")); err != nil {
+		return nil, err
+	}
+	if m.sent, err = meter.Int64Counter("kimberlite.client.sent_bytes",
+		metric.WithUnit("By"), metric.WithDescription("Event payload bytes sent.")); err != nil {
+		return nil, err
+	}
+	if m.received, err = meter.Int64Counter("kimberlite.client.received_bytes",
+		metric.WithUnit("By"), metric.WithDescription("Event payload bytes received.")); err != nil {
+		return nil, err
+	}
+	if m.inFlight, err = meter.Int64UpDownCounter("kimberlite.client.operations.in_flight",
+		metric.WithDescription("Client operations in progress.")); err != nil {
+		return nil, err
+	}
+	if m.conn, err = meter.Int64Counter("kimberlite.client.connection.events",
+		metric.WithDescription("Connection lifecycle events.")); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// OperationStarted implements kimberlite.MetricsRecorder.
+func (m *Meter) OperationStarted(op *kimberlite.Operation) {
+	m.inFlight.Add(context.Background(), 1, metric.WithAttributes(KeyTenantID.Int64(int64(op.Tenant))))
+}
+
+// OperationDone implements kimberlite.MetricsRecorder.
+func (m *Meter) OperationDone(op *kimberlite.Operation, elapsed time.Duration, err error) {
+	ctx := context.Background()
+	tenant := KeyTenantID.Int64(int64(op.Tenant))
+	m.inFlight.Add(ctx, -1, metric.WithAttributes(tenant))
+
+	attrs := []attribute.KeyValue{tenant, KeyDBOperationName.String(op.Name)}
+	if op.BytesOut > 0 {
+		m.sent.Add(ctx, op.BytesOut, metric.WithAttributes(attrs...))
+	}
+	if op.BytesIn > 0 {
+		m.received.Add(ctx, op.BytesIn, metric.WithAttributes(attrs...))
+	}
+	if err != nil {
+		attrs = append(attrs, KeyErrorType.String(kimberlite.ErrorCode(err)))
+		m.errors.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+	m.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// ConnectionEvent implements kimberlite.MetricsRecorder.
+func (m *Meter) ConnectionEvent(tenant kimberlite.TenantID, event string) {
+	m.conn.Add(context.Background(), 1, metric.WithAttributes(KeyTenantID.Int64(int64(tenant)), KeyEvent.String(event)))
+}
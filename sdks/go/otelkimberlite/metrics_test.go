@@ -0,0 +1,46 @@
+package otelkimberlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestMeterRecordsOperations(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	m, err := NewMeter(WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := &kimberlite.Operation{Name: kimberlite.OpReadEvents, Tenant: 3, BytesIn: 128}
+	m.OperationStarted(op)
+	m.OperationDone(op, 20*time.Millisecond, kimberlite.ErrTimeout)
+	m.ConnectionEvent(3, kimberlite.ConnEventConnect)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			got[md.Name] = true
+		}
+	}
+	for _, name := range []string{
+		"kimberlite.client.operation.duration",
+		"kimberlite.client.errors",
+		"kimberlite.client.received_bytes",
+		"kimberlite.client.operations.in_flight",
+		"kimberlite.client.connection.events",
+	} {
+		if !got[name] {
+			t.Errorf("metric %s not recorded (got %v)", name, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package otelkimberlite instruments the Kimberlite Go client with
+// OpenTelemetry tracing and metrics:
+//
+//	client, err := kimberlite.Connect(addr,
+//	    kimberlite.WithTenant(1),
+//	    kimberlite.WithTracer(otelkimberlite.NewTracer()),
+//	)
+//
+// Every client operation becomes a client span carrying database
+// semantic attributes (db.system, db.operation.name, db.query.text,
+// db.collection.name, the tenant and stream IDs and row or event
+// counts). Envelopes appended with AppendEnvelopes carry the
+// W3C trace context in their metadata; consumers continue the trace
+// with Extract.
+//
+// NewMeter records operation latency, error counts by code, bytes in
+// and out, in-flight operations and connection events:
+//
+//	meter, err := otelkimberlite.NewMeter()
+//	client, err := kimberlite.Connect(addr, kimberlite.WithMetrics(meter))
+//
+// The client defines its own Tracer and MetricsRecorder interfaces;
+// only this module, with its own go.mod, depends on OpenTelemetry.
+package otelkimberlite
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// ScopeName is the instrumentation scope reported with every span.
+const ScopeName = "github.com/kimberlitedb/kimberlite-go/otelkimberlite"
+
+// Attribute keys. The database keys follow the OpenTelemetry semantic
+// conventions; the rest are Kimberlite-specific.
+const (
+	KeyDBSystem        = attribute.Key("db.system")
+	KeyDBOperationName = attribute.Key("db.operation.name")
+	KeyDBQueryText     = attribute.Key("db.query.text")
+	KeyDBCollection    = attribute.Key("db.collection.name")
+	KeyReturnedRows    = attribute.Key("db.response.returned_rows")
+	KeyRowsAffected    = attribute.Key("kimberlite.rows_affected")
+	KeyTenantID        = attribute.Key("kimberlite.tenant_id")
+	KeyStreamID        = attribute.Key("kimberlite.stream_id")
+	KeyEvents          = attribute.Key("kimberlite.events")
+	KeyBytesOut        = attribute.Key("kimberlite.bytes_out")
+	KeyBytesIn         = attribute.Key("kimberlite.bytes_in")
+	KeyCacheHit        = attribute.Key("kimberlite.cache_hit")
+)
+
+// Option configures a Tracer.
+type Option func(*Tracer)
+
+// WithTracerProvider sets the provider spans are created from. The
+// global provider is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *Tracer) { t.provider = tp }
+}
+
+// WithPropagator sets the propagator used to write trace context into
+// event metadata. The global propagator is used by default.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *Tracer) { t.propagator = p }
+}
+
+// WithoutQueryText omits db.query.text, for deployments where SQL text
+// itself is considered sensitive. Bound parameters are never recorded.
+func WithoutQueryText() Option {
+	return func(t *Tracer) { t.omitQueryText = true }
+}
+
+// Tracer implements kimberlite.Tracer and kimberlite.TracePropagator.
+type Tracer struct {
+	provider      trace.TracerProvider
+	propagator    propagation.TextMapPropagator
+	tracer        trace.Tracer
+	omitQueryText bool
+}
+
+var (
+	_ kimberlite.Tracer          = (*Tracer)(nil)
+	_ kimberlite.TracePropagator = (*Tracer)(nil)
+)
+
+// NewTracer returns a Tracer for use with kimberlite.WithTracer.
+func NewTracer(opts ...Option) *Tracer {
+	t := &Tracer{}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.provider == nil {
+		t.provider = otel.GetTracerProvider()
+	}
+	t.tracer = t.provider.Tracer(ScopeName, trace.WithInstrumentationVersion(kimberlite.Version))
+	return t
+}
+
+// Start implements kimberlite.Tracer.
+func (t *Tracer) Start(ctx context.Context, op *kimberlite.Operation) (context.Context, func(error)) {
+	attrs := []attribute.KeyValue{
+		KeyDBSystem.String("kimberlite"),
+		KeyDBOperationName.String(op.Name),
+		KeyTenantID.Int64(int64(op.Tenant)),
+	}
+	if op.Statement != "" && !t.omitQueryText {
+		attrs = append(attrs, KeyDBQueryText.String(op.Statement))
+	}
+	if op.StreamID != 0 {
+		attrs = append(attrs, KeyStreamID.Int64(int64(op.StreamID)))
+	}
+	if op.Table != "" {
+		attrs = append(attrs, KeyDBCollection.String(op.Table))
+	}
+	ctx, span := t.tracer.Start(ctx, "kimberlite."+op.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, func(err error) {
+		span.SetAttributes(resultAttributes(op)...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func resultAttributes(op *kimberlite.Operation) []attribute.KeyValue {
+	switch op.Name {
+	case kimberlite.OpQuery:
+		return []attribute.KeyValue{KeyReturnedRows.Int64(op.Rows)}
+	case kimberlite.OpExec:
+		return []attribute.KeyValue{KeyRowsAffected.Int64(op.Rows)}
+	case kimberlite.OpCreateStream:
+		return []attribute.KeyValue{KeyStreamID.Int64(int64(op.StreamID))}
+	case kimberlite.OpAppend:
+		return []attribute.KeyValue{KeyEvents.Int(op.Events), KeyBytesOut.Int64(op.BytesOut)}
+	case kimberlite.OpReadEvents:
+		return []attribute.KeyValue{
+			KeyEvents.Int(op.Events), KeyBytesIn.Int64(op.BytesIn), KeyCacheHit.Bool(op.CacheHit),
+		}
+	}
+	return nil
+}
+
+func (t *Tracer) textMapPropagator() propagation.TextMapPropagator {
+	if t.propagator != nil {
+		return t.propagator
+	}
+	return otel.GetTextMapPropagator()
+}
+
+// Inject implements kimberlite.TracePropagator, writing the trace
+// context of ctx (traceparent, tracestate) into event metadata.
+func (t *Tracer) Inject(ctx context.Context, metadata map[string]string) {
+	t.textMapPropagator().Inject(ctx, propagation.MapCarrier(metadata))
+}
+
+// Extract returns ctx carrying the trace context recorded in env's
+// metadata, so a consumer's spans join the producer's trace. It also
+// applies kimberlite.ContextFromEnvelope, so events appended while
+// handling env are correlated with it.
+func Extract(ctx context.Context, env kimberlite.Envelope) context.Context {
+	if env.Metadata != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(env.Metadata))
+	}
+	return kimberlite.ContextFromEnvelope(ctx, env)
+}
@@ -0,0 +1,65 @@
+package otelkimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestSpanAttributes(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tr := NewTracer(WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))))
+
+	op := &kimberlite.Operation{Name: kimberlite.OpQuery, Tenant: 7, Statement: "SELECT 1"}
+	_, end := tr.Start(context.Background(), op)
+	op.Rows = 3
+	end(errors.New("boom"))
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "kimberlite.Query" || s.SpanKind() != trace.SpanKindClient || s.Status().Code != codes.Error {
+		t.Fatalf("span = %s %v %v", s.Name(), s.SpanKind(), s.Status())
+	}
+	got := map[string]any{}
+	for _, kv := range s.Attributes() {
+		got[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if got["db.system"] != "kimberlite" || got["db.query.text"] != "SELECT 1" ||
+		got["kimberlite.tenant_id"] != int64(7) || got["db.response.returned_rows"] != int64(3) {
+		t.Fatalf("attributes = %v", got)
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tp := sdktrace.NewTracerProvider()
+	tr := NewTracer(WithTracerProvider(tp))
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "produce")
+	defer span.End()
+	meta := map[string]string{}
+	tr.Inject(ctx, meta)
+	if meta["traceparent"] == "" {
+		t.Fatalf("no traceparent injected: %v", meta)
+	}
+
+	consumer := Extract(context.Background(), kimberlite.Envelope{ID: "evt-1", Metadata: meta})
+	if got := trace.SpanContextFromContext(consumer).TraceID(); got != span.SpanContext().TraceID() {
+		t.Fatalf("trace ID = %v, want %v", got, span.SpanContext().TraceID())
+	}
+	if corr, _ := kimberlite.CorrelationFromContext(consumer); corr != "evt-1" {
+		t.Fatalf("correlation = %q", corr)
+	}
+}
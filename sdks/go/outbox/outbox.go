@@ -0,0 +1,342 @@
+// Package outbox implements the transactional outbox pattern for
+// applications that change their own database and append the matching
+// events to Kimberlite.
+//
+// Add writes an intent, an event and its target stream, to an outbox
+// table in the application's transaction, so the intent is stored if
+// and only if the transaction commits. A Relay then appends committed
+// intents to Kimberlite and deletes them:
+//
+//	box := outbox.New("kimberlite_outbox")
+//	tx, err := db.BeginTx(ctx, nil)
+//	// ... the application's own writes ...
+//	if _, err := box.Add(ctx, tx, admissions, kimberlite.Envelope{Type: "PatientAdmitted", Data: body}); err != nil {
+//	    return err
+//	}
+//	err = tx.Commit()
+//
+//	relay := box.NewRelay(db, client)
+//	err = relay.Run(ctx)
+//
+// Each intent is appended exactly once. Its envelope ID is its
+// idempotency key, sent with the append for the server to deduplicate
+// retries. The server only does so within a short window, so the relay
+// also checkpoints each stream's position after the intents it
+// appended, and before appending to a stream for the first time scans
+// the events appended since, deleting intents found there without
+// appending them again. Run one relay per outbox table.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+	"github.com/kimberlitedb/kimberlite-go/internal/sqlident"
+)
+
+// Execer runs SQL statements. *sql.DB, *sql.Conn and *sql.Tx
+// implement it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// DB is the database a Relay reads intents from. *sql.DB implements
+// it.
+type DB interface {
+	connect.SQLDB
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Outbox is an outbox table. Its columns are a text id primary key, a
+// bigint seq ordering intents, a bigint stream, and a text payload
+// holding the base64 encoding of the event's envelope.
+type Outbox struct {
+	table       string
+	placeholder func(n int) string
+	now         func() time.Time
+}
+
+// Option configures an Outbox.
+type Option func(*Outbox)
+
+// WithPlaceholder sets the placeholder of the nth parameter of the
+// outbox's statements, such as "?" for MySQL, which does not number
+// them like PostgreSQL. The relay's positions table uses it too.
+func WithPlaceholder(f func(n int) string) Option {
+	return func(o *Outbox) { o.placeholder = f }
+}
+
+// New returns the outbox stored in table. It panics if table, which
+// also names the relay's positions table, is not a plain or
+// schema-qualified identifier.
+func New(table string, opts ...Option) *Outbox {
+	if err := sqlident.Check("outbox table", table); err != nil {
+		panic("outbox: " + err.Error())
+	}
+	o := &Outbox{table: table, placeholder: func(n int) string { return "$" + strconv.Itoa(n) }, now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// CreateTable creates the outbox table, and the table of relay
+// positions named after it with a "_positions" suffix, unless they
+// exist.
+func (o *Outbox) CreateTable(ctx context.Context, db connect.SQLDB) error {
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+o.table+" (id TEXT NOT NULL PRIMARY KEY, seq BIGINT NOT NULL, stream BIGINT NOT NULL, payload TEXT NOT NULL)"); err != nil {
+		return err
+	}
+	return o.positions(db).CreateTable(ctx)
+}
+
+func (o *Outbox) positions(db connect.SQLDB) *connect.SQLCheckpoints {
+	return connect.NewSQLCheckpoints(db, o.table+"_positions", connect.WithPlaceholder(o.placeholder))
+}
+
+// Add records the intent to append env to stream, in the transaction
+// or connection tx, and returns its ID, generated if env has none.
+// Intents are relayed in the order they were added, as far as the
+// clocks of the processes adding them agree.
+func (o *Outbox) Add(ctx context.Context, tx Execer, stream kimberlite.StreamID, env kimberlite.Envelope) (string, error) {
+	if env.ID == "" {
+		env.ID = kimberlite.NewEventID()
+	}
+	data, err := env.Marshal()
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO "+o.table+" (id, seq, stream, payload) VALUES ("+o.placeholder(1)+", "+o.placeholder(2)+", "+o.placeholder(3)+", "+o.placeholder(4)+")",
+		env.ID, o.now().UnixNano(), int64(stream), base64.StdEncoding.EncodeToString(data))
+	if err != nil {
+		return "", err
+	}
+	return env.ID, nil
+}
+
+// Relay appends the intents of an outbox to Kimberlite.
+// It is not safe for concurrent use.
+type Relay struct {
+	outbox   *Outbox
+	db       DB
+	log      connect.EventLog
+	store    connect.CheckpointStore
+	batch    int
+	maxBytes uint64
+	interval time.Duration
+
+	scanned map[kimberlite.StreamID]bool
+}
+
+// RelayOption configures a Relay.
+type RelayOption func(*Relay)
+
+// WithCheckpoints stores the relay's per-stream positions in store
+// rather than in the outbox's positions table.
+func WithCheckpoints(store connect.CheckpointStore) RelayOption {
+	return func(r *Relay) { r.store = store }
+}
+
+// WithBatchSize sets how many intents each poll reads. The default is
+// 100.
+func WithBatchSize(n int) RelayOption {
+	return func(r *Relay) { r.batch = max(n, 1) }
+}
+
+// WithPollInterval sets how long Run waits after the outbox is empty
+// before reading it again. The default is one second.
+func WithPollInterval(d time.Duration) RelayOption {
+	return func(r *Relay) { r.interval = d }
+}
+
+// NewRelay returns a relay of the outbox in db to log.
+func (o *Outbox) NewRelay(db DB, log connect.EventLog, opts ...RelayOption) *Relay {
+	r := &Relay{
+		outbox:   o,
+		db:       db,
+		log:      log,
+		batch:    100,
+		maxBytes: 1 << 20,
+		interval: time.Second,
+		scanned:  make(map[kimberlite.StreamID]bool),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.store == nil {
+		r.store = o.positions(db)
+	}
+	return r
+}
+
+// Run relays intents until ctx is done or an error occurs. After an
+// error the relay can be run again.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.Poll(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// intent is a row of the outbox table.
+type intent struct {
+	id     string
+	stream kimberlite.StreamID
+	data   []byte
+}
+
+// Poll relays the next batch of intents and returns the number of
+// intents relayed, including those already appended before a crash.
+func (r *Relay) Poll(ctx context.Context) (int, error) {
+	intents, err := r.next(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var done int
+	for len(intents) > 0 {
+		// Consecutive intents for one stream are appended together.
+		n := 1
+		for n < len(intents) && intents[n].stream == intents[0].stream {
+			n++
+		}
+		if err := r.relay(ctx, intents[:n]); err != nil {
+			return done, err
+		}
+		done += n
+		intents = intents[n:]
+	}
+	return done, nil
+}
+
+func (r *Relay) next(ctx context.Context) ([]intent, error) {
+	o := r.outbox
+	rows, err := r.db.QueryContext(ctx, "SELECT id, stream, payload FROM "+o.table+" ORDER BY seq, id LIMIT "+strconv.Itoa(r.batch))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var intents []intent
+	for rows.Next() {
+		var (
+			it      intent
+			stream  int64
+			payload string
+		)
+		if err := rows.Scan(&it.id, &stream, &payload); err != nil {
+			return nil, err
+		}
+		if it.data, err = base64.StdEncoding.DecodeString(payload); err != nil {
+			return nil, fmt.Errorf("kimberlite: outbox intent %s: %w", it.id, err)
+		}
+		it.stream = kimberlite.StreamID(stream)
+		intents = append(intents, it)
+	}
+	return intents, rows.Err()
+}
+
+// relay appends intents, all for one stream, that it does not find
+// among the stream's events since its checkpoint, and deletes them.
+func (r *Relay) relay(ctx context.Context, intents []intent) error {
+	stream := intents[0].stream
+	name := "outbox/" + strconv.FormatUint(uint64(stream), 10)
+	var pending [][]byte
+	var ids []string
+	if !r.scanned[stream] {
+		appended, err := r.scan(ctx, name, stream)
+		if err != nil {
+			return err
+		}
+		for _, it := range intents {
+			if appended[it.id] {
+				if err := r.delete(ctx, []string{it.id}); err != nil {
+					return err
+				}
+				continue
+			}
+			pending, ids = append(pending, it.data), append(ids, it.id)
+		}
+		r.scanned[stream] = true
+	} else {
+		for _, it := range intents {
+			pending, ids = append(pending, it.data), append(ids, it.id)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	audit, _ := kimberlite.AuditFromContext(ctx)
+	audit.IdempotencyKey = ids[0]
+	if len(ids) > 1 {
+		audit.IdempotencyKey += ".." + ids[len(ids)-1]
+	}
+	off, err := r.log.AppendContext(kimberlite.WithAudit(ctx, audit), stream, pending...)
+	if err != nil {
+		return fmt.Errorf("kimberlite: relay outbox intents to stream %d: %w", stream, err)
+	}
+	// Deleting before checkpointing keeps an appended intent visible to
+	// the next scan until it is deleted.
+	if err := r.delete(ctx, ids); err != nil {
+		return err
+	}
+	if err := r.store.Save(ctx, name, off+kimberlite.Offset(len(pending))); err != nil {
+		return fmt.Errorf("kimberlite: save outbox position for stream %d: %w", stream, err)
+	}
+	return nil
+}
+
+// scan returns the IDs of the events appended to stream since its
+// checkpoint.
+func (r *Relay) scan(ctx context.Context, name string, stream kimberlite.StreamID) (map[string]bool, error) {
+	from, _, err := r.store.Load(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: load outbox position for stream %d: %w", stream, err)
+	}
+	appended := make(map[string]bool)
+	for {
+		events, err := r.log.ReadEventsContext(ctx, stream, from, r.maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			return appended, nil
+		}
+		for _, ev := range events {
+			if env, err := ev.Envelope(); err == nil && env.ID != "" {
+				appended[env.ID] = true
+			}
+		}
+		from = events[len(events)-1].Offset + 1
+	}
+}
+
+func (r *Relay) delete(ctx context.Context, ids []string) error {
+	o := r.outbox
+	marks := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		marks[i], args[i] = o.placeholder(i+1), id
+	}
+	_, err := r.db.ExecContext(ctx, "DELETE FROM "+o.table+" WHERE id IN ("+strings.Join(marks, ", ")+")", args...)
+	if err != nil {
+		return fmt.Errorf("kimberlite: delete relayed outbox intents: %w", err)
+	}
+	return nil
+}
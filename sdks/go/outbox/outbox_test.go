@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+	"github.com/kimberlitedb/kimberlite-go/internal/sqltest"
+)
+
+// outboxTable answers the statements of Outbox and Relay, holding the
+// seq, stream and payload of each intent by ID.
+type outboxTable struct {
+	rows       map[string][]driver.Value
+	failDelete bool
+}
+
+func (tb *outboxTable) Exec(query string, args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO outbox (id, seq, stream, payload) VALUES"):
+		tb.rows[args[0].(string)] = args[1:]
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "DELETE FROM outbox WHERE id IN ("):
+		if tb.failDelete {
+			tb.failDelete = false
+			return nil, errors.New("connection reset")
+		}
+		for _, id := range args {
+			delete(tb.rows, id.(string))
+		}
+		return driver.RowsAffected(len(args)), nil
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (tb *outboxTable) Query(query string, _ []driver.Value) ([]string, [][]driver.Value, error) {
+	if !strings.HasPrefix(query, "SELECT id, stream, payload FROM outbox ORDER BY seq, id LIMIT ") {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	ids := make([]string, 0, len(tb.rows))
+	for id := range tb.rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return tb.rows[ids[i]][0].(int64) < tb.rows[ids[j]][0].(int64) })
+	limit, _ := strconv.Atoi(query[strings.LastIndex(query, " ")+1:])
+	var rows [][]driver.Value
+	for _, id := range ids[:min(limit, len(ids))] {
+		r := tb.rows[id]
+		rows = append(rows, []driver.Value{id, r[1], r[2]})
+	}
+	return []string{"id", "stream", "payload"}, rows, nil
+}
+
+type fakeLog struct {
+	events map[kimberlite.StreamID][][]byte
+	keys   []string
+}
+
+func (l *fakeLog) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, _ uint64) ([]kimberlite.Event, error) {
+	var out []kimberlite.Event
+	for i := int(from); i < len(l.events[id]); i++ {
+		out = append(out, kimberlite.Event{StreamID: id, Offset: kimberlite.Offset(i), Data: l.events[id][i]})
+	}
+	return out, nil
+}
+
+func (l *fakeLog) AppendContext(ctx context.Context, id kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error) {
+	audit, _ := kimberlite.AuditFromContext(ctx)
+	l.keys = append(l.keys, audit.IdempotencyKey)
+	first := kimberlite.Offset(len(l.events[id]))
+	l.events[id] = append(l.events[id], events...)
+	return first, nil
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	tb := &outboxTable{rows: map[string][]driver.Value{}}
+	db := sqltest.Open(t, tb)
+
+	box := New("outbox")
+	seq := time.Unix(0, 0)
+	box.now = func() time.Time { seq = seq.Add(time.Nanosecond); return seq }
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []kimberlite.StreamID{1, 1, 2} {
+		if _, err := box.Add(ctx, tx, target, kimberlite.Envelope{Type: "Admitted", Data: []byte("p")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, _ := box.Add(ctx, tx, 1, kimberlite.Envelope{ID: "last", Data: []byte("p")})
+	if id != "last" {
+		t.Fatalf("id = %q", id)
+	}
+	tx.Commit()
+
+	log := &fakeLog{events: map[kimberlite.StreamID][][]byte{}}
+	store := connect.NewMemoryCheckpoints()
+	relay := box.NewRelay(db, log, WithCheckpoints(store), WithBatchSize(2))
+	if n, err := relay.Poll(ctx); err != nil || n != 2 {
+		t.Fatalf("poll: %d, %v", n, err)
+	}
+	if len(log.events[1]) != 2 || len(log.keys) != 1 || !strings.Contains(log.keys[0], "..") {
+		t.Fatalf("appended %d events with keys %q", len(log.events[1]), log.keys)
+	}
+
+	// The relay crashes after appending, before deleting the intent.
+	tb.failDelete = true
+	if _, err := relay.Poll(ctx); err == nil {
+		t.Fatal("poll with failing delete succeeded")
+	}
+	if len(log.events[2]) != 1 || len(tb.rows) != 2 {
+		t.Fatalf("%d events in stream 2, %d intents left", len(log.events[2]), len(tb.rows))
+	}
+	// A new relay finds it among the stream's events and does not
+	// append it again.
+	relay = box.NewRelay(db, log, WithCheckpoints(store), WithBatchSize(2))
+	if n, err := relay.Poll(ctx); err != nil || n != 2 {
+		t.Fatalf("poll after crash: %d, %v", n, err)
+	}
+	if len(log.events[2]) != 1 || len(log.events[1]) != 3 || len(tb.rows) != 0 {
+		t.Fatalf("streams %d, %d events, %d intents left", len(log.events[1]), len(log.events[2]), len(tb.rows))
+	}
+	env, _ := kimberlite.UnmarshalEnvelope(log.events[1][2])
+	if env.ID != "last" || log.keys[len(log.keys)-1] != "last" {
+		t.Fatalf("last event %q, key %q", env.ID, log.keys[len(log.keys)-1])
+	}
+	if pos, _, _ := store.Load(ctx, "outbox/1"); pos != 3 {
+		t.Fatalf("stream 1 position %d", pos)
+	}
+	if n, err := relay.Poll(ctx); err != nil || n != 0 {
+		t.Fatalf("poll of empty outbox: %d, %v", n, err)
+	}
+}
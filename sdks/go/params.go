@@ -0,0 +1,34 @@
+package kimberlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// wireText renders a parameter the wire protocol has no native slot
+// for as its text encoding. The server coerces the text to the column
+// type, mirroring how it renders those types in results.
+func (v Value) wireText() (string, error) {
+	switch v.Type {
+	case ValueTypeText:
+		return v.AsText(), nil
+	case ValueTypeFloat:
+		// The shortest text that parses back to the same float64.
+		return strconv.FormatFloat(v.AsFloat(), 'g', -1, 64), nil
+	case ValueTypeJSON:
+		return string(v.AsRawJSON()), nil
+	case ValueTypeArray, ValueTypeRecord:
+		// Arrays outside ANY(...) and records are bound as a JSON
+		// document.
+		b, err := json.Marshal(v.jsonEncodable())
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case ValueTypeInterval:
+		return v.AsInterval().String(), nil
+	default:
+		return "", fmt.Errorf("%s values cannot be bound", v.Type)
+	}
+}
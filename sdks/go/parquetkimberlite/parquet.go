@@ -0,0 +1,215 @@
+// Package parquetkimberlite exports Kimberlite query results and
+// streams as Apache Parquet, for analysis in Athena, Spark, DuckDB and
+// other engines without a custom ETL step:
+//
+//	result, err := client.QueryContext(ctx, "SELECT * FROM admissions")
+//	err = parquetkimberlite.WriteParquet(f, result)
+//
+//	n, err := parquetkimberlite.WriteStream(ctx, f, client, admissions, 0)
+//
+// Output is Snappy-compressed unless a parquet.Compression option says
+// otherwise.
+//
+// parquet-go and its compression codecs are large; keeping them in
+// this module spares programs that never export Parquet from
+// building them.
+package parquetkimberlite
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// WriteParquet writes r to w as a Parquet file with one optional column
+// per result column. Column types follow r.ColumnTypes():
+//
+//	BIGINT, INTEGER, INT, SMALLINT, TINYINT   INT64
+//	REAL, DOUBLE, FLOAT                       DOUBLE
+//	BOOLEAN, BOOL                             BOOLEAN
+//	BYTES, BYTEA, BLOB                        BYTE_ARRAY
+//	TIMESTAMP, TIMESTAMPTZ                    INT64 TIMESTAMP(MICROS, UTC)
+//	JSON                                      BYTE_ARRAY JSON
+//	DECIMAL(p,s), NUMERIC(p,s) with p <= 18   INT64 DECIMAL(p,s)
+//	anything else                             BYTE_ARRAY STRING
+//
+// Values of the string columns are rendered as they travel on the wire;
+// arrays and records as JSON. Declare column types with
+// QueryResult.ApplyColumnTypes for results whose types cannot be
+// inferred from their values.
+func WriteParquet(w io.Writer, r *kimberlite.QueryResult, opts ...parquet.WriterOption) error {
+	types := r.ColumnTypes()
+	group := make(parquet.Group, len(types))
+	kinds := make([]columnKind, len(types))
+	for i, ct := range types {
+		if _, dup := group[ct.Name]; dup {
+			return fmt.Errorf("kimberlite: duplicate column %q", ct.Name)
+		}
+		var node parquet.Node
+		kinds[i], node = columnFor(ct)
+		group[ct.Name] = parquet.Optional(node)
+	}
+	schema := parquet.NewSchema("kimberlite", group)
+
+	leaf := make([]int, len(types))
+	for i, ct := range types {
+		col, _ := schema.Lookup(ct.Name)
+		leaf[i] = col.ColumnIndex
+	}
+
+	pw := parquet.NewWriter(w, append([]parquet.WriterOption{schema, parquet.Compression(&parquet.Snappy)}, opts...)...)
+	rows := make([]parquet.Row, 0, min(len(r.Rows), 1024))
+	flush := func() error {
+		_, err := pw.WriteRows(rows)
+		rows = rows[:0]
+		return err
+	}
+	for i := range r.Rows {
+		src := r.Row(i)
+		row := make(parquet.Row, len(types))
+		for j := range types {
+			v, err := kinds[j].value(src.Value(j), types[j])
+			if err != nil {
+				return fmt.Errorf("kimberlite: row %d column %q: %w", i, types[j].Name, err)
+			}
+			row[leaf[j]] = v.Level(0, definitionLevel(v), leaf[j])
+		}
+		if rows = append(rows, row); len(rows) == cap(rows) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+func definitionLevel(v parquet.Value) int {
+	if v.IsNull() {
+		return 0
+	}
+	return 1
+}
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt
+	kindDouble
+	kindBool
+	kindBytes
+	kindTimestamp
+	kindJSON
+	kindDecimal
+)
+
+func columnFor(ct kimberlite.ColumnType) (columnKind, parquet.Node) {
+	switch ct.DatabaseTypeName {
+	case "BIGINT", "INTEGER", "INT", "SMALLINT", "TINYINT":
+		return kindInt, parquet.Int(64)
+	case "REAL", "DOUBLE", "FLOAT":
+		return kindDouble, parquet.Leaf(parquet.DoubleType)
+	case "BOOLEAN", "BOOL":
+		return kindBool, parquet.Leaf(parquet.BooleanType)
+	case "BYTES", "BYTEA", "BLOB":
+		return kindBytes, parquet.Leaf(parquet.ByteArrayType)
+	case "TIMESTAMP", "TIMESTAMPTZ":
+		return kindTimestamp, parquet.Timestamp(parquet.Microsecond)
+	case "JSON":
+		return kindJSON, parquet.JSON()
+	case "DECIMAL", "NUMERIC":
+		if ct.HasPrecisionScale && ct.Precision > 0 && ct.Precision <= 18 {
+			return kindDecimal, parquet.Decimal(int(ct.Scale), int(ct.Precision), parquet.Int64Type)
+		}
+	}
+	return kindString, parquet.String()
+}
+
+func (k columnKind) value(v kimberlite.Value, ct kimberlite.ColumnType) (parquet.Value, error) {
+	if v.IsNull() {
+		return parquet.NullValue(), nil
+	}
+	switch k {
+	case kindInt:
+		if n, ok := v.IntOK(); ok {
+			return parquet.Int64Value(n), nil
+		}
+	case kindDouble:
+		if f, ok := v.FloatOK(); ok {
+			return parquet.DoubleValue(f), nil
+		}
+		if n, ok := v.IntOK(); ok {
+			return parquet.DoubleValue(float64(n)), nil
+		}
+	case kindBool:
+		if b, ok := v.BoolOK(); ok {
+			return parquet.BooleanValue(b), nil
+		}
+	case kindBytes:
+		b, err := v.DecodeBytes()
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.ByteArrayValue(b), nil
+	case kindTimestamp:
+		if t, ok := v.TimestampOK(); ok {
+			return parquet.Int64Value(t.UnixMicro()), nil
+		}
+	case kindDecimal:
+		s, err := text(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		n, err := unscaled(s, ct.Scale)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.Int64Value(n), nil
+	case kindJSON, kindString:
+		s, err := text(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.ByteArrayValue([]byte(s)), nil
+	}
+	return parquet.Value{}, fmt.Errorf("cannot store %s value in %s column", v.Type, ct.DatabaseTypeName)
+}
+
+// text renders v the way database/sql sees it.
+func text(v kimberlite.Value) (string, error) {
+	dv, err := v.Value()
+	if err != nil {
+		return "", err
+	}
+	switch t := dv.(type) {
+	case string:
+		return t, nil
+	case []byte:
+		return string(t), nil
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	return fmt.Sprint(dv), nil
+}
+
+// unscaled returns the decimal s as an integer count of 10^-scale.
+func unscaled(s string, scale int64) (int64, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid decimal %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return 0, fmt.Errorf("decimal %q does not fit DECIMAL with scale %d", s, scale)
+	}
+	return r.Num().Int64(), nil
+}
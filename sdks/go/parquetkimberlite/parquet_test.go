@@ -0,0 +1,107 @@
+package parquetkimberlite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestWriteParquet(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := &kimberlite.QueryResult{
+		Columns: []string{"id", "name", "score", "admitted_at", "fee", "tags"},
+		Rows: []map[string]kimberlite.Value{
+			{
+				"id": kimberlite.NewInt(1), "name": kimberlite.NewText("Ada"), "score": kimberlite.NewFloat(9.5),
+				"admitted_at": kimberlite.NewTimestamp(at), "fee": kimberlite.NewText("12.30"),
+				"tags": kimberlite.NewArray(kimberlite.NewText("icu")),
+			},
+			{
+				"id": kimberlite.NewInt(2), "name": kimberlite.NewNull(), "score": kimberlite.NewNull(),
+				"admitted_at": kimberlite.NewNull(), "fee": kimberlite.NewNull(), "tags": kimberlite.NewNull(),
+			},
+		},
+	}
+	r.ApplyColumnTypes([]kimberlite.ColumnType{{Name: "fee", DatabaseTypeName: "DECIMAL", Precision: 10, Scale: 2, HasPrecisionScale: true}})
+
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+
+	type row struct {
+		ID         *int64   `parquet:"id"`
+		Name       *string  `parquet:"name"`
+		Score      *float64 `parquet:"score"`
+		AdmittedAt *int64   `parquet:"admitted_at"`
+		Fee        *int64   `parquet:"fee"`
+		Tags       *string  `parquet:"tags"`
+	}
+	rows, err := parquet.Read[row](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows", len(rows))
+	}
+	got := rows[0]
+	if *got.ID != 1 || *got.Name != "Ada" || *got.Score != 9.5 || *got.AdmittedAt != at.UnixMicro() || *got.Fee != 1230 || *got.Tags != `["icu"]` {
+		t.Fatalf("row 0 = %+v", got)
+	}
+	if rows[1].Name != nil || rows[1].Fee != nil || *rows[1].ID != 2 {
+		t.Fatalf("row 1 = %+v", rows[1])
+	}
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	col, _ := f.Schema().Lookup("fee")
+	if lt := col.Node.Type().LogicalType(); lt == nil || lt.Decimal == nil || lt.Decimal.Scale != 2 {
+		t.Fatalf("fee logical type = %v", lt)
+	}
+}
+
+type memSource [][]byte
+
+func (m memSource) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, _ uint64) ([]kimberlite.Event, error) {
+	var out []kimberlite.Event
+	for i := int(from); i < len(m) && len(out) < 2; i++ {
+		out = append(out, kimberlite.Event{StreamID: id, Offset: kimberlite.Offset(i), Data: m[i], Timestamp: time.Unix(int64(i), 0)})
+	}
+	return out, nil
+}
+
+func TestWriteStream(t *testing.T) {
+	env, err := kimberlite.Envelope{ID: "e1", Type: "Admitted", Metadata: map[string]string{"ward": "icu"}, Data: []byte(`{"n":1}`)}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := memSource{env, []byte("raw"), []byte(`[1]`)}
+
+	var buf bytes.Buffer
+	n, err := WriteStream(context.Background(), &buf, src, 4, 0)
+	if err != nil || n != 3 {
+		t.Fatalf("WriteStream = %d, %v", n, err)
+	}
+	rows, err := parquet.Read[EventRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var md map[string]string
+	if err := json.Unmarshal([]byte(rows[0].Metadata), &md); err != nil || md["ward"] != "icu" {
+		t.Fatalf("metadata = %q", rows[0].Metadata)
+	}
+	if rows[0].EventType != "Admitted" || rows[0].DataJSON != `{"n":1}` || rows[0].StreamID != 4 {
+		t.Fatalf("row 0 = %+v", rows[0])
+	}
+	if string(rows[1].Data) != "raw" || rows[1].DataJSON != "" || rows[2].Offset != 2 || rows[2].DataJSON != "[1]" {
+		t.Fatalf("rows = %+v", rows[1:])
+	}
+}
@@ -0,0 +1,92 @@
+package parquetkimberlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// EventRow is the Parquet schema written by WriteStream: one row per
+// event, with envelope fields broken out into columns.
+type EventRow struct {
+	StreamID      uint64    `parquet:"stream_id"`
+	Offset        uint64    `parquet:"offset"`
+	Timestamp     time.Time `parquet:"timestamp,timestamp(microsecond)"`
+	EventID       string    `parquet:"event_id,optional"`
+	EventType     string    `parquet:"event_type,optional"`
+	CorrelationID string    `parquet:"correlation_id,optional"`
+	CausationID   string    `parquet:"causation_id,optional"`
+	// Metadata is the envelope metadata as a JSON object.
+	Metadata string `parquet:"metadata,optional,json"`
+	// Data is the payload; DataJSON repeats it when it is valid JSON,
+	// so query engines can parse it without a binary-to-text cast.
+	Data     []byte `parquet:"data"`
+	DataJSON string `parquet:"data_json,optional,json"`
+}
+
+// WriteStream writes the events of stream from offset from to its
+// current end to w as a Parquet file of EventRow, and returns the
+// number of events written.
+func WriteStream(ctx context.Context, w io.Writer, src connect.EventSource, stream kimberlite.StreamID, from kimberlite.Offset, opts ...parquet.WriterOption) (int, error) {
+	pw := parquet.NewGenericWriter[EventRow](w, append([]parquet.WriterOption{parquet.Compression(&parquet.Snappy)}, opts...)...)
+	var total int
+	for {
+		events, err := src.ReadEventsContext(ctx, stream, from, 1<<20)
+		if err != nil {
+			return total, err
+		}
+		if len(events) == 0 {
+			break
+		}
+		rows := make([]EventRow, len(events))
+		for i, ev := range events {
+			if rows[i], err = eventRow(ev); err != nil {
+				return total, err
+			}
+		}
+		if _, err := pw.Write(rows); err != nil {
+			return total, err
+		}
+		total += len(rows)
+		from = events[len(events)-1].Offset + 1
+	}
+	return total, pw.Close()
+}
+
+func eventRow(ev kimberlite.Event) (EventRow, error) {
+	env, err := kimberlite.UnmarshalEnvelope(ev.Data)
+	if err != nil {
+		return EventRow{}, fmt.Errorf("kimberlite: stream %d offset %d: %w", ev.StreamID, ev.Offset, err)
+	}
+	row := EventRow{
+		StreamID:      uint64(ev.StreamID),
+		Offset:        uint64(ev.Offset),
+		Timestamp:     ev.Timestamp.UTC(),
+		EventID:       env.ID,
+		EventType:     env.Type,
+		CorrelationID: env.CorrelationID,
+		CausationID:   env.CausationID,
+		Data:          env.Data,
+	}
+	if row.Data == nil {
+		row.Data = []byte{}
+	}
+	if len(env.Metadata) > 0 {
+		md, err := json.Marshal(env.Metadata)
+		if err != nil {
+			return EventRow{}, err
+		}
+		row.Metadata = string(md)
+	}
+	if json.Valid(env.Data) {
+		row.DataJSON = string(env.Data)
+	}
+	return row, nil
+}
@@ -0,0 +1,196 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A PIIGuard scans events appended to Public and Internal streams for
+// apparent personal data, such as an SSN pasted into a free-text field
+// of an analytics event, before it reaches the log for good:
+//
+//	guard := kimberlite.NewPIIGuard(nil, kimberlite.BlockPII())
+//	client, err := kimberlite.Connect(addr, kimberlite.WithPIIGuard(guard))
+//
+// Streams of Confidential and Restricted class are expected to hold
+// such data and are not scanned. Streams whose class the guard does
+// not know are scanned; streams created through the client are
+// recorded automatically, and others can be set with SetStreamClass.
+
+// ErrPIIDetected is returned (wrapped in a *PIIError) when a PIIGuard
+// blocks an append.
+var ErrPIIDetected = errors.New("kimberlite: PII detected")
+
+// PIIKind names a kind of personal data.
+type PIIKind string
+
+// Kinds found by the default detector.
+const (
+	PIISSN        PIIKind = "ssn"
+	PIICreditCard PIIKind = "credit_card"
+)
+
+// PIIFinding locates apparent personal data in an event payload. It
+// holds byte offsets rather than the match, so findings can be logged.
+type PIIFinding struct {
+	Kind       PIIKind
+	Start, End int
+}
+
+// Detector finds apparent personal data in event payloads.
+type Detector interface {
+	Detect(data []byte) []PIIFinding
+}
+
+// PIIError describes the personal data found in an append.
+type PIIError struct {
+	StreamID StreamID
+	// Event is the index of the offending event in the append.
+	Event    int
+	Findings []PIIFinding
+}
+
+func (e *PIIError) Error() string {
+	return fmt.Sprintf("kimberlite: event %d for stream %d contains apparent PII (%s)", e.Event, e.StreamID, strings.Join(e.kinds(), ", "))
+}
+
+// kinds returns the distinct kinds of the findings, in order.
+func (e *PIIError) kinds() []string {
+	var out []string
+	seen := make(map[PIIKind]bool)
+	for _, f := range e.Findings {
+		if !seen[f.Kind] {
+			seen[f.Kind] = true
+			out = append(out, string(f.Kind))
+		}
+	}
+	return out
+}
+
+// Unwrap makes errors.Is(err, ErrPIIDetected) hold.
+func (e *PIIError) Unwrap() error { return ErrPIIDetected }
+
+var (
+	ssnPattern  = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+	cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// RegexDetector is the default Detector. It finds SSNs written as
+// 123-45-6789 and card numbers of 13 to 19 digits, optionally grouped
+// by spaces or dashes, that pass the Luhn check.
+type RegexDetector struct{}
+
+var _ Detector = RegexDetector{}
+
+// Detect implements Detector.
+func (RegexDetector) Detect(data []byte) []PIIFinding {
+	var out []PIIFinding
+	for _, m := range ssnPattern.FindAllSubmatchIndex(data, -1) {
+		area, group, serial := string(data[m[2]:m[3]]), string(data[m[4]:m[5]]), string(data[m[6]:m[7]])
+		if area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+			continue
+		}
+		out = append(out, PIIFinding{Kind: PIISSN, Start: m[0], End: m[1]})
+	}
+	for _, m := range cardPattern.FindAllIndex(data, -1) {
+		if luhn(data[m[0]:m[1]]) {
+			out = append(out, PIIFinding{Kind: PIICreditCard, Start: m[0], End: m[1]})
+		}
+	}
+	return out
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum.
+func luhn(s []byte) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// PIIGuard scans appended events with a Detector.
+type PIIGuard struct {
+	detector Detector
+	block    bool
+	onFlag   func(ctx context.Context, err *PIIError)
+	streams  streamClasses
+}
+
+// PIIOption configures a PIIGuard.
+type PIIOption func(*PIIGuard)
+
+// BlockPII makes the guard fail appends containing findings with a
+// *PIIError. By default they are only flagged.
+func BlockPII() PIIOption {
+	return func(g *PIIGuard) { g.block = true }
+}
+
+// OnPII calls fn for every event with findings, blocked or not. The
+// client also logs them as warnings to its logger.
+func OnPII(fn func(ctx context.Context, err *PIIError)) PIIOption {
+	return func(g *PIIGuard) { g.onFlag = fn }
+}
+
+// NewPIIGuard returns a guard scanning with detector, or with a
+// RegexDetector if detector is nil.
+func NewPIIGuard(detector Detector, opts ...PIIOption) *PIIGuard {
+	if detector == nil {
+		detector = RegexDetector{}
+	}
+	g := &PIIGuard{detector: detector}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// SetStreamClass records the data class of stream id, so events
+// appended to Confidential and Restricted streams, or declaring those
+// classes, are not scanned.
+func (g *PIIGuard) SetStreamClass(id StreamID, class DataClass) {
+	g.streams.set(id, class)
+}
+
+// WithPIIGuard makes the client scan appended events with g.
+func WithPIIGuard(g *PIIGuard) Option {
+	return func(c *Client) { c.pii = g }
+}
+
+// inspect scans events bound for stream and returns the flagged
+// events, or the first of them as an error if the guard blocks.
+func (g *PIIGuard) inspect(ctx context.Context, stream StreamID, events [][]byte) ([]*PIIError, error) {
+	streamClass, streamKnown := g.streams.get(stream)
+	var flagged []*PIIError
+	for i, data := range events {
+		if class, known := eventClass(data, streamClass, streamKnown); known && class >= DataClassConfidential {
+			continue
+		}
+		findings := g.detector.Detect(data)
+		if len(findings) == 0 {
+			continue
+		}
+		perr := &PIIError{StreamID: stream, Event: i, Findings: findings}
+		if g.onFlag != nil {
+			g.onFlag(ctx, perr)
+		}
+		if g.block {
+			return nil, perr
+		}
+		flagged = append(flagged, perr)
+	}
+	return flagged, nil
+}
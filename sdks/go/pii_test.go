@@ -0,0 +1,66 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRegexDetector(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []PIIKind
+	}{
+		{`{"note":"ssn 123-45-6789"}`, []PIIKind{PIISSN}},
+		{`{"card":"4111 1111 1111 1111"}`, []PIIKind{PIICreditCard}},
+		{`{"card":"4111-1111-1111-1112"}`, nil},
+		{`{"ssn":"000-12-3456","order":1234567890123}`, nil},
+		{`patient 457-55-5462 paid with 5500005555555559`, []PIIKind{PIISSN, PIICreditCard}},
+	}
+	for _, tt := range tests {
+		got := RegexDetector{}.Detect([]byte(tt.in))
+		if len(got) != len(tt.want) {
+			t.Errorf("Detect(%s) = %+v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for i, f := range got {
+			if f.Kind != tt.want[i] {
+				t.Errorf("Detect(%s)[%d] = %s, want %s", tt.in, i, f.Kind, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestPIIGuard(t *testing.T) {
+	ctx := context.Background()
+	var logs bytes.Buffer
+	var seen []*PIIError
+	guard := NewPIIGuard(nil, BlockPII(), OnPII(func(_ context.Context, err *PIIError) { seen = append(seen, err) }))
+	guard.SetStreamClass(1, DataClassPublic)
+	guard.SetStreamClass(2, DataClassRestricted)
+	c := &Client{pii: guard, logger: slog.New(slog.NewTextHandler(&logs, nil))}
+
+	events := [][]byte{[]byte("ok"), []byte("ssn 123-45-6789")}
+	_, err := c.AppendContext(ctx, 1, events...)
+	var perr *PIIError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrPIIDetected) || perr.Event != 1 || len(seen) != 1 {
+		t.Fatalf("expected blocked append, got %v", err)
+	}
+	if strings.Contains(logs.String(), "6789") || !strings.Contains(logs.String(), "kinds=ssn") {
+		t.Fatalf("log: %s", logs.String())
+	}
+	if flagged, err := guard.inspect(ctx, 2, events); err != nil || flagged != nil {
+		t.Fatalf("restricted stream scanned: %v", err)
+	}
+	if _, err := guard.inspect(ctx, 3, events); err == nil {
+		t.Fatal("stream of unknown class not scanned")
+	}
+
+	flagOnly := NewPIIGuard(nil)
+	if flagged, err := flagOnly.inspect(ctx, 1, events); err != nil || len(flagged) != 1 {
+		t.Fatalf("flag only: %v, %v", flagged, err)
+	}
+}
@@ -0,0 +1,118 @@
+package kimberlite
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Statement timeouts and priorities are set per call through the
+// context, so they reach statements made through database/sql and the
+// other packages built on the client as well:
+//
+//	ctx := kimberlite.WithPriority(ctx, kimberlite.PriorityLow)
+//	ctx = kimberlite.WithStatementTimeout(ctx, 30*time.Second)
+//	rows, err := client.QueryContext(ctx, analystSQL)
+//
+// The server runs statements in arrival order and knows neither: both
+// are enforced by the client, on the statements it sends.
+
+// Priority is a hint of how urgent a statement is.
+type Priority int
+
+const (
+	// PriorityNormal is the priority of statements made with none set.
+	PriorityNormal Priority = iota
+	// PriorityLow marks statements that may wait, such as ad-hoc and
+	// reporting queries. WithLowPriorityLimit bounds how many run at
+	// once.
+	PriorityLow
+)
+
+func (p Priority) String() string {
+	if p == PriorityLow {
+		return "low"
+	}
+	return "normal"
+}
+
+type priorityKey struct{}
+
+type statementTimeoutKey struct{}
+
+// WithPriority returns a derived context whose Query and Exec
+// statements have priority p.
+func WithPriority(parent context.Context, p Priority) context.Context {
+	return context.WithValue(parent, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority of statements made under
+// ctx.
+func PriorityFromContext(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// WithStatementTimeout returns a derived context under which each
+// Query and Exec statement has d to complete, counted from the call,
+// including any wait for a low-priority slot. Unlike a deadline on the
+// context itself, the timeout applies to every statement anew.
+//
+// A query that times out returns at once, as a cancelled one does; an
+// Exec times out only while it waits to be sent.
+func WithStatementTimeout(parent context.Context, d time.Duration) context.Context {
+	return context.WithValue(parent, statementTimeoutKey{}, d)
+}
+
+// WithLowPriorityLimit bounds to n the PriorityLow statements the
+// client runs at once; further ones wait for a slot, in no particular
+// order, until their context is done. A query abandoned by its caller
+// holds its slot until the server has answered it. Statements of
+// normal priority are never held back.
+func WithLowPriorityLimit(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.lowPriority = make(chan struct{}, n)
+		}
+	}
+}
+
+// statementContext returns ctx with the statement timeout it carries
+// applied.
+func statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d, ok := ctx.Value(statementTimeoutKey{}).(time.Duration); ok && d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
+
+// admit waits for a slot for a statement of ctx's priority. It returns
+// fn wrapped to hold the slot while it runs, and a func the caller
+// defers to free the slot if the wrapped fn never ran, which it then
+// never will.
+func admit[T any](ctx context.Context, c *Client, fn func() (T, error)) (func() (T, error), func(), error) {
+	if c.lowPriority == nil || PriorityFromContext(ctx) != PriorityLow {
+		return fn, func() {}, nil
+	}
+	select {
+	case c.lowPriority <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	var claimed atomic.Bool
+	release := func() { <-c.lowPriority }
+	wrapped := func() (T, error) {
+		if !claimed.CompareAndSwap(false, true) {
+			var zero T
+			return zero, context.Canceled
+		}
+		defer release()
+		return fn()
+	}
+	done := func() {
+		if claimed.CompareAndSwap(false, true) {
+			release()
+		}
+	}
+	return wrapped, done, nil
+}
@@ -0,0 +1,67 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLowPriorityLimit(t *testing.T) {
+	b := &blockingBackend{started: make(chan struct{}, 2), release: make(chan struct{})}
+	c, err := Connect("test", WithTenant(1), WithBackend(b), WithLowPriorityLimit(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	low := WithPriority(context.Background(), PriorityLow)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.QueryContext(low, "SELECT n FROM report")
+		errc <- err
+	}()
+	<-b.started
+
+	// The slot is taken: another low-priority query times out waiting.
+	ctx := WithStatementTimeout(low, 20*time.Millisecond)
+	if _, err := c.QueryContext(ctx, "SELECT n FROM report"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("queued query: %v", err)
+	}
+	// Normal priority is not held back.
+	go func() { b.release <- struct{}{} }()
+	go func() { b.release <- struct{}{} }()
+	if _, err := c.QueryContext(context.Background(), "SELECT n FROM orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	// The slot is free again.
+	go func() { b.release <- struct{}{} }()
+	if _, err := c.QueryContext(low, "SELECT n FROM report"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStatementTimeoutPerStatement(t *testing.T) {
+	b := &blockingBackend{started: make(chan struct{}, 1), release: make(chan struct{})}
+	c, err := Connect("test", WithTenant(1), WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithStatementTimeout(context.Background(), 20*time.Millisecond)
+	start := time.Now()
+	if _, err := c.QueryContext(ctx, "SELECT n FROM slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow query: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("timed out after %v", elapsed)
+	}
+	<-b.started
+	close(b.release)
+	c.Close()
+	if ctx.Err() != nil {
+		t.Fatal("statement timeout cancelled the caller's context")
+	}
+}
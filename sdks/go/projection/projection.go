@@ -0,0 +1,358 @@
+// Package projection builds read models from Kimberlite streams.
+//
+// A Projection handles events; a Runner tails streams, feeds their
+// events to it in batches and checkpoints each stream's position after
+// the projection has handled a batch, so a restarted runner resumes
+// where it left off. Delivery is at-least-once: events handled after
+// the last checkpoint are handled again, and projections should be
+// idempotent, e.g. by upserting rows keyed by stream and offset.
+//
+//	runner := projection.NewRunner(client, "patient-list", patientList,
+//	    []kimberlite.StreamID{admissions, discharges},
+//	    projection.WithCheckpoints(connect.NewStreamCheckpoints(client, checkpoints)),
+//	    projection.WithParallelism(4),
+//	    projection.WithErrorPolicy(projection.RetryThenStop(5, time.Second)),
+//	)
+//	err := runner.Run(ctx)
+//
+// Streams are processed in parallel, each by one worker, so the events
+// of one stream are always handled in offset order; projections run
+// with WithParallelism must be safe for concurrent use. Reset clears a
+// projection that implements Resetter and its checkpoints, so the next
+// Run rebuilds it from the start of every stream; Rebuild resets it and
+// replays every stream at full speed, reporting its progress.
+package projection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// Projection maintains a read model from events.
+type Projection interface {
+	// Handle applies one event to the read model.
+	Handle(ctx context.Context, ev kimberlite.Event) error
+}
+
+// BatchProjection is implemented by projections that apply a batch of
+// one stream's events at once, e.g. in one database transaction. When
+// HandleBatch fails, the runner handles the batch's events one by one
+// with Handle, so the error policy sees the failing event.
+type BatchProjection interface {
+	Projection
+	HandleBatch(ctx context.Context, events []kimberlite.Event) error
+}
+
+// Resetter is implemented by projections that can clear their read
+// model, for Runner.Reset.
+type Resetter interface {
+	Reset(ctx context.Context) error
+}
+
+// HandlerFunc adapts a function to a Projection.
+type HandlerFunc func(ctx context.Context, ev kimberlite.Event) error
+
+// Handle implements Projection.
+func (f HandlerFunc) Handle(ctx context.Context, ev kimberlite.Event) error { return f(ctx, ev) }
+
+// Action is what a Runner does with an event its projection failed to
+// handle.
+type Action int
+
+const (
+	// Stop ends Run with the error, leaving the stream's checkpoint
+	// before the event.
+	Stop Action = iota
+	// Skip moves on to the next event.
+	Skip
+	// Retry handles the event again after a delay.
+	Retry
+)
+
+// ErrorPolicy decides what to do after the attempt-th failed attempt,
+// counting from 1, to handle ev, and for Retry, how long to wait.
+type ErrorPolicy func(ev kimberlite.Event, attempt int, err error) (Action, time.Duration)
+
+// StopOnError stops at the first failure. It is the default policy.
+func StopOnError(kimberlite.Event, int, error) (Action, time.Duration) { return Stop, 0 }
+
+// SkipErrors skips events that fail, calling report, if not nil, with
+// each of them.
+func SkipErrors(report func(ev kimberlite.Event, err error)) ErrorPolicy {
+	return func(ev kimberlite.Event, _ int, err error) (Action, time.Duration) {
+		if report != nil {
+			report(ev, err)
+		}
+		return Skip, 0
+	}
+}
+
+// RetryThenStop retries an event attempts-1 times, waiting delay
+// doubled after each attempt, and then stops.
+func RetryThenStop(attempts int, delay time.Duration) ErrorPolicy {
+	return func(_ kimberlite.Event, attempt int, _ error) (Action, time.Duration) {
+		if attempt >= attempts {
+			return Stop, 0
+		}
+		return Retry, delay << min(attempt-1, 16)
+	}
+}
+
+// Runner drives a Projection from streams. It is not safe for
+// concurrent use; run one Runner per projection name.
+type Runner struct {
+	source     connect.EventSource
+	name       string
+	projection Projection
+	streams    []kimberlite.StreamID
+	store      connect.CheckpointStore
+	policy     ErrorPolicy
+	workers    int
+	maxBytes   uint64
+	interval   time.Duration
+
+	mu  sync.Mutex
+	pos map[kimberlite.StreamID]kimberlite.Offset
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithCheckpoints stores the runner's per-stream positions in store
+// under its name, suffixed with "/<stream id>". Without it, positions
+// are kept in memory and a new Runner starts from the beginning of
+// each stream.
+func WithCheckpoints(store connect.CheckpointStore) Option {
+	return func(r *Runner) { r.store = store }
+}
+
+// WithErrorPolicy sets what the runner does with events the projection
+// fails to handle. The default is StopOnError.
+func WithErrorPolicy(p ErrorPolicy) Option {
+	return func(r *Runner) { r.policy = p }
+}
+
+// WithParallelism sets the number of workers streams are divided
+// among. The default is 1.
+func WithParallelism(n int) Option {
+	return func(r *Runner) { r.workers = max(n, 1) }
+}
+
+// WithBatchBytes sets the maxBytes of each read, and so the size of
+// each batch. The default is 1 MiB.
+func WithBatchBytes(n uint64) Option {
+	return func(r *Runner) { r.maxBytes = n }
+}
+
+// WithPollInterval sets how long a worker waits after catching up
+// before reading again. The default is one second.
+func WithPollInterval(d time.Duration) Option {
+	return func(r *Runner) { r.interval = d }
+}
+
+// NewRunner returns a runner feeding the events of streams to p. name
+// identifies the projection's checkpoints.
+func NewRunner(source connect.EventSource, name string, p Projection, streams []kimberlite.StreamID, opts ...Option) *Runner {
+	r := &Runner{
+		source:     source,
+		name:       name,
+		projection: p,
+		streams:    append([]kimberlite.StreamID(nil), streams...),
+		policy:     StopOnError,
+		workers:    1,
+		maxBytes:   1 << 20,
+		interval:   time.Second,
+		pos:        make(map[kimberlite.StreamID]kimberlite.Offset),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.store == nil {
+		r.store = connect.NewMemoryCheckpoints()
+	}
+	return r
+}
+
+// Run projects events until ctx is done or an event fails under the
+// error policy, polling each stream for new events once it has caught
+// up. After an error the runner can be run again; it resumes from its
+// last checkpoints.
+func (r *Runner) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, r.workers)
+	var wg sync.WaitGroup
+	for w := range errs {
+		streams := r.partition(w)
+		if len(streams) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			if err := r.work(ctx, streams); err != nil {
+				errs[w] = err
+				cancel()
+			}
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// Poll projects every event not yet projected, one stream after
+// another, and returns the number of events handled.
+func (r *Runner) Poll(ctx context.Context) (int, error) {
+	var total int
+	for _, id := range r.streams {
+		n, err := r.drain(ctx, id)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Reset clears the projection, which must implement Resetter, and its
+// checkpoints, so the next Run rebuilds it from the start of every
+// stream.
+func (r *Runner) Reset(ctx context.Context) error {
+	resetter, ok := r.projection.(Resetter)
+	if !ok {
+		return fmt.Errorf("kimberlite: projection %s cannot be reset", r.name)
+	}
+	if err := resetter.Reset(ctx); err != nil {
+		return err
+	}
+	for _, id := range r.streams {
+		if err := r.save(ctx, id, 0); err != nil {
+			return fmt.Errorf("kimberlite: reset checkpoint for stream %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// partition returns the streams of worker w.
+func (r *Runner) partition(w int) []kimberlite.StreamID {
+	var streams []kimberlite.StreamID
+	for i, id := range r.streams {
+		if i%r.workers == w {
+			streams = append(streams, id)
+		}
+	}
+	return streams
+}
+
+func (r *Runner) work(ctx context.Context, streams []kimberlite.StreamID) error {
+	for {
+		for _, id := range streams {
+			if _, err := r.drain(ctx, id); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+func (r *Runner) checkpointName(id kimberlite.StreamID) string {
+	return r.name + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// drain projects the events of stream id from its checkpoint on.
+func (r *Runner) drain(ctx context.Context, id kimberlite.StreamID) (int, error) {
+	r.mu.Lock()
+	from, ok := r.pos[id]
+	r.mu.Unlock()
+	if !ok {
+		var err error
+		if from, _, err = r.store.Load(ctx, r.checkpointName(id)); err != nil {
+			return 0, fmt.Errorf("kimberlite: load checkpoint for stream %d: %w", id, err)
+		}
+	}
+	var total int
+	for {
+		events, err := r.source.ReadEventsContext(ctx, id, from, r.maxBytes)
+		if err != nil {
+			return total, err
+		}
+		if len(events) == 0 {
+			return total, nil
+		}
+		n, err := r.handle(ctx, events)
+		total += n
+		if n > 0 {
+			from = events[n-1].Offset + 1
+			if err := r.save(ctx, id, from); err != nil {
+				return total, fmt.Errorf("kimberlite: save checkpoint for stream %d: %w", id, err)
+			}
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+func (r *Runner) save(ctx context.Context, id kimberlite.StreamID, pos kimberlite.Offset) error {
+	if err := r.store.Save(ctx, r.checkpointName(id), pos); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.pos[id] = pos
+	r.mu.Unlock()
+	return nil
+}
+
+// handle applies a batch and returns the number of its events handled
+// or skipped before any error.
+func (r *Runner) handle(ctx context.Context, events []kimberlite.Event) (int, error) {
+	if bp, ok := r.projection.(BatchProjection); ok {
+		if err := bp.HandleBatch(ctx, events); err == nil {
+			return len(events), nil
+		}
+	}
+	for i, ev := range events {
+		if err := r.handleOne(ctx, ev); err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+func (r *Runner) handleOne(ctx context.Context, ev kimberlite.Event) error {
+	for attempt := 1; ; attempt++ {
+		err := r.projection.Handle(ctx, ev)
+		if err == nil {
+			return nil
+		}
+		action, delay := r.policy(ev, attempt, err)
+		switch action {
+		case Skip:
+			return nil
+		case Retry:
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		default:
+			return fmt.Errorf("kimberlite: projection %s: stream %d offset %d: %w", r.name, ev.StreamID, ev.Offset, err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+type fakeLog struct {
+	mu     sync.Mutex
+	events map[kimberlite.StreamID][]kimberlite.Event
+	// batch is the number of events per read.
+	batch int
+}
+
+func (l *fakeLog) add(id kimberlite.StreamID, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i < n; i++ {
+		off := kimberlite.Offset(len(l.events[id]))
+		l.events[id] = append(l.events[id], kimberlite.Event{StreamID: id, Offset: off, Data: []byte{byte(off)}})
+	}
+}
+
+func (l *fakeLog) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, _ uint64) ([]kimberlite.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	evs := l.events[id]
+	if int(from) >= len(evs) {
+		return nil, nil
+	}
+	return append([]kimberlite.Event(nil), evs[from:min(int(from)+l.batch, len(evs))]...), nil
+}
+
+// model records handled events and fails those in fail.
+type model struct {
+	mu      sync.Mutex
+	seen    map[kimberlite.StreamID][]kimberlite.Offset
+	fail    map[kimberlite.Offset]int
+	batches int
+}
+
+func newModel() *model {
+	return &model{seen: map[kimberlite.StreamID][]kimberlite.Offset{}, fail: map[kimberlite.Offset]int{}}
+}
+
+func (m *model) Handle(_ context.Context, ev kimberlite.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail[ev.Offset] > 0 {
+		m.fail[ev.Offset]--
+		return errors.New("boom")
+	}
+	m.seen[ev.StreamID] = append(m.seen[ev.StreamID], ev.Offset)
+	return nil
+}
+
+func (m *model) Reset(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seen = map[kimberlite.StreamID][]kimberlite.Offset{}
+	return nil
+}
+
+type batchModel struct{ *model }
+
+func (b batchModel) HandleBatch(ctx context.Context, events []kimberlite.Event) error {
+	b.mu.Lock()
+	b.batches++
+	failing := false
+	for _, ev := range events {
+		failing = failing || b.fail[ev.Offset] > 0
+	}
+	b.mu.Unlock()
+	if failing {
+		return errors.New("batch failed")
+	}
+	for _, ev := range events {
+		b.Handle(ctx, ev)
+	}
+	return nil
+}
+
+func TestRunnerPoll(t *testing.T) {
+	ctx := context.Background()
+	log := &fakeLog{events: map[kimberlite.StreamID][]kimberlite.Event{}, batch: 2}
+	log.add(1, 5)
+	log.add(2, 3)
+	store := connect.NewMemoryCheckpoints()
+	m := newModel()
+	m.fail[3] = 1
+	r := NewRunner(log, "p", m, []kimberlite.StreamID{1, 2}, WithCheckpoints(store))
+
+	if n, err := r.Poll(ctx); err == nil || n != 3 {
+		t.Fatalf("first poll: %d, %v", n, err)
+	}
+	if pos, _, _ := store.Load(ctx, "p/1"); pos != 3 {
+		t.Fatalf("checkpoint %d, expected 3", pos)
+	}
+	// A new runner resumes from the checkpoint at the failed event.
+	r = NewRunner(log, "p", m, []kimberlite.StreamID{1, 2}, WithCheckpoints(store))
+	if n, err := r.Poll(ctx); err != nil || n != 5 {
+		t.Fatalf("second poll: %d, %v", n, err)
+	}
+	if got := m.seen[1]; len(got) != 5 || got[3] != 3 {
+		t.Fatalf("seen %v", got)
+	}
+
+	if err := r.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Poll(ctx); err != nil || n != 8 || len(m.seen[2]) != 3 {
+		t.Fatalf("rebuild: %d, %v, %v", n, err, m.seen)
+	}
+
+	if err := NewRunner(log, "f", HandlerFunc(m.Handle), nil).Reset(ctx); err == nil {
+		t.Fatal("reset of a projection without Resetter succeeded")
+	}
+}
+
+func TestRunnerPolicies(t *testing.T) {
+	ctx := context.Background()
+	log := &fakeLog{events: map[kimberlite.StreamID][]kimberlite.Event{}, batch: 10}
+	log.add(1, 4)
+
+	m := newModel()
+	m.fail[1] = 2
+	r := NewRunner(log, "p", batchModel{m}, []kimberlite.StreamID{1}, WithErrorPolicy(RetryThenStop(3, time.Millisecond)))
+	if n, err := r.Poll(ctx); err != nil || n != 4 || len(m.seen[1]) != 4 || m.batches != 1 {
+		t.Fatalf("retry: %d, %v, %v, %d batches", n, err, m.seen[1], m.batches)
+	}
+
+	m = newModel()
+	m.fail[2] = 1
+	var skipped []kimberlite.Offset
+	r = NewRunner(log, "p", m, []kimberlite.StreamID{1}, WithErrorPolicy(SkipErrors(func(ev kimberlite.Event, _ error) {
+		skipped = append(skipped, ev.Offset)
+	})))
+	if n, err := r.Poll(ctx); err != nil || n != 4 || len(m.seen[1]) != 3 || len(skipped) != 1 || skipped[0] != 2 {
+		t.Fatalf("skip: %d, %v, %v, %v", n, err, m.seen[1], skipped)
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	log := &fakeLog{events: map[kimberlite.StreamID][]kimberlite.Event{}, batch: 3}
+	streams := []kimberlite.StreamID{1, 2, 3, 4, 5}
+	for _, id := range streams {
+		log.add(id, 10)
+	}
+	m := newModel()
+	r := NewRunner(log, "p", m, streams, WithParallelism(3), WithPollInterval(time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mu.Lock()
+		n := 0
+		for _, offs := range m.seen {
+			n += len(offs)
+		}
+		m.mu.Unlock()
+		if n == 50 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("handled %d of 50 events", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("run: %v", err)
+	}
+	for id, offs := range m.seen {
+		for i, off := range offs {
+			if off != kimberlite.Offset(i) {
+				t.Fatalf("stream %d out of order: %v", id, offs)
+			}
+		}
+	}
+
+	// A failing event stops every worker.
+	m.fail[0] = 1
+	r = NewRunner(log, "q", m, streams, WithParallelism(2), WithPollInterval(time.Millisecond))
+	if err := r.Run(context.Background()); err == nil || errors.Is(err, context.Canceled) {
+		t.Fatalf("run: %v", err)
+	}
+}
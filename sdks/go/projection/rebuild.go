@@ -0,0 +1,225 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// Progress is the progress of a Rebuild.
+type Progress struct {
+	// Streams is the number of streams being replayed, StreamsDone the
+	// number replayed to their end.
+	Streams     int
+	StreamsDone int
+	// Events and Bytes count the events handled or skipped so far and
+	// their data.
+	Events  int64
+	Bytes   int64
+	Elapsed time.Duration
+	// ETA estimates the time left from the share of streams done; it is
+	// zero until a stream is. The server does not report the length of
+	// streams, so it assumes they are of similar length.
+	ETA time.Duration
+	// Done is set in the last report, after every stream is replayed.
+	Done bool
+}
+
+// Rate returns the number of events handled per second.
+func (p Progress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Events) / p.Elapsed.Seconds()
+}
+
+// RebuildOption configures Rebuild.
+type RebuildOption func(*rebuild)
+
+type rebuild struct {
+	workers  int
+	prefetch int
+	report   func(Progress)
+	every    time.Duration
+}
+
+// WithRebuildParallelism sets the number of streams replayed at once.
+// The default is the runner's parallelism. The projection must be safe
+// for concurrent use when it is more than 1.
+func WithRebuildParallelism(n int) RebuildOption {
+	return func(b *rebuild) { b.workers = max(n, 1) }
+}
+
+// WithPrefetch sets how many batches of a stream are kept read ahead
+// of the projection, besides the one being read. The default is 2.
+func WithPrefetch(n int) RebuildOption {
+	return func(b *rebuild) { b.prefetch = max(n, 0) }
+}
+
+// WithProgress calls report with the progress of the rebuild every
+// interval, and once more when it is done. Calls are not concurrent.
+func WithProgress(report func(Progress), every time.Duration) RebuildOption {
+	return func(b *rebuild) { b.report, b.every = report, every }
+}
+
+// Rebuild resets the projection, which must implement Resetter, and
+// its checkpoints, then replays every stream from offset 0 to its end
+// as fast as the projection handles events: streams are replayed in
+// parallel, taken from a shared queue as workers free up, and each
+// stream's next batches are read while the projection handles the
+// current one. Events are handled under the error policy and
+// checkpointed as in Run, so after an error Run or Poll continue the
+// rebuild; a second Rebuild starts it over. Rebuild must not run
+// alongside Run or Poll.
+func (r *Runner) Rebuild(ctx context.Context, opts ...RebuildOption) error {
+	b := rebuild{workers: r.workers, prefetch: 2, every: time.Second}
+	for _, opt := range opts {
+		opt(&b)
+	}
+	if err := r.Reset(ctx); err != nil {
+		return err
+	}
+
+	var (
+		start               = time.Now()
+		events, bytes, done atomic.Int64
+		reportMu            sync.Mutex
+	)
+	report := func(final bool) {
+		if b.report == nil {
+			return
+		}
+		p := Progress{
+			Streams:     len(r.streams),
+			StreamsDone: int(done.Load()),
+			Events:      events.Load(),
+			Bytes:       bytes.Load(),
+			Elapsed:     time.Since(start),
+			Done:        final,
+		}
+		if p.StreamsDone > 0 && !final {
+			p.ETA = p.Elapsed * time.Duration(p.Streams-p.StreamsDone) / time.Duration(p.StreamsDone)
+		}
+		reportMu.Lock()
+		defer reportMu.Unlock()
+		b.report(p)
+	}
+
+	queue := make(chan kimberlite.StreamID, len(r.streams))
+	for _, id := range r.streams {
+		queue <- id
+	}
+	close(queue)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, b.workers)
+	var wg sync.WaitGroup
+	for w := range errs {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for id := range queue {
+				err := r.replay(ctx, id, b.prefetch, func(batch []kimberlite.Event) {
+					events.Add(int64(len(batch)))
+					for _, ev := range batch {
+						bytes.Add(int64(len(ev.Data)))
+					}
+				})
+				if err != nil {
+					errs[w] = err
+					cancel()
+					return
+				}
+				done.Add(1)
+			}
+		}(w)
+	}
+
+	finished := make(chan struct{})
+	var ticks sync.WaitGroup
+	if b.report != nil && b.every > 0 {
+		ticks.Add(1)
+		go func() {
+			defer ticks.Done()
+			t := time.NewTicker(b.every)
+			defer t.Stop()
+			for {
+				select {
+				case <-finished:
+					return
+				case <-t.C:
+					report(false)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(finished)
+	ticks.Wait()
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	report(true)
+	return nil
+}
+
+// replay projects stream id from its start to its end, reading up to
+// prefetch batches ahead, and calls count with the events of each
+// batch handled.
+func (r *Runner) replay(ctx context.Context, id kimberlite.StreamID, prefetch int, count func([]kimberlite.Event)) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	batches := make(chan []kimberlite.Event, prefetch)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(batches)
+		var from kimberlite.Offset
+		for {
+			events, err := r.source.ReadEventsContext(ctx, id, from, r.maxBytes)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if len(events) == 0 {
+				return
+			}
+			select {
+			case batches <- events:
+			case <-ctx.Done():
+				return
+			}
+			from = events[len(events)-1].Offset + 1
+		}
+	}()
+
+	for events := range batches {
+		n, err := r.handle(ctx, events)
+		if n > 0 {
+			if err := r.save(ctx, id, events[n-1].Offset+1); err != nil {
+				return fmt.Errorf("kimberlite: save checkpoint for stream %d: %w", id, err)
+			}
+			count(events[:n])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	select {
+	case err := <-readErr:
+		return err
+	default:
+		return nil
+	}
+}
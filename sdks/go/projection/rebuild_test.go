@@ -0,0 +1,56 @@
+package projection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+func TestRunnerRebuild(t *testing.T) {
+	ctx := context.Background()
+	log := &fakeLog{events: map[kimberlite.StreamID][]kimberlite.Event{}, batch: 3}
+	streams := []kimberlite.StreamID{1, 2, 3}
+	for _, id := range streams {
+		log.add(id, 10)
+	}
+	m := newModel()
+	store := connect.NewMemoryCheckpoints()
+	r := NewRunner(log, "list", m, streams, WithCheckpoints(store))
+	if _, err := r.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []Progress
+	err := r.Rebuild(ctx, WithRebuildParallelism(2), WithPrefetch(1), WithProgress(func(p Progress) {
+		reports = append(reports, p)
+	}, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range streams {
+		if n := len(m.seen[id]); n != 10 {
+			t.Fatalf("stream %d: %d events after rebuild", id, n)
+		}
+		if pos, _, _ := store.Load(ctx, r.checkpointName(id)); pos != 10 {
+			t.Fatalf("stream %d checkpoint %d", id, pos)
+		}
+	}
+	if len(reports) != 1 {
+		t.Fatalf("%d reports", len(reports))
+	}
+	if p := reports[0]; !p.Done || p.Streams != 3 || p.StreamsDone != 3 || p.Events != 30 || p.Bytes != 30 {
+		t.Fatalf("final progress %+v", p)
+	}
+
+	// A failing event stops the rebuild with its checkpoint before it.
+	m.fail[5] = 1
+	if err := r.Rebuild(ctx, WithRebuildParallelism(1)); err == nil {
+		t.Fatal("rebuild succeeded past a failing event")
+	}
+	if pos, _, _ := store.Load(ctx, r.checkpointName(1)); pos > 5 {
+		t.Fatalf("checkpoint %d past the failing event", pos)
+	}
+}
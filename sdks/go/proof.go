@@ -0,0 +1,454 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// Inclusion proofs follow the Merkle tree of RFC 9162 (Certificate
+// Transparency v2) over the events of one stream, in offset order:
+//
+//	leaf hash  SHA-256(0x00 || offset uint64 || data)
+//	node hash  SHA-256(0x01 || left || right)
+//
+// The server does not publish tree heads, so they are computed from
+// the stream by whoever vouches for them, typically an independent
+// witness reading the log, and signed with SignTreeHead. An auditor
+// holding the witness's public key then checks an event against a
+// signed head with InclusionProof.Verify, without trusting the client
+// or server that produced the proof: a proof for an event that is not
+// in the witnessed log can't reproduce the signed root. Likewise
+// ConsistencyProof.Verify checks that a later signed head extends an
+// earlier one, so the witnessed log was only appended to.
+//
+// Leaves hash the data as the server stores it, read with
+// ReadStoredEvents: encrypted payloads stay encrypted, so erasing a
+// subject leaves the tree unchanged, and the read cache is never
+// consulted, so the tree is the server's and not a local copy's.
+
+// TreeHashSize is the size of Merkle tree hashes.
+const TreeHashSize = sha256.Size
+
+// ErrInvalidProof is returned when a proof, tree head or stream fails
+// verification.
+var ErrInvalidProof = errors.New("kimberlite: invalid proof")
+
+// TreeHead is the Merkle root of a stream's first TreeSize events.
+type TreeHead struct {
+	StreamID  StreamID
+	TreeSize  uint64
+	RootHash  [TreeHashSize]byte
+	Timestamp time.Time
+}
+
+// SignedTreeHead is a TreeHead signed by a witness with Ed25519.
+type SignedTreeHead struct {
+	TreeHead
+	Signature []byte
+}
+
+// InclusionProof is the audit path proving that the event at Offset is
+// in the tree of a stream's first TreeSize events.
+type InclusionProof struct {
+	StreamID StreamID
+	Offset   Offset
+	TreeSize uint64
+	Path     [][TreeHashSize]byte
+}
+
+var treeHeadContext = []byte("kimberlite tree head v1\x00")
+
+// signedBytes is the message a tree head signature covers.
+func (h TreeHead) signedBytes() []byte {
+	b := append([]byte(nil), treeHeadContext...)
+	b = binary.BigEndian.AppendUint64(b, uint64(h.StreamID))
+	b = binary.BigEndian.AppendUint64(b, h.TreeSize)
+	b = append(b, h.RootHash[:]...)
+	return binary.BigEndian.AppendUint64(b, uint64(h.Timestamp.UnixNano()))
+}
+
+// SignTreeHead signs h with key.
+func SignTreeHead(h TreeHead, key ed25519.PrivateKey) SignedTreeHead {
+	return SignedTreeHead{TreeHead: h, Signature: ed25519.Sign(key, h.signedBytes())}
+}
+
+// Verify checks the tree head's signature against key.
+func (s SignedTreeHead) Verify(key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, s.signedBytes(), s.Signature) {
+		return fmt.Errorf("%w: bad tree head signature", ErrInvalidProof)
+	}
+	return nil
+}
+
+// Verify checks that ev is the event proven by p and that p leads to
+// the root of sth, after checking sth's signature against key. It uses
+// only ev and its arguments, so the proof may come from anyone. ev is
+// the event as stored, as returned by ReadStoredEvents.
+func (p *InclusionProof) Verify(ev Event, sth SignedTreeHead, key ed25519.PublicKey) error {
+	if err := sth.Verify(key); err != nil {
+		return err
+	}
+	switch {
+	case ev.StreamID != p.StreamID || ev.Offset != p.Offset:
+		return fmt.Errorf("%w: event is stream %d offset %d, proof is for stream %d offset %d",
+			ErrInvalidProof, ev.StreamID, ev.Offset, p.StreamID, p.Offset)
+	case sth.StreamID != p.StreamID || sth.TreeSize != p.TreeSize:
+		return fmt.Errorf("%w: proof is for %d events of stream %d, tree head for %d of stream %d",
+			ErrInvalidProof, p.TreeSize, p.StreamID, sth.TreeSize, sth.StreamID)
+	case uint64(p.Offset) >= p.TreeSize:
+		return fmt.Errorf("%w: offset %d outside tree of size %d", ErrInvalidProof, p.Offset, p.TreeSize)
+	}
+	root, ok := rootFromPath(uint64(p.Offset), p.TreeSize, leafHash(ev.Offset, ev.Data), p.Path)
+	if !ok || !bytes.Equal(root[:], sth.RootHash[:]) {
+		return fmt.Errorf("%w: root mismatch", ErrInvalidProof)
+	}
+	return nil
+}
+
+// ConsistencyProof proves that the tree of a stream's first NewSize
+// events extends the tree of its first OldSize events: that the log
+// between two checkpoints was only appended to.
+type ConsistencyProof struct {
+	StreamID StreamID
+	OldSize  uint64
+	NewSize  uint64
+	Path     [][TreeHashSize]byte
+}
+
+// Verify checks that p links the roots of old and next, after checking
+// both signatures against key.
+func (p *ConsistencyProof) Verify(old, next SignedTreeHead, key ed25519.PublicKey) error {
+	if err := old.Verify(key); err != nil {
+		return err
+	}
+	if err := next.Verify(key); err != nil {
+		return err
+	}
+	switch {
+	case old.StreamID != p.StreamID || next.StreamID != p.StreamID:
+		return fmt.Errorf("%w: proof and tree heads are for different streams", ErrInvalidProof)
+	case old.TreeSize != p.OldSize || next.TreeSize != p.NewSize:
+		return fmt.Errorf("%w: proof is for sizes %d and %d, tree heads for %d and %d",
+			ErrInvalidProof, p.OldSize, p.NewSize, old.TreeSize, next.TreeSize)
+	}
+	if !consistent(p.OldSize, p.NewSize, old.RootHash, next.RootHash, p.Path) {
+		return fmt.Errorf("%w: stream %d at %d events is not an extension of %d events", ErrInvalidProof, p.StreamID, p.NewSize, p.OldSize)
+	}
+	return nil
+}
+
+// ProofOption configures ProveEvent, TreeHead and VerifyChain.
+type ProofOption func(*proofConfig)
+
+type proofConfig struct {
+	treeSize uint64
+}
+
+// WithTreeSize proves against, or computes the head of, the stream's
+// first n events rather than all of them, e.g. to match a tree head
+// signed earlier.
+func WithTreeSize(n uint64) ProofOption {
+	return func(c *proofConfig) { c.treeSize = n }
+}
+
+// ProveEvent is ProveEventContext with a background context.
+func (c *Client) ProveEvent(streamID StreamID, offset Offset, opts ...ProofOption) (*InclusionProof, error) {
+	return c.ProveEventContext(context.Background(), streamID, offset, opts...)
+}
+
+// ProveEventContext returns the inclusion proof of the event at offset
+// in its stream. Building it reads the stream from the start.
+func (c *Client) ProveEventContext(ctx context.Context, streamID StreamID, offset Offset, opts ...ProofOption) (*InclusionProof, error) {
+	return proveEvent(ctx, storedEvents{c}, streamID, offset, opts...)
+}
+
+// TreeHead is TreeHeadContext with a background context.
+func (c *Client) TreeHead(streamID StreamID, opts ...ProofOption) (TreeHead, error) {
+	return c.TreeHeadContext(context.Background(), streamID, opts...)
+}
+
+// TreeHeadContext computes the tree head of a stream, for a witness to
+// sign with SignTreeHead. Computing it reads the stream from the start.
+func (c *Client) TreeHeadContext(ctx context.Context, streamID StreamID, opts ...ProofOption) (TreeHead, error) {
+	return treeHead(ctx, storedEvents{c}, streamID, opts...)
+}
+
+// ProveConsistency is ProveConsistencyContext with a background
+// context.
+func (c *Client) ProveConsistency(streamID StreamID, oldSize, newSize uint64) (*ConsistencyProof, error) {
+	return c.ProveConsistencyContext(context.Background(), streamID, oldSize, newSize)
+}
+
+// ProveConsistencyContext returns the consistency proof between the
+// trees of the stream's first oldSize and newSize events, e.g. between
+// two signed tree heads. Building it reads the stream from the start.
+func (c *Client) ProveConsistencyContext(ctx context.Context, streamID StreamID, oldSize, newSize uint64) (*ConsistencyProof, error) {
+	return proveConsistency(ctx, storedEvents{c}, streamID, oldSize, newSize)
+}
+
+// VerifyChain is VerifyChainContext with a background context.
+func (c *Client) VerifyChain(streamID StreamID, trusted TreeHead, opts ...ProofOption) (TreeHead, error) {
+	return c.VerifyChainContext(context.Background(), streamID, trusted, opts...)
+}
+
+// VerifyChainContext reads the stream from the start and checks that it
+// is contiguous and still begins with the tree of trusted, a head the
+// caller verified earlier. It returns the stream's current head, to be
+// trusted on the next call, so calling it periodically monitors the
+// stream for rewritten or removed events:
+//
+//	head, err := client.TreeHead(stream)
+//	for range time.Tick(time.Minute) {
+//	    if head, err = client.VerifyChain(stream, head); err != nil {
+//	        alert(err)
+//	    }
+//	}
+//
+// A zero trusted head checks only contiguity. Verification failures wrap
+// ErrInvalidProof.
+func (c *Client) VerifyChainContext(ctx context.Context, streamID StreamID, trusted TreeHead, opts ...ProofOption) (TreeHead, error) {
+	return verifyChain(ctx, storedEvents{c}, streamID, trusted, opts...)
+}
+
+// storedEvents reads a client's events with ReadStoredEvents.
+type storedEvents struct{ c *Client }
+
+func (r storedEvents) ReadEventsContext(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64) ([]Event, error) {
+	return r.c.ReadStoredEvents(ctx, streamID, from, maxBytes)
+}
+
+func proveEvent(ctx context.Context, r eventReader, streamID StreamID, offset Offset, opts ...ProofOption) (*InclusionProof, error) {
+	leaves, err := readLeaves(ctx, r, streamID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(offset) >= uint64(len(leaves)) {
+		return nil, fmt.Errorf("kimberlite: prove stream %d offset %d: tree has %d events", streamID, offset, len(leaves))
+	}
+	return &InclusionProof{
+		StreamID: streamID,
+		Offset:   offset,
+		TreeSize: uint64(len(leaves)),
+		Path:     auditPath(uint64(offset), leaves),
+	}, nil
+}
+
+func treeHead(ctx context.Context, r eventReader, streamID StreamID, opts ...ProofOption) (TreeHead, error) {
+	leaves, err := readLeaves(ctx, r, streamID, opts)
+	if err != nil {
+		return TreeHead{}, err
+	}
+	return TreeHead{
+		StreamID:  streamID,
+		TreeSize:  uint64(len(leaves)),
+		RootHash:  treeRoot(leaves),
+		Timestamp: time.Now().UTC(),
+	}, nil
+}
+
+func proveConsistency(ctx context.Context, r eventReader, streamID StreamID, oldSize, newSize uint64) (*ConsistencyProof, error) {
+	if oldSize > newSize || newSize == 0 {
+		return nil, fmt.Errorf("kimberlite: invalid consistency range %d to %d", oldSize, newSize)
+	}
+	leaves, err := readLeaves(ctx, r, streamID, []ProofOption{WithTreeSize(newSize)})
+	if err != nil {
+		return nil, err
+	}
+	p := &ConsistencyProof{StreamID: streamID, OldSize: oldSize, NewSize: newSize}
+	if oldSize > 0 && oldSize < newSize {
+		p.Path = subproof(oldSize, leaves, true)
+	}
+	return p, nil
+}
+
+func verifyChain(ctx context.Context, r eventReader, streamID StreamID, trusted TreeHead, opts ...ProofOption) (TreeHead, error) {
+	if trusted.TreeSize > 0 && trusted.StreamID != streamID {
+		return TreeHead{}, fmt.Errorf("kimberlite: tree head is for stream %d, not %d", trusted.StreamID, streamID)
+	}
+	leaves, err := readLeaves(ctx, r, streamID, opts)
+	if err != nil {
+		return TreeHead{}, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if uint64(len(leaves)) < trusted.TreeSize {
+		return TreeHead{}, fmt.Errorf("%w: stream %d shrank from %d to %d events", ErrInvalidProof, streamID, trusted.TreeSize, len(leaves))
+	}
+	if trusted.TreeSize > 0 && treeRoot(leaves[:trusted.TreeSize]) != trusted.RootHash {
+		return TreeHead{}, fmt.Errorf("%w: stream %d's first %d events no longer match the trusted head", ErrInvalidProof, streamID, trusted.TreeSize)
+	}
+	return TreeHead{
+		StreamID:  streamID,
+		TreeSize:  uint64(len(leaves)),
+		RootHash:  treeRoot(leaves),
+		Timestamp: time.Now().UTC(),
+	}, nil
+}
+
+// readLeaves returns the leaf hashes of the stream's first treeSize
+// events, or of all of them if treeSize is unset.
+func readLeaves(ctx context.Context, r eventReader, streamID StreamID, opts []ProofOption) ([][TreeHashSize]byte, error) {
+	var cfg proofConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var leaves [][TreeHashSize]byte
+	for cfg.treeSize == 0 || uint64(len(leaves)) < cfg.treeSize {
+		events, err := r.ReadEventsContext(ctx, streamID, Offset(len(leaves)), 1<<20)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, ev := range events {
+			if cfg.treeSize != 0 && uint64(len(leaves)) == cfg.treeSize {
+				break
+			}
+			if ev.Offset != Offset(len(leaves)) {
+				return nil, fmt.Errorf("kimberlite: stream %d: expected offset %d, read %d", streamID, len(leaves), ev.Offset)
+			}
+			leaves = append(leaves, leafHash(ev.Offset, ev.Data))
+		}
+	}
+	if cfg.treeSize != 0 && uint64(len(leaves)) < cfg.treeSize {
+		return nil, fmt.Errorf("kimberlite: stream %d has %d events, fewer than tree size %d", streamID, len(leaves), cfg.treeSize)
+	}
+	return leaves, nil
+}
+
+func leafHash(offset Offset, data []byte) [TreeHashSize]byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(offset)))
+	h.Write(data)
+	var out [TreeHashSize]byte
+	h.Sum(out[:0])
+	return out
+}
+
+func nodeHash(left, right [TreeHashSize]byte) [TreeHashSize]byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left[:])
+	h.Write(right[:])
+	var out [TreeHashSize]byte
+	h.Sum(out[:0])
+	return out
+}
+
+// split returns the largest power of two smaller than n, for n > 1.
+func split(n uint64) uint64 {
+	return 1 << (bits.Len64(n-1) - 1)
+}
+
+// treeRoot is MTH of RFC 9162 section 2.1.1. The empty tree's root is
+// the hash of the empty string.
+func treeRoot(leaves [][TreeHashSize]byte) [TreeHashSize]byte {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return leaves[0]
+	}
+	k := split(uint64(len(leaves)))
+	return nodeHash(treeRoot(leaves[:k]), treeRoot(leaves[k:]))
+}
+
+// auditPath is PATH of RFC 9162 section 2.1.3.1.
+func auditPath(m uint64, leaves [][TreeHashSize]byte) [][TreeHashSize]byte {
+	n := uint64(len(leaves))
+	if n <= 1 {
+		return nil
+	}
+	k := split(n)
+	if m < k {
+		return append(auditPath(m, leaves[:k]), treeRoot(leaves[k:]))
+	}
+	return append(auditPath(m-k, leaves[k:]), treeRoot(leaves[:k]))
+}
+
+// subproof is SUBPROOF of RFC 9162 section 2.1.4.1.
+func subproof(m uint64, leaves [][TreeHashSize]byte, complete bool) [][TreeHashSize]byte {
+	n := uint64(len(leaves))
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][TreeHashSize]byte{treeRoot(leaves)}
+	}
+	k := split(n)
+	if m <= k {
+		return append(subproof(m, leaves[:k], complete), treeRoot(leaves[k:]))
+	}
+	return append(subproof(m-k, leaves[k:], false), treeRoot(leaves[:k]))
+}
+
+// consistent verifies a consistency proof per RFC 9162 section 2.1.4.2.
+func consistent(first, second uint64, firstHash, secondHash [TreeHashSize]byte, path [][TreeHashSize]byte) bool {
+	switch {
+	case first > second:
+		return false
+	case first == second:
+		return len(path) == 0 && firstHash == secondHash
+	case first == 0:
+		return len(path) == 0
+	case len(path) == 0:
+		return false
+	}
+	if first&(first-1) == 0 {
+		path = append([][TreeHashSize]byte{firstHash}, path...)
+	}
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := path[0], path[0]
+	for _, c := range path[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return fr == firstHash && sr == secondHash && sn == 0
+}
+
+// rootFromPath recomputes the root from a leaf and its audit path, per
+// RFC 9162 section 2.1.3.2.
+func rootFromPath(index, size uint64, leaf [TreeHashSize]byte, path [][TreeHashSize]byte) ([TreeHashSize]byte, bool) {
+	fn, sn, r := index, size-1, leaf
+	for _, p := range path {
+		if sn == 0 {
+			return r, false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return r, sn == 0
+}
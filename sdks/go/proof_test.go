@@ -0,0 +1,132 @@
+package kimberlite
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestInclusionProof(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := fakeLog{}
+	for i := 0; i < 13; i++ {
+		log[1] = append(log[1], Event{StreamID: 1, Offset: Offset(i), Data: []byte(fmt.Sprintf("event-%d", i))})
+	}
+
+	for size := uint64(1); size <= 13; size++ {
+		head, err := treeHead(ctx, log, 1, WithTreeSize(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sth := SignTreeHead(head, priv)
+		for i := uint64(0); i < size; i++ {
+			p, err := proveEvent(ctx, log, 1, Offset(i), WithTreeSize(size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Verify(log[1][i], sth, pub); err != nil {
+				t.Fatalf("size %d offset %d: %v", size, i, err)
+			}
+		}
+	}
+
+	head, _ := treeHead(ctx, log, 1)
+	sth := SignTreeHead(head, priv)
+	p, err := proveEvent(ctx, log, 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TreeSize != 13 {
+		t.Fatalf("tree size %d", p.TreeSize)
+	}
+
+	tampered := log[1][5]
+	tampered.Data = []byte("forged")
+	other, _, _ := ed25519.GenerateKey(nil)
+	forged := sth
+	forged.TreeSize = 12
+	cases := map[string]error{
+		"data":      p.Verify(tampered, sth, pub),
+		"event":     p.Verify(log[1][6], sth, pub),
+		"key":       p.Verify(log[1][5], sth, other),
+		"head":      p.Verify(log[1][5], forged, pub),
+		"truncated": (&InclusionProof{StreamID: 1, Offset: 5, TreeSize: 13, Path: p.Path[1:]}).Verify(log[1][5], sth, pub),
+	}
+	for name, err := range cases {
+		if !errors.Is(err, ErrInvalidProof) {
+			t.Errorf("%s: expected ErrInvalidProof, got %v", name, err)
+		}
+	}
+
+	if _, err := proveEvent(ctx, log, 1, 13); err == nil {
+		t.Fatal("expected error proving offset past the end")
+	}
+	if _, err := treeHead(ctx, log, 1, WithTreeSize(14)); err == nil {
+		t.Fatal("expected error for tree size past the end")
+	}
+}
+
+func TestConsistencyProof(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := fakeLog{}
+	for i := 0; i < 11; i++ {
+		log[1] = append(log[1], Event{StreamID: 1, Offset: Offset(i), Data: []byte(fmt.Sprintf("event-%d", i))})
+	}
+	heads := make([]SignedTreeHead, 12)
+	for size := uint64(1); size <= 11; size++ {
+		head, err := treeHead(ctx, log, 1, WithTreeSize(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		heads[size] = SignTreeHead(head, priv)
+	}
+	for m := uint64(1); m <= 11; m++ {
+		for n := m; n <= 11; n++ {
+			p, err := proveConsistency(ctx, log, 1, m, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Verify(heads[m], heads[n], pub); err != nil {
+				t.Fatalf("%d to %d: %v", m, n, err)
+			}
+			if len(p.Path) > 0 {
+				p.Path[0][0] ^= 1
+				if err := p.Verify(heads[m], heads[n], pub); !errors.Is(err, ErrInvalidProof) {
+					t.Fatalf("%d to %d with altered path: expected ErrInvalidProof, got %v", m, n, err)
+				}
+			}
+		}
+	}
+
+	// A rewritten history is detected by both proofs and VerifyChain.
+	trusted := heads[6].TreeHead
+	if head, err := verifyChain(ctx, log, 1, trusted); err != nil || head.TreeSize != 11 || head.RootHash != heads[11].RootHash {
+		t.Fatalf("verifyChain: %+v, %v", head, err)
+	}
+	log[1][3].Data = []byte("rewritten")
+	rewritten, _ := treeHead(ctx, log, 1)
+	p, _ := proveConsistency(ctx, log, 1, 6, 11)
+	if err := p.Verify(heads[6], SignTreeHead(rewritten, priv), pub); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+	if _, err := verifyChain(ctx, log, 1, trusted); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("expected ErrInvalidProof, got %v", err)
+	}
+	if _, err := verifyChain(ctx, log, 1, TreeHead{StreamID: 1, TreeSize: 12}); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("expected ErrInvalidProof for a shrunk stream, got %v", err)
+	}
+	log[1] = append(log[1][:4], log[1][5:]...)
+	if _, err := verifyChain(ctx, log, 1, TreeHead{}); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("expected ErrInvalidProof for a gap, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package kimberlite
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ProtoRuntime adapts a protobuf implementation to the SDK, which has
+// no protobuf dependency of its own. With google.golang.org/protobuf:
+//
+//	type protoRuntime struct{}
+//
+//	func (protoRuntime) Marshal(m any) ([]byte, error) { return proto.Marshal(m.(proto.Message)) }
+//	func (protoRuntime) Unmarshal(b []byte, m any) error { return proto.Unmarshal(b, m.(proto.Message)) }
+//	func (protoRuntime) MessageName(m any) string {
+//	    return string(proto.MessageName(m.(proto.Message)))
+//	}
+type ProtoRuntime interface {
+	Marshal(msg any) ([]byte, error)
+	Unmarshal(data []byte, msg any) error
+	// MessageName returns the fully-qualified message name, e.g.
+	// "acme.clinical.v1.PatientAdmitted".
+	MessageName(msg any) string
+}
+
+// ProtoContentType is the media type recorded for protobuf payloads.
+const ProtoContentType = "application/x-protobuf"
+
+// metaProtoMessage is the envelope metadata key holding the
+// fully-qualified protobuf message name. Other SDKs resolve payloads
+// through their own registries using this key.
+const metaProtoMessage = "proto_message"
+
+// ProtoCodec encodes protobuf messages and resolves them on read by
+// their fully-qualified name, so events written by the Rust or Python
+// SDKs decode without an event type registration on this side.
+type ProtoCodec struct {
+	rt ProtoRuntime
+
+	mu     sync.RWMutex
+	byName map[string]reflect.Type
+}
+
+var (
+	_ MetadataCodec  = (*ProtoCodec)(nil)
+	_ ResolvingCodec = (*ProtoCodec)(nil)
+)
+
+// NewProtoCodec returns a protobuf codec backed by rt.
+func NewProtoCodec(rt ProtoRuntime) *ProtoCodec {
+	return &ProtoCodec{rt: rt, byName: make(map[string]reflect.Type)}
+}
+
+// ContentType implements Codec.
+func (p *ProtoCodec) ContentType() string { return ProtoContentType }
+
+// Marshal implements Codec.
+func (p *ProtoCodec) Marshal(v any) ([]byte, error) { return p.rt.Marshal(v) }
+
+// Unmarshal implements Codec.
+func (p *ProtoCodec) Unmarshal(data []byte, v any) error { return p.rt.Unmarshal(data, v) }
+
+// Metadata implements MetadataCodec, recording the message name.
+func (p *ProtoCodec) Metadata(v any) map[string]string {
+	return map[string]string{metaProtoMessage: p.rt.MessageName(v)}
+}
+
+// New implements ResolvingCodec, resolving the recorded message name
+// against the registered message types.
+func (p *ProtoCodec) New(meta map[string]string) (any, bool) {
+	p.mu.RLock()
+	t, ok := p.byName[meta[metaProtoMessage]]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return reflect.New(t).Interface(), true
+}
+
+// Register adds message types to the codec's name registry, given a
+// pointer to a message of each type, and returns their names.
+func (p *ProtoCodec) Register(prototypes ...any) []string {
+	names := make([]string, len(prototypes))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, m := range prototypes {
+		t := reflect.TypeOf(m)
+		if t == nil || t.Kind() != reflect.Pointer {
+			panic(fmt.Sprintf("kimberlite: protobuf prototype must be a message pointer, got %T", m))
+		}
+		names[i] = p.rt.MessageName(m)
+		p.byName[names[i]] = t.Elem()
+	}
+	return names
+}
+
+// RegisterProto registers protobuf message types with codec and with
+// r, using each message's fully-qualified name as its event type.
+func (r *CodecRegistry) RegisterProto(codec *ProtoCodec, prototypes ...any) {
+	for i, name := range codec.Register(prototypes...) {
+		r.Register(name, prototypes[i], codec)
+	}
+}
@@ -0,0 +1,83 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// A purpose of use states why protected data is accessed, in the terms
+// of HIPAA's treatment, payment and operations or of a research
+// protocol. It travels with the AuditContext and is recorded in the
+// server's audit trail ahead of the reason:
+//
+//	ctx = kimberlite.WithPurpose(ctx, kimberlite.PurposeTreatment)
+//	events, err := client.ReadEventsContext(ctx, vitals, 0, 1<<20)
+//
+// With WithPurposeRequired, reads of DataClassRestricted streams, and
+// of streams whose class the client does not know, fail with
+// ErrPurposeRequired unless the context states a purpose.
+
+// Purpose is a purpose-of-use code.
+type Purpose string
+
+// Common purposes of use. Other codes may be used as well.
+const (
+	PurposeTreatment  Purpose = "treatment"
+	PurposeBilling    Purpose = "billing"
+	PurposeOperations Purpose = "operations"
+	PurposeResearch   Purpose = "research"
+	// PurposeEmergency is the purpose of break-glass access.
+	PurposeEmergency Purpose = "emergency"
+)
+
+// ErrPurposeRequired is returned for reads that need a purpose of use
+// and have none.
+var ErrPurposeRequired = errors.New("kimberlite: purpose of use required")
+
+// WithPurpose returns a derived context whose operations are made for
+// purpose. It sets the Purpose of the context's AuditContext, adding
+// one if there is none.
+func WithPurpose(parent context.Context, purpose Purpose) context.Context {
+	audit, _ := AuditFromContext(parent)
+	audit.Purpose = purpose
+	return WithAudit(parent, audit)
+}
+
+// PurposeFromContext returns the purpose of use of ctx, if any.
+func PurposeFromContext(ctx context.Context) (Purpose, bool) {
+	audit, _ := AuditFromContext(ctx)
+	return audit.Purpose, audit.Purpose != ""
+}
+
+// WithPurposeRequired makes the client refuse reads of Restricted
+// streams, and of streams of unknown class, without a purpose of use.
+func WithPurposeRequired() Option {
+	return func(c *Client) { c.requirePurpose = true }
+}
+
+// checkPurpose enforces WithPurposeRequired for a read of stream.
+func (c *Client) checkPurpose(ctx context.Context, stream StreamID) error {
+	if !c.requirePurpose {
+		return nil
+	}
+	if _, ok := PurposeFromContext(ctx); ok {
+		return nil
+	}
+	if class, known := c.streams.get(stream); known && class != DataClassRestricted {
+		return nil
+	}
+	return fmt.Errorf("%w: read of stream %d", ErrPurposeRequired, stream)
+}
+
+// wireReason returns the reason recorded by the server: the reason,
+// preceded by the purpose of use in brackets if there is one.
+func (a AuditContext) wireReason() string {
+	if a.Purpose == "" {
+		return a.Reason
+	}
+	if a.Reason == "" {
+		return "[" + string(a.Purpose) + "]"
+	}
+	return "[" + string(a.Purpose) + "] " + a.Reason
+}
@@ -0,0 +1,56 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPurposeRequired(t *testing.T) {
+	c := &Client{requirePurpose: true}
+	c.SetStreamClass(1, DataClassRestricted)
+	c.SetStreamClass(2, DataClassInternal)
+	ctx := context.Background()
+	if err := c.checkPurpose(ctx, 1); !errors.Is(err, ErrPurposeRequired) {
+		t.Fatalf("expected ErrPurposeRequired, got %v", err)
+	}
+	if err := c.checkPurpose(ctx, 3); !errors.Is(err, ErrPurposeRequired) {
+		t.Fatalf("expected ErrPurposeRequired for unknown class, got %v", err)
+	}
+	if err := c.checkPurpose(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.checkPurpose(WithPurpose(ctx, PurposeTreatment), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Client{}).checkPurpose(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.readEventsContext(ctx, 1, 0, 1024, true); !errors.Is(err, ErrPurposeRequired) {
+		t.Fatalf("read: expected ErrPurposeRequired, got %v", err)
+	}
+	if code := ErrorCode(c.checkPurpose(ctx, 1)); code != "purpose_required" {
+		t.Fatalf("code %q", code)
+	}
+}
+
+func TestWithPurpose(t *testing.T) {
+	ctx := WithAudit(context.Background(), AuditContext{Actor: "dr-smith", Reason: "follow-up"})
+	ctx = WithPurpose(ctx, PurposeTreatment)
+	audit, _ := AuditFromContext(ctx)
+	if audit.Actor != "dr-smith" || audit.Purpose != PurposeTreatment {
+		t.Fatalf("audit %+v", audit)
+	}
+	if got := audit.wireReason(); got != "[treatment] follow-up" {
+		t.Fatalf("wire reason %q", got)
+	}
+	if got := (AuditContext{Purpose: PurposeResearch}).wireReason(); got != "[research]" {
+		t.Fatalf("wire reason %q", got)
+	}
+	if got := (AuditContext{Reason: "x"}).wireReason(); got != "x" {
+		t.Fatalf("wire reason %q", got)
+	}
+	if p, ok := PurposeFromContext(WithPurpose(context.Background(), PurposeBilling)); !ok || p != PurposeBilling {
+		t.Fatalf("purpose %q %v", p, ok)
+	}
+}
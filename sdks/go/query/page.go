@@ -0,0 +1,268 @@
+package query
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// ErrInvalidToken is returned by Page for continuation tokens it did
+// not produce for the same statement.
+var ErrInvalidToken = errors.New("kimberlite: invalid continuation token")
+
+// Page is one page of the rows of a statement.
+type Page struct {
+	*kimberlite.QueryResult
+	// ContinuationToken fetches the page after this one when passed to
+	// Page. It is empty on a page with fewer rows than the limit; a
+	// full last page has a token, and the page after it no rows.
+	ContinuationToken string
+}
+
+// Snapshot makes Page read every page of the statement as of the time
+// its first page was read, so that rows written while a client pages
+// through the results neither appear on later pages nor, if deleted,
+// go missing from them. The time is the client's clock; reads as of a
+// time resolve as QueryAsOf's do.
+func (s *SelectBuilder) Snapshot() *SelectBuilder {
+	s.snapshot = true
+	return s
+}
+
+// Page runs the statement for the page of rows after the one token ends,
+// or for the first page if token is empty, and returns it with the
+// token for the next:
+//
+//	page, err := query.Select("id", "ward", "admitted_at").
+//	    From("admissions").
+//	    Where(query.Eq("ward", ward)).
+//	    OrderByDesc("admitted_at").OrderBy("id").
+//	    Limit(50).
+//	    Page(ctx, client, r.URL.Query().Get("page_token"))
+//
+// Pages are found by the values of the ordering columns, not by
+// counting rows, so rows appended or deleted between requests do not
+// shift later pages onto rows already returned; rows appended after
+// the last row returned appear on later pages, unless the builder has
+// Snapshot set. The statement must have a Limit, the page size, and no
+// Offset, and its orderings must be of selected, non-NULL columns that
+// together identify a row, such as a primary key last.
+//
+// Tokens are opaque, URL-safe and not encrypted: they hold the values
+// of the ordering columns of the last row of a page. Page rejects with
+// ErrInvalidToken tokens made for other statements or arguments.
+func (s *SelectBuilder) Page(ctx context.Context, c *kimberlite.Client, token string) (*Page, error) {
+	if err := s.checkPage(); err != nil {
+		return nil, err
+	}
+	base, baseArgs, err := s.build(nil, -1)
+	if err != nil {
+		return nil, err
+	}
+	st := pageToken{Version: 1, Statement: fingerprint(base, baseArgs)}
+	var extra []Cond
+	if token != "" {
+		prev, err := decodePageToken(token)
+		if err != nil || prev.Statement != st.Statement || len(prev.Key) != len(s.orderBy) {
+			return nil, ErrInvalidToken
+		}
+		key := make([]kimberlite.Value, len(prev.Key))
+		for i, k := range prev.Key {
+			if key[i], err = k.value(); err != nil {
+				return nil, ErrInvalidToken
+			}
+		}
+		if s.snapshot && prev.AsOf == 0 {
+			return nil, ErrInvalidToken
+		}
+		st.AsOf = prev.AsOf
+		extra = []Cond{s.after(key)}
+	} else if s.snapshot {
+		st.AsOf = time.Now().UnixNano()
+	}
+	sql, args, err := s.build(extra, s.limit)
+	if err != nil {
+		return nil, err
+	}
+	var r *kimberlite.QueryResult
+	if s.snapshot {
+		r, err = c.QueryAsOfContext(ctx, kimberlite.AsOf(time.Unix(0, st.AsOf)), sql, args...)
+	} else {
+		r, err = c.QueryContext(ctx, sql, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{QueryResult: r}
+	if len(r.Rows) < s.limit {
+		return page, nil
+	}
+	last := r.Rows[len(r.Rows)-1]
+	for _, o := range s.orderBy {
+		k, err := newKeyValue(last[columnName(o.column)])
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: page by %s: %w", o.column, err)
+		}
+		st.Key = append(st.Key, k)
+	}
+	if page.ContinuationToken, err = st.encode(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *SelectBuilder) checkPage() error {
+	switch {
+	case s.err != nil:
+		return s.err
+	case s.limit <= 0:
+		return fmt.Errorf("kimberlite: page without a limit")
+	case s.offset >= 0:
+		return fmt.Errorf("kimberlite: page with an offset")
+	case len(s.orderBy) == 0:
+		return fmt.Errorf("kimberlite: page without an ordering")
+	}
+	if len(s.columns) > 0 {
+		for _, o := range s.orderBy {
+			if !slices.Contains(s.columns, o.column) {
+				return fmt.Errorf("kimberlite: page ordered by %s, which is not selected", o.column)
+			}
+		}
+	}
+	return nil
+}
+
+// after is the condition on the ordering columns that holds for the
+// rows after one whose ordering columns hold key: those after it in
+// the first column, or equal in it and after it in the next, and so on.
+func (s *SelectBuilder) after(key []kimberlite.Value) Cond {
+	alts := make([]Cond, len(s.orderBy))
+	for i, o := range s.orderBy {
+		conds := make([]Cond, 0, i+1)
+		for j := 0; j < i; j++ {
+			conds = append(conds, Eq(s.orderBy[j].column, key[j]))
+		}
+		if o.desc {
+			conds = append(conds, Lt(o.column, key[i]))
+		} else {
+			conds = append(conds, Gt(o.column, key[i]))
+		}
+		alts[i] = And(conds...)
+	}
+	return Or(alts...)
+}
+
+// columnName returns the name a column has in result rows, without
+// its table.
+func columnName(column string) string {
+	return column[strings.LastIndexByte(column, '.')+1:]
+}
+
+// pageToken is the content of a continuation token.
+type pageToken struct {
+	Version int `json:"v"`
+	// Statement is a fingerprint of the statement and its arguments.
+	Statement string `json:"q"`
+	// Key holds the ordering columns of the last row of the page.
+	Key []keyValue `json:"k"`
+	// AsOf is the time, in Unix nanoseconds, a snapshot is read as of.
+	AsOf int64 `json:"t,omitempty"`
+}
+
+func (t pageToken) encode() (string, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("kimberlite: encode continuation token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodePageToken(token string) (pageToken, error) {
+	var t pageToken
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(b, &t) != nil || t.Version != 1 {
+		return t, ErrInvalidToken
+	}
+	return t, nil
+}
+
+// keyValue is a value of an ordering column in a token. Integers and
+// timestamps are held as strings, so they keep their precision.
+type keyValue struct {
+	Type  kimberlite.ValueType `json:"t"`
+	Value json.RawMessage      `json:"v"`
+}
+
+func newKeyValue(v kimberlite.Value) (keyValue, error) {
+	var x any
+	switch v.Type {
+	case kimberlite.ValueTypeInteger:
+		x = strconv.FormatInt(v.AsInt(), 10)
+	case kimberlite.ValueTypeFloat:
+		x = v.AsFloat()
+	case kimberlite.ValueTypeText:
+		x = v.AsText()
+	case kimberlite.ValueTypeBoolean:
+		x = v.AsBool()
+	case kimberlite.ValueTypeTimestamp:
+		x = strconv.FormatInt(v.AsTimestamp().UnixNano(), 10)
+	case kimberlite.ValueTypeNull:
+		return keyValue{}, fmt.Errorf("NULL in the last row of the page")
+	default:
+		return keyValue{}, fmt.Errorf("cannot page by %s values", v.Type)
+	}
+	raw, err := json.Marshal(x)
+	return keyValue{Type: v.Type, Value: raw}, err
+}
+
+func (k keyValue) value() (kimberlite.Value, error) {
+	var err error
+	switch k.Type {
+	case kimberlite.ValueTypeInteger, kimberlite.ValueTypeTimestamp:
+		var s string
+		var n int64
+		if err = json.Unmarshal(k.Value, &s); err == nil {
+			n, err = strconv.ParseInt(s, 10, 64)
+		}
+		if k.Type == kimberlite.ValueTypeTimestamp {
+			return kimberlite.NewTimestamp(time.Unix(0, n).UTC()), err
+		}
+		return kimberlite.NewInt(n), err
+	case kimberlite.ValueTypeFloat:
+		var f float64
+		err = json.Unmarshal(k.Value, &f)
+		return kimberlite.NewFloat(f), err
+	case kimberlite.ValueTypeText:
+		var s string
+		err = json.Unmarshal(k.Value, &s)
+		return kimberlite.NewText(s), err
+	case kimberlite.ValueTypeBoolean:
+		var b bool
+		err = json.Unmarshal(k.Value, &b)
+		return kimberlite.NewBool(b), err
+	}
+	return kimberlite.Value{}, fmt.Errorf("kimberlite: unexpected %s value in token", k.Type)
+}
+
+// fingerprint returns a short digest of a statement and its arguments.
+func fingerprint(sql string, args []kimberlite.Value) string {
+	h := sha256.New()
+	h.Write([]byte(sql))
+	for _, a := range args {
+		if a.Type == kimberlite.ValueTypeTimestamp {
+			fmt.Fprintf(h, "\x00%d:%d", a.Type, a.AsTimestamp().UnixNano())
+		} else {
+			fmt.Fprintf(h, "\x00%d:%v", a.Type, a)
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:12])
+}
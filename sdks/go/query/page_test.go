@@ -0,0 +1,127 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/kimberlitetest"
+)
+
+func TestPage(t *testing.T) {
+	ctx := context.Background()
+	client := kimberlitetest.NewMockClient()
+	for _, sql := range []string{
+		"CREATE TABLE admissions (id BIGINT NOT NULL PRIMARY KEY, ward TEXT, day BIGINT)",
+		"INSERT INTO admissions (id, ward, day) VALUES (1, 'icu', 3), (2, 'icu', 1), (3, 'er', 2), (4, 'icu', 3), (5, 'icu', 2)",
+	} {
+		if _, err := client.ExecContext(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sel := func() *SelectBuilder {
+		return Select("id", "day").From("admissions").Where(Eq("ward", "icu")).OrderByDesc("day").OrderBy("id").Limit(2)
+	}
+	var got []int64
+	token := ""
+	for n := 0; ; n++ {
+		page, err := sel().Page(ctx, client, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range page.Rows {
+			got = append(got, row["id"].AsInt())
+		}
+		if n == 0 {
+			// Rows written between pages before the last row returned do
+			// not shift the later pages.
+			if _, err := client.ExecContext(ctx, "INSERT INTO admissions (id, ward, day) VALUES (6, 'icu', 4), (7, 'icu', 0)"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if token = page.ContinuationToken; token == "" {
+			break
+		}
+	}
+	if want := []int64{1, 4, 5, 2, 7}; !slices.Equal(got, want) {
+		t.Fatalf("ids = %v, want %v", got, want)
+	}
+
+	first, err := sel().Page(ctx, client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"garbage":         "not a token",
+		"other statement": first.ContinuationToken,
+	} {
+		other := Select("id", "day").From("admissions").Where(Eq("ward", "er")).OrderByDesc("day").OrderBy("id").Limit(2)
+		if name == "garbage" {
+			other = sel()
+		}
+		if _, err := other.Page(ctx, client, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for name, s := range map[string]*SelectBuilder{
+		"no limit":        Select().From("admissions").OrderBy("id"),
+		"offset":          Select().From("admissions").OrderBy("id").Limit(2).Offset(2),
+		"no ordering":     Select().From("admissions").Limit(2),
+		"ordering column": Select("day").From("admissions").OrderBy("id").Limit(2),
+	} {
+		if _, err := s.Page(ctx, client, ""); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// asOfBackend serves queries from a store, ignoring their time-travel
+// clauses, which it records.
+type asOfBackend struct {
+	*kimberlitetest.Store
+	clauses []string
+}
+
+func (b *asOfBackend) Query(sql string, args []kimberlite.Value) (*kimberlite.QueryResult, error) {
+	sql, clause, _ := strings.Cut(sql, "\n AS OF ")
+	b.clauses = append(b.clauses, clause)
+	return b.Store.Query(sql, args)
+}
+
+func TestPageSnapshot(t *testing.T) {
+	ctx := context.Background()
+	b := &asOfBackend{Store: kimberlitetest.NewStore()}
+	client, err := kimberlite.Connect("test", kimberlite.WithTenant(1), kimberlite.WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range []string{
+		"CREATE TABLE admissions (id BIGINT NOT NULL PRIMARY KEY)",
+		"INSERT INTO admissions (id) VALUES (1), (2), (3)",
+	} {
+		if _, err := client.ExecContext(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sel := func() *SelectBuilder { return Select().From("admissions").OrderBy("id").Limit(2).Snapshot() }
+	page, err := sel().Page(ctx, client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sel().Page(ctx, client, page.ContinuationToken); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.clauses) != 2 || b.clauses[0] == "" || b.clauses[1] != b.clauses[0] {
+		t.Fatalf("clauses = %q", b.clauses)
+	}
+	unpinned, err := Select().From("admissions").OrderBy("id").Limit(2).Page(ctx, client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sel().Page(ctx, client, unpinned.ContinuationToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("unpinned token: %v", err)
+	}
+}
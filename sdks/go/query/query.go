@@ -0,0 +1,460 @@
+// Package query builds parameterised SELECT statements in Kimberlite's
+// SQL dialect, in place of assembling them from strings:
+//
+//	sql, args, err := query.Select("id", "name", "admitted_at").
+//	    From("patients").
+//	    Where(query.Eq("ward", "icu"), query.Gt("admitted_at", since)).
+//	    OrderByDesc("admitted_at").
+//	    Limit(50).
+//	    Build()
+//	r, err := client.QueryContext(ctx, sql, args...)
+//
+// produces
+//
+//	SELECT id, name, admitted_at FROM patients
+//	WHERE ward = $1 AND admitted_at > $2 ORDER BY admitted_at DESC LIMIT 50
+//
+// Values are converted with kimberlite.ValueOf and bound as parameters,
+// never written into the SQL, except LIKE patterns, which the server
+// takes only as string literals and which are quoted. Table and column
+// names must be plain identifiers, optionally qualified by a table
+// name, or the statement fails to build.
+package query
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// SelectBuilder builds a SELECT statement. Its methods modify and
+// return the builder, so calls chain; a builder is not safe for
+// concurrent use.
+type SelectBuilder struct {
+	columns []string
+	table   string
+	where   []Cond
+	orderBy []ordering
+	limit   int
+	offset  int
+	// snapshot makes Page read as of the time of the first page.
+	snapshot bool
+	err      error
+}
+
+type ordering struct {
+	column string
+	desc   bool
+}
+
+// Select starts a SELECT of columns, or of every column if there are
+// none.
+func Select(columns ...string) *SelectBuilder {
+	s := &SelectBuilder{limit: -1, offset: -1}
+	for _, col := range columns {
+		s.check(col)
+	}
+	s.columns = append(s.columns, columns...)
+	return s
+}
+
+// From sets the table selected from.
+func (s *SelectBuilder) From(table string) *SelectBuilder {
+	s.check(table)
+	s.table = table
+	return s
+}
+
+// Where adds conditions rows must meet, combined with AND with each
+// other and with those of earlier calls.
+func (s *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	s.where = append(s.where, conds...)
+	return s
+}
+
+// OrderBy orders the rows by column, ascending, after the orderings of
+// earlier calls.
+func (s *SelectBuilder) OrderBy(column string) *SelectBuilder {
+	s.check(column)
+	s.orderBy = append(s.orderBy, ordering{column: column})
+	return s
+}
+
+// OrderByDesc orders the rows by column, descending, after the
+// orderings of earlier calls.
+func (s *SelectBuilder) OrderByDesc(column string) *SelectBuilder {
+	s.check(column)
+	s.orderBy = append(s.orderBy, ordering{column: column, desc: true})
+	return s
+}
+
+// Limit sets the most rows returned.
+func (s *SelectBuilder) Limit(n int) *SelectBuilder {
+	if n < 0 && s.err == nil {
+		s.err = fmt.Errorf("kimberlite: negative limit %d", n)
+	}
+	s.limit = n
+	return s
+}
+
+// Offset sets the number of rows skipped before those returned.
+func (s *SelectBuilder) Offset(n int) *SelectBuilder {
+	if n < 0 && s.err == nil {
+		s.err = fmt.Errorf("kimberlite: negative offset %d", n)
+	}
+	s.offset = n
+	return s
+}
+
+// Build returns the statement and its arguments, or the first error
+// the builder met.
+func (s *SelectBuilder) Build() (string, []kimberlite.Value, error) {
+	return s.build(nil, s.limit)
+}
+
+// build builds the statement with the extra conditions and limit.
+func (s *SelectBuilder) build(extra []Cond, limit int) (string, []kimberlite.Value, error) {
+	if s.err != nil {
+		return "", nil, s.err
+	}
+	if s.table == "" {
+		return "", nil, fmt.Errorf("kimberlite: select without a table")
+	}
+	b := &builder{}
+	b.sql.WriteString("SELECT ")
+	if len(s.columns) == 0 {
+		b.sql.WriteByte('*')
+	} else {
+		b.sql.WriteString(strings.Join(s.columns, ", "))
+	}
+	b.sql.WriteString(" FROM " + s.table)
+	if where := append(s.where[:len(s.where):len(s.where)], extra...); len(where) > 0 {
+		b.sql.WriteString(" WHERE ")
+		b.join(where, " AND ", false)
+	}
+	for i, o := range s.orderBy {
+		if i == 0 {
+			b.sql.WriteString(" ORDER BY ")
+		} else {
+			b.sql.WriteString(", ")
+		}
+		b.sql.WriteString(o.column)
+		if o.desc {
+			b.sql.WriteString(" DESC")
+		}
+	}
+	if limit >= 0 {
+		b.sql.WriteString(" LIMIT " + strconv.Itoa(limit))
+	}
+	if s.offset >= 0 {
+		b.sql.WriteString(" OFFSET " + strconv.Itoa(s.offset))
+	}
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	return b.sql.String(), b.args, nil
+}
+
+// Query builds the statement and runs it on c.
+func (s *SelectBuilder) Query(ctx context.Context, c *kimberlite.Client) (*kimberlite.QueryResult, error) {
+	sql, args, err := s.Build()
+	if err != nil {
+		return nil, err
+	}
+	return c.QueryContext(ctx, sql, args...)
+}
+
+func (s *SelectBuilder) check(name string) {
+	if s.err == nil {
+		s.err = checkName(name)
+	}
+}
+
+// Cond is a condition of a WHERE clause.
+type Cond interface {
+	build(b *builder)
+}
+
+// builder accumulates a statement's SQL and arguments.
+type builder struct {
+	sql  strings.Builder
+	args []kimberlite.Value
+	err  error
+}
+
+func (b *builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// arg binds x as the next parameter and writes its placeholder.
+func (b *builder) arg(x any) {
+	v, err := kimberlite.ValueOf(x)
+	if err != nil {
+		b.fail(err)
+		return
+	}
+	b.args = append(b.args, v)
+	b.sql.WriteString("$" + strconv.Itoa(len(b.args)))
+}
+
+// join writes conds separated by sep, in parentheses if nested and
+// there is more than one.
+func (b *builder) join(conds []Cond, sep string, nested bool) {
+	paren := nested && len(conds) > 1
+	if paren {
+		b.sql.WriteByte('(')
+	}
+	for i, c := range conds {
+		if i > 0 {
+			b.sql.WriteString(sep)
+		}
+		c.build(b)
+	}
+	if paren {
+		b.sql.WriteByte(')')
+	}
+}
+
+type compare struct {
+	column, op string
+	value      any
+}
+
+func (c compare) build(b *builder) {
+	if err := checkName(c.column); err != nil {
+		b.fail(err)
+	}
+	b.sql.WriteString(c.column + " " + c.op + " ")
+	b.arg(c.value)
+}
+
+// Eq is column = value. A nil value matches no rows, as in SQL; use
+// IsNull for NULL columns.
+func Eq(column string, value any) Cond { return compare{column, "=", value} }
+
+// Ne is column <> value.
+func Ne(column string, value any) Cond { return compare{column, "<>", value} }
+
+// Lt is column < value.
+func Lt(column string, value any) Cond { return compare{column, "<", value} }
+
+// Le is column <= value.
+func Le(column string, value any) Cond { return compare{column, "<=", value} }
+
+// Gt is column > value.
+func Gt(column string, value any) Cond { return compare{column, ">", value} }
+
+// Ge is column >= value.
+func Ge(column string, value any) Cond { return compare{column, ">=", value} }
+
+type in struct {
+	column string
+	not    bool
+	values []any
+}
+
+func (c in) build(b *builder) {
+	if err := checkName(c.column); err != nil {
+		b.fail(err)
+	}
+	if len(c.values) == 0 {
+		b.fail(fmt.Errorf("kimberlite: %s IN with no values", c.column))
+	}
+	b.sql.WriteString(c.column)
+	if c.not {
+		b.sql.WriteString(" NOT")
+	}
+	b.sql.WriteString(" IN (")
+	for i, v := range c.values {
+		if i > 0 {
+			b.sql.WriteString(", ")
+		}
+		b.arg(v)
+	}
+	b.sql.WriteByte(')')
+}
+
+// In is column IN (values...). There must be at least one value.
+func In(column string, values ...any) Cond { return in{column: column, values: values} }
+
+// NotIn is column NOT IN (values...). There must be at least one value.
+func NotIn(column string, values ...any) Cond { return in{column: column, not: true, values: values} }
+
+type between struct {
+	column    string
+	not       bool
+	low, high any
+}
+
+func (c between) build(b *builder) {
+	if err := checkName(c.column); err != nil {
+		b.fail(err)
+	}
+	b.sql.WriteString(c.column)
+	if c.not {
+		b.sql.WriteString(" NOT")
+	}
+	b.sql.WriteString(" BETWEEN ")
+	b.arg(c.low)
+	b.sql.WriteString(" AND ")
+	b.arg(c.high)
+}
+
+// Between is column BETWEEN low AND high, both inclusive.
+func Between(column string, low, high any) Cond { return between{column: column, low: low, high: high} }
+
+// NotBetween is column NOT BETWEEN low AND high.
+func NotBetween(column string, low, high any) Cond {
+	return between{column: column, not: true, low: low, high: high}
+}
+
+type like struct {
+	column, op, pattern string
+}
+
+func (c like) build(b *builder) {
+	if err := checkName(c.column); err != nil {
+		b.fail(err)
+	}
+	b.sql.WriteString(c.column + " " + c.op + " '" + strings.ReplaceAll(c.pattern, "'", "''") + "'")
+}
+
+// Like is column LIKE pattern, with % matching any run of characters
+// and _ any one character.
+func Like(column, pattern string) Cond { return like{column, "LIKE", pattern} }
+
+// NotLike is column NOT LIKE pattern.
+func NotLike(column, pattern string) Cond { return like{column, "NOT LIKE", pattern} }
+
+// ILike is Like ignoring case.
+func ILike(column, pattern string) Cond { return like{column, "ILIKE", pattern} }
+
+type contains struct {
+	column string
+	doc    any
+	err    error
+}
+
+func (c contains) build(b *builder) {
+	if err := checkName(c.column); err != nil {
+		b.fail(err)
+	}
+	if c.err != nil {
+		b.fail(c.err)
+	}
+	doc, err := kimberlite.NewJSON(c.doc)
+	if err != nil {
+		b.fail(err)
+	}
+	b.sql.WriteString(c.column + " @> ")
+	b.arg(doc)
+}
+
+// JSONContains is column @> doc: the JSON document in column contains
+// doc, encoded with encoding/json. An object contains the objects whose
+// members it contains, an array the arrays whose elements it contains,
+// and any other value the values equal to it.
+func JSONContains(column string, doc any) Cond { return contains{column: column, doc: doc} }
+
+// JSONEq holds when the value at path in the JSON document in column,
+// such as "$.patient.id", equals value:
+//
+//	query.JSONEq("payload", "$.patient.id", 7)
+//
+// is payload @> $1 with $1 the document {"patient":{"id":7}}. The server
+// extracts JSON values in WHERE clauses only by containment or by a
+// single key, so the path must name object members, not array
+// elements, and an object or array value matches by containment rather
+// than equality. Decode values out of returned documents with
+// kimberlite.Value.Path.
+func JSONEq(column, path string, value any) Cond {
+	p, err := kimberlite.ParseJSONPath(path)
+	if err != nil {
+		return contains{column: column, err: err}
+	}
+	steps := p.Steps()
+	if len(steps) == 0 {
+		return contains{column: column, err: fmt.Errorf("kimberlite: json path %q names the whole document", path)}
+	}
+	doc := value
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].IsIndex {
+			return contains{column: column, err: fmt.Errorf("kimberlite: json path %q: array elements cannot be matched", path)}
+		}
+		doc = map[string]any{steps[i].Key: doc}
+	}
+	return contains{column: column, doc: doc}
+}
+
+type null struct {
+	column string
+	not    bool
+}
+
+func (c null) build(b *builder) {
+	if err := checkName(c.column); err != nil {
+		b.fail(err)
+	}
+	b.sql.WriteString(c.column + " IS ")
+	if c.not {
+		b.sql.WriteString("NOT ")
+	}
+	b.sql.WriteString("NULL")
+}
+
+// IsNull is column IS NULL.
+func IsNull(column string) Cond { return null{column: column} }
+
+// IsNotNull is column IS NOT NULL.
+func IsNotNull(column string) Cond { return null{column: column, not: true} }
+
+type junction struct {
+	sep   string
+	conds []Cond
+}
+
+func (c junction) build(b *builder) {
+	if len(c.conds) == 0 {
+		b.fail(fmt.Errorf("kimberlite: %s of no conditions", strings.TrimSpace(c.sep)))
+		return
+	}
+	b.join(c.conds, c.sep, true)
+}
+
+// And holds when every one of conds does. There must be at least one.
+func And(conds ...Cond) Cond { return junction{" AND ", conds} }
+
+// Or holds when any one of conds does. There must be at least one.
+func Or(conds ...Cond) Cond { return junction{" OR ", conds} }
+
+// checkName checks that name is an identifier, optionally qualified by
+// a table name, safe to write into SQL as it is.
+func checkName(name string) error {
+	for _, part := range strings.Split(name, ".") {
+		if !isIdentifier(part) {
+			return fmt.Errorf("kimberlite: %q is not a plain identifier", name)
+		}
+	}
+	return nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
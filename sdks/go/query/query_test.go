@@ -0,0 +1,118 @@
+package query
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/kimberlitetest"
+)
+
+func TestBuild(t *testing.T) {
+	sql, args, err := Select("id", "name").
+		From("patients").
+		Where(Eq("ward", "icu"), Or(Gt("age", 65), In("risk", "high", "severe"))).
+		Where(Like("name", "O'%"), IsNotNull("admitted_at")).
+		OrderByDesc("admitted_at").
+		OrderBy("id").
+		Limit(50).
+		Offset(100).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT id, name FROM patients WHERE ward = $1 AND (age > $2 OR risk IN ($3, $4)) AND name LIKE 'O''%' AND admitted_at IS NOT NULL ORDER BY admitted_at DESC, id LIMIT 50 OFFSET 100"
+	if sql != want {
+		t.Fatalf("sql = %q", sql)
+	}
+	wantArgs := []kimberlite.Value{kimberlite.NewText("icu"), kimberlite.NewInt(65), kimberlite.NewText("high"), kimberlite.NewText("severe")}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("args = %v", args)
+	}
+
+	sql, args, err = Select().From("wards").Where(NotBetween("beds", 1, 10)).Build()
+	if err != nil || sql != "SELECT * FROM wards WHERE beds NOT BETWEEN $1 AND $2" || len(args) != 2 {
+		t.Fatalf("sql = %q, args = %v, err = %v", sql, args, err)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for name, s := range map[string]*SelectBuilder{
+		"no table":       Select("id"),
+		"bad column":     Select("id; DROP TABLE patients").From("patients"),
+		"bad table":      Select().From("patients p"),
+		"bad condition":  Select().From("patients").Where(Eq("1=1 OR id", 1)),
+		"empty IN":       Select().From("patients").Where(In("id")),
+		"empty OR":       Select().From("patients").Where(Or()),
+		"bad value":      Select().From("patients").Where(Eq("id", struct{}{})),
+		"negative limit": Select().From("patients").Limit(-1),
+	} {
+		if sql, _, err := s.Build(); err == nil {
+			t.Errorf("%s: built %q", name, sql)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	client := kimberlitetest.NewMockClient()
+	for _, sql := range []string{
+		"CREATE TABLE patients (id BIGINT NOT NULL PRIMARY KEY, ward TEXT)",
+		"INSERT INTO patients (id, ward) VALUES (1, 'icu'), (2, 'er'), (3, 'icu')",
+	} {
+		if _, err := client.ExecContext(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := Select("id").From("patients").Where(Eq("ward", "icu")).OrderByDesc("id").Query(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Rows) != 2 || r.Rows[0]["id"] != kimberlite.NewInt(3) {
+		t.Fatalf("rows = %v", r.Rows)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	sql, args, err := Select("id").From("events").
+		Where(JSONEq("payload", "$.patient.id", 7), JSONContains("payload", map[string]any{"tags": []string{"icu"}})).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != "SELECT id FROM events WHERE payload @> $1 AND payload @> $2" {
+		t.Fatalf("sql = %q", sql)
+	}
+	if string(args[0].AsRawJSON()) != `{"patient":{"id":7}}` || string(args[1].AsRawJSON()) != `{"tags":["icu"]}` {
+		t.Fatalf("args = %s, %s", args[0].AsRawJSON(), args[1].AsRawJSON())
+	}
+	for _, path := range []string{"$", "$.allergies[0]", "$.a["} {
+		if sql, _, err := Select().From("events").Where(JSONEq("payload", path, 1)).Build(); err == nil {
+			t.Errorf("%s: built %q", path, sql)
+		}
+	}
+
+	ctx := context.Background()
+	client := kimberlitetest.NewMockClient()
+	for _, sql := range []string{
+		"CREATE TABLE events (id BIGINT NOT NULL PRIMARY KEY, payload JSON)",
+		`INSERT INTO events (id, payload) VALUES (1, '{"patient": {"id": 7, "ward": "icu"}, "tags": ["icu", "night"]}'), (2, '{"patient": {"id": 8}}')`,
+	} {
+		if _, err := client.ExecContext(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := Select("id", "payload").From("events").
+		Where(JSONEq("payload", "$.patient.id", 7), JSONContains("payload", map[string]any{"tags": []string{"night"}})).
+		Query(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Rows) != 1 || r.Rows[0]["id"] != kimberlite.NewInt(1) {
+		t.Fatalf("rows = %v", r.Rows)
+	}
+	if ward, err := r.Rows[0]["payload"].Path("$.patient.ward"); err != nil || ward.AsText() != "icu" {
+		t.Fatalf("ward = %v, %v", ward, err)
+	}
+}
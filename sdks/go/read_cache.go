@@ -0,0 +1,408 @@
+package kimberlite
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadCacheKey identifies a single ReadEvents call. Because the log is
+// append-only, a range that was filled to its byte budget can never
+// change, so the same key always yields the same events. Server is the
+// address the client connected to, so clients of different clusters
+// sharing a cache never read each other's ranges.
+type ReadCacheKey struct {
+	Server   string
+	Tenant   TenantID
+	Stream   StreamID
+	From     Offset
+	MaxBytes uint64
+}
+
+// ReadCache stores event ranges previously returned by ReadEvents.
+//
+// Implementations must be safe for concurrent use. The client only
+// calls Put for ranges that are known to be closed (the server stopped
+// because the byte budget was exhausted, not because it reached the
+// tail of the stream), so implementations never need to invalidate.
+type ReadCache interface {
+	// Get returns the cached events for key, if present.
+	Get(key ReadCacheKey) ([]Event, bool)
+	// Put stores events under key, evicting older entries as needed.
+	Put(key ReadCacheKey, events []Event)
+}
+
+// WithReadCache enables a local cache for ReadEvents.
+//
+// Cache hits are served without a server round-trip and therefore do
+// not appear in the server's access log. Intended for development and
+// projection rebuilds, not for reads that must be audited.
+func WithReadCache(cache ReadCache) Option {
+	return func(c *Client) {
+		c.readCache = cache
+	}
+}
+
+// rangeClosed reports whether a read result filled its byte budget,
+// meaning the server stopped on size rather than at the end of stream.
+func rangeClosed(events []Event, maxBytes uint64) bool {
+	if len(events) == 0 {
+		return false
+	}
+	return eventsSize(events) >= maxBytes
+}
+
+func eventsSize(events []Event) uint64 {
+	var n uint64
+	for _, e := range events {
+		n += uint64(len(e.Data))
+	}
+	return n
+}
+
+// MemoryReadCache is an in-memory LRU ReadCache bounded by total
+// payload bytes.
+type MemoryReadCache struct {
+	mu       sync.Mutex
+	maxBytes uint64
+	size     uint64
+	order    *list.List
+	entries  map[ReadCacheKey]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key    ReadCacheKey
+	events []Event
+	size   uint64
+}
+
+// NewMemoryReadCache creates an LRU cache holding at most maxBytes of
+// event payload.
+func NewMemoryReadCache(maxBytes uint64) *MemoryReadCache {
+	return &MemoryReadCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[ReadCacheKey]*list.Element),
+	}
+}
+
+// Get implements ReadCache.
+func (m *MemoryReadCache) Get(key ReadCacheKey) ([]Event, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(el)
+	return cloneEvents(el.Value.(*memoryCacheEntry).events), true
+}
+
+// Put implements ReadCache.
+func (m *MemoryReadCache) Put(key ReadCacheKey, events []Event) {
+	size := eventsSize(events)
+	if size > m.maxBytes {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		m.order.MoveToFront(el)
+		return
+	}
+	for m.size+size > m.maxBytes {
+		m.evictOldest()
+	}
+	el := m.order.PushFront(&memoryCacheEntry{key: key, events: cloneEvents(events), size: size})
+	m.entries[key] = el
+	m.size += size
+}
+
+// Len returns the number of cached ranges.
+func (m *MemoryReadCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *MemoryReadCache) evictOldest() {
+	el := m.order.Back()
+	if el == nil {
+		return
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	m.order.Remove(el)
+	delete(m.entries, entry.key)
+	m.size -= entry.size
+}
+
+// cloneEvents copies events and their payloads so cached data can't be
+// mutated through a slice handed to the caller.
+func cloneEvents(events []Event) []Event {
+	out := make([]Event, len(events))
+	for i, e := range events {
+		out[i] = e
+		out[i].Data = append([]byte(nil), e.Data...)
+	}
+	return out
+}
+
+// DiskReadCache is a ReadCache that persists ranges as files in a
+// directory, so cached ranges survive process restarts. Entries are
+// evicted least-recently-used once the directory exceeds maxBytes.
+// Ranges are read into memory on each hit; the cache does not map its
+// files.
+type DiskReadCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes uint64
+	size     uint64
+	order    *list.List
+	entries  map[ReadCacheKey]*list.Element
+}
+
+type diskCacheEntry struct {
+	key  ReadCacheKey
+	size uint64
+}
+
+const diskCacheExt = ".kmbrange"
+
+// NewDiskReadCache opens (creating if necessary) a cache rooted at dir.
+// Existing entries are indexed oldest-first by modification time;
+// entries of older versions, whose names do not record the server, are
+// removed.
+func NewDiskReadCache(dir string, maxBytes uint64) (*DiskReadCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("kimberlite: read cache: %w", err)
+	}
+	d := &DiskReadCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[ReadCacheKey]*list.Element),
+	}
+
+	dirents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: read cache: %w", err)
+	}
+	type found struct {
+		key   ReadCacheKey
+		size  uint64
+		mtime time.Time
+	}
+	var existing []found
+	for _, de := range dirents {
+		key, ok := parseDiskCacheName(de.Name())
+		if !ok {
+			if strings.HasSuffix(de.Name(), diskCacheExt) {
+				_ = os.Remove(filepath.Join(dir, de.Name()))
+			}
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		existing = append(existing, found{key: key, size: uint64(info.Size()), mtime: info.ModTime()})
+	}
+	sort.Slice(existing, func(i, j int) bool { return existing[i].mtime.Before(existing[j].mtime) })
+	for _, f := range existing {
+		d.entries[f.key] = d.order.PushFront(&diskCacheEntry{key: f.key, size: f.size})
+		d.size += f.size
+	}
+	for d.size > d.maxBytes {
+		d.evictOldest()
+	}
+	return d, nil
+}
+
+// Get implements ReadCache.
+func (d *DiskReadCache) Get(key ReadCacheKey) ([]Event, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	el, ok := d.entries[key]
+	if !ok {
+		return nil, false
+	}
+	path := d.path(key)
+	events, err := readDiskRange(path)
+	if err != nil {
+		// Corrupt or externally removed entry; drop it and miss.
+		d.remove(el)
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	d.order.MoveToFront(el)
+	return events, true
+}
+
+// Put implements ReadCache. Write failures are ignored: the cache is an
+// optimisation and the next read simply goes to the server.
+func (d *DiskReadCache) Put(key ReadCacheKey, events []Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.entries[key]; ok {
+		return
+	}
+	size, err := writeDiskRange(d.path(key), events)
+	if err != nil {
+		return
+	}
+	if size > d.maxBytes {
+		_ = os.Remove(d.path(key))
+		return
+	}
+	for d.size+size > d.maxBytes {
+		d.evictOldest()
+	}
+	d.entries[key] = d.order.PushFront(&diskCacheEntry{key: key, size: size})
+	d.size += size
+}
+
+func (d *DiskReadCache) evictOldest() {
+	if el := d.order.Back(); el != nil {
+		d.remove(el)
+	}
+}
+
+func (d *DiskReadCache) remove(el *list.Element) {
+	entry := el.Value.(*diskCacheEntry)
+	d.order.Remove(el)
+	delete(d.entries, entry.key)
+	d.size -= entry.size
+	_ = os.Remove(d.path(entry.key))
+}
+
+func (d *DiskReadCache) path(key ReadCacheKey) string {
+	name := fmt.Sprintf("%x-%d-%d-%d-%d%s", key.Server, key.Tenant, key.Stream, key.From, key.MaxBytes, diskCacheExt)
+	return filepath.Join(d.dir, name)
+}
+
+func parseDiskCacheName(name string) (ReadCacheKey, bool) {
+	if !strings.HasSuffix(name, diskCacheExt) {
+		return ReadCacheKey{}, false
+	}
+	parts := strings.Split(strings.TrimSuffix(name, diskCacheExt), "-")
+	if len(parts) != 5 {
+		return ReadCacheKey{}, false
+	}
+	server, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return ReadCacheKey{}, false
+	}
+	var nums [4]uint64
+	for i, p := range parts[1:] {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return ReadCacheKey{}, false
+		}
+		nums[i] = n
+	}
+	return ReadCacheKey{
+		Server:   string(server),
+		Tenant:   TenantID(nums[0]),
+		Stream:   StreamID(nums[1]),
+		From:     Offset(nums[2]),
+		MaxBytes: nums[3],
+	}, true
+}
+
+// On-disk range format (all integers big-endian):
+//
+//	u32 event count
+//	repeated: u64 offset | u64 stream id | i64 unix nanos | u32 len | data
+func writeDiskRange(path string, events []Event) (uint64, error) {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+	var hdr [28]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(events)))
+	_, err = w.Write(hdr[:4])
+	for _, e := range events {
+		if err != nil {
+			break
+		}
+		binary.BigEndian.PutUint64(hdr[0:8], uint64(e.Offset))
+		binary.BigEndian.PutUint64(hdr[8:16], uint64(e.StreamID))
+		binary.BigEndian.PutUint64(hdr[16:24], uint64(e.Timestamp.UnixNano()))
+		binary.BigEndian.PutUint32(hdr[24:28], uint32(len(e.Data)))
+		if _, err = w.Write(hdr[:]); err == nil {
+			_, err = w.Write(e.Data)
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	var size int64
+	if err == nil {
+		size, err = f.Seek(0, io.SeekCurrent)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return uint64(size), nil
+}
+
+func readDiskRange(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var hdr [28]byte
+	if _, err := io.ReadFull(r, hdr[:4]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	events := make([]Event, 0, n)
+	for i := uint32(0); i < n; i++ {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, err
+		}
+		data := make([]byte, binary.BigEndian.Uint32(hdr[24:28]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		events = append(events, Event{
+			Offset:    Offset(binary.BigEndian.Uint64(hdr[0:8])),
+			StreamID:  StreamID(binary.BigEndian.Uint64(hdr[8:16])),
+			Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(hdr[16:24]))),
+			Data:      data,
+		})
+	}
+	if _, err := r.ReadByte(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("kimberlite: read cache: trailing data")
+	}
+	return events, nil
+}
@@ -0,0 +1,107 @@
+package kimberlite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testEvents(stream StreamID, from Offset, sizes ...int) []Event {
+	out := make([]Event, len(sizes))
+	for i, n := range sizes {
+		out[i] = Event{
+			Offset:    from + Offset(i),
+			StreamID:  stream,
+			Data:      make([]byte, n),
+			Timestamp: time.Unix(0, int64(1000+i)),
+		}
+	}
+	return out
+}
+
+func TestRangeClosed(t *testing.T) {
+	if rangeClosed(nil, 10) {
+		t.Fatal("empty range should never be closed")
+	}
+	if rangeClosed(testEvents(1, 0, 3, 3), 10) {
+		t.Fatal("short read should not be closed")
+	}
+	if !rangeClosed(testEvents(1, 0, 6, 6), 10) {
+		t.Fatal("read that exhausted its budget should be closed")
+	}
+}
+
+func TestMemoryReadCacheEvictsLRU(t *testing.T) {
+	c := NewMemoryReadCache(20)
+	k1 := ReadCacheKey{Tenant: 1, Stream: 1, From: 0, MaxBytes: 10}
+	k2 := ReadCacheKey{Tenant: 1, Stream: 1, From: 1, MaxBytes: 10}
+	k3 := ReadCacheKey{Tenant: 1, Stream: 1, From: 2, MaxBytes: 10}
+
+	c.Put(k1, testEvents(1, 0, 10))
+	c.Put(k2, testEvents(1, 1, 10))
+	if _, ok := c.Get(k1); !ok {
+		t.Fatal("k1 should be cached")
+	}
+	c.Put(k3, testEvents(1, 2, 10))
+
+	if _, ok := c.Get(k2); ok {
+		t.Fatal("k2 should have been evicted as least recently used")
+	}
+	if _, ok := c.Get(k1); !ok {
+		t.Fatal("k1 should survive eviction")
+	}
+	if c.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", c.Len())
+	}
+}
+
+func TestMemoryReadCacheReturnsCopies(t *testing.T) {
+	c := NewMemoryReadCache(100)
+	k := ReadCacheKey{Tenant: 1, Stream: 1, MaxBytes: 4}
+	c.Put(k, testEvents(1, 0, 4))
+
+	got, _ := c.Get(k)
+	got[0].Data[0] = 0xFF
+	again, _ := c.Get(k)
+	if again[0].Data[0] != 0 {
+		t.Fatal("mutating a returned slice must not affect the cache")
+	}
+}
+
+func TestDiskReadCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskReadCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := ReadCacheKey{Server: "db-1.example:5432", Tenant: 7, Stream: 3, From: 5, MaxBytes: 8}
+	want := testEvents(3, 5, 4, 4)
+	want[1].Data[0] = 0xAB
+	c.Put(k, want)
+	// An entry of an older version, named without the server.
+	if err := os.WriteFile(filepath.Join(dir, "7-3-5-8"+diskCacheExt), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen to prove persistence across processes.
+	c2, err := NewDiskReadCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c2.Get(k)
+	if !ok {
+		t.Fatal("expected cache hit after reopen")
+	}
+	other := k
+	other.Server = "db-2.example:5432"
+	if _, ok := c2.Get(other); ok {
+		t.Fatal("range of another server served")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "7-3-5-8"+diskCacheExt)); !os.IsNotExist(err) {
+		t.Fatalf("older entry kept: %v", err)
+	}
+	if len(got) != 2 || got[1].Offset != 6 || got[1].Data[0] != 0xAB || !got[0].Timestamp.Equal(want[0].Timestamp) {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+}
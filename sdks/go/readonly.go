@@ -0,0 +1,132 @@
+package kimberlite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrReadOnly is returned by a client with WithReadOnly for operations
+// that would write.
+var ErrReadOnly = errors.New("kimberlite: read-only client")
+
+// WithReadOnly makes the client refuse, with ErrReadOnly, every
+// operation that could change data: statements other than reads,
+// appends, stream creation, masking policy changes, API key rotation,
+// tenant changes, subject erasure, and placing or releasing legal
+// holds. Statements are checked as they are sent, after
+// interceptors, so none can rewrite a read into a write.
+//
+// A statement counts as a read only if it is a single SELECT, WITH or
+// SHOW statement, or an EXPLAIN of one, with none of the keywords
+// INSERT, UPDATE, DELETE, MERGE, INTO, CREATE, DROP, ALTER, GRANT or
+// TRUNCATE outside string literals, quoted identifiers and comments.
+// Anything else counts as a write, so a read naming a column such as
+// update must quote it.
+//
+// The check runs in the client; give a service that must not write a
+// token whose roles cannot write as well, so that the server refuses
+// writes from any other client it may create.
+func WithReadOnly() Option {
+	return func(c *Client) { c.readOnly = true }
+}
+
+// checkWritable fails with ErrReadOnly on a read-only client, naming
+// the operation refused.
+func (c *Client) checkWritable(op string) error {
+	if c.readOnly {
+		return fmt.Errorf("%w: %s refused", ErrReadOnly, op)
+	}
+	return nil
+}
+
+// checkStatement fails with ErrReadOnly on a read-only client for a
+// statement that is not a read.
+func (c *Client) checkStatement(sql string) error {
+	if !c.readOnly || isReadStatement(sql) {
+		return nil
+	}
+	word, _ := firstWord(sql)
+	if word == "" {
+		word = "unrecognised"
+	}
+	return c.checkWritable(strings.ToUpper(word) + " statement")
+}
+
+// writeKeywords are the keywords that make a statement that starts as
+// a read count as a write.
+var writeKeywords = []string{"INSERT", "UPDATE", "DELETE", "MERGE", "INTO", "CREATE", "DROP", "ALTER", "GRANT", "TRUNCATE"}
+
+// isReadStatement reports whether sql is a single statement that only
+// reads, as WithReadOnly describes.
+func isReadStatement(sql string) bool {
+	word, end := firstWord(sql)
+	switch strings.ToUpper(word) {
+	case "EXPLAIN":
+		rest := sql[end:]
+		if word, end := firstWord(rest); strings.EqualFold(word, "ANALYZE") {
+			rest = rest[end:]
+		}
+		return isReadStatement(rest)
+	case "SELECT", "WITH", "SHOW":
+	default:
+		return false
+	}
+	if hasStatementAfter(sql) {
+		return false
+	}
+	for _, kw := range writeKeywords {
+		if hasKeyword(sql, kw) {
+			return false
+		}
+	}
+	return true
+}
+
+// firstWord returns the first word of sql, after spaces, comments and
+// opening parentheses, and the offset just past it. The word is empty
+// if sql starts with anything else.
+func firstWord(sql string) (string, int) {
+	i := 0
+	for i < len(sql) {
+		switch c := sql[i]; {
+		case isSpace(c) || c == '(':
+			i++
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return "", len(sql)
+			}
+			i += end + 4
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			return sql[i:j], j
+		default:
+			return "", i
+		}
+	}
+	return "", i
+}
+
+// hasStatementAfter reports whether sql holds anything but spaces and
+// comments after a semicolon outside literals.
+func hasStatementAfter(sql string) bool {
+	ended, found := false, false
+	walkSQL(sql, func(i int) int {
+		switch c := sql[i]; {
+		case c == ';':
+			ended = true
+		case ended && !isSpace(c):
+			found = true
+		}
+		return i + 1
+	})
+	return found
+}
@@ -0,0 +1,95 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIsReadStatement(t *testing.T) {
+	for sql, want := range map[string]bool{
+		"SELECT * FROM patients":                                   true,
+		"  -- chart\n/* ward */ (select id FROM a) UNION SELECT 1": true,
+		"WITH w AS (SELECT * FROM wards) SELECT * FROM w":          true,
+		"SHOW TABLES":                    true,
+		"EXPLAIN SELECT * FROM patients": true,
+		"SELECT * FROM notes WHERE body = 'DELETE FROM patients';":            true,
+		`SELECT "update" FROM audit`:                                          true,
+		"EXPLAIN ANALYZE DELETE FROM patients":                                false,
+		"INSERT INTO patients VALUES (1)":                                     false,
+		"WITH gone AS (DELETE FROM patients RETURNING id) SELECT * FROM gone": false,
+		"SELECT * INTO archive FROM patients":                                 false,
+		"SELECT 1; DROP TABLE patients":                                       false,
+		"SELECT update FROM audit":                                            false,
+		"CREATE TABLE t (id BIGINT)":                                          false,
+		"/* unterminated SELECT":                                              false,
+		"'SELECT'":                                                            false,
+		"":                                                                    false,
+	} {
+		if got := isReadStatement(sql); got != want {
+			t.Errorf("isReadStatement(%q) = %v, want %v", sql, got, want)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	b := &recordingBackend{}
+	subjects, holds := NewMemorySubjectKeys(), NewMemoryLegalHolds()
+	if _, err := subjects.SubjectKey(ctx, "patient-7", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := holds.PlaceHold(ctx, LegalHold{Target: StreamHold(1), CaseID: "case-1"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Connect("test", WithTenant(1), WithBackend(b), WithReadOnly(),
+		WithEncryption(NewEncryption(nil, WithSubjectKeys(subjects))), WithLegalHolds(holds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.QueryContext(ctx, "SELECT * FROM patients"); err != nil {
+		t.Fatal(err)
+	}
+	refused := map[string]error{}
+	_, refused["query"] = c.QueryContext(ctx, "DELETE FROM patients RETURNING id")
+	_, refused["exec"] = c.ExecContext(ctx, "UPDATE patients SET ward = $1", NewText("icu"))
+	_, refused["append"] = c.AppendContext(ctx, 1, []byte("{}"))
+	_, refused["create stream"] = c.CreateStreamContext(ctx, "charts", DataClassPublic)
+	refused["masking"] = c.DropMaskingPolicy(ctx, "ssn")
+	refused["erase"] = c.Erase(ctx, "patient-7")
+	refused["place hold"] = c.PlaceLegalHold(ctx, StreamHold(2), "case-2")
+	refused["release hold"] = c.ReleaseLegalHold(ctx, StreamHold(1), "case-1")
+	for op, err := range refused {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s: %v", op, err)
+		}
+	}
+	if len(b.sql) != 1 {
+		t.Fatalf("sent %q", b.sql)
+	}
+	if got, _ := holds.Holds(ctx, StreamHold(1)); len(got) != 1 {
+		t.Fatalf("holds after refused release %+v", got)
+	}
+	if got, _ := holds.Holds(ctx, StreamHold(2)); len(got) != 0 {
+		t.Fatalf("holds after refused placement %+v", got)
+	}
+
+	// Interceptors run before the check, so they cannot turn a read
+	// into a write.
+	rewrite := func(ctx context.Context, op *Operation, req any, next Invoker) error {
+		if s, ok := req.(*StatementRequest); ok {
+			s.SQL = "DELETE FROM patients"
+		}
+		return next(ctx, op, req)
+	}
+	c2, err := Connect("test", WithTenant(1), WithBackend(b), WithReadOnly(), WithInterceptors(rewrite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if _, err := c2.QueryContext(ctx, "SELECT * FROM patients"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("rewritten query: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ClientReceipt records that a client appended events to a stream and
+// read them back from the log as sent. Keep it with the business record
+// it backs; anyone holding the signer's public key can check it with
+// VerifyReceipt.
+//
+// Receipts are signed by the client's ReceiptSigner, not the server,
+// which neither signs appends nor holds a key to sign them with. A
+// receipt therefore attests what its signer observed, not what the
+// server accepted: it is evidence only to parties who trust the
+// signer, and not non-repudiable proof of submission. Holding the
+// signer's key apart from the application, e.g. in a notary service or
+// HSM, keeps the application from forging receipts, nothing more.
+type ClientReceipt struct {
+	TenantID    TenantID `json:"tenant_id"`
+	StreamID    StreamID `json:"stream_id"`
+	FirstOffset Offset   `json:"first_offset"`
+	// PayloadHashes are the SHA-256 hashes of the events' data, in
+	// offset order.
+	PayloadHashes []Digest `json:"payload_hashes"`
+	// Timestamp is when the client read the events back, by its clock.
+	// The server does not report when events were appended.
+	Timestamp time.Time `json:"timestamp"`
+	KeyID     string    `json:"key_id,omitempty"`
+	Signature []byte    `json:"signature"`
+}
+
+// Digest is a SHA-256 hash, hex encoded as text.
+type Digest [sha256.Size]byte
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Digest) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(d[:])), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Digest) UnmarshalText(b []byte) error {
+	if hex.DecodedLen(len(b)) != len(d) {
+		return fmt.Errorf("kimberlite: digest must be %d hex characters", 2*len(d))
+	}
+	_, err := hex.Decode(d[:], b)
+	return err
+}
+
+// LastOffset returns the offset of the last event the receipt covers.
+func (r *ClientReceipt) LastOffset() Offset {
+	return r.FirstOffset + Offset(len(r.PayloadHashes)) - 1
+}
+
+var receiptContext = []byte("kimberlite append receipt v1\x00")
+
+// SignedBytes returns the message the receipt's signature covers.
+func (r *ClientReceipt) SignedBytes() []byte {
+	b := append([]byte(nil), receiptContext...)
+	b = binary.BigEndian.AppendUint64(b, uint64(r.TenantID))
+	b = binary.BigEndian.AppendUint64(b, uint64(r.StreamID))
+	b = binary.BigEndian.AppendUint64(b, uint64(r.FirstOffset))
+	b = binary.BigEndian.AppendUint64(b, uint64(r.Timestamp.UnixNano()))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(r.KeyID)))
+	b = append(b, r.KeyID...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(r.PayloadHashes)))
+	for _, h := range r.PayloadHashes {
+		b = append(b, h[:]...)
+	}
+	return b
+}
+
+// ErrInvalidReceipt is returned when a receipt fails verification.
+var ErrInvalidReceipt = errors.New("kimberlite: invalid append receipt")
+
+// VerifyReceipt checks r's signature against key and, if events are
+// given, that they are the payloads r covers.
+func VerifyReceipt(r *ClientReceipt, key ed25519.PublicKey, events ...[]byte) error {
+	if len(r.PayloadHashes) == 0 {
+		return fmt.Errorf("%w: no events", ErrInvalidReceipt)
+	}
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, r.SignedBytes(), r.Signature) {
+		return fmt.Errorf("%w: bad signature", ErrInvalidReceipt)
+	}
+	if events == nil {
+		return nil
+	}
+	if len(events) != len(r.PayloadHashes) {
+		return fmt.Errorf("%w: receipt covers %d events, got %d", ErrInvalidReceipt, len(r.PayloadHashes), len(events))
+	}
+	for i, data := range events {
+		if Digest(sha256.Sum256(data)) != r.PayloadHashes[i] {
+			return fmt.Errorf("%w: event at offset %d does not match", ErrInvalidReceipt, r.FirstOffset+Offset(i))
+		}
+	}
+	return nil
+}
+
+// ReceiptSigner signs append receipts by setting their KeyID and
+// Signature. The signature must be an Ed25519 signature of SignedBytes,
+// computed after KeyID is set.
+type ReceiptSigner interface {
+	SignReceipt(ctx context.Context, r *ClientReceipt) error
+}
+
+type ed25519Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// NewEd25519Signer returns a ReceiptSigner signing with key in process.
+func NewEd25519Signer(keyID string, key ed25519.PrivateKey) ReceiptSigner {
+	return ed25519Signer{keyID: keyID, key: key}
+}
+
+func (s ed25519Signer) SignReceipt(_ context.Context, r *ClientReceipt) error {
+	r.KeyID = s.keyID
+	r.Signature = ed25519.Sign(s.key, r.SignedBytes())
+	return nil
+}
+
+// WithReceiptSigner sets the signer of AppendWithReceipt's receipts.
+func WithReceiptSigner(s ReceiptSigner) Option {
+	return func(c *Client) { c.receipts = s }
+}
+
+// ErrNoReceiptSigner is returned by AppendWithReceipt on a client
+// without a ReceiptSigner.
+var ErrNoReceiptSigner = errors.New("kimberlite: no receipt signer configured")
+
+// AppendWithReceipt is AppendWithReceiptContext with a background
+// context.
+func (c *Client) AppendWithReceipt(streamID StreamID, events ...[]byte) (*ClientReceipt, error) {
+	return c.AppendWithReceiptContext(context.Background(), streamID, events...)
+}
+
+// AppendWithReceiptContext appends events like AppendContext, reads
+// them back as stored, bypassing the read cache, to confirm the log
+// holds the bytes sent, and returns a receipt signed by the client's
+// ReceiptSigner. If the append succeeds but confirming or signing
+// fails, the error says so and the events remain appended.
+func (c *Client) AppendWithReceiptContext(ctx context.Context, streamID StreamID, events ...[]byte) (*ClientReceipt, error) {
+	if c.receipts == nil {
+		return nil, ErrNoReceiptSigner
+	}
+	if len(events) == 0 {
+		return nil, errors.New("kimberlite: receipt requires at least one event")
+	}
+	var sent [][]byte
+	first, err := authenticated(ctx, c, func() (Offset, error) {
+		first, sealed, err := c.appendSealed(ctx, streamID, nil, events)
+		sent = sealed
+		return first, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	r, err := confirmAppend(ctx, storedEvents{c}, c.tenant, streamID, first, sent, events)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: appended at offset %d but receipt failed: %w", first, err)
+	}
+	if err := c.receipts.SignReceipt(ctx, r); err != nil {
+		return nil, fmt.Errorf("kimberlite: appended at offset %d but receipt failed: %w", first, err)
+	}
+	return r, nil
+}
+
+// confirmAppend reads the events appended at first back from src,
+// checks they are the bytes sent, and returns the unsigned receipt for
+// events, the payloads before encryption.
+func confirmAppend(ctx context.Context, src eventReader, tenant TenantID, streamID StreamID, first Offset, sent, events [][]byte) (*ClientReceipt, error) {
+	r := &ClientReceipt{TenantID: tenant, StreamID: streamID, FirstOffset: first}
+	var maxBytes uint64
+	for _, data := range sent {
+		maxBytes += uint64(len(data))
+	}
+	for len(r.PayloadHashes) < len(events) {
+		from := first + Offset(len(r.PayloadHashes))
+		stored, err := src.ReadEventsContext(ctx, streamID, from, max(maxBytes, 1<<16))
+		if err != nil {
+			return nil, err
+		}
+		if len(stored) == 0 {
+			return nil, fmt.Errorf("event at offset %d not found", from)
+		}
+		for _, ev := range stored {
+			i := len(r.PayloadHashes)
+			if i == len(events) {
+				break
+			}
+			if ev.Offset != first+Offset(i) || !bytes.Equal(ev.Data, sent[i]) {
+				return nil, fmt.Errorf("event at offset %d does not match what was appended", first+Offset(i))
+			}
+			r.PayloadHashes = append(r.PayloadHashes, sha256.Sum256(events[i]))
+		}
+	}
+	r.Timestamp = time.Now().UTC()
+	return r, nil
+}
@@ -0,0 +1,85 @@
+package kimberlite
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReceipt(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := fakeLog{1: {
+		{StreamID: 1, Offset: 0, Data: []byte("before")},
+		{StreamID: 1, Offset: 1, Data: []byte("a")},
+		{StreamID: 1, Offset: 2, Data: []byte("b")},
+	}}
+	before := time.Now()
+	events := [][]byte{[]byte("a"), []byte("b")}
+
+	r, err := confirmAppend(ctx, log, 7, 1, 1, events, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewEd25519Signer("notary-1", priv).SignReceipt(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if r.LastOffset() != 2 || r.Timestamp.Before(before) || r.Timestamp.After(time.Now()) || r.KeyID != "notary-1" {
+		t.Fatalf("receipt: %+v", r)
+	}
+
+	// Receipts survive a JSON round trip, as stored with the record.
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored ClientReceipt
+	if err := json.Unmarshal(b, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyReceipt(&stored, pub, events...); err != nil {
+		t.Fatalf("round trip: %v", err)
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	moved := stored
+	moved.FirstOffset = 5
+	cases := map[string]error{
+		"key":     VerifyReceipt(r, other),
+		"offset":  VerifyReceipt(&moved, pub),
+		"payload": VerifyReceipt(r, pub, []byte("a"), []byte("c")),
+		"count":   VerifyReceipt(r, pub, []byte("a")),
+	}
+	for name, err := range cases {
+		if !errors.Is(err, ErrInvalidReceipt) {
+			t.Errorf("%s: expected ErrInvalidReceipt, got %v", name, err)
+		}
+	}
+
+	mismatched := [][]byte{[]byte("a"), []byte("x")}
+	if _, err := confirmAppend(ctx, log, 7, 1, 1, mismatched, mismatched); err == nil {
+		t.Fatal("expected mismatch error")
+	}
+	if _, err := confirmAppend(ctx, log, 7, 1, 2, events, events); err == nil {
+		t.Fatal("expected missing event error")
+	}
+
+	// Encrypted events are checked as sent and hashed as given.
+	plain := [][]byte{[]byte("plain a"), []byte("plain b")}
+	sealed, err := confirmAppend(ctx, log, 7, 1, 1, events, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewEd25519Signer("notary-1", priv).SignReceipt(ctx, sealed); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyReceipt(sealed, pub, plain...); err != nil {
+		t.Fatalf("receipt of encrypted events: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Data residency pins a stream's data to a region, for laws such as
+// Australia's My Health Records Act or the GDPR's transfer rules. The
+// server places a stream created with CreateStreamInRegion in that
+// region's replication group; the client makes sure it is only
+// written and read through nodes in the same region.
+//
+// A client connected to a node of one region declares it with
+// WithRegion, and then fails appends and reads of streams pinned to
+// another region with ErrResidencyViolation. A Regional client holds
+// one connection per region and routes each stream's operations to the
+// connection of its region:
+//
+//	rc, err := kimberlite.ConnectRegional(map[kimberlite.Region]string{
+//	    kimberlite.RegionUSEast1:      "kmb.us-east-1.example.com:5432",
+//	    kimberlite.RegionAPSoutheast2: "kmb.ap-southeast-2.example.com:5432",
+//	}, kimberlite.WithTenant(1))
+//	phi, err := rc.CreateStream(ctx, "au_charts", kimberlite.DataClassRestricted, kimberlite.RegionAPSoutheast2)
+//	_, err = rc.Append(ctx, phi.ID, event) // sent to ap-southeast-2 only
+//
+// Streams created outside the client are recorded with SetStreamRegion.
+
+// Region is a region data can be pinned to, named as by the server,
+// e.g. "us-east-1". Names other than the constants are custom regions.
+type Region string
+
+// Regions known to the server.
+const (
+	// RegionGlobal is the placement of streams not pinned to a region.
+	RegionGlobal       Region = ""
+	RegionUSEast1      Region = "us-east-1"
+	RegionAPSoutheast2 Region = "ap-southeast-2"
+)
+
+// ErrResidencyViolation is returned for operations that would move a
+// stream's data out of its region.
+var ErrResidencyViolation = errors.New("kimberlite: data residency violation")
+
+// WithRegion declares the region of the node the client connects to.
+// Appends and reads of streams pinned to other regions then fail with
+// ErrResidencyViolation.
+func WithRegion(region Region) Option {
+	return func(c *Client) { c.region = region }
+}
+
+// CreateStreamInRegion creates a stream whose data the server keeps in
+// region. A client with WithRegion can only create streams in its own
+// region.
+func (c *Client) CreateStreamInRegion(ctx context.Context, name string, class DataClass, region Region) (*StreamInfo, error) {
+	if c.region != RegionGlobal && region != RegionGlobal && region != c.region {
+		return nil, fmt.Errorf("%w: stream %s pinned to %s, client connected to %s", ErrResidencyViolation, name, region, c.region)
+	}
+	return authenticated(ctx, c, func() (*StreamInfo, error) { return c.createStreamContext(ctx, name, class, region) })
+}
+
+// SetStreamRegion records the region of a stream created outside the
+// client. Streams created through the client are recorded
+// automatically.
+func (c *Client) SetStreamRegion(id StreamID, region Region) {
+	c.regions.set(id, region)
+}
+
+// StreamRegion returns the region of stream id, if the client knows
+// it.
+func (c *Client) StreamRegion(id StreamID) (Region, bool) {
+	return c.regions.get(id)
+}
+
+// checkResidency enforces WithRegion for an operation on stream.
+func (c *Client) checkResidency(stream StreamID) error {
+	if c.region == RegionGlobal {
+		return nil
+	}
+	if region, _ := c.regions.get(stream); region != RegionGlobal && region != c.region {
+		return fmt.Errorf("%w: stream %d pinned to %s, client connected to %s", ErrResidencyViolation, stream, region, c.region)
+	}
+	return nil
+}
+
+// streamRegions records the regions of streams.
+type streamRegions struct {
+	mu      sync.RWMutex
+	regions map[StreamID]Region
+}
+
+func (s *streamRegions) set(id StreamID, region Region) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.regions == nil {
+		s.regions = make(map[StreamID]Region)
+	}
+	s.regions[id] = region
+}
+
+func (s *streamRegions) get(id StreamID) (Region, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	region, ok := s.regions[id]
+	return region, ok
+}
+
+// Regional is a client connected to one node per region, routing each
+// stream's operations to its region. Streams of unknown region fail
+// with ErrResidencyViolation rather than being sent to any node.
+type Regional struct {
+	clients map[Region]*Client
+	regions streamRegions
+}
+
+// ConnectRegional connects to the node addrs[r] of each region r, with
+// opts and WithRegion(r). The node of RegionGlobal, if any, serves
+// streams not pinned to a region; without one, they are served by the
+// node of the first region in alphabetical order.
+func ConnectRegional(addrs map[Region]string, opts ...Option) (*Regional, error) {
+	r := &Regional{clients: make(map[Region]*Client, len(addrs))}
+	for region, addr := range addrs {
+		c, err := Connect(addr, append(append([]Option(nil), opts...), WithRegion(region))...)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("region %q: %w", region, err)
+		}
+		r.clients[region] = c
+	}
+	return r, nil
+}
+
+// Client returns the client of region.
+func (r *Regional) Client(region Region) (*Client, error) {
+	if c, ok := r.clients[region]; ok {
+		return c, nil
+	}
+	if region == RegionGlobal && len(r.clients) > 0 {
+		regions := make([]string, 0, len(r.clients))
+		for region := range r.clients {
+			regions = append(regions, string(region))
+		}
+		sort.Strings(regions)
+		return r.clients[Region(regions[0])], nil
+	}
+	return nil, fmt.Errorf("%w: no node in region %s", ErrResidencyViolation, region)
+}
+
+// SetStreamRegion records the region of a stream created outside the
+// client.
+func (r *Regional) SetStreamRegion(id StreamID, region Region) {
+	r.regions.set(id, region)
+	for _, c := range r.clients {
+		c.SetStreamRegion(id, region)
+	}
+}
+
+// streamClient returns the client of stream's region.
+func (r *Regional) streamClient(stream StreamID) (*Client, error) {
+	region, ok := r.regions.get(stream)
+	if !ok {
+		return nil, fmt.Errorf("%w: region of stream %d unknown", ErrResidencyViolation, stream)
+	}
+	return r.Client(region)
+}
+
+// CreateStream creates a stream pinned to region through the node of
+// region.
+func (r *Regional) CreateStream(ctx context.Context, name string, class DataClass, region Region) (*StreamInfo, error) {
+	c, err := r.Client(region)
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.CreateStreamInRegion(ctx, name, class, region)
+	if err != nil {
+		return nil, err
+	}
+	r.SetStreamRegion(info.ID, region)
+	return info, nil
+}
+
+// Append appends events to stream through the node of its region.
+func (r *Regional) Append(ctx context.Context, stream StreamID, events ...[]byte) (Offset, error) {
+	c, err := r.streamClient(stream)
+	if err != nil {
+		return 0, err
+	}
+	return c.AppendContext(ctx, stream, events...)
+}
+
+// ReadEvents reads events of stream through the node of its region.
+func (r *Regional) ReadEvents(ctx context.Context, stream StreamID, from Offset, maxBytes uint64) ([]Event, error) {
+	c, err := r.streamClient(stream)
+	if err != nil {
+		return nil, err
+	}
+	return c.ReadEventsContext(ctx, stream, from, maxBytes)
+}
+
+// Close closes the connections of all regions.
+func (r *Regional) Close() error {
+	var errs []error
+	for _, c := range r.clients {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResidency(t *testing.T) {
+	ctx := context.Background()
+	var routed []Region
+	node := func(region Region) *Client {
+		record := func(ctx context.Context, op *Operation, req any, next Invoker) error {
+			routed = append(routed, region)
+			return nil
+		}
+		return &Client{region: region, interceptors: []Interceptor{record}}
+	}
+	us, au := node(RegionUSEast1), node(RegionAPSoutheast2)
+	r := &Regional{clients: map[Region]*Client{RegionUSEast1: us, RegionAPSoutheast2: au}}
+	r.SetStreamRegion(1, RegionAPSoutheast2)
+	r.SetStreamRegion(2, RegionUSEast1)
+	r.SetStreamRegion(3, RegionGlobal)
+
+	for _, stream := range []StreamID{1, 2, 3} {
+		if _, err := r.Append(ctx, stream, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.ReadEvents(ctx, stream, 0, 1024); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []Region{RegionAPSoutheast2, RegionAPSoutheast2, RegionUSEast1, RegionUSEast1, RegionAPSoutheast2, RegionAPSoutheast2}
+	if len(routed) != len(want) {
+		t.Fatalf("routed %v, want %v", routed, want)
+	}
+	for i := range want {
+		if routed[i] != want[i] {
+			t.Fatalf("routed %v, want %v", routed, want)
+		}
+	}
+
+	// The clients themselves refuse streams of other regions.
+	if _, err := us.AppendContext(ctx, 1, []byte("x")); !errors.Is(err, ErrResidencyViolation) {
+		t.Fatalf("append: expected ErrResidencyViolation, got %v", err)
+	}
+	if _, err := us.ReadEventsContext(ctx, 1, 0, 1024); !errors.Is(err, ErrResidencyViolation) {
+		t.Fatalf("read: expected ErrResidencyViolation, got %v", err)
+	}
+	if _, err := us.CreateStreamInRegion(ctx, "au", DataClassRestricted, RegionAPSoutheast2); !errors.Is(err, ErrResidencyViolation) {
+		t.Fatalf("create: expected ErrResidencyViolation, got %v", err)
+	}
+	if _, err := r.Append(ctx, 9, []byte("x")); !errors.Is(err, ErrResidencyViolation) {
+		t.Fatalf("unknown stream: expected ErrResidencyViolation, got %v", err)
+	}
+	if _, err := r.Client("eu-west-1"); !errors.Is(err, ErrResidencyViolation) {
+		t.Fatalf("unknown region: expected ErrResidencyViolation, got %v", err)
+	}
+	if code := ErrorCode(us.checkResidency(1)); code != "residency_violation" {
+		t.Fatalf("code %q", code)
+	}
+}
@@ -0,0 +1,258 @@
+package kimberlite
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Rotating an encryption key protects new events at once: rotate the
+// provider (EnvelopeKeys.Rotate, or RotateMasterKey for a new master
+// key) and the next appends use the new key. Events already in the log
+// stay sealed under the old key, because the log is append-only. To
+// retire an old key entirely, copy the stream with RotateAndReencrypt
+// into a new stream sealed under the current keys, switch readers to
+// it, and only then revoke the old key, within the retention rules and
+// legal holds of the old stream, which keeps its ciphertext.
+//
+// The copy keeps offsets: the event at offset n of the source is at
+// offset n of the target. Events of erased subjects, which nobody can
+// decrypt any more, are copied as ErasedEventType tombstones. Subject
+// keys are not rotated; their events are resealed under the same
+// subject key for the new stream.
+
+// ErasedEventType is the type of the tombstone envelopes that
+// RotateAndReencrypt writes in place of erased subjects' events.
+const ErasedEventType = "kimberlite.Erased"
+
+// ErrNoEncryption is returned by RotateAndReencrypt on a client without
+// encryption.
+var ErrNoEncryption = errors.New("kimberlite: no encryption configured")
+
+// ReencryptProgress reports how far the copy of a stream has got.
+type ReencryptProgress struct {
+	Source, Target StreamID
+	// Next is the source offset copied next, and the number of events in
+	// Target.
+	Next Offset
+	// Erased counts the tombstones in Target.
+	Erased int
+	// Done is set once every source event has been copied.
+	Done bool
+}
+
+// ReencryptOption configures RotateAndReencrypt.
+type ReencryptOption func(*reencryptConfig)
+
+type reencryptConfig struct {
+	batchBytes uint64
+	onProgress func(ReencryptProgress)
+}
+
+// WithReencryptBatchBytes sets how many bytes of events are read per
+// batch. The default is 1 MiB.
+func WithReencryptBatchBytes(n uint64) ReencryptOption {
+	return func(cfg *reencryptConfig) { cfg.batchBytes = n }
+}
+
+// OnReencryptProgress sets a function called after every append to the
+// target, for example to report progress or checkpoint it.
+func OnReencryptProgress(fn func(ReencryptProgress)) ReencryptOption {
+	return func(cfg *reencryptConfig) { cfg.onProgress = fn }
+}
+
+// RotateAndReencrypt copies the events of source into target, which is
+// encrypted under the current keys. target must be a stream used only by
+// the copy, with its data class known to the client; it normally has the
+// class of source. The copy is resumable: after a failure or
+// cancellation, calling RotateAndReencrypt again with the same streams
+// continues after the last event copied. It fails with an error
+// wrapping ErrKeyUnavailable on events it cannot decrypt other than
+// those of erased subjects.
+func (c *Client) RotateAndReencrypt(ctx context.Context, source, target StreamID, opts ...ReencryptOption) (ReencryptProgress, error) {
+	if c.encryption == nil {
+		return ReencryptProgress{Source: source, Target: target}, ErrNoEncryption
+	}
+	r := &reencryptor{raw: rawEvents{c}, enc: c.encryption, tenant: c.tenant, append: c.AppendContext}
+	return r.run(ctx, source, target, opts)
+}
+
+// rawEvents reads a client's events without decrypting them.
+type rawEvents struct{ c *Client }
+
+func (r rawEvents) ReadEventsContext(ctx context.Context, streamID StreamID, from Offset, maxBytes uint64) ([]Event, error) {
+	return r.c.readEventsContext(ctx, streamID, from, maxBytes, true)
+}
+
+type reencryptor struct {
+	raw    eventReader
+	enc    *Encryption
+	tenant TenantID
+	append func(ctx context.Context, streamID StreamID, events ...[]byte) (Offset, error)
+}
+
+func (r *reencryptor) run(ctx context.Context, source, target StreamID, opts []ReencryptOption) (ReencryptProgress, error) {
+	cfg := reencryptConfig{batchBytes: 1 << 20}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	report := func(p ReencryptProgress) {
+		if cfg.onProgress != nil {
+			cfg.onProgress(p)
+		}
+	}
+
+	p := ReencryptProgress{Source: source, Target: target}
+	for {
+		events, err := r.raw.ReadEventsContext(ctx, target, p.Next, cfg.batchBytes)
+		if err != nil {
+			return p, err
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, ev := range events {
+			if isTombstone(ev.Data) {
+				p.Erased++
+			}
+		}
+		p.Next += Offset(len(events))
+	}
+
+	for {
+		events, err := r.raw.ReadEventsContext(ctx, source, p.Next, cfg.batchBytes)
+		if err != nil {
+			return p, err
+		}
+		if len(events) == 0 {
+			p.Done = true
+			report(p)
+			return p, nil
+		}
+		data := make([][]byte, len(events))
+		subjects := make([]string, len(events))
+		erased := make([]bool, len(events))
+		for i, ev := range events {
+			if ev.Offset != p.Next+Offset(i) {
+				return p, fmt.Errorf("kimberlite: stream %d: expected offset %d, read %d", source, p.Next+Offset(i), ev.Offset)
+			}
+			if data[i], subjects[i], erased[i], err = r.open(ctx, ev); err != nil {
+				return p, err
+			}
+		}
+		// Append runs of one subject, whose key comes from the context.
+		for i := 0; i < len(data); {
+			j := i + 1
+			for j < len(data) && subjects[j] == subjects[i] {
+				j++
+			}
+			actx := ctx
+			if subjects[i] != "" {
+				actx = WithSubject(ctx, subjects[i])
+			}
+			if _, err := r.append(actx, target, data[i:j]...); err != nil {
+				return p, fmt.Errorf("kimberlite: re-encrypt stream %d offset %d: %w", source, p.Next, err)
+			}
+			for _, e := range erased[i:j] {
+				if e {
+					p.Erased++
+				}
+			}
+			p.Next += Offset(j - i)
+			report(p)
+			i = j
+		}
+	}
+}
+
+// open decrypts ev, returning its plaintext and subject, or a tombstone
+// if its subject was erased.
+func (r *reencryptor) open(ctx context.Context, ev Event) (data []byte, subject string, erased bool, err error) {
+	subject = eventSubject(ev.Data)
+	data, err = r.enc.decryptEvent(ctx, r.tenant, ev.StreamID, ev.Data)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("kimberlite: stream %d offset %d: %w", ev.StreamID, ev.Offset, err)
+	}
+	if !isSealed(data) {
+		return data, subject, false, nil
+	}
+	if subject != "" && r.enc.subjects != nil {
+		if _, err := r.enc.subjects.SubjectKey(ctx, subject, false); errors.Is(err, ErrSubjectErased) {
+			tomb := Envelope{Type: ErasedEventType}
+			if env, err := UnmarshalEnvelope(ev.Data); err == nil {
+				tomb.ID = env.ID
+			}
+			if tomb.ID == "" {
+				tomb.ID = NewEventID()
+			}
+			data, err := tomb.Marshal()
+			return data, "", true, err
+		}
+	}
+	return nil, "", false, fmt.Errorf("%w: stream %d offset %d", ErrKeyUnavailable, ev.StreamID, ev.Offset)
+}
+
+// eventSubject returns the subject of stored event data: its envelope's,
+// or else the one named by the key ID of its ciphertext.
+func eventSubject(data []byte) string {
+	if subject := subjectOf(context.Background(), data); subject != "" {
+		return subject
+	}
+	if subject, ok := strings.CutPrefix(frameKeyID(data), subjectKeyPrefix); ok {
+		return subject
+	}
+	return ""
+}
+
+// frameKeyID returns the key ID of the first encrypted frame of data.
+func frameKeyID(data []byte) string {
+	if IsEnvelope(data) {
+		env, err := UnmarshalEnvelope(data)
+		if err != nil {
+			return ""
+		}
+		data = env.Data
+		if i := bytes.Index(data, []byte(encryptedFieldPrefix)); i >= 0 && !bytes.HasPrefix(data, encryptedMagic) {
+			field := data[i+len(encryptedFieldPrefix):]
+			if end := bytes.IndexByte(field, '"'); end >= 0 {
+				field = field[:end]
+			}
+			if data, err = base64.RawStdEncoding.DecodeString(string(field)); err != nil {
+				return ""
+			}
+		}
+	}
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return ""
+	}
+	rest := data[len(encryptedMagic):]
+	if len(rest) < 2 || len(rest) < 2+int(binary.BigEndian.Uint16(rest)) {
+		return ""
+	}
+	return string(rest[2 : 2+int(binary.BigEndian.Uint16(rest))])
+}
+
+// isSealed reports whether data, after decryption, still holds
+// ciphertext.
+func isSealed(data []byte) bool {
+	if IsEncrypted(data) {
+		return true
+	}
+	if !IsEnvelope(data) {
+		return false
+	}
+	env, err := UnmarshalEnvelope(data)
+	return err == nil && bytes.Contains(env.Data, []byte(encryptedFieldPrefix))
+}
+
+func isTombstone(data []byte) bool {
+	if !IsEnvelope(data) {
+		return false
+	}
+	env, err := UnmarshalEnvelope(data)
+	return err == nil && env.Type == ErasedEventType
+}
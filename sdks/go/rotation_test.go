@@ -0,0 +1,155 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRotateMasterKey(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewEnvelopeKeys(WithMasterKey("old", &countingWrapper{}, DataClassRestricted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID, _, _ := keys.EncryptionKey(ctx, DataClassRestricted)
+	keys.Rotate()
+	if id, _, _ := keys.EncryptionKey(ctx, DataClassRestricted); id == oldID {
+		t.Fatal("Rotate kept the data key")
+	}
+
+	if err := keys.RotateMasterKey("new:1", &countingWrapper{}, DataClassRestricted); err == nil {
+		t.Fatal("expected error for invalid master key name")
+	}
+	if err := keys.RotateMasterKey("new", &countingWrapper{}, DataClassRestricted); err != nil {
+		t.Fatal(err)
+	}
+	if id, _, _ := keys.EncryptionKey(ctx, DataClassRestricted); !strings.HasPrefix(id, "new:") {
+		t.Fatalf("data key %q not wrapped by the new master key", id)
+	}
+	if _, err := keys.DecryptionKey(ctx, oldID); err != nil {
+		t.Fatalf("old master key forgotten: %v", err)
+	}
+}
+
+// sealingLog appends to a fakeLog through an Encryption, as a client
+// would.
+func sealingLog(log fakeLog, enc *Encryption) func(context.Context, StreamID, ...[]byte) (Offset, error) {
+	return func(ctx context.Context, id StreamID, events ...[]byte) (Offset, error) {
+		sealed, err := enc.encryptEvents(ctx, 1, id, events)
+		if err != nil {
+			return 0, err
+		}
+		for _, data := range sealed {
+			log[id] = append(log[id], Event{StreamID: id, Offset: Offset(len(log[id])), Data: data})
+		}
+		return Offset(len(log[id]) - 1), nil
+	}
+}
+
+func TestRotateAndReencrypt(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewEnvelopeKeys(WithMasterKey("old", &countingWrapper{}, DataClassRestricted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjects := NewMemorySubjectKeys()
+	enc := NewEncryption(keys, WithSubjectKeys(subjects))
+	enc.SetStreamClass(1, DataClassRestricted)
+	enc.SetStreamClass(2, DataClassRestricted)
+	log := fakeLog{}
+	appendTo := sealingLog(log, enc)
+
+	tagged, _ := Envelope{ID: "e-bob", Metadata: map[string]string{metaSubject: "bob"}, Data: []byte("bob")}.Marshal()
+	for _, step := range []struct {
+		ctx    context.Context
+		events [][]byte
+	}{
+		{ctx, [][]byte{[]byte("a"), []byte("b")}},
+		{WithSubject(ctx, "alice"), [][]byte{[]byte("alice")}},
+		{ctx, [][]byte{tagged, []byte("c")}},
+	} {
+		if _, err := appendTo(step.ctx, 1, step.events...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := subjects.DestroySubjectKey(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.RotateMasterKey("new", &countingWrapper{}, DataClassRestricted); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail the copy after its first append, then resume it.
+	failing := errors.New("connection lost")
+	appends := 0
+	r := &reencryptor{raw: log, enc: enc, tenant: 1, append: func(ctx context.Context, id StreamID, events ...[]byte) (Offset, error) {
+		if appends++; appends == 2 {
+			return 0, failing
+		}
+		return appendTo(ctx, id, events...)
+	}}
+	p, err := r.run(ctx, 1, 2, nil)
+	if !errors.Is(err, failing) || p.Next != 3 || p.Erased != 1 || p.Done {
+		t.Fatalf("interrupted copy: %+v, %v", p, err)
+	}
+
+	var reports []ReencryptProgress
+	p, err = r.run(ctx, 1, 2, []ReencryptOption{OnReencryptProgress(func(p ReencryptProgress) { reports = append(reports, p) })})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Done || p.Next != 5 || p.Erased != 1 || len(reports) != 3 {
+		t.Fatalf("resumed copy: %+v, %d reports", p, len(reports))
+	}
+
+	if len(log[2]) != 5 {
+		t.Fatalf("target has %d events", len(log[2]))
+	}
+	for _, ev := range log[2] {
+		if id := frameKeyID(ev.Data); !strings.HasPrefix(id, "new:") && !strings.HasPrefix(id, subjectKeyPrefix) {
+			t.Fatalf("offset %d sealed with key %q", ev.Offset, id)
+		}
+	}
+	opened, err := enc.decryptEvents(ctx, 1, log[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "", "bob", "c"}
+	for i, ev := range opened {
+		got := string(ev.Data)
+		if env, err := ev.Envelope(); err == nil {
+			got = string(env.Data)
+			if i == 2 && env.Type != ErasedEventType {
+				t.Fatalf("offset 2 is %q, not a tombstone", env.Type)
+			}
+		}
+		if got != want[i] {
+			t.Fatalf("offset %d: got %q, want %q", i, got, want[i])
+		}
+	}
+	if got := eventSubject(log[2][3].Data); got != "bob" {
+		t.Fatalf("subject of copied event: %q", got)
+	}
+
+	// An event whose key is gone, and not by erasure, stops the copy.
+	enc.SetStreamClass(3, DataClassRestricted)
+	log[3] = append(log[3], log[1][0])
+	if _, err := (&reencryptor{raw: log, enc: NewEncryption(nil), tenant: 1, append: appendTo}).run(ctx, 3, 4, nil); !errors.Is(err, ErrKeyUnavailable) {
+		t.Fatalf("expected ErrKeyUnavailable, got %v", err)
+	}
+}
+
+func TestDecodeAPIKeyRotation(t *testing.T) {
+	key, info, err := decodeAPIKeyRotation([]byte(`{"new_key":"kmb_live_2","info":{"key_id":"k2","subject":"svc","tenant_id":7,"roles":["reader"],"expires_at_nanos":1000000000}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "kmb_live_2" || info.ID != "k2" || info.Subject != "svc" || info.Tenant != 7 || len(info.Roles) != 1 || info.ExpiresAt.Unix() != 1 {
+		t.Fatalf("decoded %q, %+v", key, info)
+	}
+	if _, info, _ := decodeAPIKeyRotation([]byte(`{"new_key":"k","info":{"expires_at_nanos":null}}`)); !info.ExpiresAt.IsZero() {
+		t.Fatal("non-expiring key has an expiry")
+	}
+}
@@ -0,0 +1,77 @@
+package kimberlite
+
+import "time"
+
+// Row is a result row in column order. Index-based access avoids the
+// per-cell map lookup of QueryResult.Rows, which matters for wide
+// result sets:
+//
+//	id := result.ColumnIndex("id")
+//	for i := range result.Rows {
+//	    row := result.Row(i)
+//	    process(row.Int(id), row.Text(id+1))
+//	}
+//
+// Accessors follow the As* conventions on Value: an out-of-range index
+// or a type mismatch yields the zero value.
+type Row []Value
+
+// Value returns the i-th value, or NULL if i is out of range.
+func (r Row) Value(i int) Value {
+	if i < 0 || i >= len(r) {
+		return NewNull()
+	}
+	return r[i]
+}
+
+// Int returns the i-th value as int64.
+func (r Row) Int(i int) int64 { return r.Value(i).AsInt() }
+
+// Float returns the i-th value as float64.
+func (r Row) Float(i int) float64 { return r.Value(i).AsFloat() }
+
+// Text returns the i-th value as string.
+func (r Row) Text(i int) string { return r.Value(i).AsText() }
+
+// Bool returns the i-th value as bool.
+func (r Row) Bool(i int) bool { return r.Value(i).AsBool() }
+
+// Bytes returns the i-th value as []byte.
+func (r Row) Bytes(i int) []byte { return r.Value(i).AsBytes() }
+
+// Timestamp returns the i-th value as time.Time.
+func (r Row) Timestamp(i int) time.Time { return r.Value(i).AsTimestamp() }
+
+// Record returns the i-th value as a composite value's fields.
+func (r Row) Record(i int) map[string]Value { return r.Value(i).AsRecord() }
+
+// IsNull reports whether the i-th value is NULL (or out of range).
+func (r Row) IsNull(i int) bool { return r.Value(i).IsNull() }
+
+// Row returns the i-th row in column order, or nil if i is out of range.
+func (r *QueryResult) Row(i int) Row {
+	if i < 0 || i >= len(r.Rows) {
+		return nil
+	}
+	if i < len(r.values) {
+		return r.values[i]
+	}
+	// Results assembled by hand only carry the map form.
+	row := make(Row, len(r.Columns))
+	for j, name := range r.Columns {
+		if v, ok := r.Rows[i][name]; ok {
+			row[j] = v
+		}
+	}
+	return row
+}
+
+// ColumnIndex returns the position of the named column, or -1.
+func (r *QueryResult) ColumnIndex(name string) int {
+	for i, c := range r.Columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
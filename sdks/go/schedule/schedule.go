@@ -0,0 +1,315 @@
+// Package schedule appends events at a later time, for reminders and
+// the timeouts of sagas.
+//
+// The server appends events as soon as it receives them, so delayed
+// events are kept in a scheduler stream until they are due. Schedule
+// and After append an event there, with its target stream and due time
+// in metadata; a Scheduler tails the stream and appends each event to
+// its target once due, unless it was cancelled first:
+//
+//	sched := schedule.New(client, reminders,
+//	    schedule.WithCheckpoints(connect.NewStreamCheckpoints(client, checkpoints)))
+//	id, err := sched.After(ctx, admissions, 24*time.Hour,
+//	    kimberlite.Envelope{Type: "DischargeOverdue", CorrelationID: admission})
+//	// ... the discharge arrives in time:
+//	err = sched.Cancel(ctx, id)
+//
+//	err = sched.Run(ctx)
+//
+// Events are appended no earlier than they are due, and later by up to
+// the poll interval when scheduled by another process. Each delivery
+// is recorded in the scheduler stream; an event whose delivery was not
+// recorded before a crash is appended again, with its ID as the
+// idempotency key for the server to deduplicate. Run one Scheduler
+// per scheduler stream.
+package schedule
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// Metadata keys of scheduled events and of the scheduler's records.
+// They are removed from events when they are appended to their target.
+const (
+	MetaScheduleTarget = "schedule.target"
+	MetaScheduleAt     = "schedule.at"
+	// MetaScheduleID is the ID of the scheduled event a cancellation or
+	// delivery record refers to.
+	MetaScheduleID = "schedule.id"
+)
+
+const metaSchedulePrefix = "schedule."
+
+// Types of the records a scheduler stream holds besides scheduled
+// events.
+const (
+	TypeCancelled = "kimberlite.schedule.cancelled"
+	TypeDelivered = "kimberlite.schedule.delivered"
+)
+
+// Scheduler appends the events of a scheduler stream to their targets
+// when they are due. Schedule, After and Cancel are safe for concurrent
+// use; Run and Poll are not.
+type Scheduler struct {
+	log      connect.EventLog
+	stream   kimberlite.StreamID
+	store    connect.CheckpointStore
+	maxBytes uint64
+	interval time.Duration
+	now      func() time.Time
+
+	loaded  bool
+	from    kimberlite.Offset
+	saved   kimberlite.Offset
+	pending map[string]*scheduled
+}
+
+// scheduled is an event waiting in the scheduler stream.
+type scheduled struct {
+	offset kimberlite.Offset
+	target kimberlite.StreamID
+	at     time.Time
+	env    kimberlite.Envelope
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithCheckpoints stores in store how far the scheduler stream is
+// resolved, every event before the position delivered or cancelled, so
+// a restarted scheduler reads it from there rather than from its
+// start. The checkpoint is named "schedule/<stream id>".
+func WithCheckpoints(store connect.CheckpointStore) Option {
+	return func(s *Scheduler) { s.store = store }
+}
+
+// WithPollInterval sets how often Run reads the scheduler stream for
+// events scheduled by other processes. The default is one second.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *Scheduler) { s.interval = d }
+}
+
+// WithBatchBytes sets the maxBytes of each read. The default is 1 MiB.
+func WithBatchBytes(n uint64) Option {
+	return func(s *Scheduler) { s.maxBytes = n }
+}
+
+// New returns a scheduler keeping delayed events in stream, which
+// should hold nothing else.
+func New(log connect.EventLog, stream kimberlite.StreamID, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		log:      log,
+		stream:   stream,
+		maxBytes: 1 << 20,
+		interval: time.Second,
+		now:      time.Now,
+		pending:  make(map[string]*scheduled),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Schedule schedules env to be appended to target at at, and returns
+// its ID, generated if env has none, for Cancel.
+func (s *Scheduler) Schedule(ctx context.Context, target kimberlite.StreamID, at time.Time, env kimberlite.Envelope) (string, error) {
+	if env.ID == "" {
+		env.ID = kimberlite.NewEventID()
+	}
+	meta := make(map[string]string, len(env.Metadata)+2)
+	for k, v := range env.Metadata {
+		meta[k] = v
+	}
+	meta[MetaScheduleTarget] = strconv.FormatUint(uint64(target), 10)
+	meta[MetaScheduleAt] = at.UTC().Format(time.RFC3339Nano)
+	env.Metadata = meta
+	if err := s.record(ctx, env); err != nil {
+		return "", fmt.Errorf("kimberlite: schedule event: %w", err)
+	}
+	return env.ID, nil
+}
+
+// After schedules env to be appended to target after delay.
+func (s *Scheduler) After(ctx context.Context, target kimberlite.StreamID, delay time.Duration, env kimberlite.Envelope) (string, error) {
+	return s.Schedule(ctx, target, s.now().Add(delay), env)
+}
+
+// Cancel cancels the scheduled event id. Cancelling an event that was
+// already appended to its target has no effect.
+func (s *Scheduler) Cancel(ctx context.Context, id string) error {
+	env := kimberlite.Envelope{Type: TypeCancelled, Metadata: map[string]string{MetaScheduleID: id}}
+	if err := s.record(ctx, env); err != nil {
+		return fmt.Errorf("kimberlite: cancel scheduled event %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *Scheduler) record(ctx context.Context, env kimberlite.Envelope) error {
+	data, err := env.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = s.log.AppendContext(ctx, s.stream, data)
+	return err
+}
+
+// Run appends events as they fall due until ctx is done or an error
+// occurs. After an error the scheduler can be run again.
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		if _, err := s.Poll(ctx); err != nil {
+			return err
+		}
+		wait := s.interval
+		if at, ok := s.next(); ok {
+			wait = min(wait, max(at.Sub(s.now()), 0))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Poll reads the events scheduled since the last poll, appends those
+// due to their targets, and returns the number appended.
+func (s *Scheduler) Poll(ctx context.Context) (int, error) {
+	if !s.loaded {
+		if s.store != nil {
+			from, _, err := s.store.Load(ctx, s.checkpointName())
+			if err != nil {
+				return 0, fmt.Errorf("kimberlite: load scheduler checkpoint: %w", err)
+			}
+			s.from, s.saved = from, from
+		}
+		s.loaded = true
+	}
+	if err := s.read(ctx); err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	var due []string
+	for id, e := range s.pending {
+		if !e.at.After(now) {
+			due = append(due, id)
+		}
+	}
+	slices.SortFunc(due, func(a, b string) int {
+		ea, eb := s.pending[a], s.pending[b]
+		if c := ea.at.Compare(eb.at); c != 0 {
+			return c
+		}
+		return cmp.Compare(ea.offset, eb.offset)
+	})
+	var n int
+	for _, id := range due {
+		if err := s.deliver(ctx, id, s.pending[id]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if pos := s.resolved(); s.store != nil && pos > s.saved {
+		if err := s.store.Save(ctx, s.checkpointName(), pos); err != nil {
+			return n, fmt.Errorf("kimberlite: save scheduler checkpoint: %w", err)
+		}
+		s.saved = pos
+	}
+	return n, nil
+}
+
+// read adds the events of the scheduler stream since the last read to
+// pending, and removes the cancelled and delivered ones.
+func (s *Scheduler) read(ctx context.Context) error {
+	for {
+		events, err := s.log.ReadEventsContext(ctx, s.stream, s.from, s.maxBytes)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		for _, ev := range events {
+			env, err := ev.Envelope()
+			if err != nil {
+				return fmt.Errorf("kimberlite: scheduler stream offset %d: %w", ev.Offset, err)
+			}
+			switch env.Type {
+			case TypeCancelled, TypeDelivered:
+				delete(s.pending, env.Metadata[MetaScheduleID])
+				continue
+			}
+			target, err1 := strconv.ParseUint(env.Metadata[MetaScheduleTarget], 10, 64)
+			at, err2 := time.Parse(time.RFC3339Nano, env.Metadata[MetaScheduleAt])
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("kimberlite: scheduler stream offset %d: no schedule metadata", ev.Offset)
+			}
+			s.pending[env.ID] = &scheduled{offset: ev.Offset, target: kimberlite.StreamID(target), at: at, env: env}
+		}
+		s.from = events[len(events)-1].Offset + 1
+	}
+}
+
+// deliver appends a due event to its target and records its delivery.
+func (s *Scheduler) deliver(ctx context.Context, id string, e *scheduled) error {
+	env := e.env
+	env.Metadata = nil
+	for k, v := range e.env.Metadata {
+		if !strings.HasPrefix(k, metaSchedulePrefix) {
+			if env.Metadata == nil {
+				env.Metadata = make(map[string]string)
+			}
+			env.Metadata[k] = v
+		}
+	}
+	data, err := env.Marshal()
+	if err != nil {
+		return err
+	}
+	audit, _ := kimberlite.AuditFromContext(ctx)
+	audit.IdempotencyKey = id
+	if _, err := s.log.AppendContext(kimberlite.WithAudit(ctx, audit), e.target, data); err != nil {
+		return fmt.Errorf("kimberlite: append scheduled event %s to stream %d: %w", id, e.target, err)
+	}
+	if err := s.record(ctx, kimberlite.Envelope{Type: TypeDelivered, Metadata: map[string]string{MetaScheduleID: id}}); err != nil {
+		return fmt.Errorf("kimberlite: record delivery of scheduled event %s: %w", id, err)
+	}
+	delete(s.pending, id)
+	return nil
+}
+
+// next returns the due time of the earliest pending event.
+func (s *Scheduler) next() (time.Time, bool) {
+	var at time.Time
+	for _, e := range s.pending {
+		if at.IsZero() || e.at.Before(at) {
+			at = e.at
+		}
+	}
+	return at, !at.IsZero()
+}
+
+// resolved returns the offset before which every event of the
+// scheduler stream is delivered or cancelled.
+func (s *Scheduler) resolved() kimberlite.Offset {
+	pos := s.from
+	for _, e := range s.pending {
+		pos = min(pos, e.offset)
+	}
+	return pos
+}
+
+func (s *Scheduler) checkpointName() string {
+	return "schedule/" + strconv.FormatUint(uint64(s.stream), 10)
+}
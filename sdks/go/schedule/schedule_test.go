@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+type fakeLog map[kimberlite.StreamID][]kimberlite.Event
+
+func (l fakeLog) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, _ uint64) ([]kimberlite.Event, error) {
+	if int(from) >= len(l[id]) {
+		return nil, nil
+	}
+	return l[id][from:], nil
+}
+
+func (l fakeLog) AppendContext(_ context.Context, id kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error) {
+	first := kimberlite.Offset(len(l[id]))
+	for _, data := range events {
+		l[id] = append(l[id], kimberlite.Event{StreamID: id, Offset: kimberlite.Offset(len(l[id])), Data: data})
+	}
+	return first, nil
+}
+
+const (
+	reminders  kimberlite.StreamID = 1
+	admissions kimberlite.StreamID = 2
+)
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	log := fakeLog{}
+	store := connect.NewMemoryCheckpoints()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(log, reminders, WithCheckpoints(store))
+	s.now = func() time.Time { return now }
+
+	late, err := s.After(ctx, admissions, 2*time.Hour, kimberlite.Envelope{Type: "Late", Metadata: map[string]string{"ward": "4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	soon, _ := s.After(ctx, admissions, time.Hour, kimberlite.Envelope{Type: "Soon"})
+	cancelled, _ := s.After(ctx, admissions, time.Hour, kimberlite.Envelope{Type: "Cancelled"})
+	if err := s.Cancel(ctx, cancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.Poll(ctx); err != nil || n != 0 {
+		t.Fatalf("before due: %d, %v", n, err)
+	}
+	now = now.Add(time.Hour)
+	if n, err := s.Poll(ctx); err != nil || n != 1 {
+		t.Fatalf("first due: %d, %v", n, err)
+	}
+	env, err := log[admissions][0].Envelope()
+	if err != nil || env.ID != soon || env.Type != "Soon" || len(env.Metadata) != 0 {
+		t.Fatalf("delivered %+v, %v", env, err)
+	}
+	if pos, _, _ := store.Load(ctx, s.checkpointName()); pos != 0 {
+		t.Fatalf("checkpoint %d past the pending event", pos)
+	}
+
+	// A restarted scheduler delivers the rest, once.
+	now = now.Add(time.Hour)
+	r := New(log, reminders, WithCheckpoints(store))
+	r.now = func() time.Time { return now }
+	if n, err := r.Poll(ctx); err != nil || n != 1 {
+		t.Fatalf("after restart: %d, %v", n, err)
+	}
+	if len(log[admissions]) != 2 {
+		t.Fatalf("%d events in the target", len(log[admissions]))
+	}
+	env, _ = log[admissions][1].Envelope()
+	if env.ID != late || env.Metadata["ward"] != "4" || env.Metadata[MetaScheduleAt] != "" {
+		t.Fatalf("delivered %+v", env)
+	}
+	if pos, _, _ := store.Load(ctx, r.checkpointName()); pos != kimberlite.Offset(len(log[reminders])-1) {
+		t.Fatalf("checkpoint %d of %d", pos, len(log[reminders]))
+	}
+}
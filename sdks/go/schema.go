@@ -0,0 +1,623 @@
+package kimberlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrSchemaViolation is returned (wrapped in a *SchemaError) when an
+// event payload does not conform to its event type's schema.
+var ErrSchemaViolation = errors.New("kimberlite: schema violation")
+
+// SchemaError describes the first schema violation found in a payload.
+type SchemaError struct {
+	EventType string
+	// Path is a JSON Pointer to the offending value ("" for the root).
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("kimberlite: event %q violates schema at %s: %s", e.EventType, path, e.Message)
+}
+
+// Unwrap makes errors.Is(err, ErrSchemaViolation) hold.
+func (e *SchemaError) Unwrap() error { return ErrSchemaViolation }
+
+// Schema is a compiled JSON Schema.
+//
+// The validator implements the assertion keywords of draft 2020-12
+// that matter for event payloads: type, enum, const, the numeric,
+// string, array and object constraints, allOf/anyOf/oneOf/not, and
+// $ref to local definitions ("#/$defs/..." or "#/definitions/...").
+// Annotations and unknown keywords are ignored, as the specification
+// requires. Patterns use Go's RE2 syntax, which covers the common
+// subset of ECMA-262. Formats date-time, date, email and uuid are
+// asserted; others are ignored.
+type Schema struct {
+	root *schemaNode
+	doc  json.RawMessage
+}
+
+type schemaNode struct {
+	always    *bool // boolean schema
+	types     []string
+	enum      []any
+	constVal  any
+	hasConst  bool
+	format    string
+	ref       string
+	minimum   *float64
+	maximum   *float64
+	exclMin   *float64
+	exclMax   *float64
+	multOf    *float64
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	items     *schemaNode
+	minItems  *int
+	maxItems  *int
+	unique    bool
+	props     map[string]*schemaNode
+	required  []string
+	addProps  *schemaNode
+	allOf     []*schemaNode
+	anyOf     []*schemaNode
+	oneOf     []*schemaNode
+	not       *schemaNode
+	defs      map[string]*schemaNode
+}
+
+// CompileSchema parses a JSON Schema document.
+func CompileSchema(doc []byte) (*Schema, error) {
+	var raw any
+	if err := decodeJSONNumbers(doc, &raw); err != nil {
+		return nil, fmt.Errorf("kimberlite: schema: %w", err)
+	}
+	root, err := compileSchemaNode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: schema: %w", err)
+	}
+	return &Schema{root: root, doc: append(json.RawMessage(nil), doc...)}, nil
+}
+
+// Document returns the JSON Schema document s was compiled from.
+func (s *Schema) Document() json.RawMessage {
+	return s.doc
+}
+
+// MustCompileSchema is like CompileSchema but panics on error. It is
+// intended for schemas embedded in the program.
+func MustCompileSchema(doc []byte) *Schema {
+	s, err := CompileSchema(doc)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func decodeJSONNumbers(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data after JSON value")
+	}
+	return nil
+}
+
+func compileSchemaNode(raw any) (*schemaNode, error) {
+	if b, ok := raw.(bool); ok {
+		return &schemaNode{always: &b}, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("schema must be an object or boolean, got %T", raw)
+	}
+	n := &schemaNode{}
+	var err error
+
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		n.types = []string{t}
+	case []any:
+		for _, e := range t {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("type entries must be strings")
+			}
+			n.types = append(n.types, s)
+		}
+	default:
+		return nil, fmt.Errorf("type must be a string or array")
+	}
+	if e, ok := m["enum"].([]any); ok {
+		n.enum = e
+	}
+	n.constVal, n.hasConst = m["const"]
+	n.format, _ = m["format"].(string)
+	n.ref, _ = m["$ref"].(string)
+
+	nums := []struct {
+		key string
+		dst **float64
+	}{
+		{"minimum", &n.minimum}, {"maximum", &n.maximum},
+		{"exclusiveMinimum", &n.exclMin}, {"exclusiveMaximum", &n.exclMax},
+		{"multipleOf", &n.multOf},
+	}
+	for _, f := range nums {
+		if v, ok := m[f.key].(json.Number); ok {
+			x, err := v.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.key, err)
+			}
+			*f.dst = &x
+		}
+	}
+	ints := []struct {
+		key string
+		dst **int
+	}{
+		{"minLength", &n.minLength}, {"maxLength", &n.maxLength},
+		{"minItems", &n.minItems}, {"maxItems", &n.maxItems},
+	}
+	for _, f := range ints {
+		if v, ok := m[f.key].(json.Number); ok {
+			x, err := strconv.Atoi(v.String())
+			if err != nil || x < 0 {
+				return nil, fmt.Errorf("%s must be a non-negative integer", f.key)
+			}
+			*f.dst = &x
+		}
+	}
+	if p, ok := m["pattern"].(string); ok {
+		if n.pattern, err = regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("pattern: %w", err)
+		}
+	}
+	n.unique, _ = m["uniqueItems"].(bool)
+	if r, ok := m["required"].([]any); ok {
+		for _, e := range r {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("required entries must be strings")
+			}
+			n.required = append(n.required, s)
+		}
+	}
+
+	one := func(key string) (*schemaNode, error) {
+		v, ok := m[key]
+		if !ok {
+			return nil, nil
+		}
+		sub, err := compileSchemaNode(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return sub, nil
+	}
+	if n.items, err = one("items"); err != nil {
+		return nil, err
+	}
+	if n.addProps, err = one("additionalProperties"); err != nil {
+		return nil, err
+	}
+	if n.not, err = one("not"); err != nil {
+		return nil, err
+	}
+
+	list := func(key string) ([]*schemaNode, error) {
+		v, ok := m[key].([]any)
+		if !ok {
+			return nil, nil
+		}
+		out := make([]*schemaNode, len(v))
+		for i, e := range v {
+			sub, err := compileSchemaNode(e)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%d: %w", key, i, err)
+			}
+			out[i] = sub
+		}
+		return out, nil
+	}
+	if n.allOf, err = list("allOf"); err != nil {
+		return nil, err
+	}
+	if n.anyOf, err = list("anyOf"); err != nil {
+		return nil, err
+	}
+	if n.oneOf, err = list("oneOf"); err != nil {
+		return nil, err
+	}
+
+	object := func(key string) (map[string]*schemaNode, error) {
+		v, ok := m[key].(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		out := make(map[string]*schemaNode, len(v))
+		for name, e := range v {
+			sub, err := compileSchemaNode(e)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", key, name, err)
+			}
+			out[name] = sub
+		}
+		return out, nil
+	}
+	if n.props, err = object("properties"); err != nil {
+		return nil, err
+	}
+	if n.defs, err = object("$defs"); err != nil {
+		return nil, err
+	}
+	if n.defs == nil {
+		if n.defs, err = object("definitions"); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// Validate checks a JSON document against the schema. Violations are
+// reported as a *SchemaError.
+func (s *Schema) Validate(data []byte) error {
+	var doc any
+	if err := decodeJSONNumbers(data, &doc); err != nil {
+		return &SchemaError{Message: "payload is not valid JSON: " + err.Error()}
+	}
+	v := schemaValidator{root: s.root}
+	if path, msg, ok := v.check(s.root, doc, ""); !ok {
+		return &SchemaError{Path: path, Message: msg}
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	root  *schemaNode
+	depth int
+}
+
+func (v *schemaValidator) resolve(ref string) (*schemaNode, bool) {
+	if ref == "#" {
+		return v.root, true
+	}
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			n, found := v.root.defs[name]
+			return n, found
+		}
+	}
+	return nil, false
+}
+
+// check reports whether doc satisfies n, and if not, where and why.
+func (v *schemaValidator) check(n *schemaNode, doc any, path string) (string, string, bool) {
+	if n.always != nil {
+		if *n.always {
+			return "", "", true
+		}
+		return path, "no value is allowed here", false
+	}
+	if n.ref != "" {
+		target, ok := v.resolve(n.ref)
+		if !ok {
+			return path, fmt.Sprintf("unresolvable $ref %q", n.ref), false
+		}
+		if v.depth > 64 {
+			return path, "$ref recursion too deep", false
+		}
+		v.depth++
+		p, msg, ok := v.check(target, doc, path)
+		v.depth--
+		if !ok {
+			return p, msg, false
+		}
+	}
+
+	if len(n.types) > 0 && !schemaTypeMatches(n.types, doc) {
+		return path, fmt.Sprintf("expected %s, got %s", strings.Join(n.types, " or "), jsonTypeName(doc)), false
+	}
+	if n.enum != nil {
+		found := false
+		for _, e := range n.enum {
+			if jsonEqual(e, doc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return path, "value is not one of the allowed values", false
+		}
+	}
+	if n.hasConst && !jsonEqual(n.constVal, doc) {
+		return path, "value does not match const", false
+	}
+
+	switch t := doc.(type) {
+	case json.Number:
+		if p, msg, ok := n.checkNumber(t, path); !ok {
+			return p, msg, false
+		}
+	case string:
+		if p, msg, ok := n.checkString(t, path); !ok {
+			return p, msg, false
+		}
+	case []any:
+		if n.minItems != nil && len(t) < *n.minItems {
+			return path, fmt.Sprintf("array has %d items, fewer than %d", len(t), *n.minItems), false
+		}
+		if n.maxItems != nil && len(t) > *n.maxItems {
+			return path, fmt.Sprintf("array has %d items, more than %d", len(t), *n.maxItems), false
+		}
+		if n.unique {
+			for i := range t {
+				for j := i + 1; j < len(t); j++ {
+					if jsonEqual(t[i], t[j]) {
+						return path, fmt.Sprintf("items %d and %d are equal", i, j), false
+					}
+				}
+			}
+		}
+		if n.items != nil {
+			for i, e := range t {
+				if p, msg, ok := v.check(n.items, e, path+"/"+strconv.Itoa(i)); !ok {
+					return p, msg, false
+				}
+			}
+		}
+	case map[string]any:
+		for _, name := range n.required {
+			if _, ok := t[name]; !ok {
+				return path, fmt.Sprintf("missing required property %q", name), false
+			}
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub, ok := n.props[k]
+			if !ok {
+				sub = n.addProps
+			}
+			if sub == nil {
+				continue
+			}
+			if p, msg, ok := v.check(sub, t[k], path+"/"+jsonPointerEscape(k)); !ok {
+				return p, msg, false
+			}
+		}
+	}
+
+	for _, sub := range n.allOf {
+		if p, msg, ok := v.check(sub, doc, path); !ok {
+			return p, msg, false
+		}
+	}
+	if n.anyOf != nil {
+		matched := false
+		for _, sub := range n.anyOf {
+			if _, _, ok := v.check(sub, doc, path); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return path, "value matches none of anyOf", false
+		}
+	}
+	if n.oneOf != nil {
+		matches := 0
+		for _, sub := range n.oneOf {
+			if _, _, ok := v.check(sub, doc, path); ok {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return path, fmt.Sprintf("value matches %d of oneOf, want exactly 1", matches), false
+		}
+	}
+	if n.not != nil {
+		if _, _, ok := v.check(n.not, doc, path); ok {
+			return path, "value matches a disallowed schema", false
+		}
+	}
+	return "", "", true
+}
+
+func (n *schemaNode) checkNumber(num json.Number, path string) (string, string, bool) {
+	x, err := num.Float64()
+	if err != nil {
+		return path, "invalid number", false
+	}
+	switch {
+	case n.minimum != nil && x < *n.minimum:
+		return path, fmt.Sprintf("%v is less than minimum %v", num, *n.minimum), false
+	case n.maximum != nil && x > *n.maximum:
+		return path, fmt.Sprintf("%v is greater than maximum %v", num, *n.maximum), false
+	case n.exclMin != nil && x <= *n.exclMin:
+		return path, fmt.Sprintf("%v is not greater than %v", num, *n.exclMin), false
+	case n.exclMax != nil && x >= *n.exclMax:
+		return path, fmt.Sprintf("%v is not less than %v", num, *n.exclMax), false
+	}
+	if n.multOf != nil && *n.multOf > 0 {
+		q := x / *n.multOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			return path, fmt.Sprintf("%v is not a multiple of %v", num, *n.multOf), false
+		}
+	}
+	return "", "", true
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func (n *schemaNode) checkString(s, path string) (string, string, bool) {
+	length := utf8.RuneCountInString(s)
+	if n.minLength != nil && length < *n.minLength {
+		return path, fmt.Sprintf("string is shorter than %d characters", *n.minLength), false
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		return path, fmt.Sprintf("string is longer than %d characters", *n.maxLength), false
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		return path, fmt.Sprintf("string does not match pattern %q", n.pattern.String()), false
+	}
+	var err error
+	switch n.format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339Nano, s)
+	case "date":
+		_, err = time.Parse("2006-01-02", s)
+	case "email":
+		_, err = mail.ParseAddress(s)
+	case "uuid":
+		if !uuidPattern.MatchString(s) {
+			err = errors.New("invalid")
+		}
+	}
+	if err != nil {
+		return path, fmt.Sprintf("string is not a valid %s", n.format), false
+	}
+	return "", "", true
+}
+
+func schemaTypeMatches(types []string, doc any) bool {
+	actual := jsonTypeName(doc)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON Schema type of a decoded value.
+// Numbers with a zero fractional part are integers.
+func jsonTypeName(doc any) string {
+	switch t := doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := t.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", doc)
+}
+
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, err1 := x.Float64()
+		fy, err2 := y.Float64()
+		return err1 == nil && err2 == nil && fx == fy
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, xv := range x {
+			yv, ok := y[k]
+			if !ok || !jsonEqual(xv, yv) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func jsonPointerEscape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// RegisterSchema requires payloads of eventType to conform to schema.
+// AppendEnvelopes validates JSON payloads of that type, and
+// AppendValues validates the JSON form of the value whatever codec
+// encodes it, rejecting violations before they reach the immutable
+// log. A nil schema removes the requirement.
+func (r *CodecRegistry) RegisterSchema(eventType string, schema *Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if schema == nil {
+		delete(r.schemas, eventType)
+		return
+	}
+	if r.schemas == nil {
+		r.schemas = make(map[string]*Schema)
+	}
+	r.schemas[eventType] = schema
+}
+
+func (r *CodecRegistry) schemaFor(eventType string) *Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schemas[eventType]
+}
+
+// validatePayload checks a JSON payload of eventType against its
+// registered schema, if any.
+func (r *CodecRegistry) validatePayload(eventType string, data []byte) error {
+	s := r.schemaFor(eventType)
+	if s == nil {
+		return nil
+	}
+	if err := s.Validate(data); err != nil {
+		var se *SchemaError
+		if errors.As(err, &se) {
+			se.EventType = eventType
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package kimberlite
+
+import (
+	"errors"
+	"testing"
+)
+
+const admittedSchema = `{
+	"type": "object",
+	"required": ["patient", "bed"],
+	"additionalProperties": false,
+	"properties": {
+		"patient": {"type": "string", "pattern": "^p-[0-9]+$"},
+		"bed":     {"type": "integer", "minimum": 1},
+		"score":   {"type": "number", "exclusiveMaximum": 1},
+		"tags":    {"type": "array", "items": {"$ref": "#/$defs/tag"}, "uniqueItems": true},
+		"notes":   {"type": ["string", "null"]}
+	},
+	"$defs": {"tag": {"enum": ["icu", "er"]}}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	s := MustCompileSchema([]byte(admittedSchema))
+	if err := s.Validate([]byte(`{"patient":"p-1","bed":4,"score":0.5,"tags":["icu"],"notes":null}`)); err != nil {
+		t.Fatalf("valid payload rejected: %v", err)
+	}
+
+	cases := []struct {
+		doc, path string
+	}{
+		{`{"bed":4}`, ""},
+		{`{"patient":"x","bed":4}`, "/patient"},
+		{`{"patient":"p-1","bed":0}`, "/bed"},
+		{`{"patient":"p-1","bed":1.5}`, "/bed"},
+		{`{"patient":"p-1","bed":1,"score":1}`, "/score"},
+		{`{"patient":"p-1","bed":1,"tags":["icu","icu"]}`, "/tags"},
+		{`{"patient":"p-1","bed":1,"tags":["ward"]}`, "/tags/0"},
+		{`{"patient":"p-1","bed":1,"extra":true}`, "/extra"},
+		{`not json`, ""},
+	}
+	for _, tc := range cases {
+		err := s.Validate([]byte(tc.doc))
+		var se *SchemaError
+		if !errors.As(err, &se) || !errors.Is(err, ErrSchemaViolation) {
+			t.Fatalf("%s: expected schema violation, got %v", tc.doc, err)
+		}
+		if se.Path != tc.path {
+			t.Errorf("%s: path = %q, want %q (%s)", tc.doc, se.Path, tc.path, se.Message)
+		}
+	}
+}
+
+func TestSchemaCombinators(t *testing.T) {
+	s := MustCompileSchema([]byte(`{"oneOf":[{"type":"integer"},{"type":"number","minimum":10}],"not":{"const":42}}`))
+	for doc, ok := range map[string]bool{`3`: true, `10.5`: true, `12`: false, `42`: false, `"x"`: false} {
+		if err := s.Validate([]byte(doc)); (err == nil) != ok {
+			t.Errorf("%s: err = %v, want ok=%v", doc, err, ok)
+		}
+	}
+	if _, err := CompileSchema([]byte(`{"type":5}`)); err == nil {
+		t.Fatal("invalid schema should fail to compile")
+	}
+}
+
+func TestRegistryValidatePayload(t *testing.T) {
+	reg := NewCodecRegistry()
+	reg.RegisterSchema("PatientAdmitted", MustCompileSchema([]byte(admittedSchema)))
+	err := reg.validatePayload("PatientAdmitted", []byte(`{"patient":"p-1"}`))
+	var se *SchemaError
+	if !errors.As(err, &se) || se.EventType != "PatientAdmitted" {
+		t.Fatalf("expected violation attributed to event type, got %v", err)
+	}
+	if err := reg.validatePayload("Other", []byte(`not json`)); err != nil {
+		t.Fatalf("types without a schema should not be validated: %v", err)
+	}
+}
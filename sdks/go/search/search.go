@@ -0,0 +1,355 @@
+// Package search finds the rows of a table whose text columns contain
+// a set of words, ranked by relevance, with the matches highlighted:
+//
+//	results, err := search.New("documents", "title", "body").
+//	    Weight("title", 3).
+//	    Where(query.Eq("matter_id", 7)).
+//	    Highlight("<mark>", "</mark>", 60).
+//	    Limit(10).
+//	    Run(ctx, client, `breach "72 hours"`)
+//	for _, r := range results {
+//	    fmt.Println(r.Row["title"].AsText(), r.Score, r.Highlights["body"])
+//	}
+//
+// The server has no full-text index, tokenizer or ranking, so a Query
+// finds candidates with ILIKE conditions, one per term, and ranks them
+// in the client. Matching is case-insensitive substring matching: a
+// term matches inside longer words, and there is no stemming. Each
+// search reads the table, less the rows its Where conditions exclude,
+// so it suits tables of up to some hundreds of thousands of rows, best
+// narrowed by conditions on indexed columns.
+package search
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/query"
+)
+
+// Query is a search of a table's text columns. Its methods modify and
+// return the query, so calls chain; a Query is not safe for concurrent
+// use, but may be run any number of times.
+type Query struct {
+	table      string
+	columns    []string
+	weights    map[string]float64
+	selected   []string
+	where      []query.Cond
+	limit      int
+	candidates int
+	highlight  *highlight
+}
+
+type highlight struct {
+	pre, post string
+	context   int
+}
+
+// Result is a row a Query found.
+type Result struct {
+	// Row holds the selected columns and the searched ones.
+	Row map[string]kimberlite.Value
+	// Score is the row's relevance, higher for more frequent matches,
+	// in shorter text and in heavier columns. Scores compare between
+	// the results of one search only.
+	Score float64
+	// Highlights holds, for each searched column with a match, its text
+	// with the matches marked, when the query has Highlight set.
+	Highlights map[string]string
+}
+
+// New returns a search of the text columns of table.
+func New(table string, columns ...string) *Query {
+	return &Query{table: table, columns: columns, limit: 20, candidates: 1000}
+}
+
+// Weight multiplies the score of matches in column by w. The default
+// weight is 1.
+func (q *Query) Weight(column string, w float64) *Query {
+	if q.weights == nil {
+		q.weights = make(map[string]float64)
+	}
+	q.weights[column] = w
+	return q
+}
+
+// Select sets the columns returned besides the searched ones. By
+// default every column is returned.
+func (q *Query) Select(columns ...string) *Query {
+	q.selected = columns
+	return q
+}
+
+// Where adds conditions rows must meet, as SelectBuilder.Where does.
+func (q *Query) Where(conds ...query.Cond) *Query {
+	q.where = append(q.where, conds...)
+	return q
+}
+
+// Limit sets the most results returned, the best first. The default is
+// 20.
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// Candidates sets the most matching rows read and ranked; rows beyond
+// them are not found. The default is 1000; zero reads every match.
+func (q *Query) Candidates(n int) *Query {
+	q.candidates = n
+	return q
+}
+
+// Highlight sets Result.Highlights, marking each match with pre before
+// and post after it. With context above zero, only fragments of the
+// text are kept, each of up to context characters either side of its
+// matches, separated by " … ". The text is not escaped; to show it as
+// markup, mark matches with placeholders such as "\x00" and "\x01",
+// escape the text, then replace the placeholders with tags.
+func (q *Query) Highlight(pre, post string, context int) *Query {
+	q.highlight = &highlight{pre: pre, post: post, context: context}
+	return q
+}
+
+// Run searches for the rows containing every term of text in one or
+// more of the searched columns. Terms are separated by spaces; a term
+// in double quotes may contain spaces, and matches as a phrase.
+func (q *Query) Run(ctx context.Context, c *kimberlite.Client, text string) ([]Result, error) {
+	terms := Terms(text)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("kimberlite: no search terms in %q", text)
+	}
+	if len(q.columns) == 0 {
+		return nil, fmt.Errorf("kimberlite: no columns to search in %s", q.table)
+	}
+	var columns []string
+	if q.selected != nil {
+		columns = append(columns, q.selected...)
+		for _, col := range q.columns {
+			if !slices.Contains(columns, col) {
+				columns = append(columns, col)
+			}
+		}
+	}
+	sel := query.Select(columns...).From(q.table).Where(q.where...)
+	if q.candidates > 0 {
+		sel.Limit(q.candidates)
+	}
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		conds := make([]query.Cond, len(q.columns))
+		for i, col := range q.columns {
+			conds[i] = query.ILike(col, pattern)
+		}
+		sel.Where(query.Or(conds...))
+	}
+	r, err := sel.Query(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return q.rank(r.Rows, terms), nil
+}
+
+// BM25's term-frequency saturation and length normalisation.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// rank scores rows with BM25 over their searched columns, without its
+// inverse document frequency: every row contains every term, so it
+// would weight the terms alike.
+func (q *Query) rank(rows []map[string]kimberlite.Value, terms []string) []Result {
+	avg := make(map[string]float64, len(q.columns))
+	for _, row := range rows {
+		for _, col := range q.columns {
+			avg[col] += float64(wordCount(row[col].AsText())) / float64(len(rows))
+		}
+	}
+	results := make([]Result, len(rows))
+	for i, row := range rows {
+		res := Result{Row: row}
+		for _, col := range q.columns {
+			text := row[col].AsText()
+			norm := 1 - b
+			if avg[col] > 0 {
+				norm += b * float64(wordCount(text)) / avg[col]
+			}
+			var matches [][2]int
+			for _, term := range terms {
+				found := find(text, term)
+				if tf := float64(len(found)); tf > 0 {
+					res.Score += q.weight(col) * tf * (k1 + 1) / (tf + k1*norm)
+				}
+				matches = append(matches, found...)
+			}
+			if q.highlight != nil && len(matches) > 0 {
+				if res.Highlights == nil {
+					res.Highlights = make(map[string]string)
+				}
+				res.Highlights[col] = q.highlight.mark(text, matches)
+			}
+		}
+		results[i] = res
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if q.limit > 0 && len(results) > q.limit {
+		results = results[:q.limit]
+	}
+	return results
+}
+
+func (q *Query) weight(column string) float64 {
+	if w, ok := q.weights[column]; ok {
+		return w
+	}
+	return 1
+}
+
+// mark returns text with matches, byte ranges of text, marked.
+func (h *highlight) mark(text string, matches [][2]int) string {
+	matches = merge(matches)
+	var out strings.Builder
+	if h.context <= 0 {
+		at := 0
+		for _, m := range matches {
+			out.WriteString(text[at:m[0]] + h.pre + text[m[0]:m[1]] + h.post)
+			at = m[1]
+		}
+		out.WriteString(text[at:])
+		return out.String()
+	}
+	// Widen each match by context runes either side and join the
+	// fragments that then overlap.
+	var frags [][2]int
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		for n := 0; n < h.context && start > 0; n++ {
+			_, size := utf8.DecodeLastRuneInString(text[:start])
+			start -= size
+		}
+		for n := 0; n < h.context && end < len(text); n++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+		if len(frags) > 0 && start <= frags[len(frags)-1][1] {
+			frags[len(frags)-1][1] = end
+		} else {
+			frags = append(frags, [2]int{start, end})
+		}
+	}
+	i := 0
+	for n, f := range frags {
+		if n > 0 {
+			out.WriteString(" … ")
+		} else if f[0] > 0 {
+			out.WriteString("… ")
+		}
+		var frag strings.Builder
+		at := f[0]
+		for ; i < len(matches) && matches[i][1] <= f[1]; i++ {
+			m := matches[i]
+			frag.WriteString(text[at:m[0]] + h.pre + text[m[0]:m[1]] + h.post)
+			at = m[1]
+		}
+		frag.WriteString(text[at:f[1]])
+		out.WriteString(strings.TrimSpace(frag.String()))
+	}
+	if frags[len(frags)-1][1] < len(text) {
+		out.WriteString(" …")
+	}
+	return out.String()
+}
+
+// merge sorts byte ranges and joins those that overlap.
+func merge(ranges [][2]int) [][2]int {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	out := ranges[:0]
+	for _, r := range ranges {
+		if n := len(out); n > 0 && r[0] <= out[n-1][1] {
+			out[n-1][1] = max(out[n-1][1], r[1])
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Terms splits text into the terms Run searches for: words separated
+// by spaces, and phrases in double quotes, lower-cased and without
+// repeats.
+func Terms(text string) []string {
+	var terms []string
+	add := func(term string) {
+		term = strings.ToLower(strings.Join(strings.Fields(term), " "))
+		if term != "" && !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	for {
+		open := strings.IndexByte(text, '"')
+		if open < 0 {
+			break
+		}
+		closing := strings.IndexByte(text[open+1:], '"')
+		if closing < 0 {
+			break
+		}
+		for _, w := range strings.Fields(text[:open]) {
+			add(w)
+		}
+		add(text[open+1 : open+1+closing])
+		text = text[open+closing+2:]
+	}
+	for _, w := range strings.Fields(strings.ReplaceAll(text, `"`, " ")) {
+		add(w)
+	}
+	return terms
+}
+
+// find returns the byte ranges of text matching term, ignoring case,
+// without overlaps.
+func find(text, term string) [][2]int {
+	var out [][2]int
+	for i := 0; i < len(text); {
+		if end, ok := matchFold(text[i:], term); ok {
+			out = append(out, [2]int{i, i + end})
+			i += end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	return out
+}
+
+// matchFold reports whether s starts with term, ignoring case, and the
+// length in bytes of the prefix of s that matched.
+func matchFold(s, term string) (int, bool) {
+	i := 0
+	for _, t := range term {
+		if i >= len(s) {
+			return 0, false
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r != t && unicode.ToLower(r) != unicode.ToLower(t) {
+			return 0, false
+		}
+		i += size
+	}
+	return i, true
+}
+
+func wordCount(s string) int { return len(strings.Fields(s)) }
+
+// escapeLike makes the wildcards of s literal in a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer("%", `\%`, "_", `\_`).Replace(s)
+}
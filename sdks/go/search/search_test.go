@@ -0,0 +1,81 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go/kimberlitetest"
+	"github.com/kimberlitedb/kimberlite-go/query"
+)
+
+func TestTerms(t *testing.T) {
+	got := Terms(`Breach  "72   hours" notice breach "unclosed`)
+	want := []string{"breach", "72 hours", "notice", "unclosed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("terms %q", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	client := kimberlitetest.NewMockClient()
+	for _, sql := range []string{
+		"CREATE TABLE documents (id BIGINT NOT NULL PRIMARY KEY, matter_id BIGINT, title TEXT, body TEXT)",
+		`INSERT INTO documents (id, matter_id, title, body) VALUES
+			(1, 7, 'Engagement letter', 'Scope of the breach review and fees.'),
+			(2, 7, 'Breach notice', 'Notify the regulator of the breach within 72 hours; a breach report follows.'),
+			(3, 7, 'Minutes', 'No breach discussed.'),
+			(4, 8, 'Breach notice', 'Another matter''s breach.'),
+			(5, 7, 'Discount_50%', 'Breach of 50% discount terms.')`,
+	} {
+		if _, err := client.ExecContext(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := New("documents", "title", "body").
+		Weight("title", 3).
+		Select("id").
+		Where(query.Eq("matter_id", 7)).
+		Highlight("[", "]", 0).
+		Run(ctx, client, "BREACH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, r := range results {
+		ids = append(ids, r.Row["id"].AsInt())
+	}
+	if len(ids) != 4 || ids[0] != 2 {
+		t.Fatalf("ids %v", ids)
+	}
+	top := results[0]
+	if top.Highlights["title"] != "[Breach] notice" ||
+		top.Highlights["body"] != "Notify the regulator of the [breach] within 72 hours; a [breach] report follows." {
+		t.Fatalf("highlights %q", top.Highlights)
+	}
+	if _, ok := results[1].Row["matter_id"]; ok {
+		t.Fatal("unselected column returned")
+	}
+
+	results, err = New("documents", "body").Highlight("<", ">", 8).Run(ctx, client, `"72 hours" breach`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("phrase: %d results", len(results))
+	}
+	if got := results[0].Highlights["body"]; got != "… of the <breach> within <72 hours>; a <breach> report …" {
+		t.Fatalf("fragments %q", got)
+	}
+
+	results, err = New("documents", "title").Run(ctx, client, "_50%")
+	if err != nil || len(results) != 1 || results[0].Row["id"].AsInt() != 5 {
+		t.Fatalf("wildcards: %v, %v", results, err)
+	}
+
+	if _, err := New("documents", "title").Run(ctx, client, `  ""  `); err == nil {
+		t.Fatal("searched without terms")
+	}
+}
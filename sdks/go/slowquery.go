@@ -0,0 +1,156 @@
+package kimberlite
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// SlowQuery describes a Query or Exec call that took at least the slow
+// threshold.
+type SlowQuery struct {
+	// Operation is OpQuery or OpExec.
+	Operation string
+	// Fingerprint is the statement as Fingerprint returns it, the same
+	// for every call of the statement whatever its values.
+	Fingerprint string
+	// Duration is the round-trip time of the statement.
+	Duration time.Duration
+	// Stats describes a query's result. For Exec calls, Rows is the
+	// number of rows affected and Bytes is zero.
+	Stats QueryStats
+	// Err is the error the call failed with, if it did.
+	Err error
+}
+
+// OnSlowQuery calls fn, after the result arrives, for every Query and
+// Exec call that takes at least the threshold set with
+// WithSlowThreshold, one second by default:
+//
+//	client, err := kimberlite.Connect(addr, kimberlite.WithTenant(1),
+//	    kimberlite.WithSlowThreshold(250*time.Millisecond),
+//	    kimberlite.OnSlowQuery(func(ctx context.Context, q kimberlite.SlowQuery) {
+//	        slowQueries.WithLabelValues(q.Fingerprint).Observe(q.Duration.Seconds())
+//	    }))
+//
+// fn runs on the calling goroutine, with the call's context, before
+// the call returns; it should hand slow queries to a pipeline rather
+// than ship them itself. Statements are reported only by fingerprint,
+// so values in their text are not passed on.
+func OnSlowQuery(fn func(ctx context.Context, q SlowQuery)) Option {
+	return func(c *Client) {
+		c.onSlowQuery = fn
+	}
+}
+
+// reportSlow calls the OnSlowQuery hook if stats.Elapsed reaches the
+// slow threshold.
+func (c *Client) reportSlow(ctx context.Context, op, sql string, stats QueryStats, err error) {
+	if c.onSlowQuery == nil || c.slowThreshold <= 0 || stats.Elapsed < c.slowThreshold {
+		return
+	}
+	c.onSlowQuery(ctx, SlowQuery{
+		Operation:   op,
+		Fingerprint: Fingerprint(sql),
+		Duration:    stats.Elapsed,
+		Stats:       stats,
+		Err:         err,
+	})
+}
+
+// Fingerprint returns sql with its literals and parameters replaced by
+// ?, its comments dropped and its runs of spaces collapsed, so calls of
+// one statement with different values, or with lists of different
+// lengths, share a fingerprint:
+//
+//	SELECT * FROM patients WHERE id IN (1, 2, 3) AND ward = $1 -- rounds
+//
+// has the fingerprint
+//
+//	SELECT * FROM patients WHERE id IN (?) AND ward = ?
+func Fingerprint(sql string) string {
+	sql = redactSQL(sql)
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case isSpace(c):
+			space = true
+			i++
+			continue
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			space = true
+			continue
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(sql)
+			}
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		switch {
+		case c == '"':
+			j := skipQuoted(sql, i, c)
+			b.WriteString(sql[i:j])
+			i = j
+		case placeholderEnd(sql, i) > i:
+			// A list of placeholders counts as one.
+			j := placeholderEnd(sql, i)
+			for {
+				k := j
+				for k < len(sql) && isSpace(sql[k]) {
+					k++
+				}
+				if k >= len(sql) || sql[k] != ',' {
+					break
+				}
+				for k++; k < len(sql) && isSpace(sql[k]); k++ {
+				}
+				next := placeholderEnd(sql, k)
+				if next == k {
+					break
+				}
+				j = next
+			}
+			b.WriteByte('?')
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			b.WriteString(sql[i:j])
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// placeholderEnd returns the offset just past the ? or $N placeholder
+// at i of redacted sql, or i if there is none.
+func placeholderEnd(sql string, i int) int {
+	switch {
+	case i < len(sql) && sql[i] == '?':
+		return i + 1
+	case i+1 < len(sql) && sql[i] == '$' && isDigit(sql[i+1]):
+		j := i + 1
+		for j < len(sql) && isDigit(sql[j]) {
+			j++
+		}
+		return j
+	}
+	return i
+}
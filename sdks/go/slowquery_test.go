@@ -0,0 +1,52 @@
+package kimberlite
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	for sql, want := range map[string]string{
+		"SELECT * FROM patients WHERE id IN (1, 2, 3) AND ward = $1 -- rounds": "SELECT * FROM patients WHERE id IN (?) AND ward = ?",
+		"select  *\n\tfrom t /* hint */ where name = 'O''Neil'":                "select * from t where name = ?",
+		"INSERT INTO t (a, b) VALUES ($1, $2), ($3, $4)":                       "INSERT INTO t (a, b) VALUES (?), (?)",
+		`SELECT "weird  name", x1 FROM t LIMIT 10`:                             `SELECT "weird  name", x1 FROM t LIMIT ?`,
+	} {
+		if got := Fingerprint(sql); got != want {
+			t.Errorf("Fingerprint(%q) = %q, want %q", sql, got, want)
+		}
+	}
+}
+
+func TestOnSlowQuery(t *testing.T) {
+	var slow []SlowQuery
+	hook := OnSlowQuery(func(_ context.Context, q SlowQuery) { slow = append(slow, q) })
+	c, err := Connect("test", WithTenant(1), WithBackend(&recordingBackend{}), WithSlowThreshold(time.Nanosecond), hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Query("SELECT * FROM patients WHERE ssn = '123-45-6789'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exec("DELETE FROM patients WHERE id = $1", NewInt(1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(slow) != 2 || slow[0].Operation != OpQuery || slow[1].Operation != OpExec {
+		t.Fatalf("slow = %+v", slow)
+	}
+	if slow[0].Fingerprint != "SELECT * FROM patients WHERE ssn = ?" || slow[0].Duration <= 0 || slow[0].Duration != slow[0].Stats.Elapsed {
+		t.Fatalf("query = %+v", slow[0])
+	}
+
+	slow = nil
+	fast, err := Connect("test", WithTenant(1), WithBackend(&recordingBackend{}), WithSlowThreshold(time.Hour), hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fast.Close()
+	if _, err := fast.Query("SELECT 1"); err != nil || len(slow) != 0 {
+		t.Fatalf("fast query reported: %+v, %v", slow, err)
+	}
+}
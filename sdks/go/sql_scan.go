@@ -0,0 +1,185 @@
+package kimberlite
+
+import "strings"
+
+// placeholder is a parameter reference found in SQL text. start and
+// end are byte offsets of the whole token ("$3", ":patient_id").
+type placeholder struct {
+	start, end int
+	index      int    // 1-based, for positional placeholders
+	name       string // for named placeholders
+}
+
+// walkSQL calls visit for every byte offset of sql that lies outside
+// string literals, quoted identifiers, and comments. visit returns the
+// offset to continue scanning from.
+func walkSQL(sql string, visit func(i int) int) {
+	for i := 0; i < len(sql); {
+		switch c := sql[i]; {
+		case c == '\'' || c == '"':
+			i = skipQuoted(sql, i, c)
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			i += 2
+			for i+1 < len(sql) && !(sql[i] == '*' && sql[i+1] == '/') {
+				i++
+			}
+			i += 2
+		default:
+			i = visit(i)
+		}
+	}
+}
+
+// scanPlaceholders returns every $N placeholder in sql.
+func scanPlaceholders(sql string) []placeholder {
+	var out []placeholder
+	walkSQL(sql, func(i int) int {
+		if sql[i] != '$' || i+1 >= len(sql) || !isDigit(sql[i+1]) {
+			return i + 1
+		}
+		j, n := i+1, 0
+		for j < len(sql) && isDigit(sql[j]) {
+			n = n*10 + int(sql[j]-'0')
+			j++
+		}
+		out = append(out, placeholder{start: i, end: j, index: n})
+		return j
+	})
+	return out
+}
+
+// hasKeyword reports whether sql contains the keyword word, in any
+// case, as a whole word outside literals and comments.
+func hasKeyword(sql, word string) bool {
+	found := false
+	walkSQL(sql, func(i int) int {
+		if found || !isIdentStart(sql[i]) {
+			return i + 1
+		}
+		j := i + 1
+		for j < len(sql) && (isIdentChar(sql[j]) || sql[j] == '$') {
+			j++
+		}
+		found = strings.EqualFold(sql[i:j], word)
+		return j
+	})
+	return found
+}
+
+// scanNamedPlaceholders returns every :name and @name placeholder in
+// sql. PostgreSQL-style casts ("::text"), ":=" and operators such as
+// "@>" and "@@" are not placeholders.
+func scanNamedPlaceholders(sql string) []placeholder {
+	var out []placeholder
+	walkSQL(sql, func(i int) int {
+		if sql[i] != ':' && sql[i] != '@' {
+			return i + 1
+		}
+		if i+1 < len(sql) && sql[i+1] == sql[i] {
+			return i + 2
+		}
+		if i+1 >= len(sql) || !isIdentStart(sql[i+1]) {
+			return i + 1
+		}
+		j := i + 1
+		for j < len(sql) && isIdentChar(sql[j]) {
+			j++
+		}
+		out = append(out, placeholder{start: i, end: j, name: sql[i+1 : j]})
+		return j
+	})
+	return out
+}
+
+// skipQuoted returns the index just past the quoted run starting at i.
+// A doubled quote character is treated as an escaped quote.
+func skipQuoted(sql string, i int, q byte) int {
+	i++
+	for i < len(sql) {
+		if sql[i] == q {
+			if i+1 < len(sql) && sql[i+1] == q {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return i
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
+
+func isIdentChar(c byte) bool { return isIdentStart(c) || isDigit(c) }
+
+// redactSQL replaces string and numeric literals in sql with ?, so
+// statement text can be logged without the values embedded in it.
+// Placeholders, identifiers, quoted identifiers and comments are kept.
+func redactSQL(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			i = skipQuoted(sql, i, c)
+			b.WriteByte('?')
+		case c == '"':
+			j := skipQuoted(sql, i, c)
+			b.WriteString(sql[i:j])
+			i = j
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			j := strings.IndexByte(sql[i:], '\n')
+			if j < 0 {
+				j = len(sql) - i
+			}
+			b.WriteString(sql[i : i+j])
+			i += j
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			j := strings.Index(sql[i+2:], "*/")
+			if j < 0 {
+				j = len(sql) - i - 2
+			} else {
+				j += 2
+			}
+			b.WriteString(sql[i : i+2+j])
+			i += 2 + j
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			j := i + 1
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+			b.WriteString(sql[i:j])
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			b.WriteString(sql[i:j])
+			i = j
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			j := i + 1
+			for j < len(sql) && (isIdentChar(sql[j]) || sql[j] == '.' ||
+				((sql[j] == '+' || sql[j] == '-') && (sql[j-1]|0x20 == 'e'))) {
+				j++
+			}
+			b.WriteByte('?')
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
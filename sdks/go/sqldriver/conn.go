@@ -0,0 +1,266 @@
+package sqldriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+var (
+	_ driver.Conn               = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+	_ driver.SessionResetter    = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+)
+
+type conn struct {
+	client *kimberlite.Client
+	closed bool
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext returns a statement bound to query. The server has no
+// prepared statement protocol, so preparation is client-side only.
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if c.closed {
+		return nil, driver.ErrBadConn
+	}
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	c.closed = true
+	return c.client.Close()
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return nil, ErrTxUnsupported
+}
+
+// CheckNamedValue converts arguments with kimberlite.ValueOf, so
+// kimberlite.Value, Interval, Money, arrays and every Go scalar kind
+// can be passed to Exec and Query.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := kimberlite.ValueOf(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = v
+	return nil
+}
+
+func (c *conn) ResetSession(context.Context) error {
+	if c.closed {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool { return !c.closed }
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, vals, err := bindArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.client.QueryContext(ctx, query, vals...)
+	if err != nil {
+		return nil, c.checkErr(err)
+	}
+	return newRows(r), nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, vals, err := bindArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.client.ExecContext(ctx, query, vals...)
+	if err != nil {
+		return nil, c.checkErr(err)
+	}
+	return result{r}, nil
+}
+
+// checkErr reports a connection the client considers closed as
+// driver.ErrBadConn, so database/sql discards it and retries.
+func (c *conn) checkErr(err error) error {
+	if errors.Is(err, kimberlite.ErrNotConnected) {
+		c.closed = true
+		return driver.ErrBadConn
+	}
+	return err
+}
+
+// bindArgs converts driver arguments to kimberlite values. Named
+// arguments (sql.Named) bind :name and @name placeholders; they cannot
+// be mixed with positional ones. Positional arguments bind $N
+// placeholders, or ? placeholders as generated by sqlx and other tools
+// that assume question-mark bind variables.
+func bindArgs(query string, args []driver.NamedValue) (string, []kimberlite.Value, error) {
+	named := make(map[string]any)
+	vals := make([]kimberlite.Value, len(args))
+	for i, a := range args {
+		v, err := kimberlite.ValueOf(a.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("kimberlite: argument %d: %w", a.Ordinal, err)
+		}
+		if a.Name != "" {
+			named[a.Name] = v
+		}
+		vals[i] = v
+	}
+	switch {
+	case len(named) == 0:
+		return kimberlite.Rebind(query), vals, nil
+	case len(named) != len(args):
+		return "", nil, errors.New("kimberlite: cannot mix named and positional arguments")
+	}
+	return kimberlite.BindNamed(query, named)
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+var (
+	_ driver.StmtQueryContext = (*stmt)(nil)
+	_ driver.StmtExecContext  = (*stmt)(nil)
+)
+
+func (s *stmt) Close() error { return nil }
+
+// NumInput returns -1: placeholders are checked by the server.
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	out := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		out[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return out
+}
+
+type result struct {
+	r *kimberlite.ExecResult
+}
+
+// LastInsertId is not supported; use RETURNING or the log offset.
+func (result) LastInsertId() (int64, error) {
+	return 0, errors.New("kimberlite: LastInsertId is not supported")
+}
+
+func (r result) RowsAffected() (int64, error) { return r.r.RowsAffected, nil }
+
+// rows iterates over a fully materialised kimberlite.QueryResult.
+type rows struct {
+	r     *kimberlite.QueryResult
+	types []kimberlite.ColumnType
+	next  int
+}
+
+var (
+	_ driver.RowsColumnTypeDatabaseTypeName = (*rows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*rows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*rows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*rows)(nil)
+)
+
+func newRows(r *kimberlite.QueryResult) *rows {
+	return &rows{r: r, types: r.ColumnTypes()}
+}
+
+func (r *rows) Columns() []string { return r.r.Columns }
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.r.Rows) {
+		return io.EOF
+	}
+	row := r.r.Row(r.next)
+	r.next++
+	for i := range dest {
+		v, err := row.Value(i).Value()
+		if err != nil {
+			return fmt.Errorf("kimberlite: column %q: %w", r.r.Columns[i], err)
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(i int) string {
+	return r.types[i].DatabaseTypeName
+}
+
+func (r *rows) ColumnTypeNullable(i int) (nullable, ok bool) {
+	return r.types[i].Nullable, r.types[i].NullableKnown
+}
+
+func (r *rows) ColumnTypePrecisionScale(i int) (precision, scale int64, ok bool) {
+	t := r.types[i]
+	return t.Precision, t.Scale, t.HasPrecisionScale
+}
+
+var (
+	scanInt64   = reflect.TypeOf(int64(0))
+	scanFloat64 = reflect.TypeOf(float64(0))
+	scanString  = reflect.TypeOf("")
+	scanBool    = reflect.TypeOf(false)
+	scanBytes   = reflect.TypeOf([]byte(nil))
+	scanTime    = reflect.TypeOf(time.Time{})
+	scanAny     = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+func (r *rows) ColumnTypeScanType(i int) reflect.Type {
+	switch r.types[i].DatabaseTypeName {
+	case "BIGINT", "INTEGER", "INT", "SMALLINT", "TINYINT":
+		return scanInt64
+	case "REAL", "DOUBLE", "FLOAT":
+		return scanFloat64
+	case "TEXT", "VARCHAR", "CHAR", "UUID", "JSON", "DECIMAL", "NUMERIC":
+		return scanString
+	case "BOOLEAN", "BOOL":
+		return scanBool
+	case "BYTES", "BYTEA", "BLOB":
+		return scanBytes
+	case "TIMESTAMP", "TIMESTAMPTZ":
+		return scanTime
+	}
+	return scanAny
+}
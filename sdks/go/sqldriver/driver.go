@@ -0,0 +1,173 @@
+// Package sqldriver registers Kimberlite with database/sql under the
+// driver name "kimberlite", so sql.DB-based code and ORMs work
+// unchanged:
+//
+//	import _ "github.com/kimberlitedb/kimberlite-go/sqldriver"
+//
+//	db, err := sql.Open("kimberlite", "kimberlite://localhost:5432?tenant=1&token=secret")
+//
+// Each pooled connection is a kimberlite.Client; database/sql handles
+// pooling, so size the pool with db.SetMaxOpenConns. Positional ($1)
+// and named (:name or @name, via sql.Named) parameters are both
+// supported.
+//
+// Kimberlite statements are individually durable; there are no
+// multi-statement transactions, so BeginTx returns ErrTxUnsupported.
+//
+// Cancelling a query's context returns from it at once, though the
+// server still completes the statement (see Client.QueryContext).
+// Rows are read in full before QueryContext returns, so Rows.Close has
+// nothing left to cancel.
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/internal/embedded"
+)
+
+// DriverName is the name the driver is registered under.
+const DriverName = "kimberlite"
+
+// embeddedScheme is the DSN scheme of kimberlitetest servers.
+var embeddedScheme = strings.TrimSuffix(embedded.Scheme, "://")
+
+// ErrTxUnsupported is returned by BeginTx.
+var ErrTxUnsupported = errors.New("kimberlite: transactions are not supported")
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+var (
+	_ driver.Driver        = (*Driver)(nil)
+	_ driver.DriverContext = (*Driver)(nil)
+	_ driver.Connector     = (*Connector)(nil)
+)
+
+// Driver is the database/sql driver.
+type Driver struct{}
+
+// Open implements driver.Driver.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector implements driver.DriverContext.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	addr, opts, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &Connector{Addr: addr, Options: opts}, nil
+}
+
+// Connector opens connections to one server with fixed client options.
+// Use it with sql.OpenDB to configure options a DSN cannot express:
+//
+//	db := sql.OpenDB(&sqldriver.Connector{
+//	    Addr:    "localhost:5432",
+//	    Options: []kimberlite.Option{kimberlite.WithTenant(1), kimberlite.WithReadCache(cache)},
+//	})
+type Connector struct {
+	Addr    string
+	Options []kimberlite.Option
+}
+
+// Connect implements driver.Connector.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	client, err := kimberlite.Connect(c.Addr, c.Options...)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{client: client}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *Connector) Driver() driver.Driver { return &Driver{} }
+
+// ParseDSN parses a data source name of the form
+//
+//	kimberlite://host:port?tenant=1&token=secret&timeout=10s&loc=Local
+//
+// The scheme is optional. A DSN with the kimberlitetest:// scheme names
+// a kimberlitetest.Server in the same process. Recognised parameters are tenant (required),
+// token, timeout (a Go duration), readonly (a boolean; true connects
+// with kimberlite.WithReadOnly) and loc (an IANA time zone name, or
+// Local, used for timestamps; default UTC).
+func ParseDSN(dsn string) (string, []kimberlite.Option, error) {
+	if !strings.Contains(dsn, "://") {
+		dsn = DriverName + "://" + dsn
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", nil, fmt.Errorf("kimberlite: invalid DSN: %w", err)
+	}
+	if u.Scheme != DriverName && u.Scheme != embeddedScheme {
+		return "", nil, fmt.Errorf("kimberlite: invalid DSN scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", nil, errors.New("kimberlite: DSN has no address")
+	}
+
+	var opts []kimberlite.Option
+	for key, vals := range u.Query() {
+		val := vals[len(vals)-1]
+		switch key {
+		case "tenant":
+			id, err := strconv.ParseUint(val, 10, 64)
+			if err != nil {
+				return "", nil, fmt.Errorf("kimberlite: invalid DSN tenant %q", val)
+			}
+			opts = append(opts, kimberlite.WithTenant(id))
+		case "token":
+			opts = append(opts, kimberlite.WithToken(val))
+		case "timeout":
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return "", nil, fmt.Errorf("kimberlite: invalid DSN timeout %q", val)
+			}
+			opts = append(opts, kimberlite.WithTimeout(d))
+		case "readonly":
+			ro, err := strconv.ParseBool(val)
+			if err != nil {
+				return "", nil, fmt.Errorf("kimberlite: invalid DSN readonly %q", val)
+			}
+			if ro {
+				opts = append(opts, kimberlite.WithReadOnly())
+			}
+		case "loc":
+			loc, err := time.LoadLocation(val)
+			if err != nil {
+				return "", nil, fmt.Errorf("kimberlite: invalid DSN loc %q: %w", val, err)
+			}
+			opts = append(opts, kimberlite.WithTimestampLocation(loc))
+		default:
+			return "", nil, fmt.Errorf("kimberlite: unknown DSN parameter %q", key)
+		}
+	}
+	if u.User != nil && u.User.Username() != "" {
+		// kimberlite://<token>@host is accepted as a shorthand.
+		opts = append(opts, kimberlite.WithToken(u.User.Username()))
+	}
+	if u.Scheme == embeddedScheme {
+		return u.Scheme + "://" + u.Host, opts, nil
+	}
+	return u.Host, opts, nil
+}
@@ -0,0 +1,103 @@
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+func TestParseDSN(t *testing.T) {
+	addr, opts, err := ParseDSN("kimberlite://localhost:5432?tenant=7&timeout=5s&loc=UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "localhost:5432" || len(opts) != 3 {
+		t.Fatalf("addr = %q, %d options", addr, len(opts))
+	}
+	if _, opts, err := ParseDSN("localhost?tenant=1&readonly=true"); err != nil || len(opts) != 2 {
+		t.Fatalf("readonly DSN: %d options, %v", len(opts), err)
+	}
+	if addr, _, err := ParseDSN("db.internal:5432?tenant=1"); err != nil || addr != "db.internal:5432" {
+		t.Fatalf("schemeless DSN = %q, %v", addr, err)
+	}
+	if addr, _, err := ParseDSN("kimberlitetest://3?tenant=1"); err != nil || addr != "kimberlitetest://3" {
+		t.Fatalf("kimberlitetest DSN = %q, %v", addr, err)
+	}
+	for _, bad := range []string{
+		"postgres://localhost?tenant=1",
+		"kimberlite://localhost?tenant=x",
+		"kimberlite://localhost?tenant=1&sslmode=disable",
+		"kimberlite://?tenant=1",
+		"kimberlite://localhost?tenant=1&readonly=maybe",
+	} {
+		if _, _, err := ParseDSN(bad); err == nil {
+			t.Errorf("%q should be rejected", bad)
+		}
+	}
+}
+
+func TestBindArgs(t *testing.T) {
+	q, vals, err := bindArgs("SELECT * FROM t WHERE a = :a AND b = :b", []driver.NamedValue{
+		{Name: "b", Ordinal: 1, Value: "x"},
+		{Name: "a", Ordinal: 2, Value: int64(1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "SELECT * FROM t WHERE a = $1 AND b = $2" || vals[0].AsInt() != 1 || vals[1].AsText() != "x" {
+		t.Fatalf("bind = %q, %v", q, vals)
+	}
+	if _, _, err := bindArgs("SELECT :a, $2", []driver.NamedValue{{Name: "a", Ordinal: 1, Value: 1}, {Ordinal: 2, Value: 2}}); err == nil {
+		t.Fatal("mixing named and positional arguments should fail")
+	}
+}
+
+func TestRows(t *testing.T) {
+	r := &kimberlite.QueryResult{
+		Columns: []string{"id", "name"},
+		Rows: []map[string]kimberlite.Value{
+			{"id": kimberlite.NewInt(1), "name": kimberlite.NewText("a")},
+			{"id": kimberlite.NewInt(2), "name": kimberlite.NewNull()},
+		},
+	}
+	rs := newRows(r)
+	dest := make([]driver.Value, 2)
+	if err := rs.Next(dest); err != nil || dest[0] != int64(1) || dest[1] != "a" {
+		t.Fatalf("row 1 = %v, %v", dest, err)
+	}
+	if err := rs.Next(dest); err != nil || dest[0] != int64(2) || dest[1] != nil {
+		t.Fatalf("row 2 = %v, %v", dest, err)
+	}
+	if err := rs.Next(dest); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if got := rs.ColumnTypeDatabaseTypeName(0); got != "BIGINT" {
+		t.Fatalf("column type = %q", got)
+	}
+}
+
+func TestBindArgsQuestionMarks(t *testing.T) {
+	q, vals, err := bindArgs("SELECT * FROM t WHERE a = ? AND b = ?", []driver.NamedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Ordinal: 2, Value: "x"},
+	})
+	if err != nil || q != "SELECT * FROM t WHERE a = $1 AND b = $2" || len(vals) != 2 {
+		t.Fatalf("bind = %q, %v, %v", q, vals, err)
+	}
+}
+
+func TestArrayScanner(t *testing.T) {
+	var tags []string
+	if err := Array(&tags).Scan(`["icu","er"]`); err != nil || len(tags) != 2 || tags[1] != "er" {
+		t.Fatalf("scan = %v, %v", tags, err)
+	}
+	if err := Array(&tags).Scan(nil); err != nil || tags != nil {
+		t.Fatalf("scan NULL = %v, %v", tags, err)
+	}
+	ids := []int64{1, 2}
+	if v, err := Array(&ids).Value(); err != nil || v != "[1,2]" {
+		t.Fatalf("value = %v, %v", v, err)
+	}
+}
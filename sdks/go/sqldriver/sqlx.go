@@ -0,0 +1,103 @@
+package sqldriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/kimberlitedb/kimberlite-go"
+)
+
+// The driver works with github.com/jmoiron/sqlx without configuration:
+//
+//	db := sqlx.MustOpen("kimberlite", "localhost:5432?tenant=1")
+//	var patients []Patient
+//	err := db.Select(&patients, "SELECT * FROM patients WHERE ward = ?", "icu")
+//	_, err = db.NamedExec("INSERT INTO patients (id, ward) VALUES (:id, :ward)", p)
+//
+// sqlx does not know the driver name, so it generates ? bind variables
+// (for Rebind, In and NamedExec); the driver rewrites them to $N.
+// Registering the native style avoids the rewrite:
+//
+//	sqlx.BindDriver(sqldriver.DriverName, sqlx.DOLLAR)
+//
+// StructScan maps columns to fields by `db` tag or lowercased field
+// name, the same mapping kimberlite.BindNamed uses. Array columns scan
+// through Array; any column can scan into a kimberlite.Value.
+
+// Array returns a sql.Scanner and driver.Valuer for a *[]string,
+// *[]int64 or *[]float64, for scanning and binding array columns:
+//
+//	var tags []string
+//	err := row.Scan(sqldriver.Array(&tags))
+func Array(ptr any) interface {
+	sql.Scanner
+	driver.Valuer
+} {
+	return &array{ptr: ptr}
+}
+
+type array struct {
+	ptr any
+}
+
+func (a *array) Scan(src any) error {
+	var v kimberlite.Value
+	if err := v.Scan(src); err != nil {
+		return err
+	}
+	if v.IsNull() {
+		return a.set(nil)
+	}
+	elems, ok := v.ArrayOK()
+	if !ok {
+		return fmt.Errorf("%w: cannot scan %s into %T", kimberlite.ErrTypeMismatch, v.Type, a.ptr)
+	}
+	return a.set(elems)
+}
+
+func (a *array) set(elems []kimberlite.Value) error {
+	switch p := a.ptr.(type) {
+	case *[]string:
+		*p = nil
+		for _, e := range elems {
+			*p = append(*p, e.AsText())
+		}
+	case *[]int64:
+		*p = nil
+		for _, e := range elems {
+			*p = append(*p, e.AsInt())
+		}
+	case *[]float64:
+		*p = nil
+		for _, e := range elems {
+			f, ok := e.FloatOK()
+			if !ok {
+				f = float64(e.AsInt())
+			}
+			*p = append(*p, f)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported array destination %T", kimberlite.ErrTypeMismatch, a.ptr)
+	}
+	return nil
+}
+
+func (a *array) Value() (driver.Value, error) {
+	var v kimberlite.Value
+	switch p := a.ptr.(type) {
+	case *[]string:
+		v = kimberlite.NewTexts(*p)
+	case *[]int64:
+		v = kimberlite.NewInts(*p)
+	case *[]float64:
+		elems := make([]kimberlite.Value, len(*p))
+		for i, f := range *p {
+			elems[i] = kimberlite.NewFloat(f)
+		}
+		v = kimberlite.NewArray(elems...)
+	default:
+		return nil, fmt.Errorf("%w: unsupported array source %T", kimberlite.ErrTypeMismatch, a.ptr)
+	}
+	return v.Value()
+}
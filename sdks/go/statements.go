@@ -0,0 +1,100 @@
+package kimberlite
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// WithStatementCache keeps the client's analysis of up to size
+// statements, keyed by SQL text and evicted least-recently-used, so that
+// Query and Exec calls repeating a statement skip scanning it for
+// placeholders. With or without the cache, calls binding fewer
+// arguments than the statement has parameters fail before anything is
+// sent.
+//
+// The protocol has no prepare message: the server parses and plans
+// every statement it receives, so the cache saves client-side work
+// only. What it holds depends on the statement text alone, not on the
+// schema, so DDL never invalidates it; the server sends no schema
+// change notifications in any case.
+func WithStatementCache(size int) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.statements = newStatementCache(size)
+		}
+	}
+}
+
+// statement is a client's analysis of a statement's text.
+type statement struct {
+	sql          string
+	placeholders []placeholder
+	// params is the highest $N placeholder, the number of arguments the
+	// statement needs.
+	params int
+}
+
+func parseStatement(sql string) *statement {
+	st := &statement{sql: sql, placeholders: scanPlaceholders(sql)}
+	for _, p := range st.placeholders {
+		st.params = max(st.params, p.index)
+	}
+	return st
+}
+
+// bind checks args against st and expands array arguments.
+func (st *statement) bind(args []Value) (string, []Value, error) {
+	if len(args) < st.params {
+		return "", nil, fmt.Errorf("%w: statement has %d parameters, %d arguments bound", ErrQueryFailed, st.params, len(args))
+	}
+	sql, args := expandArrayArgsAt(st.sql, st.placeholders, args)
+	return sql, args, nil
+}
+
+// statementCache is an LRU cache of statements keyed by SQL text.
+type statementCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newStatementCache(size int) *statementCache {
+	return &statementCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the statement for sql, parsing and caching it on a miss.
+func (s *statementCache) get(sql string) *statement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[sql]; ok {
+		s.order.MoveToFront(el)
+		return el.Value.(*statement)
+	}
+	st := parseStatement(sql)
+	s.entries[sql] = s.order.PushFront(st)
+	if s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*statement).sql)
+	}
+	return st
+}
+
+// Len returns the number of cached statements.
+func (s *statementCache) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// bindStatement prepares sql and args for sending, through the
+// statement cache if the client has one.
+func (c *Client) bindStatement(sql string, args []Value) (string, []Value, error) {
+	if c.statements == nil {
+		return parseStatement(sql).bind(args)
+	}
+	return c.statements.get(sql).bind(args)
+}
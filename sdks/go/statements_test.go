@@ -0,0 +1,68 @@
+package kimberlite
+
+import (
+	"errors"
+	"testing"
+)
+
+type recordingBackend struct {
+	blockingBackend
+	sql []string
+}
+
+func (b *recordingBackend) Query(sql string, args []Value) (*QueryResult, error) {
+	b.sql = append(b.sql, sql)
+	return NewQueryResult(nil, nil), nil
+}
+
+func TestStatementCache(t *testing.T) {
+	b := &recordingBackend{}
+	c, err := Connect("test", WithTenant(1), WithBackend(b), WithStatementCache(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	const byIDs = "SELECT * FROM patients WHERE id = ANY($1)"
+	for _, ids := range [][]int64{{1, 2}, {3}} {
+		if _, err := c.Query(byIDs, NewInts(ids)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.sql[0] != "SELECT * FROM patients WHERE id IN ($1, $2)" || b.sql[1] != "SELECT * FROM patients WHERE id IN ($1)" {
+		t.Fatalf("sent %q", b.sql)
+	}
+	if _, err := c.Query("SELECT * FROM patients WHERE id = $2", NewInt(1)); !errors.Is(err, ErrQueryFailed) || len(b.sql) != 2 {
+		t.Fatalf("missing argument: %v", err)
+	}
+
+	if _, err := c.Query("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.statements.Len(); n != 2 {
+		t.Fatalf("cached %d statements", n)
+	}
+	if _, ok := c.statements.entries[byIDs]; ok {
+		t.Fatal("least recently used statement not evicted")
+	}
+}
+
+func TestStatementArgumentCount(t *testing.T) {
+	b := &recordingBackend{}
+	c, err := Connect("test", WithTenant(1), WithBackend(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Without a statement cache, too few arguments fail the same way.
+	if _, err := c.Query("SELECT * FROM patients WHERE id = $2", NewInt(1)); !errors.Is(err, ErrQueryFailed) || len(b.sql) != 0 {
+		t.Fatalf("missing argument: %v, sent %q", err, b.sql)
+	}
+	if _, err := c.Query("SELECT * FROM patients WHERE id = ANY($1)", NewInts([]int64{1, 2})); err != nil {
+		t.Fatal(err)
+	}
+	if b.sql[0] != "SELECT * FROM patients WHERE id IN ($1, $2)" {
+		t.Fatalf("sent %q", b.sql)
+	}
+}
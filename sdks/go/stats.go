@@ -0,0 +1,63 @@
+package kimberlite
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// QueryStats describes how a query performed, for logging and alerting
+// on regressions.
+//
+// The protocol does not report server-side statistics: the server
+// answers a query with its columns and rows only, so parse, plan and
+// execution times, rows scanned and cache hits within the engine are
+// not available. QueryStats holds what the client measures instead.
+// Use Explain to see how the server reads a query.
+type QueryStats struct {
+	// Elapsed is the round-trip time of the query, measured by the
+	// client from sending the statement to decoding its result.
+	Elapsed time.Duration
+	// Rows is the number of rows returned.
+	Rows int64
+	// Bytes is the size of the returned values: the length of text,
+	// bytes and JSON values, and eight bytes for each other scalar.
+	// It approximates the result's size on the wire.
+	Bytes int64
+}
+
+// queryStats returns the statistics of r, which took elapsed.
+func queryStats(r *QueryResult, elapsed time.Duration) QueryStats {
+	s := QueryStats{Elapsed: elapsed, Rows: int64(len(r.Rows))}
+	for _, row := range r.values {
+		for _, v := range row {
+			s.Bytes += valueSize(v)
+		}
+	}
+	return s
+}
+
+func valueSize(v Value) int64 {
+	switch raw := v.raw.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(raw))
+	case []byte:
+		return int64(len(raw))
+	case json.RawMessage:
+		return int64(len(raw))
+	case []Value:
+		var n int64
+		for _, e := range raw {
+			n += valueSize(e)
+		}
+		return n
+	case map[string]Value:
+		var n int64
+		for k, e := range raw {
+			n += int64(len(k)) + valueSize(e)
+		}
+		return n
+	}
+	return 8
+}
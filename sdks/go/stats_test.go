@@ -0,0 +1,27 @@
+package kimberlite
+
+import "testing"
+
+type rowsBackend struct{ blockingBackend }
+
+func (*rowsBackend) Query(string, []Value) (*QueryResult, error) {
+	return NewQueryResult([]string{"id", "name", "tags"}, []Row{
+		{NewInt(1), NewText("alice"), NewArray(NewText("a"), NewText("bc"))},
+		{NewInt(2), NewNull(), NewArray()},
+	}), nil
+}
+
+func TestQueryStats(t *testing.T) {
+	c, err := Connect("test", WithTenant(1), WithBackend(&rowsBackend{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r, err := c.Query("SELECT id, name, tags FROM patients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Stats.Rows != 2 || r.Stats.Bytes != 8+5+3+8 {
+		t.Fatalf("stats: %+v", r.Stats)
+	}
+}
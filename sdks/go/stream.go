@@ -0,0 +1,161 @@
+package kimberlite
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Stream is a typed view of a stream whose events are values of T, so
+// application code never handles raw payload bytes:
+//
+//	admissions := kimberlite.NewStream[PatientAdmitted](client, streamID)
+//	_, err := admissions.AppendT(ctx, PatientAdmitted{Patient: "p-1"})
+//	events, err := admissions.ReadT(ctx, 0, 1<<20)
+//
+// Events are encoded with the codec and event type registered for T in
+// the client's CodecRegistry. An unregistered T is written with the
+// registry's default codec under its Go type name.
+//
+// T may also be an interface type implemented by several registered
+// event types; each event then decodes to its registered type, which
+// must implement T.
+type Stream[T any] struct {
+	client    *Client
+	id        StreamID
+	eventType string
+	codec     Codec
+	union     bool
+}
+
+// TypedEvent is an event read through a Stream.
+type TypedEvent[T any] struct {
+	Event
+	Envelope Envelope
+	Value    T
+}
+
+// StreamOption configures a Stream.
+type StreamOption func(*streamConfig)
+
+type streamConfig struct {
+	eventType string
+	codec     Codec
+}
+
+// WithEventType overrides the event type recorded for appended values.
+func WithEventType(name string) StreamOption {
+	return func(c *streamConfig) { c.eventType = name }
+}
+
+// WithStreamCodec overrides the codec used to encode appended values.
+func WithStreamCodec(codec Codec) StreamOption {
+	return func(c *streamConfig) { c.codec = codec }
+}
+
+// NewStream returns a typed view of stream id.
+func NewStream[T any](c *Client, id StreamID, opts ...StreamOption) *Stream[T] {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	s := &Stream[T]{client: c, id: id, union: t.Kind() == reflect.Interface}
+
+	var cfg streamConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if !s.union {
+		eventType, codec, ok := c.codecRegistry().lookup(t)
+		if !ok {
+			eventType = t.Name()
+		}
+		s.eventType, s.codec = eventType, codec
+	}
+	if cfg.eventType != "" {
+		s.eventType = cfg.eventType
+	}
+	if cfg.codec != nil {
+		s.codec = cfg.codec
+	}
+	return s
+}
+
+// ID returns the underlying stream ID.
+func (s *Stream[T]) ID() StreamID { return s.id }
+
+// AppendT encodes and appends values, returning the offset of the
+// first one. Schema validation applies as for AppendValues.
+func (s *Stream[T]) AppendT(ctx context.Context, values ...T) (Offset, error) {
+	reg := s.client.codecRegistry()
+	envs := make([]Envelope, len(values))
+	for i, v := range values {
+		var (
+			eventType, codec = s.eventType, s.codec
+			data             []byte
+			err              error
+		)
+		if s.union {
+			eventType, codec, data, err = reg.encode(v)
+		} else if data, err = codec.Marshal(v); err != nil {
+			err = fmt.Errorf("kimberlite: encode %s: %w", eventType, err)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if envs[i], err = reg.newEnvelope(eventType, codec, v, data); err != nil {
+			return 0, err
+		}
+	}
+	return s.client.appendEnvelopes(ctx, s.id, envs, false)
+}
+
+// ReadT reads and decodes events like ReadEnvelopes. For a concrete T,
+// every event is decoded into T using the codec its content type names;
+// for an interface T, events whose type is unregistered or does not
+// implement T fail with ErrTypeMismatch.
+func (s *Stream[T]) ReadT(ctx context.Context, from Offset, maxBytes uint64) ([]TypedEvent[T], error) {
+	events, envs, err := s.client.ReadEnvelopes(ctx, s.id, from, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TypedEvent[T], len(events))
+	for i, e := range events {
+		v, err := s.decode(envs[i])
+		if err != nil {
+			return nil, fmt.Errorf("kimberlite: event at offset %d: %w", e.Offset, err)
+		}
+		out[i] = TypedEvent[T]{Event: e, Envelope: envs[i], Value: v}
+	}
+	return out, nil
+}
+
+func (s *Stream[T]) decode(env Envelope) (T, error) {
+	var zero T
+	reg := s.client.codecRegistry()
+	if s.union {
+		v, err := reg.decode(env)
+		if err != nil {
+			return zero, err
+		}
+		if v == nil {
+			return zero, fmt.Errorf("%w: unregistered event type %q", ErrTypeMismatch, env.Type)
+		}
+		// Registered types decode to pointers; accept either the
+		// pointer or its element as the implementation of T.
+		if t, ok := v.(T); ok {
+			return t, nil
+		}
+		if t, ok := reflect.ValueOf(v).Elem().Interface().(T); ok {
+			return t, nil
+		}
+		return zero, fmt.Errorf("%w: event type %q does not implement %v", ErrTypeMismatch, env.Type, reflect.TypeOf((*T)(nil)).Elem())
+	}
+
+	codec, err := reg.codecFor(env.Metadata[metaContentType], s.codec)
+	if err != nil {
+		return zero, err
+	}
+	var v T
+	if err := codec.Unmarshal(env.Data, &v); err != nil {
+		return zero, fmt.Errorf("kimberlite: decode %s: %w", env.Type, err)
+	}
+	return v, nil
+}
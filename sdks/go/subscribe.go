@@ -0,0 +1,282 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// ErrSubscriptionClosed is returned by StreamSubscription.Next once the
+// subscription has been closed, by Close or by the server.
+var ErrSubscriptionClosed = errors.New("kimberlite: subscription closed")
+
+// subscriptionCredits is how many events the server may push to a
+// subscription ahead of Next; the client grants more as they are read.
+const subscriptionCredits = 256
+
+// closeReasons names the reasons the server closes subscriptions for,
+// in the order of the FFI's KmbSubscriptionCloseReason.
+var closeReasons = []string{"cancelled by the client", "server shutdown", "stream deleted", "backpressure timeout", "protocol error"}
+
+// StreamSubscription receives the events of a stream as the server
+// pushes them. It is not safe for concurrent use, except for Close.
+type StreamSubscription struct {
+	c      *Client
+	stream StreamID
+	from   Offset
+
+	// events delivers pushed events, and the error that ends them. It
+	// is nil for clients served by a Backend, which follow the stream
+	// with FollowEvents into pending instead.
+	events  chan pushed
+	pending []Event
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	err      error
+}
+
+type pushed struct {
+	ev  Event
+	err error
+}
+
+// Subscribe returns a subscription to the events of stream streamID from
+// offset from on. The server pushes events as they are appended, so
+// each arrives as soon as it is durable, without polling:
+//
+//	sub, err := client.Subscribe(ctx, stream, from)
+//	...
+//	defer sub.Close()
+//	for {
+//	    ev, err := sub.Next(ctx)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    handle(ev)
+//	}
+//
+// A subscription has a connection of its own, authenticated with the
+// client's current credential, so waiting for events does not hold up
+// the client's other calls. Its events are decrypted like those of
+// ReadEventsContext, and their Timestamp is when the client received
+// them. A client served by a Backend, which cannot push, follows the
+// stream with FollowEvents instead.
+func (c *Client) Subscribe(ctx context.Context, streamID StreamID, from Offset) (*StreamSubscription, error) {
+	if err := c.checkPurpose(ctx, streamID); err != nil {
+		return nil, err
+	}
+	if err := c.checkResidency(streamID); err != nil {
+		return nil, err
+	}
+	s := &StreamSubscription{c: c, stream: streamID, from: from, stop: make(chan struct{})}
+	op := &Operation{Name: OpSubscribe, StreamID: streamID}
+	if c.backend != nil {
+		if err := c.instrument(ctx, op, nil, func(context.Context) error { return nil }); err != nil {
+			return nil, fmt.Errorf("kimberlite: subscribe to stream %d: %w", streamID, err)
+		}
+		return s, nil
+	}
+	feed, err := authenticated(ctx, c, func() (feed *ffiFeed, err error) {
+		err = c.instrument(ctx, op, nil, func(context.Context) (err error) {
+			feed, err = c.subscribe(streamID, from)
+			return err
+		})
+		return feed, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kimberlite: subscribe to stream %d: %w", streamID, err)
+	}
+	s.from = feed.start
+	s.events = make(chan pushed)
+	go s.run(feed)
+	return s, nil
+}
+
+// subscribe opens a connection and subscribes to the stream on it.
+func (c *Client) subscribe(streamID StreamID, from Offset) (*ffiFeed, error) {
+	c.mu.RLock()
+	closed, token := c.closed, c.token
+	c.mu.RUnlock()
+	if closed {
+		return nil, ErrNotConnected
+	}
+	handle, err := c.dialFFI(token)
+	if err != nil {
+		return nil, err
+	}
+	id, start, err := ffiSubscribe(handle, uint64(streamID), uint64(from), subscriptionCredits)
+	if err != nil {
+		ffiDisconnect(handle)
+		return nil, err
+	}
+	return &ffiFeed{handle: handle, id: id, start: start}, nil
+}
+
+// pushFeed is the connection of a server-side subscription.
+type pushFeed interface {
+	// next waits for the next event, returning an error wrapping
+	// ErrTimeout if none arrives within the connection's read timeout.
+	next() (offset Offset, data []byte, closed bool, reason int, err error)
+	grant(credits uint32) error
+	// cancel unsubscribes and closes the connection.
+	cancel()
+}
+
+type ffiFeed struct {
+	handle unsafe.Pointer
+	id     uint64
+	start  Offset
+}
+
+func (f *ffiFeed) next() (Offset, []byte, bool, int, error) {
+	return ffiSubscriptionNext(f.handle, f.id)
+}
+
+func (f *ffiFeed) grant(credits uint32) error {
+	return ffiSubscriptionGrantCredits(f.handle, f.id, credits)
+}
+
+func (f *ffiFeed) cancel() {
+	_ = ffiSubscriptionUnsubscribe(f.handle, f.id)
+	_ = ffiDisconnect(f.handle)
+}
+
+// run reads the feed into s.events until it ends or s is closed. It
+// alone uses the feed's connection, which it closes on return. A
+// pending wait for events ends at the latest at the connection's read
+// timeout, so a closed subscription's connection closes by then, and a
+// break-glass session's subscription ends with ErrBreakGlassExpired by
+// then once the session expired.
+func (s *StreamSubscription) run(feed pushFeed) {
+	defer close(s.events)
+	defer feed.cancel()
+	credits := uint32(subscriptionCredits)
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if s.c.breakGlass != nil && s.c.breakGlass.expired() {
+			s.deliver(pushed{err: ErrBreakGlassExpired})
+			return
+		}
+		if credits <= subscriptionCredits/4 {
+			if err := feed.grant(subscriptionCredits - credits); err != nil {
+				s.deliver(pushed{err: err})
+				return
+			}
+			credits = subscriptionCredits
+		}
+		offset, data, closed, reason, err := feed.next()
+		switch {
+		case errors.Is(err, ErrTimeout):
+			continue
+		case err != nil:
+			s.deliver(pushed{err: err})
+			return
+		case closed:
+			why := "closed by the server"
+			if reason >= 0 && reason < len(closeReasons) {
+				why = closeReasons[reason]
+			}
+			s.deliver(pushed{err: fmt.Errorf("%w: %s", ErrSubscriptionClosed, why)})
+			return
+		}
+		credits--
+		ev := Event{Offset: offset, StreamID: s.stream, Data: data, Timestamp: time.Now()}
+		if !s.deliver(pushed{ev: ev}) {
+			return
+		}
+	}
+}
+
+// deliver hands p to Next, reporting false if s is closed first.
+func (s *StreamSubscription) deliver(p pushed) bool {
+	select {
+	case s.events <- p:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+// Next returns the next event, waiting for one to be appended if there
+// is none, until ctx is done. It returns an error wrapping
+// ErrSubscriptionClosed once the subscription is closed.
+func (s *StreamSubscription) Next(ctx context.Context) (Event, error) {
+	if s.err != nil {
+		return Event{}, s.err
+	}
+	select {
+	case <-s.stop:
+		s.err = ErrSubscriptionClosed
+		return Event{}, s.err
+	default:
+	}
+	if s.events == nil {
+		return s.follow(ctx)
+	}
+	select {
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	case <-s.stop:
+		s.err = ErrSubscriptionClosed
+		return Event{}, s.err
+	case p, ok := <-s.events:
+		if !ok {
+			s.err = ErrSubscriptionClosed
+			return Event{}, s.err
+		}
+		if p.err != nil {
+			s.err = fmt.Errorf("kimberlite: subscription to stream %d: %w", s.stream, p.err)
+			return Event{}, s.err
+		}
+		ev := p.ev
+		if s.c.encryption != nil {
+			events, err := s.c.encryption.decryptEvents(ctx, s.c.tenant, []Event{ev})
+			if err != nil {
+				return Event{}, err
+			}
+			ev = events[0]
+		}
+		s.from = ev.Offset + 1
+		return ev, nil
+	}
+}
+
+// followWait is how long each read of a followed subscription waits for
+// new events.
+const followWait = 30 * time.Second
+
+// follow is Next for clients served by a Backend.
+func (s *StreamSubscription) follow(ctx context.Context) (Event, error) {
+	for len(s.pending) == 0 {
+		events, err := s.c.FollowEvents(ctx, s.stream, s.from, 1<<20, followWait)
+		if err != nil {
+			return Event{}, err
+		}
+		s.pending = events
+	}
+	ev := s.pending[0]
+	s.pending = s.pending[1:]
+	s.from = ev.Offset + 1
+	return ev, nil
+}
+
+// Offset returns the offset of the event Next returns next: subscribing
+// from it resumes where this subscription left off.
+func (s *StreamSubscription) Offset() Offset {
+	return s.from
+}
+
+// Close ends the subscription. It returns at once; the subscription's
+// connection closes when its pending wait for events ends.
+func (s *StreamSubscription) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return nil
+}
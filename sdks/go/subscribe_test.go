@@ -0,0 +1,95 @@
+package kimberlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeFeed replays a script of results of next.
+type fakeFeed struct {
+	script    []feedResult
+	granted   []uint32
+	cancelled chan struct{}
+}
+
+type feedResult struct {
+	offset Offset
+	data   string
+	closed bool
+	reason int
+	err    error
+}
+
+func (f *fakeFeed) next() (Offset, []byte, bool, int, error) {
+	if len(f.script) == 0 {
+		return 0, nil, true, 1, nil
+	}
+	r := f.script[0]
+	f.script = f.script[1:]
+	return r.offset, []byte(r.data), r.closed, r.reason, r.err
+}
+
+func (f *fakeFeed) grant(credits uint32) error {
+	f.granted = append(f.granted, credits)
+	return nil
+}
+
+func (f *fakeFeed) cancel() { close(f.cancelled) }
+
+func TestStreamSubscription(t *testing.T) {
+	ctx := context.Background()
+	feed := &fakeFeed{cancelled: make(chan struct{})}
+	for i := 0; i < subscriptionCredits; i++ {
+		feed.script = append(feed.script, feedResult{offset: Offset(3 + i), data: fmt.Sprint(i)})
+		if i == 0 {
+			// Read timeouts between events are not errors.
+			feed.script = append(feed.script, feedResult{err: fmt.Errorf("%w: read", ErrTimeout)})
+		}
+	}
+	feed.script = append(feed.script, feedResult{closed: true, reason: 2})
+
+	s := &StreamSubscription{c: &Client{}, stream: 1, events: make(chan pushed), stop: make(chan struct{})}
+	go s.run(feed)
+	for i := 0; i < subscriptionCredits; i++ {
+		ev, err := s.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Offset != Offset(3+i) || string(ev.Data) != fmt.Sprint(i) || ev.StreamID != 1 {
+			t.Fatalf("event %d: %+v", i, ev)
+		}
+	}
+	if s.Offset() != 3+subscriptionCredits {
+		t.Fatalf("offset %d", s.Offset())
+	}
+	if _, err := s.Next(ctx); !errors.Is(err, ErrSubscriptionClosed) {
+		t.Fatalf("expected ErrSubscriptionClosed, got %v", err)
+	}
+	<-feed.cancelled
+	if len(feed.granted) == 0 {
+		t.Fatal("no credits granted")
+	}
+
+	// Close ends a subscription waiting on its consumer.
+	feed = &fakeFeed{cancelled: make(chan struct{}), script: []feedResult{{offset: 0, data: "x"}}}
+	s = &StreamSubscription{c: &Client{}, stream: 1, events: make(chan pushed), stop: make(chan struct{})}
+	go s.run(feed)
+	s.Close()
+	<-feed.cancelled
+	if _, err := s.Next(ctx); !errors.Is(err, ErrSubscriptionClosed) {
+		t.Fatalf("after Close: %v", err)
+	}
+
+	// A break-glass session's subscription ends when the session expires.
+	feed = &fakeFeed{cancelled: make(chan struct{}), script: []feedResult{{offset: 0, data: "x"}}}
+	session := &BreakGlassSession{Expires: time.Now()}
+	s = &StreamSubscription{c: &Client{breakGlass: session}, stream: 1, events: make(chan pushed), stop: make(chan struct{})}
+	go s.run(feed)
+	if _, err := s.Next(ctx); !errors.Is(err, ErrBreakGlassExpired) {
+		t.Fatalf("after expiry: %v", err)
+	}
+	<-feed.cancelled
+}
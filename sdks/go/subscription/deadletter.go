@@ -0,0 +1,201 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+// Metadata keys DeadLetter adds to the envelopes it parks. A parked
+// event is otherwise a copy of the failed one, ID included.
+const (
+	MetaDeadLetterStream       = "dead_letter.stream"
+	MetaDeadLetterOffset       = "dead_letter.offset"
+	MetaDeadLetterSubscription = "dead_letter.subscription"
+	MetaDeadLetterDeliveries   = "dead_letter.deliveries"
+	MetaDeadLetterError        = "dead_letter.error"
+	MetaDeadLetterParkedAt     = "dead_letter.parked_at"
+	// MetaDeadLetterRaw marks events that were not envelopes; their
+	// data is the envelope's payload.
+	MetaDeadLetterRaw = "dead_letter.raw"
+)
+
+const metaDeadLetterPrefix = "dead_letter."
+
+// DeadLetter returns a ParkFunc appending failed messages to stream,
+// with their origin and failure recorded in metadata.
+func DeadLetter(log connect.EventAppender, stream kimberlite.StreamID) ParkFunc {
+	return func(ctx context.Context, m *Message) error {
+		data, err := deadLetterEnvelope(m, time.Now())
+		if err != nil {
+			return err
+		}
+		_, err = log.AppendContext(ctx, stream, data)
+		return err
+	}
+}
+
+// WithDeadLetter parks messages that failed their last allowed
+// delivery in stream, for Replay once the cause is fixed.
+func WithDeadLetter(log connect.EventAppender, stream kimberlite.StreamID) Option {
+	return WithPark(DeadLetter(log, stream))
+}
+
+func deadLetterEnvelope(m *Message, now time.Time) ([]byte, error) {
+	env, err := m.Envelope()
+	raw := err != nil || !kimberlite.IsEnvelope(m.Data)
+	if raw {
+		env = kimberlite.Envelope{Data: m.Data}
+	}
+	meta := make(map[string]string, len(env.Metadata)+7)
+	for k, v := range env.Metadata {
+		meta[k] = v
+	}
+	meta[MetaDeadLetterStream] = strconv.FormatUint(uint64(m.StreamID), 10)
+	meta[MetaDeadLetterOffset] = strconv.FormatUint(uint64(m.Offset), 10)
+	meta[MetaDeadLetterSubscription] = m.sub.name
+	meta[MetaDeadLetterDeliveries] = strconv.Itoa(m.Deliveries)
+	meta[MetaDeadLetterParkedAt] = now.UTC().Format(time.RFC3339Nano)
+	if m.LastError != nil {
+		meta[MetaDeadLetterError] = m.LastError.Error()
+	}
+	if raw {
+		meta[MetaDeadLetterRaw] = "true"
+	}
+	env.Metadata = meta
+	return env.Marshal()
+}
+
+// Parked is an event parked in a dead-letter stream.
+type Parked struct {
+	// Event is the failed event, with its stream, offset and data; its
+	// Timestamp is that of the parked copy.
+	Event kimberlite.Event
+	// Offset is the position of the parked copy in the dead-letter
+	// stream.
+	Offset       kimberlite.Offset
+	Subscription string
+	Deliveries   int
+	// Error is the message of the last delivery's error, if any.
+	Error    string
+	ParkedAt time.Time
+}
+
+// ReplayOption configures Replay.
+type ReplayOption func(*replay)
+
+type replay struct {
+	store        connect.CheckpointStore
+	name         string
+	subscription string
+	maxBytes     uint64
+}
+
+// WithReplayCheckpoint records the position of Replay in store under
+// name, so a later Replay continues after the events replayed by this
+// one. Without it, Replay starts from the beginning of the dead-letter
+// stream.
+func WithReplayCheckpoint(store connect.CheckpointStore, name string) ReplayOption {
+	return func(r *replay) { r.store, r.name = store, name }
+}
+
+// WithReplaySubscription replays only the events parked by the
+// subscription named name; the others are passed over.
+func WithReplaySubscription(name string) ReplayOption {
+	return func(r *replay) { r.subscription = name }
+}
+
+// Replay reprocesses the events parked in the dead-letter stream with
+// handle, in the order they were parked, and returns the number
+// handled. It stops at the first error of handle, with the checkpoint
+// of WithReplayCheckpoint before the failed event. The dead-letter
+// stream is immutable: replayed events stay in it, and the checkpoint
+// is what keeps them from being replayed again.
+func Replay(ctx context.Context, source connect.EventSource, deadLetters kimberlite.StreamID, handle func(context.Context, Parked) error, opts ...ReplayOption) (int, error) {
+	r := replay{maxBytes: 1 << 20}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	var from kimberlite.Offset
+	if r.store != nil {
+		var err error
+		if from, _, err = r.store.Load(ctx, r.name); err != nil {
+			return 0, fmt.Errorf("kimberlite: load replay checkpoint: %w", err)
+		}
+	}
+	var total int
+	for {
+		events, err := source.ReadEventsContext(ctx, deadLetters, from, r.maxBytes)
+		if err != nil {
+			return total, err
+		}
+		if len(events) == 0 {
+			return total, nil
+		}
+		for _, ev := range events {
+			p, err := parseParked(ev)
+			if err != nil {
+				return total, err
+			}
+			if r.subscription == "" || p.Subscription == r.subscription {
+				if err := handle(ctx, p); err != nil {
+					return total, fmt.Errorf("kimberlite: replay dead letter %d: %w", ev.Offset, err)
+				}
+				total++
+			}
+			from = ev.Offset + 1
+			if r.store != nil {
+				if err := r.store.Save(ctx, r.name, from); err != nil {
+					return total, fmt.Errorf("kimberlite: save replay checkpoint: %w", err)
+				}
+			}
+		}
+	}
+}
+
+// parseParked restores the failed event of a dead-letter event.
+func parseParked(ev kimberlite.Event) (Parked, error) {
+	env, err := ev.Envelope()
+	if err != nil {
+		return Parked{}, fmt.Errorf("kimberlite: dead letter %d: %w", ev.Offset, err)
+	}
+	meta := env.Metadata
+	stream, err1 := strconv.ParseUint(meta[MetaDeadLetterStream], 10, 64)
+	offset, err2 := strconv.ParseUint(meta[MetaDeadLetterOffset], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Parked{}, fmt.Errorf("kimberlite: dead letter %d: no origin metadata", ev.Offset)
+	}
+	p := Parked{
+		Offset:       ev.Offset,
+		Subscription: meta[MetaDeadLetterSubscription],
+		Error:        meta[MetaDeadLetterError],
+	}
+	p.Deliveries, _ = strconv.Atoi(meta[MetaDeadLetterDeliveries])
+	p.ParkedAt, _ = time.Parse(time.RFC3339Nano, meta[MetaDeadLetterParkedAt])
+
+	original := ev
+	original.StreamID, original.Offset = kimberlite.StreamID(stream), kimberlite.Offset(offset)
+	if meta[MetaDeadLetterRaw] == "true" {
+		original.Data = env.Data
+	} else {
+		env.Metadata = nil
+		for k, v := range meta {
+			if !strings.HasPrefix(k, metaDeadLetterPrefix) {
+				if env.Metadata == nil {
+					env.Metadata = make(map[string]string)
+				}
+				env.Metadata[k] = v
+			}
+		}
+		if original.Data, err = env.Marshal(); err != nil {
+			return Parked{}, err
+		}
+	}
+	p.Event = original
+	return p, nil
+}
@@ -0,0 +1,79 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+func (l *fakeLog) AppendContext(_ context.Context, id kimberlite.StreamID, events ...[]byte) (kimberlite.Offset, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	first := kimberlite.Offset(len(l.events[id]))
+	for i, data := range events {
+		l.events[id] = append(l.events[id], kimberlite.Event{StreamID: id, Offset: first + kimberlite.Offset(i), Data: data})
+	}
+	return first, nil
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	ctx := context.Background()
+	const source, dlq kimberlite.StreamID = 1, 9
+	log := newLog(nil)
+	enveloped, _ := kimberlite.Envelope{ID: "ev-1", Type: "ClaimSubmitted", Metadata: map[string]string{"k": "v"}, Data: []byte("claim")}.Marshal()
+	log.AppendContext(ctx, source, enveloped, []byte("raw"), enveloped)
+
+	s := New(log, "billing", []kimberlite.StreamID{source},
+		WithMaxDeliveries(1),
+		WithDeadLetter(log, dlq),
+		WithRetryBackoff(func(int) time.Duration { return 0 }),
+		WithPollInterval(time.Millisecond))
+	errBug := errors.New("bug")
+	for i := 0; i < 2; i++ {
+		m := receive(t, s)
+		m.Nack(errBug)
+	}
+	receive(t, s).Ack()
+	if n := len(log.events[dlq]); n != 2 {
+		t.Fatalf("%d dead letters, expected 2", n)
+	}
+
+	store := connect.NewMemoryCheckpoints()
+	var replayed []Parked
+	handle := func(_ context.Context, p Parked) error {
+		replayed = append(replayed, p)
+		return nil
+	}
+	n, err := Replay(ctx, log, dlq, handle, WithReplayCheckpoint(store, "replay"), WithReplaySubscription("billing"))
+	if err != nil || n != 2 {
+		t.Fatalf("replay: %d, %v", n, err)
+	}
+	p := replayed[0]
+	env, _ := p.Event.Envelope()
+	if p.Event.StreamID != source || p.Event.Offset != 0 || p.Subscription != "billing" || p.Deliveries != 1 || p.Error != "bug" || p.ParkedAt.IsZero() {
+		t.Fatalf("parked %+v", p)
+	}
+	if env.ID != "ev-1" || env.Type != "ClaimSubmitted" || string(env.Data) != "claim" || len(env.Metadata) != 1 || env.Metadata["k"] != "v" {
+		t.Fatalf("restored envelope %+v", env)
+	}
+	if p := replayed[1]; p.Event.Offset != 1 || string(p.Event.Data) != "raw" {
+		t.Fatalf("raw event restored as %q", p.Event.Data)
+	}
+
+	// The checkpoint keeps events from being replayed twice.
+	if n, err := Replay(ctx, log, dlq, handle, WithReplayCheckpoint(store, "replay")); n != 0 || err != nil {
+		t.Fatalf("second replay: %d, %v", n, err)
+	}
+	// Other subscriptions' events are passed over, and handler errors
+	// stop the replay.
+	if n, err := Replay(ctx, log, dlq, handle, WithReplaySubscription("other")); n != 0 || err != nil {
+		t.Fatalf("replay of other subscription: %d, %v", n, err)
+	}
+	if _, err := Replay(ctx, log, dlq, func(context.Context, Parked) error { return errBug }); !errors.Is(err, errBug) {
+		t.Fatalf("failing replay: %v", err)
+	}
+}
@@ -0,0 +1,489 @@
+// Package subscription delivers the events of Kimberlite streams to
+// competing consumers with explicit acknowledgement.
+//
+// A Subscription reads streams ahead into a bounded window of
+// messages and hands each to one caller of Receive. The caller Acks it
+// once processed, or Nacks it to have it redelivered after a delay;
+// messages neither acked nor nacked within the ack timeout are
+// redelivered as well. A message that keeps failing is delivered at
+// most WithMaxDeliveries times and then parked by the subscription's
+// ParkFunc, and consumption goes on, so one poison event does not hold
+// up the others. WithDeadLetter parks them in a dead-letter stream,
+// from which Replay reprocesses them once the cause is fixed:
+//
+//	sub := subscription.New(client, "billing", []kimberlite.StreamID{claims},
+//	    subscription.WithCheckpoints(connect.NewStreamCheckpoints(client, checkpoints)),
+//	    subscription.WithMaxDeliveries(5),
+//	    subscription.WithDeadLetter(client, billingDLQ),
+//	)
+//	for i := 0; i < 8; i++ {
+//	    go func() {
+//	        for {
+//	            m, err := sub.Receive(ctx)
+//	            if err != nil {
+//	                return
+//	            }
+//	            if err := bill(ctx, m.Event); err != nil {
+//	                m.Nack(err)
+//	                continue
+//	            }
+//	            m.Ack()
+//	        }
+//	    }()
+//	}
+//
+// Kimberlite has no server-side subscription groups: the consumers of
+// a group share one Subscription, in one process. Each stream's
+// checkpoint is its lowest unacknowledged offset, so a restarted
+// subscription redelivers the messages in flight when it stopped, with
+// their delivery counts reset. Competing consumers and redeliveries
+// mean messages are not processed in offset order.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+var (
+	// ErrAckTimeout is the LastError of messages redelivered because
+	// they were not acknowledged in time.
+	ErrAckTimeout = errors.New("kimberlite: message not acknowledged in time")
+	// ErrNotInFlight is returned when acknowledging a message that was
+	// already acknowledged, or negatively acknowledging one that was
+	// redelivered or acknowledged since. A message acknowledged late,
+	// after its redelivery was scheduled, is not redelivered.
+	ErrNotInFlight = errors.New("kimberlite: message not in flight")
+)
+
+// Message is an event delivered by a Subscription.
+type Message struct {
+	kimberlite.Event
+	// Deliveries counts the deliveries of the message, this one
+	// included.
+	Deliveries int
+	// LastError is the error of the previous delivery, if any.
+	LastError error
+
+	sub   *Subscription
+	entry *entry
+}
+
+// Ack acknowledges that the message was processed.
+func (m *Message) Ack() error {
+	return m.sub.ack(m.entry)
+}
+
+// Nack reports that processing the message failed with err, which may
+// be nil, and schedules its redelivery.
+func (m *Message) Nack(err error, opts ...NackOption) error {
+	var n nack
+	for _, opt := range opts {
+		opt(&n)
+	}
+	return m.sub.nack(m.entry, m.Deliveries, err, n)
+}
+
+// NackOption configures a negative acknowledgement.
+type NackOption func(*nack)
+
+type nack struct {
+	delay    time.Duration
+	hasDelay bool
+}
+
+// WithDelay redelivers the message after d rather than after the
+// subscription's retry backoff.
+func WithDelay(d time.Duration) NackOption {
+	return func(n *nack) { n.delay, n.hasDelay = d, true }
+}
+
+// Backoff returns how long to wait before redelivering a message that
+// failed its deliveries-th delivery.
+type Backoff func(deliveries int) time.Duration
+
+// ExponentialBackoff waits base after the first failed delivery,
+// doubling after each, up to limit.
+func ExponentialBackoff(base, limit time.Duration) Backoff {
+	return func(deliveries int) time.Duration {
+		if shift := deliveries - 1; shift < 32 && base<<shift < limit {
+			return base << shift
+		}
+		return limit
+	}
+}
+
+// A ParkFunc takes charge of a message that failed its last allowed
+// delivery, e.g. by recording it for later inspection. The message is
+// then acknowledged; if the ParkFunc fails, it is redelivered after the
+// retry backoff and parked again once received.
+type ParkFunc func(ctx context.Context, m *Message) error
+
+// Subscription delivers the events of streams to competing consumers.
+// It is safe for concurrent use.
+type Subscription struct {
+	source        connect.EventSource
+	name          string
+	streams       []kimberlite.StreamID
+	store         connect.CheckpointStore
+	maxDeliveries int
+	backoff       Backoff
+	ackTimeout    time.Duration
+	window        int
+	maxBytes      uint64
+	interval      time.Duration
+	park          ParkFunc
+
+	wake chan struct{}
+
+	mu       sync.Mutex
+	loaded   bool
+	fetching bool
+	fetched  map[kimberlite.StreamID]kimberlite.Offset
+	saved    map[kimberlite.StreamID]kimberlite.Offset
+	entries  map[kimberlite.StreamID]map[kimberlite.Offset]*entry
+	count    int
+	ready    []*entry
+}
+
+// entry is the state of a message in the window.
+type entry struct {
+	ev         kimberlite.Event
+	deliveries int
+	lastErr    error
+	// inFlight is set while a consumer holds the message, until
+	// deadline.
+	inFlight bool
+	deadline time.Time
+	// due is when a message not in flight may be delivered.
+	due time.Time
+}
+
+// Option configures a Subscription.
+type Option func(*Subscription)
+
+// WithCheckpoints stores the subscription's per-stream positions in
+// store under its name, suffixed with "/<stream id>". Without it,
+// positions are kept in memory and a new Subscription starts from the
+// beginning of each stream.
+func WithCheckpoints(store connect.CheckpointStore) Option {
+	return func(s *Subscription) { s.store = store }
+}
+
+// WithMaxDeliveries sets how many times a message is delivered before
+// it is parked. The default is 10.
+func WithMaxDeliveries(n int) Option {
+	return func(s *Subscription) { s.maxDeliveries = max(n, 1) }
+}
+
+// WithRetryBackoff sets the delay before redelivering failed messages.
+// The default is ExponentialBackoff(time.Second, time.Minute).
+func WithRetryBackoff(b Backoff) Option {
+	return func(s *Subscription) { s.backoff = b }
+}
+
+// WithAckTimeout sets how long a consumer may hold a message before it
+// is redelivered. The default is 30 seconds.
+func WithAckTimeout(d time.Duration) Option {
+	return func(s *Subscription) { s.ackTimeout = d }
+}
+
+// WithWindow sets how many unacknowledged messages the subscription
+// holds before it stops reading ahead. The default is 1000.
+func WithWindow(n int) Option {
+	return func(s *Subscription) { s.window = max(n, 1) }
+}
+
+// WithBatchBytes sets the maxBytes of each read. The default is 1 MiB.
+func WithBatchBytes(n uint64) Option {
+	return func(s *Subscription) { s.maxBytes = n }
+}
+
+// WithPollInterval sets how long Receive waits after catching up
+// before reading again. The default is one second.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *Subscription) { s.interval = d }
+}
+
+// WithPark sets what is done with messages that failed their last
+// allowed delivery. By default they are dropped.
+func WithPark(f ParkFunc) Option {
+	return func(s *Subscription) { s.park = f }
+}
+
+// New returns a subscription to streams. name identifies its
+// checkpoints.
+func New(source connect.EventSource, name string, streams []kimberlite.StreamID, opts ...Option) *Subscription {
+	s := &Subscription{
+		source:        source,
+		name:          name,
+		streams:       append([]kimberlite.StreamID(nil), streams...),
+		maxDeliveries: 10,
+		backoff:       ExponentialBackoff(time.Second, time.Minute),
+		ackTimeout:    30 * time.Second,
+		window:        1000,
+		maxBytes:      1 << 20,
+		interval:      time.Second,
+		park:          func(context.Context, *Message) error { return nil },
+		wake:          make(chan struct{}, 1),
+		fetched:       make(map[kimberlite.StreamID]kimberlite.Offset),
+		saved:         make(map[kimberlite.StreamID]kimberlite.Offset),
+		entries:       make(map[kimberlite.StreamID]map[kimberlite.Offset]*entry),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.store == nil {
+		s.store = connect.NewMemoryCheckpoints()
+	}
+	return s
+}
+
+// Receive returns the next message, waiting for one until ctx is done.
+func (s *Subscription) Receive(ctx context.Context) (*Message, error) {
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		s.expire(now)
+		if e := s.due(now); e != nil {
+			if e.deliveries >= s.maxDeliveries {
+				s.mu.Unlock()
+				if err := s.parkEntry(ctx, e); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			e.deliveries++
+			e.inFlight, e.deadline = true, now.Add(s.ackTimeout)
+			m := &Message{Event: e.ev, Deliveries: e.deliveries, LastError: e.lastErr, sub: s, entry: e}
+			s.mu.Unlock()
+			return m, nil
+		}
+		wait := s.interval
+		if s.count < s.window && !s.fetching {
+			s.fetching = true
+			s.mu.Unlock()
+			n, err := s.fill(ctx)
+			s.mu.Lock()
+			s.fetching = false
+			s.mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			if n > 0 {
+				continue
+			}
+			s.mu.Lock()
+		}
+		if next, ok := s.nextTime(); ok {
+			wait = min(wait, max(time.Until(next), 0))
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// parkEntry parks the message of e, which failed its last delivery.
+func (s *Subscription) parkEntry(ctx context.Context, e *entry) error {
+	m := &Message{Event: e.ev, Deliveries: e.deliveries, LastError: e.lastErr, sub: s, entry: e}
+	if err := s.park(ctx, m); err != nil {
+		s.mu.Lock()
+		s.schedule(e, s.backoff(e.deliveries))
+		s.mu.Unlock()
+		return fmt.Errorf("kimberlite: park stream %d offset %d: %w", e.ev.StreamID, e.ev.Offset, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remove(e)
+}
+
+// expire schedules the redelivery of messages held past their
+// deadline. s.mu must be held.
+func (s *Subscription) expire(now time.Time) {
+	for _, byOffset := range s.entries {
+		for _, e := range byOffset {
+			if e.inFlight && !now.Before(e.deadline) {
+				e.lastErr = ErrAckTimeout
+				s.schedule(e, s.backoff(e.deliveries))
+			}
+		}
+	}
+}
+
+// due removes and returns the first ready message that is due, or nil.
+// s.mu must be held.
+func (s *Subscription) due(now time.Time) *entry {
+	for i := 0; i < len(s.ready); i++ {
+		e := s.ready[i]
+		if !s.held(e) || e.inFlight {
+			// Acknowledged after its redelivery was scheduled.
+			s.ready = append(s.ready[:i], s.ready[i+1:]...)
+			i--
+			continue
+		}
+		if !now.Before(e.due) {
+			s.ready = append(s.ready[:i], s.ready[i+1:]...)
+			return e
+		}
+	}
+	return nil
+}
+
+// nextTime returns when the next message becomes due or expires.
+// s.mu must be held.
+func (s *Subscription) nextTime() (time.Time, bool) {
+	var next time.Time
+	for _, byOffset := range s.entries {
+		for _, e := range byOffset {
+			t := e.due
+			if e.inFlight {
+				t = e.deadline
+			}
+			if next.IsZero() || t.Before(next) {
+				next = t
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// schedule makes e ready again after delay. s.mu must be held.
+func (s *Subscription) schedule(e *entry, delay time.Duration) {
+	e.inFlight = false
+	e.due = time.Now().Add(delay)
+	s.ready = append(s.ready, e)
+	s.signal()
+}
+
+func (s *Subscription) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Subscription) ack(e *entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.held(e) {
+		return ErrNotInFlight
+	}
+	return s.remove(e)
+}
+
+func (s *Subscription) nack(e *entry, delivery int, err error, n nack) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.held(e) || !e.inFlight || e.deliveries != delivery {
+		return ErrNotInFlight
+	}
+	delay := n.delay
+	if !n.hasDelay {
+		delay = s.backoff(e.deliveries)
+	}
+	e.lastErr = err
+	s.schedule(e, delay)
+	return nil
+}
+
+// held reports whether e is still in the window. s.mu must be held.
+func (s *Subscription) held(e *entry) bool {
+	return s.entries[e.ev.StreamID][e.ev.Offset] == e
+}
+
+// remove drops e from the window and advances its stream's checkpoint
+// to the lowest offset still held. s.mu must be held.
+func (s *Subscription) remove(e *entry) error {
+	if !s.held(e) {
+		return nil
+	}
+	id := e.ev.StreamID
+	delete(s.entries[id], e.ev.Offset)
+	s.count--
+	s.signal()
+	low := s.fetched[id]
+	for off := range s.entries[id] {
+		low = min(low, off)
+	}
+	if low <= s.saved[id] {
+		return nil
+	}
+	if err := s.store.Save(context.Background(), s.checkpointName(id), low); err != nil {
+		return fmt.Errorf("kimberlite: save checkpoint for stream %d: %w", id, err)
+	}
+	s.saved[id] = low
+	return nil
+}
+
+func (s *Subscription) checkpointName(id kimberlite.StreamID) string {
+	return s.name + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// fill reads the streams' next events into the window and returns the
+// number read. Only one fill runs at a time.
+func (s *Subscription) fill(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	loaded := s.loaded
+	s.mu.Unlock()
+	if !loaded {
+		for _, id := range s.streams {
+			pos, _, err := s.store.Load(ctx, s.checkpointName(id))
+			if err != nil {
+				return 0, fmt.Errorf("kimberlite: load checkpoint for stream %d: %w", id, err)
+			}
+			s.mu.Lock()
+			s.fetched[id], s.saved[id] = pos, pos
+			s.mu.Unlock()
+		}
+		s.mu.Lock()
+		s.loaded = true
+		s.mu.Unlock()
+	}
+
+	var total int
+	for _, id := range s.streams {
+		s.mu.Lock()
+		from, full := s.fetched[id], s.count >= s.window
+		s.mu.Unlock()
+		if full {
+			break
+		}
+		events, err := s.source.ReadEventsContext(ctx, id, from, s.maxBytes)
+		if err != nil {
+			return total, err
+		}
+		if len(events) == 0 {
+			continue
+		}
+		s.mu.Lock()
+		if s.entries[id] == nil {
+			s.entries[id] = make(map[kimberlite.Offset]*entry)
+		}
+		for _, ev := range events {
+			e := &entry{ev: ev}
+			s.entries[id][ev.Offset] = e
+			s.ready = append(s.ready, e)
+		}
+		s.count += len(events)
+		s.fetched[id] = events[len(events)-1].Offset + 1
+		s.mu.Unlock()
+		total += len(events)
+	}
+	return total, nil
+}
@@ -0,0 +1,176 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kimberlitedb/kimberlite-go"
+	"github.com/kimberlitedb/kimberlite-go/connect"
+)
+
+type fakeLog struct {
+	mu     sync.Mutex
+	events map[kimberlite.StreamID][]kimberlite.Event
+}
+
+func newLog(n map[kimberlite.StreamID]int) *fakeLog {
+	l := &fakeLog{events: map[kimberlite.StreamID][]kimberlite.Event{}}
+	for id, count := range n {
+		for i := 0; i < count; i++ {
+			l.events[id] = append(l.events[id], kimberlite.Event{StreamID: id, Offset: kimberlite.Offset(i)})
+		}
+	}
+	return l
+}
+
+func (l *fakeLog) ReadEventsContext(_ context.Context, id kimberlite.StreamID, from kimberlite.Offset, _ uint64) ([]kimberlite.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	evs := l.events[id]
+	if int(from) >= len(evs) {
+		return nil, nil
+	}
+	return append([]kimberlite.Event(nil), evs[from:min(int(from)+2, len(evs))]...), nil
+}
+
+func receive(t *testing.T, s *Subscription) *Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	m, err := s.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestAckNack(t *testing.T) {
+	store := connect.NewMemoryCheckpoints()
+	log := newLog(map[kimberlite.StreamID]int{1: 3})
+	var parked []*Message
+	s := New(log, "sub", []kimberlite.StreamID{1},
+		WithCheckpoints(store),
+		WithMaxDeliveries(2),
+		WithRetryBackoff(func(int) time.Duration { return 0 }),
+		WithPollInterval(time.Millisecond),
+		WithPark(func(_ context.Context, m *Message) error {
+			parked = append(parked, m)
+			return nil
+		}))
+
+	m0, m1 := receive(t, s), receive(t, s)
+	if m0.Offset != 0 || m1.Offset != 1 || m0.Deliveries != 1 {
+		t.Fatalf("received %d, %d", m0.Offset, m1.Offset)
+	}
+	if err := m1.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.Ack(); !errors.Is(err, ErrNotInFlight) {
+		t.Fatalf("second ack: %v", err)
+	}
+	// Offset 0 is still in flight: the checkpoint stays.
+	if pos, _, _ := store.Load(context.Background(), "sub/1"); pos != 0 {
+		t.Fatalf("checkpoint %d, expected 0", pos)
+	}
+
+	errPoison := errors.New("poison")
+	if err := m0.Nack(errPoison); err != nil {
+		t.Fatal(err)
+	}
+	again := receive(t, s)
+	if again.Offset != 0 || again.Deliveries != 2 || again.LastError != errPoison {
+		t.Fatalf("redelivery: offset %d, %d deliveries, %v", again.Offset, again.Deliveries, again.LastError)
+	}
+	if err := m0.Nack(nil); !errors.Is(err, ErrNotInFlight) {
+		t.Fatalf("nack of a redelivered message: %v", err)
+	}
+	again.Nack(errPoison)
+
+	// The third delivery is parked instead, and consumption goes on.
+	m2 := receive(t, s)
+	if m2.Offset != 2 || len(parked) != 1 || parked[0].Offset != 0 || parked[0].LastError != errPoison {
+		t.Fatalf("received %d, parked %v", m2.Offset, parked)
+	}
+	m2.Ack()
+	if pos, _, _ := store.Load(context.Background(), "sub/1"); pos != 3 {
+		t.Fatalf("checkpoint %d, expected 3", pos)
+	}
+}
+
+func TestNackDelayAndAckTimeout(t *testing.T) {
+	log := newLog(map[kimberlite.StreamID]int{1: 2})
+	s := New(log, "sub", []kimberlite.StreamID{1},
+		WithAckTimeout(20*time.Millisecond),
+		WithRetryBackoff(func(int) time.Duration { return 0 }),
+		WithPollInterval(time.Hour))
+
+	m0 := receive(t, s)
+	m0.Nack(nil, WithDelay(time.Hour))
+	m1 := receive(t, s)
+	if m1.Offset != 1 {
+		t.Fatalf("received %d, expected 1", m1.Offset)
+	}
+	// m1 is never acknowledged and comes back after the ack timeout.
+	start := time.Now()
+	m1 = receive(t, s)
+	if m1.Offset != 1 || m1.Deliveries != 2 || !errors.Is(m1.LastError, ErrAckTimeout) || time.Since(start) > time.Second {
+		t.Fatalf("redelivery: offset %d, %d deliveries, %v after %v", m1.Offset, m1.Deliveries, m1.LastError, time.Since(start))
+	}
+}
+
+func TestCompetingConsumers(t *testing.T) {
+	log := newLog(map[kimberlite.StreamID]int{1: 50, 2: 50})
+	store := connect.NewMemoryCheckpoints()
+	s := New(log, "sub", []kimberlite.StreamID{1, 2},
+		WithCheckpoints(store),
+		WithWindow(10),
+		WithRetryBackoff(func(int) time.Duration { return 0 }),
+		WithPollInterval(time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	seen := map[kimberlite.StreamID]map[kimberlite.Offset]bool{1: {}, 2: {}}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				m, err := s.Receive(ctx)
+				if err != nil {
+					return
+				}
+				if m.Offset%7 == 3 && m.Deliveries == 1 {
+					m.Nack(nil)
+					continue
+				}
+				mu.Lock()
+				seen[m.StreamID][m.Offset] = true
+				done := len(seen[1])+len(seen[2]) == 100
+				mu.Unlock()
+				m.Ack()
+				if done {
+					cancel()
+				}
+			}
+		}()
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		cancel()
+	}
+	wg.Wait()
+	if len(seen[1])+len(seen[2]) != 100 {
+		t.Fatalf("processed %d of 100", len(seen[1])+len(seen[2]))
+	}
+	for _, id := range []kimberlite.StreamID{1, 2} {
+		if pos, _, _ := store.Load(context.Background(), s.checkpointName(id)); pos != 50 {
+			t.Fatalf("stream %d checkpoint %d", id, pos)
+		}
+	}
+}
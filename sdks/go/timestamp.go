@@ -0,0 +1,50 @@
+package kimberlite
+
+import "time"
+
+// Kimberlite stores every timestamp as nanoseconds since the Unix
+// epoch, i.e. an absolute instant (TIMESTAMPTZ semantics). Values read
+// back are presented in the client's timestamp location, UTC unless
+// changed with WithTimestampLocation, so audit timestamps never depend
+// on the host's local zone.
+//
+// Columns that hold wall-clock readings without a zone (TIMESTAMP
+// semantics, e.g. a clinic's local appointment time) follow the
+// convention of storing the wall clock as if it were UTC. Bind those
+// with NewWallTimestamp and read them with Value.AsWallTime.
+
+// WithTimestampLocation sets the location in which timestamps in query
+// results, events and stream metadata are presented. The instant is
+// unaffected; only the time.Time's Location changes. Defaults to UTC.
+func WithTimestampLocation(loc *time.Location) Option {
+	return func(c *Client) {
+		if loc != nil {
+			c.tsLoc = loc
+		}
+	}
+}
+
+// NewWallTimestamp creates a timestamp value from the wall-clock
+// reading of t, discarding its zone. Use it for TIMESTAMP (without
+// time zone) columns: 09:00 in any location is stored as 09:00.
+func NewWallTimestamp(t time.Time) Value {
+	return NewTimestamp(time.Date(t.Year(), t.Month(), t.Day(),
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC))
+}
+
+// AsTimestampIn returns the timestamp instant presented in loc.
+func (v Value) AsTimestampIn(loc *time.Location) time.Time {
+	return v.AsTimestamp().In(loc)
+}
+
+// AsWallTime interprets the value as a wall-clock reading stored by
+// NewWallTimestamp and returns that reading in loc.
+func (v Value) AsWallTime(loc *time.Location) time.Time {
+	t, ok := v.TimestampOK()
+	if !ok {
+		return time.Time{}
+	}
+	u := t.UTC()
+	return time.Date(u.Year(), u.Month(), u.Day(),
+		u.Hour(), u.Minute(), u.Second(), u.Nanosecond(), loc)
+}
@@ -60,8 +60,42 @@ const (
 	ValueTypeBoolean
 	ValueTypeBytes
 	ValueTypeTimestamp
+	ValueTypeJSON
+	ValueTypeArray
+	ValueTypeInterval
+	ValueTypeRecord
 )
 
+// String returns the SQL-facing name of a ValueType.
+func (t ValueType) String() string {
+	switch t {
+	case ValueTypeNull:
+		return "null"
+	case ValueTypeInteger:
+		return "integer"
+	case ValueTypeFloat:
+		return "float"
+	case ValueTypeText:
+		return "text"
+	case ValueTypeBoolean:
+		return "boolean"
+	case ValueTypeBytes:
+		return "bytes"
+	case ValueTypeTimestamp:
+		return "timestamp"
+	case ValueTypeJSON:
+		return "json"
+	case ValueTypeArray:
+		return "array"
+	case ValueTypeInterval:
+		return "interval"
+	case ValueTypeRecord:
+		return "record"
+	default:
+		return "unknown"
+	}
+}
+
 // IsNull returns true if the value is NULL.
 func (v Value) IsNull() bool { return v.Type == ValueTypeNull }
 
@@ -154,6 +188,25 @@ type QueryResult struct {
 	Rows []map[string]Value
 	// RowsAffected is the number of rows affected by a write operation.
 	RowsAffected int64
+	// Stats describes how the query performed. It is set on results
+	// returned by Query and QueryContext.
+	Stats QueryStats
+
+	values   []Row
+	declared map[string]ColumnType
+}
+
+// ExecResult acknowledges a write statement.
+type ExecResult struct {
+	// RowsAffected is the number of rows inserted, updated or deleted.
+	RowsAffected int64
+	// LogOffset is the log position at which the change was committed.
+	// It is zero for a statement with a RETURNING clause, whose result
+	// the server does not give it in.
+	LogOffset Offset
+	// Returning holds the rows of a RETURNING clause, one for each row
+	// affected, or nil if the statement has none.
+	Returning *QueryResult
 }
 
 // StreamInfo describes a stream in the database.
@@ -164,10 +217,21 @@ type StreamInfo struct {
 	Name string
 	// DataClass is the data classification level.
 	DataClass DataClass
+	// Region is the region the stream's data is pinned to, or
+	// RegionGlobal.
+	Region Region
 	// CreatedAt is when the stream was created.
 	CreatedAt time.Time
+	// LegalHolds are the holds on the stream, loaded by
+	// Client.LoadLegalHolds. The server does not know of holds, so
+	// they are empty until loaded.
+	LegalHolds []LegalHold
 }
 
+// UnderLegalHold reports whether the stream has legal holds. It reports
+// false until Client.LoadLegalHolds has loaded them.
+func (s *StreamInfo) UnderLegalHold() bool { return len(s.LegalHolds) > 0 }
+
 // Event represents an event in a stream.
 type Event struct {
 	// Offset is the event's position in the log.
@@ -0,0 +1,143 @@
+package kimberlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Events written years ago stay in the log in the shape they were
+// written in. Upcasters let readers see them in the current shape: an
+// upcaster registered for an event type and version transforms an
+// event of that version into the next one, and ReadValues runs the
+// chain of upcasters from each event's version up to the current one
+// before decoding it:
+//
+//	reg.Register("PatientAdmitted", PatientAdmitted{}, nil) // version 3
+//	reg.RegisterUpcaster("PatientAdmitted", 1, kimberlite.JSONUpcaster(func(doc map[string]any) error {
+//	    doc["ward"] = "unknown" // v2 added the ward
+//	    return nil
+//	}))
+//	reg.RegisterUpcaster("PatientAdmitted", 2, kimberlite.JSONUpcaster(func(doc map[string]any) error {
+//	    doc["patient_id"] = doc["mrn"] // v3 renamed mrn
+//	    delete(doc, "mrn")
+//	    return nil
+//	}))
+//
+// The current version of an event type is one more than the highest
+// version it has an upcaster from; AppendValues records it in the
+// schema_version metadata of each event. Events without it are version
+// 1. An upcaster may also rename the event type, after which the chain
+// continues with the upcasters of the new type.
+
+// metaSchemaVersion is the envelope metadata key holding the version
+// of the payload's shape.
+const metaSchemaVersion = "schema_version"
+
+// An Upcaster transforms an event of one version of its type into the
+// next version. It may change the type, payload and metadata.
+type Upcaster func(env Envelope) (Envelope, error)
+
+// JSONUpcaster returns an upcaster editing JSON payloads as documents.
+func JSONUpcaster(edit func(doc map[string]any) error) Upcaster {
+	return func(env Envelope) (Envelope, error) {
+		var doc map[string]any
+		if err := decodeJSONNumbers(env.Data, &doc); err != nil {
+			return env, err
+		}
+		if doc == nil {
+			doc = make(map[string]any)
+		}
+		if err := edit(doc); err != nil {
+			return env, err
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return env, err
+		}
+		env.Data = data
+		return env, nil
+	}
+}
+
+// RegisterUpcaster registers up to transform events of eventType from
+// version from, counting from 1, into version from+1.
+func (r *CodecRegistry) RegisterUpcaster(eventType string, from int, up Upcaster) {
+	if eventType == "" || from < 1 || up == nil {
+		panic("kimberlite: RegisterUpcaster requires an event type, a version from 1 and an upcaster")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.upcasters == nil {
+		r.upcasters = make(map[string]map[int]Upcaster)
+	}
+	if r.upcasters[eventType] == nil {
+		r.upcasters[eventType] = make(map[int]Upcaster)
+	}
+	r.upcasters[eventType][from] = up
+}
+
+// SchemaVersion returns the current version of eventType.
+func (r *CodecRegistry) SchemaVersion(eventType string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schemaVersion(eventType)
+}
+
+// schemaVersion is SchemaVersion with r.mu held.
+func (r *CodecRegistry) schemaVersion(eventType string) int {
+	v := 1
+	for from := range r.upcasters[eventType] {
+		v = max(v, from+1)
+	}
+	return v
+}
+
+// EnvelopeVersion returns the version recorded in env, or 1 if it has
+// none.
+func EnvelopeVersion(env Envelope) (int, error) {
+	s, ok := env.Metadata[metaSchemaVersion]
+	if !ok {
+		return 1, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("kimberlite: invalid %s %q", metaSchemaVersion, s)
+	}
+	return v, nil
+}
+
+// Upcast runs the upcasters of env's type from its version up to the
+// current version of the type, and returns the result with its new
+// version recorded. Events of versions newer than the registry knows,
+// written by newer code, are returned unchanged.
+func (r *CodecRegistry) Upcast(env Envelope) (Envelope, error) {
+	v, err := EnvelopeVersion(env)
+	if err != nil {
+		return env, err
+	}
+	upcasted := false
+	for {
+		r.mu.RLock()
+		up := r.upcasters[env.Type][v]
+		r.mu.RUnlock()
+		if up == nil {
+			break
+		}
+		eventType := env.Type
+		if env, err = up(env); err != nil {
+			return env, fmt.Errorf("kimberlite: upcast %s from version %d: %w", eventType, v, err)
+		}
+		v++
+		upcasted = true
+	}
+	if upcasted {
+		meta := make(map[string]string, len(env.Metadata)+1)
+		for k, val := range env.Metadata {
+			meta[k] = val
+		}
+		meta[metaSchemaVersion] = strconv.Itoa(v)
+		env.Metadata = meta
+	}
+	return env, nil
+}
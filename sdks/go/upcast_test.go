@@ -0,0 +1,83 @@
+package kimberlite
+
+import (
+	"errors"
+	"testing"
+)
+
+type admittedV3 struct {
+	PatientID string `json:"patient_id"`
+	Ward      string `json:"ward"`
+}
+
+func TestUpcast(t *testing.T) {
+	reg := NewCodecRegistry()
+	reg.Register("Admitted", admittedV3{}, nil)
+	reg.RegisterUpcaster("Admitted", 1, JSONUpcaster(func(doc map[string]any) error {
+		doc["ward"] = "unknown"
+		return nil
+	}))
+	reg.RegisterUpcaster("Admitted", 2, JSONUpcaster(func(doc map[string]any) error {
+		doc["patient_id"] = doc["mrn"]
+		delete(doc, "mrn")
+		return nil
+	}))
+	if v := reg.SchemaVersion("Admitted"); v != 3 {
+		t.Fatalf("version %d, expected 3", v)
+	}
+
+	// A version 1 event, written before versions were recorded.
+	v1 := Envelope{Type: "Admitted", Metadata: map[string]string{metaContentType: "application/json"}, Data: []byte(`{"mrn":"m-1"}`)}
+	got, err := reg.decode(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := got.(*admittedV3); a.PatientID != "m-1" || a.Ward != "unknown" {
+		t.Fatalf("decoded %+v", a)
+	}
+	if _, ok := v1.Metadata[metaSchemaVersion]; ok {
+		t.Fatal("upcasting modified the original envelope")
+	}
+
+	// A version 2 event only runs the second upcaster.
+	v2 := Envelope{Type: "Admitted", Metadata: map[string]string{metaSchemaVersion: "2"}, Data: []byte(`{"mrn":"m-2","ward":"ICU"}`)}
+	up, err := reg.Upcast(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := EnvelopeVersion(up); v != 3 || string(up.Data) != `{"patient_id":"m-2","ward":"ICU"}` {
+		t.Fatalf("upcast to version %d: %s", v, up.Data)
+	}
+
+	// New events record the current version and are left alone.
+	enc := &admittedV3{PatientID: "p", Ward: "A"}
+	eventType, codec, data, _ := reg.encode(enc)
+	env, err := reg.newEnvelope(eventType, codec, enc, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Metadata[metaSchemaVersion] != "3" {
+		t.Fatalf("metadata %v", env.Metadata)
+	}
+	if up, _ := reg.Upcast(env); string(up.Data) != string(env.Data) {
+		t.Fatalf("current event upcast to %s", up.Data)
+	}
+
+	// Renamed types continue with the new type's chain.
+	reg.RegisterUpcaster("PatientAdmitted", 1, func(env Envelope) (Envelope, error) {
+		env.Type = "Admitted"
+		return env, nil
+	})
+	if up, err := reg.Upcast(Envelope{Type: "PatientAdmitted", Data: []byte(`{"mrn":"m-3","x":1}`)}); err != nil || up.Type != "Admitted" || up.Metadata[metaSchemaVersion] != "3" {
+		t.Fatalf("renamed: %+v, %v", up, err)
+	}
+
+	errBad := errors.New("bad")
+	reg.RegisterUpcaster("Broken", 1, func(env Envelope) (Envelope, error) { return env, errBad })
+	if _, err := reg.Upcast(Envelope{Type: "Broken"}); !errors.Is(err, errBad) {
+		t.Fatalf("failing upcaster: %v", err)
+	}
+	if _, err := reg.Upcast(Envelope{Type: "Admitted", Metadata: map[string]string{metaSchemaVersion: "x"}}); err == nil {
+		t.Fatal("invalid version accepted")
+	}
+}
@@ -0,0 +1,79 @@
+package kimberlite
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Upsert inserts rows into columns of table and, for each row whose
+// conflictKeys match an existing row, updates that row's other columns
+// instead. The server requires conflictKeys to be the table's primary
+// key. When every column is a key column, rows that exist already are
+// left as they are.
+//
+//	_, err := client.Upsert(ctx, "beds", []string{"ward", "bed"},
+//	    []string{"ward", "bed", "patient_id"},
+//	    [][]kimberlite.Value{{kimberlite.NewText("icu"), kimberlite.NewInt(3), kimberlite.NewInt(42)}})
+//
+// The rows go in a single statement; load many rows with an
+// InsertBatcher configured WithUpsert. RowsAffected counts the rows
+// inserted or updated.
+func (c *Client) Upsert(ctx context.Context, table string, conflictKeys, columns []string, rows [][]Value) (*ExecResult, error) {
+	sql, err := UpsertSQL(table, conflictKeys, columns, len(rows))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]Value, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return nil, &RowError{Row: int64(i + 1), Err: fmt.Errorf("%d values for %d columns", len(row), len(columns))}
+		}
+		args = append(args, row...)
+	}
+	return c.ExecContext(ctx, sql, args...)
+}
+
+// UpsertSQL returns the statement Upsert runs for rows rows, with the
+// values as positional parameters in row order:
+//
+//	INSERT INTO beds (ward, bed, patient_id) VALUES ($1, $2, $3)
+//	ON CONFLICT (ward, bed) DO UPDATE SET patient_id = EXCLUDED.patient_id
+func UpsertSQL(table string, conflictKeys, columns []string, rows int) (string, error) {
+	if rows < 1 {
+		return "", fmt.Errorf("kimberlite: no rows to upsert into %s", table)
+	}
+	clause, err := onConflict(table, conflictKeys, columns)
+	if err != nil {
+		return "", err
+	}
+	return insertSQL(table, columns, rows) + clause, nil
+}
+
+// onConflict returns the ON CONFLICT clause of an upsert into columns
+// of table.
+func onConflict(table string, conflictKeys, columns []string) (string, error) {
+	if err := checkIdentifiers(table, columns); err != nil {
+		return "", err
+	}
+	if len(conflictKeys) == 0 {
+		return "", fmt.Errorf("kimberlite: no conflict keys for %s", table)
+	}
+	for _, k := range conflictKeys {
+		if !slices.Contains(columns, k) {
+			return "", fmt.Errorf("kimberlite: conflict key %q is not an inserted column", k)
+		}
+	}
+	var set []string
+	for _, col := range columns {
+		if !slices.Contains(conflictKeys, col) {
+			set = append(set, col+" = EXCLUDED."+col)
+		}
+	}
+	clause := " ON CONFLICT (" + strings.Join(conflictKeys, ", ") + ") DO "
+	if len(set) == 0 {
+		return clause + "NOTHING", nil
+	}
+	return clause + "UPDATE SET " + strings.Join(set, ", "), nil
+}
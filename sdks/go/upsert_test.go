@@ -0,0 +1,52 @@
+package kimberlite
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUpsertSQL(t *testing.T) {
+	sql, err := UpsertSQL("beds", []string{"ward", "bed"}, []string{"ward", "bed", "patient_id", "since"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO beds (ward, bed, patient_id, since) VALUES ($1, $2, $3, $4), ($5, $6, $7, $8)" +
+		" ON CONFLICT (ward, bed) DO UPDATE SET patient_id = EXCLUDED.patient_id, since = EXCLUDED.since"
+	if sql != want {
+		t.Fatalf("sql = %q\nwant  %q", sql, want)
+	}
+	if sql, _ := UpsertSQL("seen", []string{"id"}, []string{"id"}, 1); sql != "INSERT INTO seen (id) VALUES ($1) ON CONFLICT (id) DO NOTHING" {
+		t.Fatalf("sql = %q", sql)
+	}
+	if _, err := UpsertSQL("beds", []string{"room"}, []string{"ward"}, 1); err == nil {
+		t.Fatal("conflict key outside the columns accepted")
+	}
+}
+
+func TestUpsertBatcher(t *testing.T) {
+	eb := &execBackend{}
+	c, err := Connect("test", WithTenant(1), WithBackend(eb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	if _, err := c.Upsert(ctx, "beds", []string{"id"}, []string{"id", "ward"}, [][]Value{{NewInt(1), NewText("icu")}}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.NewInsertBatcher("beds", []string{"id", "ward"}, WithUpsert("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(ctx, 2, "er"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO beds (id, ward) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET ward = EXCLUDED.ward"
+	if len(eb.sql) != 2 || eb.sql[0] != want || eb.sql[1] != want {
+		t.Fatalf("sent %q", eb.sql)
+	}
+}
@@ -0,0 +1,156 @@
+package kimberlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// The OK accessors mirror the As* methods but report whether the value
+// actually held that type, so NULL and type mismatches can be told
+// apart from genuine zero values:
+//
+//	age, ok := row["age"].IntOK()
+//	if !ok {
+//	    // NULL or not an integer
+//	}
+
+// IntOK returns the value as int64 and whether it is a non-NULL integer.
+func (v Value) IntOK() (int64, bool) {
+	n, ok := v.raw.(int64)
+	return n, ok && v.Type == ValueTypeInteger
+}
+
+// FloatOK returns the value as float64 and whether it is a non-NULL float.
+func (v Value) FloatOK() (float64, bool) {
+	f, ok := v.raw.(float64)
+	return f, ok && v.Type == ValueTypeFloat
+}
+
+// TextOK returns the value as string and whether it is non-NULL text.
+func (v Value) TextOK() (string, bool) {
+	s, ok := v.raw.(string)
+	return s, ok && v.Type == ValueTypeText
+}
+
+// BoolOK returns the value as bool and whether it is a non-NULL boolean.
+func (v Value) BoolOK() (bool, bool) {
+	b, ok := v.raw.(bool)
+	return b, ok && v.Type == ValueTypeBoolean
+}
+
+// BytesOK returns the value as []byte and whether it is non-NULL bytes.
+func (v Value) BytesOK() ([]byte, bool) {
+	b, ok := v.raw.([]byte)
+	return b, ok && v.Type == ValueTypeBytes
+}
+
+// TimestampOK returns the value as time.Time and whether it is a
+// non-NULL timestamp.
+func (v Value) TimestampOK() (time.Time, bool) {
+	t, ok := v.raw.(time.Time)
+	return t, ok && v.Type == ValueTypeTimestamp
+}
+
+// IntervalOK returns the value as an Interval and whether it is a
+// non-NULL interval or text that parses as one.
+func (v Value) IntervalOK() (Interval, bool) {
+	switch v.Type {
+	case ValueTypeInterval:
+		i, ok := v.raw.(Interval)
+		return i, ok
+	case ValueTypeText:
+		i, err := ParseInterval(v.AsText())
+		return i, err == nil
+	}
+	return Interval{}, false
+}
+
+// ArrayOK returns the elements of the value and whether it is a
+// non-NULL array or JSON text holding an array.
+func (v Value) ArrayOK() ([]Value, bool) {
+	elems := v.AsArray()
+	return elems, elems != nil
+}
+
+// Get converts v to T, returning ErrNullValue for NULL and an error
+// wrapping ErrTypeMismatch when v does not hold a T. Supported targets
+// are int64, float64, string, bool, []byte, time.Time, time.Duration,
+// Interval, []int64, []string, []Value, map[string]Value,
+// json.RawMessage and Value. For []int64 and []string every element
+// must be an integer or text respectively; the error names the first
+// that is not.
+//
+//	name, err := kimberlite.Get[string](row["name"])
+func Get[T any](v Value) (T, error) {
+	var zero T
+	if _, wantValue := any(zero).(Value); wantValue {
+		return any(v).(T), nil
+	}
+	if v.IsNull() {
+		return zero, ErrNullValue
+	}
+
+	var (
+		out any
+		ok  bool
+	)
+	switch any(zero).(type) {
+	case int64:
+		out, ok = v.IntOK()
+	case float64:
+		out, ok = v.FloatOK()
+	case string:
+		out, ok = v.TextOK()
+	case bool:
+		out, ok = v.BoolOK()
+	case []byte:
+		out, ok = v.BytesOK()
+	case time.Time:
+		out, ok = v.TimestampOK()
+	case Interval:
+		out, ok = v.IntervalOK()
+	case time.Duration:
+		var i Interval
+		i, ok = v.IntervalOK()
+		out = i.Duration()
+	case []Value:
+		out, ok = v.ArrayOK()
+	case map[string]Value:
+		out, ok = v.RecordOK()
+	case []int64:
+		elems, isArray := v.ArrayOK()
+		if !isArray {
+			break
+		}
+		ints := make([]int64, len(elems))
+		for i, e := range elems {
+			if ints[i], ok = e.IntOK(); !ok {
+				return zero, fmt.Errorf("%w: cannot convert element %d, %s, to int64", ErrTypeMismatch, i, e.Type)
+			}
+		}
+		out, ok = ints, true
+	case []string:
+		elems, isArray := v.ArrayOK()
+		if !isArray {
+			break
+		}
+		texts := make([]string, len(elems))
+		for i, e := range elems {
+			if texts[i], ok = e.TextOK(); !ok {
+				return zero, fmt.Errorf("%w: cannot convert element %d, %s, to string", ErrTypeMismatch, i, e.Type)
+			}
+		}
+		out, ok = texts, true
+	case json.RawMessage:
+		var raw json.RawMessage
+		raw, ok = v.rawJSON()
+		out = raw
+	default:
+		return zero, fmt.Errorf("%w: unsupported target type %T", ErrTypeMismatch, zero)
+	}
+	if !ok {
+		return zero, fmt.Errorf("%w: cannot convert %s to %T", ErrTypeMismatch, v.Type, zero)
+	}
+	return out.(T), nil
+}
@@ -0,0 +1,264 @@
+package kimberlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// NewArray creates an array value from its elements.
+func NewArray(elems ...Value) Value {
+	return Value{Type: ValueTypeArray, raw: append([]Value(nil), elems...)}
+}
+
+// NewInts creates an integer array value.
+func NewInts(ns []int64) Value {
+	elems := make([]Value, len(ns))
+	for i, n := range ns {
+		elems[i] = NewInt(n)
+	}
+	return Value{Type: ValueTypeArray, raw: elems}
+}
+
+// NewTexts creates a text array value.
+func NewTexts(ss []string) Value {
+	elems := make([]Value, len(ss))
+	for i, s := range ss {
+		elems[i] = NewText(s)
+	}
+	return Value{Type: ValueTypeArray, raw: elems}
+}
+
+// AsArray returns the elements of an array value.
+//
+// The wire protocol has no native array type, so array columns are
+// delivered as JSON text; text and JSON values holding a JSON array
+// are decoded element by element. Returns nil for anything else.
+func (v Value) AsArray() []Value {
+	switch v.Type {
+	case ValueTypeArray:
+		if elems, ok := v.raw.([]Value); ok {
+			return elems
+		}
+	case ValueTypeText, ValueTypeJSON:
+		raw, _ := v.rawJSON()
+		elems, ok := decodeJSONArray(raw)
+		if ok {
+			return elems
+		}
+	}
+	return nil
+}
+
+// AsInts returns the value as []int64. Non-integer elements become 0.
+func (v Value) AsInts() []int64 {
+	elems := v.AsArray()
+	if elems == nil {
+		return nil
+	}
+	out := make([]int64, len(elems))
+	for i, e := range elems {
+		out[i] = e.AsInt()
+	}
+	return out
+}
+
+// AsTexts returns the value as []string. Non-text elements become "".
+func (v Value) AsTexts() []string {
+	elems := v.AsArray()
+	if elems == nil {
+		return nil
+	}
+	out := make([]string, len(elems))
+	for i, e := range elems {
+		out[i] = e.AsText()
+	}
+	return out
+}
+
+func decodeJSONArray(raw json.RawMessage) ([]Value, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var items []any
+	if err := dec.Decode(&items); err != nil || items == nil {
+		return nil, false
+	}
+	out := make([]Value, len(items))
+	for i, item := range items {
+		out[i] = valueFromJSON(item)
+	}
+	return out, true
+}
+
+// valueFromJSON converts a decoded JSON scalar or array into a Value.
+// Objects are kept as JSON values.
+func valueFromJSON(x any) Value {
+	switch t := x.(type) {
+	case nil:
+		return NewNull()
+	case bool:
+		return NewBool(t)
+	case string:
+		return NewText(t)
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return NewInt(n)
+		}
+		f, _ := t.Float64()
+		return NewFloat(f)
+	case []any:
+		elems := make([]Value, len(t))
+		for i, e := range t {
+			elems[i] = valueFromJSON(e)
+		}
+		return Value{Type: ValueTypeArray, raw: elems}
+	default:
+		b, _ := json.Marshal(t)
+		return NewRawJSON(b)
+	}
+}
+
+// jsonEncodable converts a Value into something encoding/json renders
+// faithfully. Used for values the wire protocol can only carry as text.
+func (v Value) jsonEncodable() any {
+	switch v.Type {
+	case ValueTypeInteger:
+		return v.AsInt()
+	case ValueTypeFloat:
+		return v.AsFloat()
+	case ValueTypeText:
+		return v.AsText()
+	case ValueTypeBoolean:
+		return v.AsBool()
+	case ValueTypeBytes:
+		return v.AsBytes()
+	case ValueTypeTimestamp:
+		return v.AsTimestamp()
+	case ValueTypeJSON:
+		return v.AsRawJSON()
+	case ValueTypeArray:
+		elems := v.AsArray()
+		out := make([]any, len(elems))
+		for i, e := range elems {
+			out[i] = e.jsonEncodable()
+		}
+		return out
+	case ValueTypeRecord:
+		fields := v.AsRecord()
+		out := make(map[string]any, len(fields))
+		for k, f := range fields {
+			out[k] = f.jsonEncodable()
+		}
+		return out
+	case ValueTypeInterval:
+		return v.AsInterval().String()
+	default:
+		return nil
+	}
+}
+
+// expandArrayArgs rewrites "= ANY($N)" where argument N is an array
+// into "IN ($N, $M, ...)", binding one parameter per element. The
+// first element reuses $N; the rest are appended after the caller's
+// arguments, so other placeholder numbers are unchanged. An empty
+// array binds NULL, which matches no rows.
+//
+// An array bound this way must only be referenced from ANY(...); any
+// other occurrence of $N sees the first element.
+func expandArrayArgs(sql string, args []Value) (string, []Value) {
+	if !hasArrayArg(args) {
+		return sql, args
+	}
+	return expandArrayArgsAt(sql, scanPlaceholders(sql), args)
+}
+
+func hasArrayArg(args []Value) bool {
+	for _, a := range args {
+		if a.Type == ValueTypeArray {
+			return true
+		}
+	}
+	return false
+}
+
+// expandArrayArgsAt is expandArrayArgs with the $N placeholders of sql
+// already scanned.
+func expandArrayArgsAt(sql string, placeholders []placeholder, args []Value) (string, []Value) {
+	if !hasArrayArg(args) {
+		return sql, args
+	}
+
+	out := append([]Value(nil), args...)
+	lists := make(map[int]string)
+	var b strings.Builder
+	last, rewrote := 0, false
+	for _, p := range placeholders {
+		if p.index < 1 || p.index > len(args) || args[p.index-1].Type != ValueTypeArray {
+			continue
+		}
+		from, to, ok := anyCallSpan(sql, p)
+		if !ok {
+			continue
+		}
+		list, seen := lists[p.index]
+		if !seen {
+			elems := args[p.index-1].AsArray()
+			if len(elems) == 0 {
+				out[p.index-1] = NewNull()
+			} else {
+				out[p.index-1] = elems[0]
+			}
+			refs := []string{"$" + strconv.Itoa(p.index)}
+			for _, e := range elems[min(1, len(elems)):] {
+				out = append(out, e)
+				refs = append(refs, "$"+strconv.Itoa(len(out)))
+			}
+			list = "IN (" + strings.Join(refs, ", ") + ")"
+			lists[p.index] = list
+		}
+		b.WriteString(sql[last:from])
+		b.WriteString(list)
+		last, rewrote = to, true
+	}
+	if !rewrote {
+		return sql, args
+	}
+	b.WriteString(sql[last:])
+	return b.String(), out
+}
+
+// anyCallSpan reports the byte span of "= ANY ( $N )" surrounding p.
+func anyCallSpan(sql string, p placeholder) (int, int, bool) {
+	i := p.start - 1
+	for i >= 0 && isSpace(sql[i]) {
+		i--
+	}
+	if i < 0 || sql[i] != '(' {
+		return 0, 0, false
+	}
+	i--
+	for i >= 0 && isSpace(sql[i]) {
+		i--
+	}
+	if i < 2 || !strings.EqualFold(sql[i-2:i+1], "ANY") || (i >= 3 && isIdentChar(sql[i-3])) {
+		return 0, 0, false
+	}
+	i -= 3
+	for i >= 0 && isSpace(sql[i]) {
+		i--
+	}
+	if i < 0 || sql[i] != '=' || (i > 0 && strings.IndexByte("<>!", sql[i-1]) >= 0) {
+		return 0, 0, false
+	}
+	from := i
+
+	j := p.end
+	for j < len(sql) && isSpace(sql[j]) {
+		j++
+	}
+	if j >= len(sql) || sql[j] != ')' {
+		return 0, 0, false
+	}
+	return from, j + 1, true
+}
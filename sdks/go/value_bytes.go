@@ -0,0 +1,135 @@
+package kimberlite
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Binary parameters are bound as BYTES, exactly as given, NUL bytes
+// included. They are copied straight into the parameter buffer handed
+// to the native library, so a large payload is never held as an
+// intermediate Go string. BYTES columns come back in the engine's text
+// form, standard base64, which DecodeBytes decodes.
+//
+// Servers that predate binary parameters cannot decode a request
+// binding one. Servers that accept them advertise the capability
+// "query_param.bytes", which the client asks for, once per connection,
+// before sending the first statement binding one; on other servers
+// such statements fail with ErrBytesParamsUnsupported.
+
+// ErrBytesParamsUnsupported is returned for statements binding bytes
+// parameters on a connection to a server that does not accept them.
+var ErrBytesParamsUnsupported = errors.New("kimberlite: server does not accept bytes parameters")
+
+const capabilityBytesParams = "query_param.bytes"
+
+// serverCaps caches the capabilities of the server of the client's
+// connection.
+type serverCaps struct {
+	mu    sync.Mutex
+	known bool
+	caps  []string
+}
+
+func (s *serverCaps) reset() {
+	s.mu.Lock()
+	s.known, s.caps = false, nil
+	s.mu.Unlock()
+}
+
+// checkBytesParams fails with ErrBytesParamsUnsupported if args bind a
+// bytes parameter and the server does not advertise
+// capabilityBytesParams. c.mu must be held.
+func (c *Client) checkBytesParams(args []Value) error {
+	if !slices.ContainsFunc(args, func(v Value) bool { return v.Type == ValueTypeBytes }) {
+		return nil
+	}
+	c.caps.mu.Lock()
+	defer c.caps.mu.Unlock()
+	if !c.caps.known {
+		raw, err := ffiServerInfo(c.kmbHandle)
+		if err != nil {
+			return fmt.Errorf("kimberlite: server capabilities: %w", err)
+		}
+		info, err := decodeServerInfo(raw)
+		if err != nil {
+			return err
+		}
+		c.caps.known, c.caps.caps = true, info.Capabilities
+	}
+	if !slices.Contains(c.caps.caps, capabilityBytesParams) {
+		return ErrBytesParamsUnsupported
+	}
+	return nil
+}
+
+// blob is a bytes parameter whose content is read from r at bind time.
+type blob struct {
+	r    io.Reader
+	size int64
+}
+
+// NewBlob creates a bytes parameter that streams size bytes from r
+// when the statement is bound, instead of requiring the caller to
+// materialise the payload as a []byte first. r is consumed exactly
+// once; binding fails if it yields fewer than size bytes.
+func NewBlob(r io.Reader, size int64) Value {
+	return Value{Type: ValueTypeBytes, raw: blob{r: r, size: size}}
+}
+
+// DecodeBytes returns the binary content of the value. BYTES columns
+// are delivered as base64 text, so text values are decoded; bytes
+// values are returned as-is. A blob created with NewBlob is read to
+// completion.
+func (v Value) DecodeBytes() ([]byte, error) {
+	switch v.Type {
+	case ValueTypeBytes:
+		if b, ok := v.raw.(blob); ok {
+			return b.readAll()
+		}
+		return v.AsBytes(), nil
+	case ValueTypeText:
+		b, err := base64.StdEncoding.DecodeString(v.AsText())
+		if err != nil {
+			return nil, fmt.Errorf("%w: text is not base64 bytes: %v", ErrTypeMismatch, err)
+		}
+		return b, nil
+	case ValueTypeNull:
+		return nil, ErrNullValue
+	}
+	return nil, fmt.Errorf("%w: cannot convert %s to bytes", ErrTypeMismatch, v.Type)
+}
+
+// bytesLen returns the length of a bytes value.
+func (v Value) bytesLen() int {
+	if b, ok := v.raw.(blob); ok {
+		return int(b.size)
+	}
+	return len(v.AsBytes())
+}
+
+// writeBytesTo writes a bytes value into dst, which must be exactly
+// bytesLen long.
+func (v Value) writeBytesTo(dst []byte) error {
+	b, ok := v.raw.(blob)
+	if !ok {
+		copy(dst, v.AsBytes())
+		return nil
+	}
+	if n, err := io.ReadFull(b.r, dst); err != nil {
+		return fmt.Errorf("blob: read %d of %d bytes: %w", n, b.size, err)
+	}
+	return nil
+}
+
+func (b blob) readAll() ([]byte, error) {
+	out := make([]byte, b.size)
+	if _, err := io.ReadFull(b.r, out); err != nil {
+		return nil, fmt.Errorf("kimberlite: blob: %w", err)
+	}
+	return out, nil
+}
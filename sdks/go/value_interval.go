@@ -0,0 +1,245 @@
+package kimberlite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval is a SQL interval. Months and days are kept separate from
+// the clock component because their length in seconds depends on the
+// calendar date they are applied to.
+type Interval struct {
+	// Months is the number of calendar months (a year is 12).
+	Months int32
+	// Days is the number of calendar days.
+	Days int32
+	// Time is the sub-day component.
+	Time time.Duration
+}
+
+// Duration approximates the interval as a time.Duration, counting a
+// month as 30 days and a day as 24 hours (PostgreSQL's justify rules).
+// Use AddTo when the exact calendar result matters.
+func (i Interval) Duration() time.Duration {
+	days := int64(i.Months)*30 + int64(i.Days)
+	return time.Duration(days)*24*time.Hour + i.Time
+}
+
+// AddTo applies the interval to t using calendar arithmetic.
+func (i Interval) AddTo(t time.Time) time.Time {
+	return t.AddDate(0, int(i.Months), int(i.Days)).Add(i.Time)
+}
+
+// String renders the interval in ISO 8601 duration format, e.g.
+// "P1Y2M3DT4H5M6.5S". Each component carries its own sign.
+func (i Interval) String() string {
+	var b strings.Builder
+	b.WriteByte('P')
+	if y := i.Months / 12; y != 0 {
+		fmt.Fprintf(&b, "%dY", y)
+	}
+	if m := i.Months % 12; m != 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if i.Days != 0 {
+		fmt.Fprintf(&b, "%dD", i.Days)
+	}
+	if i.Time != 0 {
+		b.WriteByte('T')
+		d := i.Time
+		if h := d / time.Hour; h != 0 {
+			fmt.Fprintf(&b, "%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m != 0 {
+			fmt.Fprintf(&b, "%dM", m)
+			d -= m * time.Minute
+		}
+		if d != 0 {
+			b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+			b.WriteByte('S')
+		}
+	}
+	if b.Len() == 1 {
+		return "PT0S"
+	}
+	return b.String()
+}
+
+// ParseInterval parses an interval in ISO 8601 ("P1DT2H"), PostgreSQL
+// ("1 year 2 mons 3 days 04:05:06") or Go duration ("1h30m") format.
+func ParseInterval(s string) (Interval, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Interval{}, errors.New("kimberlite: empty interval")
+	}
+	if s[0] == 'P' || (len(s) > 1 && s[0] == '-' && s[1] == 'P') {
+		return parseISOInterval(s)
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return Interval{Time: d}, nil
+	}
+	return parseSQLInterval(s)
+}
+
+func parseISOInterval(s string) (Interval, error) {
+	neg := false
+	if s[0] == '-' {
+		neg, s = true, s[1:]
+	}
+	var iv Interval
+	inTime := false
+	rest := s[1:]
+	for rest != "" {
+		if rest[0] == 'T' {
+			inTime, rest = true, rest[1:]
+			continue
+		}
+		j := 0
+		for j < len(rest) && (isDigit(rest[j]) || rest[j] == '-' || rest[j] == '.' || rest[j] == '+') {
+			j++
+		}
+		if j == 0 || j == len(rest) {
+			return Interval{}, fmt.Errorf("kimberlite: invalid interval %q", s)
+		}
+		n, err := strconv.ParseFloat(rest[:j], 64)
+		if err != nil {
+			return Interval{}, fmt.Errorf("kimberlite: invalid interval %q", s)
+		}
+		unit := rest[j]
+		rest = rest[j+1:]
+		switch {
+		case !inTime && unit == 'Y':
+			iv.Months += int32(n * 12)
+		case !inTime && unit == 'M':
+			iv.Months += int32(n)
+		case !inTime && unit == 'W':
+			iv.Days += int32(n * 7)
+		case !inTime && unit == 'D':
+			iv.Days += int32(n)
+		case inTime && unit == 'H':
+			iv.Time += time.Duration(n * float64(time.Hour))
+		case inTime && unit == 'M':
+			iv.Time += time.Duration(n * float64(time.Minute))
+		case inTime && unit == 'S':
+			iv.Time += time.Duration(math.Round(n * float64(time.Second)))
+		default:
+			return Interval{}, fmt.Errorf("kimberlite: invalid interval unit %q in %q", unit, s)
+		}
+	}
+	if neg {
+		iv = Interval{Months: -iv.Months, Days: -iv.Days, Time: -iv.Time}
+	}
+	return iv, nil
+}
+
+func parseSQLInterval(s string) (Interval, error) {
+	var iv Interval
+	fields := strings.Fields(strings.ToLower(s))
+	ago := false
+	if n := len(fields); n > 0 && fields[n-1] == "ago" {
+		ago, fields = true, fields[:n-1]
+	}
+	for k := 0; k < len(fields); k++ {
+		f := fields[k]
+		if strings.Contains(f, ":") {
+			d, err := parseClock(f)
+			if err != nil {
+				return Interval{}, fmt.Errorf("kimberlite: invalid interval %q", s)
+			}
+			iv.Time += d
+			continue
+		}
+		if k+1 >= len(fields) {
+			return Interval{}, fmt.Errorf("kimberlite: invalid interval %q", s)
+		}
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return Interval{}, fmt.Errorf("kimberlite: invalid interval %q", s)
+		}
+		k++
+		switch strings.TrimSuffix(fields[k], "s") {
+		case "year":
+			iv.Months += int32(n * 12)
+		case "mon", "month":
+			iv.Months += int32(n)
+		case "week":
+			iv.Days += int32(n * 7)
+		case "day":
+			iv.Days += int32(n)
+		case "hour":
+			iv.Time += time.Duration(n * float64(time.Hour))
+		case "min", "minute":
+			iv.Time += time.Duration(n * float64(time.Minute))
+		case "sec", "second":
+			iv.Time += time.Duration(math.Round(n * float64(time.Second)))
+		default:
+			return Interval{}, fmt.Errorf("kimberlite: invalid interval unit %q in %q", fields[k], s)
+		}
+	}
+	if ago {
+		iv = Interval{Months: -iv.Months, Days: -iv.Days, Time: -iv.Time}
+	}
+	return iv, nil
+}
+
+// parseClock parses "[-]HH:MM[:SS[.fff]]".
+func parseClock(s string) (time.Duration, error) {
+	neg := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimLeft(s, "+-"), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, errors.New("invalid clock")
+	}
+	h, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	var sec float64
+	if len(parts) == 3 {
+		if sec, err = strconv.ParseFloat(parts[2], 64); err != nil {
+			return 0, err
+		}
+	}
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(math.Round(sec*float64(time.Second)))
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// NewInterval creates an interval value.
+func NewInterval(i Interval) Value { return Value{Type: ValueTypeInterval, raw: i} }
+
+// NewDuration creates an interval value with only a clock component.
+func NewDuration(d time.Duration) Value { return NewInterval(Interval{Time: d}) }
+
+// AsInterval returns the value as an Interval. Intervals are delivered
+// over the wire as text, so text values are parsed; anything else, or
+// unparseable text, yields the zero Interval.
+func (v Value) AsInterval() Interval {
+	switch v.Type {
+	case ValueTypeInterval:
+		if i, ok := v.raw.(Interval); ok {
+			return i
+		}
+	case ValueTypeText:
+		if i, err := ParseInterval(v.AsText()); err == nil {
+			return i
+		}
+	}
+	return Interval{}
+}
+
+// AsDuration returns the value as a time.Duration using the
+// approximation documented on Interval.Duration.
+func (v Value) AsDuration() time.Duration {
+	return v.AsInterval().Duration()
+}
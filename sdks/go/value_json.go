@@ -0,0 +1,67 @@
+package kimberlite
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// NewJSON creates a JSON value by marshalling v with encoding/json.
+// Use it to pass structured documents as query parameters:
+//
+//	doc, err := kimberlite.NewJSON(map[string]any{"allergies": []string{"penicillin"}})
+//	client.Exec("INSERT INTO charts (id, doc) VALUES ($1, $2)", kimberlite.NewInt(1), doc)
+func NewJSON(v any) (Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return Value{}, fmt.Errorf("kimberlite: encode json value: %w", err)
+	}
+	return Value{Type: ValueTypeJSON, raw: json.RawMessage(b)}, nil
+}
+
+// NewRawJSON creates a JSON value from an already-encoded document.
+// The bytes are not validated until they reach the server.
+func NewRawJSON(raw json.RawMessage) Value {
+	return Value{Type: ValueTypeJSON, raw: raw}
+}
+
+// AsJSON decodes the value into v using encoding/json.
+//
+// JSON and JSONB columns arrive over the wire as text, so both
+// ValueTypeJSON and ValueTypeText values are accepted. NULL decodes as
+// JSON null, leaving v untouched for non-pointer targets.
+func (v Value) AsJSON(target any) error {
+	raw, ok := v.rawJSON()
+	if !ok {
+		return fmt.Errorf("kimberlite: cannot decode %s value as json", v.Type)
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Errorf("kimberlite: decode json value: %w", err)
+	}
+	return nil
+}
+
+// AsRawJSON returns the encoded document, or nil if the value is not
+// JSON or text.
+func (v Value) AsRawJSON() json.RawMessage {
+	raw, _ := v.rawJSON()
+	if v.Type == ValueTypeNull {
+		return nil
+	}
+	return raw
+}
+
+func (v Value) rawJSON() (json.RawMessage, bool) {
+	switch v.Type {
+	case ValueTypeNull:
+		return json.RawMessage("null"), true
+	case ValueTypeJSON:
+		if raw, ok := v.raw.(json.RawMessage); ok {
+			return raw, true
+		}
+	case ValueTypeText:
+		if s, ok := v.raw.(string); ok {
+			return json.RawMessage(s), true
+		}
+	}
+	return nil, false
+}
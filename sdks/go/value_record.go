@@ -0,0 +1,162 @@
+package kimberlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// NewRecord creates a composite value from named fields.
+func NewRecord(fields map[string]Value) Value {
+	m := make(map[string]Value, len(fields))
+	for k, f := range fields {
+		m[k] = f
+	}
+	return Value{Type: ValueTypeRecord, raw: m}
+}
+
+// AsRecord returns the fields of a composite value.
+//
+// Nested structures reach the client as text: JSON objects, or ROW
+// values in the parenthesised form "(1,abc,)". JSON objects decode
+// field by field, with nested objects becoming records themselves.
+// ROW text carries no field names, so its fields are named f1, f2, ...
+// as the SQL standard names anonymous row fields, and each field is
+// text (an empty field is NULL). Returns nil for anything else.
+func (v Value) AsRecord() map[string]Value {
+	switch v.Type {
+	case ValueTypeRecord:
+		if m, ok := v.raw.(map[string]Value); ok {
+			return m
+		}
+	case ValueTypeJSON:
+		raw, _ := v.rawJSON()
+		if m, ok := decodeJSONRecord(raw); ok {
+			return m
+		}
+	case ValueTypeText:
+		s := v.AsText()
+		if m, ok := decodeJSONRecord(json.RawMessage(s)); ok {
+			return m
+		}
+		if m, ok := parseRowLiteral(s); ok {
+			return m
+		}
+	}
+	return nil
+}
+
+// RecordOK returns the fields of the value and whether it is a
+// composite value or text holding a JSON object or ROW literal.
+func (v Value) RecordOK() (map[string]Value, bool) {
+	m := v.AsRecord()
+	return m, m != nil
+}
+
+// Field returns the named field of a composite value, or NULL if the
+// value is not composite or has no such field. Dotted paths such as
+// "address.city" descend into nested records.
+func (v Value) Field(path string) Value {
+	cur := v
+	for _, name := range strings.Split(path, ".") {
+		f, ok := cur.AsRecord()[name]
+		if !ok {
+			return NewNull()
+		}
+		cur = f
+	}
+	return cur
+}
+
+func decodeJSONRecord(raw json.RawMessage) (map[string]Value, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil || obj == nil {
+		return nil, false
+	}
+	return recordFromJSON(obj), true
+}
+
+func recordFromJSON(obj map[string]any) map[string]Value {
+	m := make(map[string]Value, len(obj))
+	for k, x := range obj {
+		m[k] = nestedValueFromJSON(x)
+	}
+	return m
+}
+
+// nestedValueFromJSON is valueFromJSON with objects, including those
+// inside arrays, decoded as records.
+func nestedValueFromJSON(x any) Value {
+	switch t := x.(type) {
+	case map[string]any:
+		return Value{Type: ValueTypeRecord, raw: recordFromJSON(t)}
+	case []any:
+		elems := make([]Value, len(t))
+		for i, e := range t {
+			elems[i] = nestedValueFromJSON(e)
+		}
+		return Value{Type: ValueTypeArray, raw: elems}
+	}
+	return valueFromJSON(x)
+}
+
+// parseRowLiteral parses the text form of a ROW value: a parenthesised,
+// comma-separated field list where fields may be double-quoted (with
+// "" or \ escaping a quote) and an empty unquoted field is NULL.
+func parseRowLiteral(s string) (map[string]Value, bool) {
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return nil, false
+	}
+	body := s[1 : len(s)-1]
+	m := make(map[string]Value)
+	var (
+		field  strings.Builder
+		quoted bool // current field had quotes, so it is not NULL
+		inQ    bool
+	)
+	flush := func() {
+		name := "f" + strconv.Itoa(len(m)+1)
+		if field.Len() == 0 && !quoted {
+			m[name] = NewNull()
+		} else {
+			m[name] = NewText(field.String())
+		}
+		field.Reset()
+		quoted = false
+	}
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case inQ && c == '\\' && i+1 < len(body):
+			i++
+			field.WriteByte(body[i])
+		case inQ && c == '"':
+			if i+1 < len(body) && body[i+1] == '"' {
+				i++
+				field.WriteByte('"')
+			} else {
+				inQ = false
+			}
+		case inQ:
+			field.WriteByte(c)
+		case c == '"':
+			inQ, quoted = true, true
+		case c == ',':
+			flush()
+		default:
+			field.WriteByte(c)
+		}
+	}
+	if inQ {
+		return nil, false
+	}
+	flush()
+	return m, true
+}
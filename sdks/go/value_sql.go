@@ -0,0 +1,142 @@
+package kimberlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	_ driver.Valuer = Value{}
+	_ sql.Scanner   = (*Value)(nil)
+)
+
+// Value implements driver.Valuer so a Value can be passed as an
+// argument to any database/sql driver. Types without a driver.Value
+// equivalent are rendered the same way they travel on the wire: JSON
+// and arrays as JSON text, intervals as ISO 8601 text.
+func (v Value) Value() (driver.Value, error) {
+	switch v.Type {
+	case ValueTypeNull:
+		return nil, nil
+	case ValueTypeInteger:
+		return v.AsInt(), nil
+	case ValueTypeFloat:
+		return v.AsFloat(), nil
+	case ValueTypeBoolean:
+		return v.AsBool(), nil
+	case ValueTypeBytes:
+		return v.DecodeBytes()
+	case ValueTypeTimestamp:
+		return v.AsTimestamp(), nil
+	default:
+		return v.wireText()
+	}
+}
+
+// Scan implements sql.Scanner so a Value can be the destination of
+// rows.Scan from any database/sql driver.
+func (v *Value) Scan(src any) error {
+	val, err := ValueOf(src)
+	if err != nil {
+		return err
+	}
+	*v = val
+	return nil
+}
+
+// ValueOf converts a Go value into a Value. It accepts Value, any
+// driver.Valuer, nil, the integer, float, string, bool, []byte and
+// time.Time kinds, time.Duration, Interval, json.RawMessage, []int64,
+// []string, map[string]Value (a record) and pointers to any of these
+// (a nil pointer is NULL).
+func ValueOf(x any) (Value, error) {
+	switch t := x.(type) {
+	case nil:
+		return NewNull(), nil
+	case Value:
+		return t, nil
+	case *Value:
+		if t == nil {
+			return NewNull(), nil
+		}
+		return *t, nil
+	case int64:
+		return NewInt(t), nil
+	case int:
+		return NewInt(int64(t)), nil
+	case int32:
+		return NewInt(int64(t)), nil
+	case int16:
+		return NewInt(int64(t)), nil
+	case int8:
+		return NewInt(int64(t)), nil
+	case uint32:
+		return NewInt(int64(t)), nil
+	case uint16:
+		return NewInt(int64(t)), nil
+	case uint8:
+		return NewInt(int64(t)), nil
+	case float64:
+		return NewFloat(t), nil
+	case float32:
+		return NewFloat(float64(t)), nil
+	case string:
+		return NewText(t), nil
+	case bool:
+		return NewBool(t), nil
+	case json.RawMessage:
+		return NewRawJSON(t), nil
+	case []byte:
+		return NewBytes(t), nil
+	case time.Time:
+		return NewTimestamp(t), nil
+	case time.Duration:
+		return NewDuration(t), nil
+	case Interval:
+		return NewInterval(t), nil
+	case []int64:
+		return NewInts(t), nil
+	case []string:
+		return NewTexts(t), nil
+	case map[string]Value:
+		return NewRecord(t), nil
+	case driver.Valuer:
+		rv := reflect.ValueOf(t)
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return NewNull(), nil
+		}
+		dv, err := t.Value()
+		if err != nil {
+			return Value{}, err
+		}
+		return ValueOf(dv)
+	}
+
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return NewNull(), nil
+		}
+		return ValueOf(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u > 1<<63-1 {
+			return Value{}, fmt.Errorf("%w: %d overflows int64", ErrTypeMismatch, u)
+		}
+		return NewInt(int64(u)), nil
+	case reflect.Float32, reflect.Float64:
+		return NewFloat(rv.Float()), nil
+	case reflect.String:
+		return NewText(rv.String()), nil
+	case reflect.Bool:
+		return NewBool(rv.Bool()), nil
+	}
+	return Value{}, fmt.Errorf("%w: cannot convert %T to a kimberlite value", ErrTypeMismatch, x)
+}
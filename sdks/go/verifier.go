@@ -0,0 +1,243 @@
+package kimberlite
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A Verifier monitors streams for tampering the way a Certificate
+// Transparency monitor watches a log. On every round it fetches each
+// stream's latest signed tree head, checks the signature, and checks
+// with a consistency proof that the head extends the last one it
+// trusted; a head that does not is a divergence, reported to the
+// OnDivergence callback, and the trusted head is kept. Heads come from
+// a TreeHeadSource, usually an independent witness's published heads,
+// or a Witness run by the monitor itself:
+//
+//	witness := kimberlite.NewWitness(client, witnessKey)
+//	v := kimberlite.NewVerifier(witness, client, witnessKey.Public().(ed25519.PublicKey), streams,
+//	    kimberlite.OnDivergence(func(ctx context.Context, d kimberlite.Divergence) {
+//	        alert(d.Err)
+//	    }))
+//	go v.Run(ctx)
+//
+// Trusted heads live in memory; persist them with Trusted and restore
+// them with WithTrustedHeads, or a restarted verifier trusts whatever
+// heads it sees first.
+
+// TreeHeadSource returns the latest signed tree head of a stream.
+type TreeHeadSource interface {
+	LatestTreeHead(ctx context.Context, streamID StreamID) (SignedTreeHead, error)
+}
+
+// ConsistencyProver proves that a stream's tree of newSize events
+// extends its tree of oldSize events. *Client implements it.
+type ConsistencyProver interface {
+	ProveConsistencyContext(ctx context.Context, streamID StreamID, oldSize, newSize uint64) (*ConsistencyProof, error)
+}
+
+var (
+	_ ConsistencyProver = (*Client)(nil)
+	_ TreeHeadSource    = (*Witness)(nil)
+	_ ConsistencyProver = (*Witness)(nil)
+)
+
+// Witness computes tree heads from the stream and signs them.
+type Witness struct {
+	source eventReader
+	key    ed25519.PrivateKey
+}
+
+// NewWitness returns a Witness reading streams as stored with c and
+// signing heads with key.
+func NewWitness(c *Client, key ed25519.PrivateKey) *Witness {
+	return &Witness{source: storedEvents{c}, key: key}
+}
+
+// LatestTreeHead implements TreeHeadSource.
+func (w *Witness) LatestTreeHead(ctx context.Context, streamID StreamID) (SignedTreeHead, error) {
+	head, err := treeHead(ctx, w.source, streamID)
+	if err != nil {
+		return SignedTreeHead{}, err
+	}
+	return SignTreeHead(head, w.key), nil
+}
+
+// ProveConsistencyContext implements ConsistencyProver.
+func (w *Witness) ProveConsistencyContext(ctx context.Context, streamID StreamID, oldSize, newSize uint64) (*ConsistencyProof, error) {
+	return proveConsistency(ctx, w.source, streamID, oldSize, newSize)
+}
+
+// Divergence is a tree head that failed verification against the head
+// a Verifier trusted.
+type Divergence struct {
+	StreamID StreamID
+	// Trusted is the last verified head; zero on the first round.
+	Trusted SignedTreeHead
+	// Observed is the rejected head.
+	Observed SignedTreeHead
+	// Err wraps ErrInvalidProof.
+	Err error
+}
+
+// Verifier periodically verifies the tree heads of streams.
+type Verifier struct {
+	heads    TreeHeadSource
+	prover   ConsistencyProver
+	key      ed25519.PublicKey
+	streams  []StreamID
+	interval time.Duration
+	onDiverg func(ctx context.Context, d Divergence)
+	onError  func(ctx context.Context, err error)
+
+	mu      sync.Mutex
+	trusted map[StreamID]SignedTreeHead
+}
+
+// VerifierOption configures a Verifier.
+type VerifierOption func(*Verifier)
+
+// WithVerifyInterval sets how often Run verifies. The default is one
+// minute.
+func WithVerifyInterval(d time.Duration) VerifierOption {
+	return func(v *Verifier) { v.interval = d }
+}
+
+// OnDivergence sets the function called for every divergence.
+func OnDivergence(fn func(ctx context.Context, d Divergence)) VerifierOption {
+	return func(v *Verifier) { v.onDiverg = fn }
+}
+
+// OnVerifyError sets the function called when Run cannot fetch heads
+// or proofs. Such failures are retried on the next round.
+func OnVerifyError(fn func(ctx context.Context, err error)) VerifierOption {
+	return func(v *Verifier) { v.onError = fn }
+}
+
+// WithTrustedHeads starts the verifier trusting heads, typically saved
+// from Trusted by an earlier run. Their signatures are checked on the
+// first round.
+func WithTrustedHeads(heads ...SignedTreeHead) VerifierOption {
+	return func(v *Verifier) {
+		for _, h := range heads {
+			v.trusted[h.StreamID] = h
+		}
+	}
+}
+
+// NewVerifier returns a Verifier of streams, fetching heads from heads,
+// proofs from prover, and checking signatures against key.
+func NewVerifier(heads TreeHeadSource, prover ConsistencyProver, key ed25519.PublicKey, streams []StreamID, opts ...VerifierOption) *Verifier {
+	v := &Verifier{
+		heads:    heads,
+		prover:   prover,
+		key:      key,
+		streams:  streams,
+		interval: time.Minute,
+		trusted:  make(map[StreamID]SignedTreeHead),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Trusted returns the last verified head of each stream.
+func (v *Verifier) Trusted() []SignedTreeHead {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	out := make([]SignedTreeHead, 0, len(v.trusted))
+	for _, id := range v.streams {
+		if h, ok := v.trusted[id]; ok {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// Run verifies the streams every interval until ctx is done, returning
+// ctx's error.
+func (v *Verifier) Run(ctx context.Context) error {
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := v.Verify(ctx); err != nil && v.onError != nil && ctx.Err() == nil {
+			v.onError(ctx, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Verify runs one round, returning the divergences found and any error
+// fetching heads or proofs.
+func (v *Verifier) Verify(ctx context.Context) ([]Divergence, error) {
+	var divergences []Divergence
+	var errs []error
+	for _, id := range v.streams {
+		d, err := v.verifyStream(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("kimberlite: verify stream %d: %w", id, err))
+		}
+		if d != nil {
+			divergences = append(divergences, *d)
+			if v.onDiverg != nil {
+				v.onDiverg(ctx, *d)
+			}
+		}
+	}
+	return divergences, errors.Join(errs...)
+}
+
+func (v *Verifier) verifyStream(ctx context.Context, id StreamID) (*Divergence, error) {
+	next, err := v.heads.LatestTreeHead(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	trusted, ok := v.trusted[id]
+	v.mu.Unlock()
+
+	diverged := func(err error) (*Divergence, error) {
+		return &Divergence{StreamID: id, Trusted: trusted, Observed: next, Err: err}, nil
+	}
+	if err := next.Verify(v.key); err != nil {
+		return diverged(err)
+	}
+	if next.StreamID != id {
+		return diverged(fmt.Errorf("%w: head of stream %d returned for stream %d", ErrInvalidProof, next.StreamID, id))
+	}
+	if ok {
+		if err := trusted.Verify(v.key); err != nil {
+			return diverged(fmt.Errorf("trusted head: %w", err))
+		}
+		switch {
+		case next.TreeSize < trusted.TreeSize:
+			return diverged(fmt.Errorf("%w: stream %d shrank from %d to %d events", ErrInvalidProof, id, trusted.TreeSize, next.TreeSize))
+		case next.TreeSize == trusted.TreeSize:
+			if next.RootHash != trusted.RootHash {
+				return diverged(fmt.Errorf("%w: stream %d has two roots for %d events", ErrInvalidProof, id, next.TreeSize))
+			}
+		case trusted.TreeSize > 0:
+			proof, err := v.prover.ProveConsistencyContext(ctx, id, trusted.TreeSize, next.TreeSize)
+			if err != nil {
+				return nil, err
+			}
+			if err := proof.Verify(trusted, next, v.key); err != nil {
+				return diverged(err)
+			}
+		}
+	}
+
+	v.mu.Lock()
+	v.trusted[id] = next
+	v.mu.Unlock()
+	return nil, nil
+}
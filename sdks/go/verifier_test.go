@@ -0,0 +1,59 @@
+package kimberlite
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := fakeLog{}
+	add := func(data string) {
+		log[1] = append(log[1], Event{StreamID: 1, Offset: Offset(len(log[1])), Data: []byte(data)})
+	}
+	for i := 0; i < 5; i++ {
+		add(fmt.Sprintf("event-%d", i))
+	}
+	witness := &Witness{source: log, key: priv}
+	var seen []Divergence
+	v := NewVerifier(witness, witness, pub, []StreamID{1}, OnDivergence(func(_ context.Context, d Divergence) {
+		seen = append(seen, d)
+	}))
+
+	for round := 0; round < 2; round++ {
+		if d, err := v.Verify(ctx); err != nil || d != nil {
+			t.Fatalf("round %d: %v, %v", round, d, err)
+		}
+		add("more")
+	}
+	if trusted := v.Trusted(); len(trusted) != 1 || trusted[0].TreeSize != 6 {
+		t.Fatalf("trusted = %+v", trusted)
+	}
+
+	log[1][2].Data = []byte("rewritten")
+	d, err := v.Verify(ctx)
+	if err != nil || len(d) != 1 || !errors.Is(d[0].Err, ErrInvalidProof) || len(seen) != 1 {
+		t.Fatalf("rewrite not detected: %v, %v", d, err)
+	}
+	if v.Trusted()[0].TreeSize != 6 {
+		t.Fatal("trusted head replaced by divergent head")
+	}
+
+	log[1] = log[1][:4]
+	if d, _ := v.Verify(ctx); len(d) != 1 {
+		t.Fatal("truncation not detected")
+	}
+
+	_, other, _ := ed25519.GenerateKey(nil)
+	resumed := NewVerifier(&Witness{source: log, key: other}, witness, pub, []StreamID{1}, WithTrustedHeads(v.Trusted()...))
+	if d, _ := resumed.Verify(ctx); len(d) != 1 {
+		t.Fatal("head signed by another key accepted")
+	}
+}